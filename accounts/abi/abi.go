@@ -19,8 +19,11 @@ package abi
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+
+	"github.com/athofficial/go-ath/crypto"
 )
 
 // The ABI holds information about a contract's context and available
@@ -145,3 +148,28 @@ func (abi *ABI) MethodById(sigdata []byte) (*Method, error) {
 	}
 	return nil, fmt.Errorf("no method with id: %#x", sigdata[:4])
 }
+
+// revertSelector is the 4 byte method id of the `Error(string)` pseudo-function
+// solidity uses to encode revert reasons.
+var revertSelector = crypto.Keccak256([]byte("Error(string)"))[:4]
+
+// UnpackRevert resolves the abi-encoded revert reason. According to the solidity
+// spec, the reason passed to `require` and `revert` is abi-encoded as if it were
+// a call to a function `Error(string)`.
+func UnpackRevert(data []byte) (string, error) {
+	if len(data) < 4 {
+		return "", errors.New("abi: revert data too short")
+	}
+	if !bytes.Equal(data[:4], revertSelector) {
+		return "", errors.New("abi: revert data is not an Error(string) call")
+	}
+	typ, err := NewType("string", nil)
+	if err != nil {
+		return "", err
+	}
+	var reason string
+	if err := (Arguments{{Type: typ}}).Unpack(&reason, data[4:]); err != nil {
+		return "", err
+	}
+	return reason, nil
+}
@@ -745,3 +745,31 @@ func TestABI_MethodById(t *testing.T) {
 		t.Errorf("Expected error, nil is short to decode data")
 	}
 }
+
+func TestUnpackRevert(t *testing.T) {
+	var cases = []struct {
+		input     string
+		expect    string
+		expectErr bool
+	}{
+		{"", "", true},
+		{"08c379a1", "", true},
+		{"08c379a00000000000000000000000000000000000000000000000000000000000000020000000000000000000000000000000000000000000000000000000000000000d72657665727420726561736f6e00000000000000000000000000000000000000", "revert reason", false},
+	}
+	for index, c := range cases {
+		got, err := UnpackRevert(common.Hex2Bytes(c.input))
+		if c.expectErr {
+			if err == nil {
+				t.Errorf("case %d: expected error, got none", index)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("case %d: unexpected error: %v", index, err)
+			continue
+		}
+		if got != c.expect {
+			t.Errorf("case %d: reason mismatch: have %q, want %q", index, got, c.expect)
+		}
+	}
+}
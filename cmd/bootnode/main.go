@@ -28,7 +28,6 @@ import (
 	"github.com/athofficial/go-ath/crypto"
 	"github.com/athofficial/go-ath/log"
 	"github.com/athofficial/go-ath/p2p/discover"
-	"github.com/athofficial/go-ath/p2p/discv5"
 	"github.com/athofficial/go-ath/p2p/enode"
 	"github.com/athofficial/go-ath/p2p/nat"
 	"github.com/athofficial/go-ath/p2p/netutil"
@@ -118,20 +117,20 @@ func main() {
 		}
 	}
 
+	db, _ := enode.OpenDB("")
+	ln := enode.NewLocalNode(db, nodeKey)
+	ln.SetFallbackIP(realaddr.IP)
+	ln.SetFallbackUDP(realaddr.Port)
+	cfg := discover.Config{
+		PrivateKey:  nodeKey,
+		NetRestrict: restrictList,
+	}
 	if *runv5 {
-		if _, err := discv5.ListenUDP(nodeKey, conn, "", restrictList); err != nil {
-			utils.Fatalf("%v", err)
-		}
-	} else {
-		db, _ := enode.OpenDB("")
-		ln := enode.NewLocalNode(db, nodeKey)
-		cfg := discover.Config{
-			PrivateKey:  nodeKey,
-			NetRestrict: restrictList,
-		}
-		if _, err := discover.ListenUDP(conn, ln, cfg); err != nil {
+		if _, err := discover.ListenV5(conn, ln, cfg); err != nil {
 			utils.Fatalf("%v", err)
 		}
+	} else if _, err := discover.ListenUDP(conn, ln, cfg); err != nil {
+		utils.Fatalf("%v", err)
 	}
 
 	select {}
@@ -0,0 +1,370 @@
+// Copyright 2019 The go-ethereum Authors
+// This file is part of go-ethereum.
+//
+// go-ethereum is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// go-ethereum is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with go-ethereum. If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/athofficial/go-ath/crypto"
+	"github.com/athofficial/go-ath/log"
+	"github.com/athofficial/go-ath/p2p/discover"
+	"github.com/athofficial/go-ath/p2p/enode"
+	"github.com/athofficial/go-ath/params"
+	"gopkg.in/urfave/cli.v1"
+)
+
+var (
+	crawlCommand = cli.Command{
+		Name:      "crawl",
+		Usage:     "Updates a nodes.json file with random nodes found in the DHT",
+		ArgsUsage: "<nodes.json>",
+		Action:    crawlNodes,
+		Flags: []cli.Flag{
+			bootnodesFlag,
+			listenAddrFlag,
+			crawlTimeoutFlag,
+			crawlDialFlag,
+			crawlRevalidateFlag,
+		},
+	}
+)
+
+var (
+	bootnodesFlag = cli.StringFlag{
+		Name:  "bootnodes",
+		Usage: "Comma separated nodes used for bootstrapping (defaults to the mainnet bootnodes)",
+	}
+	listenAddrFlag = cli.StringFlag{
+		Name:  "addr",
+		Usage: "Listening address for discovery",
+		Value: "0.0.0.0:0",
+	}
+	crawlTimeoutFlag = cli.DurationFlag{
+		Name:  "timeout",
+		Usage: "Time limit for the crawl",
+		Value: 30 * time.Minute,
+	}
+	crawlDialFlag = cli.BoolFlag{
+		Name:  "dial",
+		Usage: "Perform an RLPx handshake with found nodes to record client and chain info",
+	}
+	crawlRevalidateFlag = cli.DurationFlag{
+		Name:  "revalidate",
+		Usage: "Minimum time between liveness checks of known nodes",
+		Value: 10 * time.Minute,
+	}
+)
+
+// crawlNodes performs crawlCommand.
+func crawlNodes(ctx *cli.Context) error {
+	nodesFile, err := getArg(ctx, 0, "nodes.json")
+	if err != nil {
+		return err
+	}
+	inputSet := make(nodeSet)
+	if _, err := os.Stat(nodesFile); err == nil {
+		if inputSet, err = loadNodesJSON(nodesFile); err != nil {
+			return err
+		}
+	}
+	disc, err := startDiscovery(ctx)
+	if err != nil {
+		return err
+	}
+	defer disc.Close()
+
+	c := newCrawler(inputSet, disc, ctx.Duration(crawlRevalidateFlag.Name))
+	if ctx.Bool(crawlDialFlag.Name) {
+		if c.dialer, err = newHandshaker(); err != nil {
+			return err
+		}
+		defer c.dialer.close()
+	}
+	output := c.run(ctx.Duration(crawlTimeoutFlag.Name))
+	return writeNodesJSON(nodesFile, output)
+}
+
+// startDiscovery creates a discovery v4 table listening on the configured address.
+func startDiscovery(ctx *cli.Context) (*discover.Table, error) {
+	bootnodes, err := parseBootnodes(ctx)
+	if err != nil {
+		return nil, err
+	}
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		return nil, err
+	}
+	db, err := enode.OpenDB("")
+	if err != nil {
+		return nil, err
+	}
+	ln := enode.NewLocalNode(db, key)
+
+	addr, err := net.ResolveUDPAddr("udp", ctx.String(listenAddrFlag.Name))
+	if err != nil {
+		return nil, err
+	}
+	conn, err := net.ListenUDP("udp", addr)
+	if err != nil {
+		return nil, err
+	}
+	ln.SetFallbackUDP(conn.LocalAddr().(*net.UDPAddr).Port)
+	return discover.ListenUDP(conn, ln, discover.Config{PrivateKey: key, Bootnodes: bootnodes})
+}
+
+func parseBootnodes(ctx *cli.Context) ([]*enode.Node, error) {
+	urls := params.MainnetBootnodes
+	if ctx.IsSet(bootnodesFlag.Name) {
+		urls = strings.Split(ctx.String(bootnodesFlag.Name), ",")
+	}
+	nodes := make([]*enode.Node, len(urls))
+	for i, url := range urls {
+		var err error
+		if nodes[i], err = enode.ParseV4(strings.TrimSpace(url)); err != nil {
+			return nil, fmt.Errorf("invalid bootstrap node %q: %v", url, err)
+		}
+	}
+	return nodes, nil
+}
+
+// crawlTable is the part of the discovery table used by the crawler.
+type crawlTable interface {
+	LookupRandom() []*enode.Node
+	Resolve(*enode.Node) *enode.Node
+}
+
+// crawler walks the discovery DHT, collecting the nodes it finds and checking
+// the liveness of the nodes already known.
+type crawler struct {
+	input      nodeSet
+	output     nodeSet
+	disc       crawlTable
+	dialer     *handshaker
+	revalidate time.Duration
+
+	lookups chan *enode.Node // Nodes found by random lookups
+	closed  chan struct{}
+}
+
+// crawlConcurrency is the number of nodes checked at the same time.
+const crawlConcurrency = 16
+
+func newCrawler(input nodeSet, disc crawlTable, revalidate time.Duration) *crawler {
+	c := &crawler{
+		input:      input,
+		output:     make(nodeSet, len(input)),
+		disc:       disc,
+		revalidate: revalidate,
+		lookups:    make(chan *enode.Node),
+		closed:     make(chan struct{}),
+	}
+	for id, n := range input {
+		c.output[id] = n
+	}
+	return c
+}
+
+// run crawls until the timeout expires, returning the updated node set.
+func (c *crawler) run(timeout time.Duration) nodeSet {
+	var (
+		timer   = time.NewTimer(timeout)
+		status  = time.NewTicker(8 * time.Second)
+		tasks   = make(chan *enode.Node)
+		results = make(chan nodeJSON)
+		wg      sync.WaitGroup
+
+		added, updated, removed int
+	)
+	defer timer.Stop()
+	defer status.Stop()
+
+	go c.runLookups()
+	go c.feedKnown(tasks)
+	for i := 0; i < crawlConcurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case n := <-tasks:
+					res := c.checkNode(n)
+					select {
+					case results <- res:
+					case <-c.closed:
+						return
+					}
+				case <-c.closed:
+					return
+				}
+			}
+		}()
+	}
+	// Forward lookup results to the workers. Known nodes are checked by feedKnown,
+	// and every other node is checked at most once per crawl.
+	go func() {
+		seen := make(map[enode.ID]bool)
+		for {
+			var n *enode.Node
+			select {
+			case n = <-c.lookups:
+			case <-c.closed:
+				return
+			}
+			if _, ok := c.input[n.ID()]; ok || seen[n.ID()] {
+				continue
+			}
+			seen[n.ID()] = true
+			select {
+			case tasks <- n:
+			case <-c.closed:
+				return
+			}
+		}
+	}()
+
+loop:
+	for {
+		select {
+		case res := <-results:
+			switch c.updateNode(res) {
+			case nodeAdded:
+				added++
+			case nodeRemoved:
+				removed++
+			case nodeUpdated:
+				updated++
+			}
+		case <-status.C:
+			log.Info("Crawling in progress", "added", added, "updated", updated, "removed", removed, "total", len(c.output))
+		case <-timer.C:
+			break loop
+		}
+	}
+	close(c.closed)
+	wg.Wait()
+	log.Info("Crawl finished", "added", added, "updated", updated, "removed", removed, "total", len(c.output))
+	return c.output
+}
+
+// runLookups performs random lookups until the crawler is closed.
+func (c *crawler) runLookups() {
+	for {
+		for _, n := range c.disc.LookupRandom() {
+			select {
+			case c.lookups <- n:
+			case <-c.closed:
+				return
+			}
+		}
+		select {
+		case <-c.closed:
+			return
+		default:
+		}
+	}
+}
+
+// feedKnown schedules liveness checks of the nodes from the input set.
+func (c *crawler) feedKnown(tasks chan<- *enode.Node) {
+	for _, entry := range c.input {
+		n, err := parseRecord(entry.Record)
+		if err != nil || time.Since(entry.LastCheck) < c.revalidate {
+			continue
+		}
+		select {
+		case tasks <- n:
+		case <-c.closed:
+			return
+		}
+	}
+}
+
+// checkNode verifies that the node can still be found in the DHT and optionally
+// dials it.
+func (c *crawler) checkNode(n *enode.Node) nodeJSON {
+	rec, err := encodeRecord(n)
+	if err != nil {
+		rec = n.String()
+	}
+	res := nodeJSON{Seq: n.Seq(), Record: rec, LastCheck: truncNow()}
+
+	if nn := c.disc.Resolve(n); nn != nil {
+		res.LastResponse = res.LastCheck
+		if c.dialer != nil {
+			res.Info = c.dialer.handshake(nn)
+		}
+	}
+	return res
+}
+
+const (
+	nodeSkipped = iota
+	nodeAdded
+	nodeUpdated
+	nodeRemoved
+)
+
+// updateNode merges the result of a node check into the output set.
+func (c *crawler) updateNode(res nodeJSON) int {
+	n, err := parseRecord(res.Record)
+	if err != nil {
+		return nodeSkipped
+	}
+	node, exists := c.output[n.ID()]
+
+	status := nodeUpdated
+	if !exists {
+		status = nodeAdded
+	}
+	// Update the record only if the node has a newer or more complete one.
+	if !exists || res.Seq > node.Seq || (strings.HasPrefix(res.Record, "enr:") && !strings.HasPrefix(node.Record, "enr:")) {
+		node.Seq, node.Record = res.Seq, res.Record
+	}
+	node.LastCheck = res.LastCheck
+	if res.Info != nil {
+		node.Info = res.Info
+	}
+	if res.LastResponse.IsZero() {
+		node.Score /= 2
+	} else {
+		node.Score++
+		if node.FirstResponse.IsZero() {
+			node.FirstResponse = res.LastResponse
+		}
+		node.LastResponse = res.LastResponse
+	}
+	// Drop the node if it didn't respond to any check.
+	if node.Score == 0 {
+		delete(c.output, n.ID())
+		if !exists {
+			return nodeSkipped
+		}
+		return nodeRemoved
+	}
+	c.output[n.ID()] = node
+	return status
+}
+
+// truncNow returns the current time rounded to seconds, to keep the JSON small.
+func truncNow() time.Time {
+	return time.Now().UTC().Truncate(1 * time.Second)
+}
@@ -0,0 +1,92 @@
+// Copyright 2019 The go-ethereum Authors
+// This file is part of go-ethereum.
+//
+// go-ethereum is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// go-ethereum is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with go-ethereum. If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/athofficial/go-ath/crypto"
+	"github.com/athofficial/go-ath/p2p/enode"
+)
+
+// fakeTable is a crawlTable which knows a fixed set of nodes, of which only
+// the live ones respond.
+type fakeTable struct {
+	nodes []*enode.Node
+	live  map[enode.ID]bool
+}
+
+func (t *fakeTable) LookupRandom() []*enode.Node {
+	time.Sleep(10 * time.Millisecond)
+	return t.nodes
+}
+
+func (t *fakeTable) Resolve(n *enode.Node) *enode.Node {
+	if t.live[n.ID()] {
+		return n
+	}
+	return nil
+}
+
+func testNode(t *testing.T, port int) *enode.Node {
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return enode.NewV4(&key.PublicKey, net.IP{127, 0, 0, 1}, port, port)
+}
+
+func TestCrawlIncremental(t *testing.T) {
+	var (
+		live  = testNode(t, 30303)
+		dead  = testNode(t, 30304)
+		known = testNode(t, 30305)
+		table = &fakeTable{
+			nodes: []*enode.Node{live, dead},
+			live:  map[enode.ID]bool{live.ID(): true},
+		}
+	)
+	// The known node was seen long ago and doesn't respond anymore.
+	past := truncNow().Add(-time.Hour)
+	input := nodeSet{known.ID(): {Record: known.String(), Score: 2, LastCheck: past, LastResponse: past}}
+
+	output := newCrawler(input, table, time.Minute).run(200 * time.Millisecond)
+	if len(output) != 2 {
+		t.Fatalf("wrong number of nodes in output: %d", len(output))
+	}
+	if _, ok := output[dead.ID()]; ok {
+		t.Error("unresponsive node was added")
+	}
+	if n := output[live.ID()]; n.Score != 1 || n.FirstResponse.IsZero() || n.Record != live.String() {
+		t.Errorf("wrong entry for live node: %+v", n)
+	}
+	if n := output[known.ID()]; n.Score != 1 || n.LastResponse != past || !n.LastCheck.After(past) {
+		t.Errorf("wrong entry for known node: %+v", n)
+	}
+
+	// A second run within the revalidation period doesn't check the known nodes
+	// again, so the failing one stays in the set.
+	output = newCrawler(output, table, time.Minute).run(200 * time.Millisecond)
+	if n := output[known.ID()]; n.Score != 1 {
+		t.Errorf("known node was revalidated too early: %+v", n)
+	}
+	if n := output[live.ID()]; n.Score != 1 {
+		t.Errorf("live node was revalidated too early: %+v", n)
+	}
+}
@@ -0,0 +1,279 @@
+// Copyright 2019 The go-ethereum Authors
+// This file is part of go-ethereum.
+//
+// go-ethereum is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// go-ethereum is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with go-ethereum. If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/athofficial/go-ath/common"
+	"github.com/athofficial/go-ath/crypto"
+	"github.com/athofficial/go-ath/p2p/dnsdisc"
+	"github.com/athofficial/go-ath/p2p/enode"
+	"gopkg.in/urfave/cli.v1"
+)
+
+var (
+	dnsCommand = cli.Command{
+		Name:  "dns",
+		Usage: "DNS Discovery Commands",
+		Subcommands: []cli.Command{
+			dnsSyncCommand,
+			dnsSignCommand,
+			dnsTXTCommand,
+		},
+	}
+	dnsSyncCommand = cli.Command{
+		Name:      "sync",
+		Usage:     "Download a DNS discovery tree",
+		ArgsUsage: "<url> [ <directory> ]",
+		Action:    dnsSync,
+	}
+	dnsSignCommand = cli.Command{
+		Name:      "sign",
+		Usage:     "Sign a DNS discovery tree",
+		ArgsUsage: "<tree-directory> <key-file>",
+		Action:    dnsSign,
+		Flags:     []cli.Flag{dnsDomainFlag, dnsSeqFlag},
+	}
+	dnsTXTCommand = cli.Command{
+		Name:      "to-txt",
+		Usage:     "Create a DNS TXT records for a discovery tree",
+		ArgsUsage: "<tree-directory> [ <output-file> ]",
+		Action:    dnsToTXT,
+	}
+)
+
+var (
+	dnsDomainFlag = cli.StringFlag{
+		Name:  "domain",
+		Usage: "Domain name of the tree",
+	}
+	dnsSeqFlag = cli.UintFlag{
+		Name:  "seq",
+		Usage: "New sequence number of the tree",
+	}
+)
+
+const (
+	treeMetaFile  = "enrtree-info.json"
+	treeNodesFile = "nodes.json"
+)
+
+// dnsSync performs dnsSyncCommand.
+func dnsSync(ctx *cli.Context) error {
+	url, err := getArg(ctx, 0, "url")
+	if err != nil {
+		return err
+	}
+	outdir := ctx.Args().Get(1)
+	client, err := dnsdisc.NewClient(dnsdisc.Config{})
+	if err != nil {
+		return err
+	}
+	t, err := client.SyncTree(url)
+	if err != nil {
+		return err
+	}
+	def := treeToDefinition(url, t)
+	def.Meta.LastModified = time.Now()
+	if outdir == "" {
+		outdir = "."
+	}
+	return writeTreeDefinition(outdir, def)
+}
+
+// dnsSign performs dnsSignCommand.
+func dnsSign(ctx *cli.Context) error {
+	defdir, err := getArg(ctx, 0, "tree-directory")
+	if err != nil {
+		return err
+	}
+	keyfile, err := getArg(ctx, 1, "key-file")
+	if err != nil {
+		return err
+	}
+	def, err := loadTreeDefinition(defdir)
+	if err != nil {
+		return err
+	}
+	domain, err := treeDomain(ctx, defdir, def)
+	if err != nil {
+		return err
+	}
+	key, err := crypto.LoadECDSA(keyfile)
+	if err != nil {
+		return fmt.Errorf("can't load signing key: %v", err)
+	}
+	seq := def.Meta.Seq + 1
+	if ctx.IsSet(dnsSeqFlag.Name) {
+		seq = ctx.Uint(dnsSeqFlag.Name)
+	}
+	t, err := dnsdisc.MakeTree(seq, def.Nodes, def.Meta.Links)
+	if err != nil {
+		return err
+	}
+	url, err := t.Sign(key, domain)
+	if err != nil {
+		return fmt.Errorf("can't sign: %v", err)
+	}
+	def = treeToDefinition(url, t)
+	def.Meta.LastModified = time.Now()
+	return writeTreeMetadata(defdir, def)
+}
+
+// treeDomain returns the domain name of the tree, taken from the --domain flag
+// or the URL of a previous signature.
+func treeDomain(ctx *cli.Context, dir string, def *dnsDefinition) (string, error) {
+	if ctx.IsSet(dnsDomainFlag.Name) {
+		return ctx.String(dnsDomainFlag.Name), nil
+	}
+	if def.Meta.URL != "" {
+		domain, _, err := dnsdisc.ParseURL(def.Meta.URL)
+		if err != nil {
+			return "", fmt.Errorf("invalid URL in %s: %v", filepath.Join(dir, treeMetaFile), err)
+		}
+		return domain, nil
+	}
+	return "", fmt.Errorf("missing --%s flag", dnsDomainFlag.Name)
+}
+
+// dnsToTXT performs dnsTXTCommand.
+func dnsToTXT(ctx *cli.Context) error {
+	defdir, err := getArg(ctx, 0, "tree-directory")
+	if err != nil {
+		return err
+	}
+	output := ctx.Args().Get(1)
+	if output == "" {
+		output = "-" // default to stdout
+	}
+	def, err := loadTreeDefinition(defdir)
+	if err != nil {
+		return err
+	}
+	domain, pubkey, err := dnsdisc.ParseURL(def.Meta.URL)
+	if err != nil {
+		return fmt.Errorf("tree is not signed, run %q first", "devp2p dns sign")
+	}
+	t, err := dnsdisc.MakeTree(def.Meta.Seq, def.Nodes, def.Meta.Links)
+	if err != nil {
+		return err
+	}
+	if err := t.SetSignature(pubkey, def.Meta.Sig); err != nil {
+		return fmt.Errorf("invalid signature on tree, run %q to update it: %v", "devp2p dns sign", err)
+	}
+	return writeJSON(output, t.ToTXT(domain))
+}
+
+// dnsDefinition is the on-disk representation of a tree: the metadata file
+// and the node set.
+type dnsDefinition struct {
+	Meta  dnsMetaJSON
+	Nodes []*enode.Node
+}
+
+type dnsMetaJSON struct {
+	URL          string    `json:"url,omitempty"`
+	Seq          uint      `json:"seq"`
+	Sig          string    `json:"signature,omitempty"`
+	Links        []string  `json:"links"`
+	LastModified time.Time `json:"lastModified"`
+}
+
+func treeToDefinition(url string, t *dnsdisc.Tree) *dnsDefinition {
+	meta := dnsMetaJSON{
+		URL:   url,
+		Seq:   t.Seq(),
+		Sig:   t.Signature(),
+		Links: t.Links(),
+	}
+	if meta.Links == nil {
+		meta.Links = []string{}
+	}
+	return &dnsDefinition{Meta: meta, Nodes: t.Nodes()}
+}
+
+// loadTreeDefinition loads a directory in 'definition' format.
+func loadTreeDefinition(directory string) (*dnsDefinition, error) {
+	metaFile, nodesFile := treeDefinitionFiles(directory)
+	var def dnsDefinition
+	if err := common.LoadJSON(metaFile, &def.Meta); err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	if def.Meta.Links == nil {
+		def.Meta.Links = []string{}
+	}
+	// Check link syntax.
+	for _, link := range def.Meta.Links {
+		if _, _, err := dnsdisc.ParseURL(link); err != nil {
+			return nil, fmt.Errorf("invalid link %q: %v", link, err)
+		}
+	}
+	// Check/convert nodes.
+	ns, err := loadNodesJSON(nodesFile)
+	if err != nil {
+		return nil, err
+	}
+	// Only nodes with a signed record can be published, v4 nodes found by the
+	// crawler are skipped.
+	var skipped int
+	for id, n := range ns {
+		if !strings.HasPrefix(n.Record, "enr:") {
+			delete(ns, id)
+			skipped++
+		}
+	}
+	if skipped > 0 {
+		fmt.Fprintf(os.Stderr, "Skipping %d nodes without signed record\n", skipped)
+	}
+	if def.Nodes, err = ns.nodes(); err != nil {
+		return nil, err
+	}
+	return &def, nil
+}
+
+// writeTreeDefinition writes a DNS node tree definition to the given directory.
+func writeTreeDefinition(directory string, def *dnsDefinition) error {
+	if err := os.MkdirAll(directory, 0755); err != nil {
+		return err
+	}
+	_, nodesFile := treeDefinitionFiles(directory)
+	ns, err := makeNodeSet(def.Nodes)
+	if err != nil {
+		return err
+	}
+	if err := writeNodesJSON(nodesFile, ns); err != nil {
+		return err
+	}
+	return writeTreeMetadata(directory, def)
+}
+
+// writeTreeMetadata writes the metadata file of a tree definition.
+func writeTreeMetadata(directory string, def *dnsDefinition) error {
+	metaFile, _ := treeDefinitionFiles(directory)
+	return writeJSON(metaFile, def.Meta)
+}
+
+func treeDefinitionFiles(directory string) (string, string) {
+	meta := filepath.Join(directory, treeMetaFile)
+	nodes := filepath.Join(directory, treeNodesFile)
+	return meta, nodes
+}
@@ -0,0 +1,239 @@
+// Copyright 2019 The go-ethereum Authors
+// This file is part of go-ethereum.
+//
+// go-ethereum is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// go-ethereum is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with go-ethereum. If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/athofficial/go-ath/common"
+	"github.com/athofficial/go-ath/core/forkid"
+	"github.com/athofficial/go-ath/crypto"
+	"github.com/athofficial/go-ath/log"
+	"github.com/athofficial/go-ath/p2p"
+	"github.com/athofficial/go-ath/p2p/enode"
+	"github.com/athofficial/go-ath/rlp"
+)
+
+const (
+	handshakeTimeout = 10 * time.Second
+
+	ethStatusMsg  = 0x00 // Code of the eth Status message
+	ethMaxMsgSize = 10 * 1024
+)
+
+// nodeInfo is the result of an RLPx handshake with a node.
+type nodeInfo struct {
+	Name     string     `json:"name,omitempty"`
+	Caps     []string   `json:"caps,omitempty"`
+	Status   *ethStatus `json:"status,omitempty"`
+	Error    string     `json:"error,omitempty"`
+	LastDial time.Time  `json:"lastDial"`
+}
+
+// ethStatus is the eth protocol handshake advertised by a node.
+type ethStatus struct {
+	ProtocolVersion uint32      `json:"protocolVersion"`
+	NetworkID       uint64      `json:"networkId"`
+	TD              *big.Int    `json:"td"`
+	Head            common.Hash `json:"head"`
+	Genesis         common.Hash `json:"genesis"`
+	ForkID          *forkid.ID  `json:"forkId,omitempty"`
+}
+
+// statusPacket is the eth Status message of all protocol versions, eth/64
+// appends the fork ID.
+type statusPacket struct {
+	ProtocolVersion uint32
+	NetworkID       uint64
+	TD              *big.Int
+	Head            common.Hash
+	Genesis         common.Hash
+	Rest            []rlp.RawValue `rlp:"tail"`
+}
+
+// handshaker dials nodes and records their devp2p and eth handshakes. It uses
+// a non-listening p2p server which only accepts the connections it creates.
+type handshaker struct {
+	srv    *p2p.Server
+	dialer net.Dialer
+
+	lock    sync.Mutex
+	pending map[enode.ID]chan *handshakeResult // Deliveries of in-progress handshakes
+}
+
+// handshakeResult is delivered by the eth protocol handler of a dialed peer.
+type handshakeResult struct {
+	name   string
+	caps   []p2p.Cap
+	status *ethStatus
+}
+
+func newHandshaker() (*handshaker, error) {
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		return nil, err
+	}
+	h := &handshaker{
+		dialer:  net.Dialer{Timeout: handshakeTimeout},
+		pending: make(map[enode.ID]chan *handshakeResult),
+	}
+	h.srv = &p2p.Server{Config: p2p.Config{
+		PrivateKey:  key,
+		Name:        "devp2p-crawler",
+		MaxPeers:    1000,
+		NoDiscovery: true,
+		NoDial:      true,
+		Protocols: []p2p.Protocol{
+			h.protocol(65, 17),
+			h.protocol(64, 17),
+			h.protocol(63, 17),
+			h.protocol(62, 8),
+		},
+		Logger: log.New("module", "crawler"),
+	}}
+	if err := h.srv.Start(); err != nil {
+		return nil, err
+	}
+	return h, nil
+}
+
+func (h *handshaker) close() {
+	h.srv.Stop()
+}
+
+// protocol creates an eth protocol instance which only reads the Status message
+// of the remote node and disconnects afterwards.
+func (h *handshaker) protocol(version uint, length uint64) p2p.Protocol {
+	return p2p.Protocol{
+		Name:    "eth",
+		Version: version,
+		Length:  length,
+		Run: func(p *p2p.Peer, rw p2p.MsgReadWriter) error {
+			status, err := readStatus(rw)
+			h.lock.Lock()
+			ch := h.pending[p.ID()]
+			h.lock.Unlock()
+			if ch != nil {
+				ch <- &handshakeResult{p.Name(), p.Caps(), status} // buffered, doesn't block
+			}
+			return err
+		},
+	}
+}
+
+var errUnexpectedMsg = errors.New("first message is not Status")
+
+func readStatus(rw p2p.MsgReadWriter) (*ethStatus, error) {
+	msg, err := rw.ReadMsg()
+	if err != nil {
+		return nil, err
+	}
+	defer msg.Discard()
+
+	if msg.Code != ethStatusMsg {
+		return nil, errUnexpectedMsg
+	}
+	if msg.Size > ethMaxMsgSize {
+		return nil, fmt.Errorf("status too large: %d bytes", msg.Size)
+	}
+	var packet statusPacket
+	if err := msg.Decode(&packet); err != nil {
+		return nil, err
+	}
+	status := &ethStatus{
+		ProtocolVersion: packet.ProtocolVersion,
+		NetworkID:       packet.NetworkID,
+		TD:              packet.TD,
+		Head:            packet.Head,
+		Genesis:         packet.Genesis,
+	}
+	if len(packet.Rest) > 0 {
+		status.ForkID = new(forkid.ID)
+		if err := rlp.DecodeBytes(packet.Rest[0], status.ForkID); err != nil {
+			status.ForkID = nil
+		}
+	}
+	return status, nil
+}
+
+// handshake dials the node and returns its client name, capabilities and,
+// if it runs the eth protocol, its Status.
+func (h *handshaker) handshake(n *enode.Node) *nodeInfo {
+	info := &nodeInfo{LastDial: truncNow()}
+
+	ch := make(chan *handshakeResult, 1)
+	h.lock.Lock()
+	h.pending[n.ID()] = ch
+	h.lock.Unlock()
+	defer func() {
+		h.lock.Lock()
+		delete(h.pending, n.ID())
+		h.lock.Unlock()
+	}()
+
+	fd, err := h.dialer.Dial("tcp", (&net.TCPAddr{IP: n.IP(), Port: n.TCP()}).String())
+	if err != nil {
+		info.Error = err.Error()
+		return info
+	}
+	if err := h.srv.SetupConn(fd, 0, n); err != nil {
+		info.Error = err.Error()
+		return info
+	}
+	// The handshakes succeeded, so the peer was added to the server. Peers not
+	// running eth are reported right away, the others once their Status arrived.
+	for _, p := range h.srv.Peers() {
+		if p.ID() == n.ID() {
+			defer p.Disconnect(p2p.DiscQuitting)
+			if !hasEth(p.Caps()) {
+				info.fill(p.Name(), p.Caps())
+				return info
+			}
+		}
+	}
+	select {
+	case res := <-ch:
+		info.fill(res.name, res.caps)
+		if info.Status = res.status; info.Status == nil {
+			info.Error = "invalid eth status"
+		}
+	case <-time.After(handshakeTimeout):
+		info.Error = "eth status timeout"
+	}
+	return info
+}
+
+func (info *nodeInfo) fill(name string, caps []p2p.Cap) {
+	info.Name = name
+	for _, c := range caps {
+		info.Caps = append(info.Caps, c.String())
+	}
+}
+
+func hasEth(caps []p2p.Cap) bool {
+	for _, c := range caps {
+		if c.Name == "eth" {
+			return true
+		}
+	}
+	return false
+}
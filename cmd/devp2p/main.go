@@ -0,0 +1,56 @@
+// Copyright 2019 The go-ethereum Authors
+// This file is part of go-ethereum.
+//
+// go-ethereum is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// go-ethereum is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with go-ethereum. If not, see <http://www.gnu.org/licenses/>.
+
+// devp2p is a utility for node operators working with the p2p network, e.g.
+// for publishing DNS node lists.
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/athofficial/go-ath/cmd/utils"
+	"gopkg.in/urfave/cli.v1"
+)
+
+// Git SHA1 commit hash of the release (set via linker flags)
+var gitCommit = ""
+
+var app *cli.App
+
+func init() {
+	app = utils.NewApp(gitCommit, "go-ath devp2p tool")
+	app.Commands = []cli.Command{
+		crawlCommand,
+		dnsCommand,
+	}
+}
+
+func main() {
+	if err := app.Run(os.Args); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+// getArg retrieves the i'th positional argument of a command, failing if it's
+// missing.
+func getArg(ctx *cli.Context, i int, name string) (string, error) {
+	if ctx.NArg() <= i {
+		return "", fmt.Errorf("missing argument <%s>", name)
+	}
+	return ctx.Args().Get(i), nil
+}
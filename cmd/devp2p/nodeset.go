@@ -0,0 +1,150 @@
+// Copyright 2019 The go-ethereum Authors
+// This file is part of go-ethereum.
+//
+// go-ethereum is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// go-ethereum is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with go-ethereum. If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/athofficial/go-ath/common"
+	"github.com/athofficial/go-ath/p2p/enode"
+	"github.com/athofficial/go-ath/p2p/enr"
+	"github.com/athofficial/go-ath/rlp"
+)
+
+const jsonIndent = "    "
+
+// nodeSet is the nodes.json file format. It holds a set of node records
+// as a JSON object.
+type nodeSet map[enode.ID]nodeJSON
+
+type nodeJSON struct {
+	Seq    uint64 `json:"seq"`
+	Record string `json:"record"` // "enr:" followed by the base64 encoded record, or an enode URL
+
+	// The score tracks how many liveness checks were performed. It is incremented by one
+	// every time the node passes a check, and halved every time it doesn't.
+	Score int `json:"score,omitempty"`
+	// These two track the time of last successful contact.
+	FirstResponse time.Time `json:"firstResponse,omitempty"`
+	LastResponse  time.Time `json:"lastResponse,omitempty"`
+	// This one tracks the time of our last attempt to contact the node.
+	LastCheck time.Time `json:"lastCheck,omitempty"`
+
+	// Info holds the result of the last RLPx handshake, if the node was dialed.
+	Info *nodeInfo `json:"info,omitempty"`
+}
+
+// loadNodesJSON reads a node set from the given file.
+func loadNodesJSON(file string) (nodeSet, error) {
+	var nodes nodeSet
+	if err := common.LoadJSON(file, &nodes); err != nil {
+		return nil, err
+	}
+	return nodes, nil
+}
+
+// writeNodesJSON stores the node set into the given file.
+func writeNodesJSON(file string, nodes nodeSet) error {
+	return writeJSON(file, nodes)
+}
+
+// makeNodeSet creates a node set from the given nodes.
+func makeNodeSet(nodes []*enode.Node) (nodeSet, error) {
+	ns := make(nodeSet, len(nodes))
+	for _, n := range nodes {
+		rec, err := encodeRecord(n)
+		if err != nil {
+			return nil, err
+		}
+		ns[n.ID()] = nodeJSON{Seq: n.Seq(), Record: rec}
+	}
+	return ns, nil
+}
+
+// nodes returns the nodes contained in the set, sorted by ID.
+func (ns nodeSet) nodes() ([]*enode.Node, error) {
+	result := make([]*enode.Node, 0, len(ns))
+	for id, entry := range ns {
+		n, err := parseRecord(entry.Record)
+		if err != nil {
+			return nil, fmt.Errorf("invalid record for node %v: %v", id, err)
+		}
+		if n.ID() != id {
+			return nil, fmt.Errorf("record of node %v has ID %v", id, n.ID())
+		}
+		result = append(result, n)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return bytes.Compare(result[i].ID().Bytes(), result[j].ID().Bytes()) < 0
+	})
+	return result, nil
+}
+
+// encodeRecord returns the text representation of a node record. Nodes found
+// via discovery v4 don't have a verifiable record, they are stored as enode URLs.
+func encodeRecord(n *enode.Node) (string, error) {
+	enc, err := rlp.EncodeToBytes(n.Record())
+	if err != nil {
+		return "", err
+	}
+	text := "enr:" + base64.RawURLEncoding.EncodeToString(enc)
+	if _, err := parseRecord(text); err != nil {
+		return n.String(), nil
+	}
+	return text, nil
+}
+
+// parseRecord decodes and verifies a node record in text representation.
+func parseRecord(text string) (*enode.Node, error) {
+	if strings.HasPrefix(text, "enode://") {
+		return enode.ParseV4(text)
+	}
+	if !strings.HasPrefix(text, "enr:") {
+		return nil, fmt.Errorf("missing 'enr:' prefix")
+	}
+	enc, err := base64.RawURLEncoding.DecodeString(text[4:])
+	if err != nil {
+		return nil, err
+	}
+	var r enr.Record
+	if err := rlp.DecodeBytes(enc, &r); err != nil {
+		return nil, err
+	}
+	return enode.New(enode.ValidSchemes, &r)
+}
+
+// writeJSON stores a value as indented JSON, with "-" meaning stdout.
+func writeJSON(file string, value interface{}) error {
+	enc, err := json.MarshalIndent(value, "", jsonIndent)
+	if err != nil {
+		return err
+	}
+	enc = append(enc, '\n')
+	if file == "-" {
+		_, err := os.Stdout.Write(enc)
+		return err
+	}
+	return ioutil.WriteFile(file, enc, 0644)
+}
@@ -30,6 +30,7 @@ import (
 	"github.com/athofficial/go-ath/cmd/utils"
 	"github.com/athofficial/go-ath/dashboard"
 	"github.com/athofficial/go-ath/eth"
+	"github.com/athofficial/go-ath/log"
 	"github.com/athofficial/go-ath/node"
 	"github.com/athofficial/go-ath/params"
 	whisper "github.com/athofficial/go-ath/whisper/whisperv6"
@@ -176,6 +177,14 @@ func makeFullNode(ctx *cli.Context) *node.Node {
 		utils.RegisterShhService(stack, &cfg.Shh)
 	}
 
+	// Add the GraphQL endpoint to the HTTP-RPC server if requested.
+	if ctx.GlobalBool(utils.GraphQLEnabledFlag.Name) {
+		if !ctx.GlobalBool(utils.RPCEnabledFlag.Name) {
+			log.Warn("GraphQL requested without the HTTP-RPC server, ignoring")
+		} else {
+			utils.RegisterGraphQLService(stack)
+		}
+	}
 	// Add the Ethereum Stats daemon if requested.
 	if cfg.Ethstats.URL != "" {
 		utils.RegisterEthStatsService(stack, cfg.Ethstats.URL)
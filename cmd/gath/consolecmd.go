@@ -18,6 +18,7 @@ package main
 
 import (
 	"fmt"
+	"io/ioutil"
 	"os"
 	"os/signal"
 	"path/filepath"
@@ -51,7 +52,7 @@ See https://github.com/athofficial/go-ath/wiki/JavaScript-Console.`,
 		Name:      "attach",
 		Usage:     "Start an interactive JavaScript environment (connect to node)",
 		ArgsUsage: "[endpoint]",
-		Flags:     append(consoleFlags, utils.DataDirFlag),
+		Flags:     append(consoleFlags, utils.DataDirFlag, utils.JWTTokenFlag),
 		Category:  "CONSOLE COMMANDS",
 		Description: `
 The gath console is an interactive shell for the JavaScript runtime environment
@@ -128,7 +129,16 @@ func remoteConsole(ctx *cli.Context) error {
 		}
 		endpoint = fmt.Sprintf("%s/gath.ipc", path)
 	}
-	client, err := dialRPC(endpoint)
+	// Authenticate by a bearer token if one was given
+	var opts []rpc.DialOption
+	if path := ctx.String(utils.JWTTokenFlag.Name); path != "" {
+		token, err := ioutil.ReadFile(path)
+		if err != nil {
+			utils.Fatalf("Failed to read JWT bearer token: %v", err)
+		}
+		opts = append(opts, rpc.WithBearerToken(strings.TrimSpace(string(token))))
+	}
+	client, err := dialRPC(endpoint, opts...)
 	if err != nil {
 		utils.Fatalf("Unable to attach to remote gath: %v", err)
 	}
@@ -160,7 +170,7 @@ func remoteConsole(ctx *cli.Context) error {
 // dialRPC returns a RPC client which connects to the given endpoint.
 // The check for empty endpoint implements the defaulting logic
 // for "gath attach" and "gath monitor" with no argument.
-func dialRPC(endpoint string) (*rpc.Client, error) {
+func dialRPC(endpoint string, opts ...rpc.DialOption) (*rpc.Client, error) {
 	if endpoint == "" {
 		endpoint = node.DefaultIPCEndpoint(clientIdentifier)
 	} else if strings.HasPrefix(endpoint, "rpc:") || strings.HasPrefix(endpoint, "ipc:") {
@@ -168,7 +178,7 @@ func dialRPC(endpoint string) (*rpc.Client, error) {
 		// these prefixes.
 		endpoint = endpoint[4:]
 	}
-	return rpc.Dial(endpoint)
+	return rpc.Dial(endpoint, opts...)
 }
 
 // ephemeralConsole starts a new gath node, attaches an ephemeral JavaScript
@@ -18,7 +18,10 @@ package main
 
 import (
 	"crypto/rand"
+	"io/ioutil"
 	"math/big"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"path/filepath"
 	"runtime"
@@ -28,6 +31,7 @@ import (
 	"time"
 
 	"github.com/athofficial/go-ath/params"
+	"github.com/athofficial/go-ath/rpc"
 )
 
 const (
@@ -123,6 +127,36 @@ func TestWSAttachWelcome(t *testing.T) {
 	gath.ExpectExit()
 }
 
+// Tests that attaching over HTTP presents the bearer token given on the command
+// line to the remote endpoint.
+func TestHTTPAttachBearerToken(t *testing.T) {
+	auth := make(chan string, 100)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth <- r.Header.Get("Authorization")
+		rpc.NewServer().ServeHTTP(w, r)
+	}))
+	defer server.Close()
+
+	datadir := tmpdir(t)
+	defer os.RemoveAll(datadir)
+	token := filepath.Join(datadir, "jwt.token")
+	if err := ioutil.WriteFile(token, []byte("secret-token\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	attach := rungath(t, "--datadir", datadir, "attach", "--rpc.jwttoken", token, "--exec", "1", server.URL)
+	attach.Expect("1\n")
+	attach.ExpectExit()
+
+	select {
+	case header := <-auth:
+		if header != "Bearer secret-token" {
+			t.Fatalf("wrong authorization header: have %q, want %q", header, "Bearer secret-token")
+		}
+	default:
+		t.Fatal("no request received from attached console")
+	}
+}
+
 func testAttachWelcome(t *testing.T, gath *testgath, endpoint, apis string) {
 	// Attach to a running gath note and terminate immediately
 	attach := rungath(t, "attach", endpoint)
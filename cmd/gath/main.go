@@ -38,6 +38,7 @@ import (
 	"github.com/athofficial/go-ath/log"
 	"github.com/athofficial/go-ath/metrics"
 	"github.com/athofficial/go-ath/node"
+	"github.com/athofficial/go-ath/swarm/tracing"
 	cli "gopkg.in/urfave/cli.v1"
 )
 
@@ -82,10 +83,19 @@ var (
 		utils.TxPoolAccountQueueFlag,
 		utils.TxPoolGlobalQueueFlag,
 		utils.TxPoolLifetimeFlag,
+		utils.TxPoolAccountRateFlag,
+		utils.TxPoolReplaceRateFlag,
+		utils.TxPoolPeerRateFlag,
+		utils.TxPoolGlobalRateFlag,
+		utils.TxPoolRateBurstFlag,
 		utils.SyncModeFlag,
 		utils.GCModeFlag,
+		utils.LogIndexFlag,
+		utils.LogIndexRetentionFlag,
 		utils.LightServFlag,
 		utils.LightPeersFlag,
+		utils.ULCTrustedNodesFlag,
+		utils.ULCMinTrustedFractionFlag,
 		utils.LightKDFFlag,
 		utils.WhitelistFlag,
 		utils.CacheFlag,
@@ -111,9 +121,12 @@ var (
 		utils.MinerLegacyExtraDataFlag,
 		utils.MinerRecommitIntervalFlag,
 		utils.MinerNoVerfiyFlag,
+		utils.MinerTxOrderingFlag,
+		utils.MinerTxBlacklistFlag,
 		utils.NATFlag,
 		utils.NoDiscoverFlag,
 		utils.DiscoveryV5Flag,
+		utils.DNSDiscoveryFlag,
 		utils.NetrestrictFlag,
 		utils.NodeKeyFileFlag,
 		utils.NodeKeyHexFlag,
@@ -133,6 +146,7 @@ var (
 		utils.GpoPercentileFlag,
 		utils.EWASMInterpreterFlag,
 		utils.EVMInterpreterFlag,
+		utils.EVMBlockAnalysisFlag,
 		configFileFlag,
 	}
 
@@ -141,11 +155,15 @@ var (
 		utils.RPCListenAddrFlag,
 		utils.RPCPortFlag,
 		utils.RPCApiFlag,
+		utils.GraphQLEnabledFlag,
+		utils.JWTSecretFlag,
+		utils.RPCSlowRequestFlag,
 		utils.WSEnabledFlag,
 		utils.WSListenAddrFlag,
 		utils.WSPortFlag,
 		utils.WSApiFlag,
 		utils.WSAllowedOriginsFlag,
+		utils.WSIsolateFlag,
 		utils.IPCDisabledFlag,
 		utils.IPCPathFlag,
 		utils.RPCGlobalGasCap,
@@ -207,6 +225,7 @@ func init() {
 	app.Flags = append(app.Flags, rpcFlags...)
 	app.Flags = append(app.Flags, consoleFlags...)
 	app.Flags = append(app.Flags, debug.Flags...)
+	app.Flags = append(app.Flags, tracing.Flags...)
 	app.Flags = append(app.Flags, whisperFlags...)
 	app.Flags = append(app.Flags, metricsFlags...)
 
@@ -237,6 +256,9 @@ func init() {
 		// Start metrics export if enabled
 		utils.SetupMetrics(ctx)
 
+		// Start collecting RPC traces if enabled
+		tracing.Setup(ctx)
+
 		// Start system runtime metrics collection
 		go metrics.CollectProcessMetrics(3 * time.Second)
 
@@ -245,6 +267,9 @@ func init() {
 
 	app.After = func(ctx *cli.Context) error {
 		debug.Exit()
+		if tracing.Closer != nil {
+			tracing.Closer.Close()
+		}
 		console.Stdin.Close() // Resets terminal mode.
 		return nil
 	}
@@ -26,6 +26,7 @@ import (
 
 	"github.com/athofficial/go-ath/cmd/utils"
 	"github.com/athofficial/go-ath/internal/debug"
+	"github.com/athofficial/go-ath/swarm/tracing"
 	cli "gopkg.in/urfave/cli.v1"
 )
 
@@ -75,10 +76,14 @@ var AppHelpFlagGroups = []flagGroup{
 			utils.TestnetFlag,
 			utils.SyncModeFlag,
 			utils.GCModeFlag,
+			utils.LogIndexFlag,
+			utils.LogIndexRetentionFlag,
 			utils.EthStatsURLFlag,
 			utils.IdentityFlag,
 			utils.LightServFlag,
 			utils.LightPeersFlag,
+			utils.ULCTrustedNodesFlag,
+			utils.ULCMinTrustedFractionFlag,
 			utils.LightKDFFlag,
 			utils.WhitelistFlag,
 		},
@@ -125,6 +130,11 @@ var AppHelpFlagGroups = []flagGroup{
 			utils.TxPoolAccountQueueFlag,
 			utils.TxPoolGlobalQueueFlag,
 			utils.TxPoolLifetimeFlag,
+			utils.TxPoolAccountRateFlag,
+			utils.TxPoolReplaceRateFlag,
+			utils.TxPoolPeerRateFlag,
+			utils.TxPoolGlobalRateFlag,
+			utils.TxPoolRateBurstFlag,
 		},
 	},
 	{
@@ -151,12 +161,16 @@ var AppHelpFlagGroups = []flagGroup{
 			utils.RPCListenAddrFlag,
 			utils.RPCPortFlag,
 			utils.RPCApiFlag,
+			utils.GraphQLEnabledFlag,
+			utils.JWTSecretFlag,
+			utils.RPCSlowRequestFlag,
 			utils.RPCGlobalGasCap,
 			utils.WSEnabledFlag,
 			utils.WSListenAddrFlag,
 			utils.WSPortFlag,
 			utils.WSApiFlag,
 			utils.WSAllowedOriginsFlag,
+			utils.WSIsolateFlag,
 			utils.IPCDisabledFlag,
 			utils.IPCPathFlag,
 			utils.RPCCORSDomainFlag,
@@ -178,6 +192,7 @@ var AppHelpFlagGroups = []flagGroup{
 			utils.NATFlag,
 			utils.NoDiscoverFlag,
 			utils.DiscoveryV5Flag,
+			utils.DNSDiscoveryFlag,
 			utils.NetrestrictFlag,
 			utils.NodeKeyFileFlag,
 			utils.NodeKeyHexFlag,
@@ -196,6 +211,8 @@ var AppHelpFlagGroups = []flagGroup{
 			utils.MinerExtraDataFlag,
 			utils.MinerRecommitIntervalFlag,
 			utils.MinerNoVerfiyFlag,
+			utils.MinerTxOrderingFlag,
+			utils.MinerTxBlacklistFlag,
 		},
 	},
 	{
@@ -210,6 +227,7 @@ var AppHelpFlagGroups = []flagGroup{
 		Flags: []cli.Flag{
 			utils.VMEnableDebugFlag,
 			utils.EVMInterpreterFlag,
+			utils.EVMBlockAnalysisFlag,
 			utils.EWASMInterpreterFlag,
 		},
 	},
@@ -232,6 +250,10 @@ var AppHelpFlagGroups = []flagGroup{
 			utils.MetricsInfluxDBTagsFlag,
 		},
 	},
+	{
+		Name:  "TRACING",
+		Flags: tracing.Flags,
+	},
 	{
 		Name:  "WHISPER (EXPERIMENTAL)",
 		Flags: whisperFlags,
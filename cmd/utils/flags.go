@@ -19,6 +19,7 @@ package utils
 
 import (
 	"crypto/ecdsa"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"math/big"
@@ -45,13 +46,14 @@ import (
 	"github.com/athofficial/go-ath/eth/gasprice"
 	"github.com/athofficial/go-ath/ethdb"
 	"github.com/athofficial/go-ath/ethstats"
+	"github.com/athofficial/go-ath/graphql"
 	"github.com/athofficial/go-ath/les"
 	"github.com/athofficial/go-ath/log"
 	"github.com/athofficial/go-ath/metrics"
 	"github.com/athofficial/go-ath/metrics/influxdb"
 	"github.com/athofficial/go-ath/node"
 	"github.com/athofficial/go-ath/p2p"
-	"github.com/athofficial/go-ath/p2p/discv5"
+	"github.com/athofficial/go-ath/p2p/dnsdisc"
 	"github.com/athofficial/go-ath/p2p/enode"
 	"github.com/athofficial/go-ath/p2p/nat"
 	"github.com/athofficial/go-ath/p2p/netutil"
@@ -160,7 +162,7 @@ var (
 	defaultSyncMode = eth.DefaultConfig.SyncMode
 	SyncModeFlag    = TextMarshalerFlag{
 		Name:  "syncmode",
-		Usage: `Blockchain sync mode ("fast", "full")`,
+		Usage: `Blockchain sync mode ("fast", "full", "snap")`,
 		Value: &defaultSyncMode,
 	}
 	GCModeFlag = cli.StringFlag{
@@ -168,6 +170,15 @@ var (
 		Usage: `Blockchain garbage collection mode ("full", "archive")`,
 		Value: "full",
 	}
+	LogIndexFlag = cli.BoolFlag{
+		Name:  "logindex",
+		Usage: "Maintain an exact log index for fast log filtering over large block ranges",
+	}
+	LogIndexRetentionFlag = cli.Uint64Flag{
+		Name:  "logindex.retention",
+		Usage: "Number of recent blocks to keep in the log index (0 = entire chain)",
+		Value: eth.DefaultConfig.LogIndexRetention,
+	}
 	LightServFlag = cli.IntFlag{
 		Name:  "lightserv",
 		Usage: "Maximum percentage of time allowed for serving LES requests (0-90)",
@@ -178,6 +189,15 @@ var (
 		Usage: "Maximum number of LES client peers",
 		Value: eth.DefaultConfig.LightPeers,
 	}
+	ULCTrustedNodesFlag = cli.StringFlag{
+		Name:  "ulc.trusted",
+		Usage: "Comma separated enode URLs of trusted LES servers (enables ultra light client mode)",
+	}
+	ULCMinTrustedFractionFlag = cli.IntFlag{
+		Name:  "ulc.fraction",
+		Usage: "Minimum percentage of trusted LES servers that have to announce a new head (1-100)",
+		Value: eth.DefaultULCMinTrustedFraction,
+	}
 	LightKDFFlag = cli.BoolFlag{
 		Name:  "lightkdf",
 		Usage: "Reduce key-derivation RAM & CPU usage at some expense of KDF strength",
@@ -290,6 +310,31 @@ var (
 		Usage: "Maximum amount of time non-executable transaction are queued",
 		Value: eth.DefaultConfig.TxPool.Lifetime,
 	}
+	TxPoolAccountRateFlag = cli.Float64Flag{
+		Name:  "txpool.accountrate",
+		Usage: "Maximum number of remote transactions accepted per second from a single account (0 = unlimited)",
+		Value: eth.DefaultConfig.TxPool.AccountRate,
+	}
+	TxPoolReplaceRateFlag = cli.Float64Flag{
+		Name:  "txpool.replacerate",
+		Usage: "Maximum number of remote replacements accepted per second from a single account (0 = unlimited)",
+		Value: eth.DefaultConfig.TxPool.ReplaceRate,
+	}
+	TxPoolPeerRateFlag = cli.Float64Flag{
+		Name:  "txpool.peerrate",
+		Usage: "Maximum number of transactions accepted per second from a single peer (0 = unlimited)",
+		Value: eth.DefaultConfig.TxPool.PeerRate,
+	}
+	TxPoolGlobalRateFlag = cli.Float64Flag{
+		Name:  "txpool.globalrate",
+		Usage: "Maximum number of remote transactions accepted per second in total (0 = unlimited)",
+		Value: eth.DefaultConfig.TxPool.GlobalRate,
+	}
+	TxPoolRateBurstFlag = cli.Uint64Flag{
+		Name:  "txpool.rateburst",
+		Usage: "Number of transactions permitted in a burst before the rate limits apply",
+		Value: eth.DefaultConfig.TxPool.RateBurst,
+	}
 	// Performance tuning settings
 	CacheFlag = cli.IntFlag{
 		Name:  "cache",
@@ -387,6 +432,16 @@ var (
 		Name:  "miner.noverify",
 		Usage: "Disable remote sealing verification",
 	}
+	MinerTxOrderingFlag = cli.StringFlag{
+		Name:  "miner.txorder",
+		Usage: `Transaction ordering policy of mined blocks ("price" or "locals")`,
+		Value: eth.DefaultConfig.MinerTxOrdering,
+	}
+	MinerTxBlacklistFlag = cli.StringFlag{
+		Name:  "miner.txblacklist",
+		Usage: "Comma separated list of senders and contracts whose transactions are never mined",
+		Value: "",
+	}
 	// Account settings
 	UnlockedAccountFlag = cli.StringFlag{
 		Name:  "unlock",
@@ -450,6 +505,22 @@ var (
 		Usage: "API's offered over the HTTP-RPC interface",
 		Value: "",
 	}
+	JWTSecretFlag = cli.StringFlag{
+		Name:  "rpc.jwtsecret",
+		Usage: "Path to a hex encoded HS256 secret authenticating HTTP-RPC and WS-RPC clients by JWT bearer tokens (tokens without an expiry stay valid until the secret is rotated)",
+	}
+	RPCSlowRequestFlag = cli.DurationFlag{
+		Name:  "rpc.slowrequest",
+		Usage: "Log RPC calls executing longer than this (0 = disabled)",
+	}
+	JWTTokenFlag = cli.StringFlag{
+		Name:  "rpc.jwttoken",
+		Usage: "Path to a JWT bearer token presented when attaching over HTTP or WebSocket",
+	}
+	GraphQLEnabledFlag = cli.BoolFlag{
+		Name:  "graphql",
+		Usage: "Enable the GraphQL query endpoint (/graphql) on the HTTP-RPC server",
+	}
 	IPCDisabledFlag = cli.BoolFlag{
 		Name:  "ipcdisable",
 		Usage: "Disable the IPC-RPC server",
@@ -482,6 +553,10 @@ var (
 		Usage: "Origins from which to accept websockets requests",
 		Value: "",
 	}
+	WSIsolateFlag = cli.BoolFlag{
+		Name:  "wsisolate",
+		Usage: "Apply --wsapi and --wsorigins only to websockets upgraded on the HTTP-RPC port (if --wsport equals --rpcport)",
+	}
 	ExecFlag = cli.StringFlag{
 		Name:  "exec",
 		Usage: "Execute JavaScript statement",
@@ -541,7 +616,11 @@ var (
 	}
 	DiscoveryV5Flag = cli.BoolFlag{
 		Name:  "v5disc",
-		Usage: "Enables the experimental RLPx V5 (Topic Discovery) mechanism",
+		Usage: "Enables the discovery v5 protocol with topic advertisement",
+	}
+	DNSDiscoveryFlag = cli.StringFlag{
+		Name:  "dnsdisc",
+		Usage: "Comma separated enrtree:// URLs of DNS node lists to discover peers from",
 	}
 	NetrestrictFlag = cli.StringFlag{
 		Name:  "netrestrict",
@@ -634,6 +713,10 @@ var (
 		Usage: "External EVM configuration (default = built-in interpreter)",
 		Value: "",
 	}
+	EVMBlockAnalysisFlag = cli.BoolFlag{
+		Name:  "vm.blockanalysis",
+		Usage: "Pre-analyse contract code into basic blocks to speed up EVM execution",
+	}
 )
 
 // MakeDataDir retrieves the currently requested data directory, terminating
@@ -725,9 +808,9 @@ func setBootstrapNodesV5(ctx *cli.Context, cfg *p2p.Config) {
 		return // already set, don't apply defaults.
 	}
 
-	cfg.BootstrapNodesV5 = make([]*discv5.Node, 0, len(urls))
+	cfg.BootstrapNodesV5 = make([]*enode.Node, 0, len(urls))
 	for _, url := range urls {
-		node, err := discv5.ParseNode(url)
+		node, err := enode.ParseV4(url)
 		if err != nil {
 			log.Error("Bootstrap URL invalid", "enode", url, "err", err)
 			continue
@@ -808,6 +891,9 @@ func setWS(ctx *cli.Context, cfg *node.Config) {
 	if ctx.GlobalIsSet(WSApiFlag.Name) {
 		cfg.WSModules = splitAndTrim(ctx.GlobalString(WSApiFlag.Name))
 	}
+	if ctx.GlobalIsSet(WSIsolateFlag.Name) {
+		cfg.WSIsolate = ctx.GlobalBool(WSIsolateFlag.Name)
+	}
 }
 
 // setIPC creates an IPC path configuration from the set command line flags,
@@ -958,12 +1044,26 @@ func SetP2PConfig(ctx *cli.Context, cfg *p2p.Config) {
 		cfg.NetRestrict = list
 	}
 
+	if ctx.GlobalIsSet(DNSDiscoveryFlag.Name) {
+		cfg.DNSDiscovery = nil
+		for _, url := range strings.Split(ctx.GlobalString(DNSDiscoveryFlag.Name), ",") {
+			if url = strings.TrimSpace(url); url == "" {
+				continue
+			}
+			if _, _, err := dnsdisc.ParseURL(url); err != nil {
+				Fatalf("Option %q: invalid node list URL %q: %v", DNSDiscoveryFlag.Name, url, err)
+			}
+			cfg.DNSDiscovery = append(cfg.DNSDiscovery, url)
+		}
+	}
+
 	if ctx.GlobalBool(DeveloperFlag.Name) {
 		// --dev mode can't use p2p networking.
 		cfg.MaxPeers = 0
 		cfg.ListenAddr = ":0"
 		cfg.NoDiscovery = true
 		cfg.DiscoveryV5 = false
+		cfg.DNSDiscovery = nil
 	}
 }
 
@@ -973,6 +1073,12 @@ func SetNodeConfig(ctx *cli.Context, cfg *node.Config) {
 	setIPC(ctx, cfg)
 	setHTTP(ctx, cfg)
 	setWS(ctx, cfg)
+	if ctx.GlobalIsSet(JWTSecretFlag.Name) {
+		cfg.JWTSecret = ctx.GlobalString(JWTSecretFlag.Name)
+	}
+	if ctx.GlobalIsSet(RPCSlowRequestFlag.Name) {
+		cfg.RPCSlowRequest = ctx.GlobalDuration(RPCSlowRequestFlag.Name)
+	}
 	setNodeUserIdent(ctx, cfg)
 	setDataDir(ctx, cfg)
 
@@ -1048,6 +1154,21 @@ func setTxPool(ctx *cli.Context, cfg *core.TxPoolConfig) {
 	if ctx.GlobalIsSet(TxPoolLifetimeFlag.Name) {
 		cfg.Lifetime = ctx.GlobalDuration(TxPoolLifetimeFlag.Name)
 	}
+	if ctx.GlobalIsSet(TxPoolAccountRateFlag.Name) {
+		cfg.AccountRate = ctx.GlobalFloat64(TxPoolAccountRateFlag.Name)
+	}
+	if ctx.GlobalIsSet(TxPoolReplaceRateFlag.Name) {
+		cfg.ReplaceRate = ctx.GlobalFloat64(TxPoolReplaceRateFlag.Name)
+	}
+	if ctx.GlobalIsSet(TxPoolPeerRateFlag.Name) {
+		cfg.PeerRate = ctx.GlobalFloat64(TxPoolPeerRateFlag.Name)
+	}
+	if ctx.GlobalIsSet(TxPoolGlobalRateFlag.Name) {
+		cfg.GlobalRate = ctx.GlobalFloat64(TxPoolGlobalRateFlag.Name)
+	}
+	if ctx.GlobalIsSet(TxPoolRateBurstFlag.Name) {
+		cfg.RateBurst = ctx.GlobalUint64(TxPoolRateBurstFlag.Name)
+	}
 }
 
 func setUbqhash(ctx *cli.Context, cfg *eth.Config) {
@@ -1094,6 +1215,22 @@ func setWhitelist(ctx *cli.Context, cfg *eth.Config) {
 	}
 }
 
+// setULC creates the ultra light client configuration from the command line flags.
+func setULC(ctx *cli.Context, cfg *eth.Config) {
+	if !ctx.GlobalIsSet(ULCTrustedNodesFlag.Name) {
+		return
+	}
+	cfg.ULC = &eth.ULCConfig{
+		TrustedServers:     splitAndTrim(ctx.GlobalString(ULCTrustedNodesFlag.Name)),
+		MinTrustedFraction: ctx.GlobalInt(ULCMinTrustedFractionFlag.Name),
+	}
+	for _, url := range cfg.ULC.TrustedServers {
+		if _, err := enode.ParseV4(url); err != nil {
+			Fatalf("Invalid trusted server URL %s: %v", url, err)
+		}
+	}
+}
+
 // checkExclusive verifies that only a single instance of the provided flags was
 // set by the user. Each flag might optionally be followed by a string type to
 // specialize it further.
@@ -1160,6 +1297,7 @@ func SetEthConfig(ctx *cli.Context, stack *node.Node, cfg *eth.Config) {
 	setTxPool(ctx, &cfg.TxPool)
 	setUbqhash(ctx, cfg)
 	setWhitelist(ctx, cfg)
+	setULC(ctx, cfg)
 
 	if ctx.GlobalIsSet(SyncModeFlag.Name) {
 		cfg.SyncMode = *GlobalTextMarshaler(ctx, SyncModeFlag.Name).(*downloader.SyncMode)
@@ -1183,6 +1321,12 @@ func SetEthConfig(ctx *cli.Context, stack *node.Node, cfg *eth.Config) {
 	}
 	cfg.NoPruning = ctx.GlobalString(GCModeFlag.Name) == "archive"
 
+	if ctx.GlobalIsSet(LogIndexFlag.Name) {
+		cfg.LogIndex = ctx.GlobalBool(LogIndexFlag.Name)
+	}
+	if ctx.GlobalIsSet(LogIndexRetentionFlag.Name) {
+		cfg.LogIndexRetention = ctx.GlobalUint64(LogIndexRetentionFlag.Name)
+	}
 	if ctx.GlobalIsSet(CacheFlag.Name) || ctx.GlobalIsSet(CacheTrieFlag.Name) {
 		cfg.TrieCleanCache = ctx.GlobalInt(CacheFlag.Name) * ctx.GlobalInt(CacheTrieFlag.Name) / 100
 	}
@@ -1222,6 +1366,18 @@ func SetEthConfig(ctx *cli.Context, stack *node.Node, cfg *eth.Config) {
 	if ctx.GlobalIsSet(MinerNoVerfiyFlag.Name) {
 		cfg.MinerNoverify = ctx.Bool(MinerNoVerfiyFlag.Name)
 	}
+	if ctx.GlobalIsSet(MinerTxOrderingFlag.Name) {
+		cfg.MinerTxOrdering = ctx.GlobalString(MinerTxOrderingFlag.Name)
+	}
+	if ctx.GlobalIsSet(MinerTxBlacklistFlag.Name) {
+		for _, account := range strings.Split(ctx.GlobalString(MinerTxBlacklistFlag.Name), ",") {
+			if trimmed := strings.TrimSpace(account); !common.IsHexAddress(trimmed) {
+				Fatalf("Invalid address in --miner.txblacklist: %s", trimmed)
+			} else {
+				cfg.MinerTxBlacklist = append(cfg.MinerTxBlacklist, common.HexToAddress(trimmed))
+			}
+		}
+	}
 	if ctx.GlobalIsSet(VMEnableDebugFlag.Name) {
 		// TODO(fjl): force-enable this in --dev mode
 		cfg.EnablePreimageRecording = ctx.GlobalBool(VMEnableDebugFlag.Name)
@@ -1234,6 +1390,9 @@ func SetEthConfig(ctx *cli.Context, stack *node.Node, cfg *eth.Config) {
 	if ctx.GlobalIsSet(EVMInterpreterFlag.Name) {
 		cfg.EVMInterpreter = ctx.GlobalString(EVMInterpreterFlag.Name)
 	}
+	if ctx.GlobalIsSet(EVMBlockAnalysisFlag.Name) {
+		cfg.EVMBlockAnalysis = ctx.GlobalBool(EVMBlockAnalysisFlag.Name)
+	}
 	if ctx.GlobalIsSet(RPCGlobalGasCap.Name) {
 		cfg.RPCGasCap = new(big.Int).SetUint64(ctx.GlobalUint64(RPCGlobalGasCap.Name))
 	}
@@ -1340,6 +1499,27 @@ func RegisterEthStatsService(stack *node.Node, url string) {
 	}
 }
 
+// RegisterGraphQLService adds the GraphQL query endpoint to the stack, served
+// on the node's HTTP-RPC server.
+func RegisterGraphQLService(stack *node.Node) {
+	if err := stack.Register(func(ctx *node.ServiceContext) (node.Service, error) {
+		// Try to construct the GraphQL service backed by a full node
+		var ethServ *eth.Ethereum
+		if err := ctx.Service(&ethServ); err == nil {
+			return graphql.New(ethServ.APIBackend)
+		}
+		// Try to construct the GraphQL service backed by a light node
+		var lesServ *les.LightEthereum
+		if err := ctx.Service(&lesServ); err == nil {
+			return graphql.New(lesServ.ApiBackend)
+		}
+		// Well, this should not have happened, bail out
+		return nil, errors.New("no Ethereum service")
+	}); err != nil {
+		Fatalf("Failed to register the GraphQL service: %v", err)
+	}
+}
+
 func SetupMetrics(ctx *cli.Context) {
 	if metrics.Enabled {
 		log.Info("Enabling metrics collection")
@@ -1445,7 +1625,10 @@ func MakeChain(ctx *cli.Context, stack *node.Node) (chain *core.BlockChain, chai
 	if ctx.GlobalIsSet(CacheFlag.Name) || ctx.GlobalIsSet(CacheGCFlag.Name) {
 		cache.TrieDirtyLimit = ctx.GlobalInt(CacheFlag.Name) * ctx.GlobalInt(CacheGCFlag.Name) / 100
 	}
-	vmcfg := vm.Config{EnablePreimageRecording: ctx.GlobalBool(VMEnableDebugFlag.Name)}
+	vmcfg := vm.Config{
+		EnablePreimageRecording: ctx.GlobalBool(VMEnableDebugFlag.Name),
+		BlockAnalysis:           ctx.GlobalBool(EVMBlockAnalysisFlag.Name),
+	}
 	chain, err = core.NewBlockChain(chainDb, cache, config, engine, vmcfg, nil)
 	if err != nil {
 		Fatalf("Can't create BlockChain: %v", err)
@@ -0,0 +1,130 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package ratelimit implements token bucket rate limiting of events originating
+// from distinct entities.
+package ratelimit
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// pruneInterval is the time between two automatic cleanups of idle buckets.
+const pruneInterval = time.Minute
+
+// bucket is a token bucket tracking the allowance of a single entity.
+type bucket struct {
+	tokens  float64   // Number of events permitted at the last update
+	updated time.Time // Time of the last allowance update
+}
+
+// Limiter is a set of token buckets limiting the rate at which events are
+// accepted from distinct entities (e.g. senders, peers or clients), permitting
+// bursts up to a configured size. A nil limiter accepts everything.
+type Limiter struct {
+	rate    float64                 // Number of events permitted per second
+	burst   float64                 // Maximum number of events permitted at once
+	buckets map[interface{}]*bucket // Allowances of the tracked entities
+	pruned  time.Time               // Time of the last cleanup of idle buckets
+	lock    sync.Mutex
+}
+
+// New creates a rate limiter permitting the given number of events per second.
+// If the burst is not positive, it defaults to one second's worth of events. If
+// the rate is not positive, the limiter is disabled and nil is returned.
+func New(rate float64, burst int) *Limiter {
+	if rate <= 0 {
+		return nil
+	}
+	if burst <= 0 {
+		burst = int(math.Ceil(rate))
+	}
+	return &Limiter{
+		rate:    rate,
+		burst:   float64(burst),
+		buckets: make(map[interface{}]*bucket),
+	}
+}
+
+// Allow reports whether an event of the given entity is permitted at the given
+// time, consuming an allowance if so.
+func (l *Limiter) Allow(key interface{}, now time.Time) bool {
+	if l == nil {
+		return true
+	}
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	if now.Sub(l.pruned) > pruneInterval {
+		l.prune(now)
+	}
+	b := l.buckets[key]
+	if b == nil {
+		b = &bucket{tokens: l.burst, updated: now}
+		l.buckets[key] = b
+	}
+	l.refill(b, now)
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+// Refund gives back an allowance consumed by an event of the given entity, e.g.
+// when the event was rejected for other reasons after passing the limiter.
+func (l *Limiter) Refund(key interface{}) {
+	if l == nil {
+		return
+	}
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	if b := l.buckets[key]; b != nil {
+		b.tokens = math.Min(l.burst, b.tokens+1)
+	}
+}
+
+// Prune drops the buckets which were refilled completely, since these are
+// indistinguishable from untracked entities.
+func (l *Limiter) Prune(now time.Time) {
+	if l == nil {
+		return
+	}
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	l.prune(now)
+}
+
+func (l *Limiter) prune(now time.Time) {
+	for key, b := range l.buckets {
+		if l.refill(b, now); b.tokens >= l.burst {
+			delete(l.buckets, key)
+		}
+	}
+	l.pruned = now
+}
+
+// refill credits the allowance accumulated since the bucket's last update.
+func (l *Limiter) refill(b *bucket, now time.Time) {
+	if elapsed := now.Sub(b.updated); elapsed > 0 {
+		b.tokens = math.Min(l.burst, b.tokens+elapsed.Seconds()*l.rate)
+		b.updated = now
+	}
+}
@@ -0,0 +1,93 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package ratelimit
+
+import (
+	"testing"
+	"time"
+)
+
+// Tests that the rate limiter permits bursts, refills allowances over time and
+// forgets entities which calmed down.
+func TestLimiter(t *testing.T) {
+	if limiter := New(0, 10); limiter != nil || !limiter.Allow("a", time.Now()) {
+		t.Fatalf("disabled limiter rejected event")
+	}
+	limiter := New(2, 4)
+	now := time.Now()
+
+	for i := 0; i < 4; i++ {
+		if !limiter.Allow("a", now) {
+			t.Fatalf("event %d: burst rejected", i)
+		}
+	}
+	if limiter.Allow("a", now) {
+		t.Fatalf("event above burst accepted")
+	}
+	if !limiter.Allow("b", now) {
+		t.Fatalf("event of other entity rejected")
+	}
+	// Half a second refills a single allowance at two events per second
+	now = now.Add(500 * time.Millisecond)
+	if !limiter.Allow("a", now) {
+		t.Fatalf("refilled event rejected")
+	}
+	if limiter.Allow("a", now) {
+		t.Fatalf("event above refill accepted")
+	}
+	// Refunded allowances can be used again, but never exceed the burst
+	limiter.Refund("a")
+	if !limiter.Allow("a", now) {
+		t.Fatalf("refunded event rejected")
+	}
+	limiter.Refund("b")
+	limiter.Refund("b")
+	if limiter.buckets["b"].tokens != 4 {
+		t.Fatalf("refund exceeded burst: have %v tokens, want 4", limiter.buckets["b"].tokens)
+	}
+	// Fully refilled entities should be dropped
+	limiter.Prune(now.Add(time.Second))
+	if len(limiter.buckets) != 1 {
+		t.Fatalf("pruned bucket count mismatch: have %d, want 1", len(limiter.buckets))
+	}
+	limiter.Prune(now.Add(2 * time.Second))
+	if len(limiter.buckets) != 0 {
+		t.Fatalf("pruned bucket count mismatch: have %d, want 0", len(limiter.buckets))
+	}
+}
+
+// Tests that the burst defaults to one second's worth of events and that idle
+// buckets are cleaned up automatically.
+func TestLimiterDefaults(t *testing.T) {
+	limiter := New(2.5, 0)
+	now := time.Now()
+
+	for i := 0; i < 3; i++ {
+		if !limiter.Allow("a", now) {
+			t.Fatalf("event %d: default burst rejected", i)
+		}
+	}
+	if limiter.Allow("a", now) {
+		t.Fatalf("event above default burst accepted")
+	}
+	if !limiter.Allow("b", now.Add(2*pruneInterval)) {
+		t.Fatalf("event of other entity rejected")
+	}
+	if len(limiter.buckets) != 1 {
+		t.Fatalf("idle buckets not pruned: have %d, want 1", len(limiter.buckets))
+	}
+}
@@ -63,6 +63,12 @@ var (
 	nPowDampFlux          = big.NewInt(1) // 0.1%
 )
 
+// ForkBlocks returns the activation heights of the difficulty algorithm changes,
+// which fork the chain like the fork blocks of the chain config.
+func ForkBlocks() []uint64 {
+	return []uint64{diffChangeBlock.Uint64(), fluxChangeBlock.Uint64()}
+}
+
 // Various error messages to mark blocks invalid. These should be private to
 // prevent engine specific errors from being referenced in the remainder of the
 // codebase, inherently breaking if the engine is swapped out. Please put common
@@ -709,6 +715,27 @@ func (ubqhash *Ubqhash) SealHash(header *types.Header) (hash common.Hash) {
 var BlockReward *big.Int = new(big.Int).Mul(big.NewInt(12), big.NewInt(1e+18))
 var DevReward *big.Int = new(big.Int).Mul(big.NewInt(1), big.NewInt(1e+17))
 
+// UncleReward returns the reward credited to the coinbase of an uncle if it is
+// included in the given header, and the reward the header's coinbase receives
+// for including it.
+func (ubqhash *Ubqhash) UncleReward(header, uncle *types.Header) (*big.Int, *big.Int) {
+	return uncleRewards(header, uncle)
+}
+
+// uncleRewards calculates the uncle and inclusion rewards of an uncle. Only the
+// blocks after the first few ones clamp negative uncle rewards to zero.
+func uncleRewards(header, uncle *types.Header) (*big.Int, *big.Int) {
+	r := new(big.Int).Add(uncle.Number, big2)
+	r.Sub(r, header.Number)
+	r.Mul(r, BlockReward)
+	r.Div(r, big2)
+
+	if header.Number.Cmp(big.NewInt(10)) >= 0 && r.Sign() < 0 {
+		r = new(big.Int)
+	}
+	return r, new(big.Int).Div(BlockReward, big32)
+}
+
 /*
 
 Code for switching the developer fund adress after Block 1,655,555
@@ -781,28 +808,10 @@ func accumulateRewards(statedb *state.StateDB, header *types.Header, uncles []*t
 		rewardDev = big.NewInt(0.3e+18)
 	}
 
-	r := new(big.Int)
 	for _, uncle := range uncles {
-		r.Add(uncle.Number, big2)
-		r.Sub(r, header.Number)
-		r.Mul(r, BlockReward)
-		r.Div(r, big2)
-
-		if header.Number.Cmp(big.NewInt(10)) < 0 {
-			statedb.AddBalance(uncle.Coinbase, r)
-			r.Div(BlockReward, big32)
-			if r.Cmp(big.NewInt(0)) < 0 {
-				r = big.NewInt(0)
-			}
-		} else {
-			if r.Cmp(big.NewInt(0)) < 0 {
-				r = big.NewInt(0)
-			}
-			statedb.AddBalance(uncle.Coinbase, r)
-			r.Div(BlockReward, big32)
-		}
-
-		reward.Add(reward, r)
+		uncleReward, inclusionReward := uncleRewards(header, uncle)
+		statedb.AddBalance(uncle.Coinbase, uncleReward)
+		reward.Add(reward, inclusionReward)
 	}
 	statedb.AddBalance(header.Coinbase, reward)
 	if header.Number.Int64() < 1655555 {
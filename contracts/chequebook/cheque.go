@@ -612,6 +612,24 @@ func (ch *Cheque) Verify(signerKey *ecdsa.PublicKey, contract, beneficiary commo
 	return amount, nil
 }
 
+// Signer recovers the public key which signed the cheque. Note that a valid signer
+// doesn't imply that the cheque can be cashed, the contract might not be owned by
+// the signer.
+func (ch *Cheque) Signer() (*ecdsa.PublicKey, error) {
+	if ch.Amount == nil {
+		return nil, fmt.Errorf("invalid amount")
+	}
+	hash := sigHash(ch.Contract, ch.Beneficiary, ch.Amount)
+	if hash == nil {
+		return nil, fmt.Errorf("invalid amount: %v", ch.Amount)
+	}
+	pubKey, err := crypto.SigToPub(hash, ch.Sig)
+	if err != nil {
+		return nil, fmt.Errorf("invalid signature: %v", err)
+	}
+	return pubKey, nil
+}
+
 // v/r/s representation of signature
 func sig2vrs(sig []byte) (v byte, r, s [32]byte) {
 	v = sig[64] + 27
@@ -106,6 +106,13 @@ func TestIssueAndReceive(t *testing.T) {
 		t.Errorf("expected: %v, got %v", "43", received)
 	}
 
+	signer, err := ch.Signer()
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if crypto.PubkeyToAddress(*signer) != crypto.PubkeyToAddress(key0.PublicKey) {
+		t.Errorf("expected signer %v, got %v", crypto.PubkeyToAddress(key0.PublicKey).Hex(), crypto.PubkeyToAddress(*signer).Hex())
+	}
 }
 
 func TestCheckbookFile(t *testing.T) {
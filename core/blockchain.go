@@ -105,6 +105,7 @@ type BlockChain struct {
 	rmLogsFeed    event.Feed
 	chainFeed     event.Feed
 	chainSideFeed event.Feed
+	reorgFeed     event.Feed
 	chainHeadFeed event.Feed
 	logsFeed      event.Feed
 	scope         event.SubscriptionScope
@@ -1410,6 +1411,9 @@ func (bc *BlockChain) insertSidechain(block *types.Block, it *insertIterator) (i
 // potential missing transactions and post an event about them.
 func (bc *BlockChain) reorg(oldBlock, newBlock *types.Block) error {
 	var (
+		oldHead = oldBlock.Header()
+		newHead = newBlock.Header()
+
 		newChain    types.Blocks
 		oldChain    types.Blocks
 		commonBlock *types.Block
@@ -1549,6 +1553,20 @@ func (bc *BlockChain) reorg(oldBlock, newBlock *types.Block) error {
 			for _, block := range oldChain {
 				bc.chainSideFeed.Send(ChainSideEvent{Block: block})
 			}
+			ev := ChainReorgEvent{
+				OldHead: oldHead,
+				NewHead: newHead,
+				Common:  commonBlock.Header(),
+				Dropped: make([]common.Hash, len(oldChain)),
+				Added:   make([]common.Hash, len(newChain)),
+			}
+			for i, block := range oldChain {
+				ev.Dropped[len(oldChain)-1-i] = block.Hash()
+			}
+			for i, block := range newChain {
+				ev.Added[len(newChain)-1-i] = block.Hash()
+			}
+			bc.reorgFeed.Send(ev)
 		}
 	}()
 	return nil
@@ -1767,6 +1785,11 @@ func (bc *BlockChain) SubscribeChainSideEvent(ch chan<- ChainSideEvent) event.Su
 	return bc.scope.Track(bc.chainSideFeed.Subscribe(ch))
 }
 
+// SubscribeChainReorgEvent registers a subscription of ChainReorgEvent.
+func (bc *BlockChain) SubscribeChainReorgEvent(ch chan<- ChainReorgEvent) event.Subscription {
+	return bc.scope.Track(bc.reorgFeed.Subscribe(ch))
+}
+
 // SubscribeLogsEvent registers a subscription of []*types.Log.
 func (bc *BlockChain) SubscribeLogsEvent(ch chan<- []*types.Log) event.Subscription {
 	return bc.scope.Track(bc.logsFeed.Subscribe(ch))
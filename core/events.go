@@ -46,3 +46,17 @@ type ChainSideEvent struct {
 }
 
 type ChainHeadEvent struct{ Block *types.Block }
+
+// ChainReorgEvent is posted when a reorg replaces blocks of the canonical chain,
+// after the ChainSideEvents of the dropped blocks.
+type ChainReorgEvent struct {
+	OldHead *types.Header // Head of the canonical chain before the reorg
+	NewHead *types.Header // Head of the canonical chain after the reorg
+	Common  *types.Header // Latest block shared by the old and the new chain
+	Dropped []common.Hash // Blocks removed from the canonical chain, in ascending order
+	Added   []common.Hash // Blocks added to the canonical chain, in ascending order
+}
+
+// UncleCandidateEvent is posted when a valid header is propagated by a remote
+// peer which does not extend the local chain head, and may thus be an uncle.
+type UncleCandidateEvent struct{ Header *types.Header }
@@ -0,0 +1,185 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package forkid
+
+import (
+	"bytes"
+	"math"
+	"math/big"
+	"reflect"
+	"testing"
+
+	"github.com/athofficial/go-ath/common"
+	"github.com/athofficial/go-ath/params"
+	"github.com/athofficial/go-ath/rlp"
+)
+
+// TestCreation tests that different genesis and fork rule combinations result in
+// the correct fork ID.
+func TestCreation(t *testing.T) {
+	type testcase struct {
+		head uint64
+		want ID
+	}
+	tests := []struct {
+		config  *params.ChainConfig
+		genesis common.Hash
+		cases   []testcase
+	}{
+		// Mainnet test cases
+		{
+			params.MainnetChainConfig,
+			params.MainnetGenesisHash,
+			[]testcase{
+				{0, ID{Hash: checksumToBytes(0xa96ff9f4), Next: 10}},            // Unsynced
+				{9, ID{Hash: checksumToBytes(0xa96ff9f4), Next: 10}},            // Last block before EIP155/158
+				{10, ID{Hash: checksumToBytes(0x8ba513a5), Next: 4088}},         // First EIP155/158 block
+				{4087, ID{Hash: checksumToBytes(0x8ba513a5), Next: 4088}},       // Last block before the 88 block difficulty window
+				{4088, ID{Hash: checksumToBytes(0x6aa0d7ed), Next: 8000}},       // First 88 block difficulty window block
+				{7999, ID{Hash: checksumToBytes(0x6aa0d7ed), Next: 8000}},       // Last block before flux
+				{8000, ID{Hash: checksumToBytes(0x628be31e), Next: 1655555}},    // First flux block
+				{1655554, ID{Hash: checksumToBytes(0x628be31e), Next: 1655555}}, // Last block before the penalty system
+				{1655555, ID{Hash: checksumToBytes(0xf874554f), Next: 0}},       // First penalty system block
+				{5000000, ID{Hash: checksumToBytes(0xf874554f), Next: 0}},       // Future block
+			},
+		},
+		// Testnet test cases
+		{
+			params.TestnetChainConfig,
+			params.TestnetGenesisHash,
+			[]testcase{
+				{0, ID{Hash: checksumToBytes(0x30c7ddbc), Next: 10}},
+				{10, ID{Hash: checksumToBytes(0x63760190), Next: 4088}},
+				{4088, ID{Hash: checksumToBytes(0xdbc2e58c), Next: 8000}},
+				{8000, ID{Hash: checksumToBytes(0x29ad78b5), Next: 1655555}},
+				{1655555, ID{Hash: checksumToBytes(0x1c4a0320), Next: 0}},
+			},
+		},
+	}
+	for i, tt := range tests {
+		for j, ttt := range tt.cases {
+			if have := newID(tt.config, tt.genesis, ttt.head); have != ttt.want {
+				t.Errorf("test %d, case %d: fork ID mismatch: have %x, want %x", i, j, have, ttt.want)
+			}
+		}
+	}
+}
+
+// TestGatherForks tests that the fork blocks are collected from the chain config
+// and the ubqhash activation heights, skipping the disabled and genesis ones.
+func TestGatherForks(t *testing.T) {
+	config := &params.ChainConfig{
+		HomesteadBlock:      big.NewInt(0),
+		EIP150Block:         big.NewInt(5),
+		EIP155Block:         big.NewInt(10),
+		EIP158Block:         big.NewInt(10),
+		ByzantiumBlock:      big.NewInt(20000),
+		ConstantinopleBlock: big.NewInt(math.MaxInt64),
+	}
+	if have, want := gatherForks(config), []uint64{5, 10, 20000}; !reflect.DeepEqual(have, want) {
+		t.Errorf("forks mismatch without ubqhash: have %v, want %v", have, want)
+	}
+	config.Ubqhash = new(params.UbqhashConfig)
+	if have, want := gatherForks(config), []uint64{5, 10, 4088, 8000, 20000, uint64(params.PenaltySystemBlock)}; !reflect.DeepEqual(have, want) {
+		t.Errorf("forks mismatch with ubqhash: have %v, want %v", have, want)
+	}
+}
+
+// TestValidation tests that a local peer correctly validates and accepts a remote
+// fork ID.
+func TestValidation(t *testing.T) {
+	tests := []struct {
+		head uint64
+		id   ID
+		err  error
+	}{
+		// Local is mainnet past flux, remote announces the same. No future fork is announced.
+		{9000, ID{Hash: checksumToBytes(0x628be31e), Next: 0}, nil},
+
+		// Local is mainnet past flux, remote announces the same. Remote also announces
+		// the penalty system, which we know about too.
+		{9000, ID{Hash: checksumToBytes(0x628be31e), Next: 1655555}, nil},
+
+		// Local is mainnet past flux, remote announces the same. Remote also announces
+		// a future fork at a block we haven't passed yet.
+		{9000, ID{Hash: checksumToBytes(0x628be31e), Next: 10000}, nil},
+
+		// Local is mainnet at the 88 block window, remote announces the window too,
+		// but with flux at a different block. Local hasn't passed it yet, so the nodes
+		// may still agree on a postponed fork.
+		{5000, ID{Hash: checksumToBytes(0x6aa0d7ed), Next: 9000}, nil},
+
+		// Local is mainnet past EIP155, remote is still unsynced but announces the
+		// EIP155 fork we passed. Remote is simply syncing.
+		{5000, ID{Hash: checksumToBytes(0xa96ff9f4), Next: 10}, nil},
+
+		// Local is mainnet past flux, remote is syncing before the 88 block window.
+		{9000, ID{Hash: checksumToBytes(0x8ba513a5), Next: 4088}, nil},
+
+		// Local is mainnet before flux, remote is past it. Local is out of sync, accept.
+		{5000, ID{Hash: checksumToBytes(0x628be31e), Next: 1655555}, nil},
+
+		// Local is mainnet before the 88 block window, remote is past the penalty system.
+		{100, ID{Hash: checksumToBytes(0xf874554f), Next: 0}, nil},
+
+		// Local is mainnet past flux, remote is before the 88 block window, but doesn't
+		// know about it. Remote needs a software update.
+		{9000, ID{Hash: checksumToBytes(0x8ba513a5), Next: 0}, ErrRemoteStale},
+
+		// Local is mainnet past flux, remote is before the 88 block window and announces
+		// it at a different block. Remote is on a diverged chain.
+		{9000, ID{Hash: checksumToBytes(0x8ba513a5), Next: 5000}, ErrRemoteStale},
+
+		// Local is mainnet past flux, remote shares flux but announces a next fork we
+		// already passed without forking there. Local is stale or incompatible.
+		{9000, ID{Hash: checksumToBytes(0x628be31e), Next: 8500}, ErrLocalIncompatibleOrStale},
+
+		// Local is mainnet, remote is a random chain.
+		{9000, ID{Hash: checksumToBytes(0xdeadbeef), Next: 0}, ErrLocalIncompatibleOrStale},
+
+		// Local is mainnet, remote is testnet at the same fork state.
+		{9000, ID{Hash: checksumToBytes(0x29ad78b5), Next: 1655555}, ErrLocalIncompatibleOrStale},
+	}
+	for i, tt := range tests {
+		filter := newFilter(params.MainnetChainConfig, params.MainnetGenesisHash, func() uint64 { return tt.head })
+		if err := filter(tt.id); err != tt.err {
+			t.Errorf("test %d: validation error mismatch: have %v, want %v", i, err, tt.err)
+		}
+	}
+}
+
+// TestEncoding tests that fork IDs are RLP encoded correctly.
+func TestEncoding(t *testing.T) {
+	tests := []struct {
+		id   ID
+		want []byte
+	}{
+		{ID{Hash: checksumToBytes(0), Next: 0}, common.Hex2Bytes("c6840000000080")},
+		{ID{Hash: checksumToBytes(0xdeadbeef), Next: 0xBADDCAFE}, common.Hex2Bytes("ca84deadbeef84baddcafe")},
+		{ID{Hash: checksumToBytes(math.MaxUint32), Next: math.MaxUint64}, common.Hex2Bytes("ce84ffffffff88ffffffffffffffff")},
+	}
+	for i, tt := range tests {
+		have, err := rlp.EncodeToBytes(tt.id)
+		if err != nil {
+			t.Errorf("test %d: failed to encode forkid: %v", i, err)
+			continue
+		}
+		if !bytes.Equal(have, tt.want) {
+			t.Errorf("test %d: RLP mismatch: have %x, want %x", i, have, tt.want)
+		}
+	}
+}
@@ -0,0 +1,253 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package logindex implements an exact index of the logs of the canonical chain,
+// mapping addresses and positional topics to the logs containing them.
+//
+// Unlike the probabilistic bloombits index, lookups in the log index yield no
+// false positives, so filters over busy contracts and long block ranges only
+// load the receipts which actually hold matching logs.
+package logindex
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/athofficial/go-ath/common"
+	"github.com/athofficial/go-ath/core"
+	"github.com/athofficial/go-ath/core/rawdb"
+	"github.com/athofficial/go-ath/core/types"
+	"github.com/athofficial/go-ath/ethdb"
+)
+
+const (
+	// throttling is the time to wait between processing two consecutive index
+	// sections. It's useful during chain upgrades to prevent disk overload.
+	throttling = 100 * time.Millisecond
+
+	// addressTermKind is the leading byte of the address terms. Topic terms lead
+	// with the position of the topic instead.
+	addressTermKind = 0xff
+)
+
+// AddressTerm returns the index term of logs emitted by the given address.
+func AddressTerm(address common.Address) []byte {
+	return append([]byte{addressTermKind}, address.Bytes()...)
+}
+
+// TopicTerm returns the index term of logs having the given topic at the given
+// position.
+func TopicTerm(position int, topic common.Hash) []byte {
+	return append([]byte{byte(position)}, topic.Bytes()...)
+}
+
+// Indexer implements a core.ChainIndexerBackend, building up the log index of
+// the sections of the canonical chain.
+type Indexer struct {
+	db        ethdb.Database // Database instance to read receipts from and write the index into
+	size      uint64         // Number of blocks in a section
+	retention uint64         // Number of recent sections to retain (0 = all)
+
+	section   uint64                         // Section being processed currently
+	head      common.Hash                    // Hash of the last header processed
+	skip      bool                           // Whether the section falls outside the retention window
+	positions map[string][]rawdb.LogPosition // Log positions gathered per term
+	terms     [][]byte                       // Terms in the order of their first occurrence
+}
+
+// NewIndexer returns a chain indexer that generates the log index of the sections
+// of the canonical chain, keeping the given number of recent sections (or all if
+// zero). It's meant to be the child of the bloombits indexer, using the same
+// section size.
+func NewIndexer(db ethdb.Database, size, retention uint64) *core.ChainIndexer {
+	backend := &Indexer{
+		db:        db,
+		size:      size,
+		retention: retention,
+	}
+	table := ethdb.NewTable(db, string(rawdb.LogIndexIndexPrefix))
+
+	return core.NewChainIndexer(db, table, backend, size, 0, throttling, "logindex")
+}
+
+// Reset implements core.ChainIndexerBackend, starting a new log index section
+// and dropping any index generated for a previous version of it.
+func (idx *Indexer) Reset(ctx context.Context, section uint64, prevHead common.Hash) error {
+	if head := rawdb.ReadLogIndexHead(idx.db, section); head != (common.Hash{}) {
+		batch := idx.db.NewBatch()
+		deleteSection(idx.db, batch, section, head)
+		if err := batch.Write(); err != nil {
+			return err
+		}
+	}
+	idx.section, idx.head = section, common.Hash{}
+	idx.positions, idx.terms = make(map[string][]rawdb.LogPosition), nil
+
+	// Skip indexing the section if it would be pruned right away
+	idx.skip = false
+	if idx.retention > 0 {
+		if number := rawdb.ReadHeaderNumber(idx.db, rawdb.ReadHeadHeaderHash(idx.db)); number != nil {
+			idx.skip = section+idx.retention < (*number+1)/idx.size
+		}
+	}
+	return nil
+}
+
+// Process implements core.ChainIndexerBackend, adding the logs of a new block to
+// the index.
+func (idx *Indexer) Process(ctx context.Context, header *types.Header) error {
+	idx.head = header.Hash()
+	if idx.skip || header.Bloom == (types.Bloom{}) {
+		return nil
+	}
+	number := header.Number.Uint64()
+	receipts := rawdb.ReadReceipts(idx.db, idx.head, number)
+	if receipts == nil {
+		return fmt.Errorf("receipts of block #%d [%x…] not found", number, idx.head[:4])
+	}
+	for i, receipt := range receipts {
+		for j, l := range receipt.Logs {
+			pos := rawdb.LogPosition{Number: number, TxIndex: uint(i), LogIndex: uint(j)}
+
+			idx.add(AddressTerm(l.Address), pos)
+			for k, topic := range l.Topics {
+				idx.add(TopicTerm(k, topic), pos)
+			}
+		}
+	}
+	return nil
+}
+
+// add records a log position for a term.
+func (idx *Indexer) add(term []byte, pos rawdb.LogPosition) {
+	key := string(term)
+
+	positions, ok := idx.positions[key]
+	if !ok {
+		idx.terms = append(idx.terms, term)
+	}
+	idx.positions[key] = append(positions, pos)
+}
+
+// Commit implements core.ChainIndexerBackend, writing out the index of the
+// section and pruning the sections fallen out of the retention window.
+func (idx *Indexer) Commit() error {
+	batch := idx.db.NewBatch()
+	if !idx.skip {
+		for _, term := range idx.terms {
+			rawdb.WriteLogPositions(batch, idx.section, idx.head, term, idx.positions[string(term)])
+			if batch.ValueSize() > ethdb.IdealBatchSize {
+				if err := batch.Write(); err != nil {
+					return err
+				}
+				batch.Reset()
+			}
+		}
+		// Reference the section head last, marking the section available
+		rawdb.WriteLogIndexTerms(batch, idx.section, idx.head, idx.terms)
+		rawdb.WriteLogIndexHead(batch, idx.section, idx.head)
+	}
+
+	// Drop the sections which are not retained any more
+	if idx.retention > 0 && idx.section+1 > idx.retention {
+		tail := rawdb.ReadLogIndexTail(idx.db)
+		for newTail := idx.section + 1 - idx.retention; tail < newTail; tail++ {
+			if head := rawdb.ReadLogIndexHead(idx.db, tail); head != (common.Hash{}) {
+				deleteSection(idx.db, batch, tail, head)
+			}
+		}
+		rawdb.WriteLogIndexTail(batch, tail)
+	}
+	return batch.Write()
+}
+
+// deleteSection removes all index data of a section generated for the given
+// section head.
+func deleteSection(db ethdb.Database, batch ethdb.Batch, section uint64, head common.Hash) {
+	for _, term := range rawdb.ReadLogIndexTerms(db, section, head) {
+		rawdb.DeleteLogPositions(batch, section, head, term)
+	}
+	rawdb.DeleteLogIndexTerms(batch, section, head)
+	rawdb.DeleteLogIndexHead(batch, section)
+}
+
+// Lookup returns the positions of the logs within a section that match the filter
+// criteria: emitted by any of the addresses (if given), and carrying any of the
+// topics (if given) at each position. The positions are ordered as the logs in
+// the chain.
+//
+// False is returned if the section is not indexed against the canonical chain, or
+// if the criteria are unconstrained, in which case the index cannot serve them.
+func Lookup(db ethdb.Database, size, section uint64, addresses []common.Address, topics [][]common.Hash) ([]rawdb.LogPosition, bool) {
+	head := rawdb.ReadLogIndexHead(db, section)
+	if head == (common.Hash{}) || head != rawdb.ReadCanonicalHash(db, (section+1)*size-1) {
+		return nil, false
+	}
+	// Gather the terms of each clause, any of which must be matched
+	var clauses [][][]byte
+	if len(addresses) > 0 {
+		clause := make([][]byte, len(addresses))
+		for i, address := range addresses {
+			clause[i] = AddressTerm(address)
+		}
+		clauses = append(clauses, clause)
+	}
+	for i, sub := range topics {
+		if len(sub) == 0 {
+			continue // wildcard
+		}
+		clause := make([][]byte, len(sub))
+		for j, topic := range sub {
+			clause[j] = TopicTerm(i, topic)
+		}
+		clauses = append(clauses, clause)
+	}
+	if len(clauses) == 0 {
+		return nil, false
+	}
+	// Intersect the unions of the clauses
+	var matches map[rawdb.LogPosition]struct{}
+	for _, clause := range clauses {
+		union := make(map[rawdb.LogPosition]struct{})
+		for _, term := range clause {
+			for _, pos := range rawdb.ReadLogPositions(db, section, head, term) {
+				if _, ok := matches[pos]; ok || matches == nil {
+					union[pos] = struct{}{}
+				}
+			}
+		}
+		if matches = union; len(matches) == 0 {
+			return nil, true
+		}
+	}
+	positions := make([]rawdb.LogPosition, 0, len(matches))
+	for pos := range matches {
+		positions = append(positions, pos)
+	}
+	sort.Slice(positions, func(i, j int) bool {
+		a, b := positions[i], positions[j]
+		if a.Number != b.Number {
+			return a.Number < b.Number
+		}
+		if a.TxIndex != b.TxIndex {
+			return a.TxIndex < b.TxIndex
+		}
+		return a.LogIndex < b.LogIndex
+	})
+	return positions, true
+}
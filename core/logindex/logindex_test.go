@@ -0,0 +1,196 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package logindex
+
+import (
+	"context"
+	"math/big"
+	"reflect"
+	"testing"
+
+	"github.com/athofficial/go-ath/common"
+	"github.com/athofficial/go-ath/consensus/ubqhash"
+	"github.com/athofficial/go-ath/core"
+	"github.com/athofficial/go-ath/core/rawdb"
+	"github.com/athofficial/go-ath/core/types"
+	"github.com/athofficial/go-ath/ethdb"
+	"github.com/athofficial/go-ath/params"
+)
+
+var (
+	testSize = uint64(4)
+
+	addr1 = common.HexToAddress("0x1111111111111111111111111111111111111111")
+	addr2 = common.HexToAddress("0x2222222222222222222222222222222222222222")
+
+	topic1 = common.HexToHash("0x01")
+	topic2 = common.HexToHash("0x02")
+)
+
+// pos creates a log position.
+func pos(number uint64, tx, log uint) rawdb.LogPosition {
+	return rawdb.LogPosition{Number: number, TxIndex: tx, LogIndex: log}
+}
+
+// makeChain generates a chain of the given length on top of the genesis in the
+// database, emitting a log from addr1 with topic1 in every third block and one
+// from addr2 with topics topic2, topic1 in every fifth block. The seed is used
+// to create distinct forks. The chain is written into the database as canonical.
+func makeChain(db ethdb.Database, genesis *types.Block, n int, seed byte) []*types.Block {
+	blocks, receipts := core.GenerateChain(params.TestChainConfig, genesis, ubqhash.NewFaker(), db, n, func(i int, gen *core.BlockGen) {
+		gen.SetCoinbase(common.Address{seed})
+
+		receipt := types.NewReceipt(nil, false, 0)
+		if (i+1)%3 == 0 {
+			receipt.Logs = append(receipt.Logs, &types.Log{Address: addr1, Topics: []common.Hash{topic1}})
+		}
+		if (i+1)%5 == 0 {
+			receipt.Logs = append(receipt.Logs, &types.Log{Address: addr2, Topics: []common.Hash{topic2, topic1}})
+		}
+		if len(receipt.Logs) > 0 {
+			gen.AddUncheckedReceipt(receipt)
+		}
+	})
+	for i, block := range blocks {
+		rawdb.WriteBlock(db, block)
+		rawdb.WriteCanonicalHash(db, block.Hash(), block.NumberU64())
+		rawdb.WriteReceipts(db, block.Hash(), block.NumberU64(), receipts[i])
+	}
+	rawdb.WriteHeadHeaderHash(db, blocks[len(blocks)-1].Hash())
+	return blocks
+}
+
+// indexSection runs the indexer over a section of the canonical chain.
+func indexSection(t *testing.T, db ethdb.Database, idx *Indexer, section uint64) {
+	if err := idx.Reset(context.Background(), section, common.Hash{}); err != nil {
+		t.Fatalf("section %d: failed to reset indexer: %v", section, err)
+	}
+	for number := section * testSize; number < (section+1)*testSize; number++ {
+		header := rawdb.ReadHeader(db, rawdb.ReadCanonicalHash(db, number), number)
+		if err := idx.Process(context.Background(), header); err != nil {
+			t.Fatalf("section %d: failed to process header #%d: %v", section, number, err)
+		}
+	}
+	if err := idx.Commit(); err != nil {
+		t.Fatalf("section %d: failed to commit index: %v", section, err)
+	}
+}
+
+// Tests that lookups yield exactly the positions of the matching logs.
+func TestLookup(t *testing.T) {
+	db := ethdb.NewMemDatabase()
+	genesis := core.GenesisBlockForTesting(db, common.Address{}, big.NewInt(1000000))
+	makeChain(db, genesis, int(4*testSize), 0)
+
+	idx := &Indexer{db: db, size: testSize}
+	for section := uint64(0); section < 4; section++ {
+		indexSection(t, db, idx, section)
+	}
+
+	tests := []struct {
+		section   uint64
+		addresses []common.Address
+		topics    [][]common.Hash
+		want      []rawdb.LogPosition
+	}{
+		// Single address in either section
+		{0, []common.Address{addr1}, nil, []rawdb.LogPosition{pos(3, 0, 0)}},
+		{1, []common.Address{addr1}, nil, []rawdb.LogPosition{pos(6, 0, 0)}},
+		// Any of multiple addresses, with logs sharing a receipt
+		{3, []common.Address{addr1, addr2}, nil, []rawdb.LogPosition{pos(12, 0, 0), pos(15, 0, 0), pos(15, 0, 1)}},
+		// Topics are positional
+		{3, nil, [][]common.Hash{{topic1}}, []rawdb.LogPosition{pos(12, 0, 0), pos(15, 0, 0)}},
+		{3, nil, [][]common.Hash{nil, {topic1}}, []rawdb.LogPosition{pos(15, 0, 1)}},
+		// Addresses and topics are intersected
+		{1, []common.Address{addr1}, [][]common.Hash{{topic2}}, nil},
+		{1, []common.Address{addr2}, [][]common.Hash{{topic2}, {topic1}}, []rawdb.LogPosition{pos(5, 0, 0)}},
+		// Unknown terms match nothing
+		{0, []common.Address{{0xff}}, nil, nil},
+	}
+	for i, tt := range tests {
+		positions, ok := Lookup(db, testSize, tt.section, tt.addresses, tt.topics)
+		if !ok {
+			t.Errorf("test %d: lookup not served", i)
+			continue
+		}
+		if len(positions) != 0 || len(tt.want) != 0 {
+			if !reflect.DeepEqual(positions, tt.want) {
+				t.Errorf("test %d: positions mismatch: have %v, want %v", i, positions, tt.want)
+			}
+		}
+	}
+	// Unconstrained criteria and unindexed sections cannot be served
+	if _, ok := Lookup(db, testSize, 0, nil, [][]common.Hash{nil}); ok {
+		t.Errorf("unconstrained lookup served")
+	}
+	if _, ok := Lookup(db, testSize, 4, []common.Address{addr1}, nil); ok {
+		t.Errorf("unindexed section served")
+	}
+}
+
+// Tests that sections falling out of the retention window are pruned.
+func TestRetention(t *testing.T) {
+	db := ethdb.NewMemDatabase()
+	genesis := core.GenesisBlockForTesting(db, common.Address{}, big.NewInt(1000000))
+	makeChain(db, genesis, int(5*testSize), 0)
+
+	idx := &Indexer{db: db, size: testSize, retention: 2}
+	for section := uint64(0); section < 5; section++ {
+		indexSection(t, db, idx, section)
+	}
+	if tail := rawdb.ReadLogIndexTail(db); tail != 3 {
+		t.Errorf("tail mismatch: have %d, want 3", tail)
+	}
+	for section := uint64(0); section < 5; section++ {
+		_, ok := Lookup(db, testSize, section, []common.Address{addr1}, nil)
+		if want := section >= 3; ok != want {
+			t.Errorf("section %d: availability mismatch: have %v, want %v", section, ok, want)
+		}
+		if head := rawdb.ReadLogIndexHead(db, section); section < 3 && head != (common.Hash{}) {
+			t.Errorf("section %d: pruned head still present", section)
+		}
+	}
+}
+
+// Tests that reorged sections are not served and that their stale index is dropped
+// when reindexed.
+func TestReorg(t *testing.T) {
+	db := ethdb.NewMemDatabase()
+	genesis := core.GenesisBlockForTesting(db, common.Address{}, big.NewInt(1000000))
+	makeChain(db, genesis, int(testSize), 0)
+
+	idx := &Indexer{db: db, size: testSize}
+	indexSection(t, db, idx, 0)
+	head := rawdb.ReadLogIndexHead(db, 0)
+
+	// Replace the canonical chain and ensure the stale index is not served
+	makeChain(db, genesis, int(testSize), 1)
+	if _, ok := Lookup(db, testSize, 0, []common.Address{addr1}, nil); ok {
+		t.Fatalf("stale section served")
+	}
+	// Reindex the section and ensure the stale index is gone
+	indexSection(t, db, idx, 0)
+	if _, ok := Lookup(db, testSize, 0, []common.Address{addr1}, nil); !ok {
+		t.Fatalf("reindexed section not served")
+	}
+	if terms := rawdb.ReadLogIndexTerms(db, 0, head); len(terms) != 0 {
+		t.Errorf("stale terms present: %d", len(terms))
+	}
+	if positions := rawdb.ReadLogPositions(db, 0, head, AddressTerm(addr1)); len(positions) != 0 {
+		t.Errorf("stale positions present: %v", positions)
+	}
+}
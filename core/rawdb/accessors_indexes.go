@@ -17,6 +17,8 @@
 package rawdb
 
 import (
+	"encoding/binary"
+
 	"github.com/athofficial/go-ath/common"
 	"github.com/athofficial/go-ath/core/types"
 	"github.com/athofficial/go-ath/log"
@@ -105,3 +107,119 @@ func WriteBloomBits(db DatabaseWriter, bit uint, section uint64, head common.Has
 		log.Crit("Failed to store bloom bits", "err", err)
 	}
 }
+
+// LogPosition locates a log within the canonical chain.
+type LogPosition struct {
+	Number   uint64 // Number of the block containing the log
+	TxIndex  uint   // Index of the transaction emitting the log within the block
+	LogIndex uint   // Index of the log within the receipt of the transaction
+}
+
+// ReadLogIndexTail retrieves the first section retained by the log index.
+func ReadLogIndexTail(db DatabaseReader) uint64 {
+	data, _ := db.Get(logIndexTailKey)
+	if len(data) != 8 {
+		return 0
+	}
+	return binary.BigEndian.Uint64(data)
+}
+
+// WriteLogIndexTail stores the first section retained by the log index.
+func WriteLogIndexTail(db DatabaseWriter, section uint64) {
+	if err := db.Put(logIndexTailKey, encodeBlockNumber(section)); err != nil {
+		log.Crit("Failed to store log index tail", "err", err)
+	}
+}
+
+// ReadLogIndexHead retrieves the hash of the last block of a section the log
+// index was generated for.
+func ReadLogIndexHead(db DatabaseReader, section uint64) common.Hash {
+	data, _ := db.Get(logIndexHeadKey(section))
+	if len(data) != common.HashLength {
+		return common.Hash{}
+	}
+	return common.BytesToHash(data)
+}
+
+// WriteLogIndexHead stores the hash of the last block of a section the log index
+// was generated for.
+func WriteLogIndexHead(db DatabaseWriter, section uint64, head common.Hash) {
+	if err := db.Put(logIndexHeadKey(section), head.Bytes()); err != nil {
+		log.Crit("Failed to store log index head", "err", err)
+	}
+}
+
+// DeleteLogIndexHead removes the section head reference of the log index.
+func DeleteLogIndexHead(db DatabaseDeleter, section uint64) {
+	if err := db.Delete(logIndexHeadKey(section)); err != nil {
+		log.Crit("Failed to delete log index head", "err", err)
+	}
+}
+
+// ReadLogIndexTerms retrieves the list of address and topic terms indexed in a
+// section of the log index.
+func ReadLogIndexTerms(db DatabaseReader, section uint64, head common.Hash) [][]byte {
+	data, _ := db.Get(logIndexTermsKey(section, head))
+	if len(data) == 0 {
+		return nil
+	}
+	var terms [][]byte
+	if err := rlp.DecodeBytes(data, &terms); err != nil {
+		log.Error("Invalid log index terms RLP", "section", section, "head", head, "err", err)
+		return nil
+	}
+	return terms
+}
+
+// WriteLogIndexTerms stores the list of address and topic terms indexed in a
+// section of the log index.
+func WriteLogIndexTerms(db DatabaseWriter, section uint64, head common.Hash, terms [][]byte) {
+	data, err := rlp.EncodeToBytes(terms)
+	if err != nil {
+		log.Crit("Failed to encode log index terms", "err", err)
+	}
+	if err := db.Put(logIndexTermsKey(section, head), data); err != nil {
+		log.Crit("Failed to store log index terms", "err", err)
+	}
+}
+
+// DeleteLogIndexTerms removes the list of terms indexed in a section.
+func DeleteLogIndexTerms(db DatabaseDeleter, section uint64, head common.Hash) {
+	if err := db.Delete(logIndexTermsKey(section, head)); err != nil {
+		log.Crit("Failed to delete log index terms", "err", err)
+	}
+}
+
+// ReadLogPositions retrieves the positions of the logs matching an address or
+// topic term within a section of the log index.
+func ReadLogPositions(db DatabaseReader, section uint64, head common.Hash, term []byte) []LogPosition {
+	data, _ := db.Get(logPositionsKey(section, head, term))
+	if len(data) == 0 {
+		return nil
+	}
+	var positions []LogPosition
+	if err := rlp.DecodeBytes(data, &positions); err != nil {
+		log.Error("Invalid log positions RLP", "section", section, "head", head, "err", err)
+		return nil
+	}
+	return positions
+}
+
+// WriteLogPositions stores the positions of the logs matching an address or topic
+// term within a section of the log index.
+func WriteLogPositions(db DatabaseWriter, section uint64, head common.Hash, term []byte, positions []LogPosition) {
+	data, err := rlp.EncodeToBytes(positions)
+	if err != nil {
+		log.Crit("Failed to encode log positions", "err", err)
+	}
+	if err := db.Put(logPositionsKey(section, head, term), data); err != nil {
+		log.Crit("Failed to store log positions", "err", err)
+	}
+}
+
+// DeleteLogPositions removes the positions of the logs matching a term.
+func DeleteLogPositions(db DatabaseDeleter, section uint64, head common.Hash, term []byte) {
+	if err := db.Delete(logPositionsKey(section, head, term)); err != nil {
+		log.Crit("Failed to delete log positions", "err", err)
+	}
+}
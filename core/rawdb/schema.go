@@ -41,6 +41,9 @@ var (
 	// fastTrieProgressKey tracks the number of trie entries imported during fast sync.
 	fastTrieProgressKey = []byte("TrieSync")
 
+	// logIndexTailKey tracks the first section retained by the log index.
+	logIndexTailKey = []byte("LogIndexTail")
+
 	// Data item prefixes (use single byte to avoid mixing data types, avoid `i`, used for indexes).
 	headerPrefix       = []byte("h") // headerPrefix + num (uint64 big endian) + hash -> header
 	headerTDSuffix     = []byte("t") // headerPrefix + num (uint64 big endian) + hash + headerTDSuffix -> td
@@ -52,12 +55,14 @@ var (
 
 	txLookupPrefix  = []byte("l") // txLookupPrefix + hash -> transaction/receipt lookup metadata
 	bloomBitsPrefix = []byte("B") // bloomBitsPrefix + bit (uint16 big endian) + section (uint64 big endian) + hash -> bloom bits
+	logIndexPrefix  = []byte("x") // logIndexPrefix + section (uint64 big endian) [+ hash [+ term]] -> section head [terms [log positions]]
 
 	preimagePrefix = []byte("secure-key-")      // preimagePrefix + hash -> preimage
 	configPrefix   = []byte("ethereum-config-") // config prefix for the db
 
 	// Chain index prefixes (use `i` + single byte to avoid mixing data types).
 	BloomBitsIndexPrefix = []byte("iB") // BloomBitsIndexPrefix is the data table of a chain indexer to track its progress
+	LogIndexIndexPrefix  = []byte("iL") // LogIndexIndexPrefix is the data table of the log indexer to track its progress
 
 	preimageCounter    = metrics.NewRegisteredCounter("db/preimage/total", nil)
 	preimageHitCounter = metrics.NewRegisteredCounter("db/preimage/hits", nil)
@@ -123,6 +128,21 @@ func bloomBitsKey(bit uint, section uint64, hash common.Hash) []byte {
 	return key
 }
 
+// logIndexHeadKey = logIndexPrefix + section (uint64 big endian)
+func logIndexHeadKey(section uint64) []byte {
+	return append(logIndexPrefix, encodeBlockNumber(section)...)
+}
+
+// logIndexTermsKey = logIndexPrefix + section (uint64 big endian) + hash
+func logIndexTermsKey(section uint64, hash common.Hash) []byte {
+	return append(logIndexHeadKey(section), hash.Bytes()...)
+}
+
+// logPositionsKey = logIndexPrefix + section (uint64 big endian) + hash + term
+func logPositionsKey(section uint64, hash common.Hash, term []byte) []byte {
+	return append(logIndexTermsKey(section, hash), term...)
+}
+
 // preimageKey = preimagePrefix + hash
 func preimageKey(hash common.Hash) []byte {
 	return append(preimagePrefix, hash.Bytes()...)
@@ -27,6 +27,7 @@ import (
 
 	"github.com/athofficial/go-ath/common"
 	"github.com/athofficial/go-ath/common/prque"
+	"github.com/athofficial/go-ath/common/ratelimit"
 	"github.com/athofficial/go-ath/core/state"
 	"github.com/athofficial/go-ath/core/types"
 	"github.com/athofficial/go-ath/event"
@@ -76,6 +77,22 @@ var (
 	// than some meaningful limit a user might use. This is not a consensus error
 	// making the transaction invalid, rather a DOS protection.
 	ErrOversizedData = errors.New("oversized data")
+
+	// ErrSenderRateLimited is returned if a remote sender attempts to add more
+	// transactions than the configured per account rate permits.
+	ErrSenderRateLimited = errors.New("sender transaction rate exceeded")
+
+	// ErrReplaceRateLimited is returned if a remote sender attempts to replace
+	// transactions faster than the configured replacement rate permits.
+	ErrReplaceRateLimited = errors.New("sender replacement rate exceeded")
+
+	// ErrPeerRateLimited is returned if a peer attempts to add more transactions
+	// than the configured per peer rate permits.
+	ErrPeerRateLimited = errors.New("peer transaction rate exceeded")
+
+	// ErrGlobalRateLimited is returned if remote transactions arrive faster than
+	// the configured global rate permits.
+	ErrGlobalRateLimited = errors.New("global transaction rate exceeded")
 )
 
 var (
@@ -99,6 +116,12 @@ var (
 	// General tx metrics
 	invalidTxCounter     = metrics.NewRegisteredCounter("txpool/invalid", nil)
 	underpricedTxCounter = metrics.NewRegisteredCounter("txpool/underpriced", nil)
+
+	// Metrics for the rate limited remote transactions
+	senderLimitedCounter  = metrics.NewRegisteredCounter("txpool/limited/sender", nil)
+	replaceLimitedCounter = metrics.NewRegisteredCounter("txpool/limited/replace", nil)
+	peerLimitedCounter    = metrics.NewRegisteredCounter("txpool/limited/peer", nil)
+	globalLimitedCounter  = metrics.NewRegisteredCounter("txpool/limited/global", nil)
 )
 
 // TxStatus is the current status of a transaction as seen by the pool.
@@ -137,6 +160,12 @@ type TxPoolConfig struct {
 	GlobalQueue  uint64 // Maximum number of non-executable transaction slots for all accounts
 
 	Lifetime time.Duration // Maximum amount of time non-executable transaction are queued
+
+	AccountRate float64 // Maximum number of remote transactions accepted per second from a single account (0 = unlimited)
+	ReplaceRate float64 // Maximum number of remote replacements accepted per second from a single account (0 = unlimited)
+	PeerRate    float64 // Maximum number of transactions accepted per second from a single peer (0 = unlimited)
+	GlobalRate  float64 // Maximum number of remote transactions accepted per second in total (0 = unlimited)
+	RateBurst   uint64  // Number of transactions permitted in a burst before the rate limits apply
 }
 
 // DefaultTxPoolConfig contains the default configurations for the transaction
@@ -154,6 +183,8 @@ var DefaultTxPoolConfig = TxPoolConfig{
 	GlobalQueue:  1024,
 
 	Lifetime: 3 * time.Hour,
+
+	RateBurst: 64,
 }
 
 // sanitize checks the provided user configurations and changes anything that's
@@ -192,6 +223,10 @@ func (config *TxPoolConfig) sanitize() TxPoolConfig {
 		log.Warn("Sanitizing invalid txpool lifetime", "provided", conf.Lifetime, "updated", DefaultTxPoolConfig.Lifetime)
 		conf.Lifetime = DefaultTxPoolConfig.Lifetime
 	}
+	if conf.RateBurst < 1 {
+		log.Warn("Sanitizing invalid txpool rate burst", "provided", conf.RateBurst, "updated", DefaultTxPoolConfig.RateBurst)
+		conf.RateBurst = DefaultTxPoolConfig.RateBurst
+	}
 	return conf
 }
 
@@ -227,6 +262,11 @@ type TxPool struct {
 	all     *txLookup                    // All transactions to allow lookups
 	priced  *txPricedList                // All transactions sorted by price
 
+	accountLimit *ratelimit.Limiter // Rate limiter for remote transactions per account
+	replaceLimit *ratelimit.Limiter // Rate limiter for remote replacements per account
+	peerLimit    *ratelimit.Limiter // Rate limiter for transactions per originating peer
+	globalLimit  *ratelimit.Limiter // Rate limiter for all remote transactions
+
 	wg sync.WaitGroup // for shutdown sync
 
 	homestead bool
@@ -250,6 +290,11 @@ func NewTxPool(config TxPoolConfig, chainconfig *params.ChainConfig, chain block
 		all:         newTxLookup(),
 		chainHeadCh: make(chan ChainHeadEvent, chainHeadChanSize),
 		gasPrice:    new(big.Int).SetUint64(config.PriceLimit),
+
+		accountLimit: ratelimit.New(config.AccountRate, int(config.RateBurst)),
+		replaceLimit: ratelimit.New(config.ReplaceRate, int(config.RateBurst)),
+		peerLimit:    ratelimit.New(config.PeerRate, int(config.RateBurst)),
+		globalLimit:  ratelimit.New(config.GlobalRate, int(config.RateBurst)),
 	}
 	pool.locals = newAccountSet(pool.signer)
 	for _, addr := range config.Locals {
@@ -347,6 +392,12 @@ func (pool *TxPool) loop() {
 					}
 				}
 			}
+			// Forget about the rate limited entities which calmed down
+			now := time.Now()
+			pool.accountLimit.Prune(now)
+			pool.replaceLimit.Prune(now)
+			pool.peerLimit.Prune(now)
+			pool.globalLimit.Prune(now)
 			pool.mu.Unlock()
 
 		// Handle local transaction journal rotation
@@ -806,14 +857,21 @@ func (pool *TxPool) AddRemote(tx *types.Transaction) error {
 // marking the senders as a local ones in the mean time, ensuring they go around
 // the local pricing constraints.
 func (pool *TxPool) AddLocals(txs []*types.Transaction) []error {
-	return pool.addTxs(txs, !pool.config.NoLocals)
+	return pool.addTxs(txs, !pool.config.NoLocals, "")
 }
 
 // AddRemotes enqueues a batch of transactions into the pool if they are valid.
 // If the senders are not among the locally tracked ones, full pricing constraints
 // will apply.
 func (pool *TxPool) AddRemotes(txs []*types.Transaction) []error {
-	return pool.addTxs(txs, false)
+	return pool.addTxs(txs, false, "")
+}
+
+// AddRemotesFrom enqueues a batch of transactions received from the given peer
+// into the pool if they are valid. Besides the pricing constraints, the rate
+// limits of the originating peer apply too.
+func (pool *TxPool) AddRemotesFrom(peer string, txs []*types.Transaction) []error {
+	return pool.addTxs(txs, false, peer)
 }
 
 // addTx enqueues a single transaction into the pool if it is valid.
@@ -821,9 +879,18 @@ func (pool *TxPool) addTx(tx *types.Transaction, local bool) error {
 	pool.mu.Lock()
 	defer pool.mu.Unlock()
 
+	// Make sure remote transactions don't exceed any rate limits
+	var allowance *txAllowance
+	if !local {
+		var err error
+		if allowance, err = pool.limit(tx, ""); err != nil {
+			return err
+		}
+	}
 	// Try to inject the transaction and update any state
 	replace, err := pool.add(tx, local)
 	if err != nil {
+		pool.refund(allowance)
 		return err
 	}
 	// If we added a new transaction, run promotion checks and return
@@ -834,12 +901,116 @@ func (pool *TxPool) addTx(tx *types.Transaction, local bool) error {
 	return nil
 }
 
-// addTxs attempts to queue a batch of transactions if they are valid.
-func (pool *TxPool) addTxs(txs []*types.Transaction, local bool) []error {
+// addTxs attempts to queue a batch of transactions if they are valid. Remote
+// transactions exceeding the rate limits of their sender or originating peer
+// are rejected.
+func (pool *TxPool) addTxs(txs []*types.Transaction, local bool, peer string) []error {
 	pool.mu.Lock()
 	defer pool.mu.Unlock()
 
-	return pool.addTxsLocked(txs, local)
+	if local {
+		return pool.addTxsLocked(txs, local)
+	}
+	// Add the transactions within the rate limits, tracking the accepted ones.
+	// Rejected transactions are refunded right away, so they don't count
+	// against the remainder of the batch.
+	dirty := make(map[common.Address]struct{})
+	errs := make([]error, len(txs))
+
+	for i, tx := range txs {
+		allowance, err := pool.limit(tx, peer)
+		if err != nil {
+			errs[i] = err
+			continue
+		}
+		var replace bool
+		if replace, errs[i] = pool.add(tx, local); errs[i] != nil {
+			pool.refund(allowance)
+		} else if !replace {
+			from, _ := types.Sender(pool.signer, tx) // already validated
+			dirty[from] = struct{}{}
+		}
+	}
+	pool.promoteDirty(dirty)
+	return errs
+}
+
+// txAllowance records the rate limit allowances consumed by a remote transaction,
+// so that they can be refunded if the pool rejects the transaction.
+type txAllowance struct {
+	peer    string         // Originating peer charged for the transaction, if any
+	from    common.Address // Sender of the transaction
+	sender  bool           // Whether the sender's allowance was consumed
+	replace bool           // Whether the sender's replacement allowance was consumed
+	global  bool           // Whether the global allowance was consumed
+}
+
+// limit checks whether a remote transaction exceeds any of the configured rate
+// limits, consuming an allowance from all of them otherwise. Known transactions
+// and the ones of local accounts are exempt and consume no allowance. The
+// transaction pool lock must be held.
+func (pool *TxPool) limit(tx *types.Transaction, peer string) (*txAllowance, error) {
+	if pool.all.Get(tx.Hash()) != nil {
+		return nil, nil
+	}
+	from, err := types.Sender(pool.signer, tx)
+	if err != nil || pool.locals.contains(from) {
+		return nil, nil // Invalid transactions are rejected by the validation
+	}
+	now := time.Now()
+	if peer != "" && !pool.peerLimit.Allow(peer, now) {
+		log.Trace("Discarding peer rate limited transaction", "hash", tx.Hash(), "peer", peer)
+		peerLimitedCounter.Inc(1)
+		return nil, ErrPeerRateLimited
+	}
+	// Allowances consumed before hitting a limit are given back
+	allowance := &txAllowance{peer: peer, from: from}
+	if !pool.accountLimit.Allow(from, now) {
+		log.Trace("Discarding sender rate limited transaction", "hash", tx.Hash(), "from", from)
+		senderLimitedCounter.Inc(1)
+		pool.refund(allowance)
+		return nil, ErrSenderRateLimited
+	}
+	allowance.sender = true
+
+	if (pool.pending[from] != nil && pool.pending[from].Overlaps(tx)) || (pool.queue[from] != nil && pool.queue[from].Overlaps(tx)) {
+		if !pool.replaceLimit.Allow(from, now) {
+			log.Trace("Discarding replacement rate limited transaction", "hash", tx.Hash(), "from", from)
+			replaceLimitedCounter.Inc(1)
+			pool.refund(allowance)
+			return nil, ErrReplaceRateLimited
+		}
+		allowance.replace = true
+	}
+	if !pool.globalLimit.Allow(nil, now) {
+		log.Trace("Discarding globally rate limited transaction", "hash", tx.Hash())
+		globalLimitedCounter.Inc(1)
+		pool.refund(allowance)
+		return nil, ErrGlobalRateLimited
+	}
+	allowance.global = true
+	return allowance, nil
+}
+
+// refund gives back the rate limit allowances consumed by a rejected transaction,
+// so that replaying stale or invalid transactions of an account cannot exhaust
+// its allowance. The transaction pool lock must be held.
+func (pool *TxPool) refund(allowance *txAllowance) {
+	if allowance == nil {
+		return
+	}
+	if allowance.peer != "" {
+		pool.peerLimit.Refund(allowance.peer)
+	}
+	if allowance.sender {
+		pool.accountLimit.Refund(allowance.from)
+	}
+	if allowance.replace {
+		pool.replaceLimit.Refund(allowance.from)
+	}
+	if allowance.global {
+		pool.globalLimit.Refund(nil)
+	}
 }
 
 // addTxsLocked attempts to queue a batch of transactions if they are valid,
@@ -856,6 +1027,13 @@ func (pool *TxPool) addTxsLocked(txs []*types.Transaction, local bool) []error {
 			dirty[from] = struct{}{}
 		}
 	}
+	pool.promoteDirty(dirty)
+	return errs
+}
+
+// promoteDirty runs the promotion checks of the accounts which had transactions
+// added, whilst assuming the transaction pool lock is already held.
+func (pool *TxPool) promoteDirty(dirty map[common.Address]struct{}) {
 	// Only reprocess the internal state if something was actually added
 	if len(dirty) > 0 {
 		addrs := make([]common.Address, 0, len(dirty))
@@ -864,7 +1042,6 @@ func (pool *TxPool) addTxsLocked(txs []*types.Transaction, local bool) []error {
 		}
 		pool.promoteExecutables(addrs)
 	}
-	return errs
 }
 
 // Status returns the status (unknown/pending/queued) of a batch of transactions
@@ -753,6 +753,141 @@ func TestTransactionQueueAccountLimiting(t *testing.T) {
 	}
 }
 
+// Tests that remote transactions above the configured rates of their sender,
+// replacements and originating peer are rejected, while local transactions are
+// exempt.
+func TestTransactionRateLimiting(t *testing.T) {
+	t.Parallel()
+
+	// Create the pool with rate limits low enough to never refill during the test
+	statedb, _ := state.New(common.Hash{}, state.NewDatabase(ethdb.NewMemDatabase()))
+	blockchain := &testBlockChain{statedb, 1000000, new(event.Feed)}
+
+	config := testTxPoolConfig
+	config.AccountRate = 0.001
+	config.ReplaceRate = 0.001
+	config.PeerRate = 0.001
+	config.RateBurst = 3
+
+	pool := NewTxPool(config, params.TestChainConfig, blockchain)
+	defer pool.Stop()
+
+	keys := make([]*ecdsa.PrivateKey, 4)
+	for i := 0; i < len(keys); i++ {
+		keys[i], _ = crypto.GenerateKey()
+		pool.currentState.AddBalance(crypto.PubkeyToAddress(keys[i].PublicKey), big.NewInt(1000000000))
+	}
+	// Ensure a sender can only add a burst of remote transactions
+	for i := uint64(0); i < 3; i++ {
+		if err := pool.AddRemote(transaction(i, 100000, keys[0])); err != nil {
+			t.Fatalf("tx %d: failed to add transaction: %v", i, err)
+		}
+	}
+	if err := pool.AddRemote(transaction(3, 100000, keys[0])); err != ErrSenderRateLimited {
+		t.Errorf("sender limit error mismatch: have %v, want %v", err, ErrSenderRateLimited)
+	}
+	if err := pool.AddRemote(transaction(0, 100000, keys[0])); err == nil || err == ErrSenderRateLimited {
+		t.Errorf("known transaction error mismatch: have %v, want known transaction", err)
+	}
+	if err := pool.AddLocal(transaction(3, 100000, keys[0])); err != nil {
+		t.Errorf("failed to add local transaction: %v", err)
+	}
+	// Ensure replacements are limited separately from additions
+	if err := pool.AddRemote(pricedTransaction(0, 100000, big.NewInt(1), keys[1])); err != nil {
+		t.Fatalf("failed to add transaction: %v", err)
+	}
+	if err := pool.AddRemote(pricedTransaction(0, 100000, big.NewInt(2), keys[1])); err != nil {
+		t.Fatalf("failed to replace transaction: %v", err)
+	}
+	if err := pool.AddRemote(pricedTransaction(0, 100000, big.NewInt(3), keys[1])); err != nil {
+		t.Fatalf("failed to replace transaction: %v", err)
+	}
+	if err := pool.AddRemote(pricedTransaction(0, 100000, big.NewInt(4), keys[1])); err != ErrSenderRateLimited {
+		t.Errorf("sender limit error mismatch: have %v, want %v", err, ErrSenderRateLimited)
+	}
+	pool.accountLimit = nil
+	if err := pool.AddRemote(pricedTransaction(0, 100000, big.NewInt(5), keys[1])); err != nil {
+		t.Fatalf("failed to replace transaction: %v", err)
+	}
+	if err := pool.AddRemote(pricedTransaction(0, 100000, big.NewInt(6), keys[1])); err != ErrReplaceRateLimited {
+		t.Errorf("replacement limit error mismatch: have %v, want %v", err, ErrReplaceRateLimited)
+	}
+	// Ensure a single peer can only deliver a burst of transactions
+	txs := []*types.Transaction{
+		transaction(0, 100000, keys[2]),
+		transaction(1, 100000, keys[2]),
+		transaction(0, 100000, keys[3]),
+		transaction(1, 100000, keys[3]),
+	}
+	errs := pool.AddRemotesFrom("spammer", txs)
+	for i := 0; i < 3; i++ {
+		if errs[i] != nil {
+			t.Errorf("tx %d: failed to add transaction: %v", i, errs[i])
+		}
+	}
+	if errs[3] != ErrPeerRateLimited {
+		t.Errorf("peer limit error mismatch: have %v, want %v", errs[3], ErrPeerRateLimited)
+	}
+	if errs := pool.AddRemotesFrom("honest", txs[3:]); errs[0] != nil {
+		t.Errorf("failed to add transaction from another peer: %v", errs[0])
+	}
+	if err := validateTxPoolInternals(pool); err != nil {
+		t.Fatalf("pool internal state corrupted: %v", err)
+	}
+}
+
+// Tests that transactions rejected by the pool give back the rate limit allowances
+// they consumed, so that replaying stale transactions of an account can't starve
+// its new ones.
+func TestTransactionRateLimitRefund(t *testing.T) {
+	t.Parallel()
+
+	statedb, _ := state.New(common.Hash{}, state.NewDatabase(ethdb.NewMemDatabase()))
+	blockchain := &testBlockChain{statedb, 1000000, new(event.Feed)}
+
+	config := testTxPoolConfig
+	config.AccountRate = 0.001
+	config.PeerRate = 0.001
+	config.RateBurst = 2
+
+	pool := NewTxPool(config, params.TestChainConfig, blockchain)
+	defer pool.Stop()
+
+	key, _ := crypto.GenerateKey()
+	account := crypto.PubkeyToAddress(key.PublicKey)
+	pool.currentState.AddBalance(account, big.NewInt(1000000000))
+	pool.currentState.SetNonce(account, 5)
+
+	// Replay a batch of stale transactions, rejected for their nonces
+	stale := make([]*types.Transaction, 5)
+	for i := range stale {
+		stale[i] = transaction(uint64(i), 100000, key)
+	}
+	for i := 0; i < 3; i++ {
+		if err := pool.AddRemote(stale[i]); err != ErrNonceTooLow {
+			t.Fatalf("stale tx %d: error mismatch: have %v, want %v", i, err, ErrNonceTooLow)
+		}
+	}
+	for i, err := range pool.AddRemotesFrom("replayer", stale) {
+		if err != ErrNonceTooLow {
+			t.Fatalf("stale tx %d: error mismatch: have %v, want %v", i, err, ErrNonceTooLow)
+		}
+	}
+	// The sender and the peer should still have their full allowance
+	errs := pool.AddRemotesFrom("replayer", []*types.Transaction{transaction(5, 100000, key), transaction(6, 100000, key)})
+	for i, err := range errs {
+		if err != nil {
+			t.Errorf("tx %d: failed to add transaction: %v", i, err)
+		}
+	}
+	if err := pool.AddRemote(transaction(7, 100000, key)); err != ErrSenderRateLimited {
+		t.Errorf("sender limit error mismatch: have %v, want %v", err, ErrSenderRateLimited)
+	}
+	if err := validateTxPoolInternals(pool); err != nil {
+		t.Fatalf("pool internal state corrupted: %v", err)
+	}
+}
+
 // Tests that if the transaction count belonging to multiple accounts go above
 // some threshold, the higher transactions are dropped to prevent DOS attacks.
 //
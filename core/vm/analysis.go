@@ -16,6 +16,12 @@
 
 package vm
 
+import (
+	"github.com/athofficial/go-ath/common"
+	"github.com/athofficial/go-ath/params"
+	lru "github.com/hashicorp/golang-lru"
+)
+
 // bitvec is a bit vector which maps bytes in a program.
 // An unset bit means the byte is an opcode, a set bit means
 // it's data (i.e. argument of PUSHxx).
@@ -60,3 +66,108 @@ func codeBitmap(code []byte) bitvec {
 	}
 	return bits
 }
+
+// blockAnalysisCacheLimit is the number of contracts whose basic block analysis
+// is cached across interpreter instances.
+const blockAnalysisCacheLimit = 1024
+
+// blockAnalysisCache caches the basic block analysis of contract code, keyed by
+// code hash and instruction set.
+var blockAnalysisCache, _ = lru.New(blockAnalysisCacheLimit)
+
+// blockAnalysisKey identifies a cached block analysis. The analysis depends on
+// the instruction set, so the same code is analysed separately for each fork.
+type blockAnalysisKey struct {
+	hash common.Hash
+	set  instructionSetID
+}
+
+// codeBlock is a basic block of straight-line instructions which all have a
+// constant gas cost and neither touch memory nor state, nor depend on the
+// remaining gas. Such a block can be charged and stack-validated on entry
+// instead of once per instruction.
+type codeBlock struct {
+	end      uint64 // Program counter following the last instruction of the block
+	gas      uint64 // Accumulated constant gas of all instructions in the block
+	minStack int    // Minimum stack height required on entry
+	maxStack int    // Maximum stack height allowed on entry
+}
+
+// blockAnalysis is the result of splitting a piece of code into basic blocks.
+type blockAnalysis struct {
+	blocks []codeBlock // Basic blocks in code order
+	starts []uint32    // Index+1 into blocks for every block starting pc, 0 otherwise
+}
+
+// blockAt returns the basic block starting at the given program counter, or nil
+// if no block starts there.
+func (a *blockAnalysis) blockAt(pc uint64) *codeBlock {
+	if pc >= uint64(len(a.starts)) {
+		return nil
+	}
+	if idx := a.starts[pc]; idx != 0 {
+		return &a.blocks[idx-1]
+	}
+	return nil
+}
+
+// blockable reports whether an operation may be executed as part of a basic
+// block, without its gas and stack requirements being checked individually.
+func blockable(op OpCode, operation *operation) bool {
+	switch {
+	case !operation.valid:
+		return false
+	case operation.gasCost != nil || operation.memorySize != nil:
+		return false
+	case operation.writes || operation.returns:
+		return false
+	case operation.halts || operation.jumps || operation.reverts:
+		return false
+	case op == GAS:
+		return false
+	}
+	return true
+}
+
+// codeBlocks splits the code into basic blocks of blockable instructions with
+// respect to the given instruction set. Blocks are terminated by non-blockable
+// instructions, which are executed individually, and by jump destinations,
+// which always start a new block.
+func codeBlocks(code []byte, jumpTable *[256]operation) *blockAnalysis {
+	var (
+		analysis = &blockAnalysis{starts: make([]uint32, len(code))}
+		block    *codeBlock
+		height   int // Stack height relative to the block's entry
+	)
+	for pc := uint64(0); pc < uint64(len(code)); {
+		op := OpCode(code[pc])
+		operation := &jumpTable[op]
+
+		if !blockable(op, operation) {
+			block = nil
+			pc++
+			continue
+		}
+		if block == nil || op == JUMPDEST {
+			analysis.blocks = append(analysis.blocks, codeBlock{maxStack: int(params.StackLimit)})
+			analysis.starts[pc] = uint32(len(analysis.blocks))
+
+			block, height = &analysis.blocks[len(analysis.blocks)-1], 0
+		}
+		block.gas += operation.constantGas
+		if need := operation.minStack - height; need > block.minStack {
+			block.minStack = need
+		}
+		if allow := operation.maxStack - height; allow < block.maxStack {
+			block.maxStack = allow
+		}
+		height += int(params.StackLimit) - operation.maxStack
+
+		if op >= PUSH1 && op <= PUSH32 {
+			pc += uint64(op - PUSH1 + 1)
+		}
+		pc++
+		block.end = pc
+	}
+	return analysis
+}
@@ -20,6 +20,7 @@ import (
 	"testing"
 
 	"github.com/athofficial/go-ath/crypto"
+	"github.com/athofficial/go-ath/params"
 )
 
 func TestJumpDestAnalysis(t *testing.T) {
@@ -55,6 +56,36 @@ func TestJumpDestAnalysis(t *testing.T) {
 	}
 }
 
+func TestBlockAnalysis(t *testing.T) {
+	code := []byte{
+		byte(PUSH1), 0x01, byte(PUSH1), 0x02, byte(ADD), // block 0: 3 ops, pushes 1
+		byte(JUMPDEST), byte(POP), byte(POP), // block 1: starts at jumpdest, pops 2
+		byte(MSTORE),                       // not blockable
+		byte(DUP2), byte(SWAP1), byte(GAS), // block 2: DUP2 and SWAP1 only
+		byte(PUSH2), 0x01, // block 3: truncated push
+	}
+	analysis := codeBlocks(code, &constantinopleInstructionSet)
+
+	want := map[uint64]codeBlock{
+		0:  {end: 5, gas: 3 * GasFastestStep, minStack: 0, maxStack: 1022},
+		5:  {end: 8, gas: params.JumpdestGas + 2*GasQuickStep, minStack: 2, maxStack: 1024},
+		9:  {end: 11, gas: 2 * GasFastestStep, minStack: 2, maxStack: 1023},
+		12: {end: 15, gas: GasFastestStep, minStack: 0, maxStack: 1023},
+	}
+	for pc := uint64(0); pc < uint64(len(code)); pc++ {
+		block := analysis.blockAt(pc)
+		exp, ok := want[pc]
+		switch {
+		case !ok && block != nil:
+			t.Errorf("pc %d: unexpected block %+v", pc, *block)
+		case ok && block == nil:
+			t.Errorf("pc %d: missing block", pc)
+		case ok && *block != exp:
+			t.Errorf("pc %d: block mismatch: have %+v, want %+v", pc, *block, exp)
+		}
+	}
+}
+
 func BenchmarkJumpdestAnalysis_1200k(bench *testing.B) {
 	// 1.4 ms
 	code := make([]byte, 1200000)
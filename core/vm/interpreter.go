@@ -46,8 +46,24 @@ type Config struct {
 	EWASMInterpreter string
 	// Type of the EVM interpreter
 	EVMInterpreter string
+	// BlockAnalysis enables the optimised execution mode which pre-analyses
+	// contract code into basic blocks, charging gas and validating the stack
+	// once per block. It is ignored when debugging.
+	BlockAnalysis bool
 }
 
+// instructionSetID identifies one of the built-in instruction sets, so that code
+// analysis depending on it can be shared between interpreters.
+type instructionSetID uint8
+
+const (
+	customInstructionSet instructionSetID = iota // Caller supplied jump table
+	frontierSet
+	homesteadSet
+	byzantiumSet
+	constantinopleSet
+)
+
 // Interpreter is used to run Ethereum based contracts and will utilise the
 // passed environment to query external sources for state information.
 // The Interpreter will run the byte code VM based on the passed
@@ -83,6 +99,7 @@ type EVMInterpreter struct {
 	evm      *EVM
 	cfg      Config
 	gasTable params.GasTable
+	set      instructionSetID // Built-in instruction set in use, if any
 
 	intPool *intPool
 
@@ -98,16 +115,17 @@ func NewEVMInterpreter(evm *EVM, cfg Config) *EVMInterpreter {
 	// We use the STOP instruction whether to see
 	// the jump table was initialised. If it was not
 	// we'll set the default jump table.
+	set := customInstructionSet
 	if !cfg.JumpTable[STOP].valid {
 		switch {
 		case evm.ChainConfig().IsConstantinople(evm.BlockNumber):
-			cfg.JumpTable = constantinopleInstructionSet
+			cfg.JumpTable, set = constantinopleInstructionSet, constantinopleSet
 		case evm.ChainConfig().IsByzantium(evm.BlockNumber):
-			cfg.JumpTable = byzantiumInstructionSet
+			cfg.JumpTable, set = byzantiumInstructionSet, byzantiumSet
 		case evm.ChainConfig().IsHomestead(evm.BlockNumber):
-			cfg.JumpTable = homesteadInstructionSet
+			cfg.JumpTable, set = homesteadInstructionSet, homesteadSet
 		default:
-			cfg.JumpTable = frontierInstructionSet
+			cfg.JumpTable, set = frontierInstructionSet, frontierSet
 		}
 	}
 
@@ -115,7 +133,24 @@ func NewEVMInterpreter(evm *EVM, cfg Config) *EVMInterpreter {
 		evm:      evm,
 		cfg:      cfg,
 		gasTable: evm.ChainConfig().GasTable(evm.BlockNumber),
+		set:      set,
+	}
+}
+
+// blockAnalysis returns the basic block analysis of the contract's code, or nil
+// if the code cannot be analysed. Results of built-in instruction sets are
+// cached by code hash and shared between interpreters.
+func (in *EVMInterpreter) blockAnalysis(contract *Contract) *blockAnalysis {
+	if in.set == customInstructionSet || contract.CodeHash == (common.Hash{}) {
+		return nil
+	}
+	key := blockAnalysisKey{hash: contract.CodeHash, set: in.set}
+	if cached, ok := blockAnalysisCache.Get(key); ok {
+		return cached.(*blockAnalysis)
 	}
+	analysis := codeBlocks(contract.Code, &in.cfg.JumpTable)
+	blockAnalysisCache.Add(key, analysis)
+	return analysis
 }
 
 func (in *EVMInterpreter) enforceRestrictions(op OpCode, operation operation, stack *Stack) error {
@@ -170,9 +205,10 @@ func (in *EVMInterpreter) Run(contract *Contract, input []byte, readOnly bool) (
 	}
 
 	var (
-		op    OpCode        // current opcode
-		mem   = NewMemory() // bound memory
-		stack = newstack()  // local stack
+		op     OpCode         // current opcode
+		mem    = NewMemory()  // bound memory
+		stack  = newstack()   // local stack
+		blocks *blockAnalysis // basic blocks of the code in optimised mode
 		// For optimisation reason we're using uint64 as the program counter.
 		// It's theoretically possible to go above 2^64. The YP defines the PC
 		// to be uint256. Practically much less so feasible.
@@ -185,6 +221,11 @@ func (in *EVMInterpreter) Run(contract *Contract, input []byte, readOnly bool) (
 	)
 	contract.Input = input
 
+	// Tracers need to observe every single step, so only use the basic block
+	// analysis if no debugging was requested.
+	if in.cfg.BlockAnalysis && !in.cfg.Debug {
+		blocks = in.blockAnalysis(contract)
+	}
 	// Reclaim the stack as an int pool when the execution stops
 	defer func() { in.intPool.put(stack.data...) }()
 
@@ -208,7 +249,28 @@ func (in *EVMInterpreter) Run(contract *Contract, input []byte, readOnly bool) (
 			// Capture pre-execution values for tracing.
 			logged, pcCopy, gasCopy = false, pc, contract.Gas
 		}
-
+		// If a basic block starts here and its gas and stack requirements are
+		// met on entry, run all its instructions without checking them one by
+		// one. Otherwise fall back to stepping through them individually, which
+		// yields the exact same failure as the unoptimised interpreter.
+		if blocks != nil {
+			if block := blocks.blockAt(pc); block != nil && contract.Gas >= block.gas {
+				if sLen := stack.len(); sLen >= block.minStack && sLen <= block.maxStack {
+					contract.Gas -= block.gas
+					for pc < block.end {
+						op = OpCode(contract.Code[pc])
+						if _, err := in.cfg.JumpTable[op].execute(&pc, in, contract, mem, stack); err != nil {
+							return nil, err
+						}
+						if verifyPool {
+							verifyIntegerPool(in.intPool)
+						}
+						pc++
+					}
+					continue
+				}
+			}
+		}
 		// Get the operation from the jump table and validate the stack to ensure there are
 		// enough stack items available to perform the operation.
 		op = contract.GetOp(pc)
@@ -216,8 +278,10 @@ func (in *EVMInterpreter) Run(contract *Contract, input []byte, readOnly bool) (
 		if !operation.valid {
 			return nil, fmt.Errorf("invalid opcode 0x%x", int(op))
 		}
-		if err := operation.validateStack(stack); err != nil {
-			return nil, err
+		if sLen := stack.len(); sLen < operation.minStack {
+			return nil, fmt.Errorf("stack underflow (%d <=> %d)", sLen, operation.minStack)
+		} else if sLen > operation.maxStack {
+			return nil, fmt.Errorf("stack limit reached %d (%d)", sLen, params.StackLimit)
 		}
 		// If the operation is valid, enforce and write restrictions
 		if err := in.enforceRestrictions(op, operation, stack); err != nil {
@@ -240,7 +304,10 @@ func (in *EVMInterpreter) Run(contract *Contract, input []byte, readOnly bool) (
 		}
 		// consume the gas and return an error if not enough gas is available.
 		// cost is explicitly set so that the capture state defer method can get the proper cost
-		cost, err = operation.gasCost(in.gasTable, in.evm, contract, stack, mem, memorySize)
+		cost = operation.constantGas
+		if operation.gasCost != nil {
+			cost, err = operation.gasCost(in.gasTable, in.evm, contract, stack, mem, memorySize)
+		}
 		if err != nil || !contract.UseGas(cost) {
 			return nil, ErrOutOfGas
 		}
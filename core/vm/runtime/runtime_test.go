@@ -17,6 +17,7 @@
 package runtime
 
 import (
+	"bytes"
 	"math/big"
 	"strings"
 	"testing"
@@ -201,3 +202,62 @@ func BenchmarkEVM_CREATE2_1200(bench *testing.B) {
 	// initcode size 1200K, repeatedly calls CREATE2 and then modifies the mem contents
 	benchmarkEVM_Create(bench, "5b5862124f80600080f5600152600056")
 }
+
+// Tests that the optimised basic block execution mode yields exactly the same
+// results as the plain interpreter, including the failure modes at every gas
+// allowance around the point where execution runs out of gas.
+func TestBlockAnalysisEquivalence(t *testing.T) {
+	programs := map[string][]byte{
+		"loop": {
+			byte(vm.PUSH1), 0,
+			byte(vm.JUMPDEST),
+			byte(vm.PUSH1), 1, byte(vm.ADD),
+			byte(vm.DUP1), byte(vm.PUSH1), 20, byte(vm.GT),
+			byte(vm.PUSH1), 2, byte(vm.JUMPI),
+			byte(vm.PUSH1), 0, byte(vm.MSTORE),
+			byte(vm.PUSH1), 32, byte(vm.PUSH1), 0, byte(vm.RETURN),
+		},
+		"underflow": {
+			byte(vm.PUSH1), 1, byte(vm.PUSH1), 2, byte(vm.ADD), byte(vm.ADD), byte(vm.STOP),
+		},
+		"overflow": {
+			byte(vm.JUMPDEST), byte(vm.PUSH1), 1, byte(vm.PUSH1), 0, byte(vm.JUMP),
+		},
+		"gas": {
+			byte(vm.PUSH1), 1, byte(vm.PUSH1), 2, byte(vm.ADD), byte(vm.GAS),
+			byte(vm.PUSH1), 0, byte(vm.MSTORE),
+			byte(vm.PUSH1), 32, byte(vm.PUSH1), 0, byte(vm.RETURN),
+		},
+		"invalid": {
+			byte(vm.PUSH1), 1, byte(vm.PUSH1), 2, 0xef, byte(vm.ADD),
+		},
+		"truncated": {
+			byte(vm.PUSH1), 1, byte(vm.PUSH1), 2, byte(vm.ADD), byte(vm.PUSH32), 1,
+		},
+	}
+	run := func(code []byte, gas uint64, optimise bool) ([]byte, uint64, string) {
+		statedb, _ := state.New(common.Hash{}, state.NewDatabase(ethdb.NewMemDatabase()))
+		address := common.HexToAddress("0x0a")
+		statedb.SetCode(address, code)
+
+		ret, left, err := Call(address, nil, &Config{
+			State:     statedb,
+			GasLimit:  gas,
+			EVMConfig: vm.Config{BlockAnalysis: optimise},
+		})
+		if err != nil {
+			return ret, left, err.Error()
+		}
+		return ret, left, ""
+	}
+	for name, code := range programs {
+		for gas := uint64(0); gas < 2000; gas++ {
+			ret1, left1, err1 := run(code, gas, false)
+			ret2, left2, err2 := run(code, gas, true)
+
+			if !bytes.Equal(ret1, ret2) || left1 != left2 || err1 != err2 {
+				t.Fatalf("%s, gas %d: result mismatch: have (%x, %d, %q), want (%x, %d, %q)", name, gas, ret2, left2, err2, ret1, left1, err1)
+			}
+		}
+	}
+}
@@ -53,6 +53,9 @@ const (
 	systemCPUSampleLimit      = 200 // Maximum number of system cpu data samples
 	diskReadSampleLimit       = 200 // Maximum number of disk read data samples
 	diskWriteSampleLimit      = 200 // Maximum number of disk write data samples
+	rpcRequestsSampleLimit    = 200 // Maximum number of RPC request rate samples
+	rpcFailuresSampleLimit    = 200 // Maximum number of RPC failure rate samples
+	rpcLatencySampleLimit     = 200 // Maximum number of RPC latency samples
 )
 
 var nextID uint32 // Next connection id
@@ -105,6 +108,11 @@ func New(config *Config, commit string, logdir string) *Dashboard {
 				DiskRead:       emptyChartEntries(now, diskReadSampleLimit, config.Refresh),
 				DiskWrite:      emptyChartEntries(now, diskWriteSampleLimit, config.Refresh),
 			},
+			RPC: &RPCMessage{
+				Requests: emptyChartEntries(now, rpcRequestsSampleLimit, config.Refresh),
+				Failures: emptyChartEntries(now, rpcFailuresSampleLimit, config.Refresh),
+				Latency:  emptyChartEntries(now, rpcLatencySampleLimit, config.Refresh),
+			},
 		},
 		logdir: logdir,
 	}
@@ -272,6 +280,20 @@ func meterCollector(name string) func() int64 {
 	}
 }
 
+// timerCollector returns a function, which retrieves the mean of the recent
+// durations in nanoseconds recorded by a specific timer.
+func timerCollector(name string) func() float64 {
+	if metric := metrics.DefaultRegistry.Get(name); metric != nil {
+		t := metric.(metrics.Timer)
+		return func() float64 {
+			return t.Mean()
+		}
+	}
+	return func() float64 {
+		return 0
+	}
+}
+
 // collectData collects the required data to plot on the dashboard.
 func (db *Dashboard) collectData() {
 	defer db.wg.Done()
@@ -285,6 +307,9 @@ func (db *Dashboard) collectData() {
 		collectNetworkEgress  = meterCollector("p2p/OutboundTraffic")
 		collectDiskRead       = meterCollector("eth/db/chaindata/disk/read")
 		collectDiskWrite      = meterCollector("eth/db/chaindata/disk/write")
+		collectRPCRequests    = meterCollector("rpc/requests")
+		collectRPCFailures    = meterCollector("rpc/failures")
+		collectRPCLatency     = timerCollector("rpc/duration")
 
 		prevNetworkIngress = collectNetworkIngress()
 		prevNetworkEgress  = collectNetworkEgress()
@@ -292,6 +317,8 @@ func (db *Dashboard) collectData() {
 		prevSystemCPUUsage = systemCPUUsage
 		prevDiskRead       = collectDiskRead()
 		prevDiskWrite      = collectDiskWrite()
+		prevRPCRequests    = collectRPCRequests()
+		prevRPCFailures    = collectRPCFailures()
 
 		frequency = float64(db.config.Refresh / time.Second)
 		numCPU    = float64(runtime.NumCPU())
@@ -311,6 +338,8 @@ func (db *Dashboard) collectData() {
 				curSystemCPUUsage = systemCPUUsage
 				curDiskRead       = collectDiskRead()
 				curDiskWrite      = collectDiskWrite()
+				curRPCRequests    = collectRPCRequests()
+				curRPCFailures    = collectRPCFailures()
 
 				deltaNetworkIngress = float64(curNetworkIngress - prevNetworkIngress)
 				deltaNetworkEgress  = float64(curNetworkEgress - prevNetworkEgress)
@@ -318,6 +347,8 @@ func (db *Dashboard) collectData() {
 				deltaSystemCPUUsage = curSystemCPUUsage.Delta(prevSystemCPUUsage)
 				deltaDiskRead       = curDiskRead - prevDiskRead
 				deltaDiskWrite      = curDiskWrite - prevDiskWrite
+				deltaRPCRequests    = float64(curRPCRequests - prevRPCRequests)
+				deltaRPCFailures    = float64(curRPCFailures - prevRPCFailures)
 			)
 			prevNetworkIngress = curNetworkIngress
 			prevNetworkEgress = curNetworkEgress
@@ -325,6 +356,8 @@ func (db *Dashboard) collectData() {
 			prevSystemCPUUsage = curSystemCPUUsage
 			prevDiskRead = curDiskRead
 			prevDiskWrite = curDiskWrite
+			prevRPCRequests = curRPCRequests
+			prevRPCFailures = curRPCFailures
 
 			now := time.Now()
 
@@ -361,6 +394,18 @@ func (db *Dashboard) collectData() {
 				Time:  now,
 				Value: float64(deltaDiskWrite) / frequency,
 			}
+			rpcRequests := &ChartEntry{
+				Time:  now,
+				Value: deltaRPCRequests / frequency,
+			}
+			rpcFailures := &ChartEntry{
+				Time:  now,
+				Value: deltaRPCFailures / frequency,
+			}
+			rpcLatency := &ChartEntry{
+				Time:  now,
+				Value: collectRPCLatency() / float64(time.Millisecond),
+			}
 			sys := db.history.System
 			db.lock.Lock()
 			sys.ActiveMemory = append(sys.ActiveMemory[1:], activeMemory)
@@ -371,6 +416,10 @@ func (db *Dashboard) collectData() {
 			sys.SystemCPU = append(sys.SystemCPU[1:], systemCPU)
 			sys.DiskRead = append(sys.DiskRead[1:], diskRead)
 			sys.DiskWrite = append(sys.DiskWrite[1:], diskWrite)
+			rpc := db.history.RPC
+			rpc.Requests = append(rpc.Requests[1:], rpcRequests)
+			rpc.Failures = append(rpc.Failures[1:], rpcFailures)
+			rpc.Latency = append(rpc.Latency[1:], rpcLatency)
 			db.lock.Unlock()
 
 			db.sendToAll(&Message{
@@ -384,6 +433,11 @@ func (db *Dashboard) collectData() {
 					DiskRead:       ChartEntries{diskRead},
 					DiskWrite:      ChartEntries{diskWrite},
 				},
+				RPC: &RPCMessage{
+					Requests: ChartEntries{rpcRequests},
+					Failures: ChartEntries{rpcFailures},
+					Latency:  ChartEntries{rpcLatency},
+				},
 			})
 		}
 	}
@@ -28,6 +28,7 @@ type Message struct {
 	TxPool  *TxPoolMessage  `json:"txpool,omitempty"`
 	Network *NetworkMessage `json:"network,omitempty"`
 	System  *SystemMessage  `json:"system,omitempty"`
+	RPC     *RPCMessage     `json:"rpc,omitempty"`
 	Logs    *LogsMessage    `json:"logs,omitempty"`
 }
 
@@ -70,6 +71,13 @@ type SystemMessage struct {
 	DiskWrite      ChartEntries `json:"diskWrite,omitempty"`
 }
 
+// RPCMessage contains the load and the performance of the RPC servers.
+type RPCMessage struct {
+	Requests ChartEntries `json:"requests,omitempty"` // Calls served per second
+	Failures ChartEntries `json:"failures,omitempty"` // Failed calls per second
+	Latency  ChartEntries `json:"latency,omitempty"`  // Mean execution time in milliseconds
+}
+
 // LogsMessage wraps up a log chunk. If Source isn't present, the chunk is a stream chunk.
 type LogsMessage struct {
 	Source *LogFile        `json:"source,omitempty"` // Attributes of the log file.
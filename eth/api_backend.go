@@ -25,6 +25,7 @@ import (
 	"github.com/athofficial/go-ath/common/math"
 	"github.com/athofficial/go-ath/core"
 	"github.com/athofficial/go-ath/core/bloombits"
+	"github.com/athofficial/go-ath/core/rawdb"
 	"github.com/athofficial/go-ath/core/state"
 	"github.com/athofficial/go-ath/core/types"
 	"github.com/athofficial/go-ath/core/vm"
@@ -137,6 +138,10 @@ func (b *EthAPIBackend) SubscribeRemovedLogsEvent(ch chan<- core.RemovedLogsEven
 	return b.eth.BlockChain().SubscribeRemovedLogsEvent(ch)
 }
 
+func (b *EthAPIBackend) SubscribeChainReorgEvent(ch chan<- core.ChainReorgEvent) event.Subscription {
+	return b.eth.BlockChain().SubscribeChainReorgEvent(ch)
+}
+
 func (b *EthAPIBackend) SubscribeChainEvent(ch chan<- core.ChainEvent) event.Subscription {
 	return b.eth.BlockChain().SubscribeChainEvent(ch)
 }
@@ -201,6 +206,10 @@ func (b *EthAPIBackend) SuggestPrice(ctx context.Context) (*big.Int, error) {
 	return b.gpo.SuggestPrice(ctx)
 }
 
+func (b *EthAPIBackend) FeeHistory(ctx context.Context, blocks int, lastBlock rpc.BlockNumber, percentiles []float64) (*big.Int, [][]*big.Int, []float64, error) {
+	return b.gpo.FeeHistory(ctx, blocks, lastBlock, percentiles)
+}
+
 func (b *EthAPIBackend) ChainDb() ethdb.Database {
 	return b.eth.ChainDb()
 }
@@ -222,6 +231,14 @@ func (b *EthAPIBackend) BloomStatus() (uint64, uint64) {
 	return params.BloomBitsBlocks, sections
 }
 
+func (b *EthAPIBackend) LogIndexStatus() (uint64, uint64, uint64) {
+	if b.eth.logIndexer == nil {
+		return params.BloomBitsBlocks, 0, 0
+	}
+	sections, _, _ := b.eth.logIndexer.Sections()
+	return params.BloomBitsBlocks, rawdb.ReadLogIndexTail(b.eth.chainDb), sections
+}
+
 func (b *EthAPIBackend) ServiceFilter(ctx context.Context, session *bloombits.MatcherSession) {
 	for i := 0; i < bloomFilterThreads; i++ {
 		go session.Multiplex(bloomRetrievalBatch, bloomRetrievalWait, b.eth.bloomRequests)
@@ -33,6 +33,7 @@ import (
 	"github.com/athofficial/go-ath/consensus/ubqhash"
 	"github.com/athofficial/go-ath/core"
 	"github.com/athofficial/go-ath/core/bloombits"
+	"github.com/athofficial/go-ath/core/logindex"
 	"github.com/athofficial/go-ath/core/rawdb"
 	"github.com/athofficial/go-ath/core/types"
 	"github.com/athofficial/go-ath/core/vm"
@@ -46,6 +47,7 @@ import (
 	"github.com/athofficial/go-ath/miner"
 	"github.com/athofficial/go-ath/node"
 	"github.com/athofficial/go-ath/p2p"
+	"github.com/athofficial/go-ath/p2p/discover"
 	"github.com/athofficial/go-ath/params"
 	"github.com/athofficial/go-ath/rlp"
 	"github.com/athofficial/go-ath/rpc"
@@ -55,6 +57,7 @@ type LesServer interface {
 	Start(srvr *p2p.Server)
 	Stop()
 	Protocols() []p2p.Protocol
+	APIs() []rpc.API
 	SetBloomBitsIndexer(bbIndexer *core.ChainIndexer)
 }
 
@@ -64,7 +67,8 @@ type Ethereum struct {
 	chainConfig *params.ChainConfig
 
 	// Channel for shutting down the service
-	shutdownChan chan bool // Channel for shutting down the Ethereum
+	shutdownChan chan bool     // Channel for shutting down the Ethereum
+	topicQuit    chan struct{} // Channel stopping the discovery topic registration
 
 	// Handlers
 	txPool          *core.TxPool
@@ -81,6 +85,7 @@ type Ethereum struct {
 
 	bloomRequests chan chan *bloombits.Retrieval // Channel receiving bloom data retrieval requests
 	bloomIndexer  *core.ChainIndexer             // Bloom indexer operating during block imports
+	logIndexer    *core.ChainIndexer             // Exact log indexer cascaded from the bloom indexer (nil if disabled)
 
 	APIBackend *EthAPIBackend
 
@@ -90,6 +95,7 @@ type Ethereum struct {
 
 	networkID     uint64
 	netRPCService *ethapi.PublicNetAPI
+	tracing       bool // Whether the tracing (debug) API is served to remote clients
 
 	lock sync.RWMutex // Protects the variadic fields (e.g. gas price and etherbase)
 }
@@ -132,7 +138,9 @@ func New(ctx *node.ServiceContext, config *Config) (*Ethereum, error) {
 		accountManager: ctx.AccountManager,
 		engine:         CreateConsensusEngine(ctx, chainConfig, &config.Ubqhash, config.MinerNotify, config.MinerNoverify, chainDb),
 		shutdownChan:   make(chan bool),
+		topicQuit:      make(chan struct{}),
 		networkID:      config.NetworkId,
+		tracing:        ctx.ExposesModule("debug"),
 		gasPrice:       config.MinerGasPrice,
 		etherbase:      config.Etherbase,
 		bloomRequests:  make(chan chan *bloombits.Retrieval),
@@ -155,6 +163,7 @@ func New(ctx *node.ServiceContext, config *Config) (*Ethereum, error) {
 			EnablePreimageRecording: config.EnablePreimageRecording,
 			EWASMInterpreter:        config.EWASMInterpreter,
 			EVMInterpreter:          config.EVMInterpreter,
+			BlockAnalysis:           config.EVMBlockAnalysis,
 		}
 		cacheConfig = &core.CacheConfig{Disabled: config.NoPruning, TrieCleanLimit: config.TrieCleanCache, TrieDirtyLimit: config.TrieDirtyCache, TrieTimeLimit: config.TrieTimeout}
 	)
@@ -168,6 +177,11 @@ func New(ctx *node.ServiceContext, config *Config) (*Ethereum, error) {
 		eth.blockchain.SetHead(compat.RewindTo)
 		rawdb.WriteChainConfig(chainDb, genesisHash, chainConfig)
 	}
+	if config.LogIndex {
+		retention := (config.LogIndexRetention + params.BloomBitsBlocks - 1) / params.BloomBitsBlocks
+		eth.logIndexer = logindex.NewIndexer(chainDb, params.BloomBitsBlocks, retention)
+		eth.bloomIndexer.AddChildIndexer(eth.logIndexer)
+	}
 	eth.bloomIndexer.Start(eth.blockchain)
 
 	if config.TxPool.Journal != "" {
@@ -179,7 +193,11 @@ func New(ctx *node.ServiceContext, config *Config) (*Ethereum, error) {
 		return nil, err
 	}
 
-	eth.miner = miner.New(eth, eth.chainConfig, eth.EventMux(), eth.engine, config.MinerRecommit, config.MinerGasFloor, config.MinerGasCeil, eth.isLocalBlock)
+	ordering, err := miner.NewTxOrderingPolicy(config.MinerTxOrdering, config.MinerTxBlacklist)
+	if err != nil {
+		return nil, err
+	}
+	eth.miner = miner.New(eth, eth.chainConfig, eth.EventMux(), eth.engine, config.MinerRecommit, config.MinerGasFloor, config.MinerGasCeil, ordering, eth.isLocalBlock)
 	eth.miner.SetExtra(makeExtraData(config.MinerExtraData))
 
 	eth.APIBackend = &EthAPIBackend{eth, nil}
@@ -187,6 +205,9 @@ func New(ctx *node.ServiceContext, config *Config) (*Ethereum, error) {
 	if gpoParams.Default == nil {
 		gpoParams.Default = config.MinerGasPrice
 	}
+	if gpoParams.MinPrice == nil {
+		gpoParams.MinPrice = new(big.Int).SetUint64(config.TxPool.PriceLimit)
+	}
 	eth.APIBackend.gpo = gasprice.NewOracle(eth.APIBackend, gpoParams)
 
 	return eth, nil
@@ -260,6 +281,11 @@ func (s *Ethereum) APIs() []rpc.API {
 	// Append any APIs exposed explicitly by the consensus engine
 	apis = append(apis, s.engine.APIs(s.BlockChain())...)
 
+	// Append the APIs of the light server
+	if s.lesServer != nil {
+		apis = append(apis, s.lesServer.APIs()...)
+	}
+
 	// Append all the local APIs and return
 	return append(apis, []rpc.API{
 		{
@@ -488,6 +514,10 @@ func (s *Ethereum) Start(srvr *p2p.Server) error {
 	// Start the bloom bits servicing goroutines
 	s.startBloomHandlers(params.BloomBitsBlocks)
 
+	// Advertise the fork ID of the chain head in the node record
+	if ln := srvr.LocalNode(); ln != nil {
+		s.startEthEntryUpdate(ln)
+	}
 	// Start the RPC service
 	s.netRPCService = ethapi.NewPublicNetAPI(srvr, s.NetVersion())
 
@@ -504,9 +534,32 @@ func (s *Ethereum) Start(srvr *p2p.Server) error {
 	if s.lesServer != nil {
 		s.lesServer.Start(srvr)
 	}
+	// Archive nodes can serve state of any block and tracing nodes re-execute
+	// transactions on request, advertise them to indexers
+	if srvr.DiscV5 != nil {
+		genesis := s.blockchain.Genesis().Hash()
+		if s.config.NoPruning {
+			go srvr.DiscV5.RegisterTopic(archiveTopic(genesis), s.topicQuit)
+		}
+		if s.tracing {
+			go srvr.DiscV5.RegisterTopic(tracingTopic(genesis), s.topicQuit)
+		}
+	}
 	return nil
 }
 
+// archiveTopic returns the discovery topic advertised by archive nodes of the
+// network with the given genesis block.
+func archiveTopic(genesisHash common.Hash) discover.Topic {
+	return discover.Topic("archive@" + common.Bytes2Hex(genesisHash.Bytes()[0:8]))
+}
+
+// tracingTopic returns the discovery topic advertised by the nodes of the network
+// with the given genesis block which serve the tracing API to remote clients.
+func tracingTopic(genesisHash common.Hash) discover.Topic {
+	return discover.Topic("tracing@" + common.Bytes2Hex(genesisHash.Bytes()[0:8]))
+}
+
 // Stop implements node.Service, terminating all internal goroutines used by the
 // ATH protocol.
 func (s *Ethereum) Stop() error {
@@ -517,6 +570,7 @@ func (s *Ethereum) Stop() error {
 	if s.lesServer != nil {
 		s.lesServer.Stop()
 	}
+	close(s.topicQuit)
 	s.txPool.Stop()
 	s.miner.Stop()
 	s.eventMux.Stop()
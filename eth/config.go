@@ -30,6 +30,7 @@ import (
 	"github.com/athofficial/go-ath/core"
 	"github.com/athofficial/go-ath/eth/downloader"
 	"github.com/athofficial/go-ath/eth/gasprice"
+	"github.com/athofficial/go-ath/miner"
 	"github.com/athofficial/go-ath/params"
 )
 
@@ -54,6 +55,8 @@ var DefaultConfig = Config{
 	MinerGasPrice:  big.NewInt(params.GWei),
 	MinerRecommit:  3 * time.Second,
 
+	MinerTxOrdering: miner.LocalsFirstOrdering,
+
 	TxPool: core.DefaultTxPoolConfig,
 	GPO: gasprice.Config{
 		Blocks:     20,
@@ -94,6 +97,9 @@ type Config struct {
 	LightServ  int `toml:",omitempty"` // Maximum percentage of time allowed for serving LES requests
 	LightPeers int `toml:",omitempty"` // Maximum number of LES client peers
 
+	// Ultra light client options
+	ULC *ULCConfig `toml:",omitempty"`
+
 	// Database options
 	SkipBcVersionCheck bool `toml:"-"`
 	DatabaseHandles    int  `toml:"-"`
@@ -102,6 +108,10 @@ type Config struct {
 	TrieDirtyCache     int
 	TrieTimeout        time.Duration
 
+	// Log index options
+	LogIndex          bool   `toml:",omitempty"` // Maintain an exact address and topic index of the logs
+	LogIndexRetention uint64 `toml:",omitempty"` // Number of recent blocks to keep indexed (0 = all)
+
 	// Mining-related options
 	Etherbase      common.Address `toml:",omitempty"`
 	MinerNotify    []string       `toml:",omitempty"`
@@ -112,6 +122,10 @@ type Config struct {
 	MinerRecommit  time.Duration
 	MinerNoverify  bool
 
+	// Transaction ordering policy of the miner and addresses it never includes
+	MinerTxOrdering  string
+	MinerTxBlacklist []common.Address `toml:",omitempty"`
+
 	// Ubqhash options
 	Ubqhash ubqhash.Config
 
@@ -133,6 +147,9 @@ type Config struct {
 	// Type of the EVM interpreter ("" for default)
 	EVMInterpreter string
 
+	// Pre-analyse contract code into basic blocks to speed up EVM execution
+	EVMBlockAnalysis bool
+
 	// Constantinople block override (TODO: remove after the fork)
 	ConstantinopleOverride *big.Int
 
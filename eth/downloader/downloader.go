@@ -29,6 +29,7 @@ import (
 	"github.com/athofficial/go-ath/common"
 	"github.com/athofficial/go-ath/core/rawdb"
 	"github.com/athofficial/go-ath/core/types"
+	"github.com/athofficial/go-ath/eth/snap"
 	"github.com/athofficial/go-ath/ethdb"
 	"github.com/athofficial/go-ath/event"
 	"github.com/athofficial/go-ath/log"
@@ -105,6 +106,8 @@ type Downloader struct {
 	peers   *peerSet // Set of active peers from which download can proceed
 	stateDB ethdb.Database
 
+	SnapSyncer *snap.Syncer // Range based state retriever used during snap sync
+
 	rttEstimate   uint64 // Round trip time to target for download requests
 	rttConfidence uint64 // Confidence in the estimated RTT (unit: millionths to allow atomic ops)
 
@@ -214,6 +217,7 @@ func New(mode SyncMode, stateDb ethdb.Database, mux *event.TypeMux, chain BlockC
 	dl := &Downloader{
 		mode:           mode,
 		stateDB:        stateDb,
+		SnapSyncer:     snap.NewSyncer(stateDb),
 		mux:            mux,
 		queue:          newQueue(),
 		peers:          newPeerSet(),
@@ -257,7 +261,7 @@ func (d *Downloader) Progress() ethereum.SyncProgress {
 	switch d.mode {
 	case FullSync:
 		current = d.blockchain.CurrentBlock().NumberU64()
-	case FastSync:
+	case FastSync, SnapSync:
 		current = d.blockchain.CurrentFastBlock().NumberU64()
 	case LightSync:
 		current = d.lightchain.CurrentHeader().Number.Uint64()
@@ -448,7 +452,7 @@ func (d *Downloader) syncWithPeer(p *peerConnection, hash common.Hash, td *big.I
 
 	// Ensure our origin point is below any fast sync pivot point
 	pivot := uint64(0)
-	if d.mode == FastSync {
+	if d.mode.isFast() {
 		if height <= uint64(fsMinFullBlocks) {
 			origin = 0
 		} else {
@@ -459,7 +463,7 @@ func (d *Downloader) syncWithPeer(p *peerConnection, hash common.Hash, td *big.I
 		}
 	}
 	d.committed = 1
-	if d.mode == FastSync && pivot != 0 {
+	if d.mode.isFast() && pivot != 0 {
 		d.committed = 0
 	}
 	// Initiate the sync using a concurrent header and content retrieval algorithm
@@ -474,7 +478,7 @@ func (d *Downloader) syncWithPeer(p *peerConnection, hash common.Hash, td *big.I
 		func() error { return d.fetchReceipts(origin + 1) },        // Receipts are retrieved during fast sync
 		func() error { return d.processHeaders(origin+1, pivot, td) },
 	}
-	if d.mode == FastSync {
+	if d.mode.isFast() {
 		fetchers = append(fetchers, func() error { return d.processFastSyncContent(latest) })
 	} else if d.mode == FullSync {
 		fetchers = append(fetchers, d.processFullSyncContent)
@@ -659,7 +663,7 @@ func (d *Downloader) findAncestor(p *peerConnection, remoteHeader *types.Header)
 	switch d.mode {
 	case FullSync:
 		localHeight = d.blockchain.CurrentBlock().NumberU64()
-	case FastSync:
+	case FastSync, SnapSync:
 		localHeight = d.blockchain.CurrentFastBlock().NumberU64()
 	default:
 		localHeight = d.lightchain.CurrentHeader().Number.Uint64()
@@ -740,7 +744,7 @@ func (d *Downloader) findAncestor(p *peerConnection, remoteHeader *types.Header)
 				switch d.mode {
 				case FullSync:
 					known = d.blockchain.HasBlock(h, n)
-				case FastSync:
+				case FastSync, SnapSync:
 					known = d.blockchain.HasFastBlock(h, n)
 				default:
 					known = d.lightchain.HasHeader(h, n)
@@ -813,7 +817,7 @@ func (d *Downloader) findAncestor(p *peerConnection, remoteHeader *types.Header)
 				switch d.mode {
 				case FullSync:
 					known = d.blockchain.HasBlock(h, n)
-				case FastSync:
+				case FastSync, SnapSync:
 					known = d.blockchain.HasFastBlock(h, n)
 				default:
 					known = d.lightchain.HasHeader(h, n)
@@ -1358,7 +1362,7 @@ func (d *Downloader) processHeaders(origin uint64, pivot uint64, td *big.Int) er
 				// This check cannot be executed "as is" for full imports, since blocks may still be
 				// queued for processing when the header download completes. However, as long as the
 				// peer gave us something useful, we're already happy/progressed (above check).
-				if d.mode == FastSync || d.mode == LightSync {
+				if d.mode.isFast() || d.mode == LightSync {
 					head := d.lightchain.CurrentHeader()
 					if td.Cmp(d.lightchain.GetTd(head.Hash(), head.Number.Uint64())) > 0 {
 						return errStallingPeer
@@ -1386,7 +1390,7 @@ func (d *Downloader) processHeaders(origin uint64, pivot uint64, td *big.Int) er
 				chunk := headers[:limit]
 
 				// In case of header only syncing, validate the chunk immediately
-				if d.mode == FastSync || d.mode == LightSync {
+				if d.mode.isFast() || d.mode == LightSync {
 					// Collect the yet unknown headers to mark them as uncertain
 					unknown := make([]*types.Header, 0, len(headers))
 					for _, header := range chunk {
@@ -1414,7 +1418,7 @@ func (d *Downloader) processHeaders(origin uint64, pivot uint64, td *big.Int) er
 					}
 				}
 				// Unless we're doing light chains, schedule the headers for associated content retrieval
-				if d.mode == FullSync || d.mode == FastSync {
+				if d.mode == FullSync || d.mode.isFast() {
 					// If we've reached the allowed number of pending headers, stall a bit
 					for d.queue.PendingBlocks() >= maxQueuedHeaders || d.queue.PendingReceipts() >= maxQueuedHeaders {
 						select {
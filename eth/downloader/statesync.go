@@ -25,6 +25,7 @@ import (
 	"github.com/athofficial/go-ath/common"
 	"github.com/athofficial/go-ath/core/rawdb"
 	"github.com/athofficial/go-ath/core/state"
+	"github.com/athofficial/go-ath/eth/snap"
 	"github.com/athofficial/go-ath/ethdb"
 	"github.com/athofficial/go-ath/log"
 	"github.com/athofficial/go-ath/trie"
@@ -214,6 +215,9 @@ func (d *Downloader) runStateSync(s *stateSync) *stateSync {
 type stateSync struct {
 	d *Downloader // Downloader instance to access and manage current peerset
 
+	root common.Hash // State root currently being synced
+	snap bool        // Whether to retrieve the state in ranges before healing it
+
 	sched  *trie.Sync                 // State trie sync scheduler defining the tasks
 	keccak hash.Hash                  // Keccak256 hasher to verify deliveries with
 	tasks  map[common.Hash]*stateTask // Set of tasks currently queued for retrieval
@@ -239,6 +243,8 @@ type stateTask struct {
 func newStateSync(d *Downloader, root common.Hash) *stateSync {
 	return &stateSync{
 		d:       d,
+		root:    root,
+		snap:    d.mode == SnapSync,
 		sched:   state.NewStateSync(root, d.stateDB),
 		keccak:  sha3.NewLegacyKeccak256(),
 		tasks:   make(map[common.Hash]*stateTask),
@@ -252,6 +258,18 @@ func newStateSync(d *Downloader, root common.Hash) *stateSync {
 // it finishes, and finally notifying any goroutines waiting for the loop to
 // finish.
 func (s *stateSync) run() {
+	// If snap syncing, retrieve the bulk of the state in ranges first, leaving
+	// only the gaps to be healed by the node-by-node sync.
+	if s.snap {
+		if err := s.d.SnapSyncer.Sync(s.root, s.cancel); err != nil {
+			if err == snap.ErrCancelled {
+				s.err = errCancelStateFetch
+				close(s.done)
+				return
+			}
+			log.Warn("Snap sync failed, falling back to trie healing", "root", s.root, "err", err)
+		}
+	}
 	s.err = s.loop()
 	close(s.done)
 }
@@ -64,6 +64,10 @@ type chainHeightFn func() uint64
 // chainInsertFn is a callback type to insert a batch of blocks into the local chain.
 type chainInsertFn func(types.Blocks) (int, error)
 
+// sideHeaderFn is a callback type to report a valid header not extending the
+// local chain, which may be included as an uncle.
+type sideHeaderFn func(header *types.Header)
+
 // peerDropFn is a callback type for dropping a peer detected as malicious.
 type peerDropFn func(id string)
 
@@ -135,6 +139,7 @@ type Fetcher struct {
 	broadcastBlock blockBroadcasterFn // Broadcasts a block to connected peers
 	chainHeight    chainHeightFn      // Retrieves the current chain's height
 	insertChain    chainInsertFn      // Injects a batch of blocks into the chain
+	sideHeader     sideHeaderFn       // Reports valid side chain headers as possible uncles
 	dropPeer       peerDropFn         // Drops a peer for misbehaving
 
 	// Testing hooks
@@ -146,7 +151,7 @@ type Fetcher struct {
 }
 
 // New creates a block fetcher to retrieve blocks based on hash announcements.
-func New(getBlock blockRetrievalFn, verifyHeader headerVerifierFn, broadcastBlock blockBroadcasterFn, chainHeight chainHeightFn, insertChain chainInsertFn, dropPeer peerDropFn) *Fetcher {
+func New(getBlock blockRetrievalFn, verifyHeader headerVerifierFn, broadcastBlock blockBroadcasterFn, chainHeight chainHeightFn, insertChain chainInsertFn, sideHeader sideHeaderFn, dropPeer peerDropFn) *Fetcher {
 	return &Fetcher{
 		notify:         make(chan *announce),
 		inject:         make(chan *inject),
@@ -168,6 +173,7 @@ func New(getBlock blockRetrievalFn, verifyHeader headerVerifierFn, broadcastBloc
 		broadcastBlock: broadcastBlock,
 		chainHeight:    chainHeight,
 		insertChain:    insertChain,
+		sideHeader:     sideHeader,
 		dropPeer:       dropPeer,
 	}
 }
@@ -656,6 +662,13 @@ func (f *Fetcher) insert(peer string, block *types.Block) {
 			propBroadcastOutTimer.UpdateSince(block.ReceivedAt)
 			go f.broadcastBlock(block, true)
 
+			// Blocks not extending the chain are side blocks, report them as
+			// uncles even if their body turns out to be unimportable
+			if f.sideHeader != nil && block.NumberU64() <= f.chainHeight() {
+				propSideHeaderMeter.Mark(1)
+				f.sideHeader(block.Header())
+			}
+
 		case consensus.ErrFutureBlock:
 			// Weird future block, don't fail, but neither propagate
 
@@ -0,0 +1,422 @@
+// Copyright 2019 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package fetcher
+
+import (
+	"time"
+
+	"github.com/athofficial/go-ath/common"
+	"github.com/athofficial/go-ath/common/mclock"
+	"github.com/athofficial/go-ath/core/types"
+	"github.com/athofficial/go-ath/log"
+)
+
+const (
+	// MaxTransactionFetch is the maximum number of transactions to request from,
+	// or to serve to, a peer in a single message.
+	MaxTransactionFetch = 256
+
+	txArriveTimeout = 500 * time.Millisecond // Time allowance before an announced transaction is explicitly requested
+	txFetchTimeout  = 5 * time.Second        // Maximum allotted time to return an explicitly requested transaction
+	txAnnounceLimit = 4096                   // Maximum number of unique transactions a peer may have announced
+)
+
+// txRetrievalFn is a callback type for checking whether a transaction is
+// already known locally.
+type txRetrievalFn func(common.Hash) bool
+
+// txAdderFn is a callback type for delivering a batch of transactions received
+// from a peer to the transaction pool.
+type txAdderFn func(string, []*types.Transaction) []error
+
+// txRequesterFn is a callback type for sending a transaction retrieval request.
+type txRequesterFn func(string, []common.Hash) error
+
+// txAnnounce is the hash notification of the availability of a batch of new
+// transactions in the network.
+type txAnnounce struct {
+	origin string        // Identifier of the peer originating the notification
+	hashes []common.Hash // Hashes of the transactions being announced
+}
+
+// txDelivery is the notification that a batch of transactions have been added
+// to the pool and should be forgotten by the fetcher.
+type txDelivery struct {
+	origin string        // Identifier of the peer originating the delivery
+	hashes []common.Hash // Hashes of the transactions being delivered
+	direct bool          // Whether this is a reply to a request or a broadcast
+}
+
+// txRequest is an in-flight transaction retrieval request to a single peer.
+type txRequest struct {
+	hashes []common.Hash  // Transactions requested from the peer
+	time   mclock.AbsTime // Timestamp of the request
+}
+
+// TxFetcher is responsible for retrieving new transactions based on the hash
+// announcements of eth/65 peers. Announced transactions are given some time to
+// arrive through a direct broadcast first, after which they are requested from
+// one of the announcers at a time. Peers failing to deliver in time are dropped
+// and their announcements are retried from the alternate sources.
+type TxFetcher struct {
+	// Various event channels
+	notify  chan *txAnnounce
+	cleanup chan *txDelivery
+	drop    chan string
+	quit    chan struct{}
+
+	// Announce states
+	waitlist  map[common.Hash]map[string]struct{} // Transactions waiting for a direct broadcast, with their announcers
+	waittime  map[common.Hash]mclock.AbsTime      // Timestamps of the first announcements of the waiting transactions
+	announced map[common.Hash]map[string]struct{} // Transactions scheduled for retrieval, with their announcers
+	announces map[string]map[common.Hash]struct{} // Per peer announced transactions to prevent memory exhaustion
+	fetching  map[common.Hash]string              // Transactions being retrieved, with the peer requested from
+	requests  map[string]*txRequest               // In-flight retrieval requests, at most one per peer
+
+	// Callbacks
+	hasTx    txRetrievalFn // Checks whether a transaction is already in the pool
+	addTxs   txAdderFn     // Injects a batch of transactions into the pool
+	fetchTxs txRequesterFn // Requests a batch of transactions from a peer
+	dropPeer peerDropFn    // Drops a peer for misbehaving
+
+	clock mclock.Clock // Time source, replaceable for testing
+
+	// Testing hooks
+	step chan struct{} // Notification channel signalled after each processed event
+}
+
+// NewTxFetcher creates a transaction fetcher to retrieve transactions based on
+// hash announcements.
+func NewTxFetcher(hasTx txRetrievalFn, addTxs txAdderFn, fetchTxs txRequesterFn, dropPeer peerDropFn) *TxFetcher {
+	return &TxFetcher{
+		notify:    make(chan *txAnnounce),
+		cleanup:   make(chan *txDelivery),
+		drop:      make(chan string),
+		quit:      make(chan struct{}),
+		waitlist:  make(map[common.Hash]map[string]struct{}),
+		waittime:  make(map[common.Hash]mclock.AbsTime),
+		announced: make(map[common.Hash]map[string]struct{}),
+		announces: make(map[string]map[common.Hash]struct{}),
+		fetching:  make(map[common.Hash]string),
+		requests:  make(map[string]*txRequest),
+		hasTx:     hasTx,
+		addTxs:    addTxs,
+		fetchTxs:  fetchTxs,
+		dropPeer:  dropPeer,
+		clock:     mclock.System{},
+	}
+}
+
+// Start boots up the announcement based transaction retriever.
+func (f *TxFetcher) Start() {
+	go f.loop()
+}
+
+// Stop terminates the announcement based transaction retriever, canceling all
+// pending operations.
+func (f *TxFetcher) Stop() {
+	close(f.quit)
+}
+
+// Notify announces the fetcher of the potential availability of a batch of new
+// transactions in the network.
+func (f *TxFetcher) Notify(peer string, hashes []common.Hash) error {
+	txAnnounceInMeter.Mark(int64(len(hashes)))
+
+	// Skip the transactions already in the pool, no need to involve the loop
+	unknown := make([]common.Hash, 0, len(hashes))
+	for _, hash := range hashes {
+		if f.hasTx(hash) {
+			txAnnounceKnownMeter.Mark(1)
+			continue
+		}
+		unknown = append(unknown, hash)
+	}
+	if len(unknown) == 0 {
+		return nil
+	}
+	select {
+	case f.notify <- &txAnnounce{origin: peer, hashes: unknown}:
+		return nil
+	case <-f.quit:
+		return errTerminated
+	}
+}
+
+// Enqueue delivers a batch of transactions received from a peer to the pool and
+// marks them done in the fetcher. Direct deliveries are replies to retrieval
+// requests, the missing transactions of which won't be requested from the peer
+// again. The pool's verdict on each transaction is returned.
+func (f *TxFetcher) Enqueue(peer string, txs []*types.Transaction, direct bool) []error {
+	if direct {
+		txReplyInMeter.Mark(int64(len(txs)))
+	} else {
+		txBroadcastInMeter.Mark(int64(len(txs)))
+	}
+	errs := f.addTxs(peer, txs)
+
+	hashes := make([]common.Hash, len(txs))
+	for i, tx := range txs {
+		hashes[i] = tx.Hash()
+	}
+	select {
+	case f.cleanup <- &txDelivery{origin: peer, hashes: hashes, direct: direct}:
+	case <-f.quit:
+	}
+	return errs
+}
+
+// Drop removes all traces of a disconnected peer from the fetcher, retrying its
+// pending retrievals from alternate announcers.
+func (f *TxFetcher) Drop(peer string) {
+	select {
+	case f.drop <- peer:
+	case <-f.quit:
+	}
+}
+
+// loop is the main fetcher loop, checking and processing various notification
+// events.
+func (f *TxFetcher) loop() {
+	var (
+		timer   <-chan time.Time // Timer of the next announcement or request expiry
+		timerAt mclock.AbsTime   // Expiry time the timer was scheduled for
+	)
+	for {
+		select {
+		case <-f.quit:
+			return
+
+		case ann := <-f.notify:
+			// Transactions were announced, make sure the peer isn't DOSing us
+			announces := f.announces[ann.origin]
+			if announces == nil {
+				announces = make(map[common.Hash]struct{})
+				f.announces[ann.origin] = announces
+			}
+			now := f.clock.Now()
+			for _, hash := range ann.hashes {
+				if _, ok := announces[hash]; ok {
+					continue
+				}
+				if len(announces) >= txAnnounceLimit {
+					log.Debug("Peer exceeded outstanding transaction announces", "peer", ann.origin, "limit", txAnnounceLimit)
+					txAnnounceDOSMeter.Mark(1)
+					break
+				}
+				announces[hash] = struct{}{}
+
+				// Add the peer as an alternate source if the transaction is already
+				// scheduled, otherwise wait for it to arrive by itself
+				if sources, ok := f.announced[hash]; ok {
+					sources[ann.origin] = struct{}{}
+					continue
+				}
+				if sources, ok := f.waitlist[hash]; ok {
+					sources[ann.origin] = struct{}{}
+					continue
+				}
+				f.waitlist[hash] = map[string]struct{}{ann.origin: {}}
+				f.waittime[hash] = now
+			}
+			if len(announces) == 0 {
+				delete(f.announces, ann.origin)
+			}
+
+		case delivery := <-f.cleanup:
+			// Transactions were added to the pool, forget about them
+			for _, hash := range delivery.hashes {
+				f.forgetHash(hash)
+			}
+			// If the peer replied to a request, it doesn't have the missing ones
+			if req := f.requests[delivery.origin]; delivery.direct && req != nil {
+				for _, hash := range req.hashes {
+					if f.fetching[hash] == delivery.origin {
+						delete(f.fetching, hash)
+						f.forgetAnnounce(delivery.origin, hash)
+					}
+				}
+				delete(f.requests, delivery.origin)
+			}
+			f.scheduleFetches()
+
+		case peer := <-f.drop:
+			f.forgetPeer(peer)
+			f.scheduleFetches()
+
+		case <-timer:
+			timer = nil
+			now := f.clock.Now()
+
+			// Schedule the transactions which didn't arrive in time for retrieval
+			for hash, sources := range f.waitlist {
+				if time.Duration(now-f.waittime[hash]) < txArriveTimeout {
+					continue
+				}
+				f.announced[hash] = sources
+				delete(f.waitlist, hash)
+				delete(f.waittime, hash)
+			}
+			// Drop the peers which didn't deliver the requested transactions in time
+			for peer, req := range f.requests {
+				if time.Duration(now-req.time) < txFetchTimeout {
+					continue
+				}
+				log.Debug("Transaction retrieval timed out", "peer", peer, "count", len(req.hashes))
+				txRequestTimeoutMeter.Mark(int64(len(req.hashes)))
+
+				f.forgetPeer(peer)
+				go f.dropPeer(peer)
+			}
+			f.scheduleFetches()
+		}
+		// Schedule the timer for the next expiry if it's earlier than the current one
+		if next, ok := f.nextExpiry(); ok && (timer == nil || next < timerAt) {
+			wait := time.Duration(next - f.clock.Now())
+			if wait < 0 {
+				wait = 0
+			}
+			timer, timerAt = f.clock.After(wait), next
+		}
+		if f.step != nil {
+			f.step <- struct{}{}
+		}
+	}
+}
+
+// scheduleFetches requests the transactions which are due for retrieval from
+// their idle announcers, at most one batch per peer.
+func (f *TxFetcher) scheduleFetches() {
+	now := f.clock.Now()
+	for peer, announces := range f.announces {
+		if _, busy := f.requests[peer]; busy {
+			continue
+		}
+		var hashes []common.Hash
+		for hash := range announces {
+			if _, ok := f.announced[hash]; !ok {
+				continue // still waiting for a broadcast
+			}
+			if _, ok := f.fetching[hash]; ok {
+				continue // already requested from somebody else
+			}
+			if f.hasTx(hash) {
+				f.forgetHash(hash)
+				continue
+			}
+			hashes = append(hashes, hash)
+			if len(hashes) == MaxTransactionFetch {
+				break
+			}
+		}
+		if len(hashes) == 0 {
+			continue
+		}
+		f.requests[peer] = &txRequest{hashes: hashes, time: now}
+		for _, hash := range hashes {
+			f.fetching[hash] = peer
+		}
+		txRequestOutMeter.Mark(int64(len(hashes)))
+
+		// Create a closure of the fetch and schedule in on a new thread
+		peer, hashes := peer, hashes
+		go func() {
+			if err := f.fetchTxs(peer, hashes); err != nil {
+				log.Debug("Failed to request transactions", "peer", peer, "err", err)
+			}
+		}()
+	}
+}
+
+// nextExpiry returns the earliest time at which a waiting announcement or an
+// in-flight request expires.
+func (f *TxFetcher) nextExpiry() (mclock.AbsTime, bool) {
+	var (
+		next  mclock.AbsTime
+		found bool
+	)
+	for _, t := range f.waittime {
+		if t = t.Add(txArriveTimeout); !found || t < next {
+			next, found = t, true
+		}
+	}
+	for _, req := range f.requests {
+		if t := req.time.Add(txFetchTimeout); !found || t < next {
+			next, found = t, true
+		}
+	}
+	return next, found
+}
+
+// forgetHash removes all traces of a transaction from the fetcher.
+func (f *TxFetcher) forgetHash(hash common.Hash) {
+	for peer := range f.waitlist[hash] {
+		f.forgetPeerAnnounce(peer, hash)
+	}
+	for peer := range f.announced[hash] {
+		f.forgetPeerAnnounce(peer, hash)
+	}
+	delete(f.waitlist, hash)
+	delete(f.waittime, hash)
+	delete(f.announced, hash)
+	delete(f.fetching, hash)
+}
+
+// forgetAnnounce removes a peer as a source of a transaction, dropping the
+// transaction altogether if no other source remains.
+func (f *TxFetcher) forgetAnnounce(peer string, hash common.Hash) {
+	f.forgetPeerAnnounce(peer, hash)
+
+	if sources, ok := f.waitlist[hash]; ok {
+		if delete(sources, peer); len(sources) == 0 {
+			delete(f.waitlist, hash)
+			delete(f.waittime, hash)
+		}
+	}
+	if sources, ok := f.announced[hash]; ok {
+		if delete(sources, peer); len(sources) == 0 {
+			delete(f.announced, hash)
+			delete(f.fetching, hash)
+		}
+	}
+}
+
+// forgetPeerAnnounce removes a transaction from the announces of a peer.
+func (f *TxFetcher) forgetPeerAnnounce(peer string, hash common.Hash) {
+	if announces := f.announces[peer]; announces != nil {
+		if delete(announces, hash); len(announces) == 0 {
+			delete(f.announces, peer)
+		}
+	}
+}
+
+// forgetPeer removes all traces of a peer from the fetcher, releasing its
+// in-flight retrievals to be rescheduled from the alternate sources.
+func (f *TxFetcher) forgetPeer(peer string) {
+	if req := f.requests[peer]; req != nil {
+		for _, hash := range req.hashes {
+			if f.fetching[hash] == peer {
+				delete(f.fetching, hash)
+			}
+		}
+		delete(f.requests, peer)
+	}
+	for hash := range f.announces[peer] {
+		f.forgetAnnounce(peer, hash)
+	}
+	delete(f.announces, peer)
+}
@@ -0,0 +1,275 @@
+// Copyright 2019 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package fetcher
+
+import (
+	"math/big"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/athofficial/go-ath/common"
+	"github.com/athofficial/go-ath/common/mclock"
+	"github.com/athofficial/go-ath/core/types"
+)
+
+// txFetch is a transaction retrieval request issued by the fetcher.
+type txFetch struct {
+	peer   string
+	hashes []common.Hash
+}
+
+// txFetcherTester is a test simulator for mocking out the transaction pool and
+// the network.
+type txFetcherTester struct {
+	fetcher *TxFetcher
+	clock   *mclock.Simulated
+
+	pool    map[common.Hash]*types.Transaction // Transactions added to the pool
+	fetches chan txFetch                       // Retrieval requests sent to the peers
+	drops   chan string                        // Peers dropped by the fetcher
+	lock    sync.RWMutex
+}
+
+// newTxTester creates a new transaction fetcher test mocker.
+func newTxTester() *txFetcherTester {
+	tester := &txFetcherTester{
+		clock:   new(mclock.Simulated),
+		pool:    make(map[common.Hash]*types.Transaction),
+		fetches: make(chan txFetch, 16),
+		drops:   make(chan string, 16),
+	}
+	tester.fetcher = NewTxFetcher(tester.hasTx, tester.addTxs, tester.fetchTxs, tester.dropPeer)
+	tester.fetcher.clock = tester.clock
+	tester.fetcher.step = make(chan struct{})
+	tester.fetcher.Start()
+	return tester
+}
+
+func (t *txFetcherTester) hasTx(hash common.Hash) bool {
+	t.lock.RLock()
+	defer t.lock.RUnlock()
+
+	return t.pool[hash] != nil
+}
+
+func (t *txFetcherTester) addTxs(peer string, txs []*types.Transaction) []error {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	for _, tx := range txs {
+		t.pool[tx.Hash()] = tx
+	}
+	return make([]error, len(txs))
+}
+
+func (t *txFetcherTester) fetchTxs(peer string, hashes []common.Hash) error {
+	t.fetches <- txFetch{peer, hashes}
+	return nil
+}
+
+func (t *txFetcherTester) dropPeer(peer string) {
+	t.drops <- peer
+}
+
+// notify announces the transactions and waits for the fetcher to process them.
+func (t *txFetcherTester) notify(peer string, txs ...*types.Transaction) {
+	hashes := make([]common.Hash, len(txs))
+	for i, tx := range txs {
+		hashes[i] = tx.Hash()
+	}
+	t.fetcher.Notify(peer, hashes)
+	<-t.fetcher.step
+}
+
+// enqueue delivers the transactions and waits for the fetcher to process them.
+func (t *txFetcherTester) enqueue(peer string, direct bool, txs ...*types.Transaction) {
+	t.fetcher.Enqueue(peer, txs, direct)
+	<-t.fetcher.step
+}
+
+// expire runs the clock past the given timeout and waits for the fetcher to
+// process the expiry.
+func (t *txFetcherTester) expire(d time.Duration) {
+	t.clock.Run(d)
+	<-t.fetcher.step
+}
+
+// expectFetch checks that a retrieval request for exactly the given transactions
+// was issued and returns the peer it was sent to.
+func (t *txFetcherTester) expectFetch(test *testing.T, txs ...*types.Transaction) string {
+	select {
+	case fetch := <-t.fetches:
+		if len(fetch.hashes) != len(txs) {
+			test.Fatalf("fetched hash count mismatch: have %d, want %d", len(fetch.hashes), len(txs))
+		}
+		want := make(map[common.Hash]bool)
+		for _, tx := range txs {
+			want[tx.Hash()] = true
+		}
+		for _, hash := range fetch.hashes {
+			if !want[hash] {
+				test.Fatalf("unexpected fetch of %x", hash)
+			}
+		}
+		return fetch.peer
+	case <-time.After(time.Second):
+		test.Fatalf("transactions not fetched")
+	}
+	return ""
+}
+
+// expectNoFetch checks that no retrieval request was issued.
+func (t *txFetcherTester) expectNoFetch(test *testing.T) {
+	select {
+	case fetch := <-t.fetches:
+		test.Fatalf("unexpected fetch from %s: %x", fetch.peer, fetch.hashes)
+	case <-time.After(10 * time.Millisecond):
+	}
+}
+
+// expectEmpty checks that the fetcher doesn't track any transaction anymore.
+func (t *txFetcherTester) expectEmpty(test *testing.T) {
+	f := t.fetcher
+	if len(f.waitlist) != 0 || len(f.waittime) != 0 || len(f.announced) != 0 || len(f.announces) != 0 || len(f.fetching) != 0 || len(f.requests) != 0 {
+		test.Fatalf("fetcher not empty: waitlist %d, waittime %d, announced %d, announces %d, fetching %d, requests %d",
+			len(f.waitlist), len(f.waittime), len(f.announced), len(f.announces), len(f.fetching), len(f.requests))
+	}
+}
+
+func newTestTx(nonce uint64) *types.Transaction {
+	return types.NewTransaction(nonce, common.Address{}, big.NewInt(0), 21000, big.NewInt(1), nil)
+}
+
+// Tests that announced transactions arriving through a broadcast in time are
+// not retrieved explicitly.
+func TestTxFetcherBroadcastBeforeTimeout(t *testing.T) {
+	tester := newTxTester()
+	defer tester.fetcher.Stop()
+
+	tx := newTestTx(0)
+	tester.notify("A", tx)
+	tester.enqueue("B", false, tx)
+	tester.expectEmpty(t)
+
+	tester.expire(txArriveTimeout)
+	tester.expectNoFetch(t)
+}
+
+// Tests that announced transactions are retrieved once from a single announcer
+// after the arrival timeout.
+func TestTxFetcherFetch(t *testing.T) {
+	tester := newTxTester()
+	defer tester.fetcher.Stop()
+
+	txs := []*types.Transaction{newTestTx(0), newTestTx(1)}
+	tester.notify("A", txs...)
+	tester.notify("B", txs...)
+	tester.expire(txArriveTimeout)
+
+	peer := tester.expectFetch(t, txs...)
+	tester.expectNoFetch(t)
+
+	tester.enqueue(peer, true, txs...)
+	tester.expectEmpty(t)
+}
+
+// Tests that peers not replying in time are dropped and their transactions are
+// requested from the alternate announcers.
+func TestTxFetcherTimeout(t *testing.T) {
+	tester := newTxTester()
+	defer tester.fetcher.Stop()
+
+	tx := newTestTx(0)
+	tester.notify("A", tx)
+	tester.notify("B", tx)
+	tester.expire(txArriveTimeout)
+	first := tester.expectFetch(t, tx)
+
+	tester.expire(txFetchTimeout)
+	if peer := <-tester.drops; peer != first {
+		t.Fatalf("wrong peer dropped: have %s, want %s", peer, first)
+	}
+	second := tester.expectFetch(t, tx)
+	if second == first {
+		t.Fatalf("transaction refetched from the timed out peer")
+	}
+	tester.enqueue(second, true, tx)
+	tester.expectEmpty(t)
+}
+
+// Tests that transactions missing from a reply are not requested again from the
+// same peer, but from the alternate announcers if any.
+func TestTxFetcherMissingReply(t *testing.T) {
+	tester := newTxTester()
+	defer tester.fetcher.Stop()
+
+	tx := newTestTx(0)
+	tester.notify("A", tx)
+	tester.notify("B", tx)
+	tester.expire(txArriveTimeout)
+	first := tester.expectFetch(t, tx)
+
+	tester.enqueue(first, true)
+	second := tester.expectFetch(t, tx)
+	if second == first {
+		t.Fatalf("transaction refetched from the same peer")
+	}
+	tester.enqueue(second, true)
+	tester.expectNoFetch(t)
+	tester.expectEmpty(t)
+}
+
+// Tests that dropped peers are removed from the fetcher and their in-flight
+// retrievals rescheduled.
+func TestTxFetcherDrop(t *testing.T) {
+	tester := newTxTester()
+	defer tester.fetcher.Stop()
+
+	tx := newTestTx(0)
+	tester.notify("A", tx)
+	tester.notify("B", tx)
+	tester.expire(txArriveTimeout)
+	first := tester.expectFetch(t, tx)
+
+	tester.fetcher.Drop(first)
+	<-tester.fetcher.step
+	second := tester.expectFetch(t, tx)
+
+	tester.fetcher.Drop(second)
+	<-tester.fetcher.step
+	tester.expectEmpty(t)
+}
+
+// Tests that a peer cannot make the fetcher track an unbounded number of
+// announced transactions.
+func TestTxFetcherAnnounceLimit(t *testing.T) {
+	tester := newTxTester()
+	defer tester.fetcher.Stop()
+
+	txs := make([]*types.Transaction, txAnnounceLimit+10)
+	for i := range txs {
+		txs[i] = newTestTx(uint64(i))
+	}
+	tester.notify("A", txs...)
+	if have := len(tester.fetcher.announces["A"]); have != txAnnounceLimit {
+		t.Fatalf("announce count mismatch: have %d, want %d", have, txAnnounceLimit)
+	}
+	if have := len(tester.fetcher.waitlist); have != txAnnounceLimit {
+		t.Fatalf("waitlist size mismatch: have %d, want %d", have, txAnnounceLimit)
+	}
+}
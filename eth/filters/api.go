@@ -28,9 +28,11 @@ import (
 	ethereum "github.com/athofficial/go-ath"
 	"github.com/athofficial/go-ath/common"
 	"github.com/athofficial/go-ath/common/hexutil"
+	"github.com/athofficial/go-ath/core"
 	"github.com/athofficial/go-ath/core/types"
 	"github.com/athofficial/go-ath/ethdb"
 	"github.com/athofficial/go-ath/event"
+	"github.com/athofficial/go-ath/internal/ethapi"
 	"github.com/athofficial/go-ath/rpc"
 )
 
@@ -104,7 +106,7 @@ func (api *PublicFilterAPI) timeoutLoop() {
 // https://github.com/ubiq/wiki/wiki/JSON-RPC#eth_newpendingtransactionfilter
 func (api *PublicFilterAPI) NewPendingTransactionFilter() rpc.ID {
 	var (
-		pendingTxs   = make(chan []common.Hash)
+		pendingTxs   = make(chan []*types.Transaction)
 		pendingTxSub = api.events.SubscribePendingTxs(pendingTxs)
 	)
 
@@ -115,10 +117,12 @@ func (api *PublicFilterAPI) NewPendingTransactionFilter() rpc.ID {
 	go func() {
 		for {
 			select {
-			case ph := <-pendingTxs:
+			case txs := <-pendingTxs:
 				api.filtersMu.Lock()
 				if f, found := api.filters[pendingTxSub.ID]; found {
-					f.hashes = append(f.hashes, ph...)
+					for _, tx := range txs {
+						f.hashes = append(f.hashes, tx.Hash())
+					}
 				}
 				api.filtersMu.Unlock()
 			case <-pendingTxSub.Err():
@@ -135,7 +139,8 @@ func (api *PublicFilterAPI) NewPendingTransactionFilter() rpc.ID {
 
 // NewPendingTransactions creates a subscription that is triggered each time a transaction
 // enters the transaction pool and was signed from one of the transactions this nodes manages.
-func (api *PublicFilterAPI) NewPendingTransactions(ctx context.Context) (*rpc.Subscription, error) {
+// If fullTx is set, the whole transactions are sent instead of their hashes.
+func (api *PublicFilterAPI) NewPendingTransactions(ctx context.Context, fullTx *bool) (*rpc.Subscription, error) {
 	notifier, supported := rpc.NotifierFromContext(ctx)
 	if !supported {
 		return &rpc.Subscription{}, rpc.ErrNotificationsUnsupported
@@ -144,16 +149,20 @@ func (api *PublicFilterAPI) NewPendingTransactions(ctx context.Context) (*rpc.Su
 	rpcSub := notifier.CreateSubscription()
 
 	go func() {
-		txHashes := make(chan []common.Hash, 128)
-		pendingTxSub := api.events.SubscribePendingTxs(txHashes)
+		pendingTxs := make(chan []*types.Transaction, 128)
+		pendingTxSub := api.events.SubscribePendingTxs(pendingTxs)
 
 		for {
 			select {
-			case hashes := <-txHashes:
-				// To keep the original behaviour, send a single tx hash in one notification.
+			case txs := <-pendingTxs:
+				// To keep the original behaviour, send a single tx in one notification.
 				// TODO(rjl493456442) Send a batch of tx hashes in one notification
-				for _, h := range hashes {
-					notifier.Notify(rpcSub.ID, h)
+				for _, tx := range txs {
+					if fullTx != nil && *fullTx {
+						notifier.Notify(rpcSub.ID, ethapi.NewRPCPendingTransaction(tx))
+					} else {
+						notifier.Notify(rpcSub.ID, tx.Hash())
+					}
 				}
 			case <-rpcSub.Err():
 				pendingTxSub.Unsubscribe()
@@ -233,6 +242,53 @@ func (api *PublicFilterAPI) NewHeads(ctx context.Context) (*rpc.Subscription, er
 	return rpcSub, nil
 }
 
+// chainReorg is the notification sent by the chainReorg subscription.
+type chainReorg struct {
+	OldHead        *types.Header `json:"oldHead"`
+	NewHead        *types.Header `json:"newHead"`
+	CommonAncestor *types.Header `json:"commonAncestor"`
+	Dropped        []common.Hash `json:"dropped"`
+	Added          []common.Hash `json:"added"`
+}
+
+// ChainReorg sends a notification each time a reorg replaces blocks of the
+// canonical chain, with the old and new heads, their common ancestor and the
+// hashes of the dropped and added blocks in ascending order.
+func (api *PublicFilterAPI) ChainReorg(ctx context.Context) (*rpc.Subscription, error) {
+	notifier, supported := rpc.NotifierFromContext(ctx)
+	if !supported {
+		return &rpc.Subscription{}, rpc.ErrNotificationsUnsupported
+	}
+
+	rpcSub := notifier.CreateSubscription()
+
+	go func() {
+		reorgs := make(chan core.ChainReorgEvent)
+		reorgsSub := api.events.SubscribeChainReorgs(reorgs)
+
+		for {
+			select {
+			case ev := <-reorgs:
+				notifier.Notify(rpcSub.ID, &chainReorg{
+					OldHead:        ev.OldHead,
+					NewHead:        ev.NewHead,
+					CommonAncestor: ev.Common,
+					Dropped:        ev.Dropped,
+					Added:          ev.Added,
+				})
+			case <-rpcSub.Err():
+				reorgsSub.Unsubscribe()
+				return
+			case <-notifier.Closed():
+				reorgsSub.Unsubscribe()
+				return
+			}
+		}
+	}()
+
+	return rpcSub, nil
+}
+
 // Logs creates a subscription that fires for all new log that match the given filter criteria.
 func (api *PublicFilterAPI) Logs(ctx context.Context, crit FilterCriteria) (*rpc.Subscription, error) {
 	notifier, supported := rpc.NotifierFromContext(ctx)
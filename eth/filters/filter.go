@@ -24,6 +24,8 @@ import (
 	"github.com/athofficial/go-ath/common"
 	"github.com/athofficial/go-ath/core"
 	"github.com/athofficial/go-ath/core/bloombits"
+	"github.com/athofficial/go-ath/core/logindex"
+	"github.com/athofficial/go-ath/core/rawdb"
 	"github.com/athofficial/go-ath/core/types"
 	"github.com/athofficial/go-ath/ethdb"
 	"github.com/athofficial/go-ath/event"
@@ -42,8 +44,10 @@ type Backend interface {
 	SubscribeChainEvent(ch chan<- core.ChainEvent) event.Subscription
 	SubscribeRemovedLogsEvent(ch chan<- core.RemovedLogsEvent) event.Subscription
 	SubscribeLogsEvent(ch chan<- []*types.Log) event.Subscription
+	SubscribeChainReorgEvent(ch chan<- core.ChainReorgEvent) event.Subscription
 
 	BloomStatus() (uint64, uint64)
+	LogIndexStatus() (uint64, uint64, uint64)
 	ServiceFilter(ctx context.Context, session *bloombits.MatcherSession)
 }
 
@@ -142,18 +146,24 @@ func (f *Filter) Logs(ctx context.Context) ([]*types.Log, error) {
 	if f.end == -1 {
 		end = head
 	}
-	// Gather all indexed logs, and finish with non indexed ones
-	var (
-		logs []*types.Log
-		err  error
-	)
+	// Gather all exactly indexed logs, then bloom indexed ones, and finish with
+	// non indexed ones
+	logs, err := f.logIndexLogs(ctx, end)
+	if err != nil {
+		return logs, err
+	}
+	if uint64(f.begin) > end {
+		return logs, nil
+	}
 	size, sections := f.backend.BloomStatus()
 	if indexed := sections * size; indexed > uint64(f.begin) {
+		var found []*types.Log
 		if indexed > end {
-			logs, err = f.indexedLogs(ctx, end)
+			found, err = f.indexedLogs(ctx, end)
 		} else {
-			logs, err = f.indexedLogs(ctx, indexed-1)
+			found, err = f.indexedLogs(ctx, indexed-1)
 		}
+		logs = append(logs, found...)
 		if err != nil {
 			return logs, err
 		}
@@ -163,6 +173,59 @@ func (f *Filter) Logs(ctx context.Context) ([]*types.Log, error) {
 	return logs, err
 }
 
+// logIndexLogs returns the logs matching the filter criteria based on the exact
+// log index, advancing the start of the filter past the sections served. It stops
+// at the first section the index cannot serve, leaving it to the bloom bits.
+func (f *Filter) logIndexLogs(ctx context.Context, end uint64) ([]*types.Log, error) {
+	size, _, sections := f.backend.LogIndexStatus()
+
+	var logs []*types.Log
+	for section := uint64(f.begin) / size; section < sections && section*size <= end; section++ {
+		positions, ok := logindex.Lookup(f.db, size, section, f.addresses, f.topics)
+		if !ok {
+			break
+		}
+		// Group the matching positions within the filter range by block
+		var (
+			blocks   []uint64
+			byNumber = make(map[uint64][]rawdb.LogPosition)
+		)
+		for _, pos := range positions {
+			if pos.Number < uint64(f.begin) || pos.Number > end {
+				continue
+			}
+			if _, ok := byNumber[pos.Number]; !ok {
+				blocks = append(blocks, pos.Number)
+			}
+			byNumber[pos.Number] = append(byNumber[pos.Number], pos)
+		}
+		// Retrieve the logs of the matching blocks
+		for _, number := range blocks {
+			if err := ctx.Err(); err != nil {
+				return logs, err
+			}
+			hash := rawdb.ReadCanonicalHash(f.db, number)
+			logsList, err := f.backend.GetLogs(ctx, hash)
+			if err != nil {
+				return logs, err
+			}
+			var found []*types.Log
+			for _, pos := range byNumber[number] {
+				if pos.TxIndex < uint(len(logsList)) && pos.LogIndex < uint(len(logsList[pos.TxIndex])) {
+					found = append(found, logsList[pos.TxIndex][pos.LogIndex])
+				}
+			}
+			logs = append(logs, filterLogs(found, nil, nil, f.addresses, f.topics)...)
+		}
+		if next := (section + 1) * size; next <= end {
+			f.begin = int64(next)
+		} else {
+			f.begin = int64(end) + 1
+		}
+	}
+	return logs, nil
+}
+
 // indexedLogs returns the logs matching the filter criteria based on the bloom
 // bits indexed available locally or via the network.
 func (f *Filter) indexedLogs(ctx context.Context, end uint64) ([]*types.Log, error) {
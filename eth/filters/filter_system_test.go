@@ -32,8 +32,11 @@ import (
 	"github.com/athofficial/go-ath/core/bloombits"
 	"github.com/athofficial/go-ath/core/rawdb"
 	"github.com/athofficial/go-ath/core/types"
+	"github.com/athofficial/go-ath/core/vm"
+	"github.com/athofficial/go-ath/crypto"
 	"github.com/athofficial/go-ath/ethdb"
 	"github.com/athofficial/go-ath/event"
+	"github.com/athofficial/go-ath/internal/ethapi"
 	"github.com/athofficial/go-ath/params"
 	"github.com/athofficial/go-ath/rpc"
 )
@@ -120,10 +123,18 @@ func (b *testBackend) SubscribeChainEvent(ch chan<- core.ChainEvent) event.Subsc
 	return b.chainFeed.Subscribe(ch)
 }
 
+func (b *testBackend) SubscribeChainReorgEvent(ch chan<- core.ChainReorgEvent) event.Subscription {
+	return new(event.Feed).Subscribe(ch)
+}
+
 func (b *testBackend) BloomStatus() (uint64, uint64) {
 	return params.BloomBitsBlocks, b.sections
 }
 
+func (b *testBackend) LogIndexStatus() (uint64, uint64, uint64) {
+	return params.BloomBitsBlocks, 0, 0
+}
+
 func (b *testBackend) ServiceFilter(ctx context.Context, session *bloombits.MatcherSession) {
 	requests := make(chan chan *bloombits.Retrieval)
 
@@ -273,6 +284,151 @@ func TestPendingTxFilter(t *testing.T) {
 	}
 }
 
+// TestPendingTxSubscription tests whether pending transaction subscriptions send
+// the hashes or the whole transactions entering the pool.
+func TestPendingTxSubscription(t *testing.T) {
+	t.Parallel()
+
+	var (
+		txFeed  = new(event.Feed)
+		backend = &testBackend{new(event.TypeMux), ethdb.NewMemDatabase(), 0, txFeed, new(event.Feed), new(event.Feed), new(event.Feed)}
+		server  = rpc.NewServer()
+		signer  = types.HomesteadSigner{}
+		key, _  = crypto.GenerateKey()
+
+		transactions = make([]*types.Transaction, 3)
+	)
+	for i := range transactions {
+		tx := types.NewTransaction(uint64(i), common.HexToAddress("0xb794f5ea0ba39494ce83a213fffba74279579268"), big.NewInt(1), 21000, big.NewInt(1), nil)
+		transactions[i], _ = types.SignTx(tx, signer, key)
+	}
+	server.RegisterName("eth", NewPublicFilterAPI(backend, false))
+	client := rpc.DialInProc(server)
+	defer client.Close()
+
+	hashes := make(chan common.Hash)
+	hashSub, err := client.EthSubscribe(context.Background(), hashes, "newPendingTransactions")
+	if err != nil {
+		t.Fatalf("failed to subscribe to hashes: %v", err)
+	}
+	defer hashSub.Unsubscribe()
+
+	txs := make(chan *ethapi.RPCTransaction)
+	txSub, err := client.EthSubscribe(context.Background(), txs, "newPendingTransactions", true)
+	if err != nil {
+		t.Fatalf("failed to subscribe to transactions: %v", err)
+	}
+	defer txSub.Unsubscribe()
+
+	txFeed.Send(core.NewTxsEvent{Txs: transactions})
+
+	for i, want := range transactions {
+		select {
+		case hash := <-hashes:
+			if hash != want.Hash() {
+				t.Errorf("hash %d mismatch: have %x, want %x", i, hash, want.Hash())
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("hash %d not received", i)
+		}
+		select {
+		case tx := <-txs:
+			if tx.Hash != want.Hash() || uint64(tx.Nonce) != want.Nonce() || tx.From != crypto.PubkeyToAddress(key.PublicKey) {
+				t.Errorf("transaction %d mismatch: have %+v, want %x", i, tx, want.Hash())
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("transaction %d not received", i)
+		}
+	}
+}
+
+// reorgBackend is a test backend announcing the reorgs of a real chain.
+type reorgBackend struct {
+	*testBackend
+	chain *core.BlockChain
+}
+
+func (b *reorgBackend) SubscribeChainReorgEvent(ch chan<- core.ChainReorgEvent) event.Subscription {
+	return b.chain.SubscribeChainReorgEvent(ch)
+}
+
+// TestChainReorgSubscription tests whether the reorgs of the canonical chain are
+// announced with the dropped and added blocks.
+func TestChainReorgSubscription(t *testing.T) {
+	t.Parallel()
+
+	var (
+		db      = ethdb.NewMemDatabase()
+		gspec   = &core.Genesis{Config: params.TestChainConfig}
+		genesis = gspec.MustCommit(db)
+		forker  = common.Address{1}
+	)
+	// Blocks of the old chain win difficulty ties, so only the longer fork reorgs.
+	chain, err := core.NewBlockChain(db, nil, gspec.Config, ubqhash.NewFaker(), vm.Config{}, func(block *types.Block) bool {
+		return block.Coinbase() != forker
+	})
+	if err != nil {
+		t.Fatalf("failed to create chain: %v", err)
+	}
+	defer chain.Stop()
+
+	blocks, _ := core.GenerateChain(gspec.Config, genesis, ubqhash.NewFaker(), ethdb.NewMemDatabase(), 3, func(i int, gen *core.BlockGen) {})
+	forks, _ := core.GenerateChain(gspec.Config, genesis, ubqhash.NewFaker(), ethdb.NewMemDatabase(), 4, func(i int, gen *core.BlockGen) {
+		gen.SetCoinbase(forker)
+	})
+	if _, err := chain.InsertChain(blocks); err != nil {
+		t.Fatalf("failed to insert chain: %v", err)
+	}
+	backend := &reorgBackend{
+		testBackend: &testBackend{new(event.TypeMux), db, 0, new(event.Feed), new(event.Feed), new(event.Feed), new(event.Feed)},
+		chain:       chain,
+	}
+	server := rpc.NewServer()
+	server.RegisterName("eth", NewPublicFilterAPI(backend, false))
+	client := rpc.DialInProc(server)
+	defer client.Close()
+
+	reorgs := make(chan *chainReorg)
+	sub, err := client.EthSubscribe(context.Background(), reorgs, "chainReorg")
+	if err != nil {
+		t.Fatalf("failed to subscribe: %v", err)
+	}
+	defer sub.Unsubscribe()
+
+	if _, err := chain.InsertChain(forks); err != nil {
+		t.Fatalf("failed to insert fork: %v", err)
+	}
+	select {
+	case reorg := <-reorgs:
+		if reorg.OldHead.Hash() != blocks[2].Hash() || reorg.NewHead.Hash() != forks[3].Hash() {
+			t.Errorf("head mismatch: have %x -> %x, want %x -> %x", reorg.OldHead.Hash(), reorg.NewHead.Hash(), blocks[2].Hash(), forks[3].Hash())
+		}
+		if reorg.CommonAncestor.Hash() != genesis.Hash() {
+			t.Errorf("common ancestor mismatch: have %x, want %x", reorg.CommonAncestor.Hash(), genesis.Hash())
+		}
+		var dropped, added []common.Hash
+		for _, block := range blocks {
+			dropped = append(dropped, block.Hash())
+		}
+		for _, block := range forks {
+			added = append(added, block.Hash())
+		}
+		if !reflect.DeepEqual(reorg.Dropped, dropped) {
+			t.Errorf("dropped blocks mismatch: have %x, want %x", reorg.Dropped, dropped)
+		}
+		if !reflect.DeepEqual(reorg.Added, added) {
+			t.Errorf("added blocks mismatch: have %x, want %x", reorg.Added, added)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("reorg not announced")
+	}
+	select {
+	case reorg := <-reorgs:
+		t.Errorf("unexpected reorg: %+v", reorg)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
 // TestLogFilterCreation test whether a given filter criteria makes sense.
 // If not it must return an error.
 func TestLogFilterCreation(t *testing.T) {
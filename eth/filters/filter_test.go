@@ -21,11 +21,14 @@ import (
 	"io/ioutil"
 	"math/big"
 	"os"
+	"reflect"
 	"testing"
+	"time"
 
 	"github.com/athofficial/go-ath/common"
 	"github.com/athofficial/go-ath/consensus/ubqhash"
 	"github.com/athofficial/go-ath/core"
+	"github.com/athofficial/go-ath/core/logindex"
 	"github.com/athofficial/go-ath/core/rawdb"
 	"github.com/athofficial/go-ath/core/types"
 	"github.com/athofficial/go-ath/crypto"
@@ -230,3 +233,98 @@ func TestFilters(t *testing.T) {
 		t.Error("expected 0 log, got", len(logs))
 	}
 }
+
+// logIndexBackend is a test backend serving log filters from an exact log index.
+type logIndexBackend struct {
+	*testBackend
+	indexer *core.ChainIndexer
+}
+
+func (b *logIndexBackend) LogIndexStatus() (uint64, uint64, uint64) {
+	sections, _, _ := b.indexer.Sections()
+	return 4, rawdb.ReadLogIndexTail(b.db), sections
+}
+
+// logIndexChain is a static chain the log indexer is run against.
+type logIndexChain struct {
+	head *types.Header
+	feed event.Feed
+}
+
+func (c *logIndexChain) CurrentHeader() *types.Header { return c.head }
+
+func (c *logIndexChain) SubscribeChainHeadEvent(ch chan<- core.ChainHeadEvent) event.Subscription {
+	return c.feed.Subscribe(ch)
+}
+
+// Tests that range filters served from the log index match those served by
+// scanning the blocks, including ranges not aligned to the index sections.
+func TestLogIndexFilters(t *testing.T) {
+	var (
+		db      = ethdb.NewMemDatabase()
+		plain   = &testBackend{new(event.TypeMux), db, 0, new(event.Feed), new(event.Feed), new(event.Feed), new(event.Feed)}
+		key1, _ = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+		addr    = crypto.PubkeyToAddress(key1.PublicKey)
+
+		hash1 = common.BytesToHash([]byte("topic1"))
+		hash2 = common.BytesToHash([]byte("topic2"))
+	)
+	genesis := core.GenesisBlockForTesting(db, addr, big.NewInt(1000000))
+	chain, receipts := core.GenerateChain(params.TestChainConfig, genesis, ubqhash.NewFaker(), db, 20, func(i int, gen *core.BlockGen) {
+		if i%3 == 0 {
+			receipt := types.NewReceipt(nil, false, 0)
+			receipt.Logs = []*types.Log{{Address: addr, Topics: []common.Hash{hash1}}}
+			if i%2 == 0 {
+				receipt.Logs = append(receipt.Logs, &types.Log{Address: addr, Topics: []common.Hash{hash2}})
+			}
+			gen.AddUncheckedReceipt(receipt)
+		}
+	})
+	for i, block := range chain {
+		rawdb.WriteBlock(db, block)
+		rawdb.WriteCanonicalHash(db, block.Hash(), block.NumberU64())
+		rawdb.WriteHeadBlockHash(db, block.Hash())
+		rawdb.WriteHeadHeaderHash(db, block.Hash())
+		rawdb.WriteReceipts(db, block.Hash(), block.NumberU64(), receipts[i])
+	}
+	// Index the chain and wait for all sections to be processed
+	indexer := logindex.NewIndexer(db, 4, 0)
+	defer indexer.Close()
+
+	indexer.Start(&logIndexChain{head: chain[len(chain)-1].Header()})
+	for start := time.Now(); ; time.Sleep(10 * time.Millisecond) {
+		if sections, _, _ := indexer.Sections(); sections == 5 {
+			break
+		}
+		if time.Since(start) > 5*time.Second {
+			t.Fatalf("log index not generated in time")
+		}
+	}
+	indexed := &logIndexBackend{plain, indexer}
+
+	tests := []struct {
+		begin, end int64
+		addresses  []common.Address
+		topics     [][]common.Hash
+	}{
+		{0, -1, []common.Address{addr}, nil},
+		{0, -1, nil, [][]common.Hash{{hash2}}},
+		{2, 13, []common.Address{addr}, [][]common.Hash{{hash1, hash2}}},
+		{5, 5, nil, [][]common.Hash{{hash1}}},
+		{9, 17, nil, [][]common.Hash{{hash2}}},
+		{0, -1, []common.Address{{0xff}}, nil},
+	}
+	for i, tt := range tests {
+		want, err := NewRangeFilter(plain, tt.begin, tt.end, tt.addresses, tt.topics).Logs(context.Background())
+		if err != nil {
+			t.Fatalf("test %d: failed to scan logs: %v", i, err)
+		}
+		have, err := NewRangeFilter(indexed, tt.begin, tt.end, tt.addresses, tt.topics).Logs(context.Background())
+		if err != nil {
+			t.Fatalf("test %d: failed to filter indexed logs: %v", i, err)
+		}
+		if !reflect.DeepEqual(have, want) {
+			t.Errorf("test %d: logs mismatch: have %v, want %v", i, have, want)
+		}
+	}
+}
@@ -18,6 +18,7 @@ package gasprice
 
 import (
 	"context"
+	"fmt"
 	"math/big"
 	"sort"
 	"sync"
@@ -31,10 +32,23 @@ import (
 
 var maxPrice = big.NewInt(500 * params.GWei)
 
+const (
+	// fullnessTarget is the average gas used ratio of recent blocks below which
+	// they are considered to have room for any transaction paying the minimum
+	// price. The suggested percentile is scaled down proportionally below it.
+	fullnessTarget = 0.5
+
+	// maxHistoryFetchers is the number of blocks retrieved concurrently when
+	// assembling a fee history.
+	maxHistoryFetchers = 4
+)
+
 type Config struct {
 	Blocks     int
 	Percentile int
 	Default    *big.Int `toml:",omitempty"`
+	MinPrice   *big.Int `toml:",omitempty"` // Lowest price accepted by the local transaction pool
+	MaxHistory int      `toml:",omitempty"` // Maximum number of blocks served in a fee history
 }
 
 // Oracle recommends gas prices based on the content of recent
@@ -48,6 +62,8 @@ type Oracle struct {
 
 	checkBlocks, maxEmpty, maxBlocks int
 	percentile                       int
+	minPrice                         *big.Int
+	maxHistory                       int
 }
 
 // NewOracle returns a new oracle.
@@ -63,6 +79,10 @@ func NewOracle(backend ethapi.Backend, params Config) *Oracle {
 	if percent > 100 {
 		percent = 100
 	}
+	history := params.MaxHistory
+	if history < 1 {
+		history = 1024
+	}
 	return &Oracle{
 		backend:     backend,
 		lastPrice:   params.Default,
@@ -70,10 +90,15 @@ func NewOracle(backend ethapi.Backend, params Config) *Oracle {
 		maxEmpty:    blocks / 2,
 		maxBlocks:   blocks * 5,
 		percentile:  percent,
+		minPrice:    params.MinPrice,
+		maxHistory:  history,
 	}
 }
 
-// SuggestPrice returns the recommended gas price.
+// SuggestPrice returns the recommended gas price. It is the configured percentile
+// of the lowest prices included in recent blocks, lowered if those blocks were
+// mostly empty and raised if the pending pool holds more than fits in the next
+// block, but never below the minimum price of the local pool.
 func (gpo *Oracle) SuggestPrice(ctx context.Context) (*big.Int, error) {
 	gpo.cacheLock.RLock()
 	lastHead := gpo.lastHead
@@ -102,7 +127,11 @@ func (gpo *Oracle) SuggestPrice(ctx context.Context) (*big.Int, error) {
 	ch := make(chan getBlockPricesResult, gpo.checkBlocks)
 	sent := 0
 	exp := 0
-	var blockPrices []*big.Int
+	var (
+		blockPrices []*big.Int
+		gasUsed     float64
+		checked     int
+	)
 	for sent < gpo.checkBlocks && blockNum > 0 {
 		go gpo.getBlockPrices(ctx, types.MakeSigner(gpo.backend.ChainConfig(), big.NewInt(int64(blockNum))), blockNum, ch)
 		sent++
@@ -116,6 +145,9 @@ func (gpo *Oracle) SuggestPrice(ctx context.Context) (*big.Int, error) {
 			return lastPrice, res.err
 		}
 		exp--
+		gasUsed += res.ratio
+		checked++
+
 		if res.price != nil {
 			blockPrices = append(blockPrices, res.price)
 			continue
@@ -134,7 +166,18 @@ func (gpo *Oracle) SuggestPrice(ctx context.Context) (*big.Int, error) {
 	price := lastPrice
 	if len(blockPrices) > 0 {
 		sort.Sort(bigIntArray(blockPrices))
-		price = blockPrices[(len(blockPrices)-1)*gpo.percentile/100]
+
+		percentile := gpo.percentile
+		if fullness := gasUsed / float64(checked); fullness < fullnessTarget {
+			percentile = int(float64(percentile) * fullness / fullnessTarget)
+		}
+		price = blockPrices[(len(blockPrices)-1)*percentile/100]
+	}
+	if pending := gpo.pendingPrice(head.GasLimit); pending != nil && pending.Cmp(price) > 0 {
+		price = pending
+	}
+	if gpo.minPrice != nil && price.Cmp(gpo.minPrice) < 0 {
+		price = new(big.Int).Set(gpo.minPrice)
 	}
 	if price.Cmp(maxPrice) > 0 {
 		price = new(big.Int).Set(maxPrice)
@@ -147,8 +190,30 @@ func (gpo *Oracle) SuggestPrice(ctx context.Context) (*big.Int, error) {
 	return price, nil
 }
 
+// pendingPrice returns the lowest price among the pending transactions which
+// would fit into the next block if it was assembled from the best paying ones,
+// or nil if all of them fit.
+func (gpo *Oracle) pendingPrice(gasLimit uint64) *big.Int {
+	pending, err := gpo.backend.GetPoolTransactions()
+	if err != nil || len(pending) == 0 {
+		return nil
+	}
+	txs := make([]*types.Transaction, len(pending))
+	copy(txs, pending)
+	sort.Sort(sort.Reverse(transactionsByGasPrice(txs)))
+
+	var gas uint64
+	for _, tx := range txs {
+		if gas += tx.Gas(); gas > gasLimit {
+			return tx.GasPrice()
+		}
+	}
+	return nil
+}
+
 type getBlockPricesResult struct {
 	price *big.Int
+	ratio float64
 	err   error
 }
 
@@ -158,14 +223,16 @@ func (t transactionsByGasPrice) Len() int           { return len(t) }
 func (t transactionsByGasPrice) Swap(i, j int)      { t[i], t[j] = t[j], t[i] }
 func (t transactionsByGasPrice) Less(i, j int) bool { return t[i].GasPrice().Cmp(t[j].GasPrice()) < 0 }
 
-// getBlockPrices calculates the lowest transaction gas price in a given block
-// and sends it to the result channel. If the block is empty, price is nil.
+// getBlockPrices calculates the lowest transaction gas price and the gas used
+// ratio of a given block and sends them to the result channel. If the block is
+// empty, price is nil.
 func (gpo *Oracle) getBlockPrices(ctx context.Context, signer types.Signer, blockNum uint64, ch chan getBlockPricesResult) {
 	block, err := gpo.backend.BlockByNumber(ctx, rpc.BlockNumber(blockNum))
 	if block == nil {
-		ch <- getBlockPricesResult{nil, err}
+		ch <- getBlockPricesResult{nil, 0, err}
 		return
 	}
+	ratio := gasUsedRatio(block.Header())
 
 	blockTxs := block.Transactions()
 	txs := make([]*types.Transaction, len(blockTxs))
@@ -175,11 +242,176 @@ func (gpo *Oracle) getBlockPrices(ctx context.Context, signer types.Signer, bloc
 	for _, tx := range txs {
 		sender, err := types.Sender(signer, tx)
 		if err == nil && sender != block.Coinbase() {
-			ch <- getBlockPricesResult{tx.GasPrice(), nil}
+			ch <- getBlockPricesResult{tx.GasPrice(), ratio, nil}
 			return
 		}
 	}
-	ch <- getBlockPricesResult{nil, nil}
+	ch <- getBlockPricesResult{nil, ratio, nil}
+}
+
+// gasUsedRatio returns the portion of the gas limit of a block used up by its
+// transactions.
+func gasUsedRatio(header *types.Header) float64 {
+	if header.GasLimit == 0 {
+		return 0
+	}
+	return float64(header.GasUsed) / float64(header.GasLimit)
+}
+
+// blockFees is the fee history entry of a single block.
+type blockFees struct {
+	number  uint64
+	ratio   float64
+	rewards []*big.Int
+	err     error
+}
+
+// FeeHistory returns the gas used ratios and the requested gas price percentiles
+// of up to the given number of blocks ending with lastBlock, along with the number
+// of the oldest block returned. The percentiles are weighted by the gas used by
+// the transactions, and are omitted if none are requested.
+func (gpo *Oracle) FeeHistory(ctx context.Context, blocks int, lastBlock rpc.BlockNumber, percentiles []float64) (*big.Int, [][]*big.Int, []float64, error) {
+	for i, p := range percentiles {
+		if p < 0 || p > 100 || (i > 0 && p < percentiles[i-1]) {
+			return nil, nil, nil, fmt.Errorf("invalid reward percentile #%d: %f", i, p)
+		}
+	}
+	if blocks < 1 {
+		return new(big.Int), nil, nil, nil
+	}
+	if blocks > gpo.maxHistory {
+		blocks = gpo.maxHistory
+	}
+	// Resolve the last block of the range, pending is served from the head
+	if lastBlock == rpc.PendingBlockNumber {
+		lastBlock = rpc.LatestBlockNumber
+	}
+	head, err := gpo.backend.HeaderByNumber(ctx, rpc.LatestBlockNumber)
+	if head == nil {
+		return nil, nil, nil, err
+	}
+	last := head.Number.Uint64()
+	if lastBlock != rpc.LatestBlockNumber {
+		if uint64(lastBlock) > last {
+			return nil, nil, nil, fmt.Errorf("block #%d beyond head #%d", lastBlock, last)
+		}
+		last = uint64(lastBlock)
+	}
+	if uint64(blocks) > last+1 {
+		blocks = int(last + 1)
+	}
+	oldest := last + 1 - uint64(blocks)
+
+	// Retrieve the blocks concurrently and gather the results in order
+	var (
+		next    = make(chan uint64, blocks)
+		results = make(chan *blockFees, blocks)
+	)
+	for number := oldest; number <= last; number++ {
+		next <- number
+	}
+	close(next)
+
+	fetchers := maxHistoryFetchers
+	if fetchers > blocks {
+		fetchers = blocks
+	}
+	for i := 0; i < fetchers; i++ {
+		go func() {
+			for number := range next {
+				results <- gpo.blockFees(ctx, number, percentiles)
+			}
+		}()
+	}
+	var (
+		rewards [][]*big.Int
+		ratios  = make([]float64, blocks)
+	)
+	if len(percentiles) > 0 {
+		rewards = make([][]*big.Int, blocks)
+	}
+	for i := 0; i < blocks; i++ {
+		res := <-results
+		if res.err != nil {
+			return nil, nil, nil, res.err
+		}
+		ratios[res.number-oldest] = res.ratio
+		if rewards != nil {
+			rewards[res.number-oldest] = res.rewards
+		}
+	}
+	return new(big.Int).SetUint64(oldest), rewards, ratios, nil
+}
+
+// blockFees retrieves the gas used ratio and the gas price percentiles of a
+// single block. The receipts are only retrieved if percentiles are requested.
+func (gpo *Oracle) blockFees(ctx context.Context, number uint64, percentiles []float64) *blockFees {
+	fees := &blockFees{number: number}
+	if len(percentiles) == 0 {
+		header, err := gpo.backend.HeaderByNumber(ctx, rpc.BlockNumber(number))
+		if header == nil {
+			if err == nil {
+				err = fmt.Errorf("header #%d not found", number)
+			}
+			fees.err = err
+			return fees
+		}
+		fees.ratio = gasUsedRatio(header)
+		return fees
+	}
+	block, err := gpo.backend.BlockByNumber(ctx, rpc.BlockNumber(number))
+	if block == nil {
+		if err == nil {
+			err = fmt.Errorf("block #%d not found", number)
+		}
+		fees.err = err
+		return fees
+	}
+	fees.ratio = gasUsedRatio(block.Header())
+	fees.rewards = make([]*big.Int, len(percentiles))
+
+	txs := block.Transactions()
+	if len(txs) == 0 {
+		for i := range fees.rewards {
+			fees.rewards[i] = new(big.Int)
+		}
+		return fees
+	}
+	receipts, err := gpo.backend.GetReceipts(ctx, block.Hash())
+	if err != nil {
+		fees.err = err
+		return fees
+	}
+	if len(receipts) != len(txs) {
+		fees.err = fmt.Errorf("receipts of block #%d not found", number)
+		return fees
+	}
+	// Sort the transactions by price and walk them, weighted by gas used
+	sorted := make([]txGasAndPrice, len(txs))
+	for i, tx := range txs {
+		sorted[i] = txGasAndPrice{price: tx.GasPrice(), gasUsed: receipts[i].GasUsed}
+	}
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].price.Cmp(sorted[j].price) < 0 })
+
+	var (
+		idx     int
+		sumUsed = sorted[0].gasUsed
+	)
+	for i, p := range percentiles {
+		threshold := uint64(float64(block.GasUsed()) * p / 100)
+		for sumUsed < threshold && idx < len(sorted)-1 {
+			idx++
+			sumUsed += sorted[idx].gasUsed
+		}
+		fees.rewards[i] = sorted[idx].price
+	}
+	return fees
+}
+
+// txGasAndPrice is the gas used and the gas price of a single transaction.
+type txGasAndPrice struct {
+	gasUsed uint64
+	price   *big.Int
 }
 
 type bigIntArray []*big.Int
@@ -0,0 +1,220 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package gasprice
+
+import (
+	"context"
+	"math/big"
+	"testing"
+
+	"github.com/athofficial/go-ath/common"
+	"github.com/athofficial/go-ath/core/types"
+	"github.com/athofficial/go-ath/crypto"
+	"github.com/athofficial/go-ath/internal/ethapi"
+	"github.com/athofficial/go-ath/params"
+	"github.com/athofficial/go-ath/rpc"
+)
+
+var testKey, _ = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+
+// testBackend is a chain of blocks served to the oracle. Only the methods used
+// by the oracle are implemented.
+type testBackend struct {
+	ethapi.Backend
+
+	blocks   []*types.Block
+	receipts map[common.Hash]types.Receipts
+	pending  types.Transactions
+}
+
+// newTestBackend creates a chain of ten blocks, block n holding four transactions
+// paying n to n+3 gwei, each using 21000 gas and together filling the given
+// portion of the block.
+func newTestBackend(t *testing.T, fullness float64) *testBackend {
+	var (
+		signer  = types.NewEIP155Signer(params.TestChainConfig.ChainID)
+		backend = &testBackend{receipts: make(map[common.Hash]types.Receipts)}
+		nonce   uint64
+	)
+	backend.blocks = append(backend.blocks, types.NewBlockWithHeader(&types.Header{Number: new(big.Int)}))
+	for n := int64(1); n <= 10; n++ {
+		var (
+			txs      types.Transactions
+			receipts types.Receipts
+		)
+		for i := int64(0); i < 4; i++ {
+			txs = append(txs, signTx(t, signer, nonce, 21000, big.NewInt((n+i)*params.GWei)))
+			nonce++
+
+			receipt := types.NewReceipt(nil, false, uint64(21000*(i+1)))
+			receipt.GasUsed = 21000
+			receipts = append(receipts, receipt)
+		}
+		header := &types.Header{
+			Number:   big.NewInt(n),
+			GasUsed:  4 * 21000,
+			GasLimit: uint64(4 * 21000 / fullness),
+		}
+		block := types.NewBlock(header, txs, nil, receipts)
+		backend.blocks = append(backend.blocks, block)
+		backend.receipts[block.Hash()] = receipts
+	}
+	return backend
+}
+
+func signTx(t *testing.T, signer types.Signer, nonce uint64, gas uint64, price *big.Int) *types.Transaction {
+	tx, err := types.SignTx(types.NewTransaction(nonce, common.Address{}, new(big.Int), gas, price, nil), signer, testKey)
+	if err != nil {
+		t.Fatalf("failed to sign transaction: %v", err)
+	}
+	return tx
+}
+
+func (b *testBackend) HeaderByNumber(ctx context.Context, number rpc.BlockNumber) (*types.Header, error) {
+	block, _ := b.BlockByNumber(ctx, number)
+	if block == nil {
+		return nil, nil
+	}
+	return block.Header(), nil
+}
+
+func (b *testBackend) BlockByNumber(ctx context.Context, number rpc.BlockNumber) (*types.Block, error) {
+	if number == rpc.LatestBlockNumber {
+		return b.blocks[len(b.blocks)-1], nil
+	}
+	if number < 0 || int(number) >= len(b.blocks) {
+		return nil, nil
+	}
+	return b.blocks[number], nil
+}
+
+func (b *testBackend) GetReceipts(ctx context.Context, hash common.Hash) (types.Receipts, error) {
+	return b.receipts[hash], nil
+}
+
+func (b *testBackend) GetPoolTransactions() (types.Transactions, error) {
+	return b.pending, nil
+}
+
+func (b *testBackend) ChainConfig() *params.ChainConfig {
+	return params.TestChainConfig
+}
+
+// Tests that the suggested price accounts for the fullness of recent blocks, the
+// pending pool and the minimum price of the local pool.
+func TestSuggestPrice(t *testing.T) {
+	gwei := func(n int64) *big.Int { return big.NewInt(n * params.GWei) }
+
+	tests := []struct {
+		fullness float64
+		minPrice *big.Int
+		pending  []int64
+		want     *big.Int
+	}{
+		// Full blocks yield the configured percentile of the lowest prices
+		{1, nil, nil, gwei(6)},
+		// Mostly empty blocks lower the percentile
+		{0.2, nil, nil, gwei(3)},
+		// The minimum price of the local pool is a floor
+		{0.2, gwei(5), nil, gwei(5)},
+		{1, gwei(5), nil, gwei(6)},
+		// Pending transactions fitting into a block don't raise the price
+		{1, nil, []int64{20, 19, 18, 17}, gwei(6)},
+		// Pending transactions overflowing a block must be outbid
+		{1, nil, []int64{20, 19, 18, 17, 16}, gwei(16)},
+	}
+	signer := types.NewEIP155Signer(params.TestChainConfig.ChainID)
+	for i, tt := range tests {
+		backend := newTestBackend(t, tt.fullness)
+		for j, price := range tt.pending {
+			backend.pending = append(backend.pending, signTx(t, signer, uint64(100+j), 21000, gwei(price)))
+		}
+		oracle := NewOracle(backend, Config{Blocks: 10, Percentile: 60, Default: gwei(1), MinPrice: tt.minPrice})
+
+		price, err := oracle.SuggestPrice(context.Background())
+		if err != nil {
+			t.Fatalf("test %d: failed to suggest price: %v", i, err)
+		}
+		if price.Cmp(tt.want) != 0 {
+			t.Errorf("test %d: price mismatch: have %v, want %v", i, price, tt.want)
+		}
+	}
+}
+
+// Tests that fee histories report the gas used ratios and the gas weighted price
+// percentiles of the requested blocks.
+func TestFeeHistory(t *testing.T) {
+	oracle := NewOracle(newTestBackend(t, 0.5), Config{Blocks: 10, Percentile: 60, MaxHistory: 8})
+
+	tests := []struct {
+		blocks      int
+		last        rpc.BlockNumber
+		percentiles []float64
+		oldest      uint64
+		ratios      int
+		fail        bool
+	}{
+		{3, rpc.LatestBlockNumber, []float64{0, 25, 50, 100}, 8, 3, false},
+		{3, 5, nil, 3, 3, false},
+		{20, rpc.LatestBlockNumber, nil, 3, 8, false},  // capped by the maximum history
+		{20, 4, []float64{50}, 0, 5, false},            // capped by the genesis
+		{1, rpc.PendingBlockNumber, nil, 10, 1, false}, // pending is served from the head
+		{0, rpc.LatestBlockNumber, nil, 0, 0, false},
+		{1, 11, nil, 0, 0, true},              // beyond the head
+		{1, 5, []float64{50, 25}, 0, 0, true}, // unordered percentiles
+		{1, 5, []float64{101}, 0, 0, true},    // out of range percentiles
+	}
+	for i, tt := range tests {
+		oldest, rewards, ratios, err := oracle.FeeHistory(context.Background(), tt.blocks, tt.last, tt.percentiles)
+		if tt.fail {
+			if err == nil {
+				t.Errorf("test %d: no error", i)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("test %d: failed to retrieve fee history: %v", i, err)
+		}
+		if oldest.Uint64() != tt.oldest {
+			t.Errorf("test %d: oldest block mismatch: have %d, want %d", i, oldest, tt.oldest)
+		}
+		if len(ratios) != tt.ratios {
+			t.Errorf("test %d: gas used ratio count mismatch: have %d, want %d", i, len(ratios), tt.ratios)
+		}
+		if len(tt.percentiles) == 0 && rewards != nil {
+			t.Errorf("test %d: rewards returned without percentiles", i)
+		}
+		if len(tt.percentiles) > 0 && len(rewards) != tt.ratios {
+			t.Errorf("test %d: reward count mismatch: have %d, want %d", i, len(rewards), tt.ratios)
+		}
+		for j, ratio := range ratios {
+			if number := oldest.Uint64() + uint64(j); number > 0 && ratio != 0.5 {
+				t.Errorf("test %d: block #%d: gas used ratio mismatch: have %f, want 0.5", i, number, ratio)
+			}
+		}
+	}
+	// Check the weighted percentiles of the last three blocks
+	_, rewards, _, _ := oracle.FeeHistory(context.Background(), 3, rpc.LatestBlockNumber, []float64{0, 25, 50, 100})
+	for i, block := range rewards {
+		n := int64(8 + i)
+		for j, want := range []int64{n, n, n + 1, n + 3} {
+			if block[j].Cmp(big.NewInt(want*params.GWei)) != 0 {
+				t.Errorf("block #%d, percentile %d: reward mismatch: have %v, want %d gwei", n, j, block[j], want)
+			}
+		}
+	}
+}
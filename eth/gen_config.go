@@ -23,14 +23,17 @@ func (c Config) MarshalTOML() (interface{}, error) {
 		NetworkId               uint64
 		SyncMode                downloader.SyncMode
 		NoPruning               bool
-		LightServ               int  `toml:",omitempty"`
-		LightPeers              int  `toml:",omitempty"`
-		SkipBcVersionCheck      bool `toml:"-"`
-		DatabaseHandles         int  `toml:"-"`
+		LightServ               int        `toml:",omitempty"`
+		LightPeers              int        `toml:",omitempty"`
+		ULC                     *ULCConfig `toml:",omitempty"`
+		SkipBcVersionCheck      bool       `toml:"-"`
+		DatabaseHandles         int        `toml:"-"`
 		DatabaseCache           int
 		TrieCleanCache          int
 		TrieDirtyCache          int
 		TrieTimeout             time.Duration
+		LogIndex                bool           `toml:",omitempty"`
+		LogIndexRetention       uint64         `toml:",omitempty"`
 		Etherbase               common.Address `toml:",omitempty"`
 		MinerNotify             []string       `toml:",omitempty"`
 		MinerExtraData          hexutil.Bytes  `toml:",omitempty"`
@@ -39,13 +42,16 @@ func (c Config) MarshalTOML() (interface{}, error) {
 		MinerGasPrice           *big.Int
 		MinerRecommit           time.Duration
 		MinerNoverify           bool
-		Ubqhash                  ubqhash.Config
+		MinerTxOrdering         string
+		MinerTxBlacklist        []common.Address `toml:",omitempty"`
+		Ubqhash                 ubqhash.Config
 		TxPool                  core.TxPoolConfig
 		GPO                     gasprice.Config
 		EnablePreimageRecording bool
 		DocRoot                 string `toml:"-"`
 		EWASMInterpreter        string
 		EVMInterpreter          string
+		EVMBlockAnalysis        bool
 	}
 	var enc Config
 	enc.Genesis = c.Genesis
@@ -54,12 +60,15 @@ func (c Config) MarshalTOML() (interface{}, error) {
 	enc.NoPruning = c.NoPruning
 	enc.LightServ = c.LightServ
 	enc.LightPeers = c.LightPeers
+	enc.ULC = c.ULC
 	enc.SkipBcVersionCheck = c.SkipBcVersionCheck
 	enc.DatabaseHandles = c.DatabaseHandles
 	enc.DatabaseCache = c.DatabaseCache
 	enc.TrieCleanCache = c.TrieCleanCache
 	enc.TrieDirtyCache = c.TrieDirtyCache
 	enc.TrieTimeout = c.TrieTimeout
+	enc.LogIndex = c.LogIndex
+	enc.LogIndexRetention = c.LogIndexRetention
 	enc.Etherbase = c.Etherbase
 	enc.MinerNotify = c.MinerNotify
 	enc.MinerExtraData = c.MinerExtraData
@@ -68,6 +77,8 @@ func (c Config) MarshalTOML() (interface{}, error) {
 	enc.MinerGasPrice = c.MinerGasPrice
 	enc.MinerRecommit = c.MinerRecommit
 	enc.MinerNoverify = c.MinerNoverify
+	enc.MinerTxOrdering = c.MinerTxOrdering
+	enc.MinerTxBlacklist = c.MinerTxBlacklist
 	enc.Ubqhash = c.Ubqhash
 	enc.TxPool = c.TxPool
 	enc.GPO = c.GPO
@@ -75,6 +86,7 @@ func (c Config) MarshalTOML() (interface{}, error) {
 	enc.DocRoot = c.DocRoot
 	enc.EWASMInterpreter = c.EWASMInterpreter
 	enc.EVMInterpreter = c.EVMInterpreter
+	enc.EVMBlockAnalysis = c.EVMBlockAnalysis
 	return &enc, nil
 }
 
@@ -85,14 +97,17 @@ func (c *Config) UnmarshalTOML(unmarshal func(interface{}) error) error {
 		NetworkId               *uint64
 		SyncMode                *downloader.SyncMode
 		NoPruning               *bool
-		LightServ               *int  `toml:",omitempty"`
-		LightPeers              *int  `toml:",omitempty"`
-		SkipBcVersionCheck      *bool `toml:"-"`
-		DatabaseHandles         *int  `toml:"-"`
+		LightServ               *int       `toml:",omitempty"`
+		LightPeers              *int       `toml:",omitempty"`
+		ULC                     *ULCConfig `toml:",omitempty"`
+		SkipBcVersionCheck      *bool      `toml:"-"`
+		DatabaseHandles         *int       `toml:"-"`
 		DatabaseCache           *int
 		TrieCleanCache          *int
 		TrieDirtyCache          *int
 		TrieTimeout             *time.Duration
+		LogIndex                *bool           `toml:",omitempty"`
+		LogIndexRetention       *uint64         `toml:",omitempty"`
 		Etherbase               *common.Address `toml:",omitempty"`
 		MinerNotify             []string        `toml:",omitempty"`
 		MinerExtraData          *hexutil.Bytes  `toml:",omitempty"`
@@ -101,13 +116,16 @@ func (c *Config) UnmarshalTOML(unmarshal func(interface{}) error) error {
 		MinerGasPrice           *big.Int
 		MinerRecommit           *time.Duration
 		MinerNoverify           *bool
-		Ubqhash                  *ubqhash.Config
+		MinerTxOrdering         *string
+		MinerTxBlacklist        []common.Address `toml:",omitempty"`
+		Ubqhash                 *ubqhash.Config
 		TxPool                  *core.TxPoolConfig
 		GPO                     *gasprice.Config
 		EnablePreimageRecording *bool
 		DocRoot                 *string `toml:"-"`
 		EWASMInterpreter        *string
 		EVMInterpreter          *string
+		EVMBlockAnalysis        *bool
 	}
 	var dec Config
 	if err := unmarshal(&dec); err != nil {
@@ -131,6 +149,9 @@ func (c *Config) UnmarshalTOML(unmarshal func(interface{}) error) error {
 	if dec.LightPeers != nil {
 		c.LightPeers = *dec.LightPeers
 	}
+	if dec.ULC != nil {
+		c.ULC = dec.ULC
+	}
 	if dec.SkipBcVersionCheck != nil {
 		c.SkipBcVersionCheck = *dec.SkipBcVersionCheck
 	}
@@ -149,6 +170,12 @@ func (c *Config) UnmarshalTOML(unmarshal func(interface{}) error) error {
 	if dec.TrieTimeout != nil {
 		c.TrieTimeout = *dec.TrieTimeout
 	}
+	if dec.LogIndex != nil {
+		c.LogIndex = *dec.LogIndex
+	}
+	if dec.LogIndexRetention != nil {
+		c.LogIndexRetention = *dec.LogIndexRetention
+	}
 	if dec.Etherbase != nil {
 		c.Etherbase = *dec.Etherbase
 	}
@@ -173,6 +200,12 @@ func (c *Config) UnmarshalTOML(unmarshal func(interface{}) error) error {
 	if dec.MinerNoverify != nil {
 		c.MinerNoverify = *dec.MinerNoverify
 	}
+	if dec.MinerTxOrdering != nil {
+		c.MinerTxOrdering = *dec.MinerTxOrdering
+	}
+	if dec.MinerTxBlacklist != nil {
+		c.MinerTxBlacklist = dec.MinerTxBlacklist
+	}
 	if dec.Ubqhash != nil {
 		c.Ubqhash = *dec.Ubqhash
 	}
@@ -194,5 +227,8 @@ func (c *Config) UnmarshalTOML(unmarshal func(interface{}) error) error {
 	if dec.EVMInterpreter != nil {
 		c.EVMInterpreter = *dec.EVMInterpreter
 	}
+	if dec.EVMBlockAnalysis != nil {
+		c.EVMBlockAnalysis = *dec.EVMBlockAnalysis
+	}
 	return nil
 }
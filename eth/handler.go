@@ -29,14 +29,17 @@ import (
 	"github.com/athofficial/go-ath/common"
 	"github.com/athofficial/go-ath/consensus"
 	"github.com/athofficial/go-ath/core"
+	"github.com/athofficial/go-ath/core/forkid"
 	"github.com/athofficial/go-ath/core/types"
 	"github.com/athofficial/go-ath/eth/downloader"
 	"github.com/athofficial/go-ath/eth/fetcher"
+	"github.com/athofficial/go-ath/eth/snap"
 	"github.com/athofficial/go-ath/ethdb"
 	"github.com/athofficial/go-ath/event"
 	"github.com/athofficial/go-ath/log"
 	"github.com/athofficial/go-ath/p2p"
 	"github.com/athofficial/go-ath/p2p/enode"
+	"github.com/athofficial/go-ath/p2p/enr"
 	"github.com/athofficial/go-ath/params"
 	"github.com/athofficial/go-ath/rlp"
 )
@@ -57,14 +60,26 @@ const (
 // not compatible (low protocol version restrictions and high requirements).
 var errIncompatibleConfig = errors.New("incompatible configuration")
 
+// protocolError is a violation of the eth protocol by the remote peer.
+type protocolError struct {
+	code errCode
+	msg  string
+}
+
+func (e *protocolError) Error() string {
+	return fmt.Sprintf("%v - %v", e.code, e.msg)
+}
+
 func errResp(code errCode, format string, v ...interface{}) error {
-	return fmt.Errorf("%v - %v", code, fmt.Sprintf(format, v...))
+	return &protocolError{code, fmt.Sprintf(format, v...)}
 }
 
 type ProtocolManager struct {
-	networkID uint64
+	networkID  uint64
+	forkFilter forkid.Filter // Fork ID filter, constant across the lifetime of the node
 
 	fastSync  uint32 // Flag whether fast sync is enabled (gets disabled if we already have blocks)
+	snapSync  uint32 // Flag whether fast sync should retrieve the state via the snap protocol
 	acceptTxs uint32 // Flag whether we're considered synchronised (enables transaction processing)
 
 	txpool      txPool
@@ -74,6 +89,7 @@ type ProtocolManager struct {
 
 	downloader *downloader.Downloader
 	fetcher    *fetcher.Fetcher
+	txFetcher  *fetcher.TxFetcher
 	peers      *peerSet
 
 	SubProtocols []p2p.Protocol
@@ -102,6 +118,7 @@ func NewProtocolManager(config *params.ChainConfig, mode downloader.SyncMode, ne
 	// Create the protocol manager with the base fields
 	manager := &ProtocolManager{
 		networkID:   networkID,
+		forkFilter:  forkid.NewFilter(blockchain),
 		eventMux:    mux,
 		txpool:      txpool,
 		blockchain:  blockchain,
@@ -114,18 +131,21 @@ func NewProtocolManager(config *params.ChainConfig, mode downloader.SyncMode, ne
 		quitSync:    make(chan struct{}),
 	}
 	// Figure out whether to allow fast sync or not
-	if mode == downloader.FastSync && blockchain.CurrentBlock().NumberU64() > 0 {
+	if (mode == downloader.FastSync || mode == downloader.SnapSync) && blockchain.CurrentBlock().NumberU64() > 0 {
 		log.Warn("Blockchain not empty, fast sync disabled")
 		mode = downloader.FullSync
 	}
-	if mode == downloader.FastSync {
+	if mode == downloader.FastSync || mode == downloader.SnapSync {
 		manager.fastSync = uint32(1)
 	}
+	if mode == downloader.SnapSync {
+		manager.snapSync = uint32(1)
+	}
 	// Initiate a sub-protocol for every implemented version we can handle
 	manager.SubProtocols = make([]p2p.Protocol, 0, len(ProtocolVersions))
 	for i, version := range ProtocolVersions {
 		// Skip protocol version if incompatible with the mode of operation
-		if mode == downloader.FastSync && version < eth63 {
+		if (mode == downloader.FastSync || mode == downloader.SnapSync) && version < eth63 {
 			continue
 		}
 		// Compatible; initialise the sub-protocol
@@ -154,13 +174,17 @@ func NewProtocolManager(config *params.ChainConfig, mode downloader.SyncMode, ne
 				}
 				return nil
 			},
+			Attributes: []enr.Entry{&ethEntry{ForkID: forkid.NewID(blockchain)}},
 		})
 	}
 	if len(manager.SubProtocols) == 0 {
 		return nil, errIncompatibleConfig
 	}
 	// Construct the different synchronisation mechanisms
-	manager.downloader = downloader.New(mode, chaindb, manager.eventMux, blockchain, nil, manager.removePeer)
+	manager.downloader = downloader.New(mode, chaindb, manager.eventMux, blockchain, nil, manager.penalisePeer(p2p.BadResponse))
+
+	// Serve (and consume) state ranges over the snap protocol alongside eth
+	manager.SubProtocols = append(manager.SubProtocols, snap.MakeProtocol(blockchain, manager.downloader.SnapSyncer))
 
 	validator := func(header *types.Header) error {
 		return engine.VerifyHeader(blockchain, header, true)
@@ -177,7 +201,22 @@ func NewProtocolManager(config *params.ChainConfig, mode downloader.SyncMode, ne
 		atomic.StoreUint32(&manager.acceptTxs, 1) // Mark initial sync done on any fetcher import
 		return manager.blockchain.InsertChain(blocks)
 	}
-	manager.fetcher = fetcher.New(blockchain.GetBlockByHash, validator, manager.BroadcastBlock, heighter, inserter, manager.removePeer)
+	sider := func(header *types.Header) {
+		manager.eventMux.Post(core.UncleCandidateEvent{Header: header})
+	}
+	manager.fetcher = fetcher.New(blockchain.GetBlockByHash, validator, manager.BroadcastBlock, heighter, inserter, sider, manager.penalisePeer(p2p.InvalidBlock))
+
+	hasTx := func(hash common.Hash) bool {
+		return txpool.Get(hash) != nil
+	}
+	fetchTxs := func(id string, hashes []common.Hash) error {
+		p := manager.peers.Peer(id)
+		if p == nil {
+			return errNotRegistered
+		}
+		return p.RequestTxs(hashes)
+	}
+	manager.txFetcher = fetcher.NewTxFetcher(hasTx, txpool.AddRemotesFrom, fetchTxs, manager.penalisePeer(p2p.RequestTimeout))
 
 	return manager, nil
 }
@@ -192,6 +231,7 @@ func (pm *ProtocolManager) removePeer(id string) {
 
 	// Unregister the peer from the downloader and Ubiq peer set
 	pm.downloader.UnregisterPeer(id)
+	pm.txFetcher.Drop(id)
 	if err := pm.peers.Unregister(id); err != nil {
 		log.Error("Peer removal failed", "peer", id, "err", err)
 	}
@@ -201,6 +241,17 @@ func (pm *ProtocolManager) removePeer(id string) {
 	}
 }
 
+// penalisePeer returns a callback which reports the given misbehaviour of a peer
+// to the p2p reputation system and disconnects it.
+func (pm *ProtocolManager) penalisePeer(behaviour p2p.Behaviour) func(id string) {
+	return func(id string) {
+		if peer := pm.peers.Peer(id); peer != nil {
+			peer.report(behaviour)
+		}
+		pm.removePeer(id)
+	}
+}
+
 func (pm *ProtocolManager) Start(maxPeers int) {
 	pm.maxPeers = maxPeers
 
@@ -214,6 +265,7 @@ func (pm *ProtocolManager) Start(maxPeers int) {
 	go pm.minedBroadcastLoop()
 
 	// start sync handlers
+	pm.txFetcher.Start()
 	go pm.syncer()
 	go pm.txsyncLoop()
 }
@@ -230,6 +282,7 @@ func (pm *ProtocolManager) Stop() {
 
 	// Quit fetcher, txsyncLoop.
 	close(pm.quitSync)
+	pm.txFetcher.Stop()
 
 	// Disconnect existing sessions.
 	// This also closes the gate for any new registrations on the peer set.
@@ -264,7 +317,7 @@ func (pm *ProtocolManager) handle(p *peer) error {
 		number  = head.Number.Uint64()
 		td      = pm.blockchain.GetTd(hash, number)
 	)
-	if err := p.Handshake(pm.networkID, td, hash, genesis.Hash()); err != nil {
+	if err := p.Handshake(pm.networkID, td, hash, genesis.Hash(), forkid.NewID(pm.blockchain), pm.forkFilter); err != nil {
 		p.Log().Debug("Ethereum handshake failed", "err", err)
 		return err
 	}
@@ -296,6 +349,9 @@ func (pm *ProtocolManager) handle(p *peer) error {
 	for {
 		if err := pm.handleMsg(p); err != nil {
 			p.Log().Debug("Ethereum message handling failed", "err", err)
+			if _, ok := err.(*protocolError); ok {
+				p.report(p2p.ProtocolBreach)
+			}
 			return err
 		}
 	}
@@ -421,6 +477,7 @@ func (pm *ProtocolManager) handleMsg(p *peer) error {
 			if want, ok := pm.whitelist[headers[0].Number.Uint64()]; ok {
 				if hash := headers[0].Hash(); want != hash {
 					p.Log().Info("Whitelist mismatch, dropping peer", "number", headers[0].Number.Uint64(), "hash", hash, "want", want)
+					p.Report(p2p.BadResponse)
 					return errors.New("whitelist block mismatch")
 				}
 				p.Log().Debug("Whitelist block verified", "number", headers[0].Number.Uint64(), "hash", want)
@@ -432,6 +489,8 @@ func (pm *ProtocolManager) handleMsg(p *peer) error {
 			err := pm.downloader.DeliverHeaders(p.id, headers)
 			if err != nil {
 				log.Debug("Failed to deliver headers", "err", err)
+			} else if len(headers) > 0 {
+				p.Report(p2p.GoodResponse)
 			}
 		}
 
@@ -485,6 +544,8 @@ func (pm *ProtocolManager) handleMsg(p *peer) error {
 			err := pm.downloader.DeliverBodies(p.id, transactions, uncles)
 			if err != nil {
 				log.Debug("Failed to deliver bodies", "err", err)
+			} else if len(transactions) > 0 {
+				p.Report(p2p.GoodResponse)
 			}
 		}
 
@@ -524,6 +585,8 @@ func (pm *ProtocolManager) handleMsg(p *peer) error {
 		// Deliver all to the downloader
 		if err := pm.downloader.DeliverNodeData(p.id, data); err != nil {
 			log.Debug("Failed to deliver node state data", "err", err)
+		} else if len(data) > 0 {
+			p.Report(p2p.GoodResponse)
 		}
 
 	case p.version >= eth63 && msg.Code == GetReceiptsMsg:
@@ -571,6 +634,8 @@ func (pm *ProtocolManager) handleMsg(p *peer) error {
 		// Deliver all to the downloader
 		if err := pm.downloader.DeliverReceipts(p.id, receipts); err != nil {
 			log.Debug("Failed to deliver receipts", "err", err)
+		} else if len(receipts) > 0 {
+			p.Report(p2p.GoodResponse)
 		}
 
 	case msg.Code == NewBlockHashesMsg:
@@ -625,7 +690,58 @@ func (pm *ProtocolManager) handleMsg(p *peer) error {
 			}
 		}
 
-	case msg.Code == TxMsg:
+	case p.version >= eth65 && msg.Code == NewPooledTransactionHashesMsg:
+		// New transaction announcement arrived, make sure we have a valid and fresh chain to handle them
+		if atomic.LoadUint32(&pm.acceptTxs) == 0 {
+			break
+		}
+		var hashes []common.Hash
+		if err := msg.Decode(&hashes); err != nil {
+			return errResp(ErrDecode, "msg %v: %v", msg, err)
+		}
+		// Mark the hashes as present at the remote node and schedule the unknown ones
+		for _, hash := range hashes {
+			p.MarkTransaction(hash)
+		}
+		pm.txFetcher.Notify(p.id, hashes)
+
+	case p.version >= eth65 && msg.Code == GetPooledTransactionsMsg:
+		// Decode the retrieval message
+		msgStream := rlp.NewStream(msg.Payload, uint64(msg.Size))
+		if _, err := msgStream.List(); err != nil {
+			return err
+		}
+		// Gather transactions until the fetch or network limits is reached
+		var (
+			hash   common.Hash
+			bytes  int
+			hashes []common.Hash
+			txs    []rlp.RawValue
+		)
+		for bytes < softResponseLimit && len(txs) < fetcher.MaxTransactionFetch {
+			// Retrieve the hash of the next transaction
+			if err := msgStream.Decode(&hash); err == rlp.EOL {
+				break
+			} else if err != nil {
+				return errResp(ErrDecode, "msg %v: %v", msg, err)
+			}
+			// Retrieve the requested transaction, skipping if unknown to us
+			tx := pm.txpool.Get(hash)
+			if tx == nil {
+				continue
+			}
+			// If known, encode and queue for response packet
+			if encoded, err := rlp.EncodeToBytes(tx); err != nil {
+				log.Error("Failed to encode transaction", "err", err)
+			} else {
+				hashes = append(hashes, hash)
+				txs = append(txs, encoded)
+				bytes += len(encoded)
+			}
+		}
+		return p.SendPooledTransactionsRLP(hashes, txs)
+
+	case msg.Code == TxMsg || (p.version >= eth65 && msg.Code == PooledTransactionsMsg):
 		// Transactions arrived, make sure we have a valid and fresh chain to handle them
 		if atomic.LoadUint32(&pm.acceptTxs) == 0 {
 			break
@@ -642,7 +758,14 @@ func (pm *ProtocolManager) handleMsg(p *peer) error {
 			}
 			p.MarkTransaction(tx.Hash())
 		}
-		pm.txpool.AddRemotes(txs)
+		errs := pm.txFetcher.Enqueue(p.id, txs, msg.Code == PooledTransactionsMsg)
+
+		// Rate the delivered transactions, spamming peers get banned eventually
+		for _, err := range errs {
+			if behaviour, ok := txBehaviour(err); ok {
+				p.report(behaviour)
+			}
+		}
 
 	default:
 		return errResp(ErrInvalidMsgCode, "%v", msg.Code)
@@ -691,22 +814,56 @@ func (pm *ProtocolManager) BroadcastBlock(block *types.Block, propagate bool) {
 }
 
 // BroadcastTxs will propagate a batch of transactions to all peers which are not known to
-// already have the given transaction.
+// already have the given transaction. The full transactions are only sent to the square
+// root of the peers, the remaining eth/65 peers get an announcement of the hashes and can
+// retrieve them if needed. Legacy peers always get the full transactions.
 func (pm *ProtocolManager) BroadcastTxs(txs types.Transactions) {
-	var txset = make(map[*peer]types.Transactions)
-
+	var (
+		txset  = make(map[*peer]types.Transactions)
+		annset = make(map[*peer][]common.Hash)
+	)
 	// Broadcast transactions to a batch of peers not knowing about it
 	for _, tx := range txs {
-		peers := pm.peers.PeersWithoutTx(tx.Hash())
-		for _, peer := range peers {
-			txset[peer] = append(txset[peer], tx)
+		var peers []*peer
+		for _, peer := range pm.peers.PeersWithoutTx(tx.Hash()) {
+			// Skip the peers which keep delivering junk transactions
+			if peer.Score() >= txThrottleScore {
+				peers = append(peers, peer)
+			}
+		}
+		direct := int(math.Sqrt(float64(len(peers))))
+		for i, peer := range peers {
+			if i < direct || peer.version < eth65 {
+				txset[peer] = append(txset[peer], tx)
+			} else {
+				annset[peer] = append(annset[peer], tx.Hash())
+			}
 		}
 		log.Trace("Broadcast transaction", "hash", tx.Hash(), "recipients", len(peers))
 	}
-	// FIXME include this again: peers = peers[:int(math.Sqrt(float64(len(peers))))]
 	for peer, txs := range txset {
 		peer.AsyncSendTransactions(txs)
 	}
+	for peer, hashes := range annset {
+		peer.AsyncSendPooledTransactionHashes(hashes)
+	}
+}
+
+// txBehaviour returns the behaviour to report of a peer delivering a transaction
+// which the pool handled with the given error, if any. Transactions which are
+// already known, underpriced or became stale in transit are not penalised, since
+// honest peers deliver them as well. Neither are the ones exceeding the rate
+// limits of their sender, since honest peers relay a spamming account's burst
+// too.
+func txBehaviour(err error) (p2p.Behaviour, bool) {
+	switch err {
+	case nil:
+		return p2p.GoodResponse, true
+	case core.ErrPeerRateLimited, core.ErrInvalidSender, core.ErrNegativeValue, core.ErrOversizedData, core.ErrIntrinsicGas, core.ErrGasLimit:
+		return p2p.BadTransaction, true
+	default:
+		return 0, false
+	}
 }
 
 // Mined broadcast loop
@@ -30,6 +30,7 @@ import (
 	"github.com/athofficial/go-ath/common"
 	"github.com/athofficial/go-ath/consensus/ubqhash"
 	"github.com/athofficial/go-ath/core"
+	"github.com/athofficial/go-ath/core/forkid"
 	"github.com/athofficial/go-ath/core/types"
 	"github.com/athofficial/go-ath/core/vm"
 	"github.com/athofficial/go-ath/crypto"
@@ -91,16 +92,24 @@ type testTxPool struct {
 	txFeed event.Feed
 	pool   []*types.Transaction        // Collection of all transactions
 	added  chan<- []*types.Transaction // Notification channel for new transactions
+	reject error                       // Error rejecting all remote transactions, if set
 
 	lock sync.RWMutex // Protects the transaction pool
 }
 
-// AddRemotes appends a batch of transactions to the pool, and notifies any
+// AddRemotesFrom appends a batch of transactions to the pool, and notifies any
 // listeners if the addition channel is non nil
-func (p *testTxPool) AddRemotes(txs []*types.Transaction) []error {
+func (p *testTxPool) AddRemotesFrom(peer string, txs []*types.Transaction) []error {
 	p.lock.Lock()
 	defer p.lock.Unlock()
 
+	if p.reject != nil {
+		errs := make([]error, len(txs))
+		for i := range errs {
+			errs[i] = p.reject
+		}
+		return errs
+	}
 	p.pool = append(p.pool, txs...)
 	if p.added != nil {
 		p.added <- txs
@@ -124,6 +133,19 @@ func (p *testTxPool) Pending() (map[common.Address]types.Transactions, error) {
 	return batches, nil
 }
 
+// Get returns the transaction with the given hash, if known to the pool
+func (p *testTxPool) Get(hash common.Hash) *types.Transaction {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+
+	for _, tx := range p.pool {
+		if tx.Hash() == hash {
+			return tx
+		}
+	}
+	return nil
+}
+
 func (p *testTxPool) SubscribeNewTxsEvent(ch chan<- core.NewTxsEvent) event.Subscription {
 	return p.txFeed.Subscribe(ch)
 }
@@ -171,20 +193,33 @@ func newTestPeer(name string, version int, pm *ProtocolManager, shake bool) (*te
 			head    = pm.blockchain.CurrentHeader()
 			td      = pm.blockchain.GetTd(head.Hash(), head.Number.Uint64())
 		)
-		tp.handshake(nil, td, head.Hash(), genesis.Hash())
+		tp.handshake(nil, td, head.Hash(), genesis.Hash(), forkid.NewID(pm.blockchain))
 	}
 	return tp, errc
 }
 
 // handshake simulates a trivial handshake that expects the same state from the
 // remote side as we are simulating locally.
-func (p *testPeer) handshake(t *testing.T, td *big.Int, head common.Hash, genesis common.Hash) {
-	msg := &statusData{
-		ProtocolVersion: uint32(p.version),
-		NetworkId:       DefaultConfig.NetworkId,
-		TD:              td,
-		CurrentBlock:    head,
-		GenesisBlock:    genesis,
+func (p *testPeer) handshake(t *testing.T, td *big.Int, head common.Hash, genesis common.Hash, forkID forkid.ID) {
+	var msg interface{}
+	switch {
+	case p.version >= eth64:
+		msg = &statusData64{
+			ProtocolVersion: uint32(p.version),
+			NetworkID:       DefaultConfig.NetworkId,
+			TD:              td,
+			Head:            head,
+			Genesis:         genesis,
+			ForkID:          forkID,
+		}
+	default:
+		msg = &statusData{
+			ProtocolVersion: uint32(p.version),
+			NetworkId:       DefaultConfig.NetworkId,
+			TD:              td,
+			CurrentBlock:    head,
+			GenesisBlock:    genesis,
+		}
 	}
 	if err := p2p.ExpectMsg(p.app, StatusMsg, msg); err != nil {
 		t.Fatalf("status recv: %v", err)
@@ -25,6 +25,7 @@ import (
 
 	mapset "github.com/deckarep/golang-set"
 	"github.com/athofficial/go-ath/common"
+	"github.com/athofficial/go-ath/core/forkid"
 	"github.com/athofficial/go-ath/core/types"
 	"github.com/athofficial/go-ath/p2p"
 	"github.com/athofficial/go-ath/rlp"
@@ -45,6 +46,14 @@ const (
 	// contain a single transaction, or thousands.
 	maxQueuedTxs = 128
 
+	// maxQueuedTxAnns is the maximum number of transaction announcement lists to
+	// queue up before dropping broadcasts.
+	maxQueuedTxAnns = 128
+
+	// maxTxAnnounceBatch is the maximum number of transaction hashes to announce
+	// to a peer in a single message.
+	maxTxAnnounceBatch = 4096
+
 	// maxQueuedProps is the maximum number of block propagations to queue up before
 	// dropping broadcasts. There's not much point in queueing stale blocks, so a few
 	// that might cover uncles should be enough.
@@ -55,6 +64,10 @@ const (
 	// above some healthy uncle limit, so use that.
 	maxQueuedAnns = 4
 
+	// txThrottleScore is the reputation score below which no more transactions
+	// are propagated to a peer.
+	txThrottleScore = -50
+
 	handshakeTimeout = 5 * time.Second
 )
 
@@ -85,9 +98,12 @@ type peer struct {
 	td   *big.Int
 	lock sync.RWMutex
 
+	report func(p2p.Behaviour) // Reports a behaviour to the p2p reputation system (overridden in tests)
+
 	knownTxs    mapset.Set                // Set of transaction hashes known to be known by this peer
 	knownBlocks mapset.Set                // Set of block hashes known to be known by this peer
 	queuedTxs   chan []*types.Transaction // Queue of transactions to broadcast to the peer
+	queuedTxAnn chan []common.Hash        // Queue of transactions to announce to the peer
 	queuedProps chan *propEvent           // Queue of blocks to broadcast to the peer
 	queuedAnns  chan *types.Block         // Queue of blocks to announce to the peer
 	term        chan struct{}             // Termination channel to stop the broadcaster
@@ -102,9 +118,11 @@ func newPeer(version int, p *p2p.Peer, rw p2p.MsgReadWriter) *peer {
 		knownTxs:    mapset.NewSet(),
 		knownBlocks: mapset.NewSet(),
 		queuedTxs:   make(chan []*types.Transaction, maxQueuedTxs),
+		queuedTxAnn: make(chan []common.Hash, maxQueuedTxAnns),
 		queuedProps: make(chan *propEvent, maxQueuedProps),
 		queuedAnns:  make(chan *types.Block, maxQueuedAnns),
 		term:        make(chan struct{}),
+		report:      p.Report,
 	}
 }
 
@@ -120,6 +138,12 @@ func (p *peer) broadcast() {
 			}
 			p.Log().Trace("Broadcast transactions", "count", len(txs))
 
+		case hashes := <-p.queuedTxAnn:
+			if err := p.SendPooledTransactionHashes(hashes); err != nil {
+				return
+			}
+			p.Log().Trace("Announced transactions", "count", len(hashes))
+
 		case prop := <-p.queuedProps:
 			if err := p.SendNewBlock(prop.block, prop.td); err != nil {
 				return
@@ -215,6 +239,40 @@ func (p *peer) AsyncSendTransactions(txs []*types.Transaction) {
 	}
 }
 
+// SendPooledTransactionHashes announces the availability of a number of
+// transactions through a hash notification, and includes the hashes in the
+// transaction hash set of the peer for future reference.
+func (p *peer) SendPooledTransactionHashes(hashes []common.Hash) error {
+	for _, hash := range hashes {
+		p.knownTxs.Add(hash)
+	}
+	return p2p.Send(p.rw, NewPooledTransactionHashesMsg, hashes)
+}
+
+// AsyncSendPooledTransactionHashes queues a list of transactions hashes to
+// announce to a remote peer. If the peer's announcement queue is full, the
+// event is silently dropped.
+func (p *peer) AsyncSendPooledTransactionHashes(hashes []common.Hash) {
+	select {
+	case p.queuedTxAnn <- hashes:
+		for _, hash := range hashes {
+			p.knownTxs.Add(hash)
+		}
+	default:
+		p.Log().Debug("Dropping transaction announcement", "count", len(hashes))
+	}
+}
+
+// SendPooledTransactionsRLP sends requested transactions to the peer from an
+// already RLP encoded format, and adds their hashes to the transaction hash set
+// of the peer.
+func (p *peer) SendPooledTransactionsRLP(hashes []common.Hash, txs []rlp.RawValue) error {
+	for _, hash := range hashes {
+		p.knownTxs.Add(hash)
+	}
+	return p2p.Send(p.rw, PooledTransactionsMsg, txs)
+}
+
 // SendNewBlockHashes announces the availability of a number of blocks through
 // a hash notification.
 func (p *peer) SendNewBlockHashes(hashes []common.Hash, numbers []uint64) error {
@@ -327,24 +385,51 @@ func (p *peer) RequestReceipts(hashes []common.Hash) error {
 	return p2p.Send(p.rw, GetReceiptsMsg, hashes)
 }
 
+// RequestTxs fetches a batch of transactions from a remote node's pool.
+func (p *peer) RequestTxs(hashes []common.Hash) error {
+	p.Log().Debug("Fetching batch of transactions", "count", len(hashes))
+	return p2p.Send(p.rw, GetPooledTransactionsMsg, hashes)
+}
+
 // Handshake executes the eth protocol handshake, negotiating version number,
-// network IDs, difficulties, head and genesis blocks.
-func (p *peer) Handshake(network uint64, td *big.Int, head common.Hash, genesis common.Hash) error {
+// network IDs, difficulties, head and genesis blocks. From eth/64 onwards the
+// fork identifiers are exchanged too, rejecting peers on incompatible chains.
+func (p *peer) Handshake(network uint64, td *big.Int, head common.Hash, genesis common.Hash, forkID forkid.ID, forkFilter forkid.Filter) error {
 	// Send out own handshake in a new thread
 	errc := make(chan error, 2)
-	var status statusData // safe to read after two values have been received from errc
 
+	var ( // safe to read after two values have been received from errc
+		status   statusData
+		status64 statusData64
+	)
 	go func() {
-		errc <- p2p.Send(p.rw, StatusMsg, &statusData{
-			ProtocolVersion: uint32(p.version),
-			NetworkId:       network,
-			TD:              td,
-			CurrentBlock:    head,
-			GenesisBlock:    genesis,
-		})
+		switch {
+		case p.version >= eth64:
+			errc <- p2p.Send(p.rw, StatusMsg, &statusData64{
+				ProtocolVersion: uint32(p.version),
+				NetworkID:       network,
+				TD:              td,
+				Head:            head,
+				Genesis:         genesis,
+				ForkID:          forkID,
+			})
+		default:
+			errc <- p2p.Send(p.rw, StatusMsg, &statusData{
+				ProtocolVersion: uint32(p.version),
+				NetworkId:       network,
+				TD:              td,
+				CurrentBlock:    head,
+				GenesisBlock:    genesis,
+			})
+		}
 	}()
 	go func() {
-		errc <- p.readStatus(network, &status, genesis)
+		switch {
+		case p.version >= eth64:
+			errc <- p.readStatus64(network, &status64, genesis, forkFilter)
+		default:
+			errc <- p.readStatus(network, &status, genesis)
+		}
 	}()
 	timeout := time.NewTimer(handshakeTimeout)
 	defer timeout.Stop()
@@ -358,7 +443,12 @@ func (p *peer) Handshake(network uint64, td *big.Int, head common.Hash, genesis
 			return p2p.DiscReadTimeout
 		}
 	}
-	p.td, p.head = status.TD, status.CurrentBlock
+	switch {
+	case p.version >= eth64:
+		p.td, p.head = status64.TD, status64.Head
+	default:
+		p.td, p.head = status.TD, status.CurrentBlock
+	}
 	return nil
 }
 
@@ -389,6 +479,36 @@ func (p *peer) readStatus(network uint64, status *statusData, genesis common.Has
 	return nil
 }
 
+func (p *peer) readStatus64(network uint64, status *statusData64, genesis common.Hash, forkFilter forkid.Filter) (err error) {
+	msg, err := p.rw.ReadMsg()
+	if err != nil {
+		return err
+	}
+	if msg.Code != StatusMsg {
+		return errResp(ErrNoStatusMsg, "first msg has code %x (!= %x)", msg.Code, StatusMsg)
+	}
+	if msg.Size > ProtocolMaxMsgSize {
+		return errResp(ErrMsgTooLarge, "%v > %v", msg.Size, ProtocolMaxMsgSize)
+	}
+	// Decode the handshake and make sure everything matches
+	if err := msg.Decode(&status); err != nil {
+		return errResp(ErrDecode, "msg %v: %v", msg, err)
+	}
+	if status.Genesis != genesis {
+		return errResp(ErrGenesisBlockMismatch, "%x (!= %x)", status.Genesis[:8], genesis[:8])
+	}
+	if status.NetworkID != network {
+		return errResp(ErrNetworkIdMismatch, "%d (!= %d)", status.NetworkID, network)
+	}
+	if int(status.ProtocolVersion) != p.version {
+		return errResp(ErrProtocolVersionMismatch, "%d (!= %d)", status.ProtocolVersion, p.version)
+	}
+	if err := forkFilter(status.ForkID); err != nil {
+		return errResp(ErrForkIDRejected, "%v", err)
+	}
+	return nil
+}
+
 // String implements fmt.Stringer.
 func (p *peer) String() string {
 	return fmt.Sprintf("Peer %s [%s]", p.id,
@@ -23,6 +23,8 @@ import (
 	"time"
 
 	"github.com/athofficial/go-ath/common"
+	"github.com/athofficial/go-ath/core"
+	"github.com/athofficial/go-ath/core/forkid"
 	"github.com/athofficial/go-ath/core/types"
 	"github.com/athofficial/go-ath/crypto"
 	"github.com/athofficial/go-ath/eth/downloader"
@@ -92,9 +94,67 @@ func testStatusMsgErrors(t *testing.T, protocol int) {
 	}
 }
 
+func TestStatusMsgErrors64(t *testing.T) {
+	pm, _ := newTestProtocolManagerMust(t, downloader.FullSync, 0, nil, nil)
+	var (
+		genesis = pm.blockchain.Genesis()
+		head    = pm.blockchain.CurrentHeader()
+		td      = pm.blockchain.GetTd(head.Hash(), head.Number.Uint64())
+		forkID  = forkid.NewID(pm.blockchain)
+	)
+	defer pm.Stop()
+
+	tests := []struct {
+		code      uint64
+		data      interface{}
+		wantError error
+	}{
+		{
+			code: TxMsg, data: []interface{}{},
+			wantError: errResp(ErrNoStatusMsg, "first msg has code 2 (!= 0)"),
+		},
+		{
+			code: StatusMsg, data: statusData64{10, DefaultConfig.NetworkId, td, head.Hash(), genesis.Hash(), forkID},
+			wantError: errResp(ErrProtocolVersionMismatch, "10 (!= %d)", eth64),
+		},
+		{
+			code: StatusMsg, data: statusData64{eth64, 999, td, head.Hash(), genesis.Hash(), forkID},
+			wantError: errResp(ErrNetworkIdMismatch, "999 (!= %d)", DefaultConfig.NetworkId),
+		},
+		{
+			code: StatusMsg, data: statusData64{eth64, DefaultConfig.NetworkId, td, head.Hash(), common.Hash{3}, forkID},
+			wantError: errResp(ErrGenesisBlockMismatch, "0300000000000000 (!= %x)", genesis.Hash().Bytes()[:8]),
+		},
+		{
+			code: StatusMsg, data: statusData64{eth64, DefaultConfig.NetworkId, td, head.Hash(), genesis.Hash(), forkid.ID{Hash: [4]byte{0x00, 0x01, 0x02, 0x03}}},
+			wantError: errResp(ErrForkIDRejected, "%v", forkid.ErrLocalIncompatibleOrStale),
+		},
+	}
+
+	for i, test := range tests {
+		p, errc := newTestPeer("peer", eth64, pm, false)
+		// The send call might hang until reset because
+		// the protocol might not read the payload.
+		go p2p.Send(p.app, test.code, test.data)
+
+		select {
+		case err := <-errc:
+			if err == nil {
+				t.Errorf("test %d: protocol returned nil error, want %q", i, test.wantError)
+			} else if err.Error() != test.wantError.Error() {
+				t.Errorf("test %d: wrong error: got %q, want %q", i, err, test.wantError)
+			}
+		case <-time.After(2 * time.Second):
+			t.Errorf("protocol did not shut down within 2 seconds")
+		}
+		p.close()
+	}
+}
+
 // This test checks that received transactions are added to the local pool.
 func TestRecvTransactions62(t *testing.T) { testRecvTransactions(t, 62) }
 func TestRecvTransactions63(t *testing.T) { testRecvTransactions(t, 63) }
+func TestRecvTransactions65(t *testing.T) { testRecvTransactions(t, 65) }
 
 func testRecvTransactions(t *testing.T, protocol int) {
 	txAdded := make(chan []*types.Transaction)
@@ -120,9 +180,58 @@ func testRecvTransactions(t *testing.T, protocol int) {
 	}
 }
 
+// This test checks that peers relaying the burst of a rate limited sender are
+// not punished, while peers exceeding their own rate limit are dropped.
+func TestRecvRateLimitedTransactions(t *testing.T) {
+	testRecvRateLimitedTransactions(t, core.ErrSenderRateLimited, false)
+	testRecvRateLimitedTransactions(t, core.ErrReplaceRateLimited, false)
+	testRecvRateLimitedTransactions(t, core.ErrPeerRateLimited, true)
+}
+
+func testRecvRateLimitedTransactions(t *testing.T, reject error, penalise bool) {
+	pm, _ := newTestProtocolManagerMust(t, downloader.FullSync, 0, nil, nil)
+	pm.acceptTxs = 1 // mark synced to accept transactions
+	pm.txpool.(*testTxPool).reject = reject
+	p, _ := newTestPeer("peer", eth65, pm, true)
+	defer pm.Stop()
+	defer p.close()
+
+	reports := make(chan p2p.Behaviour, 200)
+	p.peer.report = func(b p2p.Behaviour) { reports <- b }
+
+	// Relay a burst of transactions, all of them rejected by the pool
+	for nonce := uint64(0); nonce < 200; nonce++ {
+		tx := newTestTransaction(testAccount, nonce, 0)
+		if err := p2p.Send(p.app, TxMsg, []interface{}{tx}); err != nil {
+			t.Fatalf("%v: send error: %v", reject, err)
+		}
+	}
+	if !penalise {
+		select {
+		case b := <-reports:
+			t.Fatalf("%v: relaying peer reported: %v", reject, b)
+		case <-time.After(100 * time.Millisecond):
+		}
+	}
+	for i := 0; penalise && i < 200; i++ {
+		select {
+		case b := <-reports:
+			if b != p2p.BadTransaction {
+				t.Fatalf("%v: behaviour mismatch: have %v, want %v", reject, b, p2p.BadTransaction)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("%v: spamming peer reported %d times, want 200", reject, i)
+		}
+	}
+	if pm.peers.Peer(p.id) == nil {
+		t.Fatalf("%v: peer unregistered", reject)
+	}
+}
+
 // This test checks that pending transactions are sent.
 func TestSendTransactions62(t *testing.T) { testSendTransactions(t, 62) }
 func TestSendTransactions63(t *testing.T) { testSendTransactions(t, 63) }
+func TestSendTransactions65(t *testing.T) { testSendTransactions(t, 65) }
 
 func testSendTransactions(t *testing.T, protocol int) {
 	pm, _ := newTestProtocolManagerMust(t, downloader.FullSync, 0, nil, nil)
@@ -134,7 +243,7 @@ func testSendTransactions(t *testing.T, protocol int) {
 	for nonce := range alltxs {
 		alltxs[nonce] = newTestTransaction(testAccount, uint64(nonce), txsize)
 	}
-	pm.txpool.AddRemotes(alltxs)
+	pm.txpool.AddRemotesFrom("", alltxs)
 
 	// Connect several peers. They should all receive the pending transactions.
 	var wg sync.WaitGroup
@@ -146,18 +255,29 @@ func testSendTransactions(t *testing.T, protocol int) {
 			seen[tx.Hash()] = false
 		}
 		for n := 0; n < len(alltxs) && !t.Failed(); {
-			var txs []*types.Transaction
+			// Legacy peers get the full transactions, eth/65 peers the hashes only
+			var hashes []common.Hash
 			msg, err := p.app.ReadMsg()
 			if err != nil {
 				t.Errorf("%v: read error: %v", p.Peer, err)
-			} else if msg.Code != TxMsg {
-				t.Errorf("%v: got code %d, want TxMsg", p.Peer, msg.Code)
 			}
-			if err := msg.Decode(&txs); err != nil {
-				t.Errorf("%v: %v", p.Peer, err)
+			switch {
+			case protocol < eth65 && msg.Code == TxMsg:
+				var txs []*types.Transaction
+				if err := msg.Decode(&txs); err != nil {
+					t.Errorf("%v: %v", p.Peer, err)
+				}
+				for _, tx := range txs {
+					hashes = append(hashes, tx.Hash())
+				}
+			case protocol >= eth65 && msg.Code == NewPooledTransactionHashesMsg:
+				if err := msg.Decode(&hashes); err != nil {
+					t.Errorf("%v: %v", p.Peer, err)
+				}
+			default:
+				t.Errorf("%v: got unexpected code %d", p.Peer, msg.Code)
 			}
-			for _, tx := range txs {
-				hash := tx.Hash()
+			for _, hash := range hashes {
 				seentx, want := seen[hash]
 				if seentx {
 					t.Errorf("%v: got tx more than once: %x", p.Peer, hash)
@@ -178,6 +298,32 @@ func testSendTransactions(t *testing.T, protocol int) {
 	wg.Wait()
 }
 
+// Tests that pooled transactions are served to eth/65 peers, skipping the ones
+// not known to the pool.
+func TestGetPooledTransactions65(t *testing.T) {
+	pm, _ := newTestProtocolManagerMust(t, downloader.FullSync, 0, nil, nil)
+	defer pm.Stop()
+
+	known := []*types.Transaction{newTestTransaction(testAccount, 0, 0), newTestTransaction(testAccount, 1, 0)}
+	pm.txpool.AddRemotesFrom("", known)
+
+	p, _ := newTestPeer("peer", eth65, pm, true)
+	defer p.close()
+
+	// Drain the announcement of the pending transactions
+	if msg, err := p.app.ReadMsg(); err != nil || msg.Code != NewPooledTransactionHashesMsg {
+		t.Fatalf("pending transactions not announced: code %v, err %v", msg.Code, err)
+	}
+	unknown := newTestTransaction(testAccount, 2, 0)
+	request := []common.Hash{known[0].Hash(), unknown.Hash(), known[1].Hash()}
+	if err := p2p.Send(p.app, GetPooledTransactionsMsg, request); err != nil {
+		t.Fatalf("send error: %v", err)
+	}
+	if err := p2p.ExpectMsg(p.app, PooledTransactionsMsg, known); err != nil {
+		t.Errorf("pooled transactions mismatch: %v", err)
+	}
+}
+
 // Tests that the custom union field encoder and decoder works correctly.
 func TestGetBlockHeadersDataEncodeDecode(t *testing.T) {
 	// Create a "random" hash for testing
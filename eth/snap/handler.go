@@ -0,0 +1,355 @@
+// Copyright 2019 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package snap
+
+import (
+	"bytes"
+
+	"github.com/athofficial/go-ath/common"
+	"github.com/athofficial/go-ath/core/state"
+	"github.com/athofficial/go-ath/ethdb"
+	"github.com/athofficial/go-ath/p2p"
+	"github.com/athofficial/go-ath/rlp"
+	"github.com/athofficial/go-ath/trie"
+)
+
+const (
+	// softResponseLimit is the target maximum size of replies to data retrievals.
+	softResponseLimit = 2 * 1024 * 1024
+
+	// maxCodeLookups is the maximum number of bytecodes to serve. This number is
+	// there to limit the number of disk lookups.
+	maxCodeLookups = 1024
+)
+
+// Backend defines the data retrieval methods to serve remote requests.
+type Backend interface {
+	// StateCache retrieves the state database to serve the account and storage
+	// ranges and the contract codes from.
+	StateCache() state.Database
+}
+
+// MakeProtocol constructs the snap sub-protocol, serving state ranges from the
+// given backend and delivering the responses to our own requests to the syncer.
+func MakeProtocol(backend Backend, syncer *Syncer) p2p.Protocol {
+	return p2p.Protocol{
+		Name:    ProtocolName,
+		Version: ProtocolVersions[0],
+		Length:  ProtocolLengths[0],
+		Run: func(p *p2p.Peer, rw p2p.MsgReadWriter) error {
+			peer := newPeer(int(ProtocolVersions[0]), p, rw)
+			return handle(backend, syncer, peer)
+		},
+	}
+}
+
+// handle is the callback invoked to manage the life cycle of a snap peer. When
+// this function terminates, the peer is disconnected.
+func handle(backend Backend, syncer *Syncer, peer *Peer) error {
+	if err := syncer.Register(peer); err != nil {
+		peer.Log().Error("Failed to register peer in snap syncer", "err", err)
+		return err
+	}
+	defer syncer.Unregister(peer.id)
+
+	for {
+		if err := handleMessage(backend, syncer, peer); err != nil {
+			peer.Log().Debug("Message handling failed in snap", "err", err)
+			return err
+		}
+	}
+}
+
+// handleMessage is invoked whenever an inbound message is received from a
+// remote peer. The remote connection is torn down upon returning any error.
+func handleMessage(backend Backend, syncer *Syncer, peer *Peer) error {
+	// Read the next message from the remote peer, and ensure it's fully consumed
+	msg, err := peer.rw.ReadMsg()
+	if err != nil {
+		return err
+	}
+	if msg.Size > ProtocolMaxMsgSize {
+		return errResp(errMsgTooLarge, "%v > %v", msg.Size, ProtocolMaxMsgSize)
+	}
+	defer msg.Discard()
+
+	// Handle the message depending on its contents
+	switch msg.Code {
+	case GetAccountRangeMsg:
+		// Decode the account retrieval request
+		var req getAccountRangeData
+		if err := msg.Decode(&req); err != nil {
+			return errResp(errDecode, "msg %v: %v", msg, err)
+		}
+		accounts, proof := serviceGetAccountRange(backend, &req)
+		return peer.sendAccountRange(req.ID, accounts, proof)
+
+	case AccountRangeMsg:
+		// A range of accounts arrived to one of our previous requests
+		var res accountRangeData
+		if err := msg.Decode(&res); err != nil {
+			return errResp(errDecode, "msg %v: %v", msg, err)
+		}
+		hashes, accounts := res.Unpack()
+		return syncer.OnAccounts(peer, res.ID, hashes, accounts, res.Proof)
+
+	case GetStorageRangesMsg:
+		// Decode the storage retrieval request
+		var req getStorageRangesData
+		if err := msg.Decode(&req); err != nil {
+			return errResp(errDecode, "msg %v: %v", msg, err)
+		}
+		slots, proof := serviceGetStorageRanges(backend, &req)
+		return peer.sendStorageRanges(req.ID, slots, proof)
+
+	case StorageRangesMsg:
+		// A range of storage slots arrived to one of our previous requests
+		var res storageRangesData
+		if err := msg.Decode(&res); err != nil {
+			return errResp(errDecode, "msg %v: %v", msg, err)
+		}
+		// Ensure the ranges are monotonically increasing
+		for i, slots := range res.Slots {
+			for j := 1; j < len(slots); j++ {
+				if bytes.Compare(slots[j-1].Hash[:], slots[j].Hash[:]) >= 0 {
+					return errResp(errBadRequest, "storage slots not monotonically increasing for account #%d", i)
+				}
+			}
+		}
+		hashes, slots := res.Unpack()
+		return syncer.OnStorage(peer, res.ID, hashes, slots, res.Proof)
+
+	case GetByteCodesMsg:
+		// Decode bytecode retrieval request
+		var req getByteCodesData
+		if err := msg.Decode(&req); err != nil {
+			return errResp(errDecode, "msg %v: %v", msg, err)
+		}
+		codes := serviceGetByteCodes(backend, &req)
+		return peer.sendByteCodes(req.ID, codes)
+
+	case ByteCodesMsg:
+		// A batch of byte codes arrived to one of our previous requests
+		var res byteCodesData
+		if err := msg.Decode(&res); err != nil {
+			return errResp(errDecode, "msg %v: %v", msg, err)
+		}
+		return syncer.OnByteCodes(peer, res.ID, res.Codes)
+
+	default:
+		return errResp(errInvalidMsgCode, "%v", msg.Code)
+	}
+}
+
+// serviceGetAccountRange assembles the response to an account range query. If
+// the requested state is not available, an empty response is returned.
+func serviceGetAccountRange(backend Backend, req *getAccountRangeData) ([]*accountData, [][]byte) {
+	if req.Bytes > softResponseLimit {
+		req.Bytes = softResponseLimit
+	}
+	tr, err := trie.New(req.Root, backend.StateCache().TrieDB())
+	if err != nil {
+		return nil, nil
+	}
+	// Iterate over the requested range and pile accounts up
+	var (
+		it       = trie.NewIterator(tr.NodeIterator(req.Origin[:]))
+		accounts []*accountData
+		size     uint64
+		last     []byte
+	)
+	for it.Next() {
+		hash, account := common.BytesToHash(it.Key), common.CopyBytes(it.Value)
+
+		// Track the returned interval for the Merkle proofs
+		last = hash[:]
+
+		// Assemble the reply item
+		size += uint64(common.HashLength + len(account))
+		accounts = append(accounts, &accountData{
+			Hash: hash,
+			Body: account,
+		})
+		// If we've exceeded the request threshold, abort
+		if bytes.Compare(hash[:], req.Limit[:]) >= 0 {
+			break
+		}
+		if size > req.Bytes {
+			break
+		}
+	}
+	if it.Err != nil {
+		return nil, nil
+	}
+	// Generate the Merkle proofs for the first and last account
+	proof := ethdb.NewMemDatabase()
+	if err := tr.Prove(req.Origin[:], 0, proof); err != nil {
+		return nil, nil
+	}
+	if last != nil {
+		if err := tr.Prove(last, 0, proof); err != nil {
+			return nil, nil
+		}
+	}
+	return accounts, proofList(proof)
+}
+
+// serviceGetStorageRanges assembles the response to a storage ranges query. If
+// the requested state is not available, an empty response is returned.
+func serviceGetStorageRanges(backend Backend, req *getStorageRangesData) ([][]*storageData, [][]byte) {
+	if req.Bytes > softResponseLimit {
+		req.Bytes = softResponseLimit
+	}
+	// Calculate the hard limit at which to abort, even if mid storage trie
+	hardLimit := uint64(float64(req.Bytes) * 1.25)
+
+	triedb := backend.StateCache().TrieDB()
+	accTrie, err := trie.New(req.Root, triedb)
+	if err != nil {
+		return nil, nil
+	}
+	// Retrieve storage ranges until the packet limit is reached
+	var (
+		slots  [][]*storageData
+		proofs [][]byte
+		size   uint64
+	)
+	for _, account := range req.Accounts {
+		// If we've exceeded the requested data limit, abort without opening
+		// a new storage range (that we'd need to prove due to exceeded size)
+		if size >= req.Bytes {
+			break
+		}
+		// The first account might start from a different origin and end sooner
+		var origin common.Hash
+		if len(req.Origin) > 0 {
+			origin, req.Origin = common.BytesToHash(req.Origin), nil
+		}
+		var limit = common.HexToHash("0xffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffff")
+		if len(req.Limit) > 0 {
+			limit, req.Limit = common.BytesToHash(req.Limit), nil
+		}
+		// Resolve the storage trie of the account
+		blob, err := accTrie.TryGet(account[:])
+		if err != nil || blob == nil {
+			return nil, nil
+		}
+		var acc state.Account
+		if err := rlp.DecodeBytes(blob, &acc); err != nil {
+			return nil, nil
+		}
+		stTrie, err := trie.New(acc.Root, triedb)
+		if err != nil {
+			return nil, nil
+		}
+		// Retrieve the requested state and bail out if non existent
+		var (
+			it      = trie.NewIterator(stTrie.NodeIterator(origin[:]))
+			storage []*storageData
+			last    []byte
+			abort   bool
+		)
+		for it.Next() {
+			if size >= hardLimit {
+				abort = true
+				break
+			}
+			hash, slot := common.BytesToHash(it.Key), common.CopyBytes(it.Value)
+
+			// Track the returned interval for the Merkle proofs
+			last = hash[:]
+
+			// Assemble the reply item
+			size += uint64(common.HashLength + len(slot))
+			storage = append(storage, &storageData{
+				Hash: hash,
+				Body: slot,
+			})
+			// If we've exceeded the request threshold, abort
+			if bytes.Compare(hash[:], limit[:]) >= 0 {
+				break
+			}
+		}
+		if it.Err != nil {
+			return nil, nil
+		}
+		if len(storage) > 0 {
+			slots = append(slots, storage)
+		}
+		// Generate the Merkle proofs for the first and last storage slot, but
+		// only if the response was capped. If the entire storage trie included
+		// in the response, no need for any proofs.
+		if origin != (common.Hash{}) || (abort && len(storage) > 0) {
+			proof := ethdb.NewMemDatabase()
+			if err := stTrie.Prove(origin[:], 0, proof); err != nil {
+				return nil, nil
+			}
+			if last != nil {
+				if err := stTrie.Prove(last, 0, proof); err != nil {
+					return nil, nil
+				}
+			}
+			proofs = proofList(proof)
+
+			// Proof terminates the reply as proofs are only added if a node
+			// refuses to serve more data (exception when a contract fetch is
+			// finishing, but that's that).
+			break
+		}
+	}
+	return slots, proofs
+}
+
+// serviceGetByteCodes assembles the response to a byte codes query, skipping
+// any codes not available locally.
+func serviceGetByteCodes(backend Backend, req *getByteCodesData) [][]byte {
+	if req.Bytes > softResponseLimit {
+		req.Bytes = softResponseLimit
+	}
+	if len(req.Hashes) > maxCodeLookups {
+		req.Hashes = req.Hashes[:maxCodeLookups]
+	}
+	// Retrieve bytecodes until the packet size limit is reached
+	var (
+		codes [][]byte
+		size  uint64
+	)
+	for _, hash := range req.Hashes {
+		if hash == emptyCode {
+			// Peers should not request the empty code, but if they do, at
+			// least sent them back a correct response without db lookups
+			codes = append(codes, []byte{})
+		} else if blob, err := backend.StateCache().TrieDB().Node(hash); err == nil {
+			codes = append(codes, blob)
+			size += uint64(len(blob))
+		}
+		if size > req.Bytes {
+			break
+		}
+	}
+	return codes
+}
+
+// proofList flattens a proof database into the list of its nodes.
+func proofList(proof *ethdb.MemDatabase) [][]byte {
+	var nodes [][]byte
+	for _, key := range proof.Keys() {
+		node, _ := proof.Get(key)
+		nodes = append(nodes, node)
+	}
+	return nodes
+}
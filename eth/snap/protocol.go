@@ -0,0 +1,161 @@
+// Copyright 2019 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package snap implements a state synchronisation protocol which retrieves the
+// state trie as contiguous account and storage ranges, each accompanied by a
+// Merkle range proof, instead of downloading it node by node.
+package snap
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/athofficial/go-ath/common"
+	"github.com/athofficial/go-ath/rlp"
+)
+
+// Constants to match up protocol versions and messages
+const (
+	snap1 = 1
+)
+
+// ProtocolName is the official short name of the protocol used during capability negotiation.
+var ProtocolName = "snap"
+
+// ProtocolVersions are the supported versions of the snap protocol (first is primary).
+var ProtocolVersions = []uint{snap1}
+
+// ProtocolLengths are the number of implemented message corresponding to different protocol versions.
+var ProtocolLengths = []uint64{6}
+
+const ProtocolMaxMsgSize = 10 * 1024 * 1024 // Maximum cap on the size of a protocol message
+
+// snap protocol message codes
+const (
+	GetAccountRangeMsg  = 0x00
+	AccountRangeMsg     = 0x01
+	GetStorageRangesMsg = 0x02
+	StorageRangesMsg    = 0x03
+	GetByteCodesMsg     = 0x04
+	ByteCodesMsg        = 0x05
+)
+
+var (
+	errMsgTooLarge    = errors.New("message too long")
+	errDecode         = errors.New("invalid message")
+	errInvalidMsgCode = errors.New("invalid message code")
+	errBadRequest     = errors.New("bad request")
+)
+
+// getAccountRangeData is the network packet to request a range of accounts
+// from the state trie of a given root.
+type getAccountRangeData struct {
+	ID     uint64      // Request ID to match up responses with
+	Root   common.Hash // Root hash of the account trie to serve
+	Origin common.Hash // Hash of the first account to retrieve
+	Limit  common.Hash // Hash of the last account to retrieve
+	Bytes  uint64      // Soft limit at which to stop returning data
+}
+
+// accountRangeData is the network packet of an account range, along with the
+// Merkle proofs of its first and last accounts.
+type accountRangeData struct {
+	ID       uint64         // ID of the request this is a response for
+	Accounts []*accountData // List of consecutive accounts from the trie
+	Proof    [][]byte       // List of trie nodes proving the account range
+}
+
+// accountData represents a single account in a range response.
+type accountData struct {
+	Hash common.Hash  // Hash of the account
+	Body rlp.RawValue // RLP encoded account, as stored in the trie
+}
+
+// Unpack retrieves the accounts from the range packet and returns them in
+// split form.
+func (p *accountRangeData) Unpack() ([]common.Hash, [][]byte) {
+	var (
+		hashes   = make([]common.Hash, len(p.Accounts))
+		accounts = make([][]byte, len(p.Accounts))
+	)
+	for i, acc := range p.Accounts {
+		hashes[i], accounts[i] = acc.Hash, acc.Body
+	}
+	return hashes, accounts
+}
+
+// getStorageRangesData is the network packet to request the storage slots of
+// multiple accounts. The origin and limit only apply to the first account,
+// allowing large storage tries to be retrieved in multiple chunks.
+type getStorageRangesData struct {
+	ID       uint64        // Request ID to match up responses with
+	Root     common.Hash   // Root hash of the account trie to serve
+	Accounts []common.Hash // Account hashes of the storage tries to serve
+	Origin   []byte        // Hash of the first storage slot to retrieve (large contract mode)
+	Limit    []byte        // Hash of the last storage slot to retrieve (large contract mode)
+	Bytes    uint64        // Soft limit at which to stop returning data
+}
+
+// storageRangesData is the network packet of the storage ranges of multiple
+// accounts. Only the last range may be partial, in which case its edges are
+// proven; all the others are complete storage tries.
+type storageRangesData struct {
+	ID    uint64           // ID of the request this is a response for
+	Slots [][]*storageData // Lists of consecutive storage slots for the requested accounts
+	Proof [][]byte         // Merkle proofs for the *last* slot range, if it's incomplete
+}
+
+// storageData represents a single storage slot in a range response.
+type storageData struct {
+	Hash common.Hash // Hash of the storage slot
+	Body []byte      // Data content of the slot, as stored in the trie
+}
+
+// Unpack retrieves the storage slots from the range packet and returns them in
+// split form.
+func (p *storageRangesData) Unpack() ([][]common.Hash, [][][]byte) {
+	var (
+		hashset = make([][]common.Hash, len(p.Slots))
+		slotset = make([][][]byte, len(p.Slots))
+	)
+	for i, slots := range p.Slots {
+		hashset[i] = make([]common.Hash, len(slots))
+		slotset[i] = make([][]byte, len(slots))
+		for j, slot := range slots {
+			hashset[i][j] = slot.Hash
+			slotset[i][j] = slot.Body
+		}
+	}
+	return hashset, slotset
+}
+
+// getByteCodesData is the network packet to request a batch of contract codes.
+type getByteCodesData struct {
+	ID     uint64        // Request ID to match up responses with
+	Hashes []common.Hash // Code hashes to retrieve the code for
+	Bytes  uint64        // Soft limit at which to stop returning data
+}
+
+// byteCodesData is the network packet of a batch of contract codes.
+type byteCodesData struct {
+	ID    uint64   // ID of the request this is a response for
+	Codes [][]byte // Requested contract bytecodes
+}
+
+// errResp wraps a protocol violation into a descriptive error.
+func errResp(err error, format string, v ...interface{}) error {
+	return fmt.Errorf("%v - %v", err, fmt.Sprintf(format, v...))
+}
@@ -0,0 +1,1037 @@
+// Copyright 2019 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package snap
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math/big"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/athofficial/go-ath/common"
+	"github.com/athofficial/go-ath/core/state"
+	"github.com/athofficial/go-ath/crypto"
+	"github.com/athofficial/go-ath/ethdb"
+	"github.com/athofficial/go-ath/log"
+	"github.com/athofficial/go-ath/rlp"
+	"github.com/athofficial/go-ath/trie"
+)
+
+var (
+	// emptyRoot is the known root hash of an empty trie.
+	emptyRoot = common.HexToHash("56e81f171bcc55a6ff8345e692c0f86e5b48e01b996cadc001622fb5e363b421")
+
+	// emptyCode is the known hash of the empty EVM bytecode.
+	emptyCode = crypto.Keccak256Hash(nil)
+)
+
+const (
+	// maxRequestSize is the maximum number of bytes to request from a remote peer.
+	maxRequestSize = 512 * 1024
+
+	// maxStorageSetRequestCount is the maximum number of contracts to request the
+	// storage of in a single query. If this number is too low, we're not filling
+	// responses fully and waste round trip times. If it's too high, we're capping
+	// responses and waste bandwidth.
+	maxStorageSetRequestCount = maxRequestSize / 1024
+
+	// maxCodeRequestCount is the maximum number of bytecode blobs to request in a
+	// single query. If this number is too low, we're not filling responses fully
+	// and waste round trip times. If it's too high, we're capping responses and
+	// waste bandwidth.
+	//
+	// Deployed bytecodes are currently capped at 24KB, so the minimum request
+	// size should be maxRequestSize / 24K. Assuming that most contracts do not
+	// come close to that, requesting 4x should be a good approximation.
+	maxCodeRequestCount = maxRequestSize / (24 * 1024) * 4
+
+	// requestTimeout is the maximum time a peer is allowed to spend on serving
+	// a single network request.
+	requestTimeout = 10 * time.Second
+
+	// accountConcurrency is the number of chunks to split the account trie into
+	// to allow concurrent retrievals.
+	accountConcurrency = 16
+)
+
+// ErrCancelled is returned from snap syncing if the operation was prematurely
+// terminated.
+var ErrCancelled = errors.New("sync cancelled")
+
+// SyncPeer abstracts out the methods required for a peer to be synced against
+// with the goal of allowing the construction of mock peers without the full
+// blown networking.
+type SyncPeer interface {
+	// ID retrieves the peer's unique identifier.
+	ID() string
+
+	// RequestAccountRange fetches a batch of accounts rooted in a specific account
+	// trie, starting with the origin.
+	RequestAccountRange(id uint64, root, origin, limit common.Hash, bytes uint64) error
+
+	// RequestStorageRanges fetches a batch of storage slots belonging to one or
+	// more accounts. If slots from only one account is requested, an origin marker
+	// may also be used to retrieve from there.
+	RequestStorageRanges(id uint64, root common.Hash, accounts []common.Hash, origin, limit []byte, bytes uint64) error
+
+	// RequestByteCodes fetches a batch of bytecodes by hash.
+	RequestByteCodes(id uint64, hashes []common.Hash, bytes uint64) error
+
+	// Log retrieves the peer's own contextual logger.
+	Log() log.Logger
+}
+
+// requestKind is the type of data a network request retrieves.
+type requestKind int
+
+const (
+	accountRequest requestKind = iota
+	storageRequest
+	bytecodeRequest
+)
+
+// request tracks a pending network request of any kind, waiting for the remote
+// peer to deliver or for the request to time out.
+type request struct {
+	kind requestKind // Type of data being retrieved
+	peer string      // Peer to which this request is assigned
+	id   uint64      // Request ID of this request
+
+	deliver chan *response // Channel to deliver successful response on
+	cancel  chan struct{}  // Channel to track sync cancellation
+	timer   *time.Timer    // Timer to track delivery timeout
+
+	task     *accountTask  // [account] Task which this request is filling
+	origin   common.Hash   // [account, storage] First hash requested
+	accounts []common.Hash // [storage] Accounts whose storage is requested
+	hashes   []common.Hash // [bytecode] Code hashes requested
+}
+
+// response is a delivered (or timed out) network request, along with all the
+// data it carried.
+type response struct {
+	req     *request // Original request to match up the response with
+	timeout bool     // Whether the request timed out or its peer dropped
+
+	hashes   []common.Hash   // [account] Account hashes in the returned range
+	accounts [][]byte        // [account] RLP encoded accounts in the returned range
+	slotKeys [][]common.Hash // [storage] Slot hashes in the returned ranges
+	slots    [][][]byte      // [storage] Slot values in the returned ranges
+	codes    [][]byte        // [bytecode] Contract codes returned
+	proof    [][]byte        // [account, storage] Merkle proof of the last range
+}
+
+// accountTask represents the sync task for a chunk of the account snapshot.
+type accountTask struct {
+	first common.Hash // First account of this interval
+	next  common.Hash // Next account to sync in this interval
+	last  common.Hash // Last account to sync in this interval
+
+	req   *request      // Pending request to fill this task
+	batch *accountBatch // Retrieved accounts waiting on their storage and code
+	done  bool          // Flag whether the interval has been fully synced
+
+	trie *trie.Trie // Account trie accumulating the completed leaves of the interval
+}
+
+// accountBatch is a range of accounts retrieved for a task, waiting for all the
+// storage tries and contract codes they reference to be retrieved before being
+// committed into the task's trie.
+type accountBatch struct {
+	task *accountTask // Task which this batch is part of
+
+	hashes   []common.Hash // Account hashes in the range
+	accounts [][]byte      // RLP encoded accounts in the range
+	cont     bool          // Whether the task has more accounts after this batch
+	pending  int           // Number of storage tries and codes still missing
+}
+
+// storageTask represents the sync task for the storage trie of an account.
+type storageTask struct {
+	batch *accountBatch // Batch of the account owning the storage
+	root  common.Hash   // Storage root the retrieved slots must hash to
+	next  common.Hash   // Next slot to sync (non-zero for continued large tries)
+	req   *request      // Pending request to fill this task
+	trie  *trie.Trie    // Storage trie accumulating the retrieved slots
+}
+
+// codeTask represents the sync task for a contract code, which may be needed
+// by multiple accounts at once.
+type codeTask struct {
+	batches []*accountBatch // Batches of the accounts waiting for the code
+	req     *request        // Pending request to fill this task
+}
+
+// Syncer is a state synchroniser which retrieves the state trie of a given
+// root as contiguous account and storage ranges from remote peers, verifying
+// each of them with Merkle range proofs.
+//
+// The ranges are reassembled into trie nodes locally. As the pivot of the sync
+// may move while retrieving, the resulting trie will have gaps at the edges of
+// the retrieved chunks, which need to be healed afterwards by a node by node
+// trie sync. All the trie nodes written are complete sub-tries however, so the
+// healing will not descend into them.
+type Syncer struct {
+	db     ethdb.Database // Database to store the trie nodes into (and dedup)
+	triedb *trie.Database // Trie database to accumulate and commit the tries with
+
+	root         common.Hash                  // Current state trie root being synced
+	tasks        []*accountTask               // Current account task set being synced
+	storageTasks map[common.Hash]*storageTask // Storage tries waiting for retrieval, by account hash
+	codeTasks    map[common.Hash]*codeTask    // Contract codes waiting for retrieval, by code hash
+
+	peers     map[string]SyncPeer // Currently active peers to download from
+	idlers    map[string]struct{} // Peers that are not currently serving a request
+	stateless map[string]struct{} // Peers that failed to deliver the current state
+	update    chan struct{}       // Notification channel for possible sync progression
+	requests  map[uint64]*request // Requests currently running against peers
+
+	accountSynced  uint64             // Number of accounts downloaded
+	accountBytes   common.StorageSize // Number of account trie bytes persisted to disk
+	storageSynced  uint64             // Number of storage slots downloaded
+	storageBytes   common.StorageSize // Number of storage trie bytes persisted to disk
+	bytecodeSynced uint64             // Number of bytecodes downloaded
+	bytecodeBytes  common.StorageSize // Number of bytecode bytes downloaded
+
+	startTime time.Time // Time instance when snapshot sync started
+	logTime   time.Time // Time instance when status was last reported
+
+	lock sync.RWMutex // Protects fields that can change outside of sync (peers, reqs, root)
+}
+
+// NewSyncer creates a new snapshot syncer to download the state trie over the
+// snap protocol into the given database.
+func NewSyncer(db ethdb.Database) *Syncer {
+	return &Syncer{
+		db:        db,
+		triedb:    trie.NewDatabase(db),
+		peers:     make(map[string]SyncPeer),
+		idlers:    make(map[string]struct{}),
+		stateless: make(map[string]struct{}),
+		update:    make(chan struct{}, 1),
+		requests:  make(map[uint64]*request),
+	}
+}
+
+// Register injects a new data source into the syncer's peerset.
+func (s *Syncer) Register(peer SyncPeer) error {
+	// Make sure the peer is not registered yet
+	id := peer.ID()
+
+	s.lock.Lock()
+	if _, ok := s.peers[id]; ok {
+		log.Error("Snap peer already registered", "id", id)
+
+		s.lock.Unlock()
+		return errors.New("already registered")
+	}
+	s.peers[id] = peer
+	s.idlers[id] = struct{}{}
+	s.lock.Unlock()
+
+	// Notify any active syncs that a new peer can be assigned data
+	s.notify()
+	return nil
+}
+
+// Unregister removes a data source from the syncer's peerset, reverting any
+// requests it had in flight.
+func (s *Syncer) Unregister(id string) error {
+	// Remove all traces of the peer from the registry
+	s.lock.Lock()
+	if _, ok := s.peers[id]; !ok {
+		log.Error("Snap peer not registered", "id", id)
+
+		s.lock.Unlock()
+		return errors.New("not registered")
+	}
+	delete(s.peers, id)
+	delete(s.idlers, id)
+	delete(s.stateless, id)
+
+	var reverts []*request
+	for reqid, req := range s.requests {
+		if req.peer == id {
+			req.timer.Stop()
+			delete(s.requests, reqid)
+			reverts = append(reverts, req)
+		}
+	}
+	s.lock.Unlock()
+
+	// Revert the requests of the peer, treating them as timed out
+	for _, req := range reverts {
+		go s.deliver(req, &response{req: req, timeout: true})
+	}
+	// Notify any active syncs that pending requests need to be reverted
+	s.notify()
+	return nil
+}
+
+// notify pings the sync loop that something might have changed.
+func (s *Syncer) notify() {
+	select {
+	case s.update <- struct{}{}:
+	default:
+	}
+}
+
+// Sync starts (or resumes a previous) sync cycle to iterate over a state trie
+// with the given root and reconstruct the nodes based on the retrieved ranges.
+//
+// Previously completed chunks are retained across calls even if the root
+// changes, leaving any inconsistencies to the healing phase.
+func (s *Syncer) Sync(root common.Hash, cancel chan struct{}) error {
+	s.lock.Lock()
+	if s.tasks == nil {
+		s.tasks = s.newAccountTasks()
+		s.startTime = time.Now()
+	}
+	if s.root != root {
+		// Peers might have the new state even if they had no old one
+		s.stateless = make(map[string]struct{})
+	}
+	s.root = root
+
+	// Drop any accounts retrieved but not yet committed in a previous cycle,
+	// their storage roots might be stale by now
+	for _, task := range s.tasks {
+		task.batch = nil
+	}
+	s.storageTasks = make(map[common.Hash]*storageTask)
+	s.codeTasks = make(map[common.Hash]*codeTask)
+	s.lock.Unlock()
+
+	log.Debug("Starting snapshot sync cycle", "root", root)
+
+	var (
+		responses = make(chan *response)
+		done      = make(chan struct{})
+	)
+	defer func() {
+		// Abort all pending requests, making their peers available again
+		s.lock.Lock()
+		for id, req := range s.requests {
+			req.timer.Stop()
+			delete(s.requests, id)
+			if _, ok := s.peers[req.peer]; ok {
+				s.idlers[req.peer] = struct{}{}
+			}
+		}
+		for _, task := range s.tasks {
+			task.req = nil
+		}
+		s.lock.Unlock()
+
+		close(done)
+		s.report(true)
+	}()
+	for {
+		// If all the account tasks are done, the range sync is finished
+		if s.complete() {
+			log.Debug("Snapshot sync cycle completed", "root", root)
+			return nil
+		}
+		// Assign all the data retrieval tasks to any free peers
+		s.assignAccountTasks(responses, done)
+		s.assignStorageTasks(responses, done)
+		s.assignBytecodeTasks(responses, done)
+
+		// Wait for something to happen
+		select {
+		case <-s.update:
+			// Something happened (new peer, delivery, timeout), recheck tasks
+		case <-cancel:
+			return ErrCancelled
+
+		case res := <-responses:
+			s.lock.Lock()
+			if _, ok := s.peers[res.req.peer]; ok {
+				s.idlers[res.req.peer] = struct{}{}
+			}
+			s.lock.Unlock()
+
+			switch res.req.kind {
+			case accountRequest:
+				s.processAccountResponse(res)
+			case storageRequest:
+				s.processStorageResponse(res)
+			case bytecodeRequest:
+				s.processBytecodeResponse(res)
+			}
+		}
+		s.report(false)
+	}
+}
+
+// newAccountTasks splits the account hash space into equal chunks, creating a
+// sync task for each.
+func (s *Syncer) newAccountTasks() []*accountTask {
+	var (
+		tasks []*accountTask
+		next  common.Hash
+		step  = new(big.Int).Sub(
+			new(big.Int).Div(
+				new(big.Int).Exp(common.Big2, common.Big256, nil),
+				big.NewInt(accountConcurrency),
+			), common.Big1,
+		)
+	)
+	for i := 0; i < accountConcurrency; i++ {
+		last := common.BigToHash(new(big.Int).Add(next.Big(), step))
+		if i == accountConcurrency-1 {
+			// Make sure we don't overflow if the step is not a proper divisor
+			last = common.HexToHash("0xffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffff")
+		}
+		tr, _ := trie.New(common.Hash{}, s.triedb)
+		tasks = append(tasks, &accountTask{
+			first: next,
+			next:  next,
+			last:  last,
+			trie:  tr,
+		})
+		next = common.BigToHash(new(big.Int).Add(last.Big(), common.Big1))
+	}
+	return tasks
+}
+
+// complete returns whether all the account tasks have been synced and
+// committed.
+func (s *Syncer) complete() bool {
+	for _, task := range s.tasks {
+		if !task.done {
+			return false
+		}
+	}
+	return true
+}
+
+// idlePeers returns the peers that are free to be assigned a request and that
+// are believed to have the current state.
+func (s *Syncer) idlePeers() []string {
+	idlers := make([]string, 0, len(s.idlers))
+	for id := range s.idlers {
+		if _, ok := s.stateless[id]; ok {
+			continue
+		}
+		idlers = append(idlers, id)
+	}
+	rand.Shuffle(len(idlers), func(i, j int) { idlers[i], idlers[j] = idlers[j], idlers[i] })
+	return idlers
+}
+
+// track registers a new request as pending against its peer, starting the
+// timer that reverts it if no response arrives in time.
+func (s *Syncer) track(req *request) {
+	for {
+		req.id = rand.Uint64()
+		if _, ok := s.requests[req.id]; !ok {
+			break
+		}
+	}
+	req.timer = time.AfterFunc(requestTimeout, func() {
+		s.lock.Lock()
+		if s.requests[req.id] != req {
+			s.lock.Unlock()
+			return
+		}
+		delete(s.requests, req.id)
+		s.lock.Unlock()
+
+		log.Debug("Snap request timed out", "peer", req.peer, "reqid", req.id)
+		s.deliver(req, &response{req: req, timeout: true})
+	})
+	s.requests[req.id] = req
+	delete(s.idlers, req.peer)
+}
+
+// deliver hands a response over to the sync cycle that issued the request,
+// unless that cycle was already terminated.
+func (s *Syncer) deliver(req *request, res *response) {
+	select {
+	case req.deliver <- res:
+	case <-req.cancel:
+	}
+}
+
+// assignAccountTasks attempts to match idle peers to pending account range
+// retrievals.
+func (s *Syncer) assignAccountTasks(deliver chan *response, cancel chan struct{}) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	idlers := s.idlePeers()
+	for _, task := range s.tasks {
+		if len(idlers) == 0 {
+			return
+		}
+		// Skip any tasks already filling or waiting on their storage and code
+		if task.done || task.req != nil || task.batch != nil {
+			continue
+		}
+		peer := s.peers[idlers[0]]
+		idlers = idlers[1:]
+
+		req := &request{
+			kind:    accountRequest,
+			peer:    peer.ID(),
+			deliver: deliver,
+			cancel:  cancel,
+			task:    task,
+			origin:  task.next,
+		}
+		s.track(req)
+		task.req = req
+
+		if err := peer.RequestAccountRange(req.id, s.root, req.origin, task.last, maxRequestSize); err != nil {
+			peer.Log().Debug("Failed to request account range", "err", err)
+			s.revertLocked(req)
+		}
+	}
+}
+
+// assignStorageTasks attempts to match idle peers to pending storage range
+// retrievals.
+func (s *Syncer) assignStorageTasks(deliver chan *response, cancel chan struct{}) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	idlers := s.idlePeers()
+	for len(idlers) > 0 {
+		// Gather a batch of unassigned storage tries to request. Continuations
+		// of large tries are requested on their own.
+		var (
+			accounts []common.Hash
+			origin   common.Hash
+		)
+		for account, task := range s.storageTasks {
+			if task.req != nil {
+				continue
+			}
+			if task.next != (common.Hash{}) {
+				if len(accounts) > 0 {
+					continue
+				}
+				accounts, origin = []common.Hash{account}, task.next
+				break
+			}
+			accounts = append(accounts, account)
+			if len(accounts) >= maxStorageSetRequestCount {
+				break
+			}
+		}
+		if len(accounts) == 0 {
+			return
+		}
+		peer := s.peers[idlers[0]]
+		idlers = idlers[1:]
+
+		req := &request{
+			kind:     storageRequest,
+			peer:     peer.ID(),
+			deliver:  deliver,
+			cancel:   cancel,
+			origin:   origin,
+			accounts: accounts,
+		}
+		s.track(req)
+		for _, account := range accounts {
+			s.storageTasks[account].req = req
+		}
+		var from []byte
+		if origin != (common.Hash{}) {
+			from = origin[:]
+		}
+		if err := peer.RequestStorageRanges(req.id, s.root, accounts, from, nil, maxRequestSize); err != nil {
+			peer.Log().Debug("Failed to request storage ranges", "err", err)
+			s.revertLocked(req)
+		}
+	}
+}
+
+// assignBytecodeTasks attempts to match idle peers to pending code retrievals.
+func (s *Syncer) assignBytecodeTasks(deliver chan *response, cancel chan struct{}) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	idlers := s.idlePeers()
+	for len(idlers) > 0 {
+		var hashes []common.Hash
+		for hash, task := range s.codeTasks {
+			if task.req != nil {
+				continue
+			}
+			hashes = append(hashes, hash)
+			if len(hashes) >= maxCodeRequestCount {
+				break
+			}
+		}
+		if len(hashes) == 0 {
+			return
+		}
+		peer := s.peers[idlers[0]]
+		idlers = idlers[1:]
+
+		req := &request{
+			kind:    bytecodeRequest,
+			peer:    peer.ID(),
+			deliver: deliver,
+			cancel:  cancel,
+			hashes:  hashes,
+		}
+		s.track(req)
+		for _, hash := range hashes {
+			s.codeTasks[hash].req = req
+		}
+		if err := peer.RequestByteCodes(req.id, hashes, maxRequestSize); err != nil {
+			peer.Log().Debug("Failed to request bytecodes", "err", err)
+			s.revertLocked(req)
+		}
+	}
+}
+
+// revertLocked drops a request which could not be sent, making its tasks
+// available for retrieval again. The lock must be held by the caller.
+func (s *Syncer) revertLocked(req *request) {
+	req.timer.Stop()
+	delete(s.requests, req.id)
+	if _, ok := s.peers[req.peer]; ok {
+		s.idlers[req.peer] = struct{}{}
+	}
+	s.revertTasks(req)
+}
+
+// revertTasks unassigns the tasks of a failed or timed out request, making
+// them available for retrieval again.
+func (s *Syncer) revertTasks(req *request) {
+	switch req.kind {
+	case accountRequest:
+		if req.task.req == req {
+			req.task.req = nil
+		}
+	case storageRequest:
+		for _, account := range req.accounts {
+			if task := s.storageTasks[account]; task != nil && task.req == req {
+				task.req = nil
+			}
+		}
+	case bytecodeRequest:
+		for _, hash := range req.hashes {
+			if task := s.codeTasks[hash]; task != nil && task.req == req {
+				task.req = nil
+			}
+		}
+	}
+}
+
+// markStateless flags a peer as not having (or not willing to serve) the state
+// currently being synced, excluding it from further requests until the root
+// changes.
+func (s *Syncer) markStateless(peer string, reason string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	log.Debug("Peer cannot serve snapshot state", "peer", peer, "root", s.root, "reason", reason)
+	s.stateless[peer] = struct{}{}
+}
+
+// processAccountResponse verifies a delivered account range and schedules the
+// retrieval of the storage tries and codes of its accounts.
+func (s *Syncer) processAccountResponse(res *response) {
+	req := res.req
+	if req.task.req == req {
+		req.task.req = nil
+	}
+	if res.timeout {
+		return
+	}
+	// An empty response without proofs signals that the peer doesn't have the
+	// requested state (or refuses to serve it)
+	if len(res.hashes) == 0 && len(res.proof) == 0 {
+		s.markStateless(req.peer, "empty account range")
+		return
+	}
+	// Ensure the range is valid against the state root
+	keys := make([][]byte, len(res.hashes))
+	for i, hash := range res.hashes {
+		keys[i] = common.CopyBytes(hash[:])
+	}
+	var end []byte
+	if len(keys) > 0 {
+		end = keys[len(keys)-1]
+	}
+	cont, err := trie.VerifyRangeProof(s.root, req.origin[:], end, keys, res.accounts, proofDatabase(res.proof))
+	if err != nil {
+		s.markStateless(req.peer, fmt.Sprintf("invalid account range: %v", err))
+		return
+	}
+	// Drop any accounts beyond the limit of the task, which are already part of
+	// the next chunk
+	task := req.task
+	for i, hash := range res.hashes {
+		if cmp := bytes.Compare(hash[:], task.last[:]); cmp >= 0 {
+			if cmp > 0 {
+				res.hashes, res.accounts = res.hashes[:i], res.accounts[:i]
+			}
+			cont = false
+			break
+		}
+	}
+	batch := &accountBatch{
+		task:     task,
+		hashes:   res.hashes,
+		accounts: res.accounts,
+		cont:     cont,
+	}
+	// Schedule the retrieval of any storage trie and code not yet known locally
+	for i, blob := range res.accounts {
+		var account state.Account
+		if err := rlp.DecodeBytes(blob, &account); err != nil {
+			s.markStateless(req.peer, fmt.Sprintf("invalid account: %v", err))
+			return
+		}
+		if codeHash := common.BytesToHash(account.CodeHash); codeHash != emptyCode {
+			if ok, _ := s.db.Has(codeHash[:]); !ok {
+				if s.codeTasks[codeHash] == nil {
+					s.codeTasks[codeHash] = new(codeTask)
+				}
+				s.codeTasks[codeHash].batches = append(s.codeTasks[codeHash].batches, batch)
+				batch.pending++
+			}
+		}
+		if account.Root != emptyRoot {
+			if ok, _ := s.db.Has(account.Root[:]); !ok {
+				tr, _ := trie.New(common.Hash{}, s.triedb)
+				s.storageTasks[res.hashes[i]] = &storageTask{
+					batch: batch,
+					root:  account.Root,
+					trie:  tr,
+				}
+				batch.pending++
+			}
+		}
+	}
+	s.accountSynced += uint64(len(res.hashes))
+
+	task.batch = batch
+	if batch.pending == 0 {
+		s.commitBatch(batch)
+	}
+}
+
+// processStorageResponse verifies the delivered storage ranges, committing any
+// storage tries completed by them.
+func (s *Syncer) processStorageResponse(res *response) {
+	req := res.req
+	if res.timeout {
+		s.revertTasks(req)
+		return
+	}
+	// An empty response without proofs signals that the peer doesn't have the
+	// requested state (or refuses to serve it)
+	if len(res.slotKeys) == 0 && len(res.proof) == 0 {
+		s.revertTasks(req)
+		s.markStateless(req.peer, "empty storage ranges")
+		return
+	}
+	if len(res.slotKeys) > len(req.accounts) {
+		s.revertTasks(req)
+		s.markStateless(req.peer, "too many storage ranges")
+		return
+	}
+	for i, account := range req.accounts {
+		task := s.storageTasks[account]
+		if task == nil || task.req != req {
+			continue
+		}
+		task.req = nil
+
+		// Any accounts not delivered are simply retried later
+		if i >= len(res.slotKeys) {
+			continue
+		}
+		keys := make([][]byte, len(res.slotKeys[i]))
+		for j, hash := range res.slotKeys[i] {
+			keys[j] = common.CopyBytes(hash[:])
+		}
+		// All ranges but the last must be complete storage tries, the last one
+		// is proven against its edges if it's only partial
+		var (
+			cont bool
+			err  error
+		)
+		if i == len(res.slotKeys)-1 && len(res.proof) > 0 {
+			var end []byte
+			if len(keys) > 0 {
+				end = keys[len(keys)-1]
+			}
+			cont, err = trie.VerifyRangeProof(task.root, task.next[:], end, keys, res.slots[i], proofDatabase(res.proof))
+		} else {
+			cont, err = trie.VerifyRangeProof(task.root, nil, nil, keys, res.slots[i], nil)
+		}
+		if err != nil {
+			// Retry the rest of the request with other peers
+			s.revertTasks(req)
+			s.markStateless(req.peer, fmt.Sprintf("invalid storage range: %v", err))
+			return
+		}
+		for j, key := range keys {
+			task.trie.Update(key, res.slots[i][j])
+		}
+		s.storageSynced += uint64(len(keys))
+
+		if cont {
+			task.next = incHash(common.BytesToHash(keys[len(keys)-1]))
+			continue
+		}
+		// Storage trie complete, persist it and release the owning account
+		root, err := task.trie.Commit(nil)
+		if err == nil && root == task.root {
+			err = s.commitTrie(root, &s.storageBytes)
+		} else if err == nil {
+			err = fmt.Errorf("storage root mismatch: have %x, want %x", root, task.root)
+		}
+		if err != nil {
+			log.Error("Failed to commit storage trie", "account", account, "err", err)
+		}
+		delete(s.storageTasks, account)
+		s.releaseBatch(task.batch)
+	}
+}
+
+// processBytecodeResponse stores the delivered contract codes, releasing the
+// accounts waiting on them.
+func (s *Syncer) processBytecodeResponse(res *response) {
+	req := res.req
+	if res.timeout {
+		s.revertTasks(req)
+		return
+	}
+	if len(res.codes) == 0 {
+		s.revertTasks(req)
+		s.markStateless(req.peer, "empty bytecodes")
+		return
+	}
+	// Match the delivered codes with the requested hashes, ignoring anything
+	// unrequested
+	requested := make(map[common.Hash]struct{}, len(req.hashes))
+	for _, hash := range req.hashes {
+		requested[hash] = struct{}{}
+	}
+	var (
+		batch    = s.db.NewBatch()
+		released []*accountBatch
+	)
+	for _, code := range res.codes {
+		hash := crypto.Keccak256Hash(code)
+		if _, ok := requested[hash]; !ok {
+			continue
+		}
+		delete(requested, hash)
+
+		task := s.codeTasks[hash]
+		if task == nil || task.req != req {
+			continue
+		}
+		batch.Put(hash[:], code)
+		s.bytecodeSynced++
+		s.bytecodeBytes += common.StorageSize(len(code))
+
+		delete(s.codeTasks, hash)
+		released = append(released, task.batches...)
+	}
+	if err := batch.Write(); err != nil {
+		log.Error("Failed to persist bytecodes", "err", err)
+		return
+	}
+	// Any codes not delivered are simply retried later
+	s.revertTasks(req)
+
+	// Release the accounts waiting on the now persisted codes
+	for _, waiting := range released {
+		s.releaseBatch(waiting)
+	}
+}
+
+// releaseBatch marks one of the storage tries or codes a batch is waiting on as
+// retrieved, committing the batch if nothing else is missing.
+func (s *Syncer) releaseBatch(batch *accountBatch) {
+	// Ignore batches dropped by a new sync cycle
+	if batch.task.batch != batch {
+		return
+	}
+	if batch.pending--; batch.pending == 0 {
+		s.commitBatch(batch)
+	}
+}
+
+// commitBatch inserts the accounts of a completed batch into the task's trie
+// and persists it, moving the task forward.
+func (s *Syncer) commitBatch(batch *accountBatch) {
+	task := batch.task
+	for i, hash := range batch.hashes {
+		task.trie.Update(hash[:], batch.accounts[i])
+	}
+	root, err := task.trie.Commit(nil)
+	if err == nil {
+		err = s.commitTrie(root, &s.accountBytes)
+	}
+	if err != nil {
+		log.Error("Failed to commit account trie", "err", err)
+		// Leave the task as is, the batch will be retried
+		task.batch = nil
+		return
+	}
+	task.batch = nil
+	if !batch.cont || len(batch.hashes) == 0 {
+		task.done = true
+		return
+	}
+	task.next = incHash(batch.hashes[len(batch.hashes)-1])
+}
+
+// commitTrie flushes a trie committed into the trie database out to disk,
+// accumulating the written size into the given counter.
+func (s *Syncer) commitTrie(root common.Hash, size *common.StorageSize) error {
+	if root == emptyRoot {
+		return nil
+	}
+	before, _ := s.triedb.Size()
+	if err := s.triedb.Commit(root, false); err != nil {
+		return err
+	}
+	after, _ := s.triedb.Size()
+	if before > after {
+		*size += before - after
+	}
+	return nil
+}
+
+// OnAccounts is a callback method to invoke when a range of accounts are
+// received from a remote peer.
+func (s *Syncer) OnAccounts(peer SyncPeer, id uint64, hashes []common.Hash, accounts [][]byte, proof [][]byte) error {
+	if len(hashes) != len(accounts) {
+		return errors.New("account hashes and bodies mismatch")
+	}
+	req := s.take(peer, id, accountRequest)
+	if req == nil {
+		return nil
+	}
+	s.deliver(req, &response{req: req, hashes: hashes, accounts: accounts, proof: proof})
+	return nil
+}
+
+// OnStorage is a callback method to invoke when ranges of storage slots
+// are received from a remote peer.
+func (s *Syncer) OnStorage(peer SyncPeer, id uint64, hashes [][]common.Hash, slots [][][]byte, proof [][]byte) error {
+	if len(hashes) != len(slots) {
+		return errors.New("storage hashes and slots mismatch")
+	}
+	for i := range hashes {
+		if len(hashes[i]) != len(slots[i]) {
+			return errors.New("storage hashes and slots mismatch")
+		}
+	}
+	req := s.take(peer, id, storageRequest)
+	if req == nil {
+		return nil
+	}
+	s.deliver(req, &response{req: req, slotKeys: hashes, slots: slots, proof: proof})
+	return nil
+}
+
+// OnByteCodes is a callback method to invoke when a batch of contract
+// bytes codes are received from a remote peer.
+func (s *Syncer) OnByteCodes(peer SyncPeer, id uint64, codes [][]byte) error {
+	req := s.take(peer, id, bytecodeRequest)
+	if req == nil {
+		return nil
+	}
+	s.deliver(req, &response{req: req, codes: codes})
+	return nil
+}
+
+// take retrieves and removes a pending request of the given peer and kind,
+// returning nil if no such request is pending (e.g. it already timed out).
+func (s *Syncer) take(peer SyncPeer, id uint64, kind requestKind) *request {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	req := s.requests[id]
+	if req == nil || req.peer != peer.ID() || req.kind != kind {
+		peer.Log().Debug("Unrequested snap response", "reqid", id)
+		return nil
+	}
+	req.timer.Stop()
+	delete(s.requests, id)
+	return req
+}
+
+// report prints the current sync progress, rate limited to once every few
+// seconds unless forced.
+func (s *Syncer) report(force bool) {
+	if !force && time.Since(s.logTime) < 8*time.Second {
+		return
+	}
+	s.logTime = time.Now()
+
+	// Estimate the progress from the position of the account tasks, assuming
+	// the accounts are uniformly spread across the hash space
+	synced := new(big.Int)
+	for _, task := range s.tasks {
+		end := task.next
+		if task.done {
+			end = incHash(task.last)
+		}
+		synced.Add(synced, new(big.Int).Sub(end.Big(), task.first.Big()))
+	}
+	if task := s.tasks[len(s.tasks)-1]; task.done {
+		// The last interval wraps around when incremented, account for it
+		synced.Add(synced, new(big.Int).Exp(common.Big2, common.Big256, nil))
+	}
+	progress, _ := new(big.Float).Quo(new(big.Float).SetInt(synced), new(big.Float).SetInt(new(big.Int).Exp(common.Big2, common.Big256, nil))).Float64()
+
+	log.Info("State sync in progress", "synced", fmt.Sprintf("%.2f%%", progress*100),
+		"accounts", s.accountSynced, "accountbytes", s.accountBytes,
+		"slots", s.storageSynced, "storagebytes", s.storageBytes,
+		"codes", s.bytecodeSynced, "codebytes", s.bytecodeBytes,
+		"elapsed", common.PrettyDuration(time.Since(s.startTime)))
+}
+
+// proofDatabase assembles a list of proof nodes into a database keyed by the
+// node hashes, which is what the range verification expects.
+func proofDatabase(proof [][]byte) *ethdb.MemDatabase {
+	db := ethdb.NewMemDatabase()
+	for _, node := range proof {
+		db.Put(crypto.Keccak256(node), node)
+	}
+	return db
+}
+
+// incHash returns the next hash, in lexicographical order (a.k.a plus one).
+func incHash(h common.Hash) common.Hash {
+	for i := len(h) - 1; i >= 0; i-- {
+		h[i]++
+		if h[i] != 0 {
+			break
+		}
+	}
+	return h
+}
@@ -0,0 +1,245 @@
+// Copyright 2019 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package snap
+
+import (
+	"fmt"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/athofficial/go-ath/common"
+	"github.com/athofficial/go-ath/core/state"
+	"github.com/athofficial/go-ath/crypto"
+	"github.com/athofficial/go-ath/ethdb"
+	"github.com/athofficial/go-ath/log"
+	"github.com/athofficial/go-ath/trie"
+)
+
+// testBackend serves snap requests from a state database.
+type testBackend struct {
+	db state.Database
+}
+
+func (b *testBackend) StateCache() state.Database { return b.db }
+
+// testPeer is a mock snap peer serving requests directly from a backend,
+// delivering the responses to the syncer asynchronously.
+type testPeer struct {
+	id      string
+	backend Backend
+	syncer  *Syncer
+	logger  log.Logger
+
+	stateless bool // Whether to reply with empty responses to all requests
+}
+
+func newTestPeer(id string, backend Backend, syncer *Syncer) *testPeer {
+	return &testPeer{
+		id:      id,
+		backend: backend,
+		syncer:  syncer,
+		logger:  log.New("id", id),
+	}
+}
+
+func (p *testPeer) ID() string      { return p.id }
+func (p *testPeer) Log() log.Logger { return p.logger }
+
+func (p *testPeer) RequestAccountRange(id uint64, root, origin, limit common.Hash, bytes uint64) error {
+	go func() {
+		var (
+			accounts []*accountData
+			proof    [][]byte
+		)
+		if !p.stateless {
+			accounts, proof = serviceGetAccountRange(p.backend, &getAccountRangeData{id, root, origin, limit, bytes})
+		}
+		res := &accountRangeData{ID: id, Accounts: accounts, Proof: proof}
+		hashes, bodies := res.Unpack()
+		p.syncer.OnAccounts(p, id, hashes, bodies, proof)
+	}()
+	return nil
+}
+
+func (p *testPeer) RequestStorageRanges(id uint64, root common.Hash, accounts []common.Hash, origin, limit []byte, bytes uint64) error {
+	go func() {
+		var (
+			slots [][]*storageData
+			proof [][]byte
+		)
+		if !p.stateless {
+			slots, proof = serviceGetStorageRanges(p.backend, &getStorageRangesData{id, root, accounts, origin, limit, bytes})
+		}
+		res := &storageRangesData{ID: id, Slots: slots, Proof: proof}
+		hashes, values := res.Unpack()
+		p.syncer.OnStorage(p, id, hashes, values, proof)
+	}()
+	return nil
+}
+
+func (p *testPeer) RequestByteCodes(id uint64, hashes []common.Hash, bytes uint64) error {
+	go func() {
+		var codes [][]byte
+		if !p.stateless {
+			codes = serviceGetByteCodes(p.backend, &getByteCodesData{id, hashes, bytes})
+		}
+		p.syncer.OnByteCodes(p, id, codes)
+	}()
+	return nil
+}
+
+// makeTestState creates a state with the given number of accounts, every
+// third of them having some storage slots and every fifth a contract code.
+func makeTestState(accounts int, slots int) (state.Database, common.Hash) {
+	db := state.NewDatabase(ethdb.NewMemDatabase())
+	statedb, _ := state.New(common.Hash{}, db)
+
+	for i := 0; i < accounts; i++ {
+		addr := common.BigToAddress(big.NewInt(int64(i + 1)))
+		statedb.AddBalance(addr, big.NewInt(int64(i+1)))
+		statedb.SetNonce(addr, uint64(i))
+		if i%3 == 0 {
+			for j := 0; j < slots; j++ {
+				statedb.SetState(addr, crypto.Keccak256Hash([]byte(fmt.Sprintf("%d-%d", i, j))), common.BigToHash(big.NewInt(int64(j+1))))
+			}
+		}
+		if i%5 == 0 {
+			statedb.SetCode(addr, []byte(fmt.Sprintf("code-%d", i)))
+		}
+	}
+	root, _ := statedb.Commit(false)
+	db.TrieDB().Commit(root, false)
+	return db, root
+}
+
+// heal runs a node by node trie sync to fill the gaps left by the snap sync,
+// returning the number of nodes that needed to be retrieved.
+func heal(t *testing.T, src state.Database, dst ethdb.Database, root common.Hash) int {
+	var (
+		sched  = state.NewStateSync(root, dst)
+		healed int
+	)
+	for sched.Pending() > 0 {
+		var results []trie.SyncResult
+		for _, hash := range sched.Missing(128) {
+			data, err := src.TrieDB().Node(hash)
+			if err != nil {
+				t.Fatalf("failed to retrieve node %x: %v", hash, err)
+			}
+			results = append(results, trie.SyncResult{Hash: hash, Data: data})
+		}
+		if _, index, err := sched.Process(results); err != nil {
+			t.Fatalf("failed to process result #%d: %v", index, err)
+		}
+		if _, err := sched.Commit(dst); err != nil {
+			t.Fatalf("failed to commit data: %v", err)
+		}
+		healed += len(results)
+	}
+	return healed
+}
+
+// checkState iterates over every node of the synced state, ensuring nothing
+// is missing.
+func checkState(t *testing.T, db ethdb.Database, root common.Hash) int {
+	statedb, err := state.New(root, state.NewDatabase(db))
+	if err != nil {
+		t.Fatalf("failed to open synced state: %v", err)
+	}
+	nodes := 0
+	it := state.NewNodeIterator(statedb)
+	for it.Next() {
+		nodes++
+	}
+	if it.Error != nil {
+		t.Fatalf("synced state inconsistent: %v", it.Error)
+	}
+	return nodes
+}
+
+// Tests that a state can be synced via snap ranges, leaving only a small number
+// of trie nodes to be healed.
+func TestSync(t *testing.T) {
+	src, root := makeDefaultTestState()
+	dst := ethdb.NewMemDatabase()
+
+	syncer := NewSyncer(dst)
+	syncer.Register(newTestPeer("peer-1", &testBackend{src}, syncer))
+	syncer.Register(newTestPeer("peer-2", &testBackend{src}, syncer))
+
+	if err := syncSnap(syncer, root); err != nil {
+		t.Fatalf("sync failed: %v", err)
+	}
+	healed := heal(t, src, dst, root)
+	total := checkState(t, dst, root)
+	if healed*2 > total {
+		t.Errorf("too many nodes healed: %d of %d", healed, total)
+	}
+}
+
+// Tests that peers not serving the requested state are skipped in favour of
+// ones that do.
+func TestSyncWithStatelessPeer(t *testing.T) {
+	src, root := makeDefaultTestState()
+	dst := ethdb.NewMemDatabase()
+
+	syncer := NewSyncer(dst)
+	stateless := newTestPeer("stateless", &testBackend{src}, syncer)
+	stateless.stateless = true
+	syncer.Register(stateless)
+	syncer.Register(newTestPeer("full", &testBackend{src}, syncer))
+
+	if err := syncSnap(syncer, root); err != nil {
+		t.Fatalf("sync failed: %v", err)
+	}
+	heal(t, src, dst, root)
+	checkState(t, dst, root)
+}
+
+// Tests that a sync can be cancelled, and that a later cycle resumes it.
+func TestSyncCancelResume(t *testing.T) {
+	src, root := makeDefaultTestState()
+	dst := ethdb.NewMemDatabase()
+
+	syncer := NewSyncer(dst)
+	cancel := make(chan struct{})
+	close(cancel)
+	if err := syncer.Sync(root, cancel); err != ErrCancelled {
+		t.Fatalf("cancelled sync error mismatch: have %v, want %v", err, ErrCancelled)
+	}
+	syncer.Register(newTestPeer("peer", &testBackend{src}, syncer))
+	if err := syncSnap(syncer, root); err != nil {
+		t.Fatalf("sync failed: %v", err)
+	}
+	heal(t, src, dst, root)
+	checkState(t, dst, root)
+}
+
+// makeDefaultTestState creates the test state shared by the sync tests.
+func makeDefaultTestState() (state.Database, common.Hash) {
+	return makeTestState(1000, 100)
+}
+
+// syncSnap runs a snap sync cycle, failing it if it doesn't complete in time.
+func syncSnap(syncer *Syncer, root common.Hash) error {
+	cancel := make(chan struct{})
+	timer := time.AfterFunc(20*time.Second, func() { close(cancel) })
+	defer timer.Stop()
+
+	return syncer.Sync(root, cancel)
+}
@@ -43,6 +43,7 @@ type txsync struct {
 }
 
 // syncTransactions starts sending all currently pending transactions to the given peer.
+// Peers supporting eth/65 only get the hashes announced and retrieve the ones they need.
 func (pm *ProtocolManager) syncTransactions(p *peer) {
 	var txs types.Transactions
 	pending, _ := pm.txpool.Pending()
@@ -52,6 +53,19 @@ func (pm *ProtocolManager) syncTransactions(p *peer) {
 	if len(txs) == 0 {
 		return
 	}
+	if p.version >= eth65 {
+		hashes := make([]common.Hash, 0, maxTxAnnounceBatch)
+		for _, tx := range txs {
+			if hashes = append(hashes, tx.Hash()); len(hashes) == maxTxAnnounceBatch {
+				p.AsyncSendPooledTransactionHashes(hashes)
+				hashes = make([]common.Hash, 0, maxTxAnnounceBatch)
+			}
+		}
+		if len(hashes) > 0 {
+			p.AsyncSendPooledTransactionHashes(hashes)
+		}
+		return
+	}
 	select {
 	case pm.txsyncCh <- &txsync{p, txs}:
 	case <-pm.quitSync:
@@ -179,6 +193,9 @@ func (pm *ProtocolManager) synchronise(peer *peer) {
 	if atomic.LoadUint32(&pm.fastSync) == 1 {
 		// Fast sync was explicitly requested, and explicitly granted
 		mode = downloader.FastSync
+		if atomic.LoadUint32(&pm.snapSync) == 1 {
+			mode = downloader.SnapSync
+		}
 	} else if currentBlock.NumberU64() == 0 && pm.blockchain.CurrentFastBlock().NumberU64() > 0 {
 		// The database seems empty as the current block is the genesis. Yet the fast
 		// block is ahead, so fast sync was enabled for this node at a certain point.
@@ -189,7 +206,7 @@ func (pm *ProtocolManager) synchronise(peer *peer) {
 		mode = downloader.FastSync
 	}
 
-	if mode == downloader.FastSync {
+	if mode == downloader.FastSync || mode == downloader.SnapSync {
 		// Make sure the peer's total difficulty we are synchronizing is higher.
 		if pm.blockchain.GetTdByHash(pm.blockchain.CurrentFastBlock().Hash()).Cmp(pTd) >= 0 {
 			return
@@ -0,0 +1,159 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package adminclient provides a client for the admin RPC API.
+package adminclient
+
+import (
+	"context"
+
+	"github.com/athofficial/go-ath"
+	"github.com/athofficial/go-ath/p2p"
+	"github.com/athofficial/go-ath/rpc"
+)
+
+// Client defines typed wrappers for the admin RPC API.
+type Client struct {
+	c *rpc.Client
+}
+
+// Dial connects a client to the given URL.
+func Dial(rawurl string) (*Client, error) {
+	return DialContext(context.Background(), rawurl)
+}
+
+// DialContext connects a client to the given URL with the given context.
+func DialContext(ctx context.Context, rawurl string) (*Client, error) {
+	c, err := rpc.DialContext(ctx, rawurl)
+	if err != nil {
+		return nil, err
+	}
+	return NewClient(c), nil
+}
+
+// NewClient creates a client that uses the given RPC client.
+func NewClient(c *rpc.Client) *Client {
+	return &Client{c}
+}
+
+// Close closes the underlying RPC connection.
+func (ac *Client) Close() {
+	ac.c.Close()
+}
+
+// Peer management
+
+// NodeInfo retrieves information about the node's p2p server.
+func (ac *Client) NodeInfo(ctx context.Context) (*p2p.NodeInfo, error) {
+	var result *p2p.NodeInfo
+	err := ac.c.CallContext(ctx, &result, "admin_nodeInfo")
+	return result, err
+}
+
+// Peers retrieves information about the connected remote nodes.
+func (ac *Client) Peers(ctx context.Context) ([]*p2p.PeerInfo, error) {
+	var result []*p2p.PeerInfo
+	err := ac.c.CallContext(ctx, &result, "admin_peers")
+	return result, err
+}
+
+// AddPeer requests connecting to a remote node given by its enode URL, and
+// maintaining the connection at all times.
+func (ac *Client) AddPeer(ctx context.Context, url string) error {
+	return ac.c.CallContext(ctx, nil, "admin_addPeer", url)
+}
+
+// RemovePeer disconnects from a remote node given by its enode URL.
+func (ac *Client) RemovePeer(ctx context.Context, url string) error {
+	return ac.c.CallContext(ctx, nil, "admin_removePeer", url)
+}
+
+// AddTrustedPeer allows a remote node to always connect, even if slots are full.
+func (ac *Client) AddTrustedPeer(ctx context.Context, url string) error {
+	return ac.c.CallContext(ctx, nil, "admin_addTrustedPeer", url)
+}
+
+// RemoveTrustedPeer removes a remote node from the trusted peer set, without
+// disconnecting it.
+func (ac *Client) RemoveTrustedPeer(ctx context.Context, url string) error {
+	return ac.c.CallContext(ctx, nil, "admin_removeTrustedPeer", url)
+}
+
+// SubscribePeerEvents subscribes to the peer events of the node's p2p server on
+// the given channel.
+func (ac *Client) SubscribePeerEvents(ctx context.Context, ch chan<- *p2p.PeerEvent) (ethereum.Subscription, error) {
+	return ac.c.Subscribe(ctx, "admin", ch, "peerEvents")
+}
+
+// RPC endpoints
+
+// StartRPC starts the HTTP RPC endpoint of the node. Empty or zero arguments
+// leave the node's configured values in place.
+func (ac *Client) StartRPC(ctx context.Context, host string, port int, cors, apis, vhosts string) error {
+	return ac.c.CallContext(ctx, nil, "admin_startRPC", optString(host), optInt(port), optString(cors), optString(apis), optString(vhosts))
+}
+
+// StopRPC stops the HTTP RPC endpoint of the node.
+func (ac *Client) StopRPC(ctx context.Context) error {
+	return ac.c.CallContext(ctx, nil, "admin_stopRPC")
+}
+
+// StartWS starts the WebSocket RPC endpoint of the node. Empty or zero arguments
+// leave the node's configured values in place.
+func (ac *Client) StartWS(ctx context.Context, host string, port int, origins, apis string) error {
+	return ac.c.CallContext(ctx, nil, "admin_startWS", optString(host), optInt(port), optString(origins), optString(apis))
+}
+
+// StopWS stops the WebSocket RPC endpoint of the node.
+func (ac *Client) StopWS(ctx context.Context) error {
+	return ac.c.CallContext(ctx, nil, "admin_stopWS")
+}
+
+// Chain management
+
+// Datadir retrieves the data directory of the node.
+func (ac *Client) Datadir(ctx context.Context) (string, error) {
+	var result string
+	err := ac.c.CallContext(ctx, &result, "admin_datadir")
+	return result, err
+}
+
+// ExportChain exports the canonical chain into a file on the node's host. The
+// file is gzipped if its name ends in ".gz".
+func (ac *Client) ExportChain(ctx context.Context, file string) error {
+	return ac.c.CallContext(ctx, nil, "admin_exportChain", file)
+}
+
+// ImportChain imports blocks from a file on the node's host.
+func (ac *Client) ImportChain(ctx context.Context, file string) error {
+	return ac.c.CallContext(ctx, nil, "admin_importChain", file)
+}
+
+// optString converts an empty string into a missing argument.
+func optString(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return &s
+}
+
+// optInt converts a zero integer into a missing argument.
+func optInt(n int) *int {
+	if n == 0 {
+		return nil
+	}
+	return &n
+}
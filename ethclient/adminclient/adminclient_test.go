@@ -0,0 +1,119 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package adminclient
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/athofficial/go-ath/crypto"
+	"github.com/athofficial/go-ath/ethclient/internal/testnode"
+	"github.com/athofficial/go-ath/node"
+	"github.com/athofficial/go-ath/p2p"
+	"github.com/athofficial/go-ath/p2p/enode"
+)
+
+func newTestClient(t *testing.T, stack *node.Node) *Client {
+	rpcclient, err := stack.Attach()
+	if err != nil {
+		t.Fatalf("failed to attach to node: %v", err)
+	}
+	return NewClient(rpcclient)
+}
+
+func TestPeers(t *testing.T) {
+	stack, _, _ := testnode.New(t, 0)
+	defer stack.Stop()
+
+	client := newTestClient(t, stack)
+	defer client.Close()
+
+	ctx := context.Background()
+
+	info, err := client.NodeInfo(ctx)
+	if err != nil {
+		t.Fatalf("failed to retrieve node info: %v", err)
+	}
+	if self := stack.Server().Self(); info.ID != self.ID().String() || info.Enode != self.String() {
+		t.Errorf("node info mismatch: have %s (%s), want %s (%s)", info.ID, info.Enode, self.ID(), self)
+	}
+	if _, ok := info.Protocols["eth"]; !ok {
+		t.Errorf("eth protocol missing from node info: %v", info.Protocols)
+	}
+	peers, err := client.Peers(ctx)
+	if err != nil {
+		t.Fatalf("failed to retrieve peers: %v", err)
+	}
+	if len(peers) != 0 {
+		t.Errorf("peer count mismatch: have %d, want 0", len(peers))
+	}
+	// Add and remove a static peer
+	key, _ := crypto.GenerateKey()
+	url := enode.NewV4(&key.PublicKey, []byte{127, 0, 0, 1}, 30303, 30303).String()
+	if err := client.AddPeer(ctx, url); err != nil {
+		t.Errorf("failed to add peer: %v", err)
+	}
+	if err := client.RemovePeer(ctx, url); err != nil {
+		t.Errorf("failed to remove peer: %v", err)
+	}
+	if err := client.AddTrustedPeer(ctx, "enode://invalid"); err == nil {
+		t.Errorf("invalid trusted peer accepted")
+	}
+	// Subscribe to the peer events
+	events := make(chan *p2p.PeerEvent)
+	sub, err := client.SubscribePeerEvents(ctx, events)
+	if err != nil {
+		t.Fatalf("failed to subscribe to peer events: %v", err)
+	}
+	sub.Unsubscribe()
+}
+
+func TestExportChain(t *testing.T) {
+	stack, ethservice, _ := testnode.New(t, 0)
+	defer stack.Stop()
+
+	client := newTestClient(t, stack)
+	defer client.Close()
+
+	dir, err := ioutil.TempDir("", "adminclient-test")
+	if err != nil {
+		t.Fatalf("failed to create temporary directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	ctx := context.Background()
+	file := filepath.Join(dir, "chain.rlp")
+
+	if err := client.ExportChain(ctx, file); err != nil {
+		t.Fatalf("failed to export chain: %v", err)
+	}
+	if stat, err := os.Stat(file); err != nil || stat.Size() == 0 {
+		t.Fatalf("chain not exported: %v", err)
+	}
+	if err := client.ImportChain(ctx, file); err != nil {
+		t.Errorf("failed to import chain: %v", err)
+	}
+	if head := ethservice.BlockChain().CurrentBlock().NumberU64(); head != 0 {
+		t.Errorf("head mismatch: have %d, want 0", head)
+	}
+	if err := client.ImportChain(ctx, filepath.Join(dir, "missing.rlp")); err == nil {
+		t.Errorf("missing chain file imported")
+	}
+}
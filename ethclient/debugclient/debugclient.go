@@ -0,0 +1,213 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package debugclient provides a client for the debug RPC API.
+package debugclient
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"math/big"
+
+	"github.com/athofficial/go-ath/common"
+	"github.com/athofficial/go-ath/common/hexutil"
+	"github.com/athofficial/go-ath/core/state"
+	"github.com/athofficial/go-ath/eth"
+	"github.com/athofficial/go-ath/internal/ethapi"
+	"github.com/athofficial/go-ath/rpc"
+)
+
+// ExecutionResult is the trace of a transaction produced by the default struct
+// logger.
+type ExecutionResult = ethapi.ExecutionResult
+
+// StructLogRes is a single step of an execution trace.
+type StructLogRes = ethapi.StructLogRes
+
+// RevertResult is the outcome of a transaction along with its revert reason.
+type RevertResult = ethapi.RevertResult
+
+// TxTraceResult is the trace of a single transaction of a block produced by the
+// default struct logger.
+type TxTraceResult struct {
+	Result *ExecutionResult `json:"result,omitempty"` // Trace results produced by the logger
+	Error  string           `json:"error,omitempty"`  // Trace failure produced by the logger
+}
+
+// errCustomTracer is returned if a struct logger trace is requested with a
+// custom tracer configured.
+var errCustomTracer = errors.New("custom tracer configured, use TraceTransactionWithTracer")
+
+// Client defines typed wrappers for the debug RPC API.
+type Client struct {
+	c *rpc.Client
+}
+
+// Dial connects a client to the given URL.
+func Dial(rawurl string) (*Client, error) {
+	return DialContext(context.Background(), rawurl)
+}
+
+// DialContext connects a client to the given URL with the given context.
+func DialContext(ctx context.Context, rawurl string) (*Client, error) {
+	c, err := rpc.DialContext(ctx, rawurl)
+	if err != nil {
+		return nil, err
+	}
+	return NewClient(c), nil
+}
+
+// NewClient creates a client that uses the given RPC client.
+func NewClient(c *rpc.Client) *Client {
+	return &Client{c}
+}
+
+// Close closes the underlying RPC connection.
+func (dc *Client) Close() {
+	dc.c.Close()
+}
+
+// Tracing
+
+// TraceTransaction replays a transaction with the default struct logger and
+// returns its execution trace. The config may be nil.
+func (dc *Client) TraceTransaction(ctx context.Context, hash common.Hash, config *eth.TraceConfig) (*ExecutionResult, error) {
+	if config != nil && config.Tracer != nil {
+		return nil, errCustomTracer
+	}
+	var result *ExecutionResult
+	err := dc.c.CallContext(ctx, &result, "debug_traceTransaction", hash, config)
+	return result, err
+}
+
+// TraceTransactionWithTracer replays a transaction with the custom tracer set
+// in the config and returns its raw output.
+func (dc *Client) TraceTransactionWithTracer(ctx context.Context, hash common.Hash, config *eth.TraceConfig) (json.RawMessage, error) {
+	var result json.RawMessage
+	err := dc.c.CallContext(ctx, &result, "debug_traceTransaction", hash, config)
+	return result, err
+}
+
+// TraceBlockByNumber replays all transactions of a canonical block with the
+// default struct logger and returns their execution traces.
+func (dc *Client) TraceBlockByNumber(ctx context.Context, number *big.Int, config *eth.TraceConfig) ([]*TxTraceResult, error) {
+	if config != nil && config.Tracer != nil {
+		return nil, errCustomTracer
+	}
+	var result []*TxTraceResult
+	err := dc.c.CallContext(ctx, &result, "debug_traceBlockByNumber", toBlockNumArg(number), config)
+	return result, err
+}
+
+// TraceBlockByHash replays all transactions of a block with the default struct
+// logger and returns their execution traces.
+func (dc *Client) TraceBlockByHash(ctx context.Context, hash common.Hash, config *eth.TraceConfig) ([]*TxTraceResult, error) {
+	if config != nil && config.Tracer != nil {
+		return nil, errCustomTracer
+	}
+	var result []*TxTraceResult
+	err := dc.c.CallContext(ctx, &result, "debug_traceBlockByHash", hash, config)
+	return result, err
+}
+
+// GetRevertReason replays a transaction and returns whether it failed, along
+// with the reason it reverted with, if any.
+func (dc *Client) GetRevertReason(ctx context.Context, hash common.Hash) (*RevertResult, error) {
+	var result *RevertResult
+	err := dc.c.CallContext(ctx, &result, "debug_getRevertReason", hash)
+	return result, err
+}
+
+// State inspection
+
+// DumpBlock retrieves the entire state at the given block. A nil number dumps
+// the latest state.
+func (dc *Client) DumpBlock(ctx context.Context, number *big.Int) (state.Dump, error) {
+	var result state.Dump
+	err := dc.c.CallContext(ctx, &result, "debug_dumpBlock", toBlockNumArg(number))
+	return result, err
+}
+
+// StorageRangeAt returns up to max storage slots of a contract, starting with
+// the given key, as seen after executing the first txIndex transactions of a
+// block.
+func (dc *Client) StorageRangeAt(ctx context.Context, blockHash common.Hash, txIndex int, contract common.Address, keyStart []byte, max int) (eth.StorageRangeResult, error) {
+	var result eth.StorageRangeResult
+	err := dc.c.CallContext(ctx, &result, "debug_storageRangeAt", blockHash, txIndex, contract, hexutil.Bytes(keyStart), max)
+	return result, err
+}
+
+// GetModifiedAccountsByNumber returns the accounts modified between the two
+// blocks, excluding the start block. If end is nil, the accounts modified by
+// the start block are returned.
+func (dc *Client) GetModifiedAccountsByNumber(ctx context.Context, start uint64, end *uint64) ([]common.Address, error) {
+	var result []common.Address
+	err := dc.c.CallContext(ctx, &result, "debug_getModifiedAccountsByNumber", start, end)
+	return result, err
+}
+
+// GetModifiedAccountsByHash returns the accounts modified between the two
+// blocks, excluding the start block. If end is nil, the accounts modified by
+// the start block are returned.
+func (dc *Client) GetModifiedAccountsByHash(ctx context.Context, start common.Hash, end *common.Hash) ([]common.Address, error) {
+	var result []common.Address
+	err := dc.c.CallContext(ctx, &result, "debug_getModifiedAccountsByHash", start, end)
+	return result, err
+}
+
+// Preimage returns the preimage of a sha3 hash, if known.
+func (dc *Client) Preimage(ctx context.Context, hash common.Hash) ([]byte, error) {
+	var result hexutil.Bytes
+	err := dc.c.CallContext(ctx, &result, "debug_preimage", hash)
+	return result, err
+}
+
+// GetBadBlocks returns the last bad blocks the node has seen on the network.
+func (dc *Client) GetBadBlocks(ctx context.Context) ([]*eth.BadBlockArgs, error) {
+	var result []*eth.BadBlockArgs
+	err := dc.c.CallContext(ctx, &result, "debug_getBadBlocks")
+	return result, err
+}
+
+// Chain management
+
+// SetHead rewinds the local chain to the given block.
+func (dc *Client) SetHead(ctx context.Context, number uint64) error {
+	return dc.c.CallContext(ctx, nil, "debug_setHead", hexutil.Uint64(number))
+}
+
+// ChaindbProperty returns a leveldb property of the chain database.
+func (dc *Client) ChaindbProperty(ctx context.Context, property string) (string, error) {
+	var result string
+	err := dc.c.CallContext(ctx, &result, "debug_chaindbProperty", property)
+	return result, err
+}
+
+// Metrics retrieves the metrics collected by the node. If raw is set, the raw
+// metric values are returned instead of the aggregated ones.
+func (dc *Client) Metrics(ctx context.Context, raw bool) (map[string]interface{}, error) {
+	var result map[string]interface{}
+	err := dc.c.CallContext(ctx, &result, "debug_metrics", raw)
+	return result, err
+}
+
+func toBlockNumArg(number *big.Int) string {
+	if number == nil {
+		return "latest"
+	}
+	return hexutil.EncodeBig(number)
+}
@@ -0,0 +1,131 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package debugclient
+
+import (
+	"context"
+	"math/big"
+	"testing"
+
+	"github.com/athofficial/go-ath/common"
+	"github.com/athofficial/go-ath/eth"
+	"github.com/athofficial/go-ath/ethclient/internal/testnode"
+	"github.com/athofficial/go-ath/node"
+	"github.com/athofficial/go-ath/params"
+)
+
+func newTestClient(t *testing.T, stack *node.Node) *Client {
+	rpcclient, err := stack.Attach()
+	if err != nil {
+		t.Fatalf("failed to attach to node: %v", err)
+	}
+	return NewClient(rpcclient)
+}
+
+func TestTracing(t *testing.T) {
+	stack, _, blocks := testnode.New(t, 3)
+	defer stack.Stop()
+
+	client := newTestClient(t, stack)
+	defer client.Close()
+
+	ctx := context.Background()
+	tx := blocks[0].Transactions()[0]
+
+	// Trace a single transaction with the struct logger and a custom tracer
+	result, err := client.TraceTransaction(ctx, tx.Hash(), nil)
+	if err != nil {
+		t.Fatalf("failed to trace transaction: %v", err)
+	}
+	if result.Gas != params.TxGas || result.Failed {
+		t.Errorf("trace mismatch: have gas %d, failed %v, want gas %d, succeeded", result.Gas, result.Failed, params.TxGas)
+	}
+	tracer := `{step: function() {}, fault: function() {}, result: function() { return "done"; }}`
+	if _, err := client.TraceTransaction(ctx, tx.Hash(), &eth.TraceConfig{Tracer: &tracer}); err != errCustomTracer {
+		t.Errorf("custom tracer error mismatch: have %v, want %v", err, errCustomTracer)
+	}
+	raw, err := client.TraceTransactionWithTracer(ctx, tx.Hash(), &eth.TraceConfig{Tracer: &tracer})
+	if err != nil {
+		t.Fatalf("failed to trace transaction with custom tracer: %v", err)
+	}
+	if string(raw) != `"done"` {
+		t.Errorf("custom trace mismatch: have %s, want %q", raw, "done")
+	}
+	// Trace entire blocks
+	traces, err := client.TraceBlockByNumber(ctx, big.NewInt(2), nil)
+	if err != nil {
+		t.Fatalf("failed to trace block by number: %v", err)
+	}
+	if len(traces) != 1 || traces[0].Result == nil || traces[0].Result.Gas != params.TxGas {
+		t.Errorf("block trace mismatch: have %v", traces)
+	}
+	if traces, err = client.TraceBlockByHash(ctx, blocks[2].Hash(), nil); err != nil || len(traces) != 1 {
+		t.Errorf("block trace by hash mismatch: have %v, %v", traces, err)
+	}
+	// Retrieve the revert reason of the successful transaction
+	revert, err := client.GetRevertReason(ctx, tx.Hash())
+	if err != nil {
+		t.Fatalf("failed to retrieve revert reason: %v", err)
+	}
+	if revert.Failed || revert.Reason != "" {
+		t.Errorf("revert reason of succeeded transaction: %+v", revert)
+	}
+}
+
+func TestStateInspection(t *testing.T) {
+	stack, _, blocks := testnode.New(t, 3)
+	defer stack.Stop()
+
+	client := newTestClient(t, stack)
+	defer client.Close()
+
+	ctx := context.Background()
+
+	// Dump the latest state and check the transferred funds
+	dump, err := client.DumpBlock(ctx, nil)
+	if err != nil {
+		t.Fatalf("failed to dump state: %v", err)
+	}
+	if dump.Root != blocks[2].Root().Hex()[2:] {
+		t.Errorf("state root mismatch: have %s, want %x", dump.Root, blocks[2].Root())
+	}
+	if account, ok := dump.Accounts[common.Bytes2Hex(testnode.Target.Bytes())]; !ok || account.Balance != "3000" {
+		t.Errorf("target account mismatch: have %+v", account)
+	}
+	// Check the accounts modified by a block
+	modified, err := client.GetModifiedAccountsByNumber(ctx, 1, nil)
+	if err != nil {
+		t.Fatalf("failed to retrieve modified accounts: %v", err)
+	}
+	touched := make(map[common.Address]bool)
+	for _, addr := range modified {
+		touched[addr] = true
+	}
+	if !touched[testnode.Addr] || !touched[testnode.Target] {
+		t.Errorf("modified accounts mismatch: have %v", modified)
+	}
+	if byHash, err := client.GetModifiedAccountsByHash(ctx, blocks[0].Hash(), nil); err != nil || len(byHash) != len(modified) {
+		t.Errorf("modified accounts by hash mismatch: have %v, %v", byHash, err)
+	}
+	// Rewind the chain
+	if err := client.SetHead(ctx, 1); err != nil {
+		t.Fatalf("failed to set head: %v", err)
+	}
+	if dump, err = client.DumpBlock(ctx, nil); err != nil || dump.Root != blocks[0].Root().Hex()[2:] {
+		t.Errorf("state root mismatch after rewind: have %s, %v, want %x", dump.Root, err, blocks[0].Root())
+	}
+}
@@ -0,0 +1,89 @@
+// Copyright 2019 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package testnode provides the in-process Ethereum node the typed API clients
+// are tested against.
+package testnode
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/athofficial/go-ath/common"
+	"github.com/athofficial/go-ath/consensus/ubqhash"
+	"github.com/athofficial/go-ath/core"
+	"github.com/athofficial/go-ath/core/types"
+	"github.com/athofficial/go-ath/crypto"
+	"github.com/athofficial/go-ath/eth"
+	"github.com/athofficial/go-ath/node"
+	"github.com/athofficial/go-ath/p2p"
+	"github.com/athofficial/go-ath/params"
+)
+
+var (
+	Key, _  = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291") // Key of the funded account
+	Addr    = crypto.PubkeyToAddress(Key.PublicKey)                                                 // Account funded in the genesis block
+	Balance = big.NewInt(2e18)                                                                      // Genesis balance of Addr
+	Target  = common.HexToAddress("0x0000000000000000000000000000000000000b0b")                     // Recipient of the generated transfers
+)
+
+// New creates an in-process Ethereum node whose chain is extended with the given
+// number of blocks, each transferring 1000 wei from Addr to Target.
+func New(t *testing.T, blocks int) (*node.Node, *eth.Ethereum, []*types.Block) {
+	stack, err := node.New(&node.Config{
+		P2P: p2p.Config{
+			ListenAddr:  "127.0.0.1:0",
+			NoDiscovery: true,
+			MaxPeers:    0,
+		},
+	})
+	if err != nil {
+		t.Fatalf("failed to create node: %v", err)
+	}
+	config := eth.DefaultConfig
+	config.Genesis = &core.Genesis{
+		Config:     params.TestChainConfig,
+		GasLimit:   8000000,
+		Difficulty: big.NewInt(131072),
+		Alloc:      core.GenesisAlloc{Addr: {Balance: Balance}},
+	}
+	config.NetworkId = 1337
+	config.Ubqhash.PowMode = ubqhash.ModeFake
+
+	var ethservice *eth.Ethereum
+	if err := stack.Register(func(ctx *node.ServiceContext) (node.Service, error) {
+		ethservice, err = eth.New(ctx, &config)
+		return ethservice, err
+	}); err != nil {
+		t.Fatalf("failed to register eth service: %v", err)
+	}
+	if err := stack.Start(); err != nil {
+		t.Fatalf("failed to start node: %v", err)
+	}
+	if blocks == 0 {
+		return stack, ethservice, nil
+	}
+	signer := types.HomesteadSigner{}
+	chain, _ := core.GenerateChain(params.TestChainConfig, ethservice.BlockChain().Genesis(), ubqhash.NewFaker(), ethservice.ChainDb(), blocks, func(i int, b *core.BlockGen) {
+		tx, _ := types.SignTx(types.NewTransaction(b.TxNonce(Addr), Target, big.NewInt(1000), params.TxGas, big.NewInt(params.GWei), nil), signer, Key)
+		b.AddTx(tx)
+	})
+	if _, err := ethservice.BlockChain().InsertChain(chain); err != nil {
+		stack.Stop()
+		t.Fatalf("failed to import blocks: %v", err)
+	}
+	return stack, ethservice, chain
+}
@@ -0,0 +1,162 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package minerclient provides a client for the miner RPC API and the ubqhash
+// remote mining methods of the eth RPC API.
+package minerclient
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/athofficial/go-ath/common"
+	"github.com/athofficial/go-ath/common/hexutil"
+	"github.com/athofficial/go-ath/core/types"
+	"github.com/athofficial/go-ath/rpc"
+)
+
+// Work is a mining work package handed out to remote miners.
+type Work struct {
+	PowHash common.Hash // Proof-of-work hash of the block header
+	Seed    common.Hash // Seed hash of the DAG used to mine the block
+	Target  *big.Int    // Boundary the mix digest must satisfy, 2^256/difficulty
+	Number  uint64      // Number of the block being mined
+}
+
+// Client defines typed wrappers for the miner RPC API.
+type Client struct {
+	c *rpc.Client
+}
+
+// Dial connects a client to the given URL.
+func Dial(rawurl string) (*Client, error) {
+	return DialContext(context.Background(), rawurl)
+}
+
+// DialContext connects a client to the given URL with the given context.
+func DialContext(ctx context.Context, rawurl string) (*Client, error) {
+	c, err := rpc.DialContext(ctx, rawurl)
+	if err != nil {
+		return nil, err
+	}
+	return NewClient(c), nil
+}
+
+// NewClient creates a client that uses the given RPC client.
+func NewClient(c *rpc.Client) *Client {
+	return &Client{c}
+}
+
+// Close closes the underlying RPC connection.
+func (mc *Client) Close() {
+	mc.c.Close()
+}
+
+// Local mining
+
+// Start starts the miner with the given number of threads, or with as many as
+// logical CPUs if threads is zero.
+func (mc *Client) Start(ctx context.Context, threads int) error {
+	if threads == 0 {
+		return mc.c.CallContext(ctx, nil, "miner_start")
+	}
+	return mc.c.CallContext(ctx, nil, "miner_start", threads)
+}
+
+// Stop stops the miner.
+func (mc *Client) Stop(ctx context.Context) error {
+	return mc.c.CallContext(ctx, nil, "miner_stop")
+}
+
+// Mining reports whether the node is mining.
+func (mc *Client) Mining(ctx context.Context) (bool, error) {
+	var result bool
+	err := mc.c.CallContext(ctx, &result, "eth_mining")
+	return result, err
+}
+
+// Hashrate retrieves the combined hash rate of the local and remote miners.
+func (mc *Client) Hashrate(ctx context.Context) (uint64, error) {
+	var result hexutil.Uint64
+	err := mc.c.CallContext(ctx, &result, "eth_hashrate")
+	return uint64(result), err
+}
+
+// Coinbase retrieves the address mining rewards are paid to.
+func (mc *Client) Coinbase(ctx context.Context) (common.Address, error) {
+	var result common.Address
+	err := mc.c.CallContext(ctx, &result, "eth_coinbase")
+	return result, err
+}
+
+// SetEtherbase sets the address mining rewards are paid to.
+func (mc *Client) SetEtherbase(ctx context.Context, etherbase common.Address) error {
+	return mc.c.CallContext(ctx, nil, "miner_setEtherbase", etherbase)
+}
+
+// SetExtra sets the extra data included in the mined blocks.
+func (mc *Client) SetExtra(ctx context.Context, extra string) error {
+	return mc.c.CallContext(ctx, nil, "miner_setExtra", extra)
+}
+
+// SetGasPrice sets the minimum gas price of the transactions accepted by the
+// miner and the transaction pool.
+func (mc *Client) SetGasPrice(ctx context.Context, price *big.Int) error {
+	return mc.c.CallContext(ctx, nil, "miner_setGasPrice", (*hexutil.Big)(price))
+}
+
+// SetRecommitInterval sets the interval in which the miner recreates the block
+// being mined.
+func (mc *Client) SetRecommitInterval(ctx context.Context, interval time.Duration) error {
+	return mc.c.CallContext(ctx, nil, "miner_setRecommitInterval", int(interval/time.Millisecond))
+}
+
+// Remote mining
+
+// GetWork retrieves the work package remote miners should be mining on.
+func (mc *Client) GetWork(ctx context.Context) (*Work, error) {
+	var result [4]string
+	if err := mc.c.CallContext(ctx, &result, "eth_getWork"); err != nil {
+		return nil, err
+	}
+	number, err := hexutil.DecodeUint64(result[3])
+	if err != nil {
+		return nil, fmt.Errorf("invalid block number: %v", err)
+	}
+	return &Work{
+		PowHash: common.HexToHash(result[0]),
+		Seed:    common.HexToHash(result[1]),
+		Target:  common.HexToHash(result[2]).Big(),
+		Number:  number,
+	}, nil
+}
+
+// SubmitWork submits a proof-of-work solution, reporting whether it was accepted.
+func (mc *Client) SubmitWork(ctx context.Context, nonce types.BlockNonce, powHash, digest common.Hash) (bool, error) {
+	var result bool
+	err := mc.c.CallContext(ctx, &result, "eth_submitWork", nonce, powHash, digest)
+	return result, err
+}
+
+// SubmitHashrate reports the hash rate of a remote miner identified by id,
+// reporting whether it was accepted.
+func (mc *Client) SubmitHashrate(ctx context.Context, rate uint64, id common.Hash) (bool, error) {
+	var result bool
+	err := mc.c.CallContext(ctx, &result, "eth_submitHashRate", hexutil.Uint64(rate), id)
+	return result, err
+}
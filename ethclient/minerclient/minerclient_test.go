@@ -0,0 +1,96 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package minerclient
+
+import (
+	"context"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/athofficial/go-ath/common"
+	"github.com/athofficial/go-ath/core/types"
+	"github.com/athofficial/go-ath/ethclient/internal/testnode"
+	"github.com/athofficial/go-ath/node"
+	"github.com/athofficial/go-ath/params"
+)
+
+func newTestClient(t *testing.T, stack *node.Node) *Client {
+	rpcclient, err := stack.Attach()
+	if err != nil {
+		t.Fatalf("failed to attach to node: %v", err)
+	}
+	return NewClient(rpcclient)
+}
+
+func TestMinerSettings(t *testing.T) {
+	stack, ethservice, _ := testnode.New(t, 0)
+	defer stack.Stop()
+
+	client := newTestClient(t, stack)
+	defer client.Close()
+
+	ctx := context.Background()
+
+	if mining, err := client.Mining(ctx); err != nil || mining {
+		t.Errorf("mining status mismatch: have %v, %v, want false", mining, err)
+	}
+	etherbase := common.HexToAddress("0x0000000000000000000000000000000000000b0b")
+	if err := client.SetEtherbase(ctx, etherbase); err != nil {
+		t.Fatalf("failed to set etherbase: %v", err)
+	}
+	if coinbase, err := client.Coinbase(ctx); err != nil || coinbase != etherbase {
+		t.Errorf("coinbase mismatch: have %x, %v, want %x", coinbase, err, etherbase)
+	}
+	price := big.NewInt(7 * params.GWei)
+	if err := client.SetGasPrice(ctx, price); err != nil {
+		t.Fatalf("failed to set gas price: %v", err)
+	}
+	if have := ethservice.TxPool().GasPrice(); have.Cmp(price) != 0 {
+		t.Errorf("pool gas price mismatch: have %v, want %v", have, price)
+	}
+	if err := client.SetExtra(ctx, "typed"); err != nil {
+		t.Errorf("failed to set extra data: %v", err)
+	}
+	if err := client.SetExtra(ctx, string(make([]byte, params.MaximumExtraDataSize+1))); err == nil {
+		t.Errorf("oversized extra data accepted")
+	}
+	if err := client.SetRecommitInterval(ctx, 5*time.Second); err != nil {
+		t.Errorf("failed to set recommit interval: %v", err)
+	}
+}
+
+func TestRemoteMining(t *testing.T) {
+	stack, _, _ := testnode.New(t, 0)
+	defer stack.Stop()
+
+	client := newTestClient(t, stack)
+	defer client.Close()
+
+	ctx := context.Background()
+
+	// The fake ubqhash engine doesn't hand out work nor accept solutions
+	if _, err := client.GetWork(ctx); err == nil {
+		t.Errorf("work package retrieved from fake engine")
+	}
+	if ok, err := client.SubmitWork(ctx, types.BlockNonce{}, common.Hash{1}, common.Hash{2}); err != nil || ok {
+		t.Errorf("work submission mismatch: have %v, %v, want rejected", ok, err)
+	}
+	if ok, err := client.SubmitHashrate(ctx, 100, common.Hash{1}); err != nil || ok {
+		t.Errorf("hash rate submission mismatch: have %v, %v, want rejected", ok, err)
+	}
+}
@@ -0,0 +1,98 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package txpoolclient provides a client for the txpool RPC API.
+package txpoolclient
+
+import (
+	"context"
+
+	"github.com/athofficial/go-ath/common"
+	"github.com/athofficial/go-ath/common/hexutil"
+	"github.com/athofficial/go-ath/internal/ethapi"
+	"github.com/athofficial/go-ath/rpc"
+)
+
+// RPCTransaction is a transaction as represented by the RPC API.
+type RPCTransaction = ethapi.RPCTransaction
+
+// Content is the content of the transaction pool, grouped by sender and nonce.
+type Content struct {
+	Pending map[common.Address]map[uint64]*RPCTransaction `json:"pending"`
+	Queued  map[common.Address]map[uint64]*RPCTransaction `json:"queued"`
+}
+
+// Inspection is a flattened, human readable summary of the content of the
+// transaction pool, grouped by sender and nonce.
+type Inspection struct {
+	Pending map[common.Address]map[uint64]string `json:"pending"`
+	Queued  map[common.Address]map[uint64]string `json:"queued"`
+}
+
+// Client defines typed wrappers for the txpool RPC API.
+type Client struct {
+	c *rpc.Client
+}
+
+// Dial connects a client to the given URL.
+func Dial(rawurl string) (*Client, error) {
+	return DialContext(context.Background(), rawurl)
+}
+
+// DialContext connects a client to the given URL with the given context.
+func DialContext(ctx context.Context, rawurl string) (*Client, error) {
+	c, err := rpc.DialContext(ctx, rawurl)
+	if err != nil {
+		return nil, err
+	}
+	return NewClient(c), nil
+}
+
+// NewClient creates a client that uses the given RPC client.
+func NewClient(c *rpc.Client) *Client {
+	return &Client{c}
+}
+
+// Close closes the underlying RPC connection.
+func (tc *Client) Close() {
+	tc.c.Close()
+}
+
+// Content retrieves the pending and queued transactions of the pool.
+func (tc *Client) Content(ctx context.Context) (*Content, error) {
+	var result *Content
+	err := tc.c.CallContext(ctx, &result, "txpool_content")
+	return result, err
+}
+
+// Inspect retrieves a summary of the pending and queued transactions of the pool.
+func (tc *Client) Inspect(ctx context.Context) (*Inspection, error) {
+	var result *Inspection
+	err := tc.c.CallContext(ctx, &result, "txpool_inspect")
+	return result, err
+}
+
+// Status returns the number of pending and queued transactions in the pool.
+func (tc *Client) Status(ctx context.Context) (pending uint, queued uint, err error) {
+	var result struct {
+		Pending hexutil.Uint `json:"pending"`
+		Queued  hexutil.Uint `json:"queued"`
+	}
+	if err := tc.c.CallContext(ctx, &result, "txpool_status"); err != nil {
+		return 0, 0, err
+	}
+	return uint(result.Pending), uint(result.Queued), nil
+}
@@ -0,0 +1,93 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package txpoolclient
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/athofficial/go-ath/core/types"
+	"github.com/athofficial/go-ath/ethclient/internal/testnode"
+	"github.com/athofficial/go-ath/node"
+	"github.com/athofficial/go-ath/params"
+)
+
+func newTestClient(t *testing.T, stack *node.Node) *Client {
+	rpcclient, err := stack.Attach()
+	if err != nil {
+		t.Fatalf("failed to attach to node: %v", err)
+	}
+	return NewClient(rpcclient)
+}
+
+func TestContent(t *testing.T) {
+	stack, ethservice, _ := testnode.New(t, 3)
+	defer stack.Stop()
+
+	client := newTestClient(t, stack)
+	defer client.Close()
+
+	// Add an executable and a gapped transaction to the pool
+	signer := types.HomesteadSigner{}
+	pending, _ := types.SignTx(types.NewTransaction(3, testnode.Target, big.NewInt(1000), params.TxGas, big.NewInt(params.GWei), nil), signer, testnode.Key)
+	queued, _ := types.SignTx(types.NewTransaction(5, testnode.Target, big.NewInt(1000), params.TxGas, big.NewInt(params.GWei), nil), signer, testnode.Key)
+	for _, tx := range []*types.Transaction{pending, queued} {
+		if err := ethservice.TxPool().AddLocal(tx); err != nil {
+			t.Fatalf("failed to add transaction: %v", err)
+		}
+	}
+	ctx := context.Background()
+
+	// Wait for the pool to catch up with the imported chain and promote the
+	// executable transaction
+	for start := time.Now(); ; time.Sleep(10 * time.Millisecond) {
+		npending, nqueued, err := client.Status(ctx)
+		if err != nil {
+			t.Fatalf("failed to retrieve status: %v", err)
+		}
+		if npending == 1 && nqueued == 1 {
+			break
+		}
+		if time.Since(start) > 5*time.Second {
+			t.Fatalf("status mismatch: have %d pending, %d queued, want 1, 1", npending, nqueued)
+		}
+	}
+	content, err := client.Content(ctx)
+	if err != nil {
+		t.Fatalf("failed to retrieve content: %v", err)
+	}
+	if tx := content.Pending[testnode.Addr][3]; tx == nil || tx.Hash != pending.Hash() || tx.From != testnode.Addr {
+		t.Errorf("pending transaction mismatch: have %+v", tx)
+	}
+	if tx := content.Queued[testnode.Addr][5]; tx == nil || tx.Hash != queued.Hash() {
+		t.Errorf("queued transaction mismatch: have %+v", tx)
+	}
+	inspection, err := client.Inspect(ctx)
+	if err != nil {
+		t.Fatalf("failed to inspect pool: %v", err)
+	}
+	want := fmt.Sprintf("%s: 1000 wei + %d gas × %v wei", testnode.Target.Hex(), params.TxGas, big.NewInt(params.GWei))
+	if have := inspection.Pending[testnode.Addr][3]; have != want {
+		t.Errorf("pending summary mismatch: have %q, want %q", have, want)
+	}
+	if _, ok := inspection.Queued[testnode.Addr][5]; !ok {
+		t.Errorf("queued summary missing")
+	}
+}
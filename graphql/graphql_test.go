@@ -0,0 +1,277 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package graphql
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/athofficial/go-ath/common"
+	"github.com/athofficial/go-ath/consensus/ubqhash"
+	"github.com/athofficial/go-ath/core"
+	"github.com/athofficial/go-ath/core/types"
+	"github.com/athofficial/go-ath/crypto"
+	"github.com/athofficial/go-ath/eth"
+	"github.com/athofficial/go-ath/node"
+	"github.com/athofficial/go-ath/p2p"
+	"github.com/athofficial/go-ath/params"
+	graphqlgo "github.com/graph-gophers/graphql-go"
+)
+
+var (
+	testKey, _  = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+	testAddr    = crypto.PubkeyToAddress(testKey.PublicKey)
+	testBalance = big.NewInt(2e18)
+	testTarget  = common.HexToAddress("0x0000000000000000000000000000000000000b0b")
+)
+
+// Tests that the schema is consistent with the resolvers.
+func TestBuildSchema(t *testing.T) {
+	if _, err := graphqlgo.ParseSchema(schema, &Resolver{}); err != nil {
+		t.Fatalf("failed to parse schema: %v", err)
+	}
+}
+
+// newTestNode creates an Ethereum node serving GraphQL on its HTTP endpoint,
+// whose chain is extended with a few blocks transferring funds.
+func newTestNode(t *testing.T) (*node.Node, []*types.Block) {
+	// Find a free port for the HTTP server
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to find free port: %v", err)
+	}
+	port := listener.Addr().(*net.TCPAddr).Port
+	listener.Close()
+
+	stack, err := node.New(&node.Config{
+		HTTPHost:         "127.0.0.1",
+		HTTPPort:         port,
+		HTTPVirtualHosts: []string{"*"},
+		P2P: p2p.Config{
+			ListenAddr:  "127.0.0.1:0",
+			NoDiscovery: true,
+			MaxPeers:    0,
+		},
+	})
+	if err != nil {
+		t.Fatalf("failed to create node: %v", err)
+	}
+	genesis := &core.Genesis{
+		Config:     params.TestChainConfig,
+		GasLimit:   8000000,
+		Difficulty: big.NewInt(131072),
+		Alloc:      core.GenesisAlloc{testAddr: {Balance: testBalance}},
+	}
+	config := eth.DefaultConfig
+	config.Genesis = genesis
+	config.NetworkId = 1337
+	config.Ubqhash.PowMode = ubqhash.ModeFake
+
+	var ethservice *eth.Ethereum
+	if err := stack.Register(func(ctx *node.ServiceContext) (node.Service, error) {
+		ethservice, err = eth.New(ctx, &config)
+		return ethservice, err
+	}); err != nil {
+		t.Fatalf("failed to register eth service: %v", err)
+	}
+	if err := stack.Register(func(ctx *node.ServiceContext) (node.Service, error) {
+		var ethservice *eth.Ethereum
+		if err := ctx.Service(&ethservice); err != nil {
+			return nil, err
+		}
+		return New(ethservice.APIBackend)
+	}); err != nil {
+		t.Fatalf("failed to register graphql service: %v", err)
+	}
+	if err := stack.Start(); err != nil {
+		t.Fatalf("failed to start node: %v", err)
+	}
+	// Generate a few blocks sending funds and import them
+	signer := types.HomesteadSigner{}
+	parent := ethservice.BlockChain().Genesis()
+	blocks, _ := core.GenerateChain(params.TestChainConfig, parent, ubqhash.NewFaker(), ethservice.ChainDb(), 3, func(i int, b *core.BlockGen) {
+		tx, _ := types.SignTx(types.NewTransaction(b.TxNonce(testAddr), testTarget, big.NewInt(1000), params.TxGas, big.NewInt(params.GWei), nil), signer, testKey)
+		b.AddTx(tx)
+	})
+	if _, err := ethservice.BlockChain().InsertChain(blocks); err != nil {
+		stack.Stop()
+		t.Fatalf("failed to import blocks: %v", err)
+	}
+	return stack, blocks
+}
+
+// query posts a GraphQL query to the node's HTTP endpoint, decoding the data
+// section of the response into result.
+func query(t *testing.T, stack *node.Node, q string, result interface{}) {
+	data, errs := post(t, stack, q)
+	if len(errs) > 0 {
+		t.Fatalf("query %q: failed: %v", q, errs)
+	}
+	if err := json.Unmarshal(data, result); err != nil {
+		t.Fatalf("query %q: failed to decode data %s: %v", q, data, err)
+	}
+}
+
+// post posts a GraphQL query to the node's HTTP endpoint, returning the data and
+// error sections of the response.
+func post(t *testing.T, stack *node.Node, q string) (json.RawMessage, []string) {
+	body, _ := json.Marshal(map[string]string{"query": q})
+	resp, err := http.Post(fmt.Sprintf("http://%s/graphql", stack.HTTPEndpoint()), "application/json", strings.NewReader(string(body)))
+	if err != nil {
+		t.Fatalf("failed to post query: %v", err)
+	}
+	defer resp.Body.Close()
+
+	blob, _ := ioutil.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("query %q: status mismatch: have %d, want %d: %s", q, resp.StatusCode, http.StatusOK, blob)
+	}
+	var res struct {
+		Data   json.RawMessage
+		Errors []struct{ Message string }
+	}
+	if err := json.Unmarshal(blob, &res); err != nil {
+		t.Fatalf("query %q: failed to decode response %s: %v", q, blob, err)
+	}
+	var errs []string
+	for _, err := range res.Errors {
+		errs = append(errs, err.Message)
+	}
+	return res.Data, errs
+}
+
+// Tests that blocks, transactions, receipts and accounts of a simulated chain
+// are served through the GraphQL endpoint of a node.
+func TestGraphQLQueries(t *testing.T) {
+	stack, blocks := newTestNode(t)
+	defer stack.Stop()
+
+	head := blocks[len(blocks)-1]
+	tx := head.Transactions()[0]
+
+	// Check the head block and its transactions
+	var block struct {
+		Block struct {
+			Number       string
+			Hash         common.Hash
+			Parent       struct{ Hash common.Hash }
+			Transactions []struct {
+				Hash    common.Hash
+				Index   int
+				From    struct{ Address common.Address }
+				To      struct{ Address common.Address }
+				Value   string
+				GasUsed string
+				Status  string
+			}
+		}
+	}
+	query(t, stack, `{ block { number hash parent { hash } transactions { hash index from { address } to { address } value gasUsed status } } }`, &block)
+
+	if block.Block.Number != "0x3" {
+		t.Errorf("head number mismatch: have %s, want 0x3", block.Block.Number)
+	}
+	if block.Block.Hash != head.Hash() {
+		t.Errorf("head hash mismatch: have %x, want %x", block.Block.Hash, head.Hash())
+	}
+	if block.Block.Parent.Hash != head.ParentHash() {
+		t.Errorf("parent hash mismatch: have %x, want %x", block.Block.Parent.Hash, head.ParentHash())
+	}
+	if len(block.Block.Transactions) != 1 {
+		t.Fatalf("transaction count mismatch: have %d, want 1", len(block.Block.Transactions))
+	}
+	if have := block.Block.Transactions[0]; have.Hash != tx.Hash() || have.From.Address != testAddr || have.To.Address != testTarget ||
+		have.Value != "0x3e8" || have.GasUsed != "0x5208" || have.Status != "0x1" {
+		t.Errorf("transaction mismatch: have %+v", have)
+	}
+	// Check transaction lookups by hash
+	var transaction struct {
+		Transaction struct {
+			Nonce string
+			Block struct{ Number string }
+		}
+	}
+	query(t, stack, fmt.Sprintf(`{ transaction(hash: "%s") { nonce block { number } } }`, tx.Hash().Hex()), &transaction)
+
+	if transaction.Transaction.Nonce != "0x2" || transaction.Transaction.Block.Number != "0x3" {
+		t.Errorf("transaction mismatch: have %+v, want nonce 0x2 in block 0x3", transaction.Transaction)
+	}
+	// Check block ranges and account states at historical blocks
+	var blockRange struct {
+		Blocks []struct {
+			Number  string
+			Account struct {
+				Balance          string
+				TransactionCount string
+			}
+		}
+	}
+	query(t, stack, fmt.Sprintf(`{ blocks(from: 0) { number account(address: "%s") { balance transactionCount } } }`, testTarget.Hex()), &blockRange)
+
+	if len(blockRange.Blocks) != len(blocks)+1 {
+		t.Fatalf("block range length mismatch: have %d, want %d", len(blockRange.Blocks), len(blocks)+1)
+	}
+	for i, b := range blockRange.Blocks {
+		if want := fmt.Sprintf("%#x", 1000*i); b.Account.Balance != want {
+			t.Errorf("block %d: balance mismatch: have %s, want %s", i, b.Account.Balance, want)
+		}
+		if b.Account.TransactionCount != "0x0" {
+			t.Errorf("block %d: nonce mismatch: have %s, want 0x0", i, b.Account.TransactionCount)
+		}
+	}
+	// Check that missing entities resolve to null
+	var missing struct {
+		Block       *struct{ Number string }
+		Transaction *struct{ Hash common.Hash }
+	}
+	query(t, stack, `{ block(number: 100) { number } transaction(hash: "0x0000000000000000000000000000000000000000000000000000000000000001") { hash } }`, &missing)
+
+	if missing.Block != nil || missing.Transaction != nil {
+		t.Errorf("missing entities resolved: block %v, transaction %v", missing.Block, missing.Transaction)
+	}
+}
+
+// Tests that queries spanning too many blocks are rejected instead of walking
+// the chain in a single request.
+func TestGraphQLRangeLimit(t *testing.T) {
+	stack, _ := newTestNode(t)
+	defer stack.Stop()
+
+	for _, q := range []string{
+		fmt.Sprintf(`{ blocks(from: 0, to: %d) { number } }`, maxBlockRange),
+		fmt.Sprintf(`{ logs(filter: { fromBlock: 0, toBlock: %d }) { index } }`, maxBlockRange),
+		fmt.Sprintf(`{ logs(filter: { fromBlock: 0, toBlock: %d, addresses: ["%s"] }) { index } }`, 2*maxBlockRange, testTarget.Hex()),
+	} {
+		if _, errs := post(t, stack, q); len(errs) != 1 || errs[0] != errRangeTooLarge.Error() {
+			t.Errorf("query %q: error mismatch: have %v, want %v", q, errs, errRangeTooLarge)
+		}
+	}
+	var result struct {
+		Blocks []struct{ Number string }
+		Logs   []struct{ Index int }
+	}
+	query(t, stack, fmt.Sprintf(`{ blocks(from: 0, to: %d) { number } logs(filter: { fromBlock: 0 }) { index } }`, maxBlockRange-1), &result)
+	if len(result.Blocks) != 4 {
+		t.Errorf("block count mismatch: have %d, want 4", len(result.Blocks))
+	}
+}
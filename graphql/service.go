@@ -0,0 +1,81 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package graphql
+
+import (
+	"net/http"
+
+	"github.com/athofficial/go-ath/internal/ethapi"
+	"github.com/athofficial/go-ath/log"
+	"github.com/athofficial/go-ath/p2p"
+	"github.com/athofficial/go-ath/rpc"
+	graphqlgo "github.com/graph-gophers/graphql-go"
+	"github.com/graph-gophers/graphql-go/relay"
+)
+
+// Service encapsulates a GraphQL service, served on the node's HTTP endpoint.
+type Service struct {
+	handler http.Handler // GraphQL query handler
+}
+
+// New constructs a new GraphQL service instance backed by the given API backend.
+func New(backend ethapi.Backend) (*Service, error) {
+	handler, err := newHandler(backend)
+	if err != nil {
+		return nil, err
+	}
+	return &Service{handler: handler}, nil
+}
+
+// newHandler parses the GraphQL schema and binds it to the resolvers of the
+// given backend.
+func newHandler(backend ethapi.Backend) (http.Handler, error) {
+	s, err := graphqlgo.ParseSchema(schema, &Resolver{backend})
+	if err != nil {
+		return nil, err
+	}
+	return &relay.Handler{Schema: s}, nil
+}
+
+// Protocols implements node.Service, returning the P2P network protocols used
+// by the GraphQL service (nil as it doesn't use the devp2p overlay network).
+func (s *Service) Protocols() []p2p.Protocol { return nil }
+
+// APIs implements node.Service, returning the RPC API endpoints provided by the
+// GraphQL service (nil as it doesn't provide any user callable APIs).
+func (s *Service) APIs() []rpc.API { return nil }
+
+// HTTPHandlers implements node.HTTPService, mounting the GraphQL endpoint and
+// its in-browser explorer on the node's HTTP server.
+func (s *Service) HTTPHandlers() map[string]http.Handler {
+	return map[string]http.Handler{
+		"/graphql":    s.handler,
+		"/graphql/ui": graphiql{},
+	}
+}
+
+// Start implements node.Service, starting the GraphQL service. The queries are
+// served by the node's HTTP server, so there is nothing to start here.
+func (s *Service) Start(server *p2p.Server) error {
+	log.Info("GraphQL endpoint enabled", "path", "/graphql")
+	return nil
+}
+
+// Stop implements node.Service, terminating the GraphQL service.
+func (s *Service) Stop() error {
+	return nil
+}
@@ -27,6 +27,7 @@ import (
 
 	"github.com/davecgh/go-spew/spew"
 	"github.com/athofficial/go-ath/accounts"
+	"github.com/athofficial/go-ath/accounts/abi"
 	"github.com/athofficial/go-ath/accounts/keystore"
 	"github.com/athofficial/go-ath/common"
 	"github.com/athofficial/go-ath/common/hexutil"
@@ -67,6 +68,37 @@ func (s *PublicEthereumAPI) GasPrice(ctx context.Context) (*hexutil.Big, error)
 	return (*hexutil.Big)(price), err
 }
 
+// feeHistoryResult is the fee history of a range of blocks.
+type feeHistoryResult struct {
+	OldestBlock  *hexutil.Big     `json:"oldestBlock"`
+	Reward       [][]*hexutil.Big `json:"reward,omitempty"`
+	GasUsedRatio []float64        `json:"gasUsedRatio"`
+}
+
+// FeeHistory returns the gas used ratios of up to blockCount blocks ending with
+// lastBlock, and the given percentiles of the gas prices paid in each of them,
+// weighted by the gas used.
+func (s *PublicEthereumAPI) FeeHistory(ctx context.Context, blockCount hexutil.Uint, lastBlock rpc.BlockNumber, rewardPercentiles []float64) (*feeHistoryResult, error) {
+	oldest, rewards, ratios, err := s.b.FeeHistory(ctx, int(blockCount), lastBlock, rewardPercentiles)
+	if err != nil {
+		return nil, err
+	}
+	result := &feeHistoryResult{
+		OldestBlock:  (*hexutil.Big)(oldest),
+		GasUsedRatio: ratios,
+	}
+	if rewards != nil {
+		result.Reward = make([][]*hexutil.Big, len(rewards))
+		for i, block := range rewards {
+			result.Reward[i] = make([]*hexutil.Big, len(block))
+			for j, reward := range block {
+				result.Reward[i][j] = (*hexutil.Big)(reward)
+			}
+		}
+	}
+	return result, nil
+}
+
 // ProtocolVersion returns the current ATH protocol version this node supports
 func (s *PublicEthereumAPI) ProtocolVersion() hexutil.Uint {
 	return hexutil.Uint(s.b.ProtocolVersion())
@@ -118,7 +150,7 @@ func (s *PublicTxPoolAPI) Content() map[string]map[string]map[string]*RPCTransac
 	for account, txs := range pending {
 		dump := make(map[string]*RPCTransaction)
 		for _, tx := range txs {
-			dump[fmt.Sprintf("%d", tx.Nonce())] = newRPCPendingTransaction(tx)
+			dump[fmt.Sprintf("%d", tx.Nonce())] = NewRPCPendingTransaction(tx)
 		}
 		content["pending"][account.Hex()] = dump
 	}
@@ -126,7 +158,7 @@ func (s *PublicTxPoolAPI) Content() map[string]map[string]map[string]*RPCTransac
 	for account, txs := range queue {
 		dump := make(map[string]*RPCTransaction)
 		for _, tx := range txs {
-			dump[fmt.Sprintf("%d", tx.Nonce())] = newRPCPendingTransaction(tx)
+			dump[fmt.Sprintf("%d", tx.Nonce())] = NewRPCPendingTransaction(tx)
 		}
 		content["queued"][account.Hex()] = dump
 	}
@@ -749,11 +781,74 @@ func (s *PublicBlockChainAPI) doCall(ctx context.Context, args CallArgs, blockNr
 	return res, gas, failed, err
 }
 
+// revertError is an API error that encompasses an EVM revert with JSON error
+// code and the raw revert data blob.
+type revertError struct {
+	error
+	data string // revert data hex encoded
+}
+
+// ErrorCode returns the JSON error code for a reverted execution.
+// See: https://github.com/ethereum/wiki/wiki/JSON-RPC-Error-Codes-Improvement-Proposal
+func (e *revertError) ErrorCode() int {
+	return 3
+}
+
+// ErrorData returns the hex encoded revert data.
+func (e *revertError) ErrorData() interface{} {
+	return e.data
+}
+
+// newRevertError creates a revertError instance from the data returned by a
+// reverted execution, decoding the solidity revert reason if there is one.
+func newRevertError(data []byte) *revertError {
+	err := errors.New("execution reverted")
+	if reason, errUnpack := abi.UnpackRevert(data); errUnpack == nil {
+		err = fmt.Errorf("execution reverted: %v", reason)
+	}
+	return &revertError{
+		error: err,
+		data:  hexutil.Encode(data),
+	}
+}
+
+// RevertResult describes the outcome of re-executing a transaction, including
+// the decoded revert reason if the execution was reverted with one.
+type RevertResult struct {
+	Failed bool          `json:"failed"`
+	Reason string        `json:"reason,omitempty"`
+	Data   hexutil.Bytes `json:"data,omitempty"`
+}
+
+// NewRevertResult assembles a RevertResult from the return data and failure
+// status of an EVM execution.
+func NewRevertResult(ret []byte, failed bool) *RevertResult {
+	result := &RevertResult{Failed: failed}
+	if !failed || len(ret) == 0 {
+		return result
+	}
+	result.Data = common.CopyBytes(ret)
+	if reason, err := abi.UnpackRevert(ret); err == nil {
+		result.Reason = reason
+	}
+	return result
+}
+
 // Call executes the given transaction on the state for the given block number.
 // It doesn't make and changes in the state/blockchain and is useful to execute and retrieve values.
+//
+// If the execution is reverted with a reason, a structured error is returned
+// carrying the decoded message and the raw revert data.
 func (s *PublicBlockChainAPI) Call(ctx context.Context, args CallArgs, blockNr rpc.BlockNumber) (hexutil.Bytes, error) {
-	result, _, _, err := s.doCall(ctx, args, blockNr, 5*time.Second, s.b.RPCGasCap())
-	return (hexutil.Bytes)(result), err
+	result, _, failed, err := s.doCall(ctx, args, blockNr, 5*time.Second, s.b.RPCGasCap())
+	if err != nil {
+		return nil, err
+	}
+	// Only a revert leaves return data behind on a failed execution
+	if failed && len(result) > 0 {
+		return nil, newRevertError(result)
+	}
+	return (hexutil.Bytes)(result), nil
 }
 
 // EstimateGas returns an estimate of the amount of gas needed to execute the
@@ -782,20 +877,21 @@ func (s *PublicBlockChainAPI) EstimateGas(ctx context.Context, args CallArgs) (h
 	}
 	cap = hi
 
-	// Create a helper to check if a gas allowance results in an executable transaction
-	executable := func(gas uint64) bool {
+	// Create a helper to check if a gas allowance results in an executable transaction,
+	// returning the revert data of failed executions
+	executable := func(gas uint64) (bool, []byte) {
 		args.Gas = hexutil.Uint64(gas)
 
-		_, _, failed, err := s.doCall(ctx, args, rpc.PendingBlockNumber, 0, gasCap)
+		res, _, failed, err := s.doCall(ctx, args, rpc.PendingBlockNumber, 0, gasCap)
 		if err != nil || failed {
-			return false
+			return false, res
 		}
-		return true
+		return true, nil
 	}
 	// Execute the binary search and hone in on an executable gas limit
 	for lo+1 < hi {
 		mid := (hi + lo) / 2
-		if !executable(mid) {
+		if ok, _ := executable(mid); !ok {
 			lo = mid
 		} else {
 			hi = mid
@@ -803,7 +899,10 @@ func (s *PublicBlockChainAPI) EstimateGas(ctx context.Context, args CallArgs) (h
 	}
 	// Reject the transaction as invalid if it still fails at the highest allowance
 	if hi == cap {
-		if !executable(hi) {
+		if ok, revert := executable(hi); !ok {
+			if len(revert) > 0 {
+				return 0, newRevertError(revert)
+			}
 			return 0, fmt.Errorf("gas required exceeds allowance (%d) or always failing transaction", cap)
 		}
 	}
@@ -828,7 +927,7 @@ type StructLogRes struct {
 	Gas     uint64             `json:"gas"`
 	GasCost uint64             `json:"gasCost"`
 	Depth   int                `json:"depth"`
-	Error   error              `json:"error,omitempty"`
+	Error   string             `json:"error,omitempty"`
 	Stack   *[]string          `json:"stack,omitempty"`
 	Memory  *[]string          `json:"memory,omitempty"`
 	Storage *map[string]string `json:"storage,omitempty"`
@@ -844,7 +943,9 @@ func FormatLogs(logs []vm.StructLog) []StructLogRes {
 			Gas:     trace.Gas,
 			GasCost: trace.GasCost,
 			Depth:   trace.Depth,
-			Error:   trace.Err,
+		}
+		if trace.Err != nil {
+			formatted[index].Error = trace.Err.Error()
 		}
 		if trace.Stack != nil {
 			stack := make([]string, len(trace.Stack))
@@ -986,8 +1087,8 @@ func newRPCTransaction(tx *types.Transaction, blockHash common.Hash, blockNumber
 	return result
 }
 
-// newRPCPendingTransaction returns a pending transaction that will serialize to the RPC representation
-func newRPCPendingTransaction(tx *types.Transaction) *RPCTransaction {
+// NewRPCPendingTransaction returns a pending transaction that will serialize to the RPC representation
+func NewRPCPendingTransaction(tx *types.Transaction) *RPCTransaction {
 	return newRPCTransaction(tx, common.Hash{}, 0, 0)
 }
 
@@ -1108,7 +1209,7 @@ func (s *PublicTransactionPoolAPI) GetTransactionByHash(ctx context.Context, has
 	}
 	// No finalized transaction, try to retrieve it from the pool
 	if tx := s.b.GetPoolTransaction(hash); tx != nil {
-		return newRPCPendingTransaction(tx)
+		return NewRPCPendingTransaction(tx)
 	}
 	// Transaction unknown, return as such
 	return nil
@@ -1410,7 +1511,7 @@ func (s *PublicTransactionPoolAPI) PendingTransactions() ([]*RPCTransaction, err
 		}
 		from, _ := types.Sender(signer, tx)
 		if _, exists := accounts[from]; exists {
-			transactions = append(transactions, newRPCPendingTransaction(tx))
+			transactions = append(transactions, NewRPCPendingTransaction(tx))
 		}
 	}
 	return transactions, nil
@@ -0,0 +1,83 @@
+// Copyright 2019 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package les
+
+import (
+	"errors"
+	"net"
+
+	"github.com/athofficial/go-ath/contracts/chequebook"
+	"github.com/athofficial/go-ath/p2p/enode"
+)
+
+var errNotStarted = errors.New("light server not started")
+
+// PrivateLightServerAPI provides an API to manage the clients of a LES server.
+type PrivateLightServerAPI struct {
+	server *LesServer
+}
+
+// NewPrivateLightServerAPI creates a new LES server API.
+func NewPrivateLightServerAPI(server *LesServer) *PrivateLightServerAPI {
+	return &PrivateLightServerAPI{server: server}
+}
+
+// AddBalance changes the token balance of a client by value, which may be negative.
+// It returns the balance before and after the change.
+func (api *PrivateLightServerAPI) AddBalance(id enode.ID, value int64) ([2]uint64, error) {
+	pool := api.server.protocolManager.getClientPool()
+	if pool == nil {
+		return [2]uint64{}, errNotStarted
+	}
+	oldBalance, newBalance, err := pool.addBalance(id, value)
+	return [2]uint64{oldBalance, newBalance}, err
+}
+
+// ClientInfo returns the connection status and token balance of the given clients.
+func (api *PrivateLightServerAPI) ClientInfo(ids []enode.ID) (map[enode.ID]ClientInfo, error) {
+	pool := api.server.protocolManager.getClientPool()
+	if pool == nil {
+		return nil, errNotStarted
+	}
+	addresses := make(map[enode.ID]string)
+	for _, p := range api.server.protocolManager.peers.AllPeers() {
+		if addr, ok := p.RemoteAddr().(*net.TCPAddr); ok {
+			addresses[p.ID()] = addr.IP.String()
+		}
+	}
+	result := make(map[enode.ID]ClientInfo, len(ids))
+	for _, id := range ids {
+		result[id] = pool.clientInfo(id, addresses[id])
+	}
+	return result, nil
+}
+
+// DepositCheque credits the amount of a chequebook cheque made out to the server's
+// etherbase to the balance of the client which signed it. The cheque is verified
+// against the chequebook contract and the previously deposited cheques of the same
+// chequebook. It returns the new balance of the client.
+func (api *PrivateLightServerAPI) DepositCheque(cheque *chequebook.Cheque) (map[string]interface{}, error) {
+	pool := api.server.protocolManager.getClientPool()
+	if pool == nil {
+		return nil, errNotStarted
+	}
+	id, balance, err := pool.receiveCheque(cheque)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{"id": id, "balance": balance}, nil
+}
@@ -19,6 +19,7 @@ package les
 
 import (
 	"fmt"
+	"math/big"
 	"sync"
 	"time"
 
@@ -40,7 +41,7 @@ import (
 	"github.com/athofficial/go-ath/log"
 	"github.com/athofficial/go-ath/node"
 	"github.com/athofficial/go-ath/p2p"
-	"github.com/athofficial/go-ath/p2p/discv5"
+	"github.com/athofficial/go-ath/p2p/discover"
 	"github.com/athofficial/go-ath/params"
 	rpc "github.com/athofficial/go-ath/rpc"
 )
@@ -136,7 +137,7 @@ func New(ctx *node.ServiceContext, config *eth.Config) (*LightEthereum, error) {
 	}
 
 	leth.txPool = light.NewTxPool(leth.chainConfig, leth.blockchain, leth.relay)
-	if leth.protocolManager, err = NewProtocolManager(leth.chainConfig, light.DefaultClientIndexerConfig, true, config.NetworkId, leth.eventMux, leth.engine, leth.peers, leth.blockchain, nil, chainDb, leth.odr, leth.relay, leth.serverPool, quitSync, &leth.wg); err != nil {
+	if leth.protocolManager, err = NewProtocolManager(leth.chainConfig, light.DefaultClientIndexerConfig, true, config.NetworkId, leth.eventMux, leth.engine, leth.peers, leth.blockchain, nil, chainDb, leth.odr, leth.relay, leth.serverPool, config.ULC, quitSync, &leth.wg); err != nil {
 		return nil, err
 	}
 	leth.ApiBackend = &LesApiBackend{leth, nil}
@@ -144,11 +145,14 @@ func New(ctx *node.ServiceContext, config *eth.Config) (*LightEthereum, error) {
 	if gpoParams.Default == nil {
 		gpoParams.Default = config.MinerGasPrice
 	}
+	if gpoParams.MinPrice == nil {
+		gpoParams.MinPrice = new(big.Int).SetUint64(config.TxPool.PriceLimit)
+	}
 	leth.ApiBackend.gpo = gasprice.NewOracle(leth.ApiBackend, gpoParams)
 	return leth, nil
 }
 
-func lesTopic(genesisHash common.Hash, protocolVersion uint) discv5.Topic {
+func lesTopic(genesisHash common.Hash, protocolVersion uint) discover.Topic {
 	var name string
 	switch protocolVersion {
 	case lpv1:
@@ -158,7 +162,7 @@ func lesTopic(genesisHash common.Hash, protocolVersion uint) discv5.Topic {
 	default:
 		panic(nil)
 	}
-	return discv5.Topic(name + "@" + common.Bytes2Hex(genesisHash.Bytes()[0:8]))
+	return discover.Topic(name + "@" + common.Bytes2Hex(genesisHash.Bytes()[0:8]))
 }
 
 type LightDummyAPI struct{}
@@ -237,6 +241,12 @@ func (s *LightEthereum) Start(srvr *p2p.Server) error {
 	// clients are searching for the first advertised protocol in the list
 	protocolVersion := AdvertiseProtocolVersions[0]
 	s.serverPool.start(srvr, lesTopic(s.blockchain.Genesis().Hash(), protocolVersion))
+	if ulc := s.protocolManager.ulc; ulc != nil {
+		// keep connected to the trusted servers of the ultra light client
+		for _, n := range ulc.servers {
+			srvr.AddPeer(n)
+		}
+	}
 	s.protocolManager.Start(s.config.LightPeers)
 	return nil
 }
@@ -0,0 +1,437 @@
+// Copyright 2019 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package les
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/athofficial/go-ath/accounts/abi/bind"
+	"github.com/athofficial/go-ath/common"
+	"github.com/athofficial/go-ath/common/mclock"
+	"github.com/athofficial/go-ath/contracts/chequebook"
+	"github.com/athofficial/go-ath/contracts/chequebook/contract"
+	"github.com/athofficial/go-ath/crypto"
+	"github.com/athofficial/go-ath/ethdb"
+	"github.com/athofficial/go-ath/log"
+	"github.com/athofficial/go-ath/p2p/enode"
+	"github.com/athofficial/go-ath/rlp"
+)
+
+const (
+	defaultConnectedPrice = 1000 // balance tokens charged for each second of priority connection
+	defaultRequestPrice   = 1    // balance tokens charged for each million units of request cost
+	requestPriceUnit      = 1000000
+
+	chequeValidationTimeout = 10 * time.Second // timeout of the chain queries validating a cheque
+)
+
+var (
+	clientBalancePrefix = []byte("clientBalance:") // clientBalancePrefix + enode.ID -> balance
+	chequeSumPrefix     = []byte("chequeSum:")     // chequeSumPrefix + contract address -> cumulative amount received
+
+	errNoBeneficiary     = errors.New("cheques not accepted, no beneficiary configured")
+	errNoChainBackend    = errors.New("cheques not accepted, no chain backend available")
+	errInvalidChequebook = errors.New("invalid chequebook contract")
+	errWrongChequeSigner = errors.New("cheque not signed by chequebook owner")
+	errUncoveredCheque   = errors.New("cheque not covered by chequebook balance")
+	errClosedPool        = errors.New("client pool closed")
+	errNegativeResult    = errors.New("balance would become negative")
+)
+
+// clientPool decides which LES clients are served. Clients with a positive token
+// balance are priority clients: they are accepted as long as there are connection
+// slots not taken by other priority clients, kicking out free clients if necessary,
+// and are served with a higher flow control capacity. The connection slots left
+// over are shared by free clients through the freeClientPool.
+//
+// Priority clients are identified by their node ID. Their balance is reduced by
+// the time they are connected and by the cost of the requests they send. When it
+// runs out, the client is disconnected and may reconnect as a free client.
+// Balances are funded by the server operator or through chequebook cheques.
+type clientPool struct {
+	lock   sync.Mutex
+	db     ethdb.Database
+	clock  mclock.Clock
+	free   *freeClientPool
+	closed bool
+
+	connLimit      int
+	priority       map[enode.ID]*priorityClient
+	connectedPrice uint64 // tokens per second
+	requestPrice   uint64 // tokens per requestPriceUnit of request cost
+	beneficiary    common.Address
+	backend        chequeBackend
+}
+
+// chequeBackend provides the chain access needed to validate chequebook cheques.
+type chequeBackend interface {
+	bind.ContractCaller
+	BalanceAt(ctx context.Context, account common.Address, blockNumber *big.Int) (*big.Int, error)
+	StorageAt(ctx context.Context, account common.Address, key common.Hash, blockNumber *big.Int) ([]byte, error)
+}
+
+// priorityClient is a connected client with a positive balance. Its current balance
+// is calculated from the balance at connection time and the usage since then, so
+// frequent updates don't accumulate rounding errors.
+type priorityClient struct {
+	id           enode.ID
+	startBalance uint64 // balance at connection time plus later funding
+	connectedAt  mclock.AbsTime
+	requestCost  uint64 // sum of request costs since connection
+	balance      uint64 // balance at the last update
+	depleted     bool
+	disconnectFn func()
+}
+
+// ClientInfo is the status of a client returned by the les_clientInfo API.
+type ClientInfo struct {
+	Connected bool   `json:"connected"`
+	Priority  bool   `json:"priority"`
+	Balance   uint64 `json:"balance"`
+}
+
+// newClientPool creates a client pool serving up to connLimit clients. Cheques
+// are accepted if they are made out to the given beneficiary and can be validated
+// against the chequebook contract through the given backend.
+func newClientPool(db ethdb.Database, connLimit int, beneficiary common.Address, backend chequeBackend, clock mclock.Clock) *clientPool {
+	return &clientPool{
+		db:             db,
+		clock:          clock,
+		free:           newFreeClientPool(db, connLimit, 10000, clock),
+		connLimit:      connLimit,
+		priority:       make(map[enode.ID]*priorityClient),
+		connectedPrice: defaultConnectedPrice,
+		requestPrice:   defaultRequestPrice,
+		beneficiary:    beneficiary,
+		backend:        backend,
+	}
+}
+
+// stop stores the balances of all connected clients and shuts down the pool.
+func (cp *clientPool) stop() {
+	cp.lock.Lock()
+	defer cp.lock.Unlock()
+
+	now := cp.clock.Now()
+	for _, c := range cp.priority {
+		cp.charge(c, now, 0)
+		cp.setBalance(c.id, c.balance)
+	}
+	cp.closed = true
+	cp.free.stop()
+}
+
+// isPriority reports whether a client would be accepted as a priority client.
+func (cp *clientPool) isPriority(id enode.ID) bool {
+	cp.lock.Lock()
+	defer cp.lock.Unlock()
+
+	return cp.getBalance(id) > 0
+}
+
+// connect should be called after a successful handshake. Clients with a positive
+// balance are accepted as priority clients, others are passed on to the free client
+// pool which is identified by the given address. If the connection was rejected,
+// there is no need to call disconnect.
+//
+// Note: the disconnectFn callback should not block.
+func (cp *clientPool) connect(id enode.ID, address string, disconnectFn func()) bool {
+	cp.lock.Lock()
+	defer cp.lock.Unlock()
+
+	if cp.closed {
+		return false
+	}
+	cp.update()
+	if _, ok := cp.priority[id]; ok {
+		log.Debug("Client already connected", "id", id)
+		return false
+	}
+	balance := cp.getBalance(id)
+	if balance == 0 {
+		return cp.free.connect(address, disconnectFn)
+	}
+	if len(cp.priority) >= cp.connLimit {
+		log.Debug("Priority client rejected", "id", id)
+		return false
+	}
+	cp.priority[id] = &priorityClient{
+		id:           id,
+		startBalance: balance,
+		connectedAt:  cp.clock.Now(),
+		balance:      balance,
+		disconnectFn: disconnectFn,
+	}
+	cp.free.setConnectedLimit(cp.connLimit - len(cp.priority))
+	log.Debug("Priority client accepted", "id", id, "balance", balance)
+	return true
+}
+
+// disconnect should be called when a connection is terminated.
+func (cp *clientPool) disconnect(id enode.ID, address string) {
+	cp.lock.Lock()
+	defer cp.lock.Unlock()
+
+	if cp.closed {
+		return
+	}
+	c, ok := cp.priority[id]
+	if !ok {
+		cp.free.disconnect(address)
+		return
+	}
+	cp.charge(c, cp.clock.Now(), 0)
+	cp.setBalance(id, c.balance)
+	delete(cp.priority, id)
+	cp.free.setConnectedLimit(cp.connLimit - len(cp.priority))
+	log.Debug("Priority client disconnected", "id", id, "balance", c.balance)
+}
+
+// requestCost charges the balance of a priority client for a served request.
+func (cp *clientPool) requestCost(id enode.ID, cost uint64) {
+	cp.lock.Lock()
+	defer cp.lock.Unlock()
+
+	if c, ok := cp.priority[id]; ok && !cp.closed {
+		cp.charge(c, cp.clock.Now(), cost)
+	}
+}
+
+// update charges all priority clients for their connection time, disconnecting the
+// ones whose balance has run out.
+func (cp *clientPool) update() {
+	now := cp.clock.Now()
+	for _, c := range cp.priority {
+		cp.charge(c, now, 0)
+	}
+}
+
+// charge adds the given request cost to the usage of a priority client and updates
+// its balance. The balance is stored when the client disconnects.
+func (cp *clientPool) charge(c *priorityClient, now mclock.AbsTime, reqCost uint64) {
+	c.requestCost += reqCost
+
+	cost := new(big.Int).SetUint64(uint64(now - c.connectedAt))
+	cost.Mul(cost, new(big.Int).SetUint64(cp.connectedPrice))
+	cost.Div(cost, big.NewInt(int64(time.Second)))
+	rc := new(big.Int).SetUint64(c.requestCost)
+	rc.Mul(rc, new(big.Int).SetUint64(cp.requestPrice))
+	cost.Add(cost, rc.Div(rc, big.NewInt(requestPriceUnit)))
+
+	if cost.Cmp(new(big.Int).SetUint64(c.startBalance)) >= 0 {
+		c.balance = 0
+	} else {
+		c.balance = c.startBalance - cost.Uint64()
+	}
+	if c.balance == 0 && !c.depleted {
+		c.depleted = true
+		log.Debug("Priority client balance depleted", "id", c.id)
+		c.disconnectFn()
+	}
+}
+
+// addBalance changes the balance of a client by amount. The change takes effect on
+// connected priority clients immediately, free clients are prioritized when they
+// connect the next time.
+func (cp *clientPool) addBalance(id enode.ID, amount int64) (oldBalance, newBalance uint64, err error) {
+	cp.lock.Lock()
+	defer cp.lock.Unlock()
+
+	if cp.closed {
+		return 0, 0, errClosedPool
+	}
+	cp.update()
+	oldBalance = cp.getBalance(id)
+	switch {
+	case amount >= 0:
+		newBalance = oldBalance + uint64(amount)
+		if newBalance < oldBalance {
+			newBalance = ^uint64(0)
+		}
+	case uint64(-amount) > oldBalance:
+		return oldBalance, oldBalance, errNegativeResult
+	default:
+		newBalance = oldBalance - uint64(-amount)
+	}
+	if c, ok := cp.priority[id]; ok {
+		c.startBalance += newBalance - oldBalance // wraps around for negative amounts
+		cp.charge(c, cp.clock.Now(), 0)
+	}
+	cp.setBalance(id, newBalance)
+	log.Debug("Client balance changed", "id", id, "old", oldBalance, "new", newBalance)
+	return oldBalance, newBalance, nil
+}
+
+// receiveCheque verifies a cheque made out to the server's beneficiary and credits
+// the amount not yet received from the chequebook to the signer's node ID. The
+// chequebook contract has to be owned by the signer, and the cheques are credited
+// only up to the amount the contract can pay out.
+func (cp *clientPool) receiveCheque(ch *chequebook.Cheque) (enode.ID, uint64, error) {
+	if cp.beneficiary == (common.Address{}) {
+		return enode.ID{}, 0, errNoBeneficiary
+	}
+	if cp.backend == nil {
+		return enode.ID{}, 0, errNoChainBackend
+	}
+	signer, err := ch.Signer()
+	if err != nil {
+		return enode.ID{}, 0, err
+	}
+	limit, err := cp.chequeLimit(ch.Contract, crypto.PubkeyToAddress(*signer))
+	if err != nil {
+		return enode.ID{}, 0, err
+	}
+
+	cp.lock.Lock()
+	defer cp.lock.Unlock()
+
+	if cp.closed {
+		return enode.ID{}, 0, errClosedPool
+	}
+	sum := cp.getChequeSum(ch.Contract)
+	amount, err := ch.Verify(signer, ch.Contract, cp.beneficiary, sum)
+	if err != nil {
+		return enode.ID{}, 0, err
+	}
+	received := ch.Amount
+	if received.Cmp(limit) > 0 {
+		// The rest of the cheque can be credited once the chequebook is funded
+		if limit.Cmp(sum) <= 0 {
+			return enode.ID{}, 0, errUncoveredCheque
+		}
+		received = limit
+		amount = new(big.Int).Sub(limit, sum)
+	}
+	if !amount.IsUint64() {
+		return enode.ID{}, 0, errors.New("cheque amount too large")
+	}
+	cp.setChequeSum(ch.Contract, received)
+
+	id := enode.PubkeyToIDV4(signer)
+	cp.update()
+	balance := cp.getBalance(id) + amount.Uint64()
+	if c, ok := cp.priority[id]; ok {
+		c.startBalance += amount.Uint64()
+		cp.charge(c, cp.clock.Now(), 0)
+	}
+	cp.setBalance(id, balance)
+	log.Debug("Received cheque", "id", id, "contract", ch.Contract, "amount", amount, "balance", balance)
+	return id, balance, nil
+}
+
+// chequeLimit validates a chequebook contract owned by the given address and
+// returns the cumulative amount it can pay out to the beneficiary: the amount
+// already cashed plus the balance of the contract.
+func (cp *clientPool) chequeLimit(address, owner common.Address) (*big.Int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), chequeValidationTimeout)
+	defer cancel()
+
+	code, err := cp.backend.CodeAt(ctx, address, nil)
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(code, common.FromHex(contract.ContractDeployedCode)) {
+		return nil, errInvalidChequebook
+	}
+	// The owner is the first storage slot of the contract
+	slot, err := cp.backend.StorageAt(ctx, address, common.Hash{}, nil)
+	if err != nil {
+		return nil, err
+	}
+	if common.BytesToAddress(slot) != owner {
+		return nil, errWrongChequeSigner
+	}
+	caller, err := contract.NewChequebookCaller(address, cp.backend)
+	if err != nil {
+		return nil, err
+	}
+	sent, err := caller.Sent(&bind.CallOpts{Context: ctx}, cp.beneficiary)
+	if err != nil {
+		return nil, err
+	}
+	balance, err := cp.backend.BalanceAt(ctx, address, nil)
+	if err != nil {
+		return nil, err
+	}
+	return new(big.Int).Add(sent, balance), nil
+}
+
+// clientInfo returns the status of a client.
+func (cp *clientPool) clientInfo(id enode.ID, address string) ClientInfo {
+	cp.lock.Lock()
+	defer cp.lock.Unlock()
+
+	if !cp.closed {
+		cp.update()
+	}
+	if c, ok := cp.priority[id]; ok {
+		return ClientInfo{Connected: !c.depleted, Priority: true, Balance: c.balance}
+	}
+	info := ClientInfo{Balance: cp.getBalance(id)}
+	if address != "" {
+		info.Connected = cp.free.connected(address)
+	}
+	return info
+}
+
+// getBalance retrieves the balance of a client from the database.
+func (cp *clientPool) getBalance(id enode.ID) uint64 {
+	if c, ok := cp.priority[id]; ok {
+		return c.balance
+	}
+	var balance uint64
+	if enc, err := cp.db.Get(append(clientBalancePrefix, id[:]...)); err == nil {
+		if err := rlp.DecodeBytes(enc, &balance); err != nil {
+			log.Error("Failed to decode client balance", "id", id, "err", err)
+		}
+	}
+	return balance
+}
+
+// setBalance stores the balance of a client in the database.
+func (cp *clientPool) setBalance(id enode.ID, balance uint64) {
+	key := append(clientBalancePrefix, id[:]...)
+	if balance == 0 {
+		cp.db.Delete(key)
+		return
+	}
+	enc, _ := rlp.EncodeToBytes(balance)
+	if err := cp.db.Put(key, enc); err != nil {
+		log.Error("Failed to store client balance", "id", id, "err", err)
+	}
+}
+
+// getChequeSum retrieves the cumulative amount received from a chequebook.
+func (cp *clientPool) getChequeSum(contract common.Address) *big.Int {
+	sum := new(big.Int)
+	if enc, err := cp.db.Get(append(chequeSumPrefix, contract[:]...)); err == nil {
+		sum.SetBytes(enc)
+	}
+	return sum
+}
+
+// setChequeSum stores the cumulative amount received from a chequebook.
+func (cp *clientPool) setChequeSum(contract common.Address, sum *big.Int) {
+	if err := cp.db.Put(append(chequeSumPrefix, contract[:]...), sum.Bytes()); err != nil {
+		log.Error("Failed to store cheque sum", "contract", contract, "err", err)
+	}
+}
@@ -0,0 +1,297 @@
+// Copyright 2019 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package les
+
+import (
+	"crypto/ecdsa"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/athofficial/go-ath/accounts/abi/bind"
+	"github.com/athofficial/go-ath/accounts/abi/bind/backends"
+	"github.com/athofficial/go-ath/common"
+	"github.com/athofficial/go-ath/common/mclock"
+	"github.com/athofficial/go-ath/contracts/chequebook"
+	"github.com/athofficial/go-ath/contracts/chequebook/contract"
+	"github.com/athofficial/go-ath/core"
+	"github.com/athofficial/go-ath/crypto"
+	"github.com/athofficial/go-ath/ethdb"
+	"github.com/athofficial/go-ath/p2p/enode"
+)
+
+// poolTestClient is a simulated client connecting to a client pool.
+type poolTestClient struct {
+	id      enode.ID
+	address string
+	kicked  bool
+}
+
+func newPoolTestClients(n int) []*poolTestClient {
+	clients := make([]*poolTestClient, n)
+	for i := range clients {
+		clients[i] = &poolTestClient{
+			id:      enode.ID{byte(i), byte(i >> 8)},
+			address: fmt.Sprintf("10.0.%d.%d", i>>8, i&0xff),
+		}
+	}
+	return clients
+}
+
+func (c *poolTestClient) connect(pool *clientPool) bool {
+	c.kicked = false
+	return pool.connect(c.id, c.address, func() { c.kicked = true })
+}
+
+func (c *poolTestClient) disconnect(pool *clientPool) {
+	pool.disconnect(c.id, c.address)
+}
+
+// Tests that priority clients get a connection slot even if the pool is full of free
+// clients, and that free clients can't take the slots of priority clients.
+func TestClientPoolPriority(t *testing.T) {
+	var (
+		clock     mclock.Simulated
+		connLimit = 4
+		pool      = newClientPool(ethdb.NewMemDatabase(), connLimit, common.Address{}, nil, &clock)
+		free      = newPoolTestClients(connLimit)
+		paying    = newPoolTestClients(2 * connLimit)[connLimit:]
+	)
+	for _, c := range free {
+		if !c.connect(pool) {
+			t.Fatalf("free client %v rejected", c.address)
+		}
+	}
+	for _, c := range paying {
+		pool.addBalance(c.id, 1000000)
+	}
+	for i, c := range paying {
+		if !pool.isPriority(c.id) {
+			t.Fatalf("client %d with balance is not prioritized", i)
+		}
+		if !c.connect(pool) {
+			t.Fatalf("priority client %d rejected", i)
+		}
+		// Each priority client takes the slot of a free client.
+		kicked := 0
+		for _, f := range free {
+			if f.kicked {
+				kicked++
+			}
+		}
+		if kicked != i+1 {
+			t.Fatalf("wrong number of free clients kicked out: have %d, want %d", kicked, i+1)
+		}
+	}
+	// The pool is full of priority clients now.
+	extra := newPoolTestClients(3 * connLimit)[2*connLimit]
+	if extra.connect(pool) {
+		t.Fatal("free client accepted into pool full of priority clients")
+	}
+	pool.addBalance(extra.id, 1000000)
+	if extra.connect(pool) {
+		t.Fatal("priority client accepted over the connection limit")
+	}
+	// When a priority client leaves, its slot becomes available for free clients.
+	paying[0].disconnect(pool)
+	if !free[0].connect(pool) {
+		t.Fatal("free client rejected after priority client disconnected")
+	}
+	info := pool.clientInfo(free[0].id, free[0].address)
+	if !info.Connected || info.Priority {
+		t.Fatalf("wrong free client info: %+v", info)
+	}
+}
+
+// Tests that the balance of priority clients is charged for connection time and
+// requests, and that clients are disconnected when it runs out.
+func TestClientPoolBalance(t *testing.T) {
+	var (
+		clock  mclock.Simulated
+		db     = ethdb.NewMemDatabase()
+		pool   = newClientPool(db, 10, common.Address{}, nil, &clock)
+		client = newPoolTestClients(1)[0]
+	)
+	if _, _, err := pool.addBalance(client.id, -1); err != errNegativeResult {
+		t.Fatalf("wrong error for negative balance: %v", err)
+	}
+	if prev, next, err := pool.addBalance(client.id, 10000); err != nil || prev != 0 || next != 10000 {
+		t.Fatalf("wrong addBalance result: %d, %d, %v", prev, next, err)
+	}
+	if !client.connect(pool) {
+		t.Fatal("priority client rejected")
+	}
+	clock.Run(5 * time.Second)
+	if info := pool.clientInfo(client.id, client.address); info.Balance != 5000 || !info.Priority || !info.Connected {
+		t.Fatalf("wrong client info after 5s: %+v", info)
+	}
+	pool.requestCost(client.id, 2*requestPriceUnit)
+	if info := pool.clientInfo(client.id, client.address); info.Balance != 4998 {
+		t.Fatalf("wrong balance after request: %d", info.Balance)
+	}
+	// Many cheap requests add up without rounding losses.
+	for i := 0; i < 1000; i++ {
+		pool.requestCost(client.id, requestPriceUnit/1000)
+		clock.Run(time.Millisecond / 2)
+	}
+	if info := pool.clientInfo(client.id, client.address); info.Balance != 4997-500 {
+		t.Fatalf("wrong balance after many requests: %d", info.Balance)
+	}
+	// Funds added to connected clients are available immediately.
+	pool.addBalance(client.id, 503)
+	clock.Run(4 * time.Second)
+	if info := pool.clientInfo(client.id, client.address); info.Balance != 1000 {
+		t.Fatalf("wrong balance after funding: %d", info.Balance)
+	}
+	if client.kicked {
+		t.Fatal("client kicked out with positive balance")
+	}
+	clock.Run(time.Second)
+	if info := pool.clientInfo(client.id, client.address); info.Balance != 0 || !client.kicked {
+		t.Fatalf("client not kicked out with empty balance: %+v", info)
+	}
+	client.disconnect(pool)
+
+	// Without balance, the client reconnects as a free client.
+	if !client.connect(pool) {
+		t.Fatal("client rejected as free client")
+	}
+	if info := pool.clientInfo(client.id, client.address); info.Priority || !info.Connected {
+		t.Fatalf("wrong client info after reconnect: %+v", info)
+	}
+}
+
+// Tests that balances are persisted.
+func TestClientPoolPersistence(t *testing.T) {
+	var (
+		clock  mclock.Simulated
+		db     = ethdb.NewMemDatabase()
+		pool   = newClientPool(db, 10, common.Address{}, nil, &clock)
+		client = newPoolTestClients(1)[0]
+	)
+	pool.addBalance(client.id, 10000)
+	client.connect(pool)
+	clock.Run(time.Second)
+	pool.stop()
+
+	pool = newClientPool(db, 10, common.Address{}, nil, &clock)
+	if info := pool.clientInfo(client.id, ""); info.Balance != 9000 || info.Connected {
+		t.Fatalf("wrong client info after restart: %+v", info)
+	}
+}
+
+// Tests that cheques of a chequebook fund the balance of the client which signed them.
+func TestClientPoolCheque(t *testing.T) {
+	var (
+		clock          mclock.Simulated
+		clientKey, _   = crypto.GenerateKey()
+		clientAddr     = crypto.PubkeyToAddress(clientKey.PublicKey)
+		clientID       = enode.PubkeyToIDV4(&clientKey.PublicKey)
+		beneficiary    = common.Address{0xbe, 0xef}
+		otherRecipient = common.Address{0x01}
+		backend        = backends.NewSimulatedBackend(core.GenesisAlloc{clientAddr: {Balance: big.NewInt(1000000000)}}, 10000000)
+	)
+	dir, err := ioutil.TempDir("", "les-cheque-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// Set up a funded chequebook for the client.
+	contractAddr, _, _, err := contract.DeployChequebook(bind.NewKeyedTransactor(clientKey), backend)
+	if err != nil {
+		t.Fatal("can't deploy chequebook:", err)
+	}
+	backend.Commit()
+	chbook, err := chequebook.NewChequebook(filepath.Join(dir, "chequebook.json"), contractAddr, clientKey, backend)
+	if err != nil {
+		t.Fatal("can't create chequebook:", err)
+	}
+	if _, err := chbook.Deposit(big.NewInt(100000)); err != nil {
+		t.Fatal("deposit failed:", err)
+	}
+	backend.Commit()
+
+	pool := newClientPool(ethdb.NewMemDatabase(), 10, common.Address{}, backend, &clock)
+	cheque, _ := chbook.Issue(beneficiary, big.NewInt(10000))
+	if _, _, err := pool.receiveCheque(cheque); err != errNoBeneficiary {
+		t.Fatalf("wrong error without beneficiary: %v", err)
+	}
+	pool = newClientPool(ethdb.NewMemDatabase(), 10, beneficiary, nil, &clock)
+	if _, _, err := pool.receiveCheque(cheque); err != errNoChainBackend {
+		t.Fatalf("wrong error without chain backend: %v", err)
+	}
+
+	pool = newClientPool(ethdb.NewMemDatabase(), 10, beneficiary, backend, &clock)
+	if id, balance, err := pool.receiveCheque(cheque); err != nil || id != clientID || balance != 10000 {
+		t.Fatalf("wrong result for first cheque: %v, %d, %v", id, balance, err)
+	}
+	if _, _, err := pool.receiveCheque(cheque); err == nil {
+		t.Fatal("cheque accepted twice")
+	}
+	// Cheques carry the cumulative amount, only the difference is credited.
+	cheque, _ = chbook.Issue(beneficiary, big.NewInt(5000))
+	if _, balance, err := pool.receiveCheque(cheque); err != nil || balance != 15000 {
+		t.Fatalf("wrong result for second cheque: %d, %v", balance, err)
+	}
+	cheque, _ = chbook.Issue(otherRecipient, big.NewInt(5000))
+	if _, _, err := pool.receiveCheque(cheque); err == nil {
+		t.Fatal("cheque accepted for other beneficiary")
+	}
+	forged := *cheque
+	forged.Beneficiary = beneficiary
+	if id, _, err := pool.receiveCheque(&forged); err == nil && id == clientID {
+		t.Fatal("forged cheque credited to client")
+	}
+	if info := pool.clientInfo(clientID, ""); info.Balance != 15000 {
+		t.Fatalf("wrong client balance: %d", info.Balance)
+	}
+	// Cheques have to be drawn on a chequebook contract owned by the signer.
+	if _, _, err := pool.receiveCheque(signCheque(clientKey, otherRecipient, beneficiary, 20000)); err != errInvalidChequebook {
+		t.Fatalf("wrong error for cheque without chequebook: %v", err)
+	}
+	otherKey, _ := crypto.GenerateKey()
+	if _, _, err := pool.receiveCheque(signCheque(otherKey, contractAddr, beneficiary, 20000)); err != errWrongChequeSigner {
+		t.Fatalf("wrong error for cheque not signed by owner: %v", err)
+	}
+	// Only the amount covered by the chequebook balance is credited.
+	cheque = signCheque(clientKey, contractAddr, beneficiary, 150000)
+	if _, balance, err := pool.receiveCheque(cheque); err != nil || balance != 100000 {
+		t.Fatalf("wrong result for uncovered cheque: %d, %v", balance, err)
+	}
+	if _, _, err := pool.receiveCheque(cheque); err != errUncoveredCheque {
+		t.Fatalf("wrong error for cheque exceeding chequebook balance: %v", err)
+	}
+	if _, err := chbook.Deposit(big.NewInt(100000)); err != nil {
+		t.Fatal("deposit failed:", err)
+	}
+	backend.Commit()
+	if _, balance, err := pool.receiveCheque(cheque); err != nil || balance != 150000 {
+		t.Fatalf("wrong result for cheque after deposit: %d, %v", balance, err)
+	}
+}
+
+// signCheque creates a cheque for the given cumulative amount signed by key.
+func signCheque(key *ecdsa.PrivateKey, contract, beneficiary common.Address, amount int64) *chequebook.Cheque {
+	amount32 := common.LeftPadBytes(big.NewInt(amount).Bytes(), 32)
+	sig, _ := crypto.Sign(crypto.Keccak256(contract.Bytes(), beneficiary.Bytes(), amount32), key)
+	return &chequebook.Cheque{Contract: contract, Beneficiary: beneficiary, Amount: big.NewInt(amount), Sig: sig}
+}
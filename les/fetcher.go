@@ -384,6 +384,18 @@ func (f *lightFetcher) peerHasBlock(p *peer, hash common.Hash, number uint64, ha
 	return rawdb.ReadCanonicalHash(f.pm.chainDb, fp.root.number) == fp.root.hash && rawdb.ReadCanonicalHash(f.pm.chainDb, number) == hash
 }
 
+// trustedAnnounced returns true if the given head has been announced by enough
+// trusted servers to be accepted by an ultra light client
+func (f *lightFetcher) trustedAnnounced(hash common.Hash) bool {
+	count := 0
+	for p, fp := range f.peers {
+		if p.trusted && fp.nodeByHash[hash] != nil {
+			count++
+		}
+	}
+	return f.pm.ulc.enoughTrusted(count)
+}
+
 // requestAmount calculates the amount of headers to be downloaded starting
 // from a certain head backwards
 func (f *lightFetcher) requestAmount(p *peer, n *fetcherTreeNode) uint64 {
@@ -419,6 +431,10 @@ func (f *lightFetcher) nextRequest() (*distReq, uint64, bool) {
 
 	for p, fp := range f.peers {
 		for hash, n := range fp.nodeByHash {
+			if f.pm.ulc != nil && !f.trustedAnnounced(hash) {
+				// ultra light clients only follow heads confirmed by the trusted servers
+				continue
+			}
 			if !f.checkKnownNode(p, n) && !n.requested && (bestTd == nil || n.td.Cmp(bestTd) >= 0) {
 				amount := f.requestAmount(p, n)
 				if bestTd == nil || n.td.Cmp(bestTd) > 0 || amount < bestAmount {
@@ -443,6 +459,9 @@ func (f *lightFetcher) nextRequest() (*distReq, uint64, bool) {
 			},
 			canSend: func(dp distPeer) bool {
 				p := dp.(*peer)
+				if f.pm.ulc != nil && !p.trusted {
+					return false
+				}
 				f.lock.Lock()
 				defer f.lock.Unlock()
 
@@ -467,6 +486,9 @@ func (f *lightFetcher) nextRequest() (*distReq, uint64, bool) {
 			},
 			canSend: func(dp distPeer) bool {
 				p := dp.(*peer)
+				if f.pm.ulc != nil && !p.trusted {
+					return false
+				}
 				f.lock.Lock()
 				defer f.lock.Unlock()
 
@@ -520,7 +542,12 @@ func (f *lightFetcher) processResponse(req fetchRequest, resp fetchResponse) boo
 	for i, header := range resp.headers {
 		headers[int(req.amount)-1-i] = header
 	}
-	if _, err := f.chain.InsertHeaderChain(headers, 1); err != nil {
+	// headers confirmed by the trusted servers of an ultra light client are not verified
+	checkFreq := 1
+	if f.pm.ulc != nil {
+		checkFreq = 0
+	}
+	if _, err := f.chain.InsertHeaderChain(headers, checkFreq); err != nil {
 		if err == consensus.ErrFutureBlock {
 			return true
 		}
@@ -100,11 +100,16 @@ func (self *ClientManager) Stop() {
 
 func (self *ClientManager) addNode(cnode *ClientNode) *cmNode {
 	time := mclock.Now()
+	// Recharge capacity is shared in proportion to the guaranteed recharge rate
+	weight := cnode.params.MinRecharge
+	if weight == 0 {
+		weight = 1
+	}
 	node := &cmNode{
 		node:           cnode,
 		lastUpdate:     time,
 		finishRecharge: time,
-		rcWeight:       1,
+		rcWeight:       weight,
 	}
 	self.lock.Lock()
 	defer self.lock.Unlock()
@@ -93,7 +93,7 @@ func (f *freeClientPool) connect(address string, disconnectFn func()) bool {
 	f.lock.Lock()
 	defer f.lock.Unlock()
 
-	if f.closed {
+	if f.closed || f.connectedLimit <= 0 {
 		return false
 	}
 	e := f.addressMap[address]
@@ -151,7 +151,7 @@ func (f *freeClientPool) disconnect(address string) {
 	}
 	e := f.addressMap[address]
 	now := f.clock.Now()
-	if !e.connected {
+	if e == nil || !e.connected {
 		log.Debug("Client already disconnected", "address", address)
 		return
 	}
@@ -163,6 +163,34 @@ func (f *freeClientPool) disconnect(address string) {
 	log.Debug("Client disconnected", "address", address)
 }
 
+// connected reports whether a client with the given address is connected.
+func (f *freeClientPool) connected(address string) bool {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	e := f.addressMap[address]
+	return e != nil && e.connected
+}
+
+// setConnectedLimit changes the number of free clients allowed to be connected
+// at the same time. If the new limit is lower than the number of connected clients,
+// the ones with the highest recent usage are kicked out.
+func (f *freeClientPool) setConnectedLimit(limit int) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	f.connectedLimit = limit
+	now := f.clock.Now()
+	for f.connPool.Size() > limit {
+		i := f.connPool.PopItem().(*freeClientPoolEntry)
+		f.calcLogUsage(i, now)
+		i.connected = false
+		f.disconnPool.Push(i, -i.logUsage)
+		log.Debug("Client kicked out", "address", i.address)
+		i.disconnectFn()
+	}
+}
+
 // logOffset calculates the time-dependent offset for the logarithmic
 // representation of recent usage
 func (f *freeClientPool) logOffset(now mclock.AbsTime) int64 {
@@ -33,13 +33,14 @@ import (
 	"github.com/athofficial/go-ath/core/rawdb"
 	"github.com/athofficial/go-ath/core/state"
 	"github.com/athofficial/go-ath/core/types"
+	"github.com/athofficial/go-ath/eth"
 	"github.com/athofficial/go-ath/eth/downloader"
 	"github.com/athofficial/go-ath/ethdb"
 	"github.com/athofficial/go-ath/event"
 	"github.com/athofficial/go-ath/light"
 	"github.com/athofficial/go-ath/log"
 	"github.com/athofficial/go-ath/p2p"
-	"github.com/athofficial/go-ath/p2p/discv5"
+	"github.com/athofficial/go-ath/p2p/discover"
 	"github.com/athofficial/go-ath/params"
 	"github.com/athofficial/go-ath/rlp"
 	"github.com/athofficial/go-ath/trie"
@@ -100,8 +101,10 @@ type ProtocolManager struct {
 	odr         *LesOdr
 	server      *LesServer
 	serverPool  *serverPool
-	clientPool  *freeClientPool
-	lesTopic    discv5.Topic
+	clientPool  *clientPool
+	poolLock    sync.RWMutex // protects clientPool against concurrent API access
+	ulc         *ulc
+	lesTopic    discover.Topic
 	reqDist     *requestDistributor
 	retriever   *retrieveManager
 
@@ -124,7 +127,7 @@ type ProtocolManager struct {
 
 // NewProtocolManager returns a new ethereum sub protocol manager. The Ethereum sub protocol manages peers capable
 // with the ethereum network.
-func NewProtocolManager(chainConfig *params.ChainConfig, indexerConfig *light.IndexerConfig, lightSync bool, networkId uint64, mux *event.TypeMux, engine consensus.Engine, peers *peerSet, blockchain BlockChain, txpool txPool, chainDb ethdb.Database, odr *LesOdr, txrelay *LesTxRelay, serverPool *serverPool, quitSync chan struct{}, wg *sync.WaitGroup) (*ProtocolManager, error) {
+func NewProtocolManager(chainConfig *params.ChainConfig, indexerConfig *light.IndexerConfig, lightSync bool, networkId uint64, mux *event.TypeMux, engine consensus.Engine, peers *peerSet, blockchain BlockChain, txpool txPool, chainDb ethdb.Database, odr *LesOdr, txrelay *LesTxRelay, serverPool *serverPool, ulcConfig *eth.ULCConfig, quitSync chan struct{}, wg *sync.WaitGroup) (*ProtocolManager, error) {
 	// Create the protocol manager with the base fields
 	manager := &ProtocolManager{
 		lightSync:   lightSync,
@@ -155,7 +158,17 @@ func NewProtocolManager(chainConfig *params.ChainConfig, indexerConfig *light.In
 	}
 
 	if lightSync {
-		manager.downloader = downloader.New(downloader.LightSync, chainDb, manager.eventMux, nil, blockchain, removePeer)
+		ulc, err := newULC(ulcConfig)
+		if err != nil {
+			return nil, err
+		}
+		manager.ulc = ulc
+
+		var lightchain downloader.LightChain = blockchain
+		if ulc != nil {
+			lightchain = ulcHeaderChain{blockchain}
+		}
+		manager.downloader = downloader.New(downloader.LightSync, chainDb, manager.eventMux, nil, lightchain, removePeer)
 		manager.peers.notify((*downloaderPeerNotify)(manager))
 		manager.fetcher = newLightFetcher(manager)
 	}
@@ -163,6 +176,14 @@ func NewProtocolManager(chainConfig *params.ChainConfig, indexerConfig *light.In
 	return manager, nil
 }
 
+// getClientPool returns the client pool of a server, or nil if it is not started.
+func (pm *ProtocolManager) getClientPool() *clientPool {
+	pm.poolLock.RLock()
+	defer pm.poolLock.RUnlock()
+
+	return pm.clientPool
+}
+
 // removePeer initiates disconnection from a peer by removing it from the peer set
 func (pm *ProtocolManager) removePeer(id string) {
 	pm.peers.Unregister(id)
@@ -174,7 +195,19 @@ func (pm *ProtocolManager) Start(maxPeers int) {
 	if pm.lightSync {
 		go pm.syncer()
 	} else {
-		pm.clientPool = newFreeClientPool(pm.chainDb, maxPeers, 10000, mclock.System{})
+		var (
+			beneficiary common.Address
+			backend     chequeBackend
+		)
+		if pm.server != nil {
+			if pm.server.config != nil {
+				beneficiary = pm.server.config.Etherbase
+			}
+			backend = pm.server.chequeBackend
+		}
+		pm.poolLock.Lock()
+		pm.clientPool = newClientPool(pm.chainDb, maxPeers, beneficiary, backend, mclock.System{})
+		pm.poolLock.Unlock()
 		go func() {
 			for range pm.newPeerCh {
 			}
@@ -234,7 +267,9 @@ func (pm *ProtocolManager) runPeer(version uint, p *p2p.Peer, rw p2p.MsgReadWrit
 }
 
 func (pm *ProtocolManager) newPeer(pv int, nv uint64, p *p2p.Peer, rw p2p.MsgReadWriter) *peer {
-	return newPeer(pv, nv, p, newMeteredMsgWriter(rw))
+	peer := newPeer(pv, nv, p, newMeteredMsgWriter(rw))
+	peer.trusted = pm.ulc != nil && pm.ulc.isTrusted(p.ID())
+	return peer
 }
 
 // handle is the callback invoked to manage the life cycle of a les peer. When
@@ -256,6 +291,10 @@ func (pm *ProtocolManager) handle(p *peer) error {
 		number  = head.Number.Uint64()
 		td      = pm.blockchain.GetTd(hash, number)
 	)
+	if pm.server != nil {
+		priority := pm.clientPool != nil && pm.clientPool.isPriority(p.ID())
+		p.fcParams = pm.server.clientParams(priority)
+	}
 	if err := p.Handshake(td, hash, number, genesis.Hash(), pm.server); err != nil {
 		p.Log().Debug("Light ATH handshake failed", "err", err)
 		return err
@@ -266,10 +305,10 @@ func (pm *ProtocolManager) handle(p *peer) error {
 		// test peer address is not a tcp address, don't use client pool if can not typecast
 		if ok {
 			id := addr.IP.String()
-			if !pm.clientPool.connect(id, func() { go pm.removePeer(p.id) }) {
+			if !pm.clientPool.connect(p.ID(), id, func() { go pm.removePeer(p.id) }) {
 				return p2p.DiscTooManyPeers
 			}
-			defer pm.clientPool.disconnect(id)
+			defer pm.clientPool.disconnect(p.ID(), id)
 		}
 	}
 
@@ -347,14 +386,17 @@ func (pm *ProtocolManager) handleMsg(p *peer) error {
 		}
 		bufValue, _ := p.fcClient.AcceptRequest()
 		cost := costs.baseCost + reqCnt*costs.reqCost
-		if cost > pm.server.defParams.BufLimit {
-			cost = pm.server.defParams.BufLimit
+		if cost > p.fcParams.BufLimit {
+			cost = p.fcParams.BufLimit
 		}
 		if cost > bufValue {
-			recharge := time.Duration((cost - bufValue) * 1000000 / pm.server.defParams.MinRecharge)
+			recharge := time.Duration((cost - bufValue) * 1000000 / p.fcParams.MinRecharge)
 			p.Log().Error("Request came too early", "recharge", common.PrettyDuration(recharge))
 			return true
 		}
+		if pm.clientPool != nil {
+			pm.clientPool.requestCost(p.ID(), cost)
+		}
 		return false
 	}
 
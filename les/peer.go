@@ -63,7 +63,8 @@ type peer struct {
 
 	announceType, requestAnnounceType uint64
 
-	id string
+	id      string
+	trusted bool // trusted server of the ultra light client mode
 
 	headInfo *announceData
 	lock     sync.RWMutex
@@ -75,8 +76,9 @@ type peer struct {
 	hasBlock       func(common.Hash, uint64, bool) bool
 	responseErrors int
 
-	fcClient       *flowcontrol.ClientNode // nil if the peer is server only
-	fcServer       *flowcontrol.ServerNode // nil if the peer is client only
+	fcClient       *flowcontrol.ClientNode   // nil if the peer is server only
+	fcParams       *flowcontrol.ServerParams // flow control parameters assigned to a client peer
+	fcServer       *flowcontrol.ServerNode   // nil if the peer is client only
 	fcServerParams *flowcontrol.ServerParams
 	fcCosts        requestCostTable
 }
@@ -444,13 +446,20 @@ func (p *peer) Handshake(td *big.Int, head common.Hash, headNum uint64, genesis
 		send = send.add("serveChainSince", uint64(0))
 		send = send.add("serveStateSince", uint64(0))
 		send = send.add("txRelay", nil)
-		send = send.add("flowControl/BL", server.defParams.BufLimit)
-		send = send.add("flowControl/MRR", server.defParams.MinRecharge)
+		if p.fcParams == nil {
+			p.fcParams = server.defParams
+		}
+		send = send.add("flowControl/BL", p.fcParams.BufLimit)
+		send = send.add("flowControl/MRR", p.fcParams.MinRecharge)
 		list := server.fcCostStats.getCurrentList()
 		send = send.add("flowControl/MRC", list)
 		p.fcCosts = list.decode()
 	} else {
-		p.requestAnnounceType = announceTypeSimple // set to default until "very light" client mode is implemented
+		// trusted servers of the ultra light client are required to sign their announcements
+		p.requestAnnounceType = announceTypeSimple
+		if p.trusted {
+			p.requestAnnounceType = announceTypeSigned
+		}
 		send = send.add("announceType", p.requestAnnounceType)
 	}
 	recvList, err := p.sendReceiveHandshake(send)
@@ -499,7 +508,7 @@ func (p *peer) Handshake(td *big.Int, head common.Hash, headNum uint64, genesis
 		if recv.get("announceType", &p.announceType) != nil {
 			p.announceType = announceTypeSimple
 		}
-		p.fcClient = flowcontrol.NewClientNode(server.fcManager, server.defParams)
+		p.fcClient = flowcontrol.NewClientNode(server.fcManager, p.fcParams)
 	} else {
 		if recv.get("serveChainSince", nil) != nil {
 			return errResp(ErrUselessPeer, "peer cannot serve chain")
@@ -18,45 +18,57 @@
 package les
 
 import (
+	"context"
 	"crypto/ecdsa"
 	"encoding/binary"
 	"math"
+	"math/big"
 	"sync"
 
+	"github.com/athofficial/go-ath"
 	"github.com/athofficial/go-ath/common"
+	"github.com/athofficial/go-ath/common/hexutil"
 	"github.com/athofficial/go-ath/core"
 	"github.com/athofficial/go-ath/core/rawdb"
 	"github.com/athofficial/go-ath/core/types"
 	"github.com/athofficial/go-ath/eth"
 	"github.com/athofficial/go-ath/ethdb"
+	"github.com/athofficial/go-ath/internal/ethapi"
 	"github.com/athofficial/go-ath/les/flowcontrol"
 	"github.com/athofficial/go-ath/light"
 	"github.com/athofficial/go-ath/log"
 	"github.com/athofficial/go-ath/p2p"
-	"github.com/athofficial/go-ath/p2p/discv5"
+	"github.com/athofficial/go-ath/p2p/discover"
 	"github.com/athofficial/go-ath/params"
 	"github.com/athofficial/go-ath/rlp"
+	"github.com/athofficial/go-ath/rpc"
 )
 
+// priorityCapacityFactor is the flow control capacity of priority clients relative
+// to free clients.
+const priorityCapacityFactor = 4
+
 type LesServer struct {
 	lesCommons
 
-	fcManager   *flowcontrol.ClientManager // nil if our node is client only
-	fcCostStats *requestCostStats
-	defParams   *flowcontrol.ServerParams
-	lesTopics   []discv5.Topic
-	privateKey  *ecdsa.PrivateKey
-	quitSync    chan struct{}
+	fcManager      *flowcontrol.ClientManager // nil if our node is client only
+	fcCostStats    *requestCostStats
+	defParams      *flowcontrol.ServerParams
+	priorityParams *flowcontrol.ServerParams // flow control parameters of clients with a positive balance
+	lesTopics      []discover.Topic
+	privateKey     *ecdsa.PrivateKey
+	chequeBackend  chequeBackend // chain access for validating the cheques of clients
+	quitSync       chan struct{}
 }
 
 func NewLesServer(eth *eth.Ethereum, config *eth.Config) (*LesServer, error) {
 	quitSync := make(chan struct{})
-	pm, err := NewProtocolManager(eth.BlockChain().Config(), light.DefaultServerIndexerConfig, false, config.NetworkId, eth.EventMux(), eth.Engine(), newPeerSet(), eth.BlockChain(), eth.TxPool(), eth.ChainDb(), nil, nil, nil, quitSync, new(sync.WaitGroup))
+	pm, err := NewProtocolManager(eth.BlockChain().Config(), light.DefaultServerIndexerConfig, false, config.NetworkId, eth.EventMux(), eth.Engine(), newPeerSet(), eth.BlockChain(), eth.TxPool(), eth.ChainDb(), nil, nil, nil, nil, quitSync, new(sync.WaitGroup))
 	if err != nil {
 		return nil, err
 	}
 
-	lesTopics := make([]discv5.Topic, len(AdvertiseProtocolVersions))
+	lesTopics := make([]discover.Topic, len(AdvertiseProtocolVersions))
 	for i, pv := range AdvertiseProtocolVersions {
 		lesTopics[i] = lesTopic(eth.BlockChain().Genesis().Hash(), pv)
 	}
@@ -98,10 +110,16 @@ func NewLesServer(eth *eth.Ethereum, config *eth.Config) (*LesServer, error) {
 	srv.chtIndexer.Start(eth.BlockChain())
 	pm.server = srv
 
+	srv.chequeBackend = &chainChequeBackend{api: ethapi.NewPublicBlockChainAPI(eth.APIBackend)}
+
 	srv.defParams = &flowcontrol.ServerParams{
 		BufLimit:    300000000,
 		MinRecharge: 50000,
 	}
+	srv.priorityParams = &flowcontrol.ServerParams{
+		BufLimit:    srv.defParams.BufLimit * priorityCapacityFactor,
+		MinRecharge: srv.defParams.MinRecharge * priorityCapacityFactor,
+	}
 	srv.fcManager = flowcontrol.NewClientManager(uint64(config.LightServ), 10, 1000000000)
 	srv.fcCostStats = newCostStats(eth.ChainDb())
 	return srv, nil
@@ -111,6 +129,26 @@ func (s *LesServer) Protocols() []p2p.Protocol {
 	return s.makeProtocols(ServerProtocolVersions)
 }
 
+// APIs returns the RPC APIs of the LES server.
+func (s *LesServer) APIs() []rpc.API {
+	return []rpc.API{
+		{
+			Namespace: "les",
+			Version:   "1.0",
+			Service:   NewPrivateLightServerAPI(s),
+			Public:    false,
+		},
+	}
+}
+
+// clientParams returns the flow control parameters assigned to a client.
+func (s *LesServer) clientParams(priority bool) *flowcontrol.ServerParams {
+	if priority && s.priorityParams != nil {
+		return s.priorityParams
+	}
+	return s.defParams
+}
+
 // Start starts the LES server
 func (s *LesServer) Start(srvr *p2p.Server) {
 	s.protocolManager.Start(s.config.LightPeers)
@@ -146,6 +184,56 @@ func (s *LesServer) Stop() {
 	s.protocolManager.Stop()
 }
 
+// chainChequeBackend validates cheques directly against the state of the local
+// chain, without going through an RPC client.
+type chainChequeBackend struct {
+	api *ethapi.PublicBlockChainAPI
+}
+
+// toBlockNumber converts a block number of the contract backend interface, nil
+// meaning the latest block.
+func toBlockNumber(number *big.Int) rpc.BlockNumber {
+	if number == nil {
+		return rpc.LatestBlockNumber
+	}
+	return rpc.BlockNumber(number.Int64())
+}
+
+func (b *chainChequeBackend) CodeAt(ctx context.Context, contract common.Address, blockNumber *big.Int) ([]byte, error) {
+	return b.api.GetCode(ctx, contract, toBlockNumber(blockNumber))
+}
+
+func (b *chainChequeBackend) CallContract(ctx context.Context, call ethereum.CallMsg, blockNumber *big.Int) ([]byte, error) {
+	args := ethapi.CallArgs{
+		From: call.From,
+		To:   call.To,
+		Gas:  hexutil.Uint64(call.Gas),
+		Data: call.Data,
+	}
+	if call.GasPrice != nil {
+		args.GasPrice = hexutil.Big(*call.GasPrice)
+	}
+	if call.Value != nil {
+		args.Value = hexutil.Big(*call.Value)
+	}
+	return b.api.Call(ctx, args, toBlockNumber(blockNumber))
+}
+
+func (b *chainChequeBackend) BalanceAt(ctx context.Context, account common.Address, blockNumber *big.Int) (*big.Int, error) {
+	balance, err := b.api.GetBalance(ctx, account, toBlockNumber(blockNumber))
+	if err != nil {
+		return nil, err
+	}
+	if balance == nil {
+		return nil, ethereum.NotFound
+	}
+	return balance.ToInt(), nil
+}
+
+func (b *chainChequeBackend) StorageAt(ctx context.Context, account common.Address, key common.Hash, blockNumber *big.Int) ([]byte, error) {
+	return b.api.GetStorageAt(ctx, account, key.Hex(), toBlockNumber(blockNumber))
+}
+
 type requestCosts struct {
 	baseCost, reqCost uint64
 }
@@ -346,33 +434,16 @@ func (pm *ProtocolManager) blockLoop() {
 						log.Debug("Announcing block to peers", "number", number, "hash", hash, "td", td, "reorg", reorg)
 
 						announce := announceData{Hash: hash, Number: number, Td: td, ReorgDepth: reorg}
-						var (
-							signed         bool
-							signedAnnounce announceData
-						)
+						announce.sign(pm.server.privateKey)
 
 						for _, p := range peers {
-							switch p.announceType {
-
-							case announceTypeSimple:
-								select {
-								case p.announceChn <- announce:
-								default:
-									pm.removePeer(p.id)
-								}
-
-							case announceTypeSigned:
-								if !signed {
-									signedAnnounce = announce
-									signedAnnounce.sign(pm.server.privateKey)
-									signed = true
-								}
-
-								select {
-								case p.announceChn <- signedAnnounce:
-								default:
-									pm.removePeer(p.id)
-								}
+							if p.announceType == announceTypeNone {
+								continue
+							}
+							select {
+							case p.announceChn <- announce:
+							default:
+								pm.removePeer(p.id)
 							}
 						}
 					}
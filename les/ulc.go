@@ -0,0 +1,87 @@
+// Copyright 2019 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package les
+
+import (
+	"fmt"
+
+	"github.com/athofficial/go-ath/core/types"
+	"github.com/athofficial/go-ath/eth"
+	"github.com/athofficial/go-ath/log"
+	"github.com/athofficial/go-ath/p2p/enode"
+)
+
+// ulc holds the settings of the ultra light client mode. In this mode the client
+// only accepts a new head once it has been announced by a sufficient fraction of
+// the trusted servers, and headers are inserted without verifying their seals.
+type ulc struct {
+	servers            []*enode.Node
+	trustedKeys        map[enode.ID]struct{}
+	minTrustedFraction int
+}
+
+// newULC creates the ultra light client settings from the given configuration.
+// It returns nil if no trusted servers are configured.
+func newULC(config *eth.ULCConfig) (*ulc, error) {
+	if config == nil || len(config.TrustedServers) == 0 {
+		return nil, nil
+	}
+	u := &ulc{
+		trustedKeys:        make(map[enode.ID]struct{}),
+		minTrustedFraction: config.MinTrustedFraction,
+	}
+	if u.minTrustedFraction <= 0 || u.minTrustedFraction > 100 {
+		log.Warn("Invalid minimum trusted fraction, using default", "fraction", config.MinTrustedFraction, "default", eth.DefaultULCMinTrustedFraction)
+		u.minTrustedFraction = eth.DefaultULCMinTrustedFraction
+	}
+	for _, url := range config.TrustedServers {
+		node, err := enode.ParseV4(url)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trusted server %q: %v", url, err)
+		}
+		if _, ok := u.trustedKeys[node.ID()]; ok {
+			continue
+		}
+		u.servers = append(u.servers, node)
+		u.trustedKeys[node.ID()] = struct{}{}
+	}
+	return u, nil
+}
+
+// isTrusted returns true if the server with the given ID is trusted.
+func (u *ulc) isTrusted(id enode.ID) bool {
+	_, ok := u.trustedKeys[id]
+	return ok
+}
+
+// enoughTrusted returns true if the given number of trusted servers is enough
+// to accept an announced head.
+func (u *ulc) enoughTrusted(count int) bool {
+	return count*100 >= u.minTrustedFraction*len(u.trustedKeys)
+}
+
+// ulcHeaderChain wraps the light chain used by the downloader in ultra light client
+// mode. Synchronisation is only done with trusted servers, so the seals of the
+// downloaded headers are not verified.
+type ulcHeaderChain struct {
+	BlockChain
+}
+
+// InsertHeaderChain inserts a batch of headers without verifying their seals.
+func (c ulcHeaderChain) InsertHeaderChain(chain []*types.Header, checkFreq int) (int, error) {
+	return c.BlockChain.InsertHeaderChain(chain, 0)
+}
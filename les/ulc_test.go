@@ -0,0 +1,111 @@
+// Copyright 2019 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package les
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/athofficial/go-ath/crypto"
+	"github.com/athofficial/go-ath/eth"
+	"github.com/athofficial/go-ath/ethdb"
+	"github.com/athofficial/go-ath/light"
+	"github.com/athofficial/go-ath/p2p/enode"
+)
+
+// testServerURL returns the enode URL of a test LES server.
+func testServerURL(pm *ProtocolManager) string {
+	return enode.NewV4(&pm.server.privateKey.PublicKey, net.IP{127, 0, 0, 1}, 30303, 30303).String()
+}
+
+// newTestULCClient creates an ultra light client protocol manager for testing.
+func newTestULCClient(t *testing.T, config *eth.ULCConfig) *ProtocolManager {
+	db, peers := ethdb.NewMemDatabase(), newPeerSet()
+	dist := newRequestDistributor(peers, make(chan struct{}))
+	odr := NewLesOdr(db, light.TestClientIndexerConfig, newRetrieveManager(peers, dist, nil))
+	return newTestProtocolManagerMust(t, true, 0, nil, odr, peers, db, config)
+}
+
+func TestNewULC(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	url := enode.NewV4(&key.PublicKey, net.IP{127, 0, 0, 1}, 30303, 30303).String()
+
+	if u, err := newULC(&eth.ULCConfig{}); u != nil || err != nil {
+		t.Fatalf("ultra light client enabled without trusted servers: %v, %v", u, err)
+	}
+	if _, err := newULC(&eth.ULCConfig{TrustedServers: []string{"invalid"}}); err == nil {
+		t.Fatal("invalid trusted server accepted")
+	}
+	u, err := newULC(&eth.ULCConfig{TrustedServers: []string{url, url}, MinTrustedFraction: 200})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(u.servers) != 1 || !u.isTrusted(enode.PubkeyToIDV4(&key.PublicKey)) {
+		t.Fatalf("wrong trusted servers: %v", u.servers)
+	}
+	if u.minTrustedFraction != eth.DefaultULCMinTrustedFraction {
+		t.Fatalf("wrong minimum trusted fraction: have %d, want %d", u.minTrustedFraction, eth.DefaultULCMinTrustedFraction)
+	}
+}
+
+// Tests that an ultra light client only follows a head after enough trusted
+// servers have announced it.
+func TestULCSyncWithTrustedServers(t *testing.T) {
+	var (
+		server1   = newTestProtocolManagerMust(t, false, 4, testChainGen, nil, nil, ethdb.NewMemDatabase(), nil)
+		server2   = newTestProtocolManagerMust(t, false, 4, testChainGen, nil, nil, ethdb.NewMemDatabase(), nil)
+		untrusted = newTestProtocolManagerMust(t, false, 4, testChainGen, nil, nil, ethdb.NewMemDatabase(), nil)
+		client    = newTestULCClient(t, &eth.ULCConfig{
+			TrustedServers:     []string{testServerURL(server1), testServerURL(server2)},
+			MinTrustedFraction: 100,
+		})
+		head = server1.blockchain.CurrentHeader()
+	)
+	connect := func(server *ProtocolManager) {
+		_, err1, _, err2 := newTestPeerPair("peer", lpv2, server, client)
+		select {
+		case <-time.After(100 * time.Millisecond):
+		case err := <-err1:
+			t.Fatalf("server handshake error: %v", err)
+		case err := <-err2:
+			t.Fatalf("client handshake error: %v", err)
+		}
+	}
+	connect(untrusted)
+	connect(server1)
+	time.Sleep(100 * time.Millisecond)
+	if number := client.blockchain.CurrentHeader().Number.Uint64(); number != 0 {
+		t.Fatalf("client synced to block %d announced by too few trusted servers", number)
+	}
+	for _, p := range client.peers.AllPeers() {
+		if p.trusted != (p.requestAnnounceType == announceTypeSigned) {
+			t.Fatalf("wrong announcement type %d requested from server (trusted: %v)", p.requestAnnounceType, p.trusted)
+		}
+	}
+
+	connect(server2)
+	for i := 0; ; i++ {
+		if client.blockchain.CurrentHeader().Hash() == head.Hash() {
+			break
+		}
+		if i == 50 {
+			t.Fatalf("client did not sync to trusted head: have %d, want %d", client.blockchain.CurrentHeader().Number, head.Number)
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+}
@@ -533,3 +533,9 @@ func (self *LightChain) SubscribeLogsEvent(ch chan<- []*types.Log) event.Subscri
 func (self *LightChain) SubscribeRemovedLogsEvent(ch chan<- core.RemovedLogsEvent) event.Subscription {
 	return self.scope.Track(new(event.Feed).Subscribe(ch))
 }
+
+// SubscribeChainReorgEvent implements the interface of filters.Backend
+// LightChain does not send core.ChainReorgEvent, so return an empty subscription.
+func (self *LightChain) SubscribeChainReorgEvent(ch chan<- core.ChainReorgEvent) event.Subscription {
+	return self.scope.Track(new(event.Feed).Subscribe(ch))
+}
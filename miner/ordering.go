@@ -0,0 +1,148 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package miner
+
+import (
+	"fmt"
+
+	"github.com/athofficial/go-ath/common"
+	"github.com/athofficial/go-ath/core/types"
+)
+
+const (
+	// PriceOrdering orders all pending transactions strictly by gas price.
+	PriceOrdering = "price"
+
+	// LocalsFirstOrdering commits the transactions of local accounts before any
+	// remote ones, each group ordered by gas price. This is the default.
+	LocalsFirstOrdering = "locals"
+)
+
+// TxOrderingPolicy decides which pending transactions the worker attempts to
+// include in a block, and in which order.
+type TxOrderingPolicy interface {
+	// Order groups the pending transactions into sets which are committed one
+	// after the other. The transactions of an account must be nonce ordered, as
+	// returned by the transaction pool. The pending map is reowned by the policy.
+	Order(signer types.Signer, pending map[common.Address]types.Transactions, locals []common.Address) []*types.TransactionsByPriceAndNonce
+}
+
+// NewTxOrderingPolicy creates the transaction ordering policy with the given
+// name. If any addresses are blacklisted, the policy is wrapped so that those
+// senders and contracts are never included.
+func NewTxOrderingPolicy(name string, blacklist []common.Address) (TxOrderingPolicy, error) {
+	var policy TxOrderingPolicy
+	switch name {
+	case PriceOrdering:
+		policy = new(priceOrdering)
+	case LocalsFirstOrdering, "":
+		policy = new(localsFirstOrdering)
+	default:
+		return nil, fmt.Errorf("unknown transaction ordering policy %q", name)
+	}
+	if len(blacklist) > 0 {
+		policy = NewAddressFilter(policy, blacklist)
+	}
+	return policy, nil
+}
+
+// priceOrdering is a TxOrderingPolicy committing all pending transactions by gas
+// price, without any preference for local accounts.
+type priceOrdering struct{}
+
+// Order implements TxOrderingPolicy, returning a single price ordered set.
+func (p *priceOrdering) Order(signer types.Signer, pending map[common.Address]types.Transactions, locals []common.Address) []*types.TransactionsByPriceAndNonce {
+	if len(pending) == 0 {
+		return nil
+	}
+	return []*types.TransactionsByPriceAndNonce{types.NewTransactionsByPriceAndNonce(signer, pending)}
+}
+
+// localsFirstOrdering is a TxOrderingPolicy committing the transactions of local
+// accounts before those of remote accounts.
+type localsFirstOrdering struct{}
+
+// Order implements TxOrderingPolicy, splitting the pending transactions into a
+// local and a remote set, each of them price ordered.
+func (p *localsFirstOrdering) Order(signer types.Signer, pending map[common.Address]types.Transactions, locals []common.Address) []*types.TransactionsByPriceAndNonce {
+	localTxs, remoteTxs := make(map[common.Address]types.Transactions), make(map[common.Address]types.Transactions)
+	for account, txs := range pending {
+		remoteTxs[account] = txs
+	}
+	for _, account := range locals {
+		if txs := remoteTxs[account]; len(txs) > 0 {
+			delete(remoteTxs, account)
+			localTxs[account] = txs
+		}
+	}
+	var sets []*types.TransactionsByPriceAndNonce
+	if len(localTxs) > 0 {
+		sets = append(sets, types.NewTransactionsByPriceAndNonce(signer, localTxs))
+	}
+	if len(remoteTxs) > 0 {
+		sets = append(sets, types.NewTransactionsByPriceAndNonce(signer, remoteTxs))
+	}
+	return sets
+}
+
+// AddressFilter is a TxOrderingPolicy which drops all transactions sent from or
+// to a set of blacklisted addresses before deferring to another policy.
+type AddressFilter struct {
+	policy    TxOrderingPolicy
+	blacklist map[common.Address]struct{}
+}
+
+// NewAddressFilter wraps a TxOrderingPolicy, filtering out the transactions of
+// blacklisted senders and those calling blacklisted contracts.
+func NewAddressFilter(policy TxOrderingPolicy, blacklist []common.Address) *AddressFilter {
+	filter := &AddressFilter{
+		policy:    policy,
+		blacklist: make(map[common.Address]struct{}, len(blacklist)),
+	}
+	for _, addr := range blacklist {
+		filter.blacklist[addr] = struct{}{}
+	}
+	return filter
+}
+
+// Order implements TxOrderingPolicy.
+func (f *AddressFilter) Order(signer types.Signer, pending map[common.Address]types.Transactions, locals []common.Address) []*types.TransactionsByPriceAndNonce {
+	filtered := make(map[common.Address]types.Transactions, len(pending))
+	for account, txs := range pending {
+		if f.blacklisted(account) {
+			continue
+		}
+		// Transactions following a dropped one would have a nonce gap, so
+		// the account's list is cut at the first blacklisted recipient.
+		for i, tx := range txs {
+			if to := tx.To(); to != nil && f.blacklisted(*to) {
+				txs = txs[:i]
+				break
+			}
+		}
+		if len(txs) > 0 {
+			filtered[account] = txs
+		}
+	}
+	return f.policy.Order(signer, filtered, locals)
+}
+
+// blacklisted reports whether the given address is blacklisted.
+func (f *AddressFilter) blacklisted(addr common.Address) bool {
+	_, ok := f.blacklist[addr]
+	return ok
+}
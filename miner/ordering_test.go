@@ -0,0 +1,124 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package miner
+
+import (
+	"crypto/ecdsa"
+	"math/big"
+	"testing"
+
+	"github.com/athofficial/go-ath/common"
+	"github.com/athofficial/go-ath/core/types"
+	"github.com/athofficial/go-ath/crypto"
+)
+
+// orderingTx creates a signed transaction to the given recipient.
+func orderingTx(signer types.Signer, key *ecdsa.PrivateKey, nonce uint64, to common.Address, price int64) *types.Transaction {
+	tx, _ := types.SignTx(types.NewTransaction(nonce, to, big.NewInt(0), 21000, big.NewInt(price), nil), signer, key)
+	return tx
+}
+
+// drain collects all transactions of the given sets in the order they would be
+// committed by the worker.
+func drain(sets []*types.TransactionsByPriceAndNonce) []*types.Transaction {
+	var txs []*types.Transaction
+	for _, set := range sets {
+		for tx := set.Peek(); tx != nil; tx = set.Peek() {
+			txs = append(txs, tx)
+			set.Shift()
+		}
+	}
+	return txs
+}
+
+// Tests that the built-in ordering policies sort the pending transactions as
+// expected and that the address filter drops blacklisted transactions.
+func TestTxOrderingPolicies(t *testing.T) {
+	var (
+		signer = types.HomesteadSigner{}
+
+		localKey, _  = crypto.GenerateKey()
+		remoteKey, _ = crypto.GenerateKey()
+		local        = crypto.PubkeyToAddress(localKey.PublicKey)
+		remote       = crypto.PubkeyToAddress(remoteKey.PublicKey)
+
+		recipient = common.HexToAddress("0x01")
+		contract  = common.HexToAddress("0x02")
+	)
+	pending := map[common.Address]types.Transactions{
+		local: {
+			orderingTx(signer, localKey, 0, recipient, 1),
+			orderingTx(signer, localKey, 1, recipient, 1),
+		},
+		remote: {
+			orderingTx(signer, remoteKey, 0, recipient, 10),
+			orderingTx(signer, remoteKey, 1, contract, 10),
+			orderingTx(signer, remoteKey, 2, recipient, 10),
+		},
+	}
+	// The ordered sets take ownership of the pending map, create a copy per run
+	copyPending := func() map[common.Address]types.Transactions {
+		cpy := make(map[common.Address]types.Transactions)
+		for addr, txs := range pending {
+			cpy[addr] = txs
+		}
+		return cpy
+	}
+	tests := []struct {
+		policy    string
+		blacklist []common.Address
+		want      []*types.Transaction
+	}{
+		{
+			policy: PriceOrdering,
+			want:   []*types.Transaction{pending[remote][0], pending[remote][1], pending[remote][2], pending[local][0], pending[local][1]},
+		},
+		{
+			policy: LocalsFirstOrdering,
+			want:   []*types.Transaction{pending[local][0], pending[local][1], pending[remote][0], pending[remote][1], pending[remote][2]},
+		},
+		{
+			policy:    PriceOrdering,
+			blacklist: []common.Address{local},
+			want:      []*types.Transaction{pending[remote][0], pending[remote][1], pending[remote][2]},
+		},
+		{
+			policy:    LocalsFirstOrdering,
+			blacklist: []common.Address{contract},
+			want:      []*types.Transaction{pending[local][0], pending[local][1], pending[remote][0]},
+		},
+	}
+	for i, tt := range tests {
+		policy, err := NewTxOrderingPolicy(tt.policy, tt.blacklist)
+		if err != nil {
+			t.Fatalf("test %d: failed to create policy: %v", i, err)
+		}
+		have := drain(policy.Order(signer, copyPending(), []common.Address{local}))
+		if len(have) != len(tt.want) {
+			t.Errorf("test %d: transaction count mismatch: have %d, want %d", i, len(have), len(tt.want))
+			continue
+		}
+		for j := range have {
+			if have[j].Hash() != tt.want[j].Hash() {
+				t.Errorf("test %d, tx %d: transaction mismatch: have %x, want %x", i, j, have[j].Hash(), tt.want[j].Hash())
+			}
+		}
+	}
+	if _, err := NewTxOrderingPolicy("unknown", nil); err == nil {
+		t.Errorf("expected error for unknown policy")
+	}
+}
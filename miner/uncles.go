@@ -0,0 +1,148 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package miner
+
+import (
+	"bytes"
+	"math/big"
+	"sort"
+
+	"github.com/athofficial/go-ath/common"
+	"github.com/athofficial/go-ath/consensus"
+	"github.com/athofficial/go-ath/core/types"
+	"github.com/athofficial/go-ath/metrics"
+	mapset "github.com/deckarep/golang-set"
+)
+
+var (
+	uncleCandidateMeter = metrics.NewRegisteredMeter("miner/uncles/candidates", nil)
+	uncleIncludedMeter  = metrics.NewRegisteredMeter("miner/uncles/included", nil)
+	uncleLostMeter      = metrics.NewRegisteredMeter("miner/uncles/lost", nil)
+)
+
+// uncleRewarder is implemented by consensus engines rewarding included uncles,
+// allowing the worker to pick the most valuable candidates.
+type uncleRewarder interface {
+	// UncleReward returns the reward of the uncle's coinbase and the reward of
+	// the including block's coinbase if uncle is included in header.
+	UncleReward(header, uncle *types.Header) (*big.Int, *big.Int)
+}
+
+// uncleCandidate is a side chain header which may be included as an uncle.
+type uncleCandidate struct {
+	header *types.Header
+	local  bool // Whether the uncle was mined by the local miner
+}
+
+// uncleCache tracks the side chain headers, both imported and announced by the
+// network, which may be included as uncles into locally mined blocks.
+type uncleCache struct {
+	candidates map[common.Hash]*uncleCandidate
+}
+
+// newUncleCache creates an empty uncle candidate cache.
+func newUncleCache() *uncleCache {
+	return &uncleCache{
+		candidates: make(map[common.Hash]*uncleCandidate),
+	}
+}
+
+// add inserts a new uncle candidate into the cache, returning false if it was
+// already known.
+func (c *uncleCache) add(header *types.Header, local bool) bool {
+	hash := header.Hash()
+	if _, exist := c.candidates[hash]; exist {
+		return false
+	}
+	c.candidates[hash] = &uncleCandidate{header: header, local: local}
+	uncleCandidateMeter.Mark(1)
+	return true
+}
+
+// header retrieves the header of a cached uncle candidate.
+func (c *uncleCache) header(hash common.Hash) *types.Header {
+	if candidate, exist := c.candidates[hash]; exist {
+		return candidate.header
+	}
+	return nil
+}
+
+// prune drops all candidates which were included in the canonical chain, either
+// as uncles or as blocks, or which are too old to be included at the given block
+// number. Candidates expiring without ever being included are reported lost.
+func (c *uncleCache) prune(number uint64, ancestors, family mapset.Set) {
+	for hash, candidate := range c.candidates {
+		switch {
+		case ancestors.Contains(hash):
+			delete(c.candidates, hash)
+		case family.Contains(hash):
+			uncleIncludedMeter.Mark(1)
+			delete(c.candidates, hash)
+		case candidate.header.Number.Uint64()+staleThreshold <= number:
+			uncleLostMeter.Mark(1)
+			delete(c.candidates, hash)
+		}
+	}
+}
+
+// sorted returns the cached candidates ordered by their value when included into
+// the given header. Candidates paying most to the local miner come first, then
+// the ones paying most in total. Since uncles can be included at any depth up to
+// the stale threshold, ties are broken in favour of the oldest candidates, which
+// would otherwise be lost soonest.
+func (c *uncleCache) sorted(engine consensus.Engine, header *types.Header) []*types.Header {
+	type valuedCandidate struct {
+		*uncleCandidate
+		own, total *big.Int
+	}
+	rewarder, _ := engine.(uncleRewarder)
+
+	candidates := make([]valuedCandidate, 0, len(c.candidates))
+	for _, candidate := range c.candidates {
+		own, total := new(big.Int), new(big.Int)
+		if rewarder != nil {
+			uncleReward, inclusionReward := rewarder.UncleReward(header, candidate.header)
+			own.Set(inclusionReward)
+			total.Add(inclusionReward, uncleReward)
+			if candidate.local {
+				own.Set(total)
+			}
+		} else if candidate.local {
+			own.SetUint64(1)
+		}
+		candidates = append(candidates, valuedCandidate{candidate, own, total})
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		a, b := candidates[i], candidates[j]
+		if cmp := a.own.Cmp(b.own); cmp != 0 {
+			return cmp > 0
+		}
+		if cmp := a.total.Cmp(b.total); cmp != 0 {
+			return cmp > 0
+		}
+		if cmp := a.header.Number.Cmp(b.header.Number); cmp != 0 {
+			return cmp < 0
+		}
+		ha, hb := a.header.Hash(), b.header.Hash()
+		return bytes.Compare(ha[:], hb[:]) < 0
+	})
+	headers := make([]*types.Header, len(candidates))
+	for i, candidate := range candidates {
+		headers[i] = candidate.header
+	}
+	return headers
+}
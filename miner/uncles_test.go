@@ -0,0 +1,83 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package miner
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/athofficial/go-ath/consensus"
+	"github.com/athofficial/go-ath/consensus/clique"
+	"github.com/athofficial/go-ath/consensus/ubqhash"
+	"github.com/athofficial/go-ath/core/types"
+	"github.com/athofficial/go-ath/ethdb"
+	"github.com/athofficial/go-ath/params"
+	mapset "github.com/deckarep/golang-set"
+)
+
+// Tests that uncle candidates are ordered by their reward and that included or
+// stale candidates are pruned from the cache.
+func TestUncleCache(t *testing.T) {
+	var (
+		remoteNew = &types.Header{Number: big.NewInt(99), Extra: []byte("remote")}
+		localNew  = &types.Header{Number: big.NewInt(99), Extra: []byte("local")}
+		remoteOld = &types.Header{Number: big.NewInt(95)}
+		remoteMid = &types.Header{Number: big.NewInt(97)}
+	)
+	cache := newUncleCache()
+	cache.add(remoteNew, false)
+	cache.add(localNew, true)
+	cache.add(remoteOld, false)
+	cache.add(remoteMid, false)
+
+	if cache.add(remoteMid, false) {
+		t.Errorf("duplicate candidate accepted")
+	}
+	header := &types.Header{Number: big.NewInt(100)}
+
+	tests := []struct {
+		engine consensus.Engine
+		want   []*types.Header
+	}{
+		// Rewarding engines prefer the local uncle, then the rewarded remote one,
+		// then the candidates closest to going stale
+		{ubqhash.NewFaker(), []*types.Header{localNew, remoteNew, remoteOld, remoteMid}},
+		// Other engines prefer local uncles, then the oldest ones
+		{clique.New(params.AllCliqueProtocolChanges.Clique, ethdb.NewMemDatabase()), []*types.Header{localNew, remoteOld, remoteMid, remoteNew}},
+	}
+	for i, tt := range tests {
+		have := cache.sorted(tt.engine, header)
+		if len(have) != len(tt.want) {
+			t.Errorf("test %d: candidate count mismatch: have %d, want %d", i, len(have), len(tt.want))
+			continue
+		}
+		for j := range have {
+			if have[j].Hash() != tt.want[j].Hash() {
+				t.Errorf("test %d, candidate %d: number mismatch: have %d, want %d", i, j, have[j].Number, tt.want[j].Number)
+			}
+		}
+	}
+	// Prune a candidate which became canonical, one included and one gone stale
+	cache.prune(102, mapset.NewSet(remoteMid.Hash()), mapset.NewSet(remoteMid.Hash(), remoteNew.Hash()))
+
+	if have := cache.sorted(tests[0].engine, header); len(have) != 1 || have[0].Hash() != localNew.Hash() {
+		t.Errorf("pruned candidates mismatch: have %v, want only the local one", have)
+	}
+	if cache.header(remoteNew.Hash()) != nil {
+		t.Errorf("included candidate not pruned")
+	}
+}
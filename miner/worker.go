@@ -127,6 +127,7 @@ type worker struct {
 
 	gasFloor uint64
 	gasCeil  uint64
+	ordering TxOrderingPolicy // Policy deciding the order of pending transactions
 
 	// Subscriptions
 	mux          *event.TypeMux
@@ -136,6 +137,7 @@ type worker struct {
 	chainHeadSub event.Subscription
 	chainSideCh  chan core.ChainSideEvent
 	chainSideSub event.Subscription
+	uncleSub     *event.TypeMuxSubscription
 
 	// Channels
 	newWorkCh          chan *newWorkReq
@@ -146,10 +148,9 @@ type worker struct {
 	resubmitIntervalCh chan time.Duration
 	resubmitAdjustCh   chan *intervalAdjust
 
-	current      *environment                 // An environment for current running cycle.
-	localUncles  map[common.Hash]*types.Block // A set of side blocks generated locally as the possible uncle blocks.
-	remoteUncles map[common.Hash]*types.Block // A set of side blocks as the possible uncle blocks.
-	unconfirmed  *unconfirmedBlocks           // A set of locally mined blocks pending canonicalness confirmations.
+	current     *environment       // An environment for current running cycle.
+	uncles      *uncleCache        // A set of side chain headers as the possible uncles.
+	unconfirmed *unconfirmedBlocks // A set of locally mined blocks pending canonicalness confirmations.
 
 	mu       sync.RWMutex // The lock used to protect the coinbase and extra fields
 	coinbase common.Address
@@ -176,7 +177,10 @@ type worker struct {
 	resubmitHook func(time.Duration, time.Duration) // Method to call upon updating resubmitting interval.
 }
 
-func newWorker(config *params.ChainConfig, engine consensus.Engine, eth Backend, mux *event.TypeMux, recommit time.Duration, gasFloor, gasCeil uint64, isLocalBlock func(*types.Block) bool) *worker {
+func newWorker(config *params.ChainConfig, engine consensus.Engine, eth Backend, mux *event.TypeMux, recommit time.Duration, gasFloor, gasCeil uint64, ordering TxOrderingPolicy, isLocalBlock func(*types.Block) bool) *worker {
+	if ordering == nil {
+		ordering = new(localsFirstOrdering)
+	}
 	worker := &worker{
 		config:             config,
 		engine:             engine,
@@ -185,9 +189,9 @@ func newWorker(config *params.ChainConfig, engine consensus.Engine, eth Backend,
 		chain:              eth.BlockChain(),
 		gasFloor:           gasFloor,
 		gasCeil:            gasCeil,
+		ordering:           ordering,
 		isLocalBlock:       isLocalBlock,
-		localUncles:        make(map[common.Hash]*types.Block),
-		remoteUncles:       make(map[common.Hash]*types.Block),
+		uncles:             newUncleCache(),
 		unconfirmed:        newUnconfirmedBlocks(eth.BlockChain(), miningLogAtDepth),
 		pendingTasks:       make(map[common.Hash]*task),
 		txsCh:              make(chan core.NewTxsEvent, txChanSize),
@@ -206,6 +210,8 @@ func newWorker(config *params.ChainConfig, engine consensus.Engine, eth Backend,
 	// Subscribe events for blockchain
 	worker.chainHeadSub = eth.BlockChain().SubscribeChainHeadEvent(worker.chainHeadCh)
 	worker.chainSideSub = eth.BlockChain().SubscribeChainSideEvent(worker.chainSideCh)
+	// Subscribe side chain headers announced by the network
+	worker.uncleSub = mux.Subscribe(core.UncleCandidateEvent{})
 
 	// Sanitize recommit interval if the user-specified one is too short.
 	if recommit < minRecommitInterval {
@@ -401,49 +407,25 @@ func (w *worker) mainLoop() {
 	defer w.txsSub.Unsubscribe()
 	defer w.chainHeadSub.Unsubscribe()
 	defer w.chainSideSub.Unsubscribe()
+	defer w.uncleSub.Unsubscribe()
 
+	uncleCh := w.uncleSub.Chan()
 	for {
 		select {
 		case req := <-w.newWorkCh:
 			w.commitNewWork(req.interrupt, req.noempty, req.timestamp)
 
 		case ev := <-w.chainSideCh:
-			// Short circuit for duplicate side blocks
-			if _, exist := w.localUncles[ev.Block.Hash()]; exist {
-				continue
-			}
-			if _, exist := w.remoteUncles[ev.Block.Hash()]; exist {
+			w.addUncle(ev.Block)
+
+		case obj := <-uncleCh:
+			// Stop listening for announced uncles if the event mux was stopped
+			if obj == nil {
+				uncleCh = nil
 				continue
 			}
-			// Add side block to possible uncle block set depending on the author.
-			if w.isLocalBlock != nil && w.isLocalBlock(ev.Block) {
-				w.localUncles[ev.Block.Hash()] = ev.Block
-			} else {
-				w.remoteUncles[ev.Block.Hash()] = ev.Block
-			}
-			// If our mining block contains less than 2 uncle blocks,
-			// add the new uncle block if valid and regenerate a mining block.
-			if w.isRunning() && w.current != nil && w.current.uncles.Cardinality() < 2 {
-				start := time.Now()
-				if err := w.commitUncle(w.current, ev.Block.Header()); err == nil {
-					var uncles []*types.Header
-					w.current.uncles.Each(func(item interface{}) bool {
-						hash, ok := item.(common.Hash)
-						if !ok {
-							return false
-						}
-						uncle, exist := w.localUncles[hash]
-						if !exist {
-							uncle, exist = w.remoteUncles[hash]
-						}
-						if !exist {
-							return false
-						}
-						uncles = append(uncles, uncle.Header())
-						return false
-					})
-					w.commit(uncles, nil, true, start)
-				}
+			if ev, ok := obj.Data.(core.UncleCandidateEvent); ok {
+				w.addUncle(types.NewBlockWithHeader(ev.Header))
 			}
 
 		case ev := <-w.txsCh:
@@ -462,8 +444,11 @@ func (w *worker) mainLoop() {
 					acc, _ := types.Sender(w.current.signer, tx)
 					txs[acc] = append(txs[acc], tx)
 				}
-				txset := types.NewTransactionsByPriceAndNonce(w.current.signer, txs)
-				w.commitTransactions(txset, coinbase, nil)
+				// Locals are not preferred here, keeping the strict price ordering
+				// of the pending state while the blacklist still applies.
+				for _, txset := range w.ordering.Order(w.current.signer, txs, nil) {
+					w.commitTransactions(txset, coinbase, nil)
+				}
 				w.updateSnapshot()
 			} else {
 				// If we're mining, but nothing is being processed, wake on new transactions
@@ -604,6 +589,33 @@ func (w *worker) resultLoop() {
 	}
 }
 
+// addUncle adds a side block to the possible uncle set depending on the author.
+// If the current mining block contains less than 2 uncles, the new one is added
+// if valid and a new mining block is regenerated.
+func (w *worker) addUncle(block *types.Block) {
+	local := w.isLocalBlock != nil && w.isLocalBlock(block)
+	if !w.uncles.add(block.Header(), local) {
+		return
+	}
+	if w.isRunning() && w.current != nil && w.current.uncles.Cardinality() < 2 {
+		start := time.Now()
+		if err := w.commitUncle(w.current, block.Header()); err == nil {
+			var uncles []*types.Header
+			w.current.uncles.Each(func(item interface{}) bool {
+				hash, ok := item.(common.Hash)
+				if !ok {
+					return false
+				}
+				if uncle := w.uncles.header(hash); uncle != nil {
+					uncles = append(uncles, uncle)
+				}
+				return false
+			})
+			w.commit(uncles, nil, true, start)
+		}
+	}
+}
+
 // makeCurrent creates a new environment for the current cycle.
 func (w *worker) makeCurrent(parent *types.Block, header *types.Header) error {
 	state, err := w.chain.StateAt(parent.Root())
@@ -665,14 +677,9 @@ func (w *worker) updateSnapshot() {
 		if !ok {
 			return false
 		}
-		uncle, exist := w.localUncles[hash]
-		if !exist {
-			uncle, exist = w.remoteUncles[hash]
+		if uncle := w.uncles.header(hash); uncle != nil {
+			uncles = append(uncles, uncle)
 		}
-		if !exist {
-			return false
-		}
-		uncles = append(uncles, uncle.Header())
 		return false
 	})
 
@@ -859,30 +866,22 @@ func (w *worker) commitNewWork(interrupt *int32, noempty bool, timestamp int64)
 	}
 	// Create the current work task and check any fork transitions needed
 	env := w.current
-	// Accumulate the uncles for the current block
+	// Clean up the included and stale uncles first, then accumulate the most
+	// valuable ones for the current block
+	w.uncles.prune(header.Number.Uint64(), env.ancestors, env.family)
+
 	uncles := make([]*types.Header, 0, 2)
-	commitUncles := func(blocks map[common.Hash]*types.Block) {
-		// Clean up stale uncle blocks first
-		for hash, uncle := range blocks {
-			if uncle.NumberU64()+staleThreshold <= header.Number.Uint64() {
-				delete(blocks, hash)
-			}
+	for _, uncle := range w.uncles.sorted(w.engine, header) {
+		if len(uncles) == 2 {
+			break
 		}
-		for hash, uncle := range blocks {
-			if len(uncles) == 2 {
-				break
-			}
-			if err := w.commitUncle(env, uncle.Header()); err != nil {
-				log.Trace("Possible uncle rejected", "hash", hash, "reason", err)
-			} else {
-				log.Debug("Committing new uncle to block", "hash", hash)
-				uncles = append(uncles, uncle.Header())
-			}
+		if err := w.commitUncle(env, uncle); err != nil {
+			log.Trace("Possible uncle rejected", "hash", uncle.Hash(), "reason", err)
+		} else {
+			log.Debug("Committing new uncle to block", "hash", uncle.Hash())
+			uncles = append(uncles, uncle)
 		}
 	}
-	// Prefer to locally generated uncle
-	commitUncles(w.localUncles)
-	commitUncles(w.remoteUncles)
 
 	if !noempty {
 		// Create an empty block based on temporary copied state for sealing in advance without waiting block
@@ -901,22 +900,8 @@ func (w *worker) commitNewWork(interrupt *int32, noempty bool, timestamp int64)
 		w.updateSnapshot()
 		return
 	}
-	// Split the pending transactions into locals and remotes
-	localTxs, remoteTxs := make(map[common.Address]types.Transactions), pending
-	for _, account := range w.eth.TxPool().Locals() {
-		if txs := remoteTxs[account]; len(txs) > 0 {
-			delete(remoteTxs, account)
-			localTxs[account] = txs
-		}
-	}
-	if len(localTxs) > 0 {
-		txs := types.NewTransactionsByPriceAndNonce(w.current.signer, localTxs)
-		if w.commitTransactions(txs, w.coinbase, interrupt) {
-			return
-		}
-	}
-	if len(remoteTxs) > 0 {
-		txs := types.NewTransactionsByPriceAndNonce(w.current.signer, remoteTxs)
+	// Commit the pending transactions in the order decided by the policy
+	for _, txs := range w.ordering.Order(w.current.signer, pending, w.eth.TxPool().Locals()) {
 		if w.commitTransactions(txs, w.coinbase, interrupt) {
 			return
 		}
@@ -22,7 +22,7 @@ import (
 	"encoding/json"
 
 	"github.com/athofficial/go-ath/core"
-	"github.com/athofficial/go-ath/p2p/discv5"
+	"github.com/athofficial/go-ath/p2p/enode"
 	"github.com/athofficial/go-ath/params"
 )
 
@@ -44,9 +44,9 @@ func TestnetGenesis() string {
 // FoundationBootnodes returns the enode URLs of the P2P bootstrap nodes operated
 // by the foundation running the V5 discovery protocol.
 func FoundationBootnodes() *Enodes {
-	nodes := &Enodes{nodes: make([]*discv5.Node, len(params.DiscoveryV5Bootnodes))}
+	nodes := &Enodes{nodes: make([]*enode.Node, len(params.DiscoveryV5Bootnodes))}
 	for i, url := range params.DiscoveryV5Bootnodes {
-		nodes.nodes[i] = discv5.MustParseNode(url)
+		nodes.nodes[i] = enode.MustParseV4(url)
 	}
 	return nodes
 }
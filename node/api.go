@@ -19,6 +19,7 @@ package node
 import (
 	"context"
 	"fmt"
+	"net"
 	"strings"
 	"time"
 
@@ -106,6 +107,77 @@ func (api *PrivateAdminAPI) RemoveTrustedPeer(url string) (bool, error) {
 	return true, nil
 }
 
+// Ban disconnects and bans a remote node or IP network for the given number of
+// seconds, or the default ban duration if omitted. The target is an enode URL, a
+// node ID, an IP address or a subnet in CIDR notation.
+func (api *PrivateAdminAPI) Ban(target string, seconds *uint64) (bool, error) {
+	// Make sure the server is running, fail otherwise
+	server := api.node.Server()
+	if server == nil {
+		return false, ErrNodeStopped
+	}
+	id, subnet, err := parseBanTarget(target)
+	if err != nil {
+		return false, err
+	}
+	var d time.Duration
+	if seconds != nil {
+		d = time.Duration(*seconds) * time.Second
+	}
+	if subnet != nil {
+		server.BanSubnet(subnet, d)
+	} else {
+		server.BanNode(id, d)
+	}
+	return true, nil
+}
+
+// Unban lifts the ban of a remote node or IP network, given in the same format
+// as for Ban.
+func (api *PrivateAdminAPI) Unban(target string) (bool, error) {
+	// Make sure the server is running, fail otherwise
+	server := api.node.Server()
+	if server == nil {
+		return false, ErrNodeStopped
+	}
+	id, subnet, err := parseBanTarget(target)
+	if err != nil {
+		return false, err
+	}
+	if subnet != nil {
+		server.UnbanSubnet(subnet)
+	} else {
+		server.UnbanNode(id)
+	}
+	return true, nil
+}
+
+// parseBanTarget parses an enode URL or node ID into a node ID, or an IP address
+// or CIDR subnet into an IP network.
+func parseBanTarget(target string) (enode.ID, *net.IPNet, error) {
+	if strings.HasPrefix(target, "enode://") {
+		node, err := enode.ParseV4(target)
+		if err != nil {
+			return enode.ID{}, nil, fmt.Errorf("invalid enode: %v", err)
+		}
+		return node.ID(), nil, nil
+	}
+	if _, subnet, err := net.ParseCIDR(target); err == nil {
+		return enode.ID{}, subnet, nil
+	}
+	if ip := net.ParseIP(target); ip != nil {
+		if ip4 := ip.To4(); ip4 != nil {
+			return enode.ID{}, &net.IPNet{IP: ip4, Mask: net.CIDRMask(32, 32)}, nil
+		}
+		return enode.ID{}, &net.IPNet{IP: ip, Mask: net.CIDRMask(128, 128)}, nil
+	}
+	var id enode.ID
+	if err := id.UnmarshalText([]byte(target)); err != nil {
+		return enode.ID{}, nil, fmt.Errorf("invalid ban target %q, want enode URL, node ID, IP or subnet", target)
+	}
+	return id, nil, nil
+}
+
 // PeerEvents creates an RPC subscription which receives peer events from the
 // node's p2p.Server
 func (api *PrivateAdminAPI) PeerEvents(ctx context.Context) (*rpc.Subscription, error) {
@@ -188,7 +260,7 @@ func (api *PrivateAdminAPI) StartRPC(host *string, port *int, cors *string, apis
 		}
 	}
 
-	if err := api.node.startHTTP(fmt.Sprintf("%s:%d", *host, *port), api.node.rpcAPIs, modules, allowedOrigins, allowedVHosts, api.node.config.HTTPTimeouts); err != nil {
+	if err := api.node.startHTTP(fmt.Sprintf("%s:%d", *host, *port), api.node.rpcAPIs, api.node.rpcHandlers, modules, allowedOrigins, allowedVHosts, api.node.config.HTTPTimeouts); err != nil {
 		return false, err
 	}
 	return true, nil
@@ -211,7 +283,7 @@ func (api *PrivateAdminAPI) StartWS(host *string, port *int, allowedOrigins *str
 	api.node.lock.Lock()
 	defer api.node.lock.Unlock()
 
-	if api.node.wsHandler != nil {
+	if api.node.wsHandler != nil || api.node.wsShared {
 		return false, fmt.Errorf("WebSocket RPC already running on %s", api.node.wsEndpoint)
 	}
 
@@ -253,6 +325,9 @@ func (api *PrivateAdminAPI) StopWS() (bool, error) {
 	api.node.lock.Lock()
 	defer api.node.lock.Unlock()
 
+	if api.node.wsShared {
+		return false, fmt.Errorf("WebSocket RPC shares the HTTP RPC endpoint, stop that instead")
+	}
 	if api.node.wsHandler == nil {
 		return false, fmt.Errorf("WebSocket RPC not running")
 	}
@@ -282,6 +357,16 @@ func (api *PublicAdminAPI) Peers() ([]*p2p.PeerInfo, error) {
 	return server.PeersInfo(), nil
 }
 
+// PeerScores retrieves the scores of the nodes known to the reputation system
+// and the current bans.
+func (api *PublicAdminAPI) PeerScores() (*p2p.ReputationInfo, error) {
+	server := api.node.Server()
+	if server == nil {
+		return nil, ErrNodeStopped
+	}
+	return server.PeerScores(), nil
+}
+
 // NodeInfo retrieves all the information we know about the host node at the
 // protocol granularity.
 func (api *PublicAdminAPI) NodeInfo() (*p2p.NodeInfo, error) {
@@ -18,6 +18,7 @@ package node
 
 import (
 	"crypto/ecdsa"
+	"encoding/hex"
 	"fmt"
 	"io/ioutil"
 	"os"
@@ -25,6 +26,7 @@ import (
 	"runtime"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/athofficial/go-ath/accounts"
 	"github.com/athofficial/go-ath/accounts/keystore"
@@ -43,6 +45,8 @@ const (
 	datadirStaticNodes     = "static-nodes.json"  // Path within the datadir to the static node list
 	datadirTrustedNodes    = "trusted-nodes.json" // Path within the datadir to the trusted node list
 	datadirNodeDatabase    = "nodes"              // Path within the datadir to store the node infos
+
+	jwtSecretLength = 32 // Minimum length of the secret authenticating RPC clients
 )
 
 // Config represents a small collection of configuration values to fine tune the
@@ -151,6 +155,26 @@ type Config struct {
 	// private APIs to untrusted users is a major security risk.
 	WSExposeAll bool `toml:",omitempty"`
 
+	// WSIsolate applies WSModules and WSOrigins only to the websocket connections
+	// upgraded on the HTTP RPC port, if the websocket endpoint shares it. If not
+	// set, upgraded connections are served the HTTP modules and their origins are
+	// checked against the HTTP CORS domains.
+	WSIsolate bool `toml:",omitempty"`
+
+	// JWTSecret is the path of the file containing the hex encoded HS256 secret
+	// the bearer tokens of HTTP and websocket RPC clients are verified with. If
+	// empty, clients are not authenticated.
+	JWTSecret string `toml:",omitempty"`
+
+	// RPCSlowRequest is the execution time above which RPC calls are logged as
+	// slow, along with their method, parameter hash and caller. Zero disables it.
+	RPCSlowRequest time.Duration `toml:",omitempty"`
+
+	// RPCPolicy is the access policy enforced on the clients of the HTTP and
+	// websocket RPC interfaces, restricting the callable methods and the request
+	// rates, batch and response sizes.
+	RPCPolicy rpc.PolicyConfig
+
 	// Logger is a custom logger to use with the p2p.Server.
 	Logger log.Logger `toml:",omitempty"`
 
@@ -234,6 +258,31 @@ func DefaultWSEndpoint() string {
 	return config.WSEndpoint()
 }
 
+// ExposesModule reports whether the given RPC API module is served to remote
+// clients, through either the HTTP or the websocket endpoint. Modules exposed
+// only through an empty (public) module list are not considered, since those
+// never include the private APIs.
+func (c *Config) ExposesModule(module string) bool {
+	if c.HTTPHost != "" {
+		for _, m := range c.HTTPModules {
+			if m == module {
+				return true
+			}
+		}
+	}
+	if c.WSHost != "" {
+		if c.WSExposeAll {
+			return true
+		}
+		for _, m := range c.WSModules {
+			if m == module {
+				return true
+			}
+		}
+	}
+	return false
+}
+
 // NodeName returns the devp2p node identifier.
 func (c *Config) NodeName() string {
 	name := c.name()
@@ -342,6 +391,26 @@ func (c *Config) NodeKey() *ecdsa.PrivateKey {
 	return key
 }
 
+// jwtSecret loads the secret the bearer tokens of RPC clients are verified with,
+// returning nil if client authentication is disabled.
+func (c *Config) jwtSecret() ([]byte, error) {
+	if c.JWTSecret == "" {
+		return nil, nil
+	}
+	blob, err := ioutil.ReadFile(c.JWTSecret)
+	if err != nil {
+		return nil, err
+	}
+	secret, err := hex.DecodeString(strings.TrimPrefix(strings.TrimSpace(string(blob)), "0x"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid JWT secret %s: %v", c.JWTSecret, err)
+	}
+	if len(secret) < jwtSecretLength {
+		return nil, fmt.Errorf("JWT secret %s too short: have %d bytes, want at least %d", c.JWTSecret, len(secret), jwtSecretLength)
+	}
+	return secret, nil
+}
+
 // StaticNodes returns a list of node enode URLs configured as static nodes.
 func (c *Config) StaticNodes() []*enode.Node {
 	return c.parsePersistentNodes(&c.staticNodesWarning, c.ResolvePath(datadirStaticNodes))
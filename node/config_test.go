@@ -148,3 +148,26 @@ func TestNodeKeyPersistency(t *testing.T) {
 		t.Fatalf("ephemeral node key persisted to disk")
 	}
 }
+
+// Tests that only the API modules explicitly served over HTTP or websocket are
+// reported as exposed to remote clients.
+func TestExposesModule(t *testing.T) {
+	tests := []struct {
+		config Config
+		want   bool
+	}{
+		{Config{}, false},
+		{Config{HTTPModules: []string{"debug"}}, false},
+		{Config{HTTPHost: "localhost"}, false},
+		{Config{HTTPHost: "localhost", HTTPModules: []string{"eth", "net"}}, false},
+		{Config{HTTPHost: "localhost", HTTPModules: []string{"eth", "debug"}}, true},
+		{Config{WSHost: "localhost", WSModules: []string{"debug"}}, true},
+		{Config{WSHost: "localhost", WSExposeAll: true}, true},
+		{Config{WSExposeAll: true}, false},
+	}
+	for i, tt := range tests {
+		if have := tt.config.ExposesModule("debug"); have != tt.want {
+			t.Errorf("test %d: exposure mismatch: have %v, want %v", i, have, tt.want)
+		}
+	}
+}
@@ -20,6 +20,7 @@ import (
 	"errors"
 	"fmt"
 	"net"
+	"net/http"
 	"os"
 	"path/filepath"
 	"reflect"
@@ -51,8 +52,11 @@ type Node struct {
 	serviceFuncs []ServiceConstructor     // Service constructors (in dependency order)
 	services     map[reflect.Type]Service // Currently running services
 
-	rpcAPIs       []rpc.API   // List of APIs currently provided by the node
-	inprocHandler *rpc.Server // In-process RPC request handler to process the API requests
+	rpcAPIs       []rpc.API               // List of APIs currently provided by the node
+	rpcHandlers   map[string]http.Handler // Custom HTTP handlers provided by the services
+	rpcPolicy     *rpc.Policy             // Access policy enforced on the HTTP and websocket clients
+	jwtSecret     []byte                  // Secret authenticating the HTTP and websocket clients (nil = disabled)
+	inprocHandler *rpc.Server             // In-process RPC request handler to process the API requests
 
 	ipcEndpoint string       // IPC endpoint to listen at (empty = IPC disabled)
 	ipcListener net.Listener // IPC RPC listener socket to serve API requests
@@ -66,6 +70,7 @@ type Node struct {
 	wsEndpoint string       // Websocket endpoint (interface + port) to listen at (empty = websocket disabled)
 	wsListener net.Listener // Websocket RPC listener socket to server API requests
 	wsHandler  *rpc.Server  // Websocket RPC request handler to process the API requests
+	wsShared   bool         // Whether websocket connections are upgraded on the HTTP endpoint
 
 	stop chan struct{} // Channel to wait for termination notifications
 	lock sync.RWMutex
@@ -250,11 +255,35 @@ func (n *Node) openDataDir() error {
 // startup. It's not meant to be called at any time afterwards as it makes certain
 // assumptions about the state of the node.
 func (n *Node) startRPC(services map[reflect.Type]Service) error {
-	// Gather all the possible APIs to surface
+	// Gather all the possible APIs and HTTP handlers to surface
 	apis := n.apis()
-	for _, service := range services {
+	handlers := make(map[string]http.Handler)
+	for kind, service := range services {
 		apis = append(apis, service.APIs()...)
+		if service, ok := service.(HTTPService); ok {
+			for path, handler := range service.HTTPHandlers() {
+				if _, exists := handlers[path]; exists {
+					return fmt.Errorf("duplicate HTTP handler for %s: %v", path, kind)
+				}
+				handlers[path] = handler
+			}
+		}
+	}
+	// Create the access policy shared by the remotely reachable endpoints
+	policy, err := rpc.NewPolicy(n.config.RPCPolicy)
+	if err != nil {
+		return err
+	}
+	n.rpcPolicy = policy
+
+	secret, err := n.config.jwtSecret()
+	if err != nil {
+		return err
 	}
+	n.jwtSecret = secret
+
+	rpc.SetSlowRequestThreshold(n.config.RPCSlowRequest)
+
 	// Start the various API endpoints, terminating all in case of errors
 	if err := n.startInProc(apis); err != nil {
 		return err
@@ -263,7 +292,18 @@ func (n *Node) startRPC(services map[reflect.Type]Service) error {
 		n.stopInProc()
 		return err
 	}
-	if err := n.startHTTP(n.httpEndpoint, apis, n.config.HTTPModules, n.config.HTTPCors, n.config.HTTPVirtualHosts, n.config.HTTPTimeouts); err != nil {
+	if n.httpEndpoint != "" && n.httpEndpoint == n.wsEndpoint {
+		// Websocket shares the HTTP port, serve both from a single listener
+		if err := n.startShared(n.httpEndpoint, apis, handlers); err != nil {
+			n.stopIPC()
+			n.stopInProc()
+			return err
+		}
+		n.rpcAPIs = apis
+		n.rpcHandlers = handlers
+		return nil
+	}
+	if err := n.startHTTP(n.httpEndpoint, apis, handlers, n.config.HTTPModules, n.config.HTTPCors, n.config.HTTPVirtualHosts, n.config.HTTPTimeouts); err != nil {
 		n.stopIPC()
 		n.stopInProc()
 		return err
@@ -276,6 +316,7 @@ func (n *Node) startRPC(services map[reflect.Type]Service) error {
 	}
 	// All API endpoints started successfully
 	n.rpcAPIs = apis
+	n.rpcHandlers = handlers
 	return nil
 }
 
@@ -331,12 +372,12 @@ func (n *Node) stopIPC() {
 }
 
 // startHTTP initializes and starts the HTTP RPC endpoint.
-func (n *Node) startHTTP(endpoint string, apis []rpc.API, modules []string, cors []string, vhosts []string, timeouts rpc.HTTPTimeouts) error {
+func (n *Node) startHTTP(endpoint string, apis []rpc.API, handlers map[string]http.Handler, modules []string, cors []string, vhosts []string, timeouts rpc.HTTPTimeouts) error {
 	// Short circuit if the HTTP endpoint isn't being exposed
 	if endpoint == "" {
 		return nil
 	}
-	listener, handler, err := rpc.StartHTTPEndpoint(endpoint, apis, modules, cors, vhosts, timeouts)
+	listener, handler, err := rpc.StartHTTPEndpoint(endpoint, apis, handlers, modules, cors, vhosts, timeouts, n.rpcPolicy, n.jwtSecret)
 	if err != nil {
 		return err
 	}
@@ -349,8 +390,35 @@ func (n *Node) startHTTP(endpoint string, apis []rpc.API, modules []string, cors
 	return nil
 }
 
-// stopHTTP terminates the HTTP RPC endpoint.
+// startShared initializes and starts the HTTP RPC endpoint, also accepting the
+// websocket connections upgraded on it.
+func (n *Node) startShared(endpoint string, apis []rpc.API, handlers map[string]http.Handler) error {
+	config := n.config
+	listener, handler, wsHandler, err := rpc.StartSharedEndpoint(endpoint, apis, handlers, config.HTTPModules, config.HTTPCors, config.HTTPVirtualHosts, config.HTTPTimeouts,
+		config.WSModules, config.WSOrigins, config.WSExposeAll, config.WSIsolate, n.rpcPolicy, n.jwtSecret)
+	if err != nil {
+		return err
+	}
+	n.log.Info("HTTP endpoint opened", "url", fmt.Sprintf("http://%s", endpoint), "cors", strings.Join(config.HTTPCors, ","), "vhosts", strings.Join(config.HTTPVirtualHosts, ","))
+	n.log.Info("WebSocket endpoint opened", "url", fmt.Sprintf("ws://%s", listener.Addr()), "isolated", config.WSIsolate)
+
+	// All listeners booted successfully
+	n.httpEndpoint = endpoint
+	n.httpListener = listener
+	n.httpHandler = handler
+	n.wsEndpoint = endpoint
+	n.wsHandler = wsHandler
+	n.wsShared = true
+
+	return nil
+}
+
+// stopHTTP terminates the HTTP RPC endpoint, along with the websocket one if it
+// is shared.
 func (n *Node) stopHTTP() {
+	if n.wsShared {
+		n.stopWS()
+	}
 	if n.httpListener != nil {
 		n.httpListener.Close()
 		n.httpListener = nil
@@ -369,7 +437,7 @@ func (n *Node) startWS(endpoint string, apis []rpc.API, modules []string, wsOrig
 	if endpoint == "" {
 		return nil
 	}
-	listener, handler, err := rpc.StartWSEndpoint(endpoint, apis, modules, wsOrigins, exposeAll)
+	listener, handler, err := rpc.StartWSEndpoint(endpoint, apis, modules, wsOrigins, exposeAll, n.rpcPolicy, n.jwtSecret)
 	if err != nil {
 		return err
 	}
@@ -390,6 +458,10 @@ func (n *Node) stopWS() {
 
 		n.log.Info("WebSocket endpoint closed", "url", fmt.Sprintf("ws://%s", n.wsEndpoint))
 	}
+	if n.wsShared {
+		n.wsShared = false
+		n.log.Info("WebSocket endpoint closed", "url", fmt.Sprintf("ws://%s", n.wsEndpoint))
+	}
 	if n.wsHandler != nil {
 		n.wsHandler.Stop()
 		n.wsHandler = nil
@@ -412,6 +484,7 @@ func (n *Node) Stop() error {
 	n.stopHTTP()
 	n.stopIPC()
 	n.rpcAPIs = nil
+	n.rpcHandlers = nil
 	failure := &StopError{
 		Services: make(map[reflect.Type]error),
 	}
@@ -566,6 +639,9 @@ func (n *Node) WSEndpoint() string {
 	if n.wsListener != nil {
 		return n.wsListener.Addr().String()
 	}
+	if n.wsShared && n.httpListener != nil {
+		return n.httpListener.Addr().String()
+	}
 	return n.wsEndpoint
 }
 
@@ -17,6 +17,7 @@
 package node
 
 import (
+	"net/http"
 	"reflect"
 
 	"github.com/athofficial/go-ath/accounts"
@@ -57,6 +58,12 @@ func (ctx *ServiceContext) ResolvePath(path string) string {
 	return ctx.config.ResolvePath(path)
 }
 
+// ExposesModule reports whether the given RPC API module is served to remote
+// clients over HTTP or websocket.
+func (ctx *ServiceContext) ExposesModule(module string) bool {
+	return ctx.config.ExposesModule(module)
+}
+
 // Service retrieves a currently running service registered of a specific type.
 func (ctx *ServiceContext) Service(service interface{}) error {
 	element := reflect.ValueOf(service).Elem()
@@ -96,3 +103,11 @@ type Service interface {
 	// are all terminated.
 	Stop() error
 }
+
+// HTTPService is an optional interface for services which serve custom HTTP
+// handlers alongside the JSON-RPC API on the node's HTTP endpoint.
+type HTTPService interface {
+	// HTTPHandlers retrieves the handlers to mount on the HTTP endpoint, keyed
+	// by the URL path they are served on.
+	HTTPHandlers() map[string]http.Handler
+}
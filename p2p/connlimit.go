@@ -0,0 +1,173 @@
+// Copyright 2019 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package p2p
+
+import (
+	"errors"
+	"net"
+	"time"
+
+	"github.com/athofficial/go-ath/p2p/enode"
+	"github.com/athofficial/go-ath/p2p/netutil"
+)
+
+const (
+	// Default limits of regular peers from the same subnet.
+	defaultMaxPeersPerSubnet24 = 4
+	defaultMaxPeersPerSubnet16 = 8
+
+	// Prefix lengths of the IPv6 subnets corresponding to the IPv4 /24 and /16.
+	ipv6Subnet24Bits = 64
+	ipv6Subnet16Bits = 48
+
+	// inboundThrottleTime is the minimum time between two inbound connection
+	// attempts from the same IP.
+	inboundThrottleTime = 30 * time.Second
+)
+
+var (
+	errSubnetLimit     = errors.New("too many peers from subnet")
+	errInboundThrottle = errors.New("too many connection attempts")
+)
+
+// subnetLimitReached reports whether accepting the connection would exceed the
+// per-subnet peer limits. Trusted and static peers are neither limited nor counted,
+// and neither are nodes on the local network.
+func (srv *Server) subnetLimitReached(peers map[enode.ID]*Peer, c *conn) bool {
+	ip := c.node.IP()
+	if ip == nil || netutil.IsLAN(ip) {
+		return false
+	}
+	var (
+		limit24 = limitOrDefault(srv.MaxPeersPerSubnet24, defaultMaxPeersPerSubnet24)
+		limit16 = limitOrDefault(srv.MaxPeersPerSubnet16, defaultMaxPeersPerSubnet16)
+		bits24  = uint(24)
+		bits16  = uint(16)
+	)
+	if ip.To4() == nil {
+		bits24, bits16 = ipv6Subnet24Bits, ipv6Subnet16Bits
+	}
+	var count24, count16 int
+	for _, p := range peers {
+		if p.rw.is(trustedConn|staticDialedConn) || p.ID() == c.node.ID() {
+			continue
+		}
+		pip := p.Node().IP()
+		if pip == nil {
+			continue
+		}
+		if netutil.SameNet(bits24, ip, pip) {
+			count24++
+		}
+		if netutil.SameNet(bits16, ip, pip) {
+			count16++
+		}
+	}
+	return (limit24 >= 0 && count24 >= limit24) || (limit16 >= 0 && count16 >= limit16)
+}
+
+// limitOrDefault returns the configured limit, the default if it is zero, or -1
+// if it is disabled.
+func limitOrDefault(limit, def int) int {
+	switch {
+	case limit == 0:
+		return def
+	case limit < 0:
+		return -1
+	default:
+		return limit
+	}
+}
+
+// ipHistory remembers the IPs of recent inbound connection attempts. All entries
+// expire after the same time, so they are kept in order of expiry.
+type ipHistory struct {
+	queue []ipAttempt
+	ips   map[string]struct{}
+}
+
+type ipAttempt struct {
+	ip  string
+	exp time.Time
+}
+
+func newIPHistory() *ipHistory {
+	return &ipHistory{ips: make(map[string]struct{})}
+}
+
+// add records an attempt from the IP which expires at the given time.
+func (h *ipHistory) add(ip net.IP, exp time.Time) {
+	key := ip.String()
+	h.ips[key] = struct{}{}
+	h.queue = append(h.queue, ipAttempt{key, exp})
+}
+
+// contains reports whether there is an unexpired attempt from the IP.
+func (h *ipHistory) contains(ip net.IP) bool {
+	_, ok := h.ips[ip.String()]
+	return ok
+}
+
+// expire drops the attempts which expired before now.
+func (h *ipHistory) expire(now time.Time) {
+	i := 0
+	for ; i < len(h.queue) && !h.queue[i].exp.After(now); i++ {
+		delete(h.ips, h.queue[i].ip)
+	}
+	h.queue = append(h.queue[:0], h.queue[i:]...)
+}
+
+// checkInboundConn decides whether an inbound connection from the given IP may
+// enter the handshake.
+func (srv *Server) checkInboundConn(ip net.IP, now time.Time) error {
+	if ip == nil {
+		return nil
+	}
+	// Reject connections that do not match NetRestrict.
+	if srv.NetRestrict != nil && !srv.NetRestrict.Contains(ip) {
+		return errNotWhitelisted
+	}
+	// Reject connections from banned subnets.
+	if srv.reputation.isIPBanned(ip) {
+		return errBanned
+	}
+	// Reject Internet nodes which try to connect too often.
+	srv.inboundHistory.expire(now)
+	if !netutil.IsLAN(ip) {
+		if srv.inboundHistory.contains(ip) {
+			return errInboundThrottle
+		}
+		srv.inboundHistory.add(ip, now.Add(inboundThrottleTime))
+	}
+	return nil
+}
+
+// meterRejection counts a connection rejected by the peer limits or bans.
+func meterRejection(err error) {
+	switch err {
+	case DiscTooManyPeers:
+		rejectTooManyMeter.Mark(1)
+	case errSubnetLimit:
+		rejectSubnetMeter.Mark(1)
+	case errInboundThrottle:
+		rejectThrottleMeter.Mark(1)
+	case errBanned:
+		rejectBannedMeter.Mark(1)
+	case errNotWhitelisted:
+		rejectRestrictedMeter.Mark(1)
+	}
+}
@@ -0,0 +1,160 @@
+// Copyright 2019 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package p2p
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/athofficial/go-ath/p2p/enode"
+	"github.com/athofficial/go-ath/p2p/enr"
+)
+
+// newLimitTestConn creates an inbound connection from a node with the given IP.
+func newLimitTestConn(id enode.ID, ip net.IP) *conn {
+	fd, _ := net.Pipe()
+	r := new(enr.Record)
+	r.Set(enr.IP(ip))
+	tx := newTestTransport(&newkey().PublicKey, fd)
+	return &conn{fd: fd, transport: tx, flags: inboundConn, node: enode.SignNull(r, id), cont: make(chan error)}
+}
+
+func TestServerSubnetLimit(t *testing.T) {
+	srv := &Server{
+		Config: Config{
+			PrivateKey:          newkey(),
+			MaxPeers:            20,
+			NoDial:              true,
+			MaxPeersPerSubnet24: 2,
+			MaxPeersPerSubnet16: 3,
+		},
+	}
+	if err := srv.Start(); err != nil {
+		t.Fatalf("could not start: %v", err)
+	}
+	defer srv.Stop()
+
+	tests := []struct {
+		ip   net.IP
+		want error
+	}{
+		{net.IP{8, 8, 8, 1}, nil},
+		{net.IP{8, 8, 8, 2}, nil},
+		{net.IP{8, 8, 8, 3}, errSubnetLimit}, // /24 is full
+		{net.IP{8, 8, 9, 1}, nil},
+		{net.IP{8, 8, 10, 1}, errSubnetLimit}, // /16 is full
+		{net.IP{8, 9, 0, 1}, nil},
+		{net.IP{192, 168, 0, 1}, nil}, // LAN isn't limited
+		{net.IP{192, 168, 0, 2}, nil},
+		{net.IP{192, 168, 0, 3}, nil},
+	}
+	for i, test := range tests {
+		c := newLimitTestConn(randomID(), test.ip)
+		if err := srv.checkpoint(c, srv.addpeer); err != test.want {
+			t.Errorf("test %d (%v): got error %v, want %v", i, test.ip, err, test.want)
+		}
+	}
+
+	// Trusted nodes bypass the limit.
+	trustedID := randomID()
+	srv.AddTrustedPeer(newNode(trustedID, nil))
+	c := newLimitTestConn(trustedID, net.IP{8, 8, 8, 4})
+	if err := srv.checkpoint(c, srv.posthandshake); err != nil {
+		t.Error("unexpected error for trusted conn:", err)
+	}
+}
+
+func TestServerTrustedSlots(t *testing.T) {
+	trustedNode := newkey()
+	trustedID := enode.PubkeyToIDV4(&trustedNode.PublicKey)
+	srv := &Server{
+		Config: Config{
+			PrivateKey:   newkey(),
+			MaxPeers:     10,
+			TrustedSlots: 2,
+			NoDial:       true,
+			TrustedNodes: []*enode.Node{newNode(trustedID, nil)},
+		},
+	}
+	if err := srv.Start(); err != nil {
+		t.Fatalf("could not start: %v", err)
+	}
+	defer srv.Stop()
+
+	// Fill all slots which aren't reserved.
+	for i := 0; i < 8; i++ {
+		c := newLimitTestConn(randomID(), net.IP{127, 0, 0, 1})
+		if err := srv.checkpoint(c, srv.addpeer); err != nil {
+			t.Fatalf("could not add conn %d: %v", i, err)
+		}
+	}
+	c := newLimitTestConn(randomID(), net.IP{127, 0, 0, 1})
+	if err := srv.checkpoint(c, srv.posthandshake); err != DiscTooManyPeers {
+		t.Error("wrong error for regular conn in reserved slot:", err)
+	}
+	c = newLimitTestConn(trustedID, net.IP{127, 0, 0, 1})
+	if err := srv.checkpoint(c, srv.posthandshake); err != nil {
+		t.Error("unexpected error for trusted conn:", err)
+	}
+}
+
+func TestServerSlotConfig(t *testing.T) {
+	srv := &Server{Config: Config{PrivateKey: newkey(), MaxPeers: 10, OutboundSlots: 6, TrustedSlots: 2}}
+	if got := srv.maxDialedConns(); got != 6 {
+		t.Errorf("wrong maxDialedConns %d, want 6", got)
+	}
+	if got := srv.maxInboundConns(); got != 2 {
+		t.Errorf("wrong maxInboundConns %d, want 2", got)
+	}
+	srv = &Server{Config: Config{PrivateKey: newkey(), MaxPeers: 10, OutboundSlots: 6, TrustedSlots: 5, NoDial: true}}
+	if err := srv.Start(); err == nil {
+		srv.Stop()
+		t.Error("server started with more reserved slots than peers")
+	}
+}
+
+func TestServerInboundThrottle(t *testing.T) {
+	srv := &Server{Config: Config{PrivateKey: newkey(), MaxPeers: 10, NoDial: true}}
+	if err := srv.Start(); err != nil {
+		t.Fatalf("could not start: %v", err)
+	}
+	defer srv.Stop()
+
+	var (
+		now = time.Now()
+		ip  = net.IP{8, 8, 8, 8}
+		lan = net.IP{127, 0, 0, 1}
+	)
+	if err := srv.checkInboundConn(ip, now); err != nil {
+		t.Fatal("first attempt rejected:", err)
+	}
+	if err := srv.checkInboundConn(ip, now.Add(time.Second)); err != errInboundThrottle {
+		t.Fatal("wrong error for repeated attempt:", err)
+	}
+	if err := srv.checkInboundConn(net.IP{8, 8, 8, 9}, now.Add(time.Second)); err != nil {
+		t.Fatal("attempt from other IP rejected:", err)
+	}
+	if err := srv.checkInboundConn(ip, now.Add(inboundThrottleTime)); err != nil {
+		t.Fatal("attempt after throttle time rejected:", err)
+	}
+	for i := 0; i < 3; i++ {
+		if err := srv.checkInboundConn(lan, now); err != nil {
+			t.Fatal("LAN attempt rejected:", err)
+		}
+	}
+}
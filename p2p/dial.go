@@ -37,6 +37,9 @@ const (
 	// once every few seconds.
 	lookupInterval = 4 * time.Second
 
+	// Number of random nodes retrieved from DNS node lists per lookup.
+	dnsLookupSize = 16
+
 	// If no peers are found for this amount of time, the initial bootnodes are
 	// attempted to be connected.
 	fallbackInterval = 20 * time.Second
@@ -71,6 +74,7 @@ type dialstate struct {
 	maxDynDials int
 	ntab        discoverTable
 	netrestrict *netutil.Netlist
+	reputation  *reputation // banned nodes aren't dialed if set
 	self        enode.ID
 
 	lookupRunning bool
@@ -218,7 +222,7 @@ func (s *dialstate) newTasks(nRunning int, peers map[enode.ID]*Peer, now time.Ti
 	// Use random nodes from the table for half of the necessary
 	// dynamic dials.
 	randomCandidates := needDynDials / 2
-	if randomCandidates > 0 {
+	if randomCandidates > 0 && s.ntab != nil {
 		n := s.ntab.ReadRandomNodes(s.randomNodes)
 		for i := 0; i < randomCandidates && i < n; i++ {
 			if addDial(dynDialedConn, s.randomNodes[i]) {
@@ -273,6 +277,8 @@ func (s *dialstate) checkDial(n *enode.Node, peers map[enode.ID]*Peer) error {
 		return errNotWhitelisted
 	case s.hist.contains(n.ID()):
 		return errRecentlyDialed
+	case s.reputation != nil && s.reputation.isBanned(n.ID(), n.IP()):
+		return errBanned
 	}
 	return nil
 }
@@ -368,7 +374,12 @@ func (t *discoverTask) Do(srv *Server) {
 		time.Sleep(next.Sub(now))
 	}
 	srv.lastLookup = time.Now()
-	t.results = srv.ntab.LookupRandom()
+	if srv.ntab != nil {
+		t.results = srv.ntab.LookupRandom()
+	}
+	if srv.dnsdisc != nil {
+		t.results = append(t.results, srv.dnsdisc.RandomNodes(dnsLookupSize)...)
+	}
 }
 
 func (t *discoverTask) String() string {
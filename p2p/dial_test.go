@@ -412,6 +412,34 @@ func newNode(id enode.ID, ip net.IP) *enode.Node {
 	return enode.SignNull(&r, id)
 }
 
+// This test checks that dynamic dials are launched from lookup results when
+// there is no discovery table, e.g. when only DNS node lists are configured.
+func TestDialStateDynDialWithoutTable(t *testing.T) {
+	runDialTest(t, dialtest{
+		init: newDialState(enode.ID{}, nil, nil, nil, 2, nil),
+		rounds: []round{
+			// A lookup is launched right away.
+			{
+				new: []task{&discoverTask{}},
+			},
+			// Dynamic dials are launched when it completes.
+			{
+				done: []task{
+					&discoverTask{results: []*enode.Node{
+						newNode(uintID(1), nil),
+						newNode(uintID(2), nil),
+						newNode(uintID(3), nil),
+					}},
+				},
+				new: []task{
+					&dialTask{flags: dynDialedConn, dest: newNode(uintID(1), nil)},
+					&dialTask{flags: dynDialedConn, dest: newNode(uintID(2), nil)},
+				},
+			},
+		},
+	})
+}
+
 // This test checks that candidates that do not match the netrestrict list are not dialed.
 func TestDialStateNetRestrict(t *testing.T) {
 	// This table always returns the same random nodes
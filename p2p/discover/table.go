@@ -88,8 +88,8 @@ type Table struct {
 // sockets and without generating a private key.
 type transport interface {
 	self() *enode.Node
-	ping(enode.ID, *net.UDPAddr) error
-	findnode(toid enode.ID, addr *net.UDPAddr, target encPubkey) ([]*node, error)
+	ping(*node) error
+	findnode(n *node, target encPubkey) ([]*node, error)
 	close()
 }
 
@@ -248,8 +248,18 @@ func (tab *Table) LookupRandom() []*enode.Node {
 // target by querying nodes that are closer to it on each iteration. The given target does
 // not need to be an actual node identifier.
 func (tab *Table) lookup(targetKey encPubkey, refreshIfEmpty bool) []*node {
+	target := enode.ID(crypto.Keccak256Hash(targetKey[:]))
+	return tab.lookupFunc(target, refreshIfEmpty, true, func(n *node) ([]*node, error) {
+		return tab.net.findnode(n, targetKey)
+	})
+}
+
+// lookupFunc performs a network search for nodes close to target, using the given query
+// function to ask a single node for nodes closer to it. This allows transports to
+// supply their own notion of the lookup target. If checklive is set, the search only
+// starts from table nodes which passed revalidation.
+func (tab *Table) lookupFunc(target enode.ID, refreshIfEmpty, checklive bool, query func(*node) ([]*node, error)) []*node {
 	var (
-		target         = enode.ID(crypto.Keccak256Hash(targetKey[:]))
 		asked          = make(map[enode.ID]bool)
 		seen           = make(map[enode.ID]bool)
 		reply          = make(chan []*node, alpha)
@@ -263,7 +273,7 @@ func (tab *Table) lookup(targetKey encPubkey, refreshIfEmpty bool) []*node {
 	for {
 		tab.mutex.Lock()
 		// generate initial result set
-		result = tab.closest(target, bucketSize)
+		result = tab.closestNodes(target, bucketSize, checklive)
 		tab.mutex.Unlock()
 		if len(result.entries) > 0 || !refreshIfEmpty {
 			break
@@ -283,7 +293,7 @@ func (tab *Table) lookup(targetKey encPubkey, refreshIfEmpty bool) []*node {
 			if !asked[n.ID()] {
 				asked[n.ID()] = true
 				pendingQueries++
-				go tab.findnode(n, targetKey, reply)
+				go tab.findnode(n, query, reply)
 			}
 		}
 		if pendingQueries == 0 {
@@ -306,9 +316,9 @@ func (tab *Table) lookup(targetKey encPubkey, refreshIfEmpty bool) []*node {
 	return result.entries
 }
 
-func (tab *Table) findnode(n *node, targetKey encPubkey, reply chan<- []*node) {
+func (tab *Table) findnode(n *node, query func(*node) ([]*node, error), reply chan<- []*node) {
 	fails := tab.db.FindFails(n.ID(), n.IP())
-	r, err := tab.net.findnode(n.ID(), n.addr(), targetKey)
+	r, err := query(n)
 	if err == errClosed {
 		// Avoid recording failures on shutdown.
 		reply <- nil
@@ -459,7 +469,7 @@ func (tab *Table) doRevalidate(done chan<- struct{}) {
 	}
 
 	// Ping the selected node and wait for a pong.
-	err := tab.net.ping(last.ID(), last.addr())
+	err := tab.net.ping(last)
 
 	tab.mutex.Lock()
 	defer tab.mutex.Unlock()
@@ -521,13 +531,18 @@ func (tab *Table) copyLiveNodes() {
 // closest returns the n nodes in the table that are closest to the
 // given id. The caller must hold tab.mutex.
 func (tab *Table) closest(target enode.ID, nresults int) *nodesByDistance {
+	return tab.closestNodes(target, nresults, true)
+}
+
+// closestNodes is like closest, but can also return nodes which weren't revalidated yet.
+func (tab *Table) closestNodes(target enode.ID, nresults int, checklive bool) *nodesByDistance {
 	// This is a very wasteful way to find the closest nodes but
 	// obviously correct. I believe that tree-based buckets would make
 	// this easier to implement efficiently.
 	close := &nodesByDistance{target: target}
 	for _, b := range &tab.buckets {
 		for _, n := range b.entries {
-			if n.livenessChecks > 0 {
+			if !checklive || n.livenessChecks > 0 {
 				close.push(n, nresults)
 			}
 		}
@@ -545,12 +560,51 @@ func (tab *Table) len() (n int) {
 // bucket returns the bucket for the given node ID hash.
 func (tab *Table) bucket(id enode.ID) *bucket {
 	d := enode.LogDist(tab.self().ID(), id)
+	return tab.bucketAtDistance(d)
+}
+
+// bucketAtDistance returns the bucket for the given logarithmic distance.
+func (tab *Table) bucketAtDistance(d int) *bucket {
 	if d <= bucketMinDistance {
 		return tab.buckets[0]
 	}
 	return tab.buckets[d-bucketMinDistance-1]
 }
 
+// getNode returns the node with the given ID or nil if it isn't in the table.
+func (tab *Table) getNode(id enode.ID) *enode.Node {
+	tab.mutex.Lock()
+	defer tab.mutex.Unlock()
+
+	b := tab.bucket(id)
+	for _, e := range b.entries {
+		if e.ID() == id {
+			return unwrapNode(e)
+		}
+	}
+	return nil
+}
+
+// appendNodesAtDistance adds the table nodes at the given logarithmic distance from
+// the local node to result. Distance zero is the local node itself.
+func (tab *Table) appendNodesAtDistance(dist int, result []*enode.Node) []*enode.Node {
+	if dist == 0 {
+		return append(result, tab.self())
+	}
+	if dist < 0 || dist > hashBits {
+		return result
+	}
+	tab.mutex.Lock()
+	defer tab.mutex.Unlock()
+
+	for _, n := range tab.bucketAtDistance(dist).entries {
+		if enode.LogDist(tab.self().ID(), n.ID()) == dist {
+			result = append(result, unwrapNode(n))
+		}
+	}
+	return result
+}
+
 // addSeenNode adds a node which may or may not be live to the end of a bucket. If the
 // bucket has space available, adding the node succeeds immediately. Otherwise, the node is
 // added to the replacements list.
@@ -605,15 +605,15 @@ func (tn *preminedTestnet) self() *enode.Node {
 	return nullNode
 }
 
-func (tn *preminedTestnet) findnode(toid enode.ID, toaddr *net.UDPAddr, target encPubkey) ([]*node, error) {
+func (tn *preminedTestnet) findnode(n *node, target encPubkey) ([]*node, error) {
 	// current log distance is encoded in port number
-	// fmt.Println("findnode query at dist", toaddr.Port)
-	if toaddr.Port == 0 {
+	// fmt.Println("findnode query at dist", n.UDP())
+	if n.UDP() == 0 {
 		panic("query to node at distance 0")
 	}
-	next := toaddr.Port - 1
+	next := n.UDP() - 1
 	var result []*node
-	for i, ekey := range tn.dists[toaddr.Port] {
+	for i, ekey := range tn.dists[n.UDP()] {
 		key, _ := decodePubkey(ekey)
 		node := wrapNode(enode.NewV4(key, net.ParseIP("127.0.0.1"), i, next))
 		result = append(result, node)
@@ -621,8 +621,8 @@ func (tn *preminedTestnet) findnode(toid enode.ID, toaddr *net.UDPAddr, target e
 	return result, nil
 }
 
-func (*preminedTestnet) close()                                        {}
-func (*preminedTestnet) ping(toid enode.ID, toaddr *net.UDPAddr) error { return nil }
+func (*preminedTestnet) close()           {}
+func (*preminedTestnet) ping(*node) error { return nil }
 
 // mine generates a testnet struct literal with nodes at
 // various distances to the given target.
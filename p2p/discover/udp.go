@@ -297,8 +297,8 @@ func (t *udp) ourEndpoint() rpcEndpoint {
 }
 
 // ping sends a ping message to the given node and waits for a reply.
-func (t *udp) ping(toid enode.ID, toaddr *net.UDPAddr) error {
-	return <-t.sendPing(toid, toaddr, nil)
+func (t *udp) ping(n *node) error {
+	return <-t.sendPing(n.ID(), n.addr(), nil)
 }
 
 // sendPing sends a ping message to the given node and invokes the callback
@@ -333,11 +333,12 @@ func (t *udp) sendPing(toid enode.ID, toaddr *net.UDPAddr, callback func()) <-ch
 
 // findnode sends a findnode request to the given node and waits until
 // the node has sent up to k neighbors.
-func (t *udp) findnode(toid enode.ID, toaddr *net.UDPAddr, target encPubkey) ([]*node, error) {
+func (t *udp) findnode(n *node, target encPubkey) ([]*node, error) {
+	toid, toaddr := n.ID(), n.addr()
 	// If we haven't seen a ping from the destination node for a while, it won't remember
 	// our endpoint proof and reject findnode. Solicit a ping first.
 	if time.Since(t.db.LastPingReceived(toid, toaddr.IP)) > bondExpiration {
-		t.ping(toid, toaddr)
+		<-t.sendPing(toid, toaddr, nil)
 		// Wait for them to ping back and process our pong.
 		time.Sleep(respTimeout)
 	}
@@ -150,9 +150,10 @@ func TestUDP_pingTimeout(t *testing.T) {
 	test := newUDPTest(t)
 	defer test.close()
 
+	key := newkey()
 	toaddr := &net.UDPAddr{IP: net.ParseIP("1.2.3.4"), Port: 2222}
-	toid := enode.ID{1, 2, 3, 4}
-	if err := test.udp.ping(toid, toaddr); err != errTimeout {
+	node := wrapNode(enode.NewV4(&key.PublicKey, toaddr.IP, 0, toaddr.Port))
+	if err := test.udp.ping(node); err != errTimeout {
 		t.Error("expected timeout error, got", err)
 	}
 }
@@ -234,10 +235,11 @@ func TestUDP_findnodeTimeout(t *testing.T) {
 	test := newUDPTest(t)
 	defer test.close()
 
+	key := newkey()
 	toaddr := &net.UDPAddr{IP: net.ParseIP("1.2.3.4"), Port: 2222}
-	toid := enode.ID{1, 2, 3, 4}
+	node := wrapNode(enode.NewV4(&key.PublicKey, toaddr.IP, 0, toaddr.Port))
 	target := encPubkey{4, 5, 6, 7}
-	result, err := test.udp.findnode(toid, toaddr, target)
+	result, err := test.udp.findnode(node, target)
 	if err != errTimeout {
 		t.Error("expected timeout error, got", err)
 	}
@@ -311,8 +313,8 @@ func TestUDP_findnodeMultiReply(t *testing.T) {
 	// queue a pending findnode request
 	resultc, errc := make(chan []*node), make(chan error)
 	go func() {
-		rid := encodePubkey(&test.remotekey.PublicKey).id()
-		ns, err := test.udp.findnode(rid, test.remoteaddr, testTarget)
+		rnode := enode.NewV4(&test.remotekey.PublicKey, test.remoteaddr.IP, 0, test.remoteaddr.Port)
+		ns, err := test.udp.findnode(wrapNode(rnode), testTarget)
 		if err != nil && len(ns) == 0 {
 			errc <- err
 		} else {
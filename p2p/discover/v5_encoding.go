@@ -0,0 +1,1141 @@
+// Copyright 2019 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package discover
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	crand "crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash"
+	"net"
+	"time"
+
+	"github.com/athofficial/go-ath/common/math"
+	"github.com/athofficial/go-ath/common/mclock"
+	"github.com/athofficial/go-ath/crypto"
+	"github.com/athofficial/go-ath/p2p/enode"
+	"github.com/athofficial/go-ath/p2p/enr"
+	"github.com/athofficial/go-ath/rlp"
+)
+
+// Discovery v5 packet types. The packet layout follows version 5.1 of the wire
+// specification:
+//
+//	packet        = masking-iv || masked-header || message
+//	masked-header = aes-ctr(key = dest-id[:16], iv = masking-iv, header)
+//	header        = static-header || authdata
+//	static-header = "discv5" || version || flag || nonce || authdata-size
+//
+// Messages are encrypted with AES-GCM using the session key, the packet nonce
+// and masking-iv || header as additional data.
+const (
+	flagMessage   = 0
+	flagWhoareyou = 1
+	flagHandshake = 2
+)
+
+// Discovery v5 message types.
+const (
+	pingMsgV5 byte = iota + 1
+	pongMsgV5
+	findnodeMsgV5
+	nodesMsgV5
+	talkreqMsgV5
+	talkrespMsgV5
+	regtopicMsgV5
+	ticketMsgV5
+	regconfirmationMsgV5
+	topicqueryMsgV5
+
+	unknownPacketV5   = byte(255) // any non-decryptable packet
+	whoareyouPacketV5 = byte(254) // the WHOAREYOU packet
+)
+
+const (
+	sizeofMaskingIV        = 16
+	sizeofNonce            = 12
+	sizeofStaticHeader     = 6 + 2 + 1 + sizeofNonce + 2 // protocol ID, version, flag, nonce, authdata size
+	sizeofStaticPacketData = sizeofMaskingIV + sizeofStaticHeader
+	sizeofWhoareyouAuth    = 16 + 8 // id nonce, record seq
+	sizeofHandshakeAuth    = 32 + 2 // src ID, sig size, ephemeral key size
+	sizeofMessageAuth      = 32     // src ID
+	minPacketSizeV5        = 63     // minimum size of a packet
+	maxPacketSizeV5        = 1280   // maximum size of a packet
+	aesKeySizeV5           = 16     // size of session keys
+	gcmTagSize             = 16     // size of the AES-GCM authentication tag
+	randomPacketMsgSize    = 20     // size of random packet content
+	handshakeTimeoutV5     = time.Second
+	maxSessionsV5          = 1024
+)
+
+var (
+	protocolIDV5        = [6]byte{'d', 'i', 's', 'c', 'v', '5'}
+	versionV5    uint16 = 1
+)
+
+// Discovery v5 codec errors.
+var (
+	errTooShort            = errors.New("packet too short")
+	errInvalidHeader       = errors.New("invalid packet header")
+	errInvalidFlag         = errors.New("invalid flag value in header")
+	errInvalidAuthSize     = errors.New("invalid auth size")
+	errUnexpectedHandshake = errors.New("unexpected auth response, not in handshake")
+	errInvalidNonceSig     = errors.New("invalid ID nonce signature")
+	errNoRecord            = errors.New("expected ENR in handshake but none sent")
+	errInvalidReqID        = errors.New("request ID larger than 8 bytes")
+	errMessageTooShort     = errors.New("message contains no data")
+)
+
+// packetV5 is implemented by all discovery v5 packet types.
+type packetV5 interface {
+	name() string    // Name returns a string corresponding to the message type.
+	kind() byte      // Kind returns the message type.
+	reqid() []byte   // Returns the request ID.
+	setreqid([]byte) // Sets the request ID.
+}
+
+// Discovery v5 messages and packets.
+type (
+	// unknownV5 represents any packet that can't be decrypted.
+	unknownV5 struct {
+		Nonce [sizeofNonce]byte
+	}
+
+	// whoareyouV5 is the handshake challenge packet.
+	whoareyouV5 struct {
+		ChallengeData []byte            // encoded challenge, used as key derivation salt
+		Nonce         [sizeofNonce]byte // nonce of the packet that triggered the challenge
+		IDNonce       [16]byte          // identity proof data
+		RecordSeq     uint64            // ENR sequence number of recipient
+
+		// Node is the locally known node record of the recipient. This must be
+		// set by the caller of encode.
+		Node *enode.Node
+
+		sent mclock.AbsTime // for handshake GC.
+	}
+
+	// PING is sent during liveness checks.
+	pingV5 struct {
+		ReqID  []byte
+		ENRSeq uint64
+	}
+
+	// PONG is the reply to PING.
+	pongV5 struct {
+		ReqID  []byte
+		ENRSeq uint64
+		ToIP   net.IP // These fields should mirror the UDP envelope address of the ping
+		ToPort uint16 // packet, which provides a way to discover the external address (after NAT).
+	}
+
+	// FINDNODE is a query for nodes in the given bucket.
+	findnodeV5 struct {
+		ReqID     []byte
+		Distances []uint
+	}
+
+	// NODES is the reply to FINDNODE and TOPICQUERY.
+	nodesV5 struct {
+		ReqID []byte
+		Total uint8
+		Nodes []*enr.Record
+	}
+
+	// TALKREQ is an application-level request.
+	talkreqV5 struct {
+		ReqID    []byte
+		Protocol string
+		Message  []byte
+	}
+
+	// TALKRESP is the reply to TALKREQ.
+	talkrespV5 struct {
+		ReqID   []byte
+		Message []byte
+	}
+
+	// REGTOPIC requests placement of a topic advertisement.
+	regtopicV5 struct {
+		ReqID  []byte
+		Topic  enode.ID
+		ENR    *enr.Record
+		Ticket []byte
+	}
+
+	// TICKET is the first reply to REGTOPIC. A zero wait time means the
+	// advertisement was placed and REGCONFIRMATION follows.
+	ticketV5 struct {
+		ReqID    []byte
+		Ticket   []byte
+		WaitTime uint // in seconds
+	}
+
+	// REGCONFIRMATION notifies the registrant that its advertisement was placed.
+	regconfirmationV5 struct {
+		ReqID []byte
+		Topic enode.ID
+	}
+
+	// TOPICQUERY asks for nodes advertising the given topic.
+	topicqueryV5 struct {
+		ReqID []byte
+		Topic enode.ID
+	}
+)
+
+func (*unknownV5) name() string       { return "UNKNOWN/v5" }
+func (*unknownV5) kind() byte         { return unknownPacketV5 }
+func (*unknownV5) reqid() []byte      { return nil }
+func (*unknownV5) setreqid(id []byte) {}
+
+func (*whoareyouV5) name() string       { return "WHOAREYOU/v5" }
+func (*whoareyouV5) kind() byte         { return whoareyouPacketV5 }
+func (*whoareyouV5) reqid() []byte      { return nil }
+func (*whoareyouV5) setreqid(id []byte) {}
+
+func (*pingV5) name() string         { return "PING/v5" }
+func (*pingV5) kind() byte           { return pingMsgV5 }
+func (p *pingV5) reqid() []byte      { return p.ReqID }
+func (p *pingV5) setreqid(id []byte) { p.ReqID = id }
+
+func (*pongV5) name() string         { return "PONG/v5" }
+func (*pongV5) kind() byte           { return pongMsgV5 }
+func (p *pongV5) reqid() []byte      { return p.ReqID }
+func (p *pongV5) setreqid(id []byte) { p.ReqID = id }
+
+func (*findnodeV5) name() string         { return "FINDNODE/v5" }
+func (*findnodeV5) kind() byte           { return findnodeMsgV5 }
+func (p *findnodeV5) reqid() []byte      { return p.ReqID }
+func (p *findnodeV5) setreqid(id []byte) { p.ReqID = id }
+
+func (*nodesV5) name() string         { return "NODES/v5" }
+func (*nodesV5) kind() byte           { return nodesMsgV5 }
+func (p *nodesV5) reqid() []byte      { return p.ReqID }
+func (p *nodesV5) setreqid(id []byte) { p.ReqID = id }
+
+func (*talkreqV5) name() string         { return "TALKREQ/v5" }
+func (*talkreqV5) kind() byte           { return talkreqMsgV5 }
+func (p *talkreqV5) reqid() []byte      { return p.ReqID }
+func (p *talkreqV5) setreqid(id []byte) { p.ReqID = id }
+
+func (*talkrespV5) name() string         { return "TALKRESP/v5" }
+func (*talkrespV5) kind() byte           { return talkrespMsgV5 }
+func (p *talkrespV5) reqid() []byte      { return p.ReqID }
+func (p *talkrespV5) setreqid(id []byte) { p.ReqID = id }
+
+func (*regtopicV5) name() string         { return "REGTOPIC/v5" }
+func (*regtopicV5) kind() byte           { return regtopicMsgV5 }
+func (p *regtopicV5) reqid() []byte      { return p.ReqID }
+func (p *regtopicV5) setreqid(id []byte) { p.ReqID = id }
+
+func (*ticketV5) name() string         { return "TICKET/v5" }
+func (*ticketV5) kind() byte           { return ticketMsgV5 }
+func (p *ticketV5) reqid() []byte      { return p.ReqID }
+func (p *ticketV5) setreqid(id []byte) { p.ReqID = id }
+
+func (*regconfirmationV5) name() string         { return "REGCONFIRMATION/v5" }
+func (*regconfirmationV5) kind() byte           { return regconfirmationMsgV5 }
+func (p *regconfirmationV5) reqid() []byte      { return p.ReqID }
+func (p *regconfirmationV5) setreqid(id []byte) { p.ReqID = id }
+
+func (*topicqueryV5) name() string         { return "TOPICQUERY/v5" }
+func (*topicqueryV5) kind() byte           { return topicqueryMsgV5 }
+func (p *topicqueryV5) reqid() []byte      { return p.ReqID }
+func (p *topicqueryV5) setreqid(id []byte) { p.ReqID = id }
+
+// staticHeaderV5 is the fixed-size part of the packet header.
+type staticHeaderV5 struct {
+	ProtocolID [6]byte
+	Version    uint16
+	Flag       byte
+	Nonce      [sizeofNonce]byte
+	AuthSize   uint16
+}
+
+// headerV5 represents a packet header.
+type headerV5 struct {
+	IV [sizeofMaskingIV]byte
+	staticHeaderV5
+	AuthData []byte
+
+	src enode.ID // used by decoder
+}
+
+// codecV5 encodes and decodes discovery v5 packets. It is not safe for
+// concurrent use.
+type codecV5 struct {
+	sha256    hash.Hash
+	localnode *enode.LocalNode
+	privkey   *ecdsa.PrivateKey
+	sc        *sessionCache
+
+	// encoder buffers
+	buf      bytes.Buffer // whole packet
+	headbuf  bytes.Buffer // packet header
+	msgbuf   bytes.Buffer // message RLP plaintext
+	msgctbuf []byte       // message data ciphertext
+
+	// decoder buffer
+	reader bytes.Reader
+}
+
+// newCodecV5 creates a discovery v5 codec.
+func newCodecV5(ln *enode.LocalNode, key *ecdsa.PrivateKey, clock mclock.Clock) *codecV5 {
+	return &codecV5{
+		sha256:    sha256.New(),
+		localnode: ln,
+		privkey:   key,
+		sc:        newSessionCache(maxSessionsV5, clock),
+	}
+}
+
+// encode encodes a packet to a node. 'id' and 'addr' specify the destination node. The
+// 'challenge' parameter should be the most recently received WHOAREYOU packet from that
+// node. It returns the encoded packet and the nonce of the packet header.
+func (c *codecV5) encode(id enode.ID, addr string, packet packetV5, challenge *whoareyouV5) ([]byte, [sizeofNonce]byte, error) {
+	// Create the packet header.
+	var (
+		head    *headerV5
+		session *session
+		msgData []byte
+		err     error
+	)
+	switch {
+	case packet.kind() == whoareyouPacketV5:
+		head, err = c.encodeWhoareyou(id, packet.(*whoareyouV5))
+	case challenge != nil:
+		// We have an unanswered challenge, send handshake.
+		head, session, err = c.encodeHandshakeHeader(id, addr, challenge)
+	default:
+		session = c.sc.session(id, addr)
+		if session != nil {
+			// There is a session, use it.
+			head, err = c.encodeMessageHeader(id, session)
+		} else {
+			// No keys, send random data to kick off the handshake.
+			head, msgData, err = c.encodeRandom(id)
+		}
+	}
+	if err != nil {
+		return nil, [sizeofNonce]byte{}, err
+	}
+
+	// Generate masking IV.
+	if err := c.sc.maskingIVGen(head.IV[:]); err != nil {
+		return nil, [sizeofNonce]byte{}, fmt.Errorf("can't generate masking IV: %v", err)
+	}
+
+	// Encode header data.
+	c.writeHeaders(head)
+
+	// Store sent WHOAREYOU challenges.
+	if w, ok := packet.(*whoareyouV5); ok {
+		w.ChallengeData = bytesCopy(&c.buf)
+		c.sc.storeSentHandshake(id, addr, w)
+	} else if msgData == nil {
+		headerData := c.buf.Bytes()
+		msgData, err = c.encryptMessage(session, packet, head, headerData)
+		if err != nil {
+			return nil, [sizeofNonce]byte{}, err
+		}
+	}
+
+	enc, err := c.encodePacket(id, head, msgData)
+	return enc, head.Nonce, err
+}
+
+// writeHeaders writes the unmasked header of the packet to c.buf.
+func (c *codecV5) writeHeaders(head *headerV5) {
+	c.buf.Reset()
+	c.buf.Write(head.IV[:])
+	binary.Write(&c.buf, binary.BigEndian, &head.staticHeaderV5)
+	c.buf.Write(head.AuthData)
+}
+
+// makeHeader creates a packet header.
+func (c *codecV5) makeHeader(toID enode.ID, flag byte, authsizeExtra int) *headerV5 {
+	var authsize int
+	switch flag {
+	case flagMessage:
+		authsize = sizeofMessageAuth
+	case flagWhoareyou:
+		authsize = sizeofWhoareyouAuth
+	case flagHandshake:
+		authsize = sizeofHandshakeAuth
+	default:
+		panic(fmt.Errorf("BUG: invalid packet header flag %x", flag))
+	}
+	authsize += authsizeExtra
+	if authsize > int(^uint16(0)) {
+		panic(fmt.Errorf("BUG: auth size %d overflows uint16", authsize))
+	}
+	return &headerV5{
+		staticHeaderV5: staticHeaderV5{
+			ProtocolID: protocolIDV5,
+			Version:    versionV5,
+			Flag:       flag,
+			AuthSize:   uint16(authsize),
+		},
+	}
+}
+
+// encodeRandom encodes a packet with random content.
+func (c *codecV5) encodeRandom(toID enode.ID) (*headerV5, []byte, error) {
+	head := c.makeHeader(toID, flagMessage, 0)
+
+	// Encode auth data.
+	auth := messageAuthDataV5{SrcID: c.localnode.ID()}
+	if _, err := crand.Read(head.Nonce[:]); err != nil {
+		return nil, nil, fmt.Errorf("can't get random data: %v", err)
+	}
+	c.headbuf.Reset()
+	binary.Write(&c.headbuf, binary.BigEndian, auth)
+	head.AuthData = c.headbuf.Bytes()
+
+	// Fill message ciphertext buffer with random bytes.
+	c.msgctbuf = append(c.msgctbuf[:0], make([]byte, randomPacketMsgSize)...)
+	crand.Read(c.msgctbuf)
+	return head, c.msgctbuf, nil
+}
+
+// encodeWhoareyou encodes a WHOAREYOU packet.
+func (c *codecV5) encodeWhoareyou(toID enode.ID, packet *whoareyouV5) (*headerV5, error) {
+	// Sanity check node field to catch misbehaving callers.
+	if packet.RecordSeq > 0 && packet.Node == nil {
+		panic("BUG: missing node in whoareyouV5 with non-zero seq")
+	}
+
+	// Create header.
+	head := c.makeHeader(toID, flagWhoareyou, 0)
+	head.Nonce = packet.Nonce
+
+	// Encode auth data.
+	auth := &whoareyouAuthDataV5{
+		IDNonce:   packet.IDNonce,
+		RecordSeq: packet.RecordSeq,
+	}
+	c.headbuf.Reset()
+	binary.Write(&c.headbuf, binary.BigEndian, auth)
+	head.AuthData = c.headbuf.Bytes()
+	return head, nil
+}
+
+// encodeHandshakeHeader encodes the handshake message packet header.
+func (c *codecV5) encodeHandshakeHeader(toID enode.ID, addr string, challenge *whoareyouV5) (*headerV5, *session, error) {
+	// Ensure calling code sets challenge.node.
+	if challenge.Node == nil {
+		panic("BUG: missing challenge.Node in encode")
+	}
+
+	// Generate new secrets.
+	auth, session, err := c.makeHandshakeAuth(toID, addr, challenge)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Generate nonce for message.
+	nonce, err := c.sc.nextNonce(session)
+	if err != nil {
+		return nil, nil, fmt.Errorf("can't generate nonce: %v", err)
+	}
+
+	// Store the keys right away so the response can be decrypted.
+	c.sc.storeNewSession(toID, addr, session)
+
+	// Encode the auth header.
+	var (
+		authsizeExtra = len(auth.pubkey) + len(auth.signature) + len(auth.record)
+		head          = c.makeHeader(toID, flagHandshake, authsizeExtra)
+	)
+	c.headbuf.Reset()
+	binary.Write(&c.headbuf, binary.BigEndian, &auth.h)
+	c.headbuf.Write(auth.signature)
+	c.headbuf.Write(auth.pubkey)
+	c.headbuf.Write(auth.record)
+	head.AuthData = c.headbuf.Bytes()
+	head.Nonce = nonce
+	return head, session, err
+}
+
+// makeHandshakeAuth creates the auth header on a request packet following WHOAREYOU.
+func (c *codecV5) makeHandshakeAuth(toID enode.ID, addr string, challenge *whoareyouV5) (*handshakeAuthDataV5, *session, error) {
+	auth := new(handshakeAuthDataV5)
+	auth.h.SrcID = c.localnode.ID()
+
+	// Create the ephemeral key. This needs to be first because the
+	// key is part of the ID nonce signature.
+	var remotePubkey = new(ecdsa.PublicKey)
+	if err := challenge.Node.Load((*enode.Secp256k1)(remotePubkey)); err != nil {
+		return nil, nil, fmt.Errorf("can't find secp256k1 key for recipient")
+	}
+	ephkey, err := crypto.GenerateKey()
+	if err != nil {
+		return nil, nil, fmt.Errorf("can't generate ephemeral key")
+	}
+	ephpubkey := encodePubkeyV5(&ephkey.PublicKey)
+	auth.pubkey = ephpubkey[:]
+	auth.h.PubkeySize = byte(len(auth.pubkey))
+
+	// Add ID nonce signature to response.
+	cdata := challenge.ChallengeData
+	idsig, err := makeIDSignature(c.sha256, c.privkey, cdata, ephpubkey[:], toID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("can't sign: %v", err)
+	}
+	auth.signature = idsig
+	auth.h.SigSize = byte(len(auth.signature))
+
+	// Add our record to response if it's newer than what remote side has.
+	ln := c.localnode.Node()
+	if challenge.RecordSeq < ln.Seq() {
+		auth.record, _ = rlp.EncodeToBytes(ln.Record())
+	}
+
+	// Create session keys.
+	sec := deriveKeys(sha256.New, ephkey, remotePubkey, c.localnode.ID(), challenge.Node.ID(), cdata)
+	if sec == nil {
+		return nil, nil, fmt.Errorf("key derivation failed")
+	}
+	return auth, sec, err
+}
+
+// encodeMessageHeader encodes an encrypted message packet header.
+func (c *codecV5) encodeMessageHeader(toID enode.ID, s *session) (*headerV5, error) {
+	head := c.makeHeader(toID, flagMessage, 0)
+
+	// Create the header.
+	nonce, err := c.sc.nextNonce(s)
+	if err != nil {
+		return nil, fmt.Errorf("can't generate nonce: %v", err)
+	}
+	auth := messageAuthDataV5{SrcID: c.localnode.ID()}
+	c.headbuf.Reset()
+	binary.Write(&c.headbuf, binary.BigEndian, &auth)
+	head.AuthData = c.headbuf.Bytes()
+	head.Nonce = nonce
+	return head, err
+}
+
+// encryptMessage encodes and encrypts the message plaintext.
+func (c *codecV5) encryptMessage(s *session, p packetV5, head *headerV5, headerData []byte) ([]byte, error) {
+	// Encode message plaintext.
+	c.msgbuf.Reset()
+	c.msgbuf.WriteByte(p.kind())
+	if err := rlp.Encode(&c.msgbuf, p); err != nil {
+		return nil, err
+	}
+	messagePT := c.msgbuf.Bytes()
+
+	// Encrypt into message ciphertext buffer.
+	messageCT, err := encryptGCM(c.msgctbuf[:0], s.writeKey, head.Nonce[:], messagePT, headerData)
+	if err == nil {
+		c.msgctbuf = messageCT
+	}
+	return messageCT, err
+}
+
+// encodePacket masks the header in c.buf and appends the message data.
+func (c *codecV5) encodePacket(toID enode.ID, head *headerV5, msgData []byte) ([]byte, error) {
+	enc := make([]byte, c.buf.Len(), c.buf.Len()+len(msgData))
+	copy(enc, c.buf.Bytes())
+	masked := enc[sizeofMaskingIV:]
+	mask := headerMask(toID, head.IV[:])
+	mask.XORKeyStream(masked, masked)
+	enc = append(enc, msgData...)
+	if len(enc) > maxPacketSizeV5 {
+		return nil, fmt.Errorf("packet too large: %d bytes", len(enc))
+	}
+	return enc, nil
+}
+
+// decode decodes a discovery packet.
+func (c *codecV5) decode(input []byte, addr string) (src enode.ID, n *enode.Node, p packetV5, err error) {
+	if len(input) < minPacketSizeV5 {
+		return enode.ID{}, nil, nil, errTooShort
+	}
+	// Copy the input because the header is unmasked in place.
+	input = append([]byte(nil), input...)
+
+	// Unmask the static header.
+	var head headerV5
+	copy(head.IV[:], input[:sizeofMaskingIV])
+	mask := headerMask(c.localnode.ID(), input[:sizeofMaskingIV])
+	staticHeader := input[sizeofMaskingIV:sizeofStaticPacketData]
+	mask.XORKeyStream(staticHeader, staticHeader)
+
+	// Decode and verify the static header.
+	c.reader.Reset(staticHeader)
+	binary.Read(&c.reader, binary.BigEndian, &head.staticHeaderV5)
+	remainingInput := len(input) - sizeofStaticPacketData
+	if err := head.checkValid(remainingInput); err != nil {
+		return enode.ID{}, nil, nil, err
+	}
+
+	// Unmask auth data.
+	authDataEnd := sizeofStaticPacketData + int(head.AuthSize)
+	authData := input[sizeofStaticPacketData:authDataEnd]
+	mask.XORKeyStream(authData, authData)
+	head.AuthData = authData
+
+	// Delete timed-out handshakes. This must happen before decoding to avoid
+	// processing the same handshake twice.
+	c.sc.handshakeGC()
+
+	// Decode auth part and message.
+	headerData := input[:authDataEnd]
+	msgData := input[authDataEnd:]
+	switch head.Flag {
+	case flagWhoareyou:
+		p, err = c.decodeWhoareyou(&head, headerData)
+	case flagHandshake:
+		n, p, err = c.decodeHandshakeMessage(addr, &head, headerData, msgData)
+	case flagMessage:
+		p, err = c.decodeMessage(addr, &head, headerData, msgData)
+	default:
+		err = errInvalidFlag
+	}
+	return head.src, n, p, err
+}
+
+// decodeWhoareyou reads packet data after the header as a WHOAREYOU packet.
+func (c *codecV5) decodeWhoareyou(head *headerV5, headerData []byte) (packetV5, error) {
+	if len(head.AuthData) != sizeofWhoareyouAuth {
+		return nil, fmt.Errorf("invalid auth size %d for WHOAREYOU", len(head.AuthData))
+	}
+	var auth whoareyouAuthDataV5
+	c.reader.Reset(head.AuthData)
+	binary.Read(&c.reader, binary.BigEndian, &auth)
+	p := &whoareyouV5{
+		Nonce:         head.Nonce,
+		IDNonce:       auth.IDNonce,
+		RecordSeq:     auth.RecordSeq,
+		ChallengeData: make([]byte, len(headerData)),
+	}
+	copy(p.ChallengeData, headerData)
+	return p, nil
+}
+
+// decodeHandshakeMessage reads the handshake auth data and decrypts the message.
+func (c *codecV5) decodeHandshakeMessage(fromAddr string, head *headerV5, headerData, msgData []byte) (n *enode.Node, p packetV5, err error) {
+	node, auth, session, err := c.decodeHandshake(fromAddr, head)
+	if err != nil {
+		c.sc.deleteHandshake(auth.h.SrcID, fromAddr)
+		return nil, nil, err
+	}
+
+	// Decrypt the message using the new session keys.
+	msg, err := c.decryptMessage(msgData, head.Nonce[:], headerData, session.readKey)
+	if err != nil {
+		c.sc.deleteHandshake(auth.h.SrcID, fromAddr)
+		return node, msg, err
+	}
+
+	// Handshake OK, drop the challenge and store the new session keys.
+	c.sc.storeNewSession(auth.h.SrcID, fromAddr, session)
+	c.sc.deleteHandshake(auth.h.SrcID, fromAddr)
+	return node, msg, nil
+}
+
+func (c *codecV5) decodeHandshake(fromAddr string, head *headerV5) (n *enode.Node, auth handshakeAuthDataV5, s *session, err error) {
+	if auth, err = c.decodeHandshakeAuthData(head); err != nil {
+		return nil, auth, nil, err
+	}
+
+	// Verify against our last WHOAREYOU.
+	challenge := c.sc.getHandshake(auth.h.SrcID, fromAddr)
+	if challenge == nil {
+		return nil, auth, nil, errUnexpectedHandshake
+	}
+	// Get node record.
+	n, err = c.decodeHandshakeRecord(challenge.Node, auth.h.SrcID, auth.record)
+	if err != nil {
+		return nil, auth, nil, err
+	}
+	// Verify ID nonce signature.
+	sig := auth.signature
+	cdata := challenge.ChallengeData
+	err = verifyIDSignature(c.sha256, sig, n, cdata, auth.pubkey, c.localnode.ID())
+	if err != nil {
+		return nil, auth, nil, err
+	}
+	// Verify ephemeral key is on curve.
+	ephkey, err := decodePubkeyV5(crypto.S256(), auth.pubkey)
+	if err != nil {
+		return nil, auth, nil, errInvalidAuthKey
+	}
+	// Derive session keys.
+	session := deriveKeys(sha256.New, c.privkey, ephkey, auth.h.SrcID, c.localnode.ID(), cdata)
+	if session == nil {
+		return nil, auth, nil, errInvalidAuthKey
+	}
+	session = session.keysFlipped()
+	return n, auth, session, nil
+}
+
+// decodeHandshakeAuthData reads the authdata section of a handshake packet.
+func (c *codecV5) decodeHandshakeAuthData(head *headerV5) (auth handshakeAuthDataV5, err error) {
+	// Decode fixed size part.
+	if len(head.AuthData) < sizeofHandshakeAuth {
+		return auth, fmt.Errorf("header authsize %d too low for handshake", head.AuthSize)
+	}
+	c.reader.Reset(head.AuthData)
+	binary.Read(&c.reader, binary.BigEndian, &auth.h)
+	head.src = auth.h.SrcID
+
+	// Decode variable-size part.
+	var (
+		vardata       = head.AuthData[sizeofHandshakeAuth:]
+		sigAndKeySize = int(auth.h.SigSize) + int(auth.h.PubkeySize)
+		keyOffset     = int(auth.h.SigSize)
+		recOffset     = keyOffset + int(auth.h.PubkeySize)
+	)
+	if len(vardata) < sigAndKeySize {
+		return auth, errTooShort
+	}
+	auth.signature = vardata[:keyOffset]
+	auth.pubkey = vardata[keyOffset:recOffset]
+	auth.record = vardata[recOffset:]
+	return auth, nil
+}
+
+// decodeHandshakeRecord verifies the node record contained in a handshake packet. The
+// remote node should include the record if we don't have one or if ours is older than the
+// latest sequence number.
+func (c *codecV5) decodeHandshakeRecord(local *enode.Node, wantID enode.ID, remote []byte) (*enode.Node, error) {
+	node := local
+	if len(remote) > 0 {
+		var record enr.Record
+		if err := rlp.DecodeBytes(remote, &record); err != nil {
+			return nil, err
+		}
+		if local == nil || local.Seq() < record.Seq() {
+			n, err := enode.New(enode.ValidSchemes, &record)
+			if err != nil {
+				return nil, fmt.Errorf("invalid node record: %v", err)
+			}
+			if n.ID() != wantID {
+				return nil, fmt.Errorf("record in handshake has wrong ID: %v", n.ID())
+			}
+			node = n
+		}
+	}
+	if node == nil {
+		return nil, errNoRecord
+	}
+	return node, nil
+}
+
+// decodeMessage reads packet data following the header as an ordinary message packet.
+func (c *codecV5) decodeMessage(fromAddr string, head *headerV5, headerData, msgData []byte) (packetV5, error) {
+	if len(head.AuthData) != sizeofMessageAuth {
+		return nil, fmt.Errorf("invalid auth size %d for message packet", len(head.AuthData))
+	}
+	var auth messageAuthDataV5
+	c.reader.Reset(head.AuthData)
+	binary.Read(&c.reader, binary.BigEndian, &auth)
+	head.src = auth.SrcID
+
+	// Try decrypting the message.
+	key := c.sc.readKey(auth.SrcID, fromAddr)
+	msg, err := c.decryptMessage(msgData, head.Nonce[:], headerData, key)
+	if err == errMessageDecrypt {
+		// It didn't work. Start the handshake since this is an ordinary message packet.
+		return &unknownV5{Nonce: head.Nonce}, nil
+	}
+	return msg, err
+}
+
+func (c *codecV5) decryptMessage(input, nonce, headerData, readKey []byte) (packetV5, error) {
+	msgdata, err := decryptGCM(readKey, nonce, input, headerData)
+	if err != nil {
+		return nil, errMessageDecrypt
+	}
+	if len(msgdata) == 0 {
+		return nil, errMessageTooShort
+	}
+	return decodeMessageV5(msgdata[0], msgdata[1:])
+}
+
+// checkValid performs some basic validity checks on the header.
+// The packetLen here is the length remaining after the static header.
+func (h *staticHeaderV5) checkValid(packetLen int) error {
+	if h.ProtocolID != protocolIDV5 {
+		return errInvalidHeader
+	}
+	if h.Version < versionV5 {
+		return errInvalidHeader
+	}
+	if h.Flag != flagWhoareyou && packetLen < gcmTagSize {
+		return errTooShort
+	}
+	if int(h.AuthSize) > packetLen {
+		return errInvalidAuthSize
+	}
+	return nil
+}
+
+// headerMask returns a cipher for 'masking' / 'unmasking' packet headers.
+func headerMask(destID enode.ID, iv []byte) cipher.Stream {
+	block, err := aes.NewCipher(destID[:16])
+	if err != nil {
+		panic("can't create cipher")
+	}
+	return cipher.NewCTR(block, iv)
+}
+
+// decodeMessageV5 decodes the plaintext of a message packet.
+func decodeMessageV5(ptype byte, body []byte) (packetV5, error) {
+	var dec packetV5
+	switch ptype {
+	case pingMsgV5:
+		dec = new(pingV5)
+	case pongMsgV5:
+		dec = new(pongV5)
+	case findnodeMsgV5:
+		dec = new(findnodeV5)
+	case nodesMsgV5:
+		dec = new(nodesV5)
+	case talkreqMsgV5:
+		dec = new(talkreqV5)
+	case talkrespMsgV5:
+		dec = new(talkrespV5)
+	case regtopicMsgV5:
+		dec = new(regtopicV5)
+	case ticketMsgV5:
+		dec = new(ticketV5)
+	case regconfirmationMsgV5:
+		dec = new(regconfirmationV5)
+	case topicqueryMsgV5:
+		dec = new(topicqueryV5)
+	default:
+		return nil, fmt.Errorf("unknown packet type %d", ptype)
+	}
+	if err := rlp.DecodeBytes(body, dec); err != nil {
+		return nil, err
+	}
+	if dec.reqid() != nil && len(dec.reqid()) > 8 {
+		return nil, errInvalidReqID
+	}
+	return dec, nil
+}
+
+// Auth data structures.
+type (
+	messageAuthDataV5 struct {
+		SrcID enode.ID
+	}
+
+	whoareyouAuthDataV5 struct {
+		IDNonce   [16]byte // ID proof data
+		RecordSeq uint64   // highest known ENR sequence of requester
+	}
+
+	handshakeAuthDataV5 struct {
+		h struct {
+			SrcID      enode.ID
+			SigSize    byte // size of the ID signature
+			PubkeySize byte // size of the ephemeral public key
+		}
+		// Trailing variable-size data.
+		signature, pubkey, record []byte
+	}
+)
+
+// bytesCopy copies the content of a buffer.
+func bytesCopy(r *bytes.Buffer) []byte {
+	b := make([]byte, r.Len())
+	copy(b, r.Bytes())
+	return b
+}
+
+// Session keys and handshake state.
+
+// session contains session information.
+type session struct {
+	writeKey     []byte
+	readKey      []byte
+	nonceCounter uint32
+}
+
+// keysFlipped returns a copy of s with the read and write keys flipped.
+func (s *session) keysFlipped() *session {
+	return &session{s.readKey, s.writeKey, s.nonceCounter}
+}
+
+// sessionID identifies a session or handshake.
+type sessionID struct {
+	id   enode.ID
+	addr string
+}
+
+// sessionCache keeps negotiated encryption keys and state for in-progress handshakes
+// in the discovery v5 wire protocol.
+type sessionCache struct {
+	sessions   map[sessionID]*session
+	handshakes map[sessionID]*whoareyouV5
+	maxItems   int
+	clock      mclock.Clock
+
+	// hooks for overriding randomness.
+	nonceGen     func(uint32) ([sizeofNonce]byte, error)
+	maskingIVGen func([]byte) error
+}
+
+func newSessionCache(maxItems int, clock mclock.Clock) *sessionCache {
+	return &sessionCache{
+		sessions:     make(map[sessionID]*session),
+		handshakes:   make(map[sessionID]*whoareyouV5),
+		maxItems:     maxItems,
+		clock:        clock,
+		nonceGen:     generateNonce,
+		maskingIVGen: generateMaskingIV,
+	}
+}
+
+func generateNonce(counter uint32) (n [sizeofNonce]byte, err error) {
+	binary.BigEndian.PutUint32(n[:4], counter)
+	_, err = crand.Read(n[4:])
+	return n, err
+}
+
+func generateMaskingIV(buf []byte) error {
+	_, err := crand.Read(buf)
+	return err
+}
+
+// nextNonce creates a nonce for encrypting a message to the given session.
+func (sc *sessionCache) nextNonce(s *session) ([sizeofNonce]byte, error) {
+	s.nonceCounter++
+	return sc.nonceGen(s.nonceCounter)
+}
+
+// session returns the current session for the given node, if any.
+func (sc *sessionCache) session(id enode.ID, addr string) *session {
+	return sc.sessions[sessionID{id, addr}]
+}
+
+// readKey returns the current read key for the given node.
+func (sc *sessionCache) readKey(id enode.ID, addr string) []byte {
+	if s := sc.session(id, addr); s != nil {
+		return s.readKey
+	}
+	return nil
+}
+
+// storeNewSession stores new encryption keys in the cache.
+func (sc *sessionCache) storeNewSession(id enode.ID, addr string, s *session) {
+	key := sessionID{id, addr}
+	if _, ok := sc.sessions[key]; !ok && len(sc.sessions) >= sc.maxItems {
+		// Evict a random session to make room.
+		for k := range sc.sessions {
+			delete(sc.sessions, k)
+			break
+		}
+	}
+	sc.sessions[key] = s
+}
+
+// getHandshake gets the handshake challenge we previously sent to the given remote node.
+func (sc *sessionCache) getHandshake(id enode.ID, addr string) *whoareyouV5 {
+	return sc.handshakes[sessionID{id, addr}]
+}
+
+// storeSentHandshake stores the handshake challenge sent to the given remote node.
+func (sc *sessionCache) storeSentHandshake(id enode.ID, addr string, challenge *whoareyouV5) {
+	challenge.sent = sc.clock.Now()
+	sc.handshakes[sessionID{id, addr}] = challenge
+}
+
+// deleteHandshake deletes handshake data for the given node.
+func (sc *sessionCache) deleteHandshake(id enode.ID, addr string) {
+	delete(sc.handshakes, sessionID{id, addr})
+}
+
+// handshakeGC deletes timed-out handshakes.
+func (sc *sessionCache) handshakeGC() {
+	deadline := sc.clock.Now().Add(-handshakeTimeoutV5)
+	for key, challenge := range sc.handshakes {
+		if challenge.sent < deadline {
+			delete(sc.handshakes, key)
+		}
+	}
+}
+
+// Cryptographic primitives.
+
+var (
+	errInvalidAuthKey = errors.New("invalid ephemeral pubkey")
+	errMessageDecrypt = errors.New("cannot decrypt message")
+)
+
+// encodePubkeyV5 encodes a public key in compressed format.
+func encodePubkeyV5(key *ecdsa.PublicKey) []byte {
+	switch key.Curve {
+	case crypto.S256():
+		return crypto.CompressPubkey(key)
+	default:
+		panic("unsupported curve " + key.Curve.Params().Name + " in encodePubkeyV5")
+	}
+}
+
+// decodePubkeyV5 decodes a public key in compressed format.
+func decodePubkeyV5(curve elliptic.Curve, e []byte) (*ecdsa.PublicKey, error) {
+	switch curve {
+	case crypto.S256():
+		if len(e) != 33 {
+			return nil, errors.New("wrong size public key data")
+		}
+		return crypto.DecompressPubkey(e)
+	default:
+		return nil, fmt.Errorf("unsupported curve %s in decodePubkeyV5", curve.Params().Name)
+	}
+}
+
+// idNonceHash computes the ID signature hash used in the handshake.
+func idNonceHash(h hash.Hash, challenge, ephkey []byte, destID enode.ID) []byte {
+	h.Reset()
+	h.Write([]byte("discovery v5 identity proof"))
+	h.Write(challenge)
+	h.Write(ephkey)
+	h.Write(destID[:])
+	return h.Sum(nil)
+}
+
+// makeIDSignature creates the ID nonce signature.
+func makeIDSignature(hash hash.Hash, key *ecdsa.PrivateKey, challenge, ephkey []byte, destID enode.ID) ([]byte, error) {
+	input := idNonceHash(hash, challenge, ephkey, destID)
+	switch key.Curve {
+	case crypto.S256():
+		idsig, err := crypto.Sign(input, key)
+		if err != nil {
+			return nil, err
+		}
+		return idsig[:len(idsig)-1], nil // remove recovery ID
+	default:
+		return nil, fmt.Errorf("unsupported curve %s", key.Curve.Params().Name)
+	}
+}
+
+// verifyIDSignature checks that signature over idnonce was made by the given node.
+func verifyIDSignature(hash hash.Hash, sig []byte, n *enode.Node, challenge, ephkey []byte, destID enode.ID) error {
+	switch idscheme := n.Record().IdentityScheme(); idscheme {
+	case "v4":
+		var pubkey enode.Secp256k1
+		if n.Load(&pubkey) != nil {
+			return errors.New("no secp256k1 public key in record")
+		}
+		input := idNonceHash(hash, challenge, ephkey, destID)
+		if !crypto.VerifySignature(crypto.CompressPubkey((*ecdsa.PublicKey)(&pubkey)), input, sig) {
+			return errInvalidNonceSig
+		}
+		return nil
+	default:
+		return fmt.Errorf("can't verify ID nonce signature against scheme %q", idscheme)
+	}
+}
+
+type hashFn func() hash.Hash
+
+// deriveKeys creates the session keys.
+func deriveKeys(hash hashFn, priv *ecdsa.PrivateKey, pub *ecdsa.PublicKey, n1, n2 enode.ID, challenge []byte) *session {
+	const text = "discovery v5 key agreement"
+	var info = make([]byte, 0, len(text)+len(n1)+len(n2))
+	info = append(info, text...)
+	info = append(info, n1[:]...)
+	info = append(info, n2[:]...)
+
+	eph := ecdh(priv, pub)
+	if eph == nil {
+		return nil
+	}
+	keys := hkdf(hash, eph, challenge, info, 2*aesKeySizeV5)
+	return &session{writeKey: keys[:aesKeySizeV5], readKey: keys[aesKeySizeV5:]}
+}
+
+// hkdf derives length bytes of keying material from secret as specified in RFC 5869.
+func hkdf(hash hashFn, secret, salt, info []byte, length int) []byte {
+	extractor := hmac.New(hash, salt)
+	extractor.Write(secret)
+	prk := extractor.Sum(nil)
+
+	var out, prev []byte
+	for i := byte(1); len(out) < length; i++ {
+		expander := hmac.New(hash, prk)
+		expander.Write(prev)
+		expander.Write(info)
+		expander.Write([]byte{i})
+		prev = expander.Sum(nil)
+		out = append(out, prev...)
+	}
+	return out[:length]
+}
+
+// ecdh creates a shared secret.
+func ecdh(privkey *ecdsa.PrivateKey, pubkey *ecdsa.PublicKey) []byte {
+	secX, secY := pubkey.ScalarMult(pubkey.X, pubkey.Y, privkey.D.Bytes())
+	if secX == nil {
+		return nil
+	}
+	sec := make([]byte, 33)
+	sec[0] = 0x02 | byte(secY.Bit(0))
+	math.ReadBits(secX, sec[1:])
+	return sec
+}
+
+// encryptGCM encrypts pt using AES-GCM with the given key and nonce. The ciphertext is
+// appended to dest, which must not overlap with plaintext. The resulting ciphertext is 16
+// bytes longer than plaintext because it contains an authentication tag.
+func encryptGCM(dest, key, nonce, plaintext, authData []byte) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		panic(fmt.Errorf("can't create block cipher: %v", err))
+	}
+	aesgcm, err := cipher.NewGCMWithNonceSize(block, sizeofNonce)
+	if err != nil {
+		panic(fmt.Errorf("can't create GCM: %v", err))
+	}
+	return aesgcm.Seal(dest, nonce, plaintext, authData), nil
+}
+
+// decryptGCM decrypts ct using AES-GCM with the given key and nonce.
+func decryptGCM(key, nonce, ct, authData []byte) ([]byte, error) {
+	if len(key) != aesKeySizeV5 {
+		return nil, errMessageDecrypt
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("can't create block cipher: %v", err)
+	}
+	if len(nonce) != sizeofNonce {
+		return nil, fmt.Errorf("invalid GCM nonce size: %d", len(nonce))
+	}
+	aesgcm, err := cipher.NewGCMWithNonceSize(block, sizeofNonce)
+	if err != nil {
+		return nil, fmt.Errorf("can't create GCM: %v", err)
+	}
+	pt := make([]byte, 0, len(ct))
+	return aesgcm.Open(pt, nonce, ct, authData)
+}
@@ -0,0 +1,272 @@
+// Copyright 2019 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package discover
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/sha256"
+	"encoding/hex"
+	"net"
+	"reflect"
+	"testing"
+
+	"github.com/athofficial/go-ath/common/mclock"
+	"github.com/athofficial/go-ath/p2p/enode"
+)
+
+// Tests the key derivation function against the first SHA-256 test vector of RFC 5869.
+func TestHKDF(t *testing.T) {
+	var (
+		ikm  = bytes.Repeat([]byte{0x0b}, 22)
+		salt = hexBytes("000102030405060708090a0b0c")
+		info = hexBytes("f0f1f2f3f4f5f6f7f8f9")
+		want = hexBytes("3cb25f25faacd57a90434f64d0362f2a2d2d0a90cf1a5a4c5db02d56ecc4c5bf34007208d5b887185865")
+	)
+	if okm := hkdf(sha256.New, ikm, salt, info, len(want)); !bytes.Equal(okm, want) {
+		t.Fatalf("wrong output:\nhave %x\nwant %x", okm, want)
+	}
+}
+
+// Tests that both sides of a handshake derive matching keys.
+func TestDeriveKeysV5(t *testing.T) {
+	var (
+		keyA, keyB = newkey(), newkey()
+		idA, idB   = enode.PubkeyToIDV4(&keyA.PublicKey), enode.PubkeyToIDV4(&keyB.PublicKey)
+		challenge  = []byte("challenge data")
+	)
+	initiator := deriveKeys(sha256.New, keyA, &keyB.PublicKey, idA, idB, challenge)
+	recipient := deriveKeys(sha256.New, keyB, &keyA.PublicKey, idA, idB, challenge).keysFlipped()
+	if !bytes.Equal(initiator.writeKey, recipient.readKey) || !bytes.Equal(initiator.readKey, recipient.writeKey) {
+		t.Fatal("session keys don't match")
+	}
+	if bytes.Equal(initiator.writeKey, initiator.readKey) {
+		t.Fatal("read and write keys are equal")
+	}
+}
+
+// Tests that the ID signature verifies against the signer's record only.
+func TestIDSignatureV5(t *testing.T) {
+	var (
+		key       = newkey()
+		node      = enode.NewLocalNode(newTestDB(t), key).Node()
+		other     = enode.NewLocalNode(newTestDB(t), newkey()).Node()
+		challenge = []byte("challenge data")
+		ephkey    = encodePubkeyV5(&newkey().PublicKey)
+		destID    = enode.ID{1}
+	)
+	sig, err := makeIDSignature(sha256.New(), key, challenge, ephkey, destID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := verifyIDSignature(sha256.New(), sig, node, challenge, ephkey, destID); err != nil {
+		t.Fatal("valid signature rejected:", err)
+	}
+	if err := verifyIDSignature(sha256.New(), sig, other, challenge, ephkey, destID); err == nil {
+		t.Fatal("signature accepted for wrong node")
+	}
+	if err := verifyIDSignature(sha256.New(), sig, node, challenge, ephkey, enode.ID{2}); err == nil {
+		t.Fatal("signature accepted for wrong destination")
+	}
+}
+
+// Tests the handshake between two codecs and the encrypted messages following it.
+func TestHandshakeV5(t *testing.T) {
+	ht := newHandshakeTest(t)
+
+	// A -> B   RANDOM PACKET
+	packet, _ := ht.nodeA.encode(t, ht.nodeB, &pingV5{ReqID: []byte("ping")}, nil)
+	_, _, unknown := ht.nodeB.expectDecode(t, unknownPacketV5, packet)
+
+	// A <- B   WHOAREYOU
+	challenge := &whoareyouV5{Nonce: unknown.(*unknownV5).Nonce, IDNonce: [16]byte{1, 2, 3}}
+	whoareyou, _ := ht.nodeB.encode(t, ht.nodeA, challenge, nil)
+	_, _, received := ht.nodeA.expectDecode(t, whoareyouPacketV5, whoareyou)
+	if !bytes.Equal(received.(*whoareyouV5).ChallengeData, challenge.ChallengeData) {
+		t.Fatal("challenge data mismatch")
+	}
+
+	// A -> B   HANDSHAKE
+	resp := received.(*whoareyouV5)
+	resp.Node = ht.nodeB.n()
+	ping := &pingV5{ReqID: []byte("ping"), ENRSeq: 5}
+	handshake, _ := ht.nodeA.encode(t, ht.nodeB, ping, resp)
+	src, node, dec := ht.nodeB.expectDecode(t, pingMsgV5, handshake)
+	if src != ht.nodeA.id() || node == nil || node.ID() != ht.nodeA.id() {
+		t.Fatal("wrong sender in handshake")
+	}
+	if !reflect.DeepEqual(dec, ping) {
+		t.Fatalf("wrong message:\nhave %+v\nwant %+v", dec, ping)
+	}
+
+	// A <- B   PONG, encrypted with the session keys
+	pong := &pongV5{ReqID: []byte("ping"), ENRSeq: 3, ToIP: ht.nodeA.addr.IP, ToPort: uint16(ht.nodeA.addr.Port)}
+	enc, _ := ht.nodeB.encode(t, ht.nodeA, pong, nil)
+	_, _, dec = ht.nodeA.expectDecode(t, pongMsgV5, enc)
+	if !reflect.DeepEqual(dec, pong) {
+		t.Fatalf("wrong message:\nhave %+v\nwant %+v", dec, pong)
+	}
+
+	// A -> B   FINDNODE, encrypted with the session keys
+	findnode := &findnodeV5{ReqID: []byte("find"), Distances: []uint{255, 256}}
+	enc, _ = ht.nodeA.encode(t, ht.nodeB, findnode, nil)
+	ht.nodeB.expectDecode(t, findnodeMsgV5, enc)
+
+	// Tampered packets can't be decrypted and restart the handshake.
+	enc[len(enc)-1] ^= 0xFF
+	ht.nodeB.expectDecode(t, unknownPacketV5, enc)
+}
+
+// Tests that a handshake without a prior WHOAREYOU is rejected.
+func TestHandshakeV5_unexpected(t *testing.T) {
+	ht := newHandshakeTest(t)
+
+	challenge := &whoareyouV5{IDNonce: [16]byte{1}, ChallengeData: []byte("data"), Node: ht.nodeB.n()}
+	handshake, _ := ht.nodeA.encode(t, ht.nodeB, &pingV5{}, challenge)
+	if _, _, _, err := ht.nodeB.decode(handshake); err != errUnexpectedHandshake {
+		t.Fatalf("wrong error: %v", err)
+	}
+}
+
+// Tests that a handshake signed for a different challenge is rejected.
+func TestHandshakeV5_badSignature(t *testing.T) {
+	ht := newHandshakeTest(t)
+
+	packet, _ := ht.nodeA.encode(t, ht.nodeB, &pingV5{}, nil)
+	_, _, unknown := ht.nodeB.expectDecode(t, unknownPacketV5, packet)
+	challenge := &whoareyouV5{Nonce: unknown.(*unknownV5).Nonce, IDNonce: [16]byte{1}}
+	whoareyou, _ := ht.nodeB.encode(t, ht.nodeA, challenge, nil)
+	_, _, received := ht.nodeA.expectDecode(t, whoareyouPacketV5, whoareyou)
+
+	resp := received.(*whoareyouV5)
+	resp.Node = ht.nodeB.n()
+	resp.ChallengeData = append([]byte{}, resp.ChallengeData...)
+	resp.ChallengeData[0]++
+	handshake, _ := ht.nodeA.encode(t, ht.nodeB, &pingV5{}, resp)
+	if _, _, _, err := ht.nodeB.decode(handshake); err != errInvalidNonceSig {
+		t.Fatalf("wrong error: %v", err)
+	}
+}
+
+// Tests that garbage input is rejected.
+func TestDecodeErrorsV5(t *testing.T) {
+	ht := newHandshakeTest(t)
+
+	if _, _, _, err := ht.nodeB.decode(make([]byte, minPacketSizeV5-1)); err != errTooShort {
+		t.Fatalf("wrong error for short packet: %v", err)
+	}
+	if _, _, _, err := ht.nodeB.decode(make([]byte, 100)); err != errInvalidHeader {
+		t.Fatalf("wrong error for zero packet: %v", err)
+	}
+}
+
+func TestLookupDistances(t *testing.T) {
+	var target enode.ID
+	tests := []struct {
+		dest enode.ID
+		want []uint
+	}{
+		{enode.ID{0x80}, []uint{256, 255, 254}},
+		{enode.ID{31: 0x01}, []uint{1, 2, 3}},
+		{enode.ID{31: 0x08}, []uint{4, 5, 3}},
+	}
+	for _, test := range tests {
+		if dists := lookupDistances(target, test.dest); !reflect.DeepEqual(dists, test.want) {
+			t.Errorf("wrong distances for %v: have %v, want %v", test.dest, dists, test.want)
+		}
+	}
+}
+
+// handshakeTest is a pair of codecs talking to each other.
+type handshakeTest struct {
+	nodeA, nodeB handshakeTestNode
+}
+
+type handshakeTestNode struct {
+	ln   *enode.LocalNode
+	c    *codecV5
+	addr *net.UDPAddr
+	peer string // address packets are decoded from
+}
+
+func newHandshakeTest(t *testing.T) *handshakeTest {
+	ht := new(handshakeTest)
+	ht.nodeA.init(t, newkey(), net.IP{127, 0, 0, 1}, 30401)
+	ht.nodeB.init(t, newkey(), net.IP{127, 0, 0, 1}, 30402)
+	ht.nodeA.peer, ht.nodeB.peer = ht.nodeB.addr.String(), ht.nodeA.addr.String()
+	return ht
+}
+
+func (n *handshakeTestNode) init(t *testing.T, key *ecdsa.PrivateKey, ip net.IP, port int) {
+	n.ln = enode.NewLocalNode(newTestDB(t), key)
+	n.ln.SetStaticIP(ip)
+	n.ln.SetFallbackUDP(port)
+	n.addr = &net.UDPAddr{IP: ip, Port: port}
+	n.c = newCodecV5(n.ln, key, mclock.System{})
+}
+
+func (n *handshakeTestNode) encode(t *testing.T, to handshakeTestNode, p packetV5, challenge *whoareyouV5) ([]byte, [sizeofNonce]byte) {
+	t.Helper()
+	enc, nonce, err := n.c.encode(to.id(), to.addr.String(), p, challenge)
+	if err != nil {
+		t.Fatalf("%s encode error: %v", p.name(), err)
+	}
+	if len(enc) > maxPacketSizeV5 {
+		t.Fatalf("%s packet too large: %d bytes", p.name(), len(enc))
+	}
+	return enc, nonce
+}
+
+func (n *handshakeTestNode) expectDecode(t *testing.T, ptype byte, p []byte) (enode.ID, *enode.Node, packetV5) {
+	t.Helper()
+	src, node, dec, err := n.decode(p)
+	if err != nil {
+		t.Fatalf("decode error: %v", err)
+	}
+	if dec.kind() != ptype {
+		t.Fatalf("expected packet type %d, got %d", ptype, dec.kind())
+	}
+	return src, node, dec
+}
+
+func (n *handshakeTestNode) decode(input []byte) (enode.ID, *enode.Node, packetV5, error) {
+	return n.c.decode(input, n.peer)
+}
+
+func (n *handshakeTestNode) n() *enode.Node {
+	return n.ln.Node()
+}
+
+func (n *handshakeTestNode) id() enode.ID {
+	return n.ln.ID()
+}
+
+func newTestDB(t *testing.T) *enode.DB {
+	db, err := enode.OpenDB("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+func hexBytes(s string) []byte {
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		panic(err)
+	}
+	return b
+}
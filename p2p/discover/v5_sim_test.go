@@ -0,0 +1,145 @@
+// Copyright 2019 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package discover_test
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/athofficial/go-ath/node"
+	"github.com/athofficial/go-ath/p2p"
+	"github.com/athofficial/go-ath/p2p/discover"
+	"github.com/athofficial/go-ath/p2p/enode"
+	"github.com/athofficial/go-ath/p2p/simulations"
+	"github.com/athofficial/go-ath/p2p/simulations/adapters"
+	"github.com/athofficial/go-ath/rpc"
+)
+
+// discService runs discovery v5 on localhost for a simulated node and
+// advertises the configured topics.
+type discService struct {
+	bootnodes []*enode.Node
+	topics    []discover.Topic
+	udp       *discover.UDPv5
+	quit      chan struct{}
+}
+
+func (s *discService) Protocols() []p2p.Protocol { return nil }
+func (s *discService) APIs() []rpc.API          { return nil }
+
+func (s *discService) Start(srv *p2p.Server) error {
+	db, err := enode.OpenDB("")
+	if err != nil {
+		return err
+	}
+	socket, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IP{127, 0, 0, 1}})
+	if err != nil {
+		return err
+	}
+	ln := enode.NewLocalNode(db, srv.PrivateKey)
+	ln.SetStaticIP(net.IP{127, 0, 0, 1})
+	ln.SetFallbackUDP(socket.LocalAddr().(*net.UDPAddr).Port)
+	cfg := discover.Config{PrivateKey: srv.PrivateKey, Bootnodes: s.bootnodes}
+	if s.udp, err = discover.ListenV5(socket, ln, cfg); err != nil {
+		socket.Close()
+		return err
+	}
+	s.quit = make(chan struct{})
+	for _, topic := range s.topics {
+		go s.udp.RegisterTopic(topic, s.quit)
+	}
+	return nil
+}
+
+func (s *discService) Stop() error {
+	close(s.quit)
+	s.udp.Close()
+	return nil
+}
+
+// Tests that light clients and indexers find the servers advertising the LES and
+// archive topics in a simulated network.
+func TestUDPv5_simulation(t *testing.T) {
+	var (
+		lesTopic     = discover.Topic("les2@0123456789abcdef")
+		archiveTopic = discover.Topic("archive@0123456789abcdef")
+		configs      = make(map[enode.ID]*discService)
+	)
+	adapter := adapters.NewSimAdapter(adapters.Services{
+		"discv5": func(ctx *adapters.ServiceContext) (node.Service, error) {
+			return configs[ctx.Config.ID], nil
+		},
+	})
+	network := simulations.NewNetwork(adapter, &simulations.NetworkConfig{DefaultService: "discv5"})
+	defer network.Shutdown()
+
+	startNode := func(s *discService) *discService {
+		t.Helper()
+		conf := adapters.RandomNodeConfig()
+		configs[conf.ID] = s
+		if _, err := network.NewNodeWithConfig(conf); err != nil {
+			t.Fatal("can't create node:", err)
+		}
+		if err := network.Start(conf.ID); err != nil {
+			t.Fatal("can't start node:", err)
+		}
+		return s
+	}
+	var (
+		boot     = startNode(new(discService))
+		bootnode = []*enode.Node{boot.udp.Self()}
+		servers  = []*discService{
+			startNode(&discService{bootnodes: bootnode, topics: []discover.Topic{lesTopic}}),
+			startNode(&discService{bootnodes: bootnode, topics: []discover.Topic{lesTopic, archiveTopic}}),
+			startNode(&discService{bootnodes: bootnode, topics: []discover.Topic{lesTopic}}),
+		}
+		others = []*discService{
+			startNode(&discService{bootnodes: bootnode}),
+			startNode(&discService{bootnodes: bootnode}),
+			startNode(&discService{bootnodes: bootnode}),
+		}
+		client = others[len(others)-1]
+	)
+	for _, s := range others {
+		s.udp.LookupRandom()
+	}
+
+	expect := func(topic discover.Topic, want ...*discService) {
+		t.Helper()
+		deadline := time.Now().Add(10 * time.Second)
+		for {
+			found := make(map[enode.ID]bool)
+			for _, n := range client.udp.TopicQuery(topic) {
+				found[n.ID()] = true
+			}
+			complete := len(found) == len(want)
+			for _, s := range want {
+				complete = complete && found[s.udp.Self().ID()]
+			}
+			if complete {
+				return
+			}
+			if time.Now().After(deadline) {
+				t.Fatalf("topic %q: found %d nodes, want %d", topic, len(found), len(want))
+			}
+			time.Sleep(100 * time.Millisecond)
+		}
+	}
+	expect(lesTopic, servers...)
+	expect(archiveTopic, servers[1])
+}
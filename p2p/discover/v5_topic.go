@@ -0,0 +1,210 @@
+// Copyright 2019 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package discover
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	crand "crypto/rand"
+	"crypto/sha256"
+	"errors"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/athofficial/go-ath/common/mclock"
+	"github.com/athofficial/go-ath/p2p/enode"
+	"github.com/athofficial/go-ath/rlp"
+)
+
+const (
+	adLifetime     = 15 * time.Minute // lifetime of a topic advertisement
+	maxAdsPerTopic = 100              // maximum number of ads of a single topic
+	maxAds         = 10000            // maximum number of ads of all topics
+	ticketValidity = 10 * time.Second // how long a ticket can be used after its wait time
+)
+
+var (
+	errInvalidTicket = errors.New("invalid ticket")
+	errTicketExpired = errors.New("ticket expired")
+)
+
+// Topic is the name of a service advertised through discovery v5 topic
+// registration.
+type Topic string
+
+// hash returns the identifier of the topic in the network. Registrars of the topic are
+// the nodes closest to it.
+func (t Topic) hash() enode.ID {
+	return enode.ID(sha256.Sum256([]byte(t)))
+}
+
+// topicAd is an advertisement placed at the local node.
+type topicAd struct {
+	node    *enode.Node
+	expires mclock.AbsTime
+}
+
+// topicTable stores the topic advertisements placed at the local node and issues
+// tickets to registrants which have to wait for space.
+type topicTable struct {
+	mu     sync.Mutex
+	clock  mclock.Clock
+	ads    map[enode.ID][]*topicAd // ads of each topic, ordered by expiry
+	count  int                     // number of ads of all topics
+	ticket cipher.AEAD             // seals tickets, only readable by the local node
+}
+
+// ticketData is the content of a ticket.
+type ticketData struct {
+	Src    enode.ID
+	IP     net.IP
+	Topic  enode.ID
+	Issued uint64 // mclock.AbsTime
+	Wait   uint64 // time.Duration
+}
+
+func newTopicTable(clock mclock.Clock) *topicTable {
+	var key [16]byte
+	if _, err := crand.Read(key[:]); err != nil {
+		panic("can't generate ticket key: " + err.Error())
+	}
+	block, _ := aes.NewCipher(key[:])
+	aead, _ := cipher.NewGCM(block)
+	return &topicTable{
+		clock:  clock,
+		ads:    make(map[enode.ID][]*topicAd),
+		ticket: aead,
+	}
+}
+
+// register places an ad for the given node if there is room for it. Otherwise it returns
+// the time until room becomes available. Ads which are already placed can always be
+// renewed.
+func (tt *topicTable) register(topic enode.ID, n *enode.Node) time.Duration {
+	tt.mu.Lock()
+	defer tt.mu.Unlock()
+
+	now := tt.clock.Now()
+	tt.expire(now)
+
+	ads := tt.ads[topic]
+	for i, ad := range ads {
+		if ad.node.ID() == n.ID() {
+			ads = append(ads[:i], ads[i+1:]...)
+			tt.ads[topic] = append(ads, &topicAd{n, now.Add(adLifetime)})
+			return 0
+		}
+	}
+	switch {
+	case len(ads) >= maxAdsPerTopic:
+		return time.Duration(ads[0].expires - now)
+	case tt.count >= maxAds:
+		return time.Duration(tt.nextExpiry() - now)
+	}
+	tt.ads[topic] = append(ads, &topicAd{n, now.Add(adLifetime)})
+	tt.count++
+	return 0
+}
+
+// nodes returns up to max of the most recently registered advertisers of a topic.
+func (tt *topicTable) nodes(topic enode.ID, max int) []*enode.Node {
+	tt.mu.Lock()
+	defer tt.mu.Unlock()
+
+	tt.expire(tt.clock.Now())
+	ads := tt.ads[topic]
+	nodes := make([]*enode.Node, 0, max)
+	for i := len(ads) - 1; i >= 0 && len(nodes) < max; i-- {
+		nodes = append(nodes, ads[i].node)
+	}
+	return nodes
+}
+
+// expire drops all ads which have expired before now.
+func (tt *topicTable) expire(now mclock.AbsTime) {
+	for topic, ads := range tt.ads {
+		i := 0
+		for ; i < len(ads) && ads[i].expires <= now; i++ {
+			tt.count--
+		}
+		if i == len(ads) {
+			delete(tt.ads, topic)
+		} else if i > 0 {
+			tt.ads[topic] = ads[i:]
+		}
+	}
+}
+
+// nextExpiry returns the expiry time of the oldest ad of all topics.
+func (tt *topicTable) nextExpiry() mclock.AbsTime {
+	var next mclock.AbsTime
+	for _, ads := range tt.ads {
+		if next == 0 || ads[0].expires < next {
+			next = ads[0].expires
+		}
+	}
+	return next
+}
+
+// makeTicket creates a ticket telling the registrant to come back after wait.
+func (tt *topicTable) makeTicket(src enode.ID, ip net.IP, topic enode.ID, wait time.Duration) []byte {
+	data, err := rlp.EncodeToBytes(&ticketData{
+		Src:    src,
+		IP:     ip,
+		Topic:  topic,
+		Issued: uint64(tt.clock.Now()),
+		Wait:   uint64(wait),
+	})
+	if err != nil {
+		panic("can't encode ticket: " + err.Error())
+	}
+	nonce := make([]byte, tt.ticket.NonceSize(), tt.ticket.NonceSize()+len(data)+tt.ticket.Overhead())
+	crand.Read(nonce)
+	return tt.ticket.Seal(nonce, nonce, data, nil)
+}
+
+// checkTicket verifies that the ticket was issued by the local node for the given
+// registrant and topic. It returns the remaining wait time of the ticket.
+func (tt *topicTable) checkTicket(ticket []byte, src enode.ID, ip net.IP, topic enode.ID) (time.Duration, error) {
+	ns := tt.ticket.NonceSize()
+	if len(ticket) < ns {
+		return 0, errInvalidTicket
+	}
+	data, err := tt.ticket.Open(nil, ticket[:ns], ticket[ns:], nil)
+	if err != nil {
+		return 0, errInvalidTicket
+	}
+	var t ticketData
+	if err := rlp.DecodeBytes(data, &t); err != nil {
+		return 0, errInvalidTicket
+	}
+	if t.Src != src || !t.IP.Equal(ip) || t.Topic != topic {
+		return 0, errInvalidTicket
+	}
+	var (
+		now      = tt.clock.Now()
+		waitDone = mclock.AbsTime(t.Issued).Add(time.Duration(t.Wait))
+	)
+	if now > waitDone.Add(ticketValidity) {
+		return 0, errTicketExpired
+	}
+	if now < waitDone {
+		return time.Duration(waitDone - now), nil
+	}
+	return 0, nil
+}
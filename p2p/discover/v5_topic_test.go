@@ -0,0 +1,133 @@
+// Copyright 2019 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package discover
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/athofficial/go-ath/common/mclock"
+	"github.com/athofficial/go-ath/p2p/enode"
+)
+
+func newTopicTestNode(i int) *enode.Node {
+	return enode.NewV4(&newkey().PublicKey, net.IP{10, 0, 0, byte(i)}, 30303, 30303)
+}
+
+// Tests that the topic table accepts ads until full and reports the wait time
+// until the next ad expires.
+func TestTopicTableRegister(t *testing.T) {
+	var (
+		clock = new(mclock.Simulated)
+		tt    = newTopicTable(clock)
+		topic = Topic("les").hash()
+		nodes = make([]*enode.Node, maxAdsPerTopic+1)
+	)
+	for i := range nodes {
+		nodes[i] = newTopicTestNode(i)
+	}
+	for i := 0; i < maxAdsPerTopic; i++ {
+		if wait := tt.register(topic, nodes[i]); wait != 0 {
+			t.Fatalf("ad %d not placed, wait %v", i, wait)
+		}
+		clock.Run(time.Second)
+	}
+	// The table is full, the next ad has to wait until the first one expires.
+	wantWait := adLifetime - maxAdsPerTopic*time.Second
+	if wait := tt.register(topic, nodes[maxAdsPerTopic]); wait != wantWait {
+		t.Fatalf("wrong wait time: have %v, want %v", wait, wantWait)
+	}
+	// Renewals are always accepted.
+	if wait := tt.register(topic, nodes[0]); wait != 0 {
+		t.Fatalf("renewal not accepted, wait %v", wait)
+	}
+	// Other topics are unaffected.
+	if wait := tt.register(Topic("archive").hash(), nodes[maxAdsPerTopic]); wait != 0 {
+		t.Fatalf("ad for other topic not placed, wait %v", wait)
+	}
+	// After expiry of the oldest ad (nodes[1] now, nodes[0] was renewed), there is
+	// room again.
+	clock.Run(wantWait + time.Second)
+	if wait := tt.register(topic, nodes[maxAdsPerTopic]); wait != 0 {
+		t.Fatalf("ad not placed after expiry, wait %v", wait)
+	}
+	if n := len(tt.nodes(topic, 2*maxAdsPerTopic)); n != maxAdsPerTopic {
+		t.Fatalf("wrong number of ads: have %d, want %d", n, maxAdsPerTopic)
+	}
+}
+
+// Tests that all ads expire after their lifetime.
+func TestTopicTableExpiry(t *testing.T) {
+	var (
+		clock = new(mclock.Simulated)
+		tt    = newTopicTable(clock)
+		topic = Topic("les").hash()
+	)
+	tt.register(topic, newTopicTestNode(1))
+	tt.register(topic, newTopicTestNode(2))
+	if nodes := tt.nodes(topic, 10); len(nodes) != 2 {
+		t.Fatalf("wrong number of ads: have %d, want 2", len(nodes))
+	}
+	clock.Run(adLifetime)
+	if nodes := tt.nodes(topic, 10); len(nodes) != 0 {
+		t.Fatalf("ads not expired: %v", nodes)
+	}
+	if tt.count != 0 || len(tt.ads) != 0 {
+		t.Fatalf("table not empty: count %d, topics %d", tt.count, len(tt.ads))
+	}
+}
+
+// Tests ticket validation.
+func TestTopicTableTicket(t *testing.T) {
+	var (
+		clock = new(mclock.Simulated)
+		tt    = newTopicTable(clock)
+		topic = Topic("les").hash()
+		src   = enode.ID{1}
+		ip    = net.IP{10, 0, 0, 1}
+		wait  = 5 * time.Second
+	)
+	ticket := tt.makeTicket(src, ip, topic, wait)
+
+	if remaining, err := tt.checkTicket(ticket, src, ip, topic); err != nil || remaining != wait {
+		t.Fatalf("wrong result for fresh ticket: %v, %v", remaining, err)
+	}
+	if _, err := tt.checkTicket(ticket, enode.ID{2}, ip, topic); err != errInvalidTicket {
+		t.Fatalf("ticket accepted for wrong node: %v", err)
+	}
+	if _, err := tt.checkTicket(ticket, src, net.IP{10, 0, 0, 2}, topic); err != errInvalidTicket {
+		t.Fatalf("ticket accepted for wrong IP: %v", err)
+	}
+	if _, err := tt.checkTicket(ticket, src, ip, Topic("archive").hash()); err != errInvalidTicket {
+		t.Fatalf("ticket accepted for wrong topic: %v", err)
+	}
+	forged := append([]byte{}, ticket...)
+	forged[len(forged)-1] ^= 1
+	if _, err := tt.checkTicket(forged, src, ip, topic); err != errInvalidTicket {
+		t.Fatalf("forged ticket accepted: %v", err)
+	}
+
+	clock.Run(wait)
+	if remaining, err := tt.checkTicket(ticket, src, ip, topic); err != nil || remaining != 0 {
+		t.Fatalf("wrong result for due ticket: %v, %v", remaining, err)
+	}
+	clock.Run(ticketValidity + time.Second)
+	if _, err := tt.checkTicket(ticket, src, ip, topic); err != errTicketExpired {
+		t.Fatalf("expired ticket accepted: %v", err)
+	}
+}
@@ -0,0 +1,1057 @@
+// Copyright 2019 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package discover
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	crand "crypto/rand"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/athofficial/go-ath/common/mclock"
+	"github.com/athofficial/go-ath/log"
+	"github.com/athofficial/go-ath/p2p/enode"
+	"github.com/athofficial/go-ath/p2p/enr"
+	"github.com/athofficial/go-ath/p2p/netutil"
+)
+
+const (
+	lookupRequestLimit      = 3  // max requests against a single node during lookup
+	findnodeResultLimit     = 16 // applies in FINDNODE handler
+	totalNodesResponseLimit = 5  // applies in waitForNodes
+	nodesResponseItemLimit  = 3  // applies in sendNodes
+	topicQueryResultLimit   = 16 // applies in TOPICQUERY handler
+
+	respTimeoutV5 = 700 * time.Millisecond
+)
+
+// Topic registration settings.
+const (
+	topicRegistrars       = 8                // number of nodes a topic is registered at
+	topicLookupInterval   = 10 * time.Minute // how often the registrar set is renewed
+	topicLookupRetry      = 10 * time.Second // retry interval if no registrars were found
+	topicRefreshMargin    = time.Minute      // ads are renewed this long before expiry
+	topicMaxRegisterWait  = adLifetime       // registrars with longer waits are given up
+	topicQueryParallelism = 3                // number of concurrent TOPICQUERY requests
+)
+
+var (
+	errChallengeNoCall = errors.New("no matching call")
+	errChallengeTwice  = errors.New("second handshake")
+	errLowPort         = errors.New("low port")
+)
+
+// UDPv5 implements the discovery v5 wire protocol, including topic advertisement.
+type UDPv5 struct {
+	// static fields
+	conn         conn
+	tab          *Table
+	netrestrict  *netutil.Netlist
+	priv         *ecdsa.PrivateKey
+	localNode    *enode.LocalNode
+	db           *enode.DB
+	clock        mclock.Clock
+	validSchemes enr.IdentityScheme
+	topics       *topicTable
+
+	// channels into dispatch
+	packetInCh    chan ReadPacket
+	readNextCh    chan struct{}
+	callCh        chan *callV5
+	callDoneCh    chan *callV5
+	respTimeoutCh chan *callTimeout
+
+	// state of dispatch
+	codec            *codecV5
+	activeCallByNode map[enode.ID]*callV5
+	activeCallByAuth map[[sizeofNonce]byte]*callV5
+	callQueue        map[enode.ID][]*callV5
+
+	// shutdown stuff
+	closeOnce sync.Once
+	closing   chan struct{}
+	wg        sync.WaitGroup
+}
+
+// callV5 represents a remote procedure call against another node.
+type callV5 struct {
+	node          *enode.Node
+	packet        packetV5
+	responseTypes []byte // expected packet types of responses
+	reqid         []byte
+	ch            chan packetV5 // responses sent here
+	err           chan error    // errors sent here
+
+	// Valid for active calls only:
+	nonce          [sizeofNonce]byte // nonce of request packet
+	handshakeCount int               // # times we attempted handshake for this call
+	challenge      *whoareyouV5      // last sent handshake challenge
+	timeout        *time.Timer
+}
+
+// callTimeout is the response timeout event of a call.
+type callTimeout struct {
+	c     *callV5
+	timer *time.Timer
+}
+
+// expects reports whether a response of the given type answers the call.
+func (c *callV5) expects(kind byte) bool {
+	return bytes.IndexByte(c.responseTypes, kind) >= 0
+}
+
+// ListenV5 listens on the given connection.
+func ListenV5(conn conn, ln *enode.LocalNode, cfg Config) (*UDPv5, error) {
+	t, err := newUDPv5(conn, ln, cfg)
+	if err != nil {
+		return nil, err
+	}
+	t.wg.Add(2)
+	go t.readLoop()
+	go t.dispatch()
+	return t, nil
+}
+
+// newUDPv5 creates a UDPv5 transport, but doesn't start any goroutines.
+func newUDPv5(conn conn, ln *enode.LocalNode, cfg Config) (*UDPv5, error) {
+	clock := mclock.Clock(mclock.System{})
+	t := &UDPv5{
+		// static fields
+		conn:         conn,
+		localNode:    ln,
+		db:           ln.Database(),
+		netrestrict:  cfg.NetRestrict,
+		priv:         cfg.PrivateKey,
+		clock:        clock,
+		validSchemes: enode.ValidSchemes,
+		topics:       newTopicTable(clock),
+		// channels into dispatch
+		packetInCh:    make(chan ReadPacket, 1),
+		readNextCh:    make(chan struct{}, 1),
+		callCh:        make(chan *callV5),
+		callDoneCh:    make(chan *callV5),
+		respTimeoutCh: make(chan *callTimeout),
+		// state of dispatch
+		codec:            newCodecV5(ln, cfg.PrivateKey, clock),
+		activeCallByNode: make(map[enode.ID]*callV5),
+		activeCallByAuth: make(map[[sizeofNonce]byte]*callV5),
+		callQueue:        make(map[enode.ID][]*callV5),
+		// shutdown
+		closing: make(chan struct{}),
+	}
+	tab, err := newTable(t, t.db, cfg.Bootnodes)
+	if err != nil {
+		return nil, err
+	}
+	t.tab = tab
+	return t, nil
+}
+
+// Self returns the local node record.
+func (t *UDPv5) Self() *enode.Node {
+	return t.localNode.Node()
+}
+
+// LocalNode returns the current local node running the protocol.
+func (t *UDPv5) LocalNode() *enode.LocalNode {
+	return t.localNode
+}
+
+// Close shuts down packet processing.
+func (t *UDPv5) Close() {
+	t.closeOnce.Do(func() {
+		close(t.closing)
+		t.conn.Close()
+		t.wg.Wait()
+		t.tab.Close()
+	})
+}
+
+// Ping sends a ping message to the given node.
+func (t *UDPv5) Ping(n *enode.Node) error {
+	_, err := t.pingSeq(n)
+	return err
+}
+
+// Resolve searches for a specific node with the given ID and tries to get the most recent
+// version of the node record for it. It returns n if the node could not be resolved.
+func (t *UDPv5) Resolve(n *enode.Node) *enode.Node {
+	if intable := t.tab.getNode(n.ID()); intable != nil && intable.Seq() > n.Seq() {
+		n = intable
+	}
+	// Try asking directly. This works if the node is still responding on the endpoint we have.
+	if resp, err := t.RequestENR(n); err == nil {
+		return resp
+	}
+	// Otherwise do a network lookup.
+	result := t.lookup(n.ID())
+	for _, rn := range result {
+		if rn.ID() == n.ID() && rn.Seq() > n.Seq() {
+			return rn
+		}
+	}
+	return n
+}
+
+// RequestENR requests n's record.
+func (t *UDPv5) RequestENR(n *enode.Node) (*enode.Node, error) {
+	nodes, err := t.findnodeDistances(n, []uint{0})
+	if err != nil {
+		return nil, err
+	}
+	if len(nodes) != 1 {
+		return nil, fmt.Errorf("%d nodes in response for distance zero", len(nodes))
+	}
+	return nodes[0], nil
+}
+
+// ReadRandomNodes reads random nodes from the local table.
+func (t *UDPv5) ReadRandomNodes(buf []*enode.Node) int {
+	return t.tab.ReadRandomNodes(buf)
+}
+
+// LookupRandom looks up a random target. This is needed to satisfy the transport
+// interface used by the dialer.
+func (t *UDPv5) LookupRandom() []*enode.Node {
+	var target enode.ID
+	crand.Read(target[:])
+	return t.lookup(target)
+}
+
+// lookup performs a recursive lookup for the given target. Unlike discovery v4, the
+// lookup also starts from nodes which weren't revalidated yet: every query performs a
+// handshake, so unresponsive nodes can't be abused for amplification.
+func (t *UDPv5) lookup(target enode.ID) []*enode.Node {
+	return unwrapNodes(t.tab.lookupFunc(target, true, false, func(n *node) ([]*node, error) {
+		return t.lookupWorker(n, target)
+	}))
+}
+
+// lookupWorker performs FINDNODE calls against a single node during lookup.
+func (t *UDPv5) lookupWorker(destNode *node, target enode.ID) ([]*node, error) {
+	var (
+		dists = lookupDistances(target, destNode.ID())
+		nodes = nodesByDistance{target: target}
+	)
+	r, err := t.findnodeDistances(unwrapNode(destNode), dists)
+	if err == errClosed {
+		return nil, err
+	}
+	for _, n := range r {
+		if n.ID() != t.Self().ID() {
+			nodes.push(wrapNode(n), findnodeResultLimit)
+		}
+	}
+	return nodes.entries, err
+}
+
+// lookupDistances computes the distance parameter for FINDNODE calls to dest.
+// It chooses distances adjacent to logdist(target, dest), e.g. for a target
+// with logdist(target, dest) = 255 the result is [255, 256, 254].
+func lookupDistances(target, dest enode.ID) (dists []uint) {
+	td := enode.LogDist(target, dest)
+	dists = append(dists, uint(td))
+	for i := 1; len(dists) < lookupRequestLimit; i++ {
+		if td+i <= 256 {
+			dists = append(dists, uint(td+i))
+		}
+		if td-i > 0 {
+			dists = append(dists, uint(td-i))
+		}
+	}
+	if len(dists) > lookupRequestLimit {
+		dists = dists[:lookupRequestLimit]
+	}
+	return dists
+}
+
+// The following methods implement the Table transport interface.
+
+func (t *UDPv5) self() *enode.Node {
+	return t.Self()
+}
+
+func (t *UDPv5) close() {
+	// The table is closed by Close.
+}
+
+func (t *UDPv5) ping(n *node) error {
+	_, err := t.pingSeq(unwrapNode(n))
+	return err
+}
+
+func (t *UDPv5) findnode(n *node, target encPubkey) ([]*node, error) {
+	return t.lookupWorker(n, target.id())
+}
+
+// pingSeq sends a PING to n and returns the sequence number of its record.
+func (t *UDPv5) pingSeq(n *enode.Node) (uint64, error) {
+	c := t.call(n, &pingV5{ENRSeq: t.localNode.Node().Seq()}, pongMsgV5)
+	defer t.callDone(c)
+
+	select {
+	case response := <-c.ch:
+		return response.(*pongV5).ENRSeq, nil
+	case err := <-c.err:
+		return 0, err
+	}
+}
+
+// findnodeDistances asks n for the nodes at the given distances from it.
+func (t *UDPv5) findnodeDistances(n *enode.Node, distances []uint) ([]*enode.Node, error) {
+	c := t.call(n, &findnodeV5{Distances: distances}, nodesMsgV5)
+	return t.waitForNodes(c, distances)
+}
+
+// waitForNodes waits for NODES responses to the given call.
+func (t *UDPv5) waitForNodes(c *callV5, distances []uint) ([]*enode.Node, error) {
+	defer t.callDone(c)
+
+	var (
+		nodes           []*enode.Node
+		seen            = make(map[enode.ID]struct{})
+		received, total = 0, -1
+	)
+	for {
+		select {
+		case responseP := <-c.ch:
+			response := responseP.(*nodesV5)
+			for _, record := range response.Nodes {
+				node, err := t.verifyResponseNode(c, record, distances, seen)
+				if err != nil {
+					log.Debug("Invalid record in "+response.name(), "id", c.node.ID(), "err", err)
+					continue
+				}
+				nodes = append(nodes, node)
+			}
+			if total == -1 {
+				total = min(int(response.Total), totalNodesResponseLimit)
+			}
+			if received++; received == total {
+				return nodes, nil
+			}
+		case err := <-c.err:
+			return nodes, err
+		}
+	}
+}
+
+// verifyResponseNode checks validity of a record in a NODES response. A nil distances
+// list disables the distance check, which is used for topic query results.
+func (t *UDPv5) verifyResponseNode(c *callV5, r *enr.Record, distances []uint, seen map[enode.ID]struct{}) (*enode.Node, error) {
+	node, err := enode.New(t.validSchemes, r)
+	if err != nil {
+		return nil, err
+	}
+	if err := netutil.CheckRelayIP(c.node.IP(), node.IP()); err != nil {
+		return nil, err
+	}
+	if t.netrestrict != nil && !t.netrestrict.Contains(node.IP()) {
+		return nil, errors.New("not contained in netrestrict whitelist")
+	}
+	if node.UDP() <= 1024 {
+		return nil, errLowPort
+	}
+	if distances != nil {
+		nd := enode.LogDist(c.node.ID(), node.ID())
+		if !containsUint(uint(nd), distances) {
+			return nil, errors.New("does not match any requested distance")
+		}
+	}
+	if _, ok := seen[node.ID()]; ok {
+		return nil, fmt.Errorf("duplicate record")
+	}
+	seen[node.ID()] = struct{}{}
+	return node, nil
+}
+
+func containsUint(x uint, xs []uint) bool {
+	for _, v := range xs {
+		if x == v {
+			return true
+		}
+	}
+	return false
+}
+
+// call sends the given call and sets up a handler for response packets (of the given
+// types). Calls to the same node are serialized.
+func (t *UDPv5) call(node *enode.Node, packet packetV5, responseTypes ...byte) *callV5 {
+	c := &callV5{
+		node:          node,
+		packet:        packet,
+		responseTypes: responseTypes,
+		reqid:         make([]byte, 8),
+		ch:            make(chan packetV5, 1),
+		err:           make(chan error, 1),
+	}
+	// Assign request ID.
+	crand.Read(c.reqid)
+	packet.setreqid(c.reqid)
+	// Send call to dispatch.
+	select {
+	case t.callCh <- c:
+	case <-t.closing:
+		c.err <- errClosed
+	}
+	return c
+}
+
+// callDone tells dispatch that the active call is done.
+func (t *UDPv5) callDone(c *callV5) {
+	// This needs a loop because further responses may be incoming until the
+	// send to callDoneCh has completed. Such responses need to be discarded
+	// in order to avoid blocking the dispatch loop.
+	for {
+		select {
+		case <-c.ch:
+			// late response, discard.
+		case <-c.err:
+			// late error, discard.
+		case t.callDoneCh <- c:
+			return
+		case <-t.closing:
+			return
+		}
+	}
+}
+
+// dispatch runs in its own goroutine, handles incoming packets and deals with calls.
+//
+// For any destination node there is at most one 'active call', stored in the t.activeCall*
+// maps. A call is made active when it is sent. The active call can be answered by a
+// matching response, in which case c.ch receives the response; or by timing out, in which case
+// c.err receives the error. When the function that created the call signals the active
+// call is done through callDone, the next call from the call queue is started.
+//
+// Calls may also be answered by a WHOAREYOU packet referencing the call packet's authTag.
+// When that happens the call is simply re-sent to complete the handshake. We allow one
+// handshake attempt per call.
+func (t *UDPv5) dispatch() {
+	defer t.wg.Done()
+
+	// Arm first read.
+	t.readNextCh <- struct{}{}
+
+	for {
+		select {
+		case c := <-t.callCh:
+			id := c.node.ID()
+			t.callQueue[id] = append(t.callQueue[id], c)
+			t.sendNextCall(id)
+
+		case ct := <-t.respTimeoutCh:
+			active := t.activeCallByNode[ct.c.node.ID()]
+			if ct.c == active && ct.timer == active.timeout {
+				ct.c.err <- errTimeout
+			}
+
+		case c := <-t.callDoneCh:
+			id := c.node.ID()
+			active := t.activeCallByNode[id]
+			if active != c {
+				panic("BUG: callDone for inactive call")
+			}
+			c.timeout.Stop()
+			delete(t.activeCallByAuth, c.nonce)
+			delete(t.activeCallByNode, id)
+			t.sendNextCall(id)
+
+		case p := <-t.packetInCh:
+			t.handlePacket(p.Data, p.Addr)
+			// Arm next read.
+			t.readNextCh <- struct{}{}
+
+		case <-t.closing:
+			close(t.readNextCh)
+			for id, queue := range t.callQueue {
+				for _, c := range queue {
+					c.err <- errClosed
+				}
+				delete(t.callQueue, id)
+			}
+			for id, c := range t.activeCallByNode {
+				c.err <- errClosed
+				delete(t.activeCallByNode, id)
+				delete(t.activeCallByAuth, c.nonce)
+			}
+			return
+		}
+	}
+}
+
+// startResponseTimeout sets the response timer for a call.
+func (t *UDPv5) startResponseTimeout(c *callV5) {
+	if c.timeout != nil {
+		c.timeout.Stop()
+	}
+	ct := &callTimeout{c: c}
+	ct.timer = time.AfterFunc(respTimeoutV5, func() {
+		select {
+		case t.respTimeoutCh <- ct:
+		case <-t.closing:
+		}
+	})
+	c.timeout = ct.timer
+}
+
+// sendNextCall sends the next call in the call queue if there is no active call.
+func (t *UDPv5) sendNextCall(id enode.ID) {
+	queue := t.callQueue[id]
+	if len(queue) == 0 || t.activeCallByNode[id] != nil {
+		return
+	}
+	t.activeCallByNode[id] = queue[0]
+	t.sendCall(t.activeCallByNode[id])
+	if len(queue) == 1 {
+		delete(t.callQueue, id)
+	} else {
+		copy(queue, queue[1:])
+		t.callQueue[id] = queue[:len(queue)-1]
+	}
+}
+
+// sendCall encodes and sends a request packet to the call's recipient node.
+// This performs a handshake if needed.
+func (t *UDPv5) sendCall(c *callV5) {
+	// The call might have a nonce from a previous handshake attempt. Remove the entry for
+	// the old nonce because we're about to generate a new nonce for this call.
+	if c.nonce != ([sizeofNonce]byte{}) {
+		delete(t.activeCallByAuth, c.nonce)
+	}
+
+	addr := &net.UDPAddr{IP: c.node.IP(), Port: c.node.UDP()}
+	newNonce, _ := t.send(c.node.ID(), addr, c.packet, c.challenge)
+	c.nonce = newNonce
+	t.activeCallByAuth[newNonce] = c
+	t.startResponseTimeout(c)
+}
+
+// sendResponse sends a response packet to the given node.
+// This doesn't trigger a handshake even if no keys are available.
+func (t *UDPv5) sendResponse(toID enode.ID, toAddr *net.UDPAddr, packet packetV5) error {
+	_, err := t.send(toID, toAddr, packet, nil)
+	return err
+}
+
+// send sends a packet to the given node.
+func (t *UDPv5) send(toID enode.ID, toAddr *net.UDPAddr, packet packetV5, c *whoareyouV5) ([sizeofNonce]byte, error) {
+	addr := toAddr.String()
+	enc, nonce, err := t.codec.encode(toID, addr, packet, c)
+	if err != nil {
+		log.Warn(">> "+packet.name(), "id", toID, "addr", addr, "err", err)
+		return nonce, err
+	}
+	_, err = t.conn.WriteToUDP(enc, toAddr)
+	log.Trace(">> "+packet.name(), "id", toID, "addr", addr)
+	return nonce, err
+}
+
+// readLoop runs in its own goroutine and reads packets from the network.
+func (t *UDPv5) readLoop() {
+	defer t.wg.Done()
+
+	buf := make([]byte, maxPacketSizeV5)
+	for range t.readNextCh {
+		for {
+			nbytes, from, err := t.conn.ReadFromUDP(buf)
+			if netutil.IsTemporaryError(err) {
+				// Ignore temporary read errors.
+				log.Debug("Temporary UDP read error", "err", err)
+				continue
+			} else if err != nil {
+				// Shut down the loop for permament errors.
+				if err != io.EOF {
+					log.Debug("UDP read error", "err", err)
+				}
+				return
+			}
+			t.dispatchReadPacket(from, buf[:nbytes])
+			break
+		}
+	}
+}
+
+// dispatchReadPacket sends a packet into the dispatch loop.
+func (t *UDPv5) dispatchReadPacket(from *net.UDPAddr, content []byte) bool {
+	select {
+	case t.packetInCh <- ReadPacket{content, from}:
+		return true
+	case <-t.closing:
+		return false
+	}
+}
+
+// handlePacket decodes and processes an incoming packet from the network.
+func (t *UDPv5) handlePacket(rawpacket []byte, fromAddr *net.UDPAddr) error {
+	addr := fromAddr.String()
+	fromID, fromNode, packet, err := t.codec.decode(rawpacket, addr)
+	if err != nil {
+		log.Debug("Bad discv5 packet", "id", fromID, "addr", addr, "err", err)
+		return err
+	}
+	if fromNode != nil {
+		// Handshake succeeded, add to table.
+		t.tab.addSeenNode(wrapNode(fromNode))
+	}
+	if packet.kind() != whoareyouPacketV5 {
+		// WHOAREYOU logged separately to report errors.
+		log.Trace("<< "+packet.name(), "id", fromID, "addr", addr)
+	}
+	t.handle(packet, fromID, fromAddr)
+	return nil
+}
+
+// handleCallResponse dispatches a response packet to the call waiting for it.
+func (t *UDPv5) handleCallResponse(fromID enode.ID, fromAddr *net.UDPAddr, p packetV5) bool {
+	ac := t.activeCallByNode[fromID]
+	if ac == nil || !bytes.Equal(p.reqid(), ac.reqid) {
+		log.Debug(fmt.Sprintf("Unsolicited/late %s response", p.name()), "id", fromID, "addr", fromAddr)
+		return false
+	}
+	if !fromAddr.IP.Equal(ac.node.IP()) || fromAddr.Port != ac.node.UDP() {
+		log.Debug(fmt.Sprintf("%s from wrong endpoint", p.name()), "id", fromID, "addr", fromAddr)
+		return false
+	}
+	if !ac.expects(p.kind()) {
+		log.Debug(fmt.Sprintf("Wrong discv5 response type %s", p.name()), "id", fromID, "addr", fromAddr)
+		return false
+	}
+	t.startResponseTimeout(ac)
+	ac.ch <- p
+	return true
+}
+
+// getNode looks for a node record in table and database.
+func (t *UDPv5) getNode(id enode.ID) *enode.Node {
+	if n := t.tab.getNode(id); n != nil {
+		return n
+	}
+	if n := t.localNode.Database().Node(id); n != nil {
+		return n
+	}
+	return nil
+}
+
+// handle processes incoming packets according to their message type.
+func (t *UDPv5) handle(p packetV5, fromID enode.ID, fromAddr *net.UDPAddr) {
+	switch p := p.(type) {
+	case *unknownV5:
+		t.handleUnknown(p, fromID, fromAddr)
+	case *whoareyouV5:
+		t.handleWhoareyou(p, fromID, fromAddr)
+	case *pingV5:
+		t.handlePing(p, fromID, fromAddr)
+	case *pongV5:
+		if t.handleCallResponse(fromID, fromAddr, p) {
+			toAddr := &net.UDPAddr{IP: p.ToIP, Port: int(p.ToPort)}
+			t.localNode.UDPEndpointStatement(fromAddr, toAddr)
+		}
+	case *findnodeV5:
+		t.handleFindnode(p, fromID, fromAddr)
+	case *talkreqV5:
+		// There are no application protocols running on top of discovery,
+		// respond with an empty message as the specification demands.
+		t.sendResponse(fromID, fromAddr, &talkrespV5{ReqID: p.ReqID})
+	case *regtopicV5:
+		t.handleRegtopic(p, fromID, fromAddr)
+	case *topicqueryV5:
+		t.handleTopicQuery(p, fromID, fromAddr)
+	case *nodesV5, *talkrespV5, *ticketV5, *regconfirmationV5:
+		t.handleCallResponse(fromID, fromAddr, p)
+	}
+}
+
+// handleUnknown initiates a handshake by responding with WHOAREYOU.
+func (t *UDPv5) handleUnknown(p *unknownV5, fromID enode.ID, fromAddr *net.UDPAddr) {
+	challenge := &whoareyouV5{Nonce: p.Nonce}
+	crand.Read(challenge.IDNonce[:])
+	if n := t.getNode(fromID); n != nil {
+		challenge.Node = n
+		challenge.RecordSeq = n.Seq()
+	}
+	t.sendResponse(fromID, fromAddr, challenge)
+}
+
+// handleWhoareyou resends the active call as a handshake packet.
+func (t *UDPv5) handleWhoareyou(p *whoareyouV5, fromID enode.ID, fromAddr *net.UDPAddr) {
+	c, err := t.matchWithCall(fromAddr, p.Nonce)
+	if err != nil {
+		log.Debug("Invalid "+p.name(), "addr", fromAddr, "err", err)
+		return
+	}
+
+	// Resend the call that was answered by WHOAREYOU.
+	log.Trace("<< "+p.name(), "id", c.node.ID(), "addr", fromAddr)
+	c.handshakeCount++
+	c.challenge = p
+	p.Node = c.node
+	t.sendCall(c)
+}
+
+// matchWithCall checks whether a handshake attempt matches the active call.
+func (t *UDPv5) matchWithCall(fromAddr *net.UDPAddr, nonce [sizeofNonce]byte) (*callV5, error) {
+	c := t.activeCallByAuth[nonce]
+	if c == nil {
+		return nil, errChallengeNoCall
+	}
+	if !fromAddr.IP.Equal(c.node.IP()) || fromAddr.Port != c.node.UDP() {
+		return nil, errChallengeNoCall
+	}
+	if c.handshakeCount > 0 {
+		return nil, errChallengeTwice
+	}
+	return c, nil
+}
+
+// handlePing sends a PONG response.
+func (t *UDPv5) handlePing(p *pingV5, fromID enode.ID, fromAddr *net.UDPAddr) {
+	t.sendResponse(fromID, fromAddr, &pongV5{
+		ReqID:  p.ReqID,
+		ToIP:   fromAddr.IP,
+		ToPort: uint16(fromAddr.Port),
+		ENRSeq: t.localNode.Node().Seq(),
+	})
+}
+
+// handleFindnode returns nodes to the requester.
+func (t *UDPv5) handleFindnode(p *findnodeV5, fromID enode.ID, fromAddr *net.UDPAddr) {
+	var (
+		nodes []*enode.Node
+		seen  = make(map[uint]bool)
+	)
+	for _, dist := range p.Distances {
+		if dist > 256 || seen[dist] {
+			continue
+		}
+		seen[dist] = true
+		nodes = t.tab.appendNodesAtDistance(int(dist), nodes)
+	}
+	nodes = t.filterResponseNodes(fromAddr, nodes, findnodeResultLimit)
+	t.sendNodes(fromID, fromAddr, p.ReqID, nodes)
+}
+
+// filterResponseNodes removes nodes which can't be relayed to the requester. Only nodes
+// with a signed record can be sent, discovery v4 nodes are skipped.
+func (t *UDPv5) filterResponseNodes(fromAddr *net.UDPAddr, nodes []*enode.Node, max int) []*enode.Node {
+	result := nodes[:0]
+	for _, n := range nodes {
+		if len(result) >= max {
+			break
+		}
+		if n.Record().IdentityScheme() != "v4" {
+			continue
+		}
+		if netutil.CheckRelayIP(fromAddr.IP, n.IP()) != nil {
+			continue
+		}
+		result = append(result, n)
+	}
+	return result
+}
+
+// sendNodes sends the given records in one or more NODES packets.
+func (t *UDPv5) sendNodes(toID enode.ID, toAddr *net.UDPAddr, reqid []byte, nodes []*enode.Node) {
+	total := uint8(math.Ceil(float64(len(nodes)) / nodesResponseItemLimit))
+	if total == 0 {
+		total = 1
+	}
+	resp := &nodesV5{ReqID: reqid, Total: total}
+	sendResp := func() {
+		t.sendResponse(toID, toAddr, resp)
+		resp.Nodes = resp.Nodes[:0]
+	}
+	for _, node := range nodes {
+		resp.Nodes = append(resp.Nodes, node.Record())
+		if len(resp.Nodes) == nodesResponseItemLimit {
+			sendResp()
+		}
+	}
+	if len(resp.Nodes) > 0 || len(nodes) == 0 {
+		sendResp()
+	}
+}
+
+// handleRegtopic places a topic advertisement or hands out a ticket if the registrant has
+// to wait for space in the topic table.
+func (t *UDPv5) handleRegtopic(p *regtopicV5, fromID enode.ID, fromAddr *net.UDPAddr) {
+	if p.ENR == nil {
+		log.Debug("Missing record in "+p.name(), "id", fromID, "addr", fromAddr)
+		return
+	}
+	node, err := enode.New(t.validSchemes, p.ENR)
+	if err != nil {
+		log.Debug("Invalid record in "+p.name(), "id", fromID, "addr", fromAddr, "err", err)
+		return
+	}
+	if node.ID() != fromID || !node.IP().Equal(fromAddr.IP) {
+		log.Debug("Mismatching record in "+p.name(), "id", fromID, "addr", fromAddr)
+		return
+	}
+	// Registrants holding a ticket must wait until it becomes valid.
+	if len(p.Ticket) > 0 {
+		wait, err := t.topics.checkTicket(p.Ticket, fromID, fromAddr.IP, p.Topic)
+		switch {
+		case err != nil:
+			log.Debug("Ignoring ticket in "+p.name(), "id", fromID, "addr", fromAddr, "err", err)
+		case wait > 0:
+			t.sendResponse(fromID, fromAddr, &ticketV5{ReqID: p.ReqID, Ticket: p.Ticket, WaitTime: waitSeconds(wait)})
+			return
+		}
+	}
+	wait := t.topics.register(p.Topic, node)
+	if wait > 0 {
+		ticket := t.topics.makeTicket(fromID, fromAddr.IP, p.Topic, wait)
+		t.sendResponse(fromID, fromAddr, &ticketV5{ReqID: p.ReqID, Ticket: ticket, WaitTime: waitSeconds(wait)})
+		return
+	}
+	t.sendResponse(fromID, fromAddr, &ticketV5{ReqID: p.ReqID})
+	t.sendResponse(fromID, fromAddr, &regconfirmationV5{ReqID: p.ReqID, Topic: p.Topic})
+}
+
+// waitSeconds converts a ticket wait time to whole seconds, rounding up.
+func waitSeconds(d time.Duration) uint {
+	return uint((d + time.Second - 1) / time.Second)
+}
+
+// handleTopicQuery returns the advertisers of a topic to the requester.
+func (t *UDPv5) handleTopicQuery(p *topicqueryV5, fromID enode.ID, fromAddr *net.UDPAddr) {
+	nodes := t.topics.nodes(p.Topic, topicQueryResultLimit)
+	nodes = t.filterResponseNodes(fromAddr, nodes, topicQueryResultLimit)
+	t.sendNodes(fromID, fromAddr, p.ReqID, nodes)
+}
+
+// regtopic sends a REGTOPIC request to n. It returns the time to wait before the next
+// attempt and the ticket to present on it. If the advertisement was placed, confirmed
+// is true.
+func (t *UDPv5) regtopic(n *enode.Node, topic enode.ID, ticket []byte) (wait time.Duration, newTicket []byte, confirmed bool, err error) {
+	req := &regtopicV5{Topic: topic, ENR: t.localNode.Node().Record(), Ticket: ticket}
+	c := t.call(n, req, ticketMsgV5, regconfirmationMsgV5)
+	defer t.callDone(c)
+
+	for {
+		select {
+		case response := <-c.ch:
+			switch response := response.(type) {
+			case *ticketV5:
+				if response.WaitTime > 0 {
+					return time.Duration(response.WaitTime) * time.Second, response.Ticket, false, nil
+				}
+				// A zero wait time means the ad was placed, wait for the confirmation.
+			case *regconfirmationV5:
+				return 0, nil, true, nil
+			}
+		case err := <-c.err:
+			return 0, nil, false, err
+		}
+	}
+}
+
+// topicQuery asks n for advertisers of the topic.
+func (t *UDPv5) topicQuery(n *enode.Node, topic enode.ID) ([]*enode.Node, error) {
+	c := t.call(n, &topicqueryV5{Topic: topic}, nodesMsgV5)
+	return t.waitForNodes(c, nil)
+}
+
+// topicRegistrars looks up the nodes closest to the topic hash, which are the nodes
+// the topic is registered at and queried from.
+func (t *UDPv5) topicRegistrars(topic enode.ID) []*enode.Node {
+	var nodes []*enode.Node
+	for _, n := range t.lookup(topic) {
+		if n.ID() != t.Self().ID() {
+			nodes = append(nodes, n)
+		}
+	}
+	return nodes
+}
+
+// RegisterTopic advertises the local node under the given topic until stop is closed.
+// Advertisements are placed at the nodes closest to the topic hash and renewed
+// before they expire.
+func (t *UDPv5) RegisterTopic(topic Topic, stop <-chan struct{}) {
+	var (
+		th     = topic.hash()
+		quit   = make(chan struct{})
+		active = make(map[enode.ID]bool)
+		done   = make(chan enode.ID)
+		lookup = time.NewTimer(0)
+	)
+	defer func() {
+		lookup.Stop()
+		close(quit)
+		for len(active) > 0 {
+			delete(active, <-done)
+		}
+	}()
+
+	for {
+		select {
+		case <-lookup.C:
+			for _, n := range t.topicRegistrars(th) {
+				if len(active) >= topicRegistrars {
+					break
+				}
+				if active[n.ID()] {
+					continue
+				}
+				active[n.ID()] = true
+				go func(n *enode.Node) {
+					t.registerAt(n, topic, quit)
+					done <- n.ID()
+				}(n)
+			}
+			if len(active) == 0 {
+				lookup.Reset(topicLookupRetry)
+			} else {
+				lookup.Reset(topicLookupInterval)
+			}
+		case id := <-done:
+			delete(active, id)
+		case <-stop:
+			return
+		case <-t.closing:
+			return
+		}
+	}
+}
+
+// registerAt keeps the topic registered at a single registrar until quit is closed or
+// the registrar stops accepting the registration.
+func (t *UDPv5) registerAt(n *enode.Node, topic Topic, quit <-chan struct{}) {
+	var ticket []byte
+	for {
+		wait, newTicket, confirmed, err := t.regtopic(n, topic.hash(), ticket)
+		if err != nil {
+			log.Debug("Topic registration failed", "topic", topic, "id", n.ID(), "err", err)
+			return
+		}
+		if confirmed {
+			log.Trace("Topic registered", "topic", topic, "id", n.ID())
+			wait = adLifetime - topicRefreshMargin
+		}
+		if wait > topicMaxRegisterWait {
+			log.Debug("Topic registrar busy", "topic", topic, "id", n.ID(), "wait", wait)
+			return
+		}
+		ticket = newTicket
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-timer.C:
+		case <-quit:
+			timer.Stop()
+			return
+		case <-t.closing:
+			timer.Stop()
+			return
+		}
+	}
+}
+
+// TopicQuery looks up nodes advertising the given topic.
+func (t *UDPv5) TopicQuery(topic Topic) []*enode.Node {
+	var (
+		th         = topic.hash()
+		registrars = t.topicRegistrars(th)
+		results    = make(chan []*enode.Node, len(registrars))
+		limit      = make(chan struct{}, topicQueryParallelism)
+	)
+	for _, n := range registrars {
+		go func(n *enode.Node) {
+			limit <- struct{}{}
+			nodes, err := t.topicQuery(n, th)
+			if err != nil {
+				log.Trace("Topic query failed", "topic", topic, "id", n.ID(), "err", err)
+			}
+			<-limit
+			results <- nodes
+		}(n)
+	}
+	var (
+		found []*enode.Node
+		seen  = make(map[enode.ID]bool)
+	)
+	for range registrars {
+		for _, n := range <-results {
+			if !seen[n.ID()] {
+				seen[n.ID()] = true
+				found = append(found, n)
+			}
+		}
+	}
+	return found
+}
+
+// SearchTopic repeatedly looks up nodes advertising the topic and sends them on found.
+// The search interval is set through setPeriod; closing setPeriod or sending a zero
+// interval stops the search. After every round, lookup receives whether any nodes were
+// found.
+func (t *UDPv5) SearchTopic(topic Topic, setPeriod <-chan time.Duration, found chan<- *enode.Node, lookup chan<- bool) {
+	var (
+		period time.Duration
+		timer  = time.NewTimer(0)
+	)
+	<-timer.C
+	defer timer.Stop()
+
+	for {
+		select {
+		case p, ok := <-setPeriod:
+			if !ok || p == 0 {
+				return
+			}
+			if period == 0 {
+				timer.Reset(0)
+			}
+			period = p
+
+		case <-timer.C:
+			nodes := t.TopicQuery(topic)
+			for _, n := range nodes {
+				select {
+				case found <- n:
+				case <-t.closing:
+					return
+				}
+			}
+			if lookup != nil {
+				select {
+				case lookup <- len(nodes) > 0:
+				case <-t.closing:
+					return
+				}
+			}
+			timer.Reset(period)
+
+		case <-t.closing:
+			return
+		}
+	}
+}
+
+func min(x, y int) int {
+	if x < y {
+		return x
+	}
+	return y
+}
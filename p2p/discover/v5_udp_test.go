@@ -0,0 +1,206 @@
+// Copyright 2019 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package discover
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/athofficial/go-ath/p2p/enode"
+)
+
+// startLocalhostV5 starts a discovery v5 node listening on localhost.
+func startLocalhostV5(t *testing.T, bootnodes ...*enode.Node) *UDPv5 {
+	t.Helper()
+	key := newkey()
+	ln := enode.NewLocalNode(newTestDB(t), key)
+	socket, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IP{127, 0, 0, 1}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	realaddr := socket.LocalAddr().(*net.UDPAddr)
+	ln.SetStaticIP(realaddr.IP)
+	ln.SetFallbackUDP(realaddr.Port)
+
+	udp, err := ListenV5(socket, ln, Config{PrivateKey: key, Bootnodes: bootnodes})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return udp
+}
+
+// startLocalhostNetV5 starts a bootnode and n nodes bootstrapping from it.
+func startLocalhostNetV5(t *testing.T, n int) []*UDPv5 {
+	t.Helper()
+	nodes := []*UDPv5{startLocalhostV5(t)}
+	for i := 0; i < n; i++ {
+		nodes = append(nodes, startLocalhostV5(t, nodes[0].Self()))
+	}
+	return nodes
+}
+
+// selfLookups makes the nodes known to their neighbours.
+func selfLookups(nodes []*UDPv5) {
+	for _, n := range nodes {
+		n.lookup(n.Self().ID())
+	}
+}
+
+func closeAllV5(nodes []*UDPv5) {
+	for _, n := range nodes {
+		n.Close()
+	}
+}
+
+// Tests that nodes complete the handshake and learn about each other through PING.
+func TestUDPv5_pingHandshake(t *testing.T) {
+	t.Parallel()
+	a, b := startLocalhostV5(t), startLocalhostV5(t)
+	defer a.Close()
+	defer b.Close()
+
+	if err := a.Ping(b.Self()); err != nil {
+		t.Fatal("ping failed:", err)
+	}
+	// The handshake adds the initiator to the recipient's table.
+	if n := b.tab.getNode(a.Self().ID()); n == nil {
+		t.Fatal("initiator not in recipient's table")
+	}
+	// A second ping reuses the session.
+	if err := a.Ping(b.Self()); err != nil {
+		t.Fatal("second ping failed:", err)
+	}
+}
+
+// Tests that calls to unresponsive nodes time out.
+func TestUDPv5_pingTimeout(t *testing.T) {
+	t.Parallel()
+	a := startLocalhostV5(t)
+	defer a.Close()
+
+	dead := enode.NewV4(&newkey().PublicKey, net.IP{127, 0, 0, 1}, 0, 9)
+	if err := a.Ping(dead); err != errTimeout {
+		t.Fatalf("wrong error: %v", err)
+	}
+}
+
+// Tests that the current node record can be requested.
+func TestUDPv5_requestENR(t *testing.T) {
+	t.Parallel()
+	a, b := startLocalhostV5(t), startLocalhostV5(t)
+	defer a.Close()
+	defer b.Close()
+
+	b.LocalNode().Set(enrTestEntry("value"))
+	n, err := a.RequestENR(b.Self())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n.Seq() != b.Self().Seq() {
+		t.Fatalf("wrong record seq: have %d, want %d", n.Seq(), b.Self().Seq())
+	}
+	var entry enrTestEntry
+	if err := n.Load(&entry); err != nil || entry != "value" {
+		t.Fatalf("wrong record entry %q, err %v", entry, err)
+	}
+}
+
+// Tests that nodes joining through a bootnode can find each other.
+func TestUDPv5_lookup(t *testing.T) {
+	t.Parallel()
+	nodes := startLocalhostNetV5(t, 8)
+	defer closeAllV5(nodes)
+
+	selfLookups(nodes[1:])
+	target := nodes[len(nodes)-1].Self()
+	for _, n := range nodes[1 : len(nodes)-1] {
+		found := false
+		for _, rn := range n.lookup(target.ID()) {
+			if rn.ID() == target.ID() {
+				found = true
+			}
+		}
+		if !found {
+			t.Errorf("node %v did not find the target", n.Self().ID())
+		}
+	}
+	if resolved := nodes[1].Resolve(target); resolved.Seq() != target.Seq() || resolved.ID() != target.ID() {
+		t.Errorf("wrong resolve result: %v", resolved)
+	}
+}
+
+// Tests topic registration and search.
+func TestUDPv5_topics(t *testing.T) {
+	t.Parallel()
+	nodes := startLocalhostNetV5(t, 6)
+	defer closeAllV5(nodes)
+
+	selfLookups(nodes[1:])
+	stop := make(chan struct{})
+	defer close(stop)
+	go nodes[1].RegisterTopic("les", stop)
+	go nodes[2].RegisterTopic("les", stop)
+	go nodes[3].RegisterTopic("archive", stop)
+
+	expect := func(topic Topic, want ...*UDPv5) {
+		t.Helper()
+		deadline := time.Now().Add(5 * time.Second)
+		for {
+			found := make(map[enode.ID]bool)
+			for _, n := range nodes[6].TopicQuery(topic) {
+				found[n.ID()] = true
+			}
+			complete := len(found) == len(want)
+			for _, n := range want {
+				complete = complete && found[n.Self().ID()]
+			}
+			if complete {
+				return
+			}
+			if time.Now().After(deadline) {
+				t.Fatalf("topic %q: found %v", topic, found)
+			}
+			time.Sleep(100 * time.Millisecond)
+		}
+	}
+	expect("les", nodes[1], nodes[2])
+	expect("archive", nodes[3])
+	expect("unknown")
+
+	// SearchTopic reports the advertisers.
+	var (
+		setPeriod = make(chan time.Duration, 1)
+		found     = make(chan *enode.Node, 10)
+		lookup    = make(chan bool, 10)
+	)
+	setPeriod <- 100 * time.Millisecond
+	go nodes[5].SearchTopic("archive", setPeriod, found, lookup)
+	defer close(setPeriod)
+	select {
+	case n := <-found:
+		if n.ID() != nodes[3].Self().ID() {
+			t.Fatalf("wrong node found: %v", n.ID())
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no node found")
+	}
+}
+
+type enrTestEntry string
+
+func (enrTestEntry) ENRKey() string { return "testentry" }
@@ -0,0 +1,251 @@
+// Copyright 2019 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package dnsdisc
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/athofficial/go-ath/common/mclock"
+	"github.com/athofficial/go-ath/log"
+	"github.com/athofficial/go-ath/p2p/enode"
+	"github.com/athofficial/go-ath/p2p/enr"
+	lru "github.com/hashicorp/golang-lru"
+)
+
+// Client discovers nodes by querying DNS servers.
+type Client struct {
+	cfg     Config
+	clock   mclock.Clock
+	entries *lru.Cache
+
+	lock  sync.Mutex
+	trees map[string]*clientTree // Trees being followed, keyed by their enrtree:// URL
+	rand  *rand.Rand
+}
+
+// Config holds configuration options for the client.
+type Config struct {
+	Timeout         time.Duration      // timeout used for DNS lookups (default 5s)
+	RecheckInterval time.Duration      // time between tree root update checks (default 30min)
+	CacheLimit      int                // maximum number of cached records (default 1000)
+	ValidSchemes    enr.IdentityScheme // acceptable ENR identity schemes (default enode.ValidSchemes)
+	Resolver        Resolver           // the DNS resolver to use (defaults to system DNS)
+	Logger          log.Logger         // destination of client log messages (defaults to root logger)
+}
+
+// Resolver is a DNS resolver that can query TXT records.
+type Resolver interface {
+	LookupTXT(ctx context.Context, domain string) ([]string, error)
+}
+
+func (cfg Config) withDefaults() Config {
+	const (
+		defaultTimeout = 5 * time.Second
+		defaultRecheck = 30 * time.Minute
+		defaultCache   = 1000
+	)
+	if cfg.Timeout == 0 {
+		cfg.Timeout = defaultTimeout
+	}
+	if cfg.RecheckInterval == 0 {
+		cfg.RecheckInterval = defaultRecheck
+	}
+	if cfg.CacheLimit == 0 {
+		cfg.CacheLimit = defaultCache
+	}
+	if cfg.ValidSchemes == nil {
+		cfg.ValidSchemes = enode.ValidSchemes
+	}
+	if cfg.Resolver == nil {
+		cfg.Resolver = new(net.Resolver)
+	}
+	if cfg.Logger == nil {
+		cfg.Logger = log.Root()
+	}
+	return cfg
+}
+
+// NewClient creates a client that follows the trees at the given enrtree:// URLs.
+func NewClient(cfg Config, urls ...string) (*Client, error) {
+	c := &Client{
+		cfg:   cfg.withDefaults(),
+		clock: mclock.System{},
+		trees: make(map[string]*clientTree),
+		rand:  rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+	var err error
+	if c.entries, err = lru.New(c.cfg.CacheLimit); err != nil {
+		return nil, err
+	}
+	for _, url := range urls {
+		if err := c.AddTree(url); err != nil {
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+// AddTree adds an enrtree:// URL to the set of followed trees.
+func (c *Client) AddTree(url string) error {
+	le, err := parseLink(url)
+	if err != nil {
+		return fmt.Errorf("invalid enrtree URL: %v", err)
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if _, ok := c.trees[le.url()]; !ok {
+		c.trees[le.url()] = newClientTree(c, le)
+	}
+	return nil
+}
+
+// SyncTree downloads the entire node tree at the given URL. This doesn't add the
+// tree for later use, but any previously-synced entries are reused.
+func (c *Client) SyncTree(url string) (*Tree, error) {
+	le, err := parseLink(url)
+	if err != nil {
+		return nil, fmt.Errorf("invalid enrtree URL: %v", err)
+	}
+	ct := newClientTree(c, le)
+	t := &Tree{entries: make(map[string]entry)}
+	if err := ct.syncAll(t.entries); err != nil {
+		return nil, err
+	}
+	t.root = ct.root
+	return t, nil
+}
+
+// RandomNodes retrieves up to n random nodes from the followed trees, crawling
+// linked lists as they are discovered. Lookup failures are logged and skipped,
+// so the result may contain fewer nodes (or duplicates) if the trees are small
+// or unreachable.
+func (c *Client) RandomNodes(n int) []*enode.Node {
+	var nodes []*enode.Node
+	for attempts := 0; len(nodes) < n && attempts < 2*n; attempts++ {
+		ct := c.randomTree()
+		if ct == nil {
+			break
+		}
+		node, err := ct.randomNode()
+		if err != nil {
+			c.cfg.Logger.Debug("Error in DNS random node sync", "tree", ct.loc.domain, "err", err)
+			continue
+		}
+		if node != nil {
+			nodes = append(nodes, node)
+		}
+	}
+	return nodes
+}
+
+// randomTree returns a random tree among the followed ones.
+func (c *Client) randomTree() *clientTree {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if len(c.trees) == 0 {
+		return nil
+	}
+	i, pick := 0, c.rand.Intn(len(c.trees))
+	for _, ct := range c.trees {
+		if i == pick {
+			return ct
+		}
+		i++
+	}
+	return nil
+}
+
+// followLinks adds the given trees to the set of followed ones.
+func (c *Client) followLinks(links []*linkEntry) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	for _, le := range links {
+		if _, ok := c.trees[le.url()]; !ok {
+			c.cfg.Logger.Debug("Following linked DNS tree", "url", le.url())
+			c.trees[le.url()] = newClientTree(c, le)
+		}
+	}
+}
+
+// resolveRoot retrieves a root entry via DNS.
+func (c *Client) resolveRoot(ctx context.Context, loc *linkEntry) (rootEntry, error) {
+	txts, err := c.cfg.Resolver.LookupTXT(ctx, loc.domain)
+	c.cfg.Logger.Trace("Updating DNS discovery root", "tree", loc.domain, "err", err)
+	if err != nil {
+		return rootEntry{}, err
+	}
+	for _, txt := range txts {
+		if strings.HasPrefix(txt, rootPrefix) {
+			root, err := parseRoot(txt)
+			if err != nil {
+				return rootEntry{}, nameError{loc.domain, err}
+			}
+			if !root.verifySignature(loc.pubkey) {
+				return rootEntry{}, nameError{loc.domain, entryError{"root", errInvalidSig}}
+			}
+			return root, nil
+		}
+	}
+	return rootEntry{}, nameError{loc.domain, errNoRoot}
+}
+
+// resolveEntry retrieves an entry from the cache or fetches it from the network
+// if it isn't cached.
+func (c *Client) resolveEntry(ctx context.Context, domain, hash string) (entry, error) {
+	if e, ok := c.entries.Get(hash); ok {
+		return e.(entry), nil
+	}
+	e, err := c.doResolveEntry(ctx, domain, hash)
+	if err != nil {
+		return nil, err
+	}
+	c.entries.Add(hash, e)
+	return e, nil
+}
+
+// doResolveEntry fetches an entry via DNS.
+func (c *Client) doResolveEntry(ctx context.Context, domain, hash string) (entry, error) {
+	name := hash + "." + domain
+	txts, err := c.cfg.Resolver.LookupTXT(ctx, name)
+	c.cfg.Logger.Trace("DNS discovery lookup", "name", name, "err", err)
+	if err != nil {
+		return nil, err
+	}
+	for _, txt := range txts {
+		e, err := parseEntry(txt, c.cfg.ValidSchemes)
+		if err == errUnknownEntry {
+			continue
+		}
+		if err != nil {
+			return nil, nameError{name, err}
+		}
+		if subdomain(e) != hash {
+			return nil, nameError{name, errHashMismatch}
+		}
+		return e, nil
+	}
+	return nil, nameError{name, errNoEntry}
+}
@@ -0,0 +1,270 @@
+// Copyright 2019 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package dnsdisc
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"net"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/athofficial/go-ath/common/mclock"
+	"github.com/athofficial/go-ath/crypto"
+	"github.com/athofficial/go-ath/p2p/enode"
+	"github.com/athofficial/go-ath/p2p/enr"
+)
+
+const (
+	signingKeySeed = 0x111111
+	nodesSeed1     = 0x2945237
+	nodesSeed2     = 0x4567299
+)
+
+func TestClientSyncTree(t *testing.T) {
+	nodes := testNodes(nodesSeed1, 30)
+	tree, url := makeTestTree("n", nodes, nil)
+	r := mapResolver(tree.ToTXT("n"))
+
+	c, _ := NewClient(Config{Resolver: r})
+	stree, err := c.SyncTree(url)
+	if err != nil {
+		t.Fatal("sync error:", err)
+	}
+	if !reflect.DeepEqual(sortByID(stree.Nodes()), sortByID(nodes)) {
+		t.Errorf("wrong nodes in synced tree")
+	}
+	if stree.Seq() != tree.Seq() {
+		t.Errorf("synced tree has wrong seq %d, want %d", stree.Seq(), tree.Seq())
+	}
+	if stree.Signature() != tree.Signature() {
+		t.Errorf("synced tree has wrong signature")
+	}
+}
+
+// In this test, syncing the tree fails because it contains an invalid ENR entry.
+func TestClientSyncTreeBadNode(t *testing.T) {
+	tree, _ := MakeTree(3, nil, []string{"enrtree://AM5FCQLWIZX2QFPNJAP7VUERCCRNGRHWZG3YYHIUV7BVDQ5FDPRT2@morenodes.example.org"})
+	bad := rawEntry(enrPrefix + "-----")
+	badHash := subdomain(bad)
+	tree.entries[badHash] = bad
+	tree.root.eroot = badHash
+	url, _ := tree.Sign(testKey(signingKeySeed), "n")
+	r := mapResolver(tree.ToTXT("n"))
+
+	c, _ := NewClient(Config{Resolver: r})
+	_, err := c.SyncTree(url)
+	wantErr := nameError{name: badHash + ".n", err: entryError{typ: "enr", err: errInvalidENR}}
+	if err != wantErr {
+		t.Fatalf("expected sync error %q, got %q", wantErr, err)
+	}
+}
+
+// rawEntry is a tree entry with arbitrary content.
+type rawEntry string
+
+func (e rawEntry) String() string { return string(e) }
+
+// This test checks that RandomNodes returns nodes of the tree and of the trees
+// linked from it.
+func TestClientRandomNodesLinks(t *testing.T) {
+	nodes1 := testNodes(nodesSeed1, 10)
+	nodes2 := testNodes(nodesSeed2, 10)
+	tree2, url2 := makeTestTree("t2", nodes2, nil)
+	tree1, url1 := makeTestTree("t1", nodes1, []string{url2})
+
+	r := mapResolver{}
+	r.add(tree1.ToTXT("t1"))
+	r.add(tree2.ToTXT("t2"))
+
+	c, _ := NewClient(Config{Resolver: r}, url1)
+	checkRandomNodes(t, c, append(nodes1, nodes2...))
+}
+
+// This test checks that the client picks up a new root when the recheck
+// interval has passed.
+func TestClientRandomNodesRootUpdate(t *testing.T) {
+	var (
+		clock    = new(mclock.Simulated)
+		nodes    = testNodes(nodesSeed1, 30)
+		resolver = newMapResolver()
+		cfg      = Config{
+			Resolver:        resolver,
+			RecheckInterval: 20 * time.Minute,
+		}
+		c, _ = NewClient(cfg)
+	)
+	c.clock = clock
+	tree1, url := makeTestTree("n", nodes[:25], nil)
+	resolver.add(tree1.ToTXT("n"))
+	if err := c.AddTree(url); err != nil {
+		t.Fatal(err)
+	}
+	checkRandomNodes(t, c, nodes[:25])
+
+	// Update the tree and advance the clock to the recheck point.
+	tree2, _ := makeTestTree("n", nodes, nil)
+	resolver.clear()
+	resolver.add(tree2.ToTXT("n"))
+	clock.Run(cfg.RecheckInterval + 1*time.Second)
+	checkRandomNodes(t, c, nodes)
+}
+
+// This test verifies that trees with an invalid root signature are rejected.
+func TestClientBadRootSignature(t *testing.T) {
+	nodes := testNodes(nodesSeed1, 5)
+	tree, _ := makeTestTree("n", nodes, nil)
+	r := mapResolver(tree.ToTXT("n"))
+
+	// Point the client at the tree using a different public key.
+	otherKey := testKey(nodesSeed2)
+	url := (&linkEntry{"n", &otherKey.PublicKey}).url()
+
+	c, _ := NewClient(Config{Resolver: r})
+	_, err := c.SyncTree(url)
+	wantErr := nameError{name: "n", err: entryError{typ: "root", err: errInvalidSig}}
+	if err != wantErr {
+		t.Fatalf("expected sync error %q, got %q", wantErr, err)
+	}
+	c.AddTree(url)
+	if nodes := c.RandomNodes(5); len(nodes) != 0 {
+		t.Fatalf("got %d nodes from tree with bad signature", len(nodes))
+	}
+}
+
+// checkRandomNodes repeatedly queries random nodes until all wanted nodes were
+// returned, failing if any returned node isn't wanted.
+func checkRandomNodes(t *testing.T, c *Client, wantNodes []*enode.Node) {
+	t.Helper()
+
+	var (
+		want     = make(map[enode.ID]*enode.Node)
+		maxCalls = len(wantNodes) * 20
+		calls    = 0
+	)
+	for _, n := range wantNodes {
+		want[n.ID()] = n
+	}
+	for ; len(want) > 0 && calls < maxCalls; calls++ {
+		for _, n := range c.RandomNodes(1) {
+			if !containsNode(wantNodes, n) {
+				t.Fatalf("RandomNodes returned unexpected node %v", n.ID())
+			}
+			delete(want, n.ID())
+		}
+	}
+	if len(want) > 0 {
+		t.Fatalf("RandomNodes didn't return %d nodes after %d calls", len(want), calls)
+	}
+}
+
+func containsNode(nodes []*enode.Node, n *enode.Node) bool {
+	for _, m := range nodes {
+		if m.ID() == n.ID() {
+			return true
+		}
+	}
+	return false
+}
+
+func makeTestTree(domain string, nodes []*enode.Node, links []string) (*Tree, string) {
+	tree, err := MakeTree(1, nodes, links)
+	if err != nil {
+		panic(err)
+	}
+	url, err := tree.Sign(testKey(signingKeySeed), domain)
+	if err != nil {
+		panic(err)
+	}
+	return tree, url
+}
+
+// testKeys creates deterministic private keys for testing.
+func testKeys(seed int64, n int) []*ecdsa.PrivateKey {
+	keys := make([]*ecdsa.PrivateKey, n)
+	for i := 0; i < n; i++ {
+		key, err := crypto.ToECDSA(pad32(seed + int64(i)))
+		if err != nil {
+			panic("can't generate key: " + err.Error())
+		}
+		keys[i] = key
+	}
+	return keys
+}
+
+func pad32(v int64) []byte {
+	b := make([]byte, 32)
+	for i := 31; v > 0 && i >= 0; i-- {
+		b[i] = byte(v)
+		v >>= 8
+	}
+	return b
+}
+
+func testKey(seed int64) *ecdsa.PrivateKey {
+	return testKeys(seed, 1)[0]
+}
+
+func testNodes(seed int64, n int) []*enode.Node {
+	keys := testKeys(seed, n)
+	nodes := make([]*enode.Node, n)
+	for i, key := range keys {
+		record := new(enr.Record)
+		record.SetSeq(uint64(i))
+		record.Set(enr.IP(net.IPv4(10, 0, 0, byte(i))))
+		record.Set(enr.TCP(30303))
+		record.Set(enr.UDP(30303))
+		enode.SignV4(record, key)
+		n, err := enode.New(enode.ValidSchemes, record)
+		if err != nil {
+			panic(err)
+		}
+		nodes[i] = n
+	}
+	return nodes
+}
+
+// mapResolver is an in-memory DNS resolver serving TXT records from a map.
+type mapResolver map[string]string
+
+func newMapResolver(maps ...map[string]string) mapResolver {
+	mr := make(mapResolver)
+	for _, m := range maps {
+		mr.add(m)
+	}
+	return mr
+}
+
+func (mr mapResolver) clear() {
+	for k := range mr {
+		delete(mr, k)
+	}
+}
+
+func (mr mapResolver) add(m map[string]string) {
+	for k, v := range m {
+		mr[k] = v
+	}
+}
+
+func (mr mapResolver) LookupTXT(ctx context.Context, name string) ([]string, error) {
+	if record, ok := mr[name]; ok {
+		return []string{record}, nil
+	}
+	return nil, nil
+}
@@ -1,4 +1,4 @@
-// Copyright 2016 The go-ethereum Authors
+// Copyright 2019 The go-ethereum Authors
 // This file is part of the go-ethereum library.
 //
 // The go-ethereum library is free software: you can redistribute it and/or modify
@@ -14,30 +14,11 @@
 // You should have received a copy of the GNU Lesser General Public License
 // along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
 
-// +build go1.4,nacl,faketime_simulation
-
-package discv5
-
-import (
-	"os"
-	"runtime"
-	"testing"
-	"unsafe"
-)
-
-// Enable fake time mode in the runtime, like on the go playground.
-// There is a slight chance that this won't work because some go code
-// might have executed before the variable is set.
-
-//go:linkname faketime runtime.faketime
-var faketime = 1
-
-func TestMain(m *testing.M) {
-	// We need to use unsafe somehow in order to get access to go:linkname.
-	_ = unsafe.Sizeof(0)
-
-	// Run the actual test. runWithPlaygroundTime ensures that the only test
-	// that runs is the one calling it.
-	runtime.GOMAXPROCS(8)
-	os.Exit(m.Run())
-}
+// Package dnsdisc implements node discovery via DNS (EIP-1459).
+//
+// Node lists are published as a merkle tree of TXT records below a domain name.
+// The root record is signed by the list operator, and the client only follows
+// trees whose signature matches the public key contained in the enrtree:// URL
+// of the list. All other entries are content addressed by their hash, so they
+// can be cached indefinitely and verified without further signatures.
+package dnsdisc
@@ -0,0 +1,162 @@
+// Copyright 2019 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package dnsdisc
+
+import (
+	"context"
+	"sync"
+
+	"github.com/athofficial/go-ath/common/mclock"
+	"github.com/athofficial/go-ath/p2p/enode"
+)
+
+// clientTree is a full tree being synced.
+type clientTree struct {
+	c   *Client
+	loc *linkEntry // link to this tree
+
+	lock          sync.Mutex
+	lastRootCheck mclock.AbsTime // last revalidation of root
+	root          *rootEntry
+	linksSynced   bool // whether the links of the current root were followed
+}
+
+func newClientTree(c *Client, loc *linkEntry) *clientTree {
+	return &clientTree{c: c, loc: loc}
+}
+
+// randomNode returns a random node from the tree, walking down a random path
+// from the root of the ENR subtree. It returns nil if the tree has no nodes.
+func (ct *clientTree) randomNode() (*enode.Node, error) {
+	ct.lock.Lock()
+	defer ct.lock.Unlock()
+
+	if err := ct.updateRoot(); err != nil {
+		return nil, err
+	}
+	// Follow the links of the tree once per root, so linked lists also get
+	// picked by the client.
+	if !ct.linksSynced {
+		links, err := ct.syncLinks()
+		if err != nil {
+			return nil, err
+		}
+		ct.c.followLinks(links)
+		ct.linksSynced = true
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), ct.c.cfg.Timeout)
+	defer cancel()
+
+	hash := ct.root.eroot
+	for {
+		e, err := ct.c.resolveEntry(ctx, ct.loc.domain, hash)
+		if err != nil {
+			return nil, err
+		}
+		switch e := e.(type) {
+		case *enrEntry:
+			return e.node, nil
+		case *branchEntry:
+			if len(e.children) == 0 {
+				return nil, nil
+			}
+			ct.c.lock.Lock()
+			hash = e.children[ct.c.rand.Intn(len(e.children))]
+			ct.c.lock.Unlock()
+		case *linkEntry:
+			return nil, errLinkInENRTree
+		default:
+			return nil, errUnknownEntry
+		}
+	}
+}
+
+// syncLinks retrieves all links of the tree.
+func (ct *clientTree) syncLinks() ([]*linkEntry, error) {
+	var links []*linkEntry
+	err := ct.syncSubtree(ct.root.lroot, func(e entry) error {
+		switch e := e.(type) {
+		case *linkEntry:
+			links = append(links, e)
+		case *enrEntry:
+			return errENRInLinkTree
+		}
+		return nil
+	})
+	return links, err
+}
+
+// syncAll retrieves all entries of the tree into the given map.
+func (ct *clientTree) syncAll(dest map[string]entry) error {
+	ct.lock.Lock()
+	defer ct.lock.Unlock()
+
+	if err := ct.updateRoot(); err != nil {
+		return err
+	}
+	collect := func(e entry) error {
+		dest[subdomain(e)] = e
+		return nil
+	}
+	if err := ct.syncSubtree(ct.root.eroot, collect); err != nil {
+		return err
+	}
+	return ct.syncSubtree(ct.root.lroot, collect)
+}
+
+// syncSubtree resolves all entries below the given hash, calling fn on each of them.
+func (ct *clientTree) syncSubtree(hash string, fn func(entry) error) error {
+	ctx, cancel := context.WithTimeout(context.Background(), ct.c.cfg.Timeout)
+	defer cancel()
+
+	queue := []string{hash}
+	for len(queue) > 0 {
+		e, err := ct.c.resolveEntry(ctx, ct.loc.domain, queue[0])
+		if err != nil {
+			return err
+		}
+		queue = queue[1:]
+		if err := fn(e); err != nil {
+			return err
+		}
+		if b, ok := e.(*branchEntry); ok {
+			queue = append(queue, b.children...)
+		}
+	}
+	return nil
+}
+
+// updateRoot ensures that the given tree has an up-to-date root, rechecking it
+// once every recheck interval.
+func (ct *clientTree) updateRoot() error {
+	if ct.root != nil && ct.c.clock.Now() < ct.lastRootCheck.Add(ct.c.cfg.RecheckInterval) {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), ct.c.cfg.Timeout)
+	defer cancel()
+
+	root, err := ct.c.resolveRoot(ctx, ct.loc)
+	if err != nil {
+		return err
+	}
+	ct.lastRootCheck = ct.c.clock.Now()
+	if ct.root == nil || root.lroot != ct.root.lroot {
+		ct.linksSynced = false
+	}
+	ct.root = &root
+	return nil
+}
@@ -0,0 +1,209 @@
+// Copyright 2019 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package dnsdisc
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/athofficial/go-ath/p2p/enode"
+)
+
+func TestParseRoot(t *testing.T) {
+	tests := []struct {
+		input string
+		e     rootEntry
+		err   error
+	}{
+		{
+			input: "enrtree-root:v1 e=TO4Q75OQ2N7DX4EOOR7X66A6OM seq=3 sig=N-YY6UB9xD0hFx1Gmnt7v0RfSxch5tKyry2SRDoLx7B4GfPXagwLxQqyf7gAMvApFn_ORwZQekMWa_pXrcGCtw",
+			err:   entryError{"root", errSyntax},
+		},
+		{
+			input: "enrtree-root:v1 e=TO4Q75OQ2N7DX4EOOR7X66A6OM l=TO4Q75OQ2N7DX4EOOR7X66A6OM seq=3 sig=N-YY6UB9xD0hFx1Gmnt7v0RfSxch5tKyry2SRDoLx7B4GfPXagwLxQqyf7gAMvApFn_ORwZQekMWa_pXrcGCtw",
+			err:   entryError{"root", errInvalidSig},
+		},
+		{
+			input: "enrtree-root:v1 e=QFT4PBCRX4XQCV3VUYJ6BTCEPU l=JGUFMSAGI7KZYB3P7IZW4S5Y3A seq=3 sig=3FmXuVwpa8Y7OstZTx9PIb1mt8FrW7VpDOFv4AaGCsZ2EIHmhraWhe4NxYhQDlw5MjeFXYMbJjsPeKlHzmJREQE",
+			e: rootEntry{
+				eroot: "QFT4PBCRX4XQCV3VUYJ6BTCEPU",
+				lroot: "JGUFMSAGI7KZYB3P7IZW4S5Y3A",
+				seq:   3,
+				sig:   mustDecodeSig("3FmXuVwpa8Y7OstZTx9PIb1mt8FrW7VpDOFv4AaGCsZ2EIHmhraWhe4NxYhQDlw5MjeFXYMbJjsPeKlHzmJREQE"),
+			},
+		},
+	}
+	for i, test := range tests {
+		e, err := parseRoot(test.input)
+		if !reflect.DeepEqual(e, test.e) {
+			t.Errorf("test %d: wrong entry %+v, want %+v", i, e, test.e)
+		}
+		if err != test.err {
+			t.Errorf("test %d: wrong error %q, want %q", i, err, test.err)
+		}
+	}
+}
+
+func TestParseEntry(t *testing.T) {
+	testkey := testKey(signingKeySeed)
+	tests := []struct {
+		input string
+		e     entry
+		err   error
+	}{
+		// Subtrees:
+		{
+			input: "enrtree-branch:1,2",
+			err:   entryError{"branch", errInvalidChild},
+		},
+		{
+			input: "enrtree-branch:AAAAAAAAAA",
+			err:   entryError{"branch", errInvalidChild},
+		},
+		{
+			input: "enrtree-branch:",
+			e:     &branchEntry{},
+		},
+		{
+			input: "enrtree-branch:AAAAAAAAAAAAAAAAAAAAAAAAAA",
+			e:     &branchEntry{[]string{"AAAAAAAAAAAAAAAAAAAAAAAAAA"}},
+		},
+		{
+			input: "enrtree-branch:AAAAAAAAAAAAAAAAAAAAAAAAAA,BBBBBBBBBBBBBBBBBBBBBBBBBB",
+			e:     &branchEntry{[]string{"AAAAAAAAAAAAAAAAAAAAAAAAAA", "BBBBBBBBBBBBBBBBBBBBBBBBBB"}},
+		},
+		// Links
+		{
+			input: (&linkEntry{"nodes.example.org", &testkey.PublicKey}).String(),
+			e:     &linkEntry{"nodes.example.org", &testkey.PublicKey},
+		},
+		{
+			input: "enrtree://nodes.example.org",
+			err:   entryError{"link", errNoPubkey},
+		},
+		{
+			input: "enrtree://AP62DT7WOTEQZGQZOU474PP3KMEGVTTE7A7NPRXKX3DUD57@nodes.example.org",
+			err:   entryError{"link", errBadPubkey},
+		},
+		{
+			input: "enrtree://AP62DT7WONEQZGQZOU474PP3KMEGVTTE7A7NPRXKX3DUD57TQHGIA@nodes.example.org",
+			err:   entryError{"link", errBadPubkey},
+		},
+		// ENRs
+		{
+			input: "enr:-----",
+			err:   entryError{"enr", errInvalidENR},
+		},
+		// Invalid:
+		{input: "", err: errUnknownEntry},
+		{input: "foo", err: errUnknownEntry},
+		{input: "enrtree", err: errUnknownEntry},
+		{input: "enrtree-x=", err: errUnknownEntry},
+	}
+	for i, test := range tests {
+		e, err := parseEntry(test.input, enode.ValidSchemes)
+		if !reflect.DeepEqual(e, test.e) {
+			t.Errorf("test %d: wrong entry %+v, want %+v", i, e, test.e)
+		}
+		if err != test.err {
+			t.Errorf("test %d: wrong error %q, want %q", i, err, test.err)
+		}
+	}
+}
+
+func TestMakeTree(t *testing.T) {
+	nodes := testNodes(nodesSeed2, 50)
+	links := []string{(&linkEntry{"other.example.org", &testKey(nodesSeed1).PublicKey}).url()}
+	tree, err := MakeTree(2, nodes, links)
+	if err != nil {
+		t.Fatal(err)
+	}
+	txt := tree.ToTXT("")
+	if len(txt) < len(nodes)+1 {
+		t.Fatal("too few TXT records in output")
+	}
+	for name, record := range txt {
+		if name == "" {
+			continue
+		}
+		e, err := parseEntry(record, enode.ValidSchemes)
+		if err != nil {
+			t.Fatalf("can't parse record %q: %v", name, err)
+		}
+		if subdomain(e) != name {
+			t.Fatalf("record %q has wrong subdomain %q", record, name)
+		}
+		if be, ok := e.(*branchEntry); ok && len(be.children) > maxChildren {
+			t.Fatalf("branch %q has too many children", name)
+		}
+	}
+	if !reflect.DeepEqual(tree.Nodes(), sortByID(nodes)) {
+		t.Errorf("wrong nodes in tree")
+	}
+	if !reflect.DeepEqual(tree.Links(), links) {
+		t.Errorf("wrong links in tree: %v", tree.Links())
+	}
+}
+
+func TestTreeSignature(t *testing.T) {
+	key := testKey(signingKeySeed)
+	tree, err := MakeTree(1, testNodes(nodesSeed1, 3), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tree.Sign(key, "n"); err != nil {
+		t.Fatal(err)
+	}
+	sig := tree.Signature()
+
+	// The signature must be accepted with the signing key, but not any other.
+	if err := tree.SetSignature(&key.PublicKey, sig); err != nil {
+		t.Fatalf("valid signature rejected: %v", err)
+	}
+	if err := tree.SetSignature(&testKey(nodesSeed2).PublicKey, sig); err != errInvalidSig {
+		t.Fatalf("wrong error for bad signature: %v", err)
+	}
+	if tree.Signature() != sig {
+		t.Fatal("invalid signature was assigned")
+	}
+}
+
+func TestParseURL(t *testing.T) {
+	key := testKey(signingKeySeed)
+	url := (&linkEntry{"nodes.example.org", &key.PublicKey}).url()
+	domain, pubkey, err := ParseURL(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if domain != "nodes.example.org" {
+		t.Errorf("wrong domain %q", domain)
+	}
+	if !reflect.DeepEqual(pubkey, &key.PublicKey) {
+		t.Errorf("wrong public key")
+	}
+	if _, _, err := ParseURL("enode://nodes.example.org"); err == nil {
+		t.Error("expected error for wrong scheme")
+	}
+}
+
+func mustDecodeSig(sig string) []byte {
+	b, err := b64format.DecodeString(sig)
+	if err != nil {
+		panic(err)
+	}
+	return b
+}
@@ -42,6 +42,11 @@ const (
 	dbLocalPrefix  = "local:"
 	dbDiscoverRoot = "v4"
 
+	// Bans are keyed by node ID or subnet, the full keys are "ban:n:<ID>" and
+	// "ban:ip:<CIDR>". The value is the expiry time of the ban.
+	dbBanNodePrefix   = "ban:n:"
+	dbBanSubnetPrefix = "ban:ip:"
+
 	// These fields are stored per ID and IP, the full key is "n:<ID>:v4:<IP>:findfail".
 	// Use nodeItemKey to create those keys.
 	dbNodeFindFails = "findfail"
@@ -388,6 +393,56 @@ func (db *DB) storeLocalSeq(id ID, n uint64) {
 	db.storeUint64(nodeItemKey(id, zeroIP, dbLocalSeq), n)
 }
 
+// BanNode stores a ban of the given node, which expires at the given time.
+func (db *DB) BanNode(id ID, until time.Time) error {
+	return db.storeInt64(append([]byte(dbBanNodePrefix), id[:]...), until.Unix())
+}
+
+// UnbanNode deletes the ban of the given node.
+func (db *DB) UnbanNode(id ID) error {
+	return db.lvl.Delete(append([]byte(dbBanNodePrefix), id[:]...), nil)
+}
+
+// BannedNodes returns all stored node bans and their expiry times.
+func (db *DB) BannedNodes() map[ID]time.Time {
+	bans := make(map[ID]time.Time)
+	it := db.lvl.NewIterator(util.BytesPrefix([]byte(dbBanNodePrefix)), nil)
+	defer it.Release()
+	for it.Next() {
+		var id ID
+		if len(it.Key()) != len(dbBanNodePrefix)+len(id) {
+			continue
+		}
+		copy(id[:], it.Key()[len(dbBanNodePrefix):])
+		until, _ := binary.Varint(it.Value())
+		bans[id] = time.Unix(until, 0)
+	}
+	return bans
+}
+
+// BanSubnet stores a ban of the given IP network, which expires at the given time.
+func (db *DB) BanSubnet(subnet *net.IPNet, until time.Time) error {
+	return db.storeInt64([]byte(dbBanSubnetPrefix+subnet.String()), until.Unix())
+}
+
+// UnbanSubnet deletes the ban of the given IP network.
+func (db *DB) UnbanSubnet(subnet *net.IPNet) error {
+	return db.lvl.Delete([]byte(dbBanSubnetPrefix+subnet.String()), nil)
+}
+
+// BannedSubnets returns all stored subnet bans, keyed by CIDR notation, and their
+// expiry times.
+func (db *DB) BannedSubnets() map[string]time.Time {
+	bans := make(map[string]time.Time)
+	it := db.lvl.NewIterator(util.BytesPrefix([]byte(dbBanSubnetPrefix)), nil)
+	defer it.Release()
+	for it.Next() {
+		until, _ := binary.Varint(it.Value())
+		bans[string(it.Key()[len(dbBanSubnetPrefix):])] = time.Unix(until, 0)
+	}
+	return bans
+}
+
 // QuerySeeds retrieves random nodes to be used as potential seed nodes
 // for bootstrapping.
 func (db *DB) QuerySeeds(n int, maxAge time.Duration) []*Node {
@@ -462,3 +462,36 @@ func TestDBExpiration(t *testing.T) {
 		}
 	}
 }
+
+func TestDBBans(t *testing.T) {
+	db, _ := OpenDB("")
+	defer db.Close()
+
+	var (
+		id        = ID{1}
+		_, sn, _  = net.ParseCIDR("10.1.2.0/24")
+		nodeUntil = time.Unix(1000, 0)
+		netUntil  = time.Unix(2000, 0)
+	)
+	if err := db.BanNode(id, nodeUntil); err != nil {
+		t.Fatal("can't store node ban:", err)
+	}
+	if err := db.BanSubnet(sn, netUntil); err != nil {
+		t.Fatal("can't store subnet ban:", err)
+	}
+	if bans := db.BannedNodes(); len(bans) != 1 || !bans[id].Equal(nodeUntil) {
+		t.Errorf("wrong node bans: %v", bans)
+	}
+	if bans := db.BannedSubnets(); len(bans) != 1 || !bans[sn.String()].Equal(netUntil) {
+		t.Errorf("wrong subnet bans: %v", bans)
+	}
+
+	db.UnbanNode(id)
+	db.UnbanSubnet(sn)
+	if bans := db.BannedNodes(); len(bans) != 0 {
+		t.Errorf("node bans left after unban: %v", bans)
+	}
+	if bans := db.BannedSubnets(); len(bans) != 0 {
+		t.Errorf("subnet bans left after unban: %v", bans)
+	}
+}
@@ -38,6 +38,12 @@ const (
 	MetricsOutboundConnects = "p2p/OutboundConnects" // Name for the registered outbound connects meter
 	MetricsOutboundTraffic  = "p2p/OutboundTraffic"  // Name for the registered outbound traffic meter
 
+	MetricsRejectTooMany    = "p2p/Reject/TooManyPeers" // Name for the meter of connections rejected because all slots are taken
+	MetricsRejectSubnet     = "p2p/Reject/Subnet"       // Name for the meter of connections rejected by the per-subnet limits
+	MetricsRejectThrottle   = "p2p/Reject/Throttle"     // Name for the meter of inbound connections rejected for trying too often
+	MetricsRejectBanned     = "p2p/Reject/Banned"       // Name for the meter of connections from banned nodes
+	MetricsRejectRestricted = "p2p/Reject/NetRestrict"  // Name for the meter of inbound connections rejected by NetRestrict
+
 	MeteredPeerLimit = 1024 // This amount of peers are individually metered
 )
 
@@ -47,6 +53,12 @@ var (
 	egressConnectMeter  = metrics.NewRegisteredMeter(MetricsOutboundConnects, nil) // Meter counting the egress connections
 	egressTrafficMeter  = metrics.NewRegisteredMeter(MetricsOutboundTraffic, nil)  // Meter metering the cumulative egress traffic
 
+	rejectTooManyMeter    = metrics.NewRegisteredMeter(MetricsRejectTooMany, nil)    // Meter counting connections rejected because all slots are taken
+	rejectSubnetMeter     = metrics.NewRegisteredMeter(MetricsRejectSubnet, nil)     // Meter counting connections rejected by the per-subnet limits
+	rejectThrottleMeter   = metrics.NewRegisteredMeter(MetricsRejectThrottle, nil)   // Meter counting inbound connections rejected for trying too often
+	rejectBannedMeter     = metrics.NewRegisteredMeter(MetricsRejectBanned, nil)     // Meter counting connections from banned nodes
+	rejectRestrictedMeter = metrics.NewRegisteredMeter(MetricsRejectRestricted, nil) // Meter counting inbound connections rejected by NetRestrict
+
 	PeerIngressRegistry = metrics.NewPrefixedChildRegistry(metrics.EphemeralRegistry, MetricsInboundTraffic+"/")  // Registry containing the peer ingress
 	PeerEgressRegistry  = metrics.NewPrefixedChildRegistry(metrics.EphemeralRegistry, MetricsOutboundTraffic+"/") // Registry containing the peer egress
 
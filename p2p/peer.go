@@ -113,6 +113,9 @@ type Peer struct {
 
 	// events receives message send / receive events if set
 	events *event.Feed
+
+	// reputation receives the behaviours reported by protocols if set
+	reputation *reputation
 }
 
 // NewPeer returns a peer for testing purposes.
@@ -165,6 +168,38 @@ func (p *Peer) Disconnect(reason DiscReason) {
 	}
 }
 
+// Report records a behaviour of the peer in the reputation system of the server.
+// Peers whose score drops below the ban threshold are disconnected and banned
+// for a while. Trusted and static peers are never banned automatically.
+func (p *Peer) Report(b Behaviour) {
+	if p.reputation == nil {
+		return
+	}
+	exempt := p.rw.is(trustedConn | staticDialedConn)
+	if p.reputation.report(p.ID(), p.remoteIP(), b, exempt) {
+		p.log.Debug("Disconnecting banned peer", "behaviour", b)
+		p.Disconnect(DiscUselessPeer)
+	}
+}
+
+// Score returns the current score of the peer in the reputation system of the
+// server, or zero if the server doesn't track reputations.
+func (p *Peer) Score() int {
+	if p.reputation == nil {
+		return 0
+	}
+	return p.reputation.score(p.ID())
+}
+
+// remoteIP returns the IP address of the network connection, falling back to
+// the IP of the node record.
+func (p *Peer) remoteIP() net.IP {
+	if tcp, ok := p.RemoteAddr().(*net.TCPAddr); ok {
+		return tcp.IP
+	}
+	return p.Node().IP()
+}
+
 // String implements fmt.Stringer.
 func (p *Peer) String() string {
 	id := p.ID()
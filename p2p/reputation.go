@@ -0,0 +1,409 @@
+// Copyright 2019 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package p2p
+
+import (
+	"fmt"
+	"math"
+	"net"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/athofficial/go-ath/log"
+	"github.com/athofficial/go-ath/p2p/enode"
+	"github.com/athofficial/go-ath/p2p/netutil"
+)
+
+// Behaviour is a peer action which protocols report to the reputation system of
+// the server using Peer.Report.
+type Behaviour int
+
+const (
+	GoodResponse   Behaviour = iota // Delivered requested data
+	RequestTimeout                  // Didn't answer a request in time
+	BadResponse                     // Delivered invalid or useless data
+	InvalidBlock                    // Propagated a block which failed validation
+	ProtocolBreach                  // Sent a malformed or unexpected message
+	BadTransaction                  // Relayed an invalid transaction or exceeded its transaction rate
+)
+
+var behaviourScores = [...]int{
+	GoodResponse:   1,
+	RequestTimeout: -10,
+	BadResponse:    -25,
+	InvalidBlock:   -50,
+	ProtocolBreach: -50,
+	BadTransaction: -5,
+}
+
+var behaviourToString = [...]string{
+	GoodResponse:   "good response",
+	RequestTimeout: "request timeout",
+	BadResponse:    "bad response",
+	InvalidBlock:   "invalid block",
+	ProtocolBreach: "breach of protocol",
+	BadTransaction: "bad transaction",
+}
+
+func (b Behaviour) String() string {
+	if b < 0 || int(b) >= len(behaviourToString) {
+		return fmt.Sprintf("unknown behaviour %d", b)
+	}
+	return behaviourToString[b]
+}
+
+const (
+	// maxScore is the highest score a peer can build up by good behaviour, so
+	// peers can't bank credit for misbehaving later.
+	maxScore = 50
+
+	// banThreshold is the score at which a peer is disconnected and banned.
+	banThreshold = -100
+
+	// scoreHalfLife is the time after which a score has decayed to half its
+	// value, so old offences are eventually forgotten.
+	scoreHalfLife = 30 * time.Minute
+
+	// defaultBanDuration is how long nodes and subnets are banned for by the
+	// reputation system, and by admin_ban if no duration is given.
+	defaultBanDuration = time.Hour
+
+	// subnetBanThreshold is the number of banned nodes in the same subnet after
+	// which the whole subnet is banned.
+	subnetBanThreshold = 3
+
+	// Prefix lengths of the subnets a node is banned with.
+	ipv4SubnetBits = 24
+	ipv6SubnetBits = 64
+
+	// maxTrackedScores is the number of scores after which forgotten ones are
+	// pruned.
+	maxTrackedScores = 1024
+)
+
+// PeerScore is the reputation of a single node.
+type PeerScore struct {
+	ID          enode.ID   `json:"id"`
+	IP          string     `json:"ip,omitempty"`
+	Score       int        `json:"score"`
+	BannedUntil *time.Time `json:"bannedUntil,omitempty"`
+}
+
+// SubnetBan is a banned IP network.
+type SubnetBan struct {
+	Subnet      string    `json:"subnet"`
+	BannedUntil time.Time `json:"bannedUntil"`
+}
+
+// ReputationInfo is a summary of the reputation system, as reported by the
+// admin_peerScores RPC method.
+type ReputationInfo struct {
+	Peers   []*PeerScore `json:"peers"`
+	Subnets []*SubnetBan `json:"subnets"`
+}
+
+// reputation tracks the scores of remote nodes and bans the ones misbehaving.
+// Bans are persisted in the node database, scores are kept in memory only.
+type reputation struct {
+	db  *enode.DB
+	log log.Logger
+	now func() time.Time // Overridden in tests
+
+	lock       sync.Mutex
+	scores     map[enode.ID]*nodeScore
+	nodeBans   map[enode.ID]*nodeBan
+	subnetBans map[string]*subnetBan // Keyed by CIDR notation
+}
+
+type nodeScore struct {
+	value   float64
+	updated time.Time
+	ip      net.IP
+}
+
+type nodeBan struct {
+	until time.Time
+	ip    net.IP // IP of the node when it was banned, nil if unknown
+}
+
+type subnetBan struct {
+	subnet *net.IPNet
+	until  time.Time
+}
+
+// newReputation creates the reputation system, loading the bans which haven't
+// expired yet from the node database.
+func newReputation(db *enode.DB, logger log.Logger) *reputation {
+	r := &reputation{
+		db:         db,
+		log:        logger,
+		now:        time.Now,
+		scores:     make(map[enode.ID]*nodeScore),
+		nodeBans:   make(map[enode.ID]*nodeBan),
+		subnetBans: make(map[string]*subnetBan),
+	}
+	now := r.now()
+	for id, until := range db.BannedNodes() {
+		if until.After(now) {
+			r.nodeBans[id] = &nodeBan{until: until}
+		} else {
+			db.UnbanNode(id)
+		}
+	}
+	for cidr, until := range db.BannedSubnets() {
+		_, subnet, err := net.ParseCIDR(cidr)
+		if err == nil && until.After(now) {
+			r.subnetBans[subnet.String()] = &subnetBan{subnet, until}
+		} else if err == nil {
+			db.UnbanSubnet(subnet)
+		}
+	}
+	return r
+}
+
+// report adds the score of a behaviour to the node. It returns true if the node
+// got banned because of it. Exempt nodes are scored, but never banned.
+func (r *reputation) report(id enode.ID, ip net.IP, b Behaviour, exempt bool) bool {
+	if b < 0 || int(b) >= len(behaviourScores) {
+		return false
+	}
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	now := r.now()
+	s := r.scores[id]
+	if s == nil {
+		if len(r.scores) >= maxTrackedScores {
+			r.prune(now)
+		}
+		s = &nodeScore{updated: now}
+		r.scores[id] = s
+	}
+	s.value = math.Min(s.decayed(now)+float64(behaviourScores[b]), maxScore)
+	s.updated, s.ip = now, ip
+
+	if s.value > banThreshold || exempt {
+		return false
+	}
+	r.log.Debug("Banning misbehaving node", "id", id, "ip", ip, "behaviour", b)
+	delete(r.scores, id)
+	r.banNode(id, ip, now.Add(defaultBanDuration))
+	return true
+}
+
+// score returns the current score of a node.
+func (r *reputation) score(id enode.ID) int {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if s := r.scores[id]; s != nil {
+		return int(math.Round(s.decayed(r.now())))
+	}
+	return 0
+}
+
+// decayed returns the value of the score at the given time.
+func (s *nodeScore) decayed(now time.Time) float64 {
+	elapsed := now.Sub(s.updated)
+	if elapsed <= 0 {
+		return s.value
+	}
+	return s.value * math.Exp2(-float64(elapsed)/float64(scoreHalfLife))
+}
+
+// prune drops the scores which have decayed to zero.
+func (r *reputation) prune(now time.Time) {
+	for id, s := range r.scores {
+		if math.Abs(s.decayed(now)) < 1 {
+			delete(r.scores, id)
+		}
+	}
+}
+
+// banNode bans a node until the given time. If enough nodes of its subnet are
+// banned, the subnet is banned as well. The lock must be held.
+func (r *reputation) banNode(id enode.ID, ip net.IP, until time.Time) {
+	r.nodeBans[id] = &nodeBan{until: until, ip: ip}
+	if err := r.db.BanNode(id, until); err != nil {
+		r.log.Warn("Failed to store node ban", "id", id, "err", err)
+	}
+	// Nodes on the local network are never banned by subnet, they likely
+	// share their address.
+	if ip == nil || netutil.IsLAN(ip) {
+		return
+	}
+	var (
+		subnet = subnetOf(ip)
+		now    = r.now()
+		count  = 0
+	)
+	for _, ban := range r.nodeBans {
+		if ban.ip != nil && ban.until.After(now) && subnet.Contains(ban.ip) {
+			count++
+		}
+	}
+	if count >= subnetBanThreshold {
+		r.log.Debug("Banning misbehaving subnet", "subnet", subnet, "nodes", count)
+		r.banSubnet(subnet, until)
+	}
+}
+
+// banSubnet bans an IP network until the given time. The lock must be held.
+func (r *reputation) banSubnet(subnet *net.IPNet, until time.Time) {
+	r.subnetBans[subnet.String()] = &subnetBan{subnet, until}
+	if err := r.db.BanSubnet(subnet, until); err != nil {
+		r.log.Warn("Failed to store subnet ban", "subnet", subnet, "err", err)
+	}
+}
+
+// isBanned returns whether the node or its IP address is banned.
+func (r *reputation) isBanned(id enode.ID, ip net.IP) bool {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	now := r.now()
+	if ban := r.nodeBans[id]; ban != nil {
+		if ban.until.After(now) {
+			return true
+		}
+		delete(r.nodeBans, id)
+		r.db.UnbanNode(id)
+	}
+	return r.isIPBannedLocked(ip, now)
+}
+
+// isIPBanned returns whether the IP address is contained in a banned subnet.
+func (r *reputation) isIPBanned(ip net.IP) bool {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	return r.isIPBannedLocked(ip, r.now())
+}
+
+func (r *reputation) isIPBannedLocked(ip net.IP, now time.Time) bool {
+	if ip == nil {
+		return false
+	}
+	for key, ban := range r.subnetBans {
+		if !ban.until.After(now) {
+			delete(r.subnetBans, key)
+			r.db.UnbanSubnet(ban.subnet)
+			continue
+		}
+		if ban.subnet.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// ban bans a node for the given duration.
+func (r *reputation) ban(id enode.ID, d time.Duration) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	delete(r.scores, id)
+	r.nodeBans[id] = &nodeBan{until: r.now().Add(d)}
+	if err := r.db.BanNode(id, r.nodeBans[id].until); err != nil {
+		r.log.Warn("Failed to store node ban", "id", id, "err", err)
+	}
+}
+
+// banIP bans an IP network for the given duration.
+func (r *reputation) banIP(subnet *net.IPNet, d time.Duration) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	r.banSubnet(subnet, r.now().Add(d))
+}
+
+// unban lifts the ban of a node and resets its score.
+func (r *reputation) unban(id enode.ID) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	delete(r.scores, id)
+	delete(r.nodeBans, id)
+	r.db.UnbanNode(id)
+}
+
+// unbanIP lifts the ban of an IP network.
+func (r *reputation) unbanIP(subnet *net.IPNet) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	delete(r.subnetBans, subnet.String())
+	r.db.UnbanSubnet(subnet)
+}
+
+// info returns the current scores and bans.
+func (r *reputation) info() *ReputationInfo {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	var (
+		now  = r.now()
+		info = &ReputationInfo{Peers: []*PeerScore{}, Subnets: []*SubnetBan{}}
+		seen = make(map[enode.ID]*PeerScore)
+	)
+	for id, s := range r.scores {
+		ps := &PeerScore{ID: id, Score: int(math.Round(s.decayed(now)))}
+		if s.ip != nil {
+			ps.IP = s.ip.String()
+		}
+		seen[id] = ps
+		info.Peers = append(info.Peers, ps)
+	}
+	for id, ban := range r.nodeBans {
+		if !ban.until.After(now) {
+			continue
+		}
+		ps := seen[id]
+		if ps == nil {
+			ps = &PeerScore{ID: id}
+			if ban.ip != nil {
+				ps.IP = ban.ip.String()
+			}
+			info.Peers = append(info.Peers, ps)
+		}
+		until := ban.until
+		ps.BannedUntil = &until
+	}
+	for _, ban := range r.subnetBans {
+		if ban.until.After(now) {
+			info.Subnets = append(info.Subnets, &SubnetBan{ban.subnet.String(), ban.until})
+		}
+	}
+	sort.Slice(info.Peers, func(i, j int) bool {
+		return info.Peers[i].Score < info.Peers[j].Score
+	})
+	sort.Slice(info.Subnets, func(i, j int) bool {
+		return info.Subnets[i].Subnet < info.Subnets[j].Subnet
+	})
+	return info
+}
+
+// subnetOf returns the subnet which is banned together with the given IP.
+func subnetOf(ip net.IP) *net.IPNet {
+	if ip4 := ip.To4(); ip4 != nil {
+		mask := net.CIDRMask(ipv4SubnetBits, 8*net.IPv4len)
+		return &net.IPNet{IP: ip4.Mask(mask), Mask: mask}
+	}
+	mask := net.CIDRMask(ipv6SubnetBits, 8*net.IPv6len)
+	return &net.IPNet{IP: ip.Mask(mask), Mask: mask}
+}
@@ -0,0 +1,155 @@
+// Copyright 2019 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package p2p
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/athofficial/go-ath/log"
+	"github.com/athofficial/go-ath/p2p/enode"
+)
+
+func newTestReputation(t *testing.T) (*reputation, *time.Time) {
+	db, err := enode.OpenDB("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now().Truncate(time.Second)
+	r := newReputation(db, log.Root())
+	r.now = func() time.Time { return now }
+	return r, &now
+}
+
+func TestReputationBan(t *testing.T) {
+	r, now := newTestReputation(t)
+	defer r.db.Close()
+
+	var (
+		id = enode.ID{1}
+		ip = net.IP{8, 8, 8, 8}
+	)
+	// Good behaviour builds up credit which bad behaviour has to consume first.
+	for i := 0; i < 10; i++ {
+		r.report(id, ip, GoodResponse, false)
+	}
+	if r.report(id, ip, InvalidBlock, false) || r.report(id, ip, InvalidBlock, false) {
+		t.Fatal("node banned before reaching the threshold")
+	}
+	if r.isBanned(id, ip) {
+		t.Fatal("node is banned")
+	}
+	if !r.report(id, ip, ProtocolBreach, false) {
+		t.Fatal("node not banned after reaching the threshold")
+	}
+	if !r.isBanned(id, ip) {
+		t.Fatal("node isn't banned")
+	}
+	if r.isIPBanned(ip) {
+		t.Fatal("subnet of a single node banned")
+	}
+	// The ban is persisted and survives a restart.
+	r2 := newReputation(r.db, log.Root())
+	r2.now = r.now
+	if !r2.isBanned(id, ip) {
+		t.Fatal("ban not restored from database")
+	}
+	// The ban expires.
+	*now = now.Add(defaultBanDuration)
+	if r.isBanned(id, ip) {
+		t.Fatal("ban didn't expire")
+	}
+	if bans := r.db.BannedNodes(); len(bans) != 0 {
+		t.Fatalf("expired ban still in database: %v", bans)
+	}
+}
+
+func TestReputationExempt(t *testing.T) {
+	r, _ := newTestReputation(t)
+	defer r.db.Close()
+
+	id, ip := enode.ID{1}, net.IP{8, 8, 8, 8}
+	for i := 0; i < 5; i++ {
+		if r.report(id, ip, ProtocolBreach, true) {
+			t.Fatal("exempt node banned")
+		}
+	}
+	if r.isBanned(id, ip) {
+		t.Fatal("exempt node is banned")
+	}
+}
+
+func TestReputationDecay(t *testing.T) {
+	r, now := newTestReputation(t)
+	defer r.db.Close()
+
+	id, ip := enode.ID{1}, net.IP{8, 8, 8, 8}
+	r.report(id, ip, BadResponse, false)
+	r.report(id, ip, BadResponse, false)
+	r.report(id, ip, RequestTimeout, false)
+
+	if score := r.score(id); score != -60 {
+		t.Fatalf("wrong score %d, want -60", score)
+	}
+	// Old offences are forgotten after a while.
+	*now = now.Add(2 * scoreHalfLife)
+	if score := r.info().Peers[0].Score; score != -15 {
+		t.Fatalf("wrong decayed score %d, want -15", score)
+	}
+	if score := r.score(id); score != -15 {
+		t.Fatalf("wrong decayed score %d, want -15", score)
+	}
+	if r.report(id, ip, InvalidBlock, false) {
+		t.Fatal("node banned for decayed offences")
+	}
+}
+
+func TestReputationSubnetBan(t *testing.T) {
+	r, _ := newTestReputation(t)
+	defer r.db.Close()
+
+	for i := byte(1); i <= subnetBanThreshold; i++ {
+		id, ip := enode.ID{i}, net.IP{8, 8, 8, i}
+		for !r.report(id, ip, ProtocolBreach, false) {
+		}
+	}
+	if !r.isIPBanned(net.IP{8, 8, 8, 100}) {
+		t.Fatal("subnet not banned")
+	}
+	if r.isIPBanned(net.IP{8, 8, 9, 1}) {
+		t.Fatal("other subnet banned")
+	}
+	if info := r.info(); len(info.Subnets) != 1 || info.Subnets[0].Subnet != "8.8.8.0/24" {
+		t.Fatalf("wrong subnet bans: %+v", info.Subnets)
+	}
+
+	// Nodes on the local network don't get their subnet banned.
+	for i := byte(1); i <= subnetBanThreshold; i++ {
+		id, ip := enode.ID{10, i}, net.IP{192, 168, 0, i}
+		for !r.report(id, ip, ProtocolBreach, false) {
+		}
+	}
+	if r.isIPBanned(net.IP{192, 168, 0, 100}) {
+		t.Fatal("LAN subnet banned")
+	}
+
+	r.unbanIP(subnetOf(net.IP{8, 8, 8, 0}))
+	if r.isIPBanned(net.IP{8, 8, 8, 100}) {
+		t.Fatal("subnet still banned after unban")
+	}
+}
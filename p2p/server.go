@@ -34,7 +34,7 @@ import (
 	"github.com/athofficial/go-ath/event"
 	"github.com/athofficial/go-ath/log"
 	"github.com/athofficial/go-ath/p2p/discover"
-	"github.com/athofficial/go-ath/p2p/discv5"
+	"github.com/athofficial/go-ath/p2p/dnsdisc"
 	"github.com/athofficial/go-ath/p2p/enode"
 	"github.com/athofficial/go-ath/p2p/enr"
 	"github.com/athofficial/go-ath/p2p/nat"
@@ -58,7 +58,10 @@ const (
 	frameWriteTimeout = 20 * time.Second
 )
 
-var errServerStopped = errors.New("server stopped")
+var (
+	errServerStopped = errors.New("server stopped")
+	errBanned        = errors.New("node is banned")
+)
 
 // Config holds Server options.
 type Config struct {
@@ -79,12 +82,30 @@ type Config struct {
 	// Setting DialRatio to zero defaults it to 3.
 	DialRatio int `toml:",omitempty"`
 
+	// OutboundSlots is the number of peer slots reserved for dialed connections,
+	// inbound connections can only use the remaining ones. Setting it to zero
+	// derives it from DialRatio.
+	OutboundSlots int `toml:",omitempty"`
+
+	// TrustedSlots is the number of peer slots reserved for trusted nodes. Other
+	// peers can only use MaxPeers - TrustedSlots slots, while trusted nodes can
+	// always connect, even above MaxPeers.
+	TrustedSlots int `toml:",omitempty"`
+
+	// MaxPeersPerSubnet24 and MaxPeersPerSubnet16 limit the number of peers from
+	// the same /24 and /16 IPv4 networks (/64 and /48 for IPv6), so a single
+	// network can't take all peer slots. Trusted and static peers and nodes on
+	// the local network aren't limited. Zero uses the defaults of 4 and 8, a
+	// negative value disables the limit.
+	MaxPeersPerSubnet24 int `toml:",omitempty"`
+	MaxPeersPerSubnet16 int `toml:",omitempty"`
+
 	// NoDiscovery can be used to disable the peer discovery mechanism.
 	// Disabling is useful for protocol debugging (manual topology).
 	NoDiscovery bool
 
-	// DiscoveryV5 specifies whether the new topic-discovery based V5 discovery
-	// protocol should be started or not.
+	// DiscoveryV5 specifies whether the discovery v5 protocol with topic
+	// advertisement should be started or not.
 	DiscoveryV5 bool `toml:",omitempty"`
 
 	// Name sets the node name of this server.
@@ -98,7 +119,12 @@ type Config struct {
 	// BootstrapNodesV5 are used to establish connectivity
 	// with the rest of the network using the V5 discovery
 	// protocol.
-	BootstrapNodesV5 []*discv5.Node `toml:",omitempty"`
+	BootstrapNodesV5 []*enode.Node `toml:",omitempty"`
+
+	// DNSDiscovery contains enrtree:// URLs of EIP-1459 node lists which are
+	// crawled via DNS to find dial candidates, in addition to any discovery
+	// protocol. This keeps bootstrapping working when the bootnodes are down.
+	DNSDiscovery []string `toml:",omitempty"`
 
 	// Static nodes are used as pre-configured connections which are always
 	// maintained and re-connected on disconnects.
@@ -164,12 +190,17 @@ type Server struct {
 	running bool
 
 	nodedb       *enode.DB
+	reputation   *reputation
 	localnode    *enode.LocalNode
 	ntab         discoverTable
+	dnsdisc      *dnsdisc.Client
 	listener     net.Listener
 	ourHandshake *protoHandshake
 	lastLookup   time.Time
-	DiscV5       *discv5.Network
+	DiscV5       *discover.UDPv5
+
+	// Recent inbound connection attempts, only accessed by listenLoop.
+	inboundHistory *ipHistory
 
 	// These are for Peers, PeerCount (and nothing else).
 	peerOp     chan peerOpFunc
@@ -344,6 +375,51 @@ func (srv *Server) RemoveTrustedPeer(node *enode.Node) {
 	}
 }
 
+// PeerScores returns the scores of the nodes known to the reputation system and
+// the current bans.
+func (srv *Server) PeerScores() *ReputationInfo {
+	return srv.reputation.info()
+}
+
+// BanNode bans a node for the given duration, disconnecting it if connected.
+// A zero duration uses the default ban duration of the reputation system.
+func (srv *Server) BanNode(id enode.ID, d time.Duration) {
+	if d == 0 {
+		d = defaultBanDuration
+	}
+	srv.reputation.ban(id, d)
+	for _, p := range srv.Peers() {
+		if p.ID() == id {
+			p.Disconnect(DiscUselessPeer)
+		}
+	}
+}
+
+// BanSubnet bans all nodes in the given IP network for the given duration,
+// disconnecting the ones which are connected. A zero duration uses the default
+// ban duration of the reputation system.
+func (srv *Server) BanSubnet(subnet *net.IPNet, d time.Duration) {
+	if d == 0 {
+		d = defaultBanDuration
+	}
+	srv.reputation.banIP(subnet, d)
+	for _, p := range srv.Peers() {
+		if subnet.Contains(p.remoteIP()) {
+			p.Disconnect(DiscUselessPeer)
+		}
+	}
+}
+
+// UnbanNode lifts the ban of a node and resets its score.
+func (srv *Server) UnbanNode(id enode.ID) {
+	srv.reputation.unban(id)
+}
+
+// UnbanSubnet lifts the ban of an IP network.
+func (srv *Server) UnbanSubnet(subnet *net.IPNet) {
+	srv.reputation.unbanIP(subnet)
+}
+
 // SubscribePeers subscribes the given channel to peer events
 func (srv *Server) SubscribeEvents(ch chan *PeerEvent) event.Subscription {
 	return srv.peerFeed.Subscribe(ch)
@@ -361,6 +437,13 @@ func (srv *Server) Self() *enode.Node {
 	return ln.Node()
 }
 
+// LocalNode returns the local node record, or nil if the server isn't running.
+func (srv *Server) LocalNode() *enode.LocalNode {
+	srv.lock.Lock()
+	defer srv.lock.Unlock()
+	return srv.localnode
+}
+
 // Stop terminates the server and all active peer connections.
 // It blocks until all active connections have been closed.
 func (srv *Server) Stop() {
@@ -386,7 +469,7 @@ type sharedUDPConn struct {
 	unhandled chan discover.ReadPacket
 }
 
-// ReadFromUDP implements discv5.conn
+// ReadFromUDP implements discover.conn
 func (s *sharedUDPConn) ReadFromUDP(b []byte) (n int, addr *net.UDPAddr, err error) {
 	packet, ok := <-s.unhandled
 	if !ok {
@@ -400,7 +483,7 @@ func (s *sharedUDPConn) ReadFromUDP(b []byte) (n int, addr *net.UDPAddr, err err
 	return l, packet.Addr, nil
 }
 
-// Close implements discv5.conn
+// Close implements discover.conn
 func (s *sharedUDPConn) Close() error {
 	return nil
 }
@@ -432,6 +515,10 @@ func (srv *Server) Start() (err error) {
 	if srv.Dialer == nil {
 		srv.Dialer = TCPDialer{&net.Dialer{Timeout: defaultDialTimeout}}
 	}
+	if srv.OutboundSlots < 0 || srv.TrustedSlots < 0 || srv.OutboundSlots+srv.TrustedSlots > srv.MaxPeers {
+		return errors.New("invalid OutboundSlots or TrustedSlots, they must fit into MaxPeers")
+	}
+	srv.inboundHistory = newIPHistory()
 	srv.quit = make(chan struct{})
 	srv.addpeer = make(chan *conn)
 	srv.delpeer = make(chan peerDrop)
@@ -457,6 +544,7 @@ func (srv *Server) Start() (err error) {
 
 	dynPeers := srv.maxDialedConns()
 	dialer := newDialState(srv.localnode.ID(), srv.StaticNodes, srv.BootstrapNodes, srv.ntab, dynPeers, srv.NetRestrict)
+	dialer.reputation = srv.reputation
 	srv.loopWG.Add(1)
 	go srv.run(dialer)
 	return nil
@@ -477,6 +565,7 @@ func (srv *Server) setupLocalNode() error {
 		return err
 	}
 	srv.nodedb = db
+	srv.reputation = newReputation(db, srv.log)
 	srv.localnode = enode.NewLocalNode(db, srv.PrivateKey)
 	srv.localnode.SetFallbackIP(net.IP{127, 0, 0, 1})
 	srv.localnode.Set(capsByNameAndVersion(srv.ourHandshake.Caps))
@@ -508,6 +597,14 @@ func (srv *Server) setupLocalNode() error {
 }
 
 func (srv *Server) setupDiscovery() error {
+	// DNS node lists don't need the UDP listener, set them up first
+	if len(srv.DNSDiscovery) > 0 {
+		client, err := dnsdisc.NewClient(dnsdisc.Config{Logger: srv.log}, srv.DNSDiscovery...)
+		if err != nil {
+			return err
+		}
+		srv.dnsdisc = client
+	}
 	if srv.NoDiscovery && !srv.DiscoveryV5 {
 		return nil
 	}
@@ -551,20 +648,20 @@ func (srv *Server) setupDiscovery() error {
 	}
 	// Discovery V5
 	if srv.DiscoveryV5 {
-		var ntab *discv5.Network
+		cfg := discover.Config{
+			PrivateKey:  srv.PrivateKey,
+			NetRestrict: srv.NetRestrict,
+			Bootnodes:   srv.BootstrapNodesV5,
+		}
 		var err error
 		if sconn != nil {
-			ntab, err = discv5.ListenUDP(srv.PrivateKey, sconn, "", srv.NetRestrict)
+			srv.DiscV5, err = discover.ListenV5(sconn, srv.localnode, cfg)
 		} else {
-			ntab, err = discv5.ListenUDP(srv.PrivateKey, conn, "", srv.NetRestrict)
+			srv.DiscV5, err = discover.ListenV5(conn, srv.localnode, cfg)
 		}
 		if err != nil {
 			return err
 		}
-		if err := ntab.SetFallbackNodes(srv.BootstrapNodesV5); err != nil {
-			return err
-		}
-		srv.DiscV5 = ntab
 	}
 	return nil
 }
@@ -712,8 +809,10 @@ running:
 				c.flags |= trustedConn
 			}
 			// TODO: track in-progress inbound node IDs (pre-Peer) to avoid dialing them.
+			err := srv.encHandshakeChecks(peers, inboundCount, c)
+			meterRejection(err)
 			select {
-			case c.cont <- srv.encHandshakeChecks(peers, inboundCount, c):
+			case c.cont <- err:
 			case <-srv.quit:
 				break running
 			}
@@ -721,6 +820,7 @@ running:
 			// At this point the connection is past the protocol handshake.
 			// Its capabilities are known and the remote identity is verified.
 			err := srv.protoHandshakeChecks(peers, inboundCount, c)
+			meterRejection(err)
 			if err == nil {
 				// The handshakes are done and it passed all checks.
 				p := newPeer(c, srv.Protocols)
@@ -729,6 +829,7 @@ running:
 				if srv.EnableMsgEvents {
 					p.events = &srv.peerFeed
 				}
+				p.reputation = srv.reputation
 				name := truncateName(c.name)
 				srv.log.Debug("Adding p2p peer", "name", name, "addr", c.fd.RemoteAddr(), "peers", len(peers)+1)
 				go srv.runPeer(p)
@@ -790,8 +891,11 @@ func (srv *Server) protoHandshakeChecks(peers map[enode.ID]*Peer, inboundCount i
 }
 
 func (srv *Server) encHandshakeChecks(peers map[enode.ID]*Peer, inboundCount int, c *conn) error {
+	regular := !c.is(trustedConn | staticDialedConn)
 	switch {
-	case !c.is(trustedConn|staticDialedConn) && len(peers) >= srv.MaxPeers:
+	case regular && len(peers) >= srv.MaxPeers:
+		return DiscTooManyPeers
+	case regular && srv.TrustedSlots > 0 && countUntrusted(peers) >= srv.maxRegularConns():
 		return DiscTooManyPeers
 	case !c.is(trustedConn) && c.is(inboundConn) && inboundCount >= srv.maxInboundConns():
 		return DiscTooManyPeers
@@ -799,18 +903,41 @@ func (srv *Server) encHandshakeChecks(peers map[enode.ID]*Peer, inboundCount int
 		return DiscAlreadyConnected
 	case c.node.ID() == srv.localnode.ID():
 		return DiscSelf
+	case !c.is(trustedConn) && srv.reputation.isBanned(c.node.ID(), c.node.IP()):
+		return errBanned
+	case regular && srv.subnetLimitReached(peers, c):
+		return errSubnetLimit
 	default:
 		return nil
 	}
 }
 
+// countUntrusted returns the number of peers which aren't trusted.
+func countUntrusted(peers map[enode.ID]*Peer) int {
+	n := 0
+	for _, p := range peers {
+		if !p.rw.is(trustedConn) {
+			n++
+		}
+	}
+	return n
+}
+
+// maxRegularConns is the number of peer slots usable by nodes which aren't trusted.
+func (srv *Server) maxRegularConns() int {
+	return srv.MaxPeers - srv.TrustedSlots
+}
+
 func (srv *Server) maxInboundConns() int {
-	return srv.MaxPeers - srv.maxDialedConns()
+	return srv.maxRegularConns() - srv.maxDialedConns()
 }
 func (srv *Server) maxDialedConns() int {
-	if srv.NoDiscovery || srv.NoDial {
+	if (srv.NoDiscovery && len(srv.DNSDiscovery) == 0) || srv.NoDial {
 		return 0
 	}
+	if srv.OutboundSlots > 0 {
+		return srv.OutboundSlots
+	}
 	r := srv.DialRatio
 	if r == 0 {
 		r = defaultDialRatio
@@ -853,20 +980,18 @@ func (srv *Server) listenLoop() {
 			break
 		}
 
-		// Reject connections that do not match NetRestrict.
-		if srv.NetRestrict != nil {
-			if tcp, ok := fd.RemoteAddr().(*net.TCPAddr); ok && !srv.NetRestrict.Contains(tcp.IP) {
-				srv.log.Debug("Rejected conn (not whitelisted in NetRestrict)", "addr", fd.RemoteAddr())
-				fd.Close()
-				slots <- struct{}{}
-				continue
-			}
-		}
-
 		var ip net.IP
 		if tcp, ok := fd.RemoteAddr().(*net.TCPAddr); ok {
 			ip = tcp.IP
 		}
+		// Reject connections which aren't allowed before the handshake.
+		if err := srv.checkInboundConn(ip, time.Now()); err != nil {
+			srv.log.Debug("Rejected inbound connection", "addr", fd.RemoteAddr(), "err", err)
+			meterRejection(err)
+			fd.Close()
+			slots <- struct{}{}
+			continue
+		}
 		fd = newMeteredConn(fd, true, ip)
 		srv.log.Trace("Accepted connection", "addr", fd.RemoteAddr())
 		go func() {
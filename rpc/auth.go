@@ -0,0 +1,118 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package rpc
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	jwt "github.com/dgrijalva/jwt-go"
+)
+
+// jwtClaims are the claims of the bearer tokens authenticating RPC clients.
+type jwtClaims struct {
+	jwt.StandardClaims
+
+	// Modules is the list of API modules the token grants access to. If empty,
+	// all modules exposed by the endpoint are accessible.
+	Modules []string `json:"modules,omitempty"`
+}
+
+// jwtClaimsKey is the context key of the verified claims of a client.
+type jwtClaimsKey struct{}
+
+// NewBearerToken creates a JWT signed with the given HS256 secret, granting
+// access to the given API modules (or all if none are given). If lifetime is
+// positive, the token expires after it. Otherwise the token never expires and
+// can only be revoked by rotating the secret.
+func NewBearerToken(secret []byte, modules []string, lifetime time.Duration) (string, error) {
+	now := time.Now()
+	claims := &jwtClaims{
+		StandardClaims: jwt.StandardClaims{IssuedAt: now.Unix()},
+		Modules:        modules,
+	}
+	if lifetime > 0 {
+		claims.ExpiresAt = now.Add(lifetime).Unix()
+	}
+	return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(secret)
+}
+
+// moduleAuthorized reports whether the client associated with the context may
+// access the given API module. Clients not authenticated by a token are limited
+// by the endpoint's module whitelist only.
+func moduleAuthorized(ctx context.Context, module string) bool {
+	claims, ok := ctx.Value(jwtClaimsKey{}).(*jwtClaims)
+	if !ok || len(claims.Modules) == 0 || module == MetadataApi {
+		return true
+	}
+	for _, allowed := range claims.Modules {
+		if allowed == module {
+			return true
+		}
+	}
+	return false
+}
+
+// handlerAuthorized reports whether the client associated with the context may
+// access the custom HTTP handlers mounted next to the API (e.g. GraphQL). Their
+// requests can't be attributed to API modules, so tokens scoped to modules are
+// refused.
+func handlerAuthorized(ctx context.Context) bool {
+	claims, ok := ctx.Value(jwtClaimsKey{}).(*jwtClaims)
+	return !ok || len(claims.Modules) == 0
+}
+
+// jwtHandler is a handler which authenticates HTTP requests and websocket
+// handshakes by a JWT bearer token signed with a shared HS256 secret.
+type jwtHandler struct {
+	secret []byte
+	next   http.Handler
+}
+
+// newJWTHandler wraps an HTTP handler with bearer token authentication. If no
+// secret is given, the handler is returned unchanged.
+func newJWTHandler(secret []byte, next http.Handler) http.Handler {
+	if len(secret) == 0 {
+		return next
+	}
+	return &jwtHandler{secret: secret, next: next}
+}
+
+// ServeHTTP verifies the bearer token of the request, forwarding its claims to
+// the wrapped handler if valid.
+func (h *jwtHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	auth := r.Header.Get("Authorization")
+	if !strings.HasPrefix(auth, "Bearer ") {
+		http.Error(w, "missing bearer token", http.StatusUnauthorized)
+		return
+	}
+	claims := new(jwtClaims)
+	_, err := jwt.ParseWithClaims(strings.TrimPrefix(auth, "Bearer "), claims, func(token *jwt.Token) (interface{}, error) {
+		if token.Method != jwt.SigningMethodHS256 {
+			return nil, fmt.Errorf("unexpected signing method %v", token.Header["alg"])
+		}
+		return h.secret, nil
+	})
+	if err != nil {
+		http.Error(w, fmt.Sprintf("invalid bearer token: %v", err), http.StatusUnauthorized)
+		return
+	}
+	h.next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), jwtClaimsKey{}, claims)))
+}
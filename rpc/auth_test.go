@@ -0,0 +1,161 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package rpc
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+var testJWTSecret = []byte("0123456789abcdef0123456789abcdef")
+
+// Tests that HTTP and websocket clients are authenticated by bearer tokens and
+// that the accessible modules are scoped by the token claims.
+func TestJWTAuthHTTP(t *testing.T) { testJWTAuth(t, "http") }
+func TestJWTAuthWS(t *testing.T)   { testJWTAuth(t, "ws") }
+
+func testJWTAuth(t *testing.T, transport string) {
+	srv := newTestServer("service", new(Service))
+	defer srv.Stop()
+
+	var server *httptest.Server
+	switch transport {
+	case "http":
+		server = httptest.NewServer(newJWTHandler(testJWTSecret, srv))
+	case "ws":
+		server = httptest.NewServer(newJWTHandler(testJWTSecret, srv.WebsocketHandler([]string{"*"})))
+	}
+	defer server.Close()
+
+	dial := func(opts ...DialOption) (*Client, error) {
+		if transport == "ws" {
+			return DialWebsocket(context.Background(), "ws://"+strings.TrimPrefix(server.URL, "http://"), "", opts...)
+		}
+		return DialHTTP(server.URL, opts...)
+	}
+	token := func(secret []byte, modules []string, lifetime time.Duration) DialOption {
+		token, err := NewBearerToken(secret, modules, lifetime)
+		if err != nil {
+			t.Fatalf("failed to create token: %v", err)
+		}
+		return WithBearerToken(token)
+	}
+	tests := []struct {
+		opts    []DialOption
+		modules map[string]string // nil if the connection is refused
+		echo    bool              // whether service_echo may be called
+	}{
+		{nil, nil, false},
+		{[]DialOption{token([]byte("wrong secret"), nil, 0)}, nil, false},
+		{[]DialOption{token(testJWTSecret, nil, -time.Hour)}, map[string]string{"rpc": "1.0", "service": "1.0"}, true},
+		{[]DialOption{token(testJWTSecret, nil, time.Hour)}, map[string]string{"rpc": "1.0", "service": "1.0"}, true},
+		{[]DialOption{token(testJWTSecret, []string{"service"}, time.Hour)}, map[string]string{"rpc": "1.0", "service": "1.0"}, true},
+		{[]DialOption{token(testJWTSecret, []string{"admin"}, time.Hour)}, map[string]string{"rpc": "1.0"}, false},
+	}
+	for i, tt := range tests {
+		client, err := dial(tt.opts...)
+		if err != nil {
+			if tt.modules != nil {
+				t.Errorf("test %d: failed to connect: %v", i, err)
+			}
+			continue
+		}
+		modules, err := client.SupportedModules()
+		switch {
+		case tt.modules == nil && err == nil:
+			t.Errorf("test %d: unauthenticated client accepted", i)
+		case tt.modules != nil && err != nil:
+			t.Errorf("test %d: failed to retrieve modules: %v", i, err)
+		case tt.modules != nil && !reflect.DeepEqual(modules, tt.modules):
+			t.Errorf("test %d: modules mismatch: have %v, want %v", i, modules, tt.modules)
+		}
+		if tt.modules != nil {
+			var result Result
+			if err := client.Call(&result, "service_echo", "hello", 10, &Args{"world"}); (err == nil) != tt.echo {
+				t.Errorf("test %d: echo permission mismatch: have error %v, want permitted %v", i, err, tt.echo)
+			}
+		}
+		client.Close()
+	}
+}
+
+// Tests that expired tokens are rejected.
+func TestJWTAuthExpired(t *testing.T) {
+	srv := newTestServer("service", new(Service))
+	defer srv.Stop()
+
+	server := httptest.NewServer(newJWTHandler(testJWTSecret, srv))
+	defer server.Close()
+
+	token, err := NewBearerToken(testJWTSecret, nil, time.Nanosecond)
+	if err != nil {
+		t.Fatalf("failed to create token: %v", err)
+	}
+	time.Sleep(time.Second)
+
+	client, err := DialHTTP(server.URL, WithBearerToken(token))
+	if err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+	defer client.Close()
+
+	if _, err := client.SupportedModules(); err == nil {
+		t.Errorf("expired token accepted")
+	}
+}
+
+// Tests that custom HTTP handlers mounted next to the API refuse tokens scoped
+// to API modules, since their requests can't be attributed to a module.
+func TestJWTAuthHTTPHandlers(t *testing.T) {
+	srv := newTestServer("service", new(Service))
+	defer srv.Stop()
+
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	server := httptest.NewServer(newJWTHandler(testJWTSecret, newHTTPMux(srv, map[string]http.Handler{"/graphql": handler})))
+	defer server.Close()
+
+	tests := []struct {
+		modules []string
+		status  int
+	}{
+		{nil, http.StatusOK},
+		{[]string{"eth"}, http.StatusForbidden},
+		{[]string{"net"}, http.StatusForbidden},
+	}
+	for i, tt := range tests {
+		token, err := NewBearerToken(testJWTSecret, tt.modules, time.Hour)
+		if err != nil {
+			t.Fatalf("test %d: failed to create token: %v", i, err)
+		}
+		req, _ := http.NewRequest(http.MethodPost, server.URL+"/graphql", strings.NewReader(`{}`))
+		req.Header.Set("Content-Type", contentType)
+		req.Header.Set("Authorization", "Bearer "+token)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("test %d: failed to post request: %v", i, err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != tt.status {
+			t.Errorf("test %d: status mismatch: have %d, want %d", i, resp.StatusCode, tt.status)
+		}
+	}
+}
@@ -24,7 +24,9 @@ import (
 	"errors"
 	"fmt"
 	"net"
+	"net/http"
 	"net/url"
+	"path"
 	"reflect"
 	"strconv"
 	"strings"
@@ -47,6 +49,9 @@ const (
 	defaultDialTimeout   = 10 * time.Second // used when dialing if the context has no deadline
 	defaultWriteTimeout  = 10 * time.Second // used for calls if the context has no deadline
 	subscribeTimeout     = 5 * time.Second  // overall timeout eth_subscribe, rpc_modules calls
+
+	// Redial delays of resilient clients
+	defaultMinBackoff = 100 * time.Millisecond // used if WithReconnect is given no minimum delay
 )
 
 const (
@@ -109,6 +114,7 @@ type Client struct {
 	idCounter   uint32
 	connectFunc func(ctx context.Context) (net.Conn, error)
 	isHTTP      bool
+	config      *dialConfig
 
 	// writeConn is only safe to access outside dispatch, with the
 	// write lock held. The write lock is taken by sending on
@@ -126,13 +132,15 @@ type Client struct {
 	sendDone    chan error                     // signals write completion, releases write lock
 	respWait    map[string]*requestOp          // active requests
 	subs        map[string]*ClientSubscription // active subscriptions
+	lostSubs    []*ClientSubscription          // subscriptions awaiting restoration after a reconnect
 }
 
 type requestOp struct {
-	ids  []json.RawMessage
-	err  error
-	resp chan *jsonrpcMessage // receives up to len(ids) responses
-	sub  *ClientSubscription  // only set for EthSubscribe requests
+	ids   []json.RawMessage
+	err   error
+	resp  chan *jsonrpcMessage // receives up to len(ids) responses
+	sub   *ClientSubscription  // only set for EthSubscribe requests
+	resub bool                 // set if sub is being restored after a reconnect
 }
 
 func (op *requestOp) wait(ctx context.Context) (*jsonrpcMessage, error) {
@@ -153,35 +161,114 @@ func (op *requestOp) wait(ctx context.Context) (*jsonrpcMessage, error) {
 //
 // For websocket connections, the origin is set to the local host name.
 //
-// The client reconnects automatically if the connection is lost.
-func Dial(rawurl string) (*Client, error) {
-	return DialContext(context.Background(), rawurl)
+// The client reconnects automatically if the connection is lost. Subscriptions end
+// with the connection unless the client is made resilient with WithReconnect.
+func Dial(rawurl string, opts ...DialOption) (*Client, error) {
+	return DialContext(context.Background(), rawurl, opts...)
 }
 
 // DialContext creates a new RPC client, just like Dial.
 //
 // The context is used to cancel or time out the initial connection establishment. It does
 // not affect subsequent interactions with the client.
-func DialContext(ctx context.Context, rawurl string) (*Client, error) {
+func DialContext(ctx context.Context, rawurl string, opts ...DialOption) (*Client, error) {
 	u, err := url.Parse(rawurl)
 	if err != nil {
 		return nil, err
 	}
 	switch u.Scheme {
 	case "http", "https":
-		return DialHTTP(rawurl)
+		return DialHTTP(rawurl, opts...)
 	case "ws", "wss":
-		return DialWebsocket(ctx, rawurl, "")
+		return DialWebsocket(ctx, rawurl, "", opts...)
 	case "stdio":
 		return DialStdIO(ctx)
 	case "":
-		return DialIPC(ctx, rawurl)
+		return DialIPC(ctx, rawurl, opts...)
 	default:
 		return nil, fmt.Errorf("no known transport for URL scheme %q", u.Scheme)
 	}
 }
 
-func newClient(initctx context.Context, connectFunc func(context.Context) (net.Conn, error)) (*Client, error) {
+// DialOption configures the connection of a client to an HTTP, websocket or IPC
+// server. Options not applicable to a transport are ignored.
+type DialOption func(*dialConfig)
+
+// dialConfig is the connection configuration assembled from the dial options.
+type dialConfig struct {
+	header http.Header // Extra headers sent with HTTP requests and websocket handshakes
+
+	reconnect  bool          // Whether lost websocket and IPC connections are redialed
+	minBackoff time.Duration // Delay before the first redial attempt
+	maxBackoff time.Duration // Upper bound of the exponentially growing redial delay
+
+	retries    int           // Number of times a failed idempotent call is attempted again
+	retryDelay time.Duration // Delay between two attempts of a call
+	idempotent []string      // Shell patterns of the methods which may be retried
+}
+
+// newDialConfig assembles the connection configuration from the dial options.
+func newDialConfig(opts []DialOption) *dialConfig {
+	cfg := &dialConfig{header: make(http.Header)}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+	return cfg
+}
+
+// WithBearerToken authenticates the client by the given JWT, as required by
+// servers configured with a JWT secret.
+func WithBearerToken(token string) DialOption {
+	return func(cfg *dialConfig) {
+		cfg.header.Set("Authorization", "Bearer "+token)
+	}
+}
+
+// WithReconnect makes websocket and IPC clients resilient to connection loss.
+// A lost connection is redialed in the background, starting after minBackoff
+// and doubling the delay up to maxBackoff while the server is unreachable.
+//
+// Active subscriptions are restored on the new connection. As notifications may
+// have been missed while the client was disconnected, restored subscriptions
+// signal a potential gap on their Gap channel.
+func WithReconnect(minBackoff, maxBackoff time.Duration) DialOption {
+	if minBackoff <= 0 {
+		minBackoff = defaultMinBackoff
+	}
+	if maxBackoff < minBackoff {
+		maxBackoff = minBackoff
+	}
+	return func(cfg *dialConfig) {
+		cfg.reconnect = true
+		cfg.minBackoff, cfg.maxBackoff = minBackoff, maxBackoff
+	}
+}
+
+// WithRetry makes the client attempt calls of the given methods up to retries
+// more times if they fail with an I/O error, waiting delay between attempts.
+// Errors returned by the server are never retried.
+//
+// Methods are matched with shell patterns (e.g. "eth_get*"). Only idempotent
+// methods should be listed, as a call may have been executed by the server even
+// though its response was lost. Batches are retried if all their methods match.
+func WithRetry(retries int, delay time.Duration, methods ...string) DialOption {
+	return func(cfg *dialConfig) {
+		cfg.retries, cfg.retryDelay = retries, delay
+		cfg.idempotent = append(cfg.idempotent, methods...)
+	}
+}
+
+// isIdempotent reports whether calls of method may be retried.
+func (cfg *dialConfig) isIdempotent(method string) bool {
+	for _, pattern := range cfg.idempotent {
+		if ok, _ := path.Match(pattern, method); ok {
+			return true
+		}
+	}
+	return false
+}
+
+func newClient(initctx context.Context, cfg *dialConfig, connectFunc func(context.Context) (net.Conn, error)) (*Client, error) {
 	conn, err := connectFunc(initctx)
 	if err != nil {
 		return nil, err
@@ -190,6 +277,7 @@ func newClient(initctx context.Context, connectFunc func(context.Context) (net.C
 	c := &Client{
 		writeConn:   conn,
 		isHTTP:      isHTTP,
+		config:      cfg,
 		connectFunc: connectFunc,
 		close:       make(chan struct{}),
 		closing:     make(chan struct{}),
@@ -255,27 +343,59 @@ func (c *Client) CallContext(ctx context.Context, result interface{}, method str
 	if err != nil {
 		return err
 	}
+	var resp *jsonrpcMessage
+	for attempt := 0; ; attempt++ {
+		if resp, err = c.call(ctx, msg); err == nil || !c.config.isIdempotent(method) || !c.shouldRetry(ctx, err, attempt) {
+			break
+		}
+		log.Debug("Retrying failed RPC call", "method", method, "attempt", attempt+1, "err", err)
+	}
+	switch {
+	case err != nil:
+		return err
+	case resp.Error != nil:
+		return resp.Error
+	case len(resp.Result) == 0:
+		return ErrNoResult
+	default:
+		return json.Unmarshal(resp.Result, &result)
+	}
+}
+
+// call sends a single request and waits for its response. Only I/O errors are
+// returned, errors reported by the server are left in the response.
+func (c *Client) call(ctx context.Context, msg *jsonrpcMessage) (*jsonrpcMessage, error) {
 	op := &requestOp{ids: []json.RawMessage{msg.ID}, resp: make(chan *jsonrpcMessage, 1)}
 
+	var err error
 	if c.isHTTP {
 		err = c.sendHTTP(ctx, op, msg)
 	} else {
 		err = c.send(ctx, op, msg)
 	}
 	if err != nil {
-		return err
+		return nil, err
 	}
-
 	// dispatch has accepted the request and will close the channel when it quits.
-	switch resp, err := op.wait(ctx); {
-	case err != nil:
-		return err
-	case resp.Error != nil:
-		return resp.Error
-	case len(resp.Result) == 0:
-		return ErrNoResult
-	default:
-		return json.Unmarshal(resp.Result, &result)
+	return op.wait(ctx)
+}
+
+// shouldRetry reports whether an idempotent request that failed with the given
+// I/O error should be attempted again, waiting for the retry delay if so.
+func (c *Client) shouldRetry(ctx context.Context, err error, attempt int) bool {
+	if attempt >= c.config.retries || err == ErrClientQuit || err == ctx.Err() {
+		return false
+	}
+	timer := time.NewTimer(c.config.retryDelay)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return true
+	case <-ctx.Done():
+		return false
+	case <-c.didClose:
+		return false
 	}
 }
 
@@ -301,6 +421,23 @@ func (c *Client) BatchCall(b []BatchElem) error {
 //
 // Note that batch calls may not be executed atomically on the server side.
 func (c *Client) BatchCallContext(ctx context.Context, b []BatchElem) error {
+	// Batches are only retried if all of their calls may be.
+	idempotent := len(b) > 0
+	for _, elem := range b {
+		idempotent = idempotent && c.config.isIdempotent(elem.Method)
+	}
+	for attempt := 0; ; attempt++ {
+		err := c.batchCall(ctx, b)
+		if err == nil || !idempotent || !c.shouldRetry(ctx, err, attempt) {
+			return err
+		}
+		log.Debug("Retrying failed RPC batch", "size", len(b), "attempt", attempt+1, "err", err)
+	}
+}
+
+// batchCall sends all given requests as a single batch and waits for the server
+// to return a response for all of them.
+func (c *Client) batchCall(ctx context.Context, b []BatchElem) error {
 	msgs := make([]*jsonrpcMessage, len(b))
 	op := &requestOp{
 		ids:  make([]json.RawMessage, len(b)),
@@ -394,7 +531,7 @@ func (c *Client) Subscribe(ctx context.Context, namespace string, channel interf
 	op := &requestOp{
 		ids:  []json.RawMessage{msg.ID},
 		resp: make(chan *jsonrpcMessage),
-		sub:  newClientSubscription(c, namespace, chanVal),
+		sub:  newClientSubscription(c, namespace, chanVal, msg.Params),
 	}
 
 	// Send the subscription request.
@@ -532,6 +669,10 @@ func (c *Client) dispatch(conn net.Conn) {
 
 		case err := <-c.readErr:
 			log.Debug("<-readErr", "err", err)
+			if c.config.reconnect {
+				c.suspendSubscriptions()
+				go c.redial(conn)
+			}
 			c.closeRequestOps(err)
 			conn.Close()
 			reading = false
@@ -542,11 +683,20 @@ func (c *Client) dispatch(conn net.Conn) {
 				// Wait for the previous read loop to exit. This is a rare case.
 				conn.Close()
 				<-c.readErr
+				if c.config.reconnect {
+					c.suspendSubscriptions()
+				}
 			}
 			go c.read(newconn)
 			reading = true
 			conn = newconn
 
+			// Restore the subscriptions of the lost connection.
+			if len(c.lostSubs) > 0 {
+				go c.resubscribe(c.lostSubs)
+				c.lostSubs = nil
+			}
+
 		// Send path.
 		case op := <-requestOpLock:
 			// Stop listening for further send ops until the current one is done.
@@ -564,6 +714,10 @@ func (c *Client) dispatch(conn net.Conn) {
 				for _, id := range lastOp.ids {
 					delete(c.respWait, string(id))
 				}
+				// Failed restorations are attempted again on the next connection.
+				if lastOp.resub {
+					c.lostSubs = append(c.lostSubs, lastOp.sub)
+				}
 			}
 			// Listen for send ops again.
 			requestOpLock = c.requestOp
@@ -585,11 +739,97 @@ func (c *Client) closeRequestOps(err error) {
 			close(op.resp)
 			didClose[op] = true
 		}
+		// Restorations interrupted by connection loss are attempted again.
+		if op.resub {
+			if c.config.reconnect && err != ErrClientQuit {
+				c.lostSubs = append(c.lostSubs, op.sub)
+			} else {
+				op.sub.quitWithError(err, false)
+			}
+		}
 	}
 	for id, sub := range c.subs {
 		delete(c.subs, id)
 		sub.quitWithError(err, false)
 	}
+	if err == ErrClientQuit {
+		for _, sub := range c.lostSubs {
+			sub.quitWithError(err, false)
+		}
+		c.lostSubs = nil
+	}
+}
+
+// suspendSubscriptions moves the active subscriptions of a lost connection to
+// the set awaiting restoration.
+func (c *Client) suspendSubscriptions() {
+	for id, sub := range c.subs {
+		delete(c.subs, id)
+		c.lostSubs = append(c.lostSubs, sub)
+	}
+}
+
+// redial reestablishes the connection of a resilient client after the read loop
+// of conn failed, backing off exponentially while the server is unreachable.
+func (c *Client) redial(conn net.Conn) {
+	delay := c.config.minBackoff
+	for {
+		select {
+		case <-time.After(delay):
+		case <-c.closing:
+			return
+		}
+		// Take the write lock like a send and install the new connection, unless a
+		// write has already done so in the meantime.
+		select {
+		case c.requestOp <- new(requestOp):
+		case <-c.closing:
+			return
+		}
+		var err error
+		if c.writeConn == conn || c.writeConn == nil {
+			ctx, cancel := context.WithTimeout(context.Background(), defaultDialTimeout)
+			err = c.reconnect(ctx)
+			cancel()
+		}
+		c.sendDone <- err
+		if err == nil || err == ErrClientQuit {
+			return
+		}
+		log.Debug("RPC redial failed", "delay", delay, "err", err)
+		if delay *= 2; delay > c.config.maxBackoff {
+			delay = c.config.maxBackoff
+		}
+	}
+}
+
+// resubscribe restores the given subscriptions on the new connection of a
+// resilient client. Subscriptions rejected by the server are ended with the
+// error, the ones interrupted by another connection loss are requeued by
+// dispatch.
+func (c *Client) resubscribe(subs []*ClientSubscription) {
+	for _, sub := range subs {
+		select {
+		case <-sub.quit:
+			continue // Unsubscribed while the connection was down
+		default:
+		}
+		msg := &jsonrpcMessage{Version: "2.0", ID: c.nextID(), Method: sub.namespace + subscribeMethodSuffix, Params: sub.params}
+		op := &requestOp{
+			ids:   []json.RawMessage{msg.ID},
+			resp:  make(chan *jsonrpcMessage),
+			sub:   sub,
+			resub: true,
+		}
+		ctx := context.Background()
+		if err := c.send(ctx, op, msg); err != nil {
+			if err == ErrClientQuit {
+				sub.quitWithError(err, false)
+			}
+			continue
+		}
+		op.wait(ctx)
+	}
 }
 
 func (c *Client) handleNotification(msg *jsonrpcMessage) {
@@ -628,11 +868,29 @@ func (c *Client) handleResponse(msg *jsonrpcMessage) {
 	defer close(op.resp)
 	if msg.Error != nil {
 		op.err = msg.Error
-		return
+	} else {
+		var subid string
+		if op.err = json.Unmarshal(msg.Result, &subid); op.err == nil {
+			op.sub.setID(subid)
+		}
 	}
-	if op.err = json.Unmarshal(msg.Result, &op.sub.subid); op.err == nil {
+	switch {
+	case op.err != nil && op.resub:
+		op.sub.quitWithError(op.err, false)
+	case op.err != nil:
+	case !op.resub:
 		go op.sub.start()
-		c.subs[op.sub.subid] = op.sub
+		c.subs[op.sub.id()] = op.sub
+	default:
+		// The subscription was restored after a reconnect, its forwarding loop is
+		// still running. Drop it again if it ended while being restored.
+		select {
+		case <-op.sub.quit:
+			go op.sub.requestUnsubscribe()
+		default:
+			c.subs[op.sub.id()] = op.sub
+			op.sub.signalGap()
+		}
 	}
 }
 
@@ -675,28 +933,66 @@ type ClientSubscription struct {
 	etype     reflect.Type
 	channel   reflect.Value
 	namespace string
-	subid     string
+	params    json.RawMessage // arguments of the subscribe call, for restoring it
 	in        chan json.RawMessage
 
+	subid  string     // server side identifier, changes when restored
+	idLock sync.Mutex // protects subid
+
 	quitOnce sync.Once     // ensures quit is closed once
 	quit     chan struct{} // quit is closed when the subscription exits
 	errOnce  sync.Once     // ensures err is closed once
 	err      chan error
+	gap      chan struct{} // signaled when the subscription is restored after a reconnect
 }
 
-func newClientSubscription(c *Client, namespace string, channel reflect.Value) *ClientSubscription {
+func newClientSubscription(c *Client, namespace string, channel reflect.Value, params json.RawMessage) *ClientSubscription {
 	sub := &ClientSubscription{
 		client:    c,
 		namespace: namespace,
+		params:    params,
 		etype:     channel.Type().Elem(),
 		channel:   channel,
 		quit:      make(chan struct{}),
 		err:       make(chan error, 1),
+		gap:       make(chan struct{}, 1),
 		in:        make(chan json.RawMessage),
 	}
 	return sub
 }
 
+// id returns the identifier the server assigned to the subscription, which
+// changes when it is restored after a reconnect.
+func (sub *ClientSubscription) id() string {
+	sub.idLock.Lock()
+	defer sub.idLock.Unlock()
+	return sub.subid
+}
+
+func (sub *ClientSubscription) setID(id string) {
+	sub.idLock.Lock()
+	defer sub.idLock.Unlock()
+	sub.subid = id
+}
+
+// Gap returns a channel which is signaled when the subscription was restored
+// after the client reconnected to the server, see WithReconnect. Notifications
+// sent by the server while the client was disconnected are lost, so consumers
+// should reconcile their state with the server after receiving a signal.
+//
+// Multiple restorations not yet received from the channel are coalesced into a
+// single signal.
+func (sub *ClientSubscription) Gap() <-chan struct{} {
+	return sub.gap
+}
+
+func (sub *ClientSubscription) signalGap() {
+	select {
+	case sub.gap <- struct{}{}:
+	default:
+	}
+}
+
 // Err returns the subscription error channel. The intended use of Err is to schedule
 // resubscription when the client connection is closed unexpectedly.
 //
@@ -794,5 +1090,5 @@ func (sub *ClientSubscription) unmarshal(result json.RawMessage) (interface{}, e
 
 func (sub *ClientSubscription) requestUnsubscribe() error {
 	var result interface{}
-	return sub.client.Call(&result, sub.namespace+unsubscribeMethodSuffix, sub.subid)
+	return sub.client.Call(&result, sub.namespace+unsubscribeMethodSuffix, sub.id())
 }
@@ -49,6 +49,29 @@ func TestClientRequest(t *testing.T) {
 	}
 }
 
+func TestClientErrorData(t *testing.T) {
+	server := newTestServer("service", new(Service))
+	defer server.Stop()
+	client := DialInProc(server)
+	defer client.Close()
+
+	var resp string
+	err := client.Call(&resp, "service_returnError")
+	if err == nil {
+		t.Fatal("expected error")
+	}
+	if code := err.(Error).ErrorCode(); code != 444 {
+		t.Errorf("wrong error code %d, want 444", code)
+	}
+	de, ok := err.(DataError)
+	if !ok {
+		t.Fatalf("client did not return DataError, got %T", err)
+	}
+	if data := de.ErrorData(); !reflect.DeepEqual(data, "custom error data") {
+		t.Errorf("wrong error data %#v", data)
+	}
+}
+
 func TestClientBatchRequest(t *testing.T) {
 	server := newTestServer("service", new(Service))
 	defer server.Stop()
@@ -509,6 +532,148 @@ func TestClientReconnect(t *testing.T) {
 	}
 }
 
+func TestClientResubscribe(t *testing.T) {
+	server := newTestServer("eth", new(NotificationTestService))
+	defer server.Stop()
+
+	// Serve websocket connections which can be dropped on demand.
+	hs := httptest.NewUnstartedServer(server.WebsocketHandler([]string{"*"}))
+	listener := &killableListener{Listener: hs.Listener}
+	hs.Listener = listener
+	hs.Start()
+	defer hs.Close()
+
+	client, err := Dial("ws://"+hs.Listener.Addr().String(), WithReconnect(10*time.Millisecond, 50*time.Millisecond))
+	if err != nil {
+		t.Fatal("can't dial", err)
+	}
+	defer client.Close()
+
+	nc := make(chan int)
+	sub, err := client.EthSubscribe(context.Background(), nc, "someSubscription", 2, 0)
+	if err != nil {
+		t.Fatal("can't subscribe:", err)
+	}
+	defer sub.Unsubscribe()
+
+	// Each subscription made on the server delivers the same notifications.
+	receive := func() {
+		for i := 0; i < 2; i++ {
+			select {
+			case val := <-nc:
+				if val != i {
+					t.Fatalf("value mismatch: got %d, want %d", val, i)
+				}
+			case err := <-sub.Err():
+				t.Fatalf("subscription ended: %v", err)
+			case <-time.After(5 * time.Second):
+				t.Fatalf("notification %d not received", i)
+			}
+		}
+	}
+	receive()
+
+	// Drop the connection a few times and check that the subscription is restored.
+	for i := 0; i < 3; i++ {
+		listener.killAll()
+		select {
+		case <-sub.Gap():
+		case err := <-sub.Err():
+			t.Fatalf("subscription ended after disconnect: %v", err)
+		case <-time.After(5 * time.Second):
+			t.Fatalf("subscription not restored after disconnect %d", i)
+		}
+		receive()
+	}
+	var resp int
+	if err := client.Call(&resp, "eth_echo", 42); err != nil || resp != 42 {
+		t.Fatalf("call after reconnect failed: %d, %v", resp, err)
+	}
+}
+
+func TestClientRetry(t *testing.T) {
+	server := newTestServer("service", new(Service))
+	defer server.Stop()
+
+	// Serve HTTP requests, failing a configurable number of them.
+	var (
+		lock     sync.Mutex
+		failures int
+		requests int
+	)
+	hs := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lock.Lock()
+		requests++
+		fail := failures > 0
+		if fail {
+			failures--
+		}
+		lock.Unlock()
+
+		if fail {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		server.ServeHTTP(w, r)
+	}))
+	defer hs.Close()
+
+	client, err := DialHTTP(hs.URL, WithRetry(2, time.Millisecond, "service_echo*"))
+	if err != nil {
+		t.Fatal("can't dial", err)
+	}
+	defer client.Close()
+
+	tests := []struct {
+		fail     int
+		method   string
+		requests int
+		ok       bool
+	}{
+		{fail: 2, method: "service_echo", requests: 3, ok: true},
+		{fail: 3, method: "service_echo", requests: 3, ok: false},
+		{fail: 1, method: "service_echoWithCtx", requests: 2, ok: true},
+		{fail: 1, method: "service_sleep", requests: 1, ok: false},
+	}
+	for i, tt := range tests {
+		lock.Lock()
+		failures, requests = tt.fail, 0
+		lock.Unlock()
+
+		var resp Result
+		err := client.Call(&resp, tt.method, "hello", 10, nil)
+		if (err == nil) != tt.ok {
+			t.Errorf("test %d: call error mismatch: have %v, want success %v", i, err, tt.ok)
+		}
+		lock.Lock()
+		if requests != tt.requests {
+			t.Errorf("test %d: request count mismatch: have %d, want %d", i, requests, tt.requests)
+		}
+		lock.Unlock()
+	}
+	// Batches are only retried if all methods are idempotent.
+	for i, methods := range [][]string{{"service_echo", "service_echoWithCtx"}, {"service_echo", "service_sleep"}} {
+		lock.Lock()
+		failures, requests = 1, 0
+		lock.Unlock()
+
+		batch := make([]BatchElem, len(methods))
+		for j, method := range methods {
+			batch[j] = BatchElem{Method: method, Args: []interface{}{"hello", 10, nil}, Result: new(Result)}
+		}
+		err := client.BatchCall(batch)
+		want := 2 - i
+		if (err == nil) != (want == 2) {
+			t.Errorf("batch %d: error mismatch: have %v", i, err)
+		}
+		lock.Lock()
+		if requests != want {
+			t.Errorf("batch %d: request count mismatch: have %d, want %d", i, requests, want)
+		}
+		lock.Unlock()
+	}
+}
+
 func newTestServer(serviceName string, service interface{}) *Server {
 	server := NewServer()
 	if err := server.RegisterName(serviceName, service); err != nil {
@@ -589,3 +754,30 @@ func (l *flakeyListener) Accept() (net.Conn, error) {
 	}
 	return c, err
 }
+
+// killableListener tracks the accepted connections so they can be dropped.
+type killableListener struct {
+	net.Listener
+	lock  sync.Mutex
+	conns []net.Conn
+}
+
+func (l *killableListener) Accept() (net.Conn, error) {
+	c, err := l.Listener.Accept()
+	if err == nil {
+		l.lock.Lock()
+		l.conns = append(l.conns, c)
+		l.lock.Unlock()
+	}
+	return c, err
+}
+
+func (l *killableListener) killAll() {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	for _, c := range l.conns {
+		c.Close()
+	}
+	l.conns = nil
+}
@@ -18,42 +18,87 @@ package rpc
 
 import (
 	"net"
+	"net/http"
 
 	"github.com/athofficial/go-ath/log"
 )
 
 // StartHTTPEndpoint starts the HTTP RPC endpoint, configured with cors/vhosts/modules
-func StartHTTPEndpoint(endpoint string, apis []API, modules []string, cors []string, vhosts []string, timeouts HTTPTimeouts) (net.Listener, *Server, error) {
-	// Generate the whitelist based on the allowed modules
-	whitelist := make(map[string]bool)
-	for _, module := range modules {
-		whitelist[module] = true
+// and the access policy enforced on the clients. If a JWT secret is given, clients
+// must authenticate with a bearer token signed by it.
+func StartHTTPEndpoint(endpoint string, apis []API, handlers map[string]http.Handler, modules []string, cors []string, vhosts []string, timeouts HTTPTimeouts, policy *Policy, jwtSecret []byte) (net.Listener, *Server, error) {
+	// Register all the APIs exposed by the services
+	handler, err := newEndpointServer("HTTP", apis, modules, false, policy)
+	if err != nil {
+		return nil, nil, err
+	}
+	// All APIs registered, start the HTTP listener
+	listener, err := net.Listen("tcp", endpoint)
+	if err != nil {
+		return nil, nil, err
 	}
+	go NewHTTPServer(cors, vhosts, timeouts, newJWTHandler(jwtSecret, newHTTPMux(handler, handlers))).Serve(listener)
+	return listener, handler, err
+}
+
+// StartWSEndpoint starts a websocket endpoint, enforcing the given access policy
+// on the clients. If a JWT secret is given, clients must authenticate with a
+// bearer token signed by it.
+func StartWSEndpoint(endpoint string, apis []API, modules []string, wsOrigins []string, exposeAll bool, policy *Policy, jwtSecret []byte) (net.Listener, *Server, error) {
 	// Register all the APIs exposed by the services
-	handler := NewServer()
-	for _, api := range apis {
-		if whitelist[api.Namespace] || (len(whitelist) == 0 && api.Public) {
-			if err := handler.RegisterName(api.Namespace, api.Service); err != nil {
-				return nil, nil, err
-			}
-			log.Debug("HTTP registered", "namespace", api.Namespace)
-		}
+	handler, err := newEndpointServer("WebSocket", apis, modules, exposeAll, policy)
+	if err != nil {
+		return nil, nil, err
 	}
 	// All APIs registered, start the HTTP listener
-	var (
-		listener net.Listener
-		err      error
-	)
-	if listener, err = net.Listen("tcp", endpoint); err != nil {
+	listener, err := net.Listen("tcp", endpoint)
+	if err != nil {
 		return nil, nil, err
 	}
-	go NewHTTPServer(cors, vhosts, timeouts, handler).Serve(listener)
+	go (&http.Server{Handler: newJWTHandler(jwtSecret, handler.WebsocketHandler(wsOrigins))}).Serve(listener)
 	return listener, handler, err
 }
 
-// StartWSEndpoint starts a websocket endpoint
-func StartWSEndpoint(endpoint string, apis []API, modules []string, wsOrigins []string, exposeAll bool) (net.Listener, *Server, error) {
+// StartSharedEndpoint starts an HTTP RPC endpoint which also serves websocket
+// clients upgrading their connections on the same port.
+//
+// If isolateWS is set, upgraded connections are served by a dedicated server,
+// exposing the websocket modules and checking the websocket origins, which is
+// returned next to the HTTP one. Otherwise they are served by the HTTP server,
+// with the CORS domains as the permitted origins, and no websocket server is
+// returned.
+func StartSharedEndpoint(endpoint string, apis []API, handlers map[string]http.Handler, modules []string, cors []string, vhosts []string, timeouts HTTPTimeouts, wsModules []string, wsOrigins []string, wsExposeAll bool, isolateWS bool, policy *Policy, jwtSecret []byte) (net.Listener, *Server, *Server, error) {
+	// Register all the APIs exposed by the services
+	handler, err := newEndpointServer("HTTP", apis, modules, false, policy)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	var (
+		wsHandler *Server
+		ws        = handler.WebsocketHandler(cors)
+	)
+	if isolateWS {
+		if wsHandler, err = newEndpointServer("WebSocket", apis, wsModules, wsExposeAll, policy); err != nil {
+			return nil, nil, nil, err
+		}
+		ws = wsHandler.WebsocketHandler(wsOrigins)
+	}
+	// All APIs registered, start the HTTP listener
+	listener, err := net.Listen("tcp", endpoint)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	server := NewHTTPServer(cors, vhosts, timeouts, newJWTHandler(jwtSecret, newHTTPMux(handler, handlers)))
+	server.Handler = newUpgradeHandler(server.Handler, newJWTHandler(jwtSecret, ws))
+
+	go server.Serve(listener)
+	return listener, handler, wsHandler, err
+}
 
+// newEndpointServer creates an RPC server exposing the whitelisted API modules,
+// or all public ones if no whitelist is given. If exposeAll is set, all modules
+// are exposed regardless of the whitelist.
+func newEndpointServer(kind string, apis []API, modules []string, exposeAll bool, policy *Policy) (*Server, error) {
 	// Generate the whitelist based on the allowed modules
 	whitelist := make(map[string]bool)
 	for _, module := range modules {
@@ -61,25 +106,16 @@ func StartWSEndpoint(endpoint string, apis []API, modules []string, wsOrigins []
 	}
 	// Register all the APIs exposed by the services
 	handler := NewServer()
+	handler.SetPolicy(policy)
 	for _, api := range apis {
 		if exposeAll || whitelist[api.Namespace] || (len(whitelist) == 0 && api.Public) {
 			if err := handler.RegisterName(api.Namespace, api.Service); err != nil {
-				return nil, nil, err
+				return nil, err
 			}
-			log.Debug("WebSocket registered", "service", api.Service, "namespace", api.Namespace)
+			log.Debug(kind+" registered", "namespace", api.Namespace)
 		}
 	}
-	// All APIs registered, start the HTTP listener
-	var (
-		listener net.Listener
-		err      error
-	)
-	if listener, err = net.Listen("tcp", endpoint); err != nil {
-		return nil, nil, err
-	}
-	go NewWSServer(wsOrigins, handler).Serve(listener)
-	return listener, handler, err
-
+	return handler, nil
 }
 
 // StartIPCEndpoint starts an IPC endpoint.
@@ -64,3 +64,17 @@ type shutdownError struct{}
 func (e *shutdownError) ErrorCode() int { return -32000 }
 
 func (e *shutdownError) Error() string { return "server is shutting down" }
+
+// request is for a method rejected by the server's access policy
+type methodDeniedError struct{ method string }
+
+func (e *methodDeniedError) ErrorCode() int { return -32004 }
+
+func (e *methodDeniedError) Error() string { return fmt.Sprintf("method %s is not allowed", e.method) }
+
+// request exceeds a rate or size limit of the server's access policy
+type limitExceededError struct{ message string }
+
+func (e *limitExceededError) ErrorCode() int { return -32005 }
+
+func (e *limitExceededError) Error() string { return e.message }
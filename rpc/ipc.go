@@ -45,8 +45,8 @@ func (srv *Server) ServeListener(l net.Listener) error {
 //
 // The context is used for the initial connection establishment. It does not
 // affect subsequent interactions with the client.
-func DialIPC(ctx context.Context, endpoint string) (*Client, error) {
-	return newClient(ctx, func(ctx context.Context) (net.Conn, error) {
+func DialIPC(ctx context.Context, endpoint string, opts ...DialOption) (*Client, error) {
+	return newClient(ctx, newDialConfig(opts), func(ctx context.Context) (net.Conn, error) {
 		return newIPCConnection(ctx, endpoint)
 	})
 }
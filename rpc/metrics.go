@@ -0,0 +1,155 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package rpc
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/athofficial/go-ath/log"
+	"github.com/athofficial/go-ath/metrics"
+	"github.com/athofficial/go-ath/swarm/tracing"
+	opentracing "github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/ext"
+)
+
+var (
+	rpcRequestMeter = metrics.NewRegisteredMeter("rpc/requests", nil) // Calls served by all RPC servers
+	rpcFailureMeter = metrics.NewRegisteredMeter("rpc/failures", nil) // Calls returning an error
+	rpcSlowMeter    = metrics.NewRegisteredMeter("rpc/slow", nil)     // Calls exceeding the slow threshold
+	rpcTimer        = metrics.NewRegisteredTimer("rpc/duration", nil) // Execution time of all calls
+)
+
+// slowRequestThreshold is the execution time in nanoseconds above which calls
+// are logged as slow. Zero disables the slow request log.
+var slowRequestThreshold int64
+
+// SetSlowRequestThreshold sets the execution time above which RPC calls are logged
+// with their method, parameter hash, duration and caller. Zero disables the log.
+func SetSlowRequestThreshold(threshold time.Duration) {
+	atomic.StoreInt64(&slowRequestThreshold, int64(threshold))
+}
+
+// spanContextKey is the context key of the tracing span propagated by a client.
+type spanContextKey struct{}
+
+// withRemoteSpan attaches the tracing span propagated in the headers of an HTTP
+// request to the context, if tracing is enabled.
+func withRemoteSpan(ctx context.Context, r *http.Request) context.Context {
+	if !tracing.Enabled {
+		return ctx
+	}
+	sctx, err := opentracing.GlobalTracer().Extract(opentracing.HTTPHeaders, opentracing.HTTPHeadersCarrier(r.Header))
+	if err != nil {
+		return ctx
+	}
+	return context.WithValue(ctx, spanContextKey{}, sctx)
+}
+
+// callTrace tracks the execution of a single RPC call for the metrics, the slow
+// request log and the tracing span.
+type callTrace struct {
+	method string
+	params interface{}
+	start  time.Time
+	span   opentracing.Span
+}
+
+// startCall starts tracking an RPC call, returning the context the method should
+// be executed with.
+func startCall(ctx context.Context, method string, params interface{}) (context.Context, *callTrace) {
+	trace := &callTrace{method: method, params: params, start: time.Now()}
+	if tracing.Enabled {
+		var opts []opentracing.StartSpanOption
+		if sctx, ok := ctx.Value(spanContextKey{}).(opentracing.SpanContext); ok {
+			opts = append(opts, opentracing.ChildOf(sctx))
+		} else if parent := opentracing.SpanFromContext(ctx); parent != nil {
+			opts = append(opts, opentracing.ChildOf(parent.Context()))
+		}
+		trace.span = opentracing.GlobalTracer().StartSpan("rpc."+method, append(opts, ext.SpanKindRPCServer)...)
+		trace.span.SetTag("rpc.method", method)
+		ctx = opentracing.ContextWithSpan(ctx, trace.span)
+	}
+	return ctx, trace
+}
+
+// finish records the outcome of the call. The error is nil if it succeeded.
+func (t *callTrace) finish(ctx context.Context, err error) {
+	elapsed := time.Since(t.start)
+
+	// Update the aggregate and the per method metrics
+	rpcRequestMeter.Mark(1)
+	rpcTimer.Update(elapsed)
+	if err != nil {
+		rpcFailureMeter.Mark(1)
+	}
+	if metrics.Enabled {
+		metrics.GetOrRegisterTimer("rpc/methods/"+t.method+"/duration", nil).Update(elapsed)
+		if err != nil {
+			metrics.GetOrRegisterMeter("rpc/methods/"+t.method+"/failures", nil).Mark(1)
+		}
+	}
+	// Log the call if it took too long
+	if threshold := time.Duration(atomic.LoadInt64(&slowRequestThreshold)); threshold > 0 && elapsed > threshold {
+		rpcSlowMeter.Mark(1)
+		log.Warn("Slow RPC request", "method", t.method, "params", paramsHash(t.params), "elapsed", elapsed, "caller", callerFromContext(ctx), "err", err)
+	}
+	// Close the tracing span
+	if t.span != nil {
+		if err != nil {
+			ext.Error.Set(t.span, true)
+			t.span.LogKV("error", err.Error())
+		}
+		t.span.Finish()
+	}
+}
+
+// paramsHash returns a short digest identifying the parameters of a call without
+// leaking them into the logs.
+func paramsHash(params interface{}) string {
+	var blob []byte
+	switch params := params.(type) {
+	case nil:
+		return ""
+	case json.RawMessage:
+		blob = params
+	default:
+		blob = []byte(fmt.Sprintf("%v", params))
+	}
+	hash := sha256.Sum256(blob)
+	return fmt.Sprintf("%x", hash[:8])
+}
+
+// callerFromContext returns a description of the client issuing a call.
+func callerFromContext(ctx context.Context) string {
+	client, ok := ctx.Value(clientInfoKey{}).(*clientInfo)
+	switch {
+	case !ok:
+		return "local"
+	case client.apiKey != "":
+		// Identify the key without revealing it
+		hash := sha256.Sum256([]byte(client.apiKey))
+		return fmt.Sprintf("%s (key %x)", client.ip, hash[:4])
+	default:
+		return client.ip
+	}
+}
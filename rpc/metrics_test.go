@@ -0,0 +1,115 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package rpc
+
+import (
+	"encoding/json"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/athofficial/go-ath/log"
+	"github.com/athofficial/go-ath/metrics"
+)
+
+// Tests that calls are recorded in the per method metrics and that slow ones are
+// logged with their method, parameter hash and caller.
+func TestCallInstrumentation(t *testing.T) {
+	// Enable the metrics and capture the slow request log
+	defer func(enabled bool) { metrics.Enabled = enabled }(metrics.Enabled)
+	metrics.Enabled = true
+
+	SetSlowRequestThreshold(50 * time.Millisecond)
+	defer SetSlowRequestThreshold(0)
+
+	var (
+		lock    sync.Mutex
+		records []*log.Record
+	)
+	defer log.Root().SetHandler(log.Root().GetHandler())
+	log.Root().SetHandler(log.FuncHandler(func(r *log.Record) error {
+		lock.Lock()
+		defer lock.Unlock()
+		if r.Msg == "Slow RPC request" {
+			records = append(records, r)
+		}
+		return nil
+	}))
+	// Issue a fast, a slow and a failing call
+	server := newTestServer("service", new(Service))
+	defer server.Stop()
+
+	client := DialInProc(server)
+	defer client.Close()
+
+	if err := client.Call(nil, "service_noArgsRets"); err != nil {
+		t.Fatalf("fast call failed: %v", err)
+	}
+	if err := client.Call(nil, "service_sleep", 100*time.Millisecond); err != nil {
+		t.Fatalf("slow call failed: %v", err)
+	}
+	if err := client.Call(nil, "service_returnError"); err == nil {
+		t.Fatalf("failing call succeeded")
+	}
+	// Check the per method metrics
+	for method, calls := range map[string]int64{"service_noArgsRets": 1, "service_sleep": 1, "service_returnError": 1} {
+		timer, ok := metrics.DefaultRegistry.Get("rpc/methods/" + method + "/duration").(metrics.Timer)
+		if !ok || timer.Count() != calls {
+			t.Errorf("%s: call count mismatch: have %v, want %d", method, timer, calls)
+		}
+	}
+	if meter, ok := metrics.DefaultRegistry.Get("rpc/methods/service_returnError/failures").(metrics.Meter); !ok || meter.Count() != 1 {
+		t.Errorf("failure count mismatch: have %v, want 1", meter)
+	}
+	if metrics.DefaultRegistry.Get("rpc/methods/service_noArgsRets/failures") != nil {
+		t.Errorf("failures recorded for succeeding method")
+	}
+	// Check the slow request log
+	lock.Lock()
+	defer lock.Unlock()
+
+	if len(records) != 1 {
+		t.Fatalf("slow request count mismatch: have %d, want 1", len(records))
+	}
+	ctx := make(map[interface{}]interface{})
+	for i := 0; i < len(records[0].Ctx); i += 2 {
+		ctx[records[0].Ctx[i]] = records[0].Ctx[i+1]
+	}
+	if ctx["method"] != "service_sleep" {
+		t.Errorf("method mismatch: have %v, want service_sleep", ctx["method"])
+	}
+	if ctx["caller"] != "local" {
+		t.Errorf("caller mismatch: have %v, want local", ctx["caller"])
+	}
+	if ctx["params"] == "" {
+		t.Errorf("parameter hash missing")
+	}
+}
+
+// Tests that parameter hashes identify the parameters.
+func TestParamsHash(t *testing.T) {
+	a, b := paramsHash(json.RawMessage(`[1]`)), paramsHash(json.RawMessage(`[2]`))
+	if a == b {
+		t.Errorf("distinct parameters hashed equal: %s", a)
+	}
+	if a != paramsHash(json.RawMessage(`[1]`)) {
+		t.Errorf("parameter hash not deterministic")
+	}
+	if paramsHash(nil) != "" {
+		t.Errorf("missing parameters hashed")
+	}
+}
@@ -0,0 +1,296 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package rpc
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/athofficial/go-ath/common/ratelimit"
+)
+
+// apiKeyHeader is the HTTP header clients authenticate their API key with.
+const apiKeyHeader = "X-API-Key"
+
+// errInvalidAPIKey is returned if a client authenticates with an unknown API key.
+var errInvalidAPIKey = errors.New("invalid API key")
+
+// PolicyConfig is the access policy of an RPC endpoint exposed to remote clients.
+// The zero value imposes no restrictions.
+type PolicyConfig struct {
+	// Allow is the list of methods which may be called. Entries are matched with
+	// shell patterns (e.g. "eth_*" or "debug_traceTransaction"). If empty, all
+	// exposed methods are allowed. Requests to custom HTTP handlers mounted next
+	// to the API count as calls of a method named after their path, e.g. the
+	// GraphQL endpoint "/graphql" as "graphql", so they have to be allowed as a
+	// whole.
+	Allow []string `toml:",omitempty"`
+
+	// Deny is the list of methods which may not be called, overriding Allow.
+	Deny []string `toml:",omitempty"`
+
+	// IPRate is the number of requests per second permitted for a client IP,
+	// with bursts of up to IPBurst requests. Zero disables the limit.
+	IPRate  float64 `toml:",omitempty"`
+	IPBurst int     `toml:",omitempty"`
+
+	// APIKeys is the list of keys clients may authenticate with via the X-API-Key
+	// HTTP header. Requests with a known key are subject to the per key limits
+	// instead of the per IP ones, requests with an unknown key are refused.
+	APIKeys []string `toml:",omitempty"`
+
+	// KeyRate is the number of requests per second permitted for an API key,
+	// with bursts of up to KeyBurst requests. Zero disables the limit.
+	KeyRate  float64 `toml:",omitempty"`
+	KeyBurst int     `toml:",omitempty"`
+
+	// MethodRates limits the number of requests per second a single client (IP
+	// or API key) may issue to individual expensive methods (e.g. eth_getLogs).
+	MethodRates map[string]float64 `toml:",omitempty"`
+
+	// MaxBatchSize is the maximum number of requests in a batch. Zero disables
+	// the limit.
+	MaxBatchSize int `toml:",omitempty"`
+
+	// MaxResponseSize is the maximum size in bytes of a single call result. Zero
+	// disables the limit.
+	MaxResponseSize int `toml:",omitempty"`
+}
+
+// Policy enforces a PolicyConfig on the requests of remote clients. A nil policy
+// permits everything.
+type Policy struct {
+	allow []string
+	deny  []string
+	keys  map[string]bool
+
+	ipLimits     *ratelimit.Limiter
+	keyLimits    *ratelimit.Limiter
+	methodLimits map[string]*ratelimit.Limiter
+
+	maxBatch    int
+	maxResponse int
+}
+
+// NewPolicy creates an access policy from the given configuration.
+func NewPolicy(config PolicyConfig) (*Policy, error) {
+	for _, pattern := range append(append([]string{}, config.Allow...), config.Deny...) {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("invalid method pattern %q: %v", pattern, err)
+		}
+	}
+	p := &Policy{
+		allow:        config.Allow,
+		deny:         config.Deny,
+		keys:         make(map[string]bool),
+		ipLimits:     ratelimit.New(config.IPRate, config.IPBurst),
+		keyLimits:    ratelimit.New(config.KeyRate, config.KeyBurst),
+		methodLimits: make(map[string]*ratelimit.Limiter),
+		maxBatch:     config.MaxBatchSize,
+		maxResponse:  config.MaxResponseSize,
+	}
+	for _, key := range config.APIKeys {
+		p.keys[key] = true
+	}
+	for method, rate := range config.MethodRates {
+		if limiter := ratelimit.New(rate, 0); limiter != nil {
+			p.methodLimits[method] = limiter
+		}
+	}
+	return p, nil
+}
+
+// clientInfo identifies the remote client a request originates from.
+type clientInfo struct {
+	ip     string // IP address of the client
+	apiKey string // Authenticated API key, empty if none
+}
+
+// clientInfoKey is the context key of the client information.
+type clientInfoKey struct{}
+
+// clientFromRequest extracts the client information of an HTTP request and
+// verifies its API key, if any.
+func (p *Policy) clientFromRequest(r *http.Request) (*clientInfo, error) {
+	client := &clientInfo{ip: r.RemoteAddr}
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		client.ip = host
+	}
+	if key := r.Header.Get(apiKeyHeader); key != "" && p != nil {
+		if !p.keys[key] {
+			return nil, errInvalidAPIKey
+		}
+		client.apiKey = key
+	}
+	return client, nil
+}
+
+// checkBatch verifies that a batch of the given size is permitted.
+func (p *Policy) checkBatch(size int) Error {
+	if p != nil && p.maxBatch > 0 && size > p.maxBatch {
+		return &limitExceededError{fmt.Sprintf("batch too large (%d>%d)", size, p.maxBatch)}
+	}
+	return nil
+}
+
+// checkCall verifies that the client associated with the context may call the
+// given method, consuming its rate allowance if so.
+func (p *Policy) checkCall(ctx context.Context, method string) Error {
+	if p == nil {
+		return nil
+	}
+	if !p.allowed(method) {
+		return &methodDeniedError{method}
+	}
+	client, ok := ctx.Value(clientInfoKey{}).(*clientInfo)
+	if !ok {
+		return nil
+	}
+	id := client.ip
+	if client.apiKey != "" {
+		id = "key:" + client.apiKey
+	}
+	now := time.Now()
+	if limiter := p.methodLimits[method]; !limiter.Allow(id, now) {
+		return &limitExceededError{fmt.Sprintf("rate limit of %s exceeded", method)}
+	}
+	limiter := p.ipLimits
+	if client.apiKey != "" {
+		limiter = p.keyLimits
+	}
+	if !limiter.Allow(id, now) {
+		return &limitExceededError{"request rate limit exceeded"}
+	}
+	return nil
+}
+
+// checkResponse verifies that a call result of the given size may be returned.
+func (p *Policy) checkResponse(size int) Error {
+	if p != nil && p.maxResponse > 0 && size > p.maxResponse {
+		return &limitExceededError{fmt.Sprintf("response too large (%d>%d)", size, p.maxResponse)}
+	}
+	return nil
+}
+
+// allowed reports whether the method is permitted by the allow and deny lists.
+func (p *Policy) allowed(method string) bool {
+	for _, pattern := range p.deny {
+		if ok, _ := path.Match(pattern, method); ok {
+			return false
+		}
+	}
+	if len(p.allow) == 0 {
+		return true
+	}
+	for _, pattern := range p.allow {
+		if ok, _ := path.Match(pattern, method); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// policyHandler enforces the access policy of an RPC server on a custom HTTP
+// handler mounted next to its API. Each request counts as a single call of the
+// method named after the handler's path. Clients authenticated by bearer tokens
+// scoped to API modules are refused.
+type policyHandler struct {
+	srv    *Server
+	method string
+	next   http.Handler
+}
+
+// newPolicyHandler wraps a custom HTTP handler mounted on the given path.
+func newPolicyHandler(srv *Server, path string, next http.Handler) http.Handler {
+	return &policyHandler{
+		srv:    srv,
+		method: strings.Replace(strings.Trim(path, "/"), "/", "_", -1),
+		next:   next,
+	}
+}
+
+// ServeHTTP implements http.Handler, validating and throttling the request before
+// passing it on to the wrapped handler.
+func (h *policyHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !handlerAuthorized(r.Context()) {
+		http.Error(w, "bearer token not authorized for "+r.URL.Path, http.StatusForbidden)
+		return
+	}
+	// Plain page loads carry no payload, everything else is validated like the
+	// JSON-RPC requests
+	if r.Method != http.MethodGet {
+		if code, err := validateRequest(r); err != nil {
+			http.Error(w, err.Error(), code)
+			return
+		}
+	}
+	r.Body = http.MaxBytesReader(w, r.Body, maxRequestContentLength)
+
+	policy := h.srv.policy
+	if policy == nil {
+		h.next.ServeHTTP(w, r)
+		return
+	}
+	client, err := policy.clientFromRequest(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+	r = r.WithContext(context.WithValue(r.Context(), clientInfoKey{}, client))
+	if err := policy.checkCall(r.Context(), h.method); err != nil {
+		code := http.StatusTooManyRequests
+		if _, ok := err.(*methodDeniedError); ok {
+			code = http.StatusForbidden
+		}
+		http.Error(w, err.Error(), code)
+		return
+	}
+	if policy.maxResponse <= 0 {
+		h.next.ServeHTTP(w, r)
+		return
+	}
+	// Buffer the response to check its size before sending anything
+	res := &bufferedResponse{header: make(http.Header), status: http.StatusOK}
+	h.next.ServeHTTP(res, r)
+	if err := policy.checkResponse(res.body.Len()); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	for key, values := range res.header {
+		w.Header()[key] = values
+	}
+	w.WriteHeader(res.status)
+	w.Write(res.body.Bytes())
+}
+
+// bufferedResponse is an http.ResponseWriter collecting a response in memory.
+type bufferedResponse struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func (r *bufferedResponse) Header() http.Header         { return r.header }
+func (r *bufferedResponse) WriteHeader(status int)      { r.status = status }
+func (r *bufferedResponse) Write(b []byte) (int, error) { return r.body.Write(b) }
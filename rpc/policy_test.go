@@ -0,0 +1,238 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package rpc
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// newPolicyTestServer starts an HTTP RPC server enforcing the given policy.
+func newPolicyTestServer(t *testing.T, config PolicyConfig) *httptest.Server {
+	policy, err := NewPolicy(config)
+	if err != nil {
+		t.Fatalf("failed to create policy: %v", err)
+	}
+	srv := newTestServer("service", new(Service))
+	srv.SetPolicy(policy)
+	return httptest.NewServer(srv)
+}
+
+// policyCall posts a raw JSON-RPC message to the server, returning the HTTP
+// status and the error codes of the responses (zero for successful calls).
+func policyCall(t *testing.T, server *httptest.Server, apiKey string, body string) (int, []int) {
+	req, _ := http.NewRequest(http.MethodPost, server.URL, strings.NewReader(body))
+	req.Header.Set("Content-Type", contentType)
+	if apiKey != "" {
+		req.Header.Set(apiKeyHeader, apiKey)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("failed to post request: %v", err)
+	}
+	defer resp.Body.Close()
+
+	blob, _ := ioutil.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK {
+		return resp.StatusCode, nil
+	}
+	var msgs []jsonrpcMessage
+	if strings.HasPrefix(body, "[") {
+		if err := json.Unmarshal(blob, &msgs); err != nil {
+			t.Fatalf("failed to decode batch response %s: %v", blob, err)
+		}
+	} else {
+		var msg jsonrpcMessage
+		if err := json.Unmarshal(blob, &msg); err != nil {
+			t.Fatalf("failed to decode response %s: %v", blob, err)
+		}
+		msgs = append(msgs, msg)
+	}
+	codes := make([]int, len(msgs))
+	for i, msg := range msgs {
+		if msg.Error != nil {
+			codes[i] = msg.Error.Code
+		}
+	}
+	return resp.StatusCode, codes
+}
+
+// request formats a single JSON-RPC request calling the given method.
+func request(method string, params string) string {
+	return fmt.Sprintf(`{"jsonrpc":"2.0","id":1,"method":"%s","params":[%s]}`, method, params)
+}
+
+// Tests that methods are permitted or rejected according to the allow and deny
+// lists of the policy.
+func TestPolicyMethodFilter(t *testing.T) {
+	server := newPolicyTestServer(t, PolicyConfig{
+		Allow: []string{"service_*"},
+		Deny:  []string{"service_sleep"},
+	})
+	defer server.Close()
+
+	tests := []struct {
+		method string
+		params string
+		code   int
+	}{
+		{"service_echo", `"x", 1`, 0},
+		{"service_noArgsRets", ``, 0},
+		{"service_sleep", `1`, -32004},
+		{"rpc_modules", ``, -32004},
+	}
+	for _, tt := range tests {
+		if _, codes := policyCall(t, server, "", request(tt.method, tt.params)); len(codes) != 1 || codes[0] != tt.code {
+			t.Errorf("%s: error code mismatch: have %v, want %d", tt.method, codes, tt.code)
+		}
+	}
+	if _, err := NewPolicy(PolicyConfig{Deny: []string{"eth_["}}); err == nil {
+		t.Errorf("invalid method pattern accepted")
+	}
+}
+
+// Tests that requests are throttled per client IP, per API key and per method.
+func TestPolicyRateLimits(t *testing.T) {
+	server := newPolicyTestServer(t, PolicyConfig{
+		IPRate:      0.001,
+		IPBurst:     2,
+		APIKeys:     []string{"secret"},
+		KeyRate:     1000,
+		MethodRates: map[string]float64{"service_echo": 0.001},
+	})
+	defer server.Close()
+
+	tests := []struct {
+		key    string
+		method string
+		status int
+		code   int
+	}{
+		{"", "service_echo", http.StatusOK, 0},
+		{"", "service_echo", http.StatusOK, -32005},        // method limit of the IP
+		{"", "service_noArgsRets", http.StatusOK, 0},       // other methods unaffected
+		{"", "service_noArgsRets", http.StatusOK, -32005},  // IP limit
+		{"secret", "service_noArgsRets", http.StatusOK, 0}, // key has its own allowance
+		{"secret", "service_echo", http.StatusOK, 0},       // method limits are per key too
+		{"secret", "service_echo", http.StatusOK, -32005},  // method limit of the key
+		{"unknown", "service_noArgsRets", http.StatusUnauthorized, 0},
+	}
+	for i, tt := range tests {
+		params := ``
+		if tt.method == "service_echo" {
+			params = `"x", 1`
+		}
+		status, codes := policyCall(t, server, tt.key, request(tt.method, params))
+		if status != tt.status {
+			t.Errorf("test %d: status mismatch: have %d, want %d", i, status, tt.status)
+			continue
+		}
+		if status == http.StatusOK && (len(codes) != 1 || codes[0] != tt.code) {
+			t.Errorf("test %d: error code mismatch: have %v, want %d", i, codes, tt.code)
+		}
+	}
+}
+
+// Tests that oversized batches and responses are rejected.
+func TestPolicySizeLimits(t *testing.T) {
+	server := newPolicyTestServer(t, PolicyConfig{
+		MaxBatchSize:    2,
+		MaxResponseSize: 64,
+	})
+	defer server.Close()
+
+	call := request("service_noArgsRets", ``)
+	if _, codes := policyCall(t, server, "", "["+call+","+call+"]"); len(codes) != 2 || codes[0] != 0 || codes[1] != 0 {
+		t.Errorf("permitted batch rejected: %v", codes)
+	}
+	if _, codes := policyCall(t, server, "", "["+call+","+call+","+call+"]"); len(codes) != 3 || codes[0] != -32005 || codes[2] != -32005 {
+		t.Errorf("oversized batch error mismatch: have %v, want all -32005", codes)
+	}
+	if _, codes := policyCall(t, server, "", request("service_echo", `"short", 1`)); len(codes) != 1 || codes[0] != 0 {
+		t.Errorf("small response rejected: %v", codes)
+	}
+	if _, codes := policyCall(t, server, "", request("service_echo", `"`+strings.Repeat("x", 64)+`", 1`)); len(codes) != 1 || codes[0] != -32005 {
+		t.Errorf("oversized response error mismatch: have %v, want -32005", codes)
+	}
+}
+
+// Tests that custom HTTP handlers mounted next to the API are subject to the
+// access policy, counting as calls of the method named after their path.
+func TestPolicyHTTPHandlers(t *testing.T) {
+	policy, err := NewPolicy(PolicyConfig{
+		Allow:           []string{"service_*", "graphql"},
+		IPRate:          0.001,
+		IPBurst:         2,
+		APIKeys:         []string{"secret"},
+		MaxResponseSize: 64,
+	})
+	if err != nil {
+		t.Fatalf("failed to create policy: %v", err)
+	}
+	srv := newTestServer("service", new(Service))
+	srv.SetPolicy(policy)
+
+	reply := func(w http.ResponseWriter, r *http.Request) {
+		blob, _ := ioutil.ReadAll(r.Body)
+		w.Write(blob)
+	}
+	server := httptest.NewServer(newHTTPMux(srv, map[string]http.Handler{
+		"/graphql":    http.HandlerFunc(reply),
+		"/graphql/ui": http.HandlerFunc(reply),
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		path   string
+		key    string
+		ctype  string
+		body   string
+		status int
+	}{
+		{"/graphql", "", contentType, `{}`, http.StatusOK},
+		{"/graphql", "", "text/plain", `{}`, http.StatusUnsupportedMediaType},
+		{"/graphql", "", contentType, strings.Repeat("x", 65), http.StatusInternalServerError}, // response too large
+		{"/graphql/ui", "secret", contentType, `{}`, http.StatusForbidden},                    // not allowed
+		{"/graphql", "unknown", contentType, `{}`, http.StatusUnauthorized},
+		{"/graphql", "", contentType, `{}`, http.StatusTooManyRequests}, // IP limit
+		{"/graphql", "secret", contentType, `{}`, http.StatusOK},        // key has its own allowance
+	}
+	for i, tt := range tests {
+		req, _ := http.NewRequest(http.MethodPost, server.URL+tt.path, strings.NewReader(tt.body))
+		req.Header.Set("Content-Type", tt.ctype)
+		if tt.key != "" {
+			req.Header.Set(apiKeyHeader, tt.key)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("test %d: failed to post request: %v", i, err)
+		}
+		blob, _ := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+
+		if resp.StatusCode != tt.status {
+			t.Errorf("test %d: status mismatch: have %d, want %d: %s", i, resp.StatusCode, tt.status, blob)
+		} else if tt.status == http.StatusOK && string(blob) != tt.body {
+			t.Errorf("test %d: response mismatch: have %q, want %q", i, blob, tt.body)
+		}
+	}
+}
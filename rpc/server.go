@@ -18,6 +18,7 @@ package rpc
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"reflect"
 	"runtime"
@@ -64,11 +65,14 @@ type RPCService struct {
 	server *Server
 }
 
-// Modules returns the list of RPC services with their version number
-func (s *RPCService) Modules() map[string]string {
+// Modules returns the list of RPC services with their version number, limited
+// to the ones accessible by the caller.
+func (s *RPCService) Modules(ctx context.Context) map[string]string {
 	modules := make(map[string]string)
 	for name := range s.server.services {
-		modules[name] = "1.0"
+		if moduleAuthorized(ctx, name) {
+			modules[name] = "1.0"
+		}
 	}
 	return modules
 }
@@ -116,6 +120,12 @@ func (s *Server) RegisterName(name string, rcvr interface{}) error {
 	return nil
 }
 
+// SetPolicy sets the access policy enforced on the requests of the server. It
+// must be called before the server starts serving requests.
+func (s *Server) SetPolicy(policy *Policy) {
+	s.policy = policy
+}
+
 // serveRequest will reads requests from the codec, calls the RPC callback and
 // writes the response to the given codec.
 //
@@ -157,7 +167,7 @@ func (s *Server) serveRequest(ctx context.Context, codec ServerCodec, singleShot
 
 	// test if the server is ordered to stop
 	for atomic.LoadInt32(&s.run) == 1 {
-		reqs, batch, err := s.readRequest(codec)
+		reqs, batch, err := s.readRequest(ctx, codec)
 		if err != nil {
 			// If a parsing error occurred, send an error
 			if err.Error() != "EOF" {
@@ -212,8 +222,14 @@ func (s *Server) serveRequest(ctx context.Context, codec ServerCodec, singleShot
 // response back using the given codec. It will block until the codec is closed or the server is
 // stopped. In either case the codec is closed.
 func (s *Server) ServeCodec(codec ServerCodec, options CodecOption) {
+	s.serveCodec(context.Background(), codec, options)
+}
+
+// serveCodec is the context aware version of ServeCodec, used by transports
+// passing connection specific data to the request handlers.
+func (s *Server) serveCodec(ctx context.Context, codec ServerCodec, options CodecOption) {
 	defer codec.Close()
-	s.serveRequest(context.Background(), codec, false, options)
+	s.serveRequest(ctx, codec, false, options)
 }
 
 // ServeSingleRequest reads and processes a single RPC request from the given codec. It will not
@@ -274,10 +290,20 @@ func (s *Server) handle(ctx context.Context, codec ServerCodec, req *serverReque
 		return codec.CreateErrorResponse(&req.id, &invalidParamsError{"Expected subscription id as first argument"}), nil
 	}
 
+	ctx, trace := startCall(ctx, req.method, req.params)
+	response, callback, err := s.call(ctx, codec, req)
+	trace.finish(ctx, err)
+
+	return response, callback
+}
+
+// call executes the RPC method of a request, returning the response, the callback
+// to run after it is sent and the error the call failed with, if any.
+func (s *Server) call(ctx context.Context, codec ServerCodec, req *serverRequest) (interface{}, func(), error) {
 	if req.callb.isSubscribe {
 		subid, err := s.createSubscription(ctx, codec, req)
 		if err != nil {
-			return codec.CreateErrorResponse(&req.id, &callbackError{err.Error()}), nil
+			return codec.CreateErrorResponse(&req.id, &callbackError{err.Error()}), nil, err
 		}
 
 		// active the subscription after the sub id was successfully sent to the client
@@ -286,7 +312,7 @@ func (s *Server) handle(ctx context.Context, codec ServerCodec, req *serverReque
 			notifier.activate(subid, req.svcname)
 		}
 
-		return codec.CreateResponse(req.id, subid), activateSub
+		return codec.CreateResponse(req.id, subid), activateSub, nil
 	}
 
 	// regular RPC call, prepare arguments
@@ -294,7 +320,7 @@ func (s *Server) handle(ctx context.Context, codec ServerCodec, req *serverReque
 		rpcErr := &invalidParamsError{fmt.Sprintf("%s%s%s expects %d parameters, got %d",
 			req.svcname, serviceMethodSeparator, req.callb.method.Name,
 			len(req.callb.argTypes), len(req.args))}
-		return codec.CreateErrorResponse(&req.id, rpcErr), nil
+		return codec.CreateErrorResponse(&req.id, rpcErr), nil, rpcErr
 	}
 
 	arguments := []reflect.Value{req.callb.rcvr}
@@ -308,16 +334,41 @@ func (s *Server) handle(ctx context.Context, codec ServerCodec, req *serverReque
 	// execute RPC method and return result
 	reply := req.callb.method.Func.Call(arguments)
 	if len(reply) == 0 {
-		return codec.CreateResponse(req.id, nil), nil
+		return codec.CreateResponse(req.id, nil), nil, nil
 	}
 	if req.callb.errPos >= 0 { // test if method returned an error
 		if !reply[req.callb.errPos].IsNil() {
 			e := reply[req.callb.errPos].Interface().(error)
-			res := codec.CreateErrorResponse(&req.id, &callbackError{e.Error()})
-			return res, nil
+			return createCallbackErrorResponse(codec, &req.id, e), nil, e
+		}
+	}
+	result := reply[0].Interface()
+	if s.policy != nil && s.policy.maxResponse > 0 {
+		// Encode the result upfront to enforce the response size limit
+		blob, err := json.Marshal(result)
+		if err != nil {
+			return codec.CreateErrorResponse(&req.id, &callbackError{err.Error()}), nil, err
+		}
+		if err := s.policy.checkResponse(len(blob)); err != nil {
+			return codec.CreateErrorResponse(&req.id, err), nil, err
 		}
+		result = json.RawMessage(blob)
 	}
-	return codec.CreateResponse(req.id, reply[0].Interface()), nil
+	return codec.CreateResponse(req.id, result), nil, nil
+}
+
+// createCallbackErrorResponse converts an error returned by an RPC method into
+// an error response. Errors carrying their own code and data are preserved,
+// all others are reported as generic callback errors.
+func createCallbackErrorResponse(codec ServerCodec, id interface{}, err error) interface{} {
+	rpcErr, ok := err.(Error)
+	if !ok {
+		rpcErr = &callbackError{err.Error()}
+	}
+	if de, ok := err.(DataError); ok {
+		return codec.CreateErrorResponseWithInfo(id, rpcErr, de.ErrorData())
+	}
+	return codec.CreateErrorResponse(id, rpcErr)
 }
 
 // exec executes the given request and writes the result back using the codec.
@@ -371,7 +422,7 @@ func (s *Server) execBatch(ctx context.Context, codec ServerCodec, requests []*s
 // readRequest requests the next (batch) request from the codec. It will return the collection
 // of requests, an indication if the request was a batch, the invalid request identifier and an
 // error when the request could not be read/parsed.
-func (s *Server) readRequest(codec ServerCodec) ([]*serverRequest, bool, Error) {
+func (s *Server) readRequest(ctx context.Context, codec ServerCodec) ([]*serverRequest, bool, Error) {
 	reqs, batch, err := codec.ReadRequestHeaders()
 	if err != nil {
 		return nil, batch, err
@@ -379,6 +430,16 @@ func (s *Server) readRequest(codec ServerCodec) ([]*serverRequest, bool, Error)
 
 	requests := make([]*serverRequest, len(reqs))
 
+	// reject the whole batch if it's larger than permitted
+	if batch {
+		if err := s.policy.checkBatch(len(reqs)); err != nil {
+			for i, r := range reqs {
+				requests[i] = &serverRequest{id: r.id, err: err}
+			}
+			return requests, batch, nil
+		}
+	}
+
 	// verify requests
 	for i, r := range reqs {
 		var ok bool
@@ -389,6 +450,13 @@ func (s *Server) readRequest(codec ServerCodec) ([]*serverRequest, bool, Error)
 			continue
 		}
 
+		if !r.isPubSub || !strings.HasSuffix(r.method, unsubscribeMethodSuffix) { // unsubscribing is always permitted
+			if err := s.policy.checkCall(ctx, r.fullName()); err != nil {
+				requests[i] = &serverRequest{id: r.id, err: err}
+				continue
+			}
+		}
+
 		if r.isPubSub && strings.HasSuffix(r.method, unsubscribeMethodSuffix) {
 			requests[i] = &serverRequest{id: r.id, isUnsubscribe: true}
 			argTypes := []reflect.Type{reflect.TypeOf("")} // expect subscription id as first arg
@@ -400,14 +468,14 @@ func (s *Server) readRequest(codec ServerCodec) ([]*serverRequest, bool, Error)
 			continue
 		}
 
-		if svc, ok = s.services[r.service]; !ok { // rpc method isn't available
+		if svc, ok = s.services[r.service]; !ok || !moduleAuthorized(ctx, r.service) { // rpc method isn't available
 			requests[i] = &serverRequest{id: r.id, err: &methodNotFoundError{r.service, r.method}}
 			continue
 		}
 
 		if r.isPubSub { // eth_subscribe, r.method contains the subscription method name
 			if callb, ok := svc.subscriptions[r.method]; ok {
-				requests[i] = &serverRequest{id: r.id, svcname: svc.name, callb: callb}
+				requests[i] = &serverRequest{id: r.id, svcname: svc.name, method: r.fullName(), callb: callb, params: r.params}
 				if r.params != nil && len(callb.argTypes) > 0 {
 					argTypes := []reflect.Type{reflect.TypeOf("")}
 					argTypes = append(argTypes, callb.argTypes...)
@@ -424,7 +492,7 @@ func (s *Server) readRequest(codec ServerCodec) ([]*serverRequest, bool, Error)
 		}
 
 		if callb, ok := svc.callbacks[r.method]; ok { // lookup RPC method
-			requests[i] = &serverRequest{id: r.id, svcname: svc.name, callb: callb}
+			requests[i] = &serverRequest{id: r.id, svcname: svc.name, method: r.fullName(), callb: callb, params: r.params}
 			if r.params != nil && len(callb.argTypes) > 0 {
 				if args, err := codec.ParseRequestArguments(callb.argTypes, r.params); err == nil {
 					requests[i].args = args
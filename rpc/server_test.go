@@ -59,6 +59,16 @@ func (s *Service) Rets() (string, error) {
 	return "", nil
 }
 
+type dataError struct{}
+
+func (e *dataError) Error() string          { return "custom error" }
+func (e *dataError) ErrorCode() int         { return 444 }
+func (e *dataError) ErrorData() interface{} { return "custom error data" }
+
+func (s *Service) ReturnError() (string, error) {
+	return "", &dataError{}
+}
+
 func (s *Service) InvalidRets1() (error, string) {
 	return nil, ""
 }
@@ -92,8 +102,8 @@ func TestServerRegisterName(t *testing.T) {
 		t.Fatalf("Expected service calc to be registered")
 	}
 
-	if len(svc.callbacks) != 5 {
-		t.Errorf("Expected 5 callbacks for service 'calc', got %d", len(svc.callbacks))
+	if len(svc.callbacks) != 6 {
+		t.Errorf("Expected 6 callbacks for service 'calc', got %d", len(svc.callbacks))
 	}
 
 	if len(svc.subscriptions) != 1 {
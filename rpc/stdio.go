@@ -26,7 +26,7 @@ import (
 
 // DialStdIO creates a client on stdin/stdout.
 func DialStdIO(ctx context.Context) (*Client, error) {
-	return newClient(ctx, func(_ context.Context) (net.Conn, error) {
+	return newClient(ctx, newDialConfig(nil), func(_ context.Context) (net.Conn, error) {
 		return stdioConn{}, nil
 	})
 }
@@ -57,8 +57,10 @@ type service struct {
 type serverRequest struct {
 	id            interface{}
 	svcname       string
+	method        string // full method name, for instrumentation
 	callb         *callback
 	args          []reflect.Value
+	params        interface{} // raw parameters, for instrumentation
 	isUnsubscribe bool
 	err           Error
 }
@@ -70,6 +72,7 @@ type subscriptions map[string]*callback  // collection of subscription callbacks
 // Server represents a RPC server
 type Server struct {
 	services serviceRegistry
+	policy   *Policy // Access policy enforced on remote clients, nil if unrestricted
 
 	run      int32
 	codecsMu sync.Mutex
@@ -86,12 +89,28 @@ type rpcRequest struct {
 	err      Error // invalid batch element
 }
 
+// fullName returns the name of the method called by the request, which is the
+// subscription method for subscription requests.
+func (r *rpcRequest) fullName() string {
+	if r.isPubSub {
+		return r.service + subscribeMethodSuffix
+	}
+	return r.service + serviceMethodSeparator + r.method
+}
+
 // Error wraps RPC errors, which contain an error code in addition to the message.
 type Error interface {
 	Error() string  // returns the message
 	ErrorCode() int // returns the code
 }
 
+// DataError is implemented by errors that carry additional data to be returned
+// to the caller in the "data" field of the JSON-RPC error object.
+type DataError interface {
+	Error() string          // returns the message
+	ErrorData() interface{} // returns the error data
+}
+
 // ServerCodec implements reading, parsing and writing RPC messages for the server side of
 // a RPC session. Implementations must be go-routine safe since the codec can be called in
 // multiple go-routines concurrently.
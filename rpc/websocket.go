@@ -57,9 +57,27 @@ var websocketJSONCodec = websocket.Codec{
 // allowedOrigins should be a comma-separated list of allowed origin URLs.
 // To allow connections with any origin, pass "*".
 func (srv *Server) WebsocketHandler(allowedOrigins []string) http.Handler {
+	validateOrigin := wsHandshakeValidator(allowedOrigins)
+
 	return websocket.Server{
-		Handshake: wsHandshakeValidator(allowedOrigins),
+		Handshake: func(cfg *websocket.Config, req *http.Request) error {
+			if err := validateOrigin(cfg, req); err != nil {
+				return err
+			}
+			_, err := srv.policy.clientFromRequest(req)
+			return err
+		},
 		Handler: func(conn *websocket.Conn) {
+			// Attach the client identity for the access policy, verified in the handshake
+			client, _ := srv.policy.clientFromRequest(conn.Request())
+			ctx := context.WithValue(context.Background(), clientInfoKey{}, client)
+			ctx = withRemoteSpan(ctx, conn.Request())
+
+			// Attach the claims of the bearer token, if authenticated by one
+			if claims, ok := conn.Request().Context().Value(jwtClaimsKey{}).(*jwtClaims); ok {
+				ctx = context.WithValue(ctx, jwtClaimsKey{}, claims)
+			}
+
 			// Create a custom encode/decode pair to enforce payload size and number encoding
 			conn.MaxPayloadBytes = maxRequestContentLength
 
@@ -69,11 +87,40 @@ func (srv *Server) WebsocketHandler(allowedOrigins []string) http.Handler {
 			decoder := func(v interface{}) error {
 				return websocketJSONCodec.Receive(conn, v)
 			}
-			srv.ServeCodec(NewCodec(conn, encoder, decoder), OptionMethodInvocation|OptionSubscriptions)
+			srv.serveCodec(ctx, NewCodec(conn, encoder, decoder), OptionMethodInvocation|OptionSubscriptions)
 		},
 	}
 }
 
+// upgradeHandler serves websocket upgrade requests and plain HTTP requests
+// arriving on the same port by distinct handlers.
+type upgradeHandler struct {
+	http http.Handler // Handler of plain HTTP requests
+	ws   http.Handler // Handler of websocket upgrade requests
+}
+
+// newUpgradeHandler creates a handler dispatching websocket upgrade requests to
+// wsHandler and all other requests to httpHandler.
+func newUpgradeHandler(httpHandler, wsHandler http.Handler) http.Handler {
+	return &upgradeHandler{http: httpHandler, ws: wsHandler}
+}
+
+// ServeHTTP dispatches the request based on whether it upgrades to websocket.
+func (h *upgradeHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if isWebsocket(r) {
+		h.ws.ServeHTTP(w, r)
+		return
+	}
+	h.http.ServeHTTP(w, r)
+}
+
+// isWebsocket reports whether the request asks to upgrade the connection to the
+// websocket protocol.
+func isWebsocket(r *http.Request) bool {
+	return strings.ToLower(r.Header.Get("Upgrade")) == "websocket" &&
+		strings.Contains(strings.ToLower(r.Header.Get("Connection")), "upgrade")
+}
+
 // NewWSServer creates a new websocket RPC server around an API provider.
 //
 // Deprecated: use Server.WebsocketHandler
@@ -149,13 +196,17 @@ func wsGetConfig(endpoint, origin string) (*websocket.Config, error) {
 //
 // The context is used for the initial connection establishment. It does not
 // affect subsequent interactions with the client.
-func DialWebsocket(ctx context.Context, endpoint, origin string) (*Client, error) {
+func DialWebsocket(ctx context.Context, endpoint, origin string, opts ...DialOption) (*Client, error) {
 	config, err := wsGetConfig(endpoint, origin)
 	if err != nil {
 		return nil, err
 	}
+	cfg := newDialConfig(opts)
+	for key, values := range cfg.header {
+		config.Header[key] = values
+	}
 
-	return newClient(ctx, func(ctx context.Context) (net.Conn, error) {
+	return newClient(ctx, cfg, func(ctx context.Context) (net.Conn, error) {
 		return wsDialContext(ctx, config)
 	})
 }
@@ -16,7 +16,12 @@
 
 package rpc
 
-import "testing"
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+)
 
 func TestWSGetConfigNoAuth(t *testing.T) {
 	config, err := wsGetConfig("ws://example.com:1234", "")
@@ -52,3 +57,65 @@ func TestWSGetConfigWithBasicAuth(t *testing.T) {
 		t.Fail()
 	}
 }
+
+// Tests that websocket connections upgraded on an HTTP endpoint are served the
+// HTTP modules, or the websocket ones if isolated.
+func TestSharedEndpoint(t *testing.T) {
+	for _, isolate := range []bool{false, true} {
+		testSharedEndpoint(t, isolate)
+	}
+}
+
+func testSharedEndpoint(t *testing.T, isolate bool) {
+	apis := []API{
+		{Namespace: "service", Version: "1.0", Service: new(Service), Public: true},
+		{Namespace: "wsonly", Version: "1.0", Service: new(Service)},
+	}
+	timeouts := HTTPTimeouts{ReadTimeout: time.Second, WriteTimeout: time.Second, IdleTimeout: time.Second}
+
+	listener, handler, wsHandler, err := StartSharedEndpoint("127.0.0.1:0", apis, nil, nil, []string{"http://cors"}, []string{"*"}, timeouts,
+		[]string{"wsonly"}, []string{"http://origin"}, false, isolate, nil, nil)
+	if err != nil {
+		t.Fatalf("isolate %v: failed to start endpoint: %v", isolate, err)
+	}
+	defer listener.Close()
+	defer handler.Stop()
+	if isolate != (wsHandler != nil) {
+		t.Fatalf("isolate %v: websocket server mismatch: have %v", isolate, wsHandler)
+	}
+	if wsHandler != nil {
+		defer wsHandler.Stop()
+	}
+	// Plain HTTP requests must be served the HTTP modules
+	client, err := DialHTTP("http://" + listener.Addr().String())
+	if err != nil {
+		t.Fatalf("isolate %v: failed to dial HTTP: %v", isolate, err)
+	}
+	defer client.Close()
+
+	if modules, err := client.SupportedModules(); err != nil || !reflect.DeepEqual(modules, map[string]string{"rpc": "1.0", "service": "1.0"}) {
+		t.Errorf("isolate %v: HTTP modules mismatch: have %v, err %v", isolate, modules, err)
+	}
+	// Upgraded connections must be checked against the relevant origins
+	origin, rejected, want := "http://cors", "http://origin", map[string]string{"rpc": "1.0", "service": "1.0"}
+	if isolate {
+		origin, rejected, want = "http://origin", "http://cors", map[string]string{"rpc": "1.0", "wsonly": "1.0"}
+	}
+	if _, err := DialWebsocket(context.Background(), "ws://"+listener.Addr().String(), rejected); err == nil {
+		t.Errorf("isolate %v: websocket origin %s accepted", isolate, rejected)
+	}
+	ws, err := DialWebsocket(context.Background(), "ws://"+listener.Addr().String(), origin)
+	if err != nil {
+		t.Fatalf("isolate %v: failed to dial websocket: %v", isolate, err)
+	}
+	defer ws.Close()
+
+	if modules, err := ws.SupportedModules(); err != nil || !reflect.DeepEqual(modules, want) {
+		t.Errorf("isolate %v: websocket modules mismatch: have %v, want %v, err %v", isolate, modules, want, err)
+	}
+	// Upgraded connections must outlive the timeouts of the HTTP server
+	time.Sleep(1500 * time.Millisecond)
+	if _, err := ws.SupportedModules(); err != nil {
+		t.Errorf("isolate %v: websocket closed by HTTP timeouts: %v", isolate, err)
+	}
+}
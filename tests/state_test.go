@@ -76,6 +76,7 @@ var testVMConfig = func() vm.Config {
 	vmconfig := vm.Config{}
 	flag.StringVar(&vmconfig.EVMInterpreter, utils.EVMInterpreterFlag.Name, utils.EVMInterpreterFlag.Value, utils.EVMInterpreterFlag.Usage)
 	flag.StringVar(&vmconfig.EWASMInterpreter, utils.EWASMInterpreterFlag.Name, utils.EWASMInterpreterFlag.Value, utils.EWASMInterpreterFlag.Usage)
+	flag.BoolVar(&vmconfig.BlockAnalysis, utils.EVMBlockAnalysisFlag.Name, false, utils.EVMBlockAnalysisFlag.Usage)
 	flag.Parse()
 	return vmconfig
 }()
@@ -20,6 +20,7 @@ import (
 	"bytes"
 	crand "crypto/rand"
 	mrand "math/rand"
+	"sort"
 	"testing"
 	"time"
 
@@ -143,6 +144,205 @@ func TestMissingKeyProof(t *testing.T) {
 	}
 }
 
+type entrySlice []*kv
+
+func (p entrySlice) Len() int           { return len(p) }
+func (p entrySlice) Less(i, j int) bool { return bytes.Compare(p[i].k, p[j].k) < 0 }
+func (p entrySlice) Swap(i, j int)      { p[i], p[j] = p[j], p[i] }
+
+// sortedEntries returns the entries of a random trie sorted by key.
+func sortedEntries(vals map[string]*kv) []*kv {
+	var entries entrySlice
+	for _, kv := range vals {
+		entries = append(entries, kv)
+	}
+	sort.Sort(entries)
+	return entries
+}
+
+// TestRangeProof tests normal range proofs with both edge proofs being
+// existent proofs.
+func TestRangeProof(t *testing.T) {
+	trie, vals := randomTrie(4096)
+	entries := sortedEntries(vals)
+
+	for i := 0; i < 500; i++ {
+		start := mrand.Intn(len(entries))
+		end := mrand.Intn(len(entries)-start) + start + 1
+
+		proof := ethdb.NewMemDatabase()
+		if err := trie.Prove(entries[start].k, 0, proof); err != nil {
+			t.Fatalf("Failed to prove the first node %v", err)
+		}
+		if err := trie.Prove(entries[end-1].k, 0, proof); err != nil {
+			t.Fatalf("Failed to prove the last node %v", err)
+		}
+		var keys, vals [][]byte
+		for i := start; i < end; i++ {
+			keys = append(keys, entries[i].k)
+			vals = append(vals, entries[i].v)
+		}
+		more, err := VerifyRangeProof(trie.Hash(), keys[0], keys[len(keys)-1], keys, vals, proof)
+		if err != nil {
+			t.Fatalf("Case %d(%d->%d) expect no error, got %v", i, start, end-1, err)
+		}
+		if more != (end < len(entries)) {
+			t.Fatalf("Case %d(%d->%d) wrong continuation flag: have %v, want %v", i, start, end-1, more, end < len(entries))
+		}
+	}
+}
+
+// TestRangeProofWithNonExistentProof tests normal range proofs with the first
+// edge proof being a non-existent proof.
+func TestRangeProofWithNonExistentProof(t *testing.T) {
+	trie, vals := randomTrie(4096)
+	entries := sortedEntries(vals)
+
+	for i := 0; i < 500; i++ {
+		start := mrand.Intn(len(entries))
+		end := mrand.Intn(len(entries)-start) + start + 1
+
+		// Short circuit if the decreased key is same with the previous key
+		first := decreaseKey(common.CopyBytes(entries[start].k))
+		if start != 0 && bytes.Equal(first, entries[start-1].k) {
+			continue
+		}
+		// Short circuit if the decreased key underflowed
+		if bytes.Compare(first, entries[start].k) >= 0 {
+			continue
+		}
+		proof := ethdb.NewMemDatabase()
+		if err := trie.Prove(first, 0, proof); err != nil {
+			t.Fatalf("Failed to prove the first node %v", err)
+		}
+		if err := trie.Prove(entries[end-1].k, 0, proof); err != nil {
+			t.Fatalf("Failed to prove the last node %v", err)
+		}
+		var keys, vals [][]byte
+		for i := start; i < end; i++ {
+			keys = append(keys, entries[i].k)
+			vals = append(vals, entries[i].v)
+		}
+		if _, err := VerifyRangeProof(trie.Hash(), first, keys[len(keys)-1], keys, vals, proof); err != nil {
+			t.Fatalf("Case %d(%d->%d) expect no error, got %v", i, start, end-1, err)
+		}
+	}
+}
+
+// TestBadRangeProof tests that a range proof with a modified, missing or
+// injected leaf is rejected.
+func TestBadRangeProof(t *testing.T) {
+	trie, vals := randomTrie(4096)
+	entries := sortedEntries(vals)
+
+	for i := 0; i < 500; i++ {
+		start := mrand.Intn(len(entries))
+		end := mrand.Intn(len(entries)-start) + start + 1
+		if end-start < 3 {
+			continue
+		}
+		proof := ethdb.NewMemDatabase()
+		if err := trie.Prove(entries[start].k, 0, proof); err != nil {
+			t.Fatalf("Failed to prove the first node %v", err)
+		}
+		if err := trie.Prove(entries[end-1].k, 0, proof); err != nil {
+			t.Fatalf("Failed to prove the last node %v", err)
+		}
+		var keys, vals [][]byte
+		for i := start; i < end; i++ {
+			keys = append(keys, entries[i].k)
+			vals = append(vals, common.CopyBytes(entries[i].v))
+		}
+		first, last := keys[0], keys[len(keys)-1]
+
+		switch mrand.Intn(2) {
+		case 0:
+			// Modified leaf
+			vals[1] = randBytes(20)
+		case 1:
+			// Gapped entry
+			keys = append(keys[:1], keys[2:]...)
+			vals = append(vals[:1], vals[2:]...)
+		}
+		if _, err := VerifyRangeProof(trie.Hash(), first, last, keys, vals, proof); err == nil {
+			t.Fatalf("Case %d(%d->%d) expected error, got nil", i, start, end-1)
+		}
+	}
+}
+
+// TestAllElementsProof tests that a range covering the entire trie can be
+// verified without any edge proofs.
+func TestAllElementsProof(t *testing.T) {
+	trie, vals := randomTrie(4096)
+	entries := sortedEntries(vals)
+
+	var keys, vals2 [][]byte
+	for _, entry := range entries {
+		keys = append(keys, entry.k)
+		vals2 = append(vals2, entry.v)
+	}
+	more, err := VerifyRangeProof(trie.Hash(), nil, nil, keys, vals2, nil)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if more {
+		t.Fatalf("Expected no more elements")
+	}
+	// Drop an element and ensure the verification fails
+	if _, err := VerifyRangeProof(trie.Hash(), nil, nil, keys[1:], vals2[1:], nil); err == nil {
+		t.Fatalf("Expected error for missing element")
+	}
+}
+
+// TestEmptyRangeProof tests that an empty range is only accepted if there
+// are really no more elements after the origin.
+func TestEmptyRangeProof(t *testing.T) {
+	trie, vals := randomTrie(4096)
+	entries := sortedEntries(vals)
+
+	// Proving no elements after the last one should succeed, unless the
+	// increased key overflowed
+	last := increaseKey(common.CopyBytes(entries[len(entries)-1].k))
+	if bytes.Compare(last, entries[len(entries)-1].k) > 0 {
+		proof := ethdb.NewMemDatabase()
+		if err := trie.Prove(last, 0, proof); err != nil {
+			t.Fatalf("Failed to prove the first node %v", err)
+		}
+		if _, err := VerifyRangeProof(trie.Hash(), last, nil, nil, nil, proof); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+	}
+	// Proving no elements in the middle of the trie should fail
+	first := decreaseKey(common.CopyBytes(entries[len(entries)/2].k))
+	proof := ethdb.NewMemDatabase()
+	if err := trie.Prove(first, 0, proof); err != nil {
+		t.Fatalf("Failed to prove the first node %v", err)
+	}
+	if _, err := VerifyRangeProof(trie.Hash(), first, nil, nil, nil, proof); err == nil {
+		t.Fatalf("Expected error, got nil")
+	}
+}
+
+func increaseKey(key []byte) []byte {
+	for i := len(key) - 1; i >= 0; i-- {
+		key[i]++
+		if key[i] != 0x0 {
+			break
+		}
+	}
+	return key
+}
+
+func decreaseKey(key []byte) []byte {
+	for i := len(key) - 1; i >= 0; i-- {
+		key[i]--
+		if key[i] != 0xff {
+			break
+		}
+	}
+	return key
+}
+
 // mutateByte changes one byte in b.
 func mutateByte(b []byte) {
 	for r := mrand.Intn(len(b)); ; {